@@ -29,7 +29,7 @@ func NewBimapRedisReplicator(redisClient *redis.Client, forStorage string) Memor
 
 // Replicate реплицирует данные из MemorySetStorage в резервное хранилище (в данном случае в Redis).
 func (r *BitmapRedisReplicator) Replicate(ctx context.Context, storage MemorySetStorage, replicationKey string, ttl time.Duration) error {
-	bitmapBytes, err := storage.GetBytesFromBitmap()
+	bitmapBytes, err := storage.Snapshot()
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,116 @@
+package memory_storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+// BitmapDiff — разница двух поколений множества: Added есть только в текущем, Removed — только в другом.
+type BitmapDiff struct {
+	Added   *roaring64.Bitmap
+	Removed *roaring64.Bitmap
+}
+
+// Size возвращает число расходящихся ключей в обе стороны.
+func (d BitmapDiff) Size() uint64 {
+	return d.Added.GetCardinality() + d.Removed.GetCardinality()
+}
+
+// ReplicaDivergence — итог последней сверки с репликой без самих ключей.
+type ReplicaDivergence struct {
+	At      time.Time
+	Added   uint64 // есть локально, нет в реплике
+	Removed uint64 // есть в реплике, нет локально
+}
+
+// MemorySetDiffer — сравнение поколений bitmap. Реализуется хранилищем NewBitmapStorage.
+type MemorySetDiffer interface {
+	// Diff сравнивает хранилище с other; обе стороны снимаются копиями, писатели не блокируются
+	Diff(other MemorySetStorage) (BitmapDiff, error)
+	// DiffAgainstReplica читает копию из репликатора по ReplicationKey и сравнивает с ней хранилище
+	DiffAgainstReplica(ctx context.Context) (BitmapDiff, error)
+	// LastReplicaDivergence возвращает итог последней DiffAgainstReplica; false — сверок не было
+	LastReplicaDivergence() (ReplicaDivergence, bool)
+}
+
+func (s *roaringBitmapStorage) Diff(other MemorySetStorage) (BitmapDiff, error) {
+	if other == nil {
+		return BitmapDiff{}, fmt.Errorf("[%s] diff storage must be not nil", s.configs.StorageName)
+	}
+	theirs, err := bitmapCopy(other)
+	if err != nil {
+		return BitmapDiff{}, err
+	}
+	ours := s.clone()
+	return BitmapDiff{
+		Added:   roaring64.AndNot(ours, theirs),
+		Removed: roaring64.AndNot(theirs, ours),
+	}, nil
+}
+
+// DiffAgainstReplica восстанавливает реплику во временное хранилище тем же репликатором и сравнивает
+// с ним текущее. В Added попадают и записи после последней Replicate, поэтому сигнал — рост
+// расхождения между циклами репликации, а не само ненулевое значение. Итог сохраняется для
+// LastReplicaDivergence и передаётся в BitmapStorageConfigs.OnReplicaDivergence.
+func (s *roaringBitmapStorage) DiffAgainstReplica(ctx context.Context) (BitmapDiff, error) {
+	replica := NewBitmapStorage(s.replicator, BitmapStorageConfigs{
+		StorageName: s.configs.StorageName + ":replica",
+		Clock:       s.configs.Clock,
+	}, &Warmer{BatchSize: 1})
+	if err := s.replicator.Recover(ctx, replica, s.configs.ReplicationKey); err != nil {
+		return BitmapDiff{}, fmt.Errorf("[%s] read replica %q: %w", s.configs.StorageName, s.configs.ReplicationKey, err)
+	}
+	diff, err := s.Diff(replica)
+	if err != nil {
+		return BitmapDiff{}, err
+	}
+	s.lastReplicaDiff.Store(&ReplicaDivergence{
+		At:      s.configs.Clock.Now(),
+		Added:   diff.Added.GetCardinality(),
+		Removed: diff.Removed.GetCardinality(),
+	})
+	if s.configs.OnReplicaDivergence != nil {
+		s.configs.OnReplicaDivergence(diff)
+	}
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] replica divergence: added=%d removed=%d", s.configs.StorageName, diff.Added.GetCardinality(), diff.Removed.GetCardinality()))
+	}
+	return diff, nil
+}
+
+func (s *roaringBitmapStorage) LastReplicaDivergence() (ReplicaDivergence, bool) {
+	d := s.lastReplicaDiff.Load()
+	if d == nil {
+		return ReplicaDivergence{}, false
+	}
+	return *d, true
+}
+
+// clone делает полную копию bitmap; без copy-on-write Clone оригинал только читает.
+func (s *roaringBitmapStorage) clone() *roaring64.Bitmap {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitmap.Clone()
+}
+
+// bitmapCopy снимает копию произвольного хранилища: своё клонируется напрямую, чужое — через Snapshot.
+func bitmapCopy(storage MemorySetStorage) (*roaring64.Bitmap, error) {
+	if rs, ok := storage.(*roaringBitmapStorage); ok {
+		return rs.clone(), nil
+	}
+	data, err := storage.Snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("snapshot bitmap: %w", err)
+	}
+	out := roaring64.NewBitmap()
+	if len(data) > 0 {
+		if _, err := out.ReadFrom(bytes.NewReader(data)); err != nil {
+			return nil, fmt.Errorf("read bitmap snapshot: %w", err)
+		}
+	}
+	return out, nil
+}
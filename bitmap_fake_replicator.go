@@ -23,7 +23,7 @@ func NewBitmapFakeReplicator(forStorage string) MemorySetStorageReplicator {
 }
 
 func (r *BitmapFakeReplicator) Replicate(ctx context.Context, storage MemorySetStorage, replicationKey string, ttl time.Duration) error {
-	bitmapBytes, err := storage.GetBytesFromBitmap()
+	bitmapBytes, err := storage.Snapshot()
 	if err != nil {
 		return err
 	}
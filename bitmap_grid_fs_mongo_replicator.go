@@ -72,7 +72,7 @@ package memory_storage
 //		}
 //	}
 //
-//	bitmapBytes, err := storage.GetBytesFromBitmap()
+//	bitmapBytes, err := storage.Snapshot()
 //	if err != nil {
 //		return err
 //	}
@@ -0,0 +1,163 @@
+package memory_storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	bitmappb "github.com/PavelAgarkov/memory-storage/protobuf/bitmap"
+	"google.golang.org/grpc"
+)
+
+// RemoteSetClient — MemorySet поверх BitmapServer. Методы MemorySet не возвращают ошибок: при сбое
+// вызова Contains отвечает false, GetCount — 0, а ошибка уходит в RemoteSetConfigs.OnError. Там,
+// где ошибку нужно обработать, используйте варианты с Context.
+type RemoteSetClient struct {
+	configs RemoteSetConfigs
+	client  bitmappb.BitmapServiceClient
+}
+
+type RemoteSetConfigs struct {
+	StorageName string
+	// Set — имя множества на сервере, пустое — множество по умолчанию.
+	Set string
+	// Timeout — срок одного вызова методов MemorySet; 0 — 2 секунды.
+	Timeout time.Duration
+	// BatchSize — ключей в одном запросе UpsertMany/RemoveMany/ContainsMany; 0 — 100_000.
+	// Должен быть не больше MaxKeysPerRequest сервера.
+	BatchSize int
+	// OnError получает ошибки методов MemorySet; nil — ошибка печатается в лог.
+	OnError func(op string, err error)
+}
+
+const (
+	defaultRemoteSetTimeout   = 2 * time.Second
+	defaultRemoteSetBatchSize = 100_000
+)
+
+func NewRemoteSetClient(conn grpc.ClientConnInterface, configs RemoteSetConfigs) *RemoteSetClient {
+	if conn == nil {
+		panic(fmt.Sprintf("[%s] remote set connection must be not nil", configs.StorageName))
+	}
+	if configs.Timeout <= 0 {
+		configs.Timeout = defaultRemoteSetTimeout
+	}
+	if configs.BatchSize <= 0 {
+		configs.BatchSize = defaultRemoteSetBatchSize
+	}
+	return &RemoteSetClient{configs: configs, client: bitmappb.NewBitmapServiceClient(conn)}
+}
+
+func (c *RemoteSetClient) Contains(key uint64) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.configs.Timeout)
+	defer cancel()
+	hit, err := c.ContainsContext(ctx, key)
+	c.report("contains", err)
+	return hit
+}
+
+func (c *RemoteSetClient) UpsertMany(keys []uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.configs.Timeout)
+	defer cancel()
+	c.report("upsert", c.UpsertManyContext(ctx, keys))
+}
+
+func (c *RemoteSetClient) RemoveMany(keys []uint64) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.configs.Timeout)
+	defer cancel()
+	c.report("remove", c.RemoveManyContext(ctx, keys))
+}
+
+func (c *RemoteSetClient) GetCount() uint64 {
+	ctx, cancel := context.WithTimeout(context.Background(), c.configs.Timeout)
+	defer cancel()
+	n, err := c.CardinalityContext(ctx)
+	c.report("cardinality", err)
+	return n
+}
+
+// ContainsMany — членство keys одним запросом на BatchSize ключей; при сбое — все false.
+func (c *RemoteSetClient) ContainsMany(keys []uint64) []bool {
+	ctx, cancel := context.WithTimeout(context.Background(), c.configs.Timeout)
+	defer cancel()
+	out, err := c.ContainsManyContext(ctx, keys)
+	if err != nil {
+		c.report("contains_many", err)
+		return make([]bool, len(keys))
+	}
+	return out
+}
+
+func (c *RemoteSetClient) ContainsContext(ctx context.Context, key uint64) (bool, error) {
+	resp, err := c.client.Contains(ctx, &bitmappb.ContainsRequest{Set: c.configs.Set, Key: key})
+	if err != nil {
+		return false, err
+	}
+	return resp.GetContains(), nil
+}
+
+// ContainsManyContext возвращает членство keys[i] в out[i].
+func (c *RemoteSetClient) ContainsManyContext(ctx context.Context, keys []uint64) ([]bool, error) {
+	out := make([]bool, 0, len(keys))
+	err := c.batches(keys, func(batch []uint64) error {
+		resp, err := c.client.ContainsMany(ctx, &bitmappb.KeysRequest{Set: c.configs.Set, Keys: batch})
+		if err != nil {
+			return err
+		}
+		if len(resp.GetContains()) != len(batch) {
+			return fmt.Errorf("contains many: %d answers for %d keys", len(resp.GetContains()), len(batch))
+		}
+		out = append(out, resp.GetContains()...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// UpsertManyContext добавляет keys; при ошибке часть пачек может быть уже применена.
+func (c *RemoteSetClient) UpsertManyContext(ctx context.Context, keys []uint64) error {
+	return c.batches(keys, func(batch []uint64) error {
+		_, err := c.client.UpsertMany(ctx, &bitmappb.KeysRequest{Set: c.configs.Set, Keys: batch})
+		return err
+	})
+}
+
+// RemoveManyContext удаляет keys; при ошибке часть пачек может быть уже применена.
+func (c *RemoteSetClient) RemoveManyContext(ctx context.Context, keys []uint64) error {
+	return c.batches(keys, func(batch []uint64) error {
+		_, err := c.client.RemoveMany(ctx, &bitmappb.KeysRequest{Set: c.configs.Set, Keys: batch})
+		return err
+	})
+}
+
+func (c *RemoteSetClient) CardinalityContext(ctx context.Context) (uint64, error) {
+	resp, err := c.client.Cardinality(ctx, &bitmappb.CardinalityRequest{Set: c.configs.Set})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetCount(), nil
+}
+
+func (c *RemoteSetClient) batches(keys []uint64, fn func([]uint64) error) error {
+	for len(keys) > 0 {
+		n := min(len(keys), c.configs.BatchSize)
+		if err := fn(keys[:n]); err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
+
+func (c *RemoteSetClient) report(op string, err error) {
+	if err == nil {
+		return
+	}
+	if c.configs.OnError != nil {
+		c.configs.OnError(op, err)
+		return
+	}
+	fmt.Println(fmt.Sprintf("[%s] remote set %s: %v", c.configs.StorageName, op, err))
+}
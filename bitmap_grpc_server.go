@@ -0,0 +1,113 @@
+package memory_storage
+
+import (
+	"context"
+	"fmt"
+
+	bitmappb "github.com/PavelAgarkov/memory-storage/protobuf/bitmap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BitmapServer отдаёт множества узла по gRPC (api/bitmap/bitmap.proto): несколько небольших сервисов
+// работают с одним большим bitmap в памяти этого узла через RemoteSetClient, а не держат и не
+// реплицируют каждый свою копию. Прогрев, репликация и журнал операций остаются на узле.
+type BitmapServer struct {
+	bitmappb.UnimplementedBitmapServiceServer
+	configs BitmapServerConfigs
+	sets    map[string]MemorySet
+}
+
+type BitmapServerConfigs struct {
+	StorageName string
+	// MaxKeysPerRequest — предел ключей в одном ContainsMany/UpsertMany/RemoveMany; 0 — 1_000_000.
+	MaxKeysPerRequest int
+	DebugLogs         bool // флаг для включения/отключения отладочных логов
+}
+
+const defaultBitmapMaxKeysPerRequest = 1_000_000
+
+// NewBitmapServer создаёт сервер множеств sets; ключ — имя множества в запросах ("" — по умолчанию).
+func NewBitmapServer(sets map[string]MemorySet, configs BitmapServerConfigs) *BitmapServer {
+	if len(sets) == 0 {
+		panic(fmt.Sprintf("[%s] bitmap server sets must be not empty", configs.StorageName))
+	}
+	for name, set := range sets {
+		if set == nil {
+			panic(fmt.Sprintf("[%s] bitmap server set %q must be not nil", configs.StorageName, name))
+		}
+	}
+	if configs.MaxKeysPerRequest <= 0 {
+		configs.MaxKeysPerRequest = defaultBitmapMaxKeysPerRequest
+	}
+	return &BitmapServer{configs: configs, sets: sets}
+}
+
+// Register регистрирует сервис на gRPC-сервере.
+func (s *BitmapServer) Register(r grpc.ServiceRegistrar) {
+	bitmappb.RegisterBitmapServiceServer(r, s)
+}
+
+func (s *BitmapServer) Contains(_ context.Context, req *bitmappb.ContainsRequest) (*bitmappb.ContainsResponse, error) {
+	set, err := s.set(req.GetSet())
+	if err != nil {
+		return nil, err
+	}
+	return &bitmappb.ContainsResponse{Contains: set.Contains(req.GetKey())}, nil
+}
+
+func (s *BitmapServer) ContainsMany(_ context.Context, req *bitmappb.KeysRequest) (*bitmappb.ContainsManyResponse, error) {
+	set, err := s.keysSet(req)
+	if err != nil {
+		return nil, err
+	}
+	return &bitmappb.ContainsManyResponse{Contains: set.ContainsMany(req.GetKeys())}, nil
+}
+
+func (s *BitmapServer) UpsertMany(_ context.Context, req *bitmappb.KeysRequest) (*bitmappb.MutationResponse, error) {
+	set, err := s.keysSet(req)
+	if err != nil {
+		return nil, err
+	}
+	set.UpsertMany(req.GetKeys())
+	if s.configs.DebugLogs {
+		fmt.Println(fmt.Sprintf("[%s] remote upsert of %d keys into %q", s.configs.StorageName, len(req.GetKeys()), req.GetSet()))
+	}
+	return &bitmappb.MutationResponse{}, nil
+}
+
+func (s *BitmapServer) RemoveMany(_ context.Context, req *bitmappb.KeysRequest) (*bitmappb.MutationResponse, error) {
+	set, err := s.keysSet(req)
+	if err != nil {
+		return nil, err
+	}
+	set.RemoveMany(req.GetKeys())
+	if s.configs.DebugLogs {
+		fmt.Println(fmt.Sprintf("[%s] remote removal of %d keys from %q", s.configs.StorageName, len(req.GetKeys()), req.GetSet()))
+	}
+	return &bitmappb.MutationResponse{}, nil
+}
+
+func (s *BitmapServer) Cardinality(_ context.Context, req *bitmappb.CardinalityRequest) (*bitmappb.CardinalityResponse, error) {
+	set, err := s.set(req.GetSet())
+	if err != nil {
+		return nil, err
+	}
+	return &bitmappb.CardinalityResponse{Count: set.GetCount()}, nil
+}
+
+func (s *BitmapServer) set(name string) (MemorySet, error) {
+	set, ok := s.sets[name]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "set %q not found", name)
+	}
+	return set, nil
+}
+
+func (s *BitmapServer) keysSet(req *bitmappb.KeysRequest) (MemorySet, error) {
+	if n := len(req.GetKeys()); n > s.configs.MaxKeysPerRequest {
+		return nil, status.Errorf(codes.InvalidArgument, "%d keys exceed the limit of %d per request", n, s.configs.MaxKeysPerRequest)
+	}
+	return s.set(req.GetSet())
+}
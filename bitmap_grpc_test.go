@@ -0,0 +1,89 @@
+package memory_storage
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestBitmapConn(t *testing.T, server *BitmapServer) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	server.Register(srv)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestRemoteSetClient_RoundTrip(t *testing.T) {
+	set := newTestBitmapStorage("remote")
+	server := NewBitmapServer(map[string]MemorySet{"users": set}, BitmapServerConfigs{StorageName: "server", MaxKeysPerRequest: 2})
+	conn := newTestBitmapConn(t, server)
+
+	var _ MemorySet = (*RemoteSetClient)(nil)
+	client := NewRemoteSetClient(conn, RemoteSetConfigs{
+		StorageName: "client",
+		Set:         "users",
+		BatchSize:   2,
+		OnError:     func(op string, err error) { t.Fatalf("%s: %v", op, err) },
+	})
+
+	// 5 ключей при BatchSize 2 — три запроса, каждый в пределах MaxKeysPerRequest
+	client.UpsertMany([]uint64{1, 2, 3, 4, 5})
+	if set.GetCount() != 5 || client.GetCount() != 5 {
+		t.Fatalf("count: local=%d remote=%d, want 5", set.GetCount(), client.GetCount())
+	}
+	client.RemoveMany([]uint64{2, 4})
+	if !client.Contains(1) || client.Contains(2) {
+		t.Fatalf("unexpected Contains results")
+	}
+	got := client.ContainsMany([]uint64{1, 2, 3, 4, 5, 6})
+	want := []bool{true, false, true, false, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ContainsMany = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRemoteSetClient_Errors(t *testing.T) {
+	server := NewBitmapServer(map[string]MemorySet{"": newTestBitmapStorage("remote")}, BitmapServerConfigs{StorageName: "server", MaxKeysPerRequest: 2})
+	conn := newTestBitmapConn(t, server)
+
+	var ops []string
+	client := NewRemoteSetClient(conn, RemoteSetConfigs{
+		StorageName: "client",
+		Set:         "missing",
+		OnError:     func(op string, err error) { ops = append(ops, op) },
+	})
+	if client.Contains(1) {
+		t.Fatalf("failed Contains must return false")
+	}
+	if len(ops) != 1 || ops[0] != "contains" {
+		t.Fatalf("OnError calls = %v, want [contains]", ops)
+	}
+	if _, err := client.ContainsContext(context.Background(), 1); status.Code(err) != codes.NotFound {
+		t.Fatalf("unknown set: %v, want NotFound", err)
+	}
+
+	// без разбиения на пачки запрос превышает MaxKeysPerRequest сервера
+	big := NewRemoteSetClient(conn, RemoteSetConfigs{StorageName: "client", BatchSize: 3})
+	if err := big.UpsertManyContext(context.Background(), []uint64{1, 2, 3}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("oversized request: %v, want InvalidArgument", err)
+	}
+}
@@ -0,0 +1,239 @@
+package memory_storage
+
+import (
+	bytes2 "bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type MigrationProxyConfigs struct {
+	StorageName string
+	// SampleRate — доля вызовов Contains и ContainsMany (0..1), для которых результат сверяется со вторым хранилищем.
+	SampleRate float64
+	// OnDivergence вызывается при расхождении ответов Contains; может быть nil.
+	OnDivergence func(key uint64, primaryHit, secondaryHit bool)
+	DebugLogs    bool // флаг для включения/отключения отладочных логов
+}
+
+// MigrationProxy — хранилище для безопасной замены реализации в проде:
+// все записи зеркалируются в оба хранилища, чтения обслуживаются основным,
+// а часть Contains сверяется со вторым с подсчётом расхождений.
+type MigrationProxy struct {
+	configs MigrationProxyConfigs
+
+	mu        sync.RWMutex
+	primary   MemorySetStorage
+	secondary MemorySetStorage
+
+	sampled     atomic.Uint64
+	divergences atomic.Uint64
+}
+
+func NewMigrationProxy(primary, secondary MemorySetStorage, configs MigrationProxyConfigs) *MigrationProxy {
+	if primary == nil || secondary == nil {
+		panic(fmt.Sprintf("[%s] migration proxy storages must be not nil", configs.StorageName))
+	}
+	if configs.SampleRate < 0 || configs.SampleRate > 1 {
+		panic(fmt.Sprintf("[%s] sample rate must be in [0, 1]", configs.StorageName))
+	}
+	p := &MigrationProxy{
+		configs:   configs,
+		primary:   primary,
+		secondary: secondary,
+	}
+	p.switchReplicationLocked()
+	return p
+}
+
+// backgroundReplicator — хранилище, фоновую репликацию которого можно отключить.
+type backgroundReplicator interface {
+	setBackgroundReplication(on bool)
+}
+
+// switchReplication оставляет фоновую репликацию только основному хранилищу: хранилища обычно делят
+// ключ репликации, и второе перезаписывало бы реплику по своему ReplicationTicker. Вызывается под p.mu.
+func (p *MigrationProxy) switchReplicationLocked() {
+	if r, ok := p.primary.(backgroundReplicator); ok {
+		r.setBackgroundReplication(true)
+	}
+	if r, ok := p.secondary.(backgroundReplicator); ok {
+		r.setBackgroundReplication(false)
+	}
+}
+
+// Promote меняет хранилища местами: второе становится источником чтений.
+// Записи продолжают зеркалироваться, так что откат — повторный вызов Promote.
+func (p *MigrationProxy) Promote() {
+	p.mu.Lock()
+	p.primary, p.secondary = p.secondary, p.primary
+	p.switchReplicationLocked()
+	p.mu.Unlock()
+	if p.configs.DebugLogs {
+		fmt.Println(fmt.Sprintf("[%s] migration proxy storages swapped", p.configs.StorageName))
+	}
+}
+
+// Divergences возвращает число расхождений, найденных при сверке Contains.
+func (p *MigrationProxy) Divergences() uint64 {
+	return p.divergences.Load()
+}
+
+// SampledChecks возвращает число выполненных сверок Contains.
+func (p *MigrationProxy) SampledChecks() uint64 {
+	return p.sampled.Load()
+}
+
+func (p *MigrationProxy) storages() (MemorySetStorage, MemorySetStorage) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.primary, p.secondary
+}
+
+func (p *MigrationProxy) MustWarmer(ctx context.Context, warmerFunc WarmerFunc) {
+	primary, secondary := p.storages()
+	primary.MustWarmer(ctx, warmerFunc)
+	secondary.MustWarmer(ctx, warmerFunc)
+}
+
+func (p *MigrationProxy) Contains(key uint64) bool {
+	primary, secondary := p.storages()
+	hit := primary.Contains(key)
+	if p.shouldSample() {
+		p.compare(key, hit, secondary.Contains(key))
+	}
+	return hit
+}
+
+// ContainsMany отвечает по primary; при попадании в выборку сверяется вся пачка.
+func (p *MigrationProxy) ContainsMany(keys []uint64) []bool {
+	primary, secondary := p.storages()
+	hits := primary.ContainsMany(keys)
+	if len(keys) == 0 || !p.shouldSample() {
+		return hits
+	}
+	secondaryHits := secondary.ContainsMany(keys)
+	for i, k := range keys {
+		p.compare(k, hits[i], secondaryHits[i])
+	}
+	return hits
+}
+
+func (p *MigrationProxy) ContainsAll(keys []uint64) bool {
+	primary, _ := p.storages()
+	return primary.ContainsAll(keys)
+}
+
+func (p *MigrationProxy) ContainsAny(keys []uint64) bool {
+	primary, _ := p.storages()
+	return primary.ContainsAny(keys)
+}
+
+func (p *MigrationProxy) shouldSample() bool {
+	return p.configs.SampleRate > 0 && rand.Float64() < p.configs.SampleRate
+}
+
+func (p *MigrationProxy) compare(key uint64, hit, secondaryHit bool) {
+	p.sampled.Add(1)
+	if secondaryHit == hit {
+		return
+	}
+	p.divergences.Add(1)
+	if p.configs.DebugLogs {
+		fmt.Println(fmt.Sprintf("[%s] divergence for key %d: primary=%t secondary=%t", p.configs.StorageName, key, hit, secondaryHit))
+	}
+	if p.configs.OnDivergence != nil {
+		p.configs.OnDivergence(key, hit, secondaryHit)
+	}
+}
+
+func (p *MigrationProxy) UpsertMany(keys []uint64) {
+	primary, secondary := p.storages()
+	primary.UpsertMany(keys)
+	secondary.UpsertMany(keys)
+}
+
+func (p *MigrationProxy) RemoveMany(keys []uint64) {
+	primary, secondary := p.storages()
+	primary.RemoveMany(keys)
+	secondary.RemoveMany(keys)
+}
+
+func (p *MigrationProxy) UpsertRange(lo, hi uint64) {
+	primary, secondary := p.storages()
+	primary.UpsertRange(lo, hi)
+	secondary.UpsertRange(lo, hi)
+}
+
+func (p *MigrationProxy) RemoveRange(lo, hi uint64) {
+	primary, secondary := p.storages()
+	primary.RemoveRange(lo, hi)
+	secondary.RemoveRange(lo, hi)
+}
+
+func (p *MigrationProxy) GetCount() uint64 {
+	primary, _ := p.storages()
+	return primary.GetCount()
+}
+
+func (p *MigrationProxy) Clear() {
+	primary, secondary := p.storages()
+	primary.Clear()
+	secondary.Clear()
+}
+
+func (p *MigrationProxy) Warm(ctx context.Context) error {
+	primary, secondary := p.storages()
+	return errors.Join(primary.Warm(ctx), secondary.Warm(ctx))
+}
+
+// ReadFromBuffer читает буфер в оба хранилища; каждое получает собственную копию данных.
+func (p *MigrationProxy) ReadFromBuffer(ctx context.Context, buffer *bytes2.Buffer) (int64, error) {
+	primary, secondary := p.storages()
+	data := cloneBytes(buffer.Bytes())
+	n, err := primary.ReadFromBuffer(ctx, buffer)
+	if err != nil {
+		return n, err
+	}
+	if _, err := secondary.ReadFromBuffer(ctx, bytes2.NewBuffer(data)); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+func (p *MigrationProxy) GetBytesFromBitmap() ([]byte, error) {
+	primary, _ := p.storages()
+	return primary.GetBytesFromBitmap()
+}
+
+func (p *MigrationProxy) Snapshot() ([]byte, error) {
+	primary, _ := p.storages()
+	return primary.Snapshot()
+}
+
+func (p *MigrationProxy) Recover(ctx context.Context) error {
+	primary, secondary := p.storages()
+	return errors.Join(primary.Recover(ctx), secondary.Recover(ctx))
+}
+
+// Replicate реплицирует только основное хранилище: хранилища обычно делят ключ репликации, и запись
+// обоих оставила бы в реплике того, кто записал последним. После Promote реплику ведёт новое основное.
+func (p *MigrationProxy) Replicate(ctx context.Context) error {
+	primary, _ := p.storages()
+	return primary.Replicate(ctx)
+}
+
+// DropReplicationKey удаляет ключ репликации основного хранилища — того, что ведёт реплику.
+func (p *MigrationProxy) DropReplicationKey(ctx context.Context) error {
+	primary, _ := p.storages()
+	return primary.DropReplicationKey(ctx)
+}
+
+func (p *MigrationProxy) Replay(ctx context.Context, from time.Time) error {
+	primary, secondary := p.storages()
+	return errors.Join(primary.Replay(ctx, from), secondary.Replay(ctx, from))
+}
@@ -0,0 +1,171 @@
+package memory_storage
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newTestBitmapStorage(name string) MemorySetStorage {
+	return NewBitmapStorage(
+		NewBitmapStubReplicator(),
+		BitmapStorageConfigs{
+			StorageName:       name,
+			MonitoringTicker:  time.Minute,
+			OptimizingTicker:  time.Minute,
+			ReplicationTicker: time.Minute,
+			ReplicationKey:    name,
+		},
+		&Warmer{BatchSize: 10},
+	)
+}
+
+func TestMigrationProxy_MirrorsWritesAndReportsDivergence(t *testing.T) {
+	primary := newTestBitmapStorage("primary")
+	secondary := newTestBitmapStorage("secondary")
+
+	var reported []uint64
+	proxy := NewMigrationProxy(primary, secondary, MigrationProxyConfigs{
+		StorageName: "proxy",
+		SampleRate:  1,
+		OnDivergence: func(key uint64, primaryHit, secondaryHit bool) {
+			reported = append(reported, key)
+		},
+	})
+
+	proxy.UpsertMany([]uint64{1, 2, 3})
+	proxy.RemoveMany([]uint64{2})
+	if primary.GetCount() != 2 || secondary.GetCount() != 2 {
+		t.Fatalf("writes must be mirrored: primary=%d secondary=%d", primary.GetCount(), secondary.GetCount())
+	}
+	if !proxy.Contains(1) || proxy.Contains(2) {
+		t.Fatalf("unexpected Contains results")
+	}
+	if proxy.Divergences() != 0 || proxy.SampledChecks() != 2 {
+		t.Fatalf("divergences=%d sampled=%d, want 0 and 2", proxy.Divergences(), proxy.SampledChecks())
+	}
+
+	// расхождение: ключ есть только во втором хранилище
+	secondary.UpsertMany([]uint64{10})
+	if proxy.Contains(10) {
+		t.Fatalf("reads must be served by primary")
+	}
+	if proxy.Divergences() != 1 || len(reported) != 1 || reported[0] != 10 {
+		t.Fatalf("divergence must be reported once for key 10, got %v", reported)
+	}
+
+	proxy.Promote()
+	if !proxy.Contains(10) {
+		t.Fatalf("after Promote reads must be served by secondary")
+	}
+}
+
+// keyRecordingReplicator запоминает, через какие ключи шла репликация.
+type keyRecordingReplicator struct {
+	BitmapStubReplicator
+	replicated []string
+	dropped    []string
+}
+
+func (r *keyRecordingReplicator) Replicate(_ context.Context, _ MemorySetStorage, replicationKey string, _ time.Duration) error {
+	r.replicated = append(r.replicated, replicationKey)
+	return nil
+}
+
+func (r *keyRecordingReplicator) DropReplicationKey(_ context.Context, replicationKey string) error {
+	r.dropped = append(r.dropped, replicationKey)
+	return nil
+}
+
+func TestMigrationProxy_ReplicatesOnlyPrimary(t *testing.T) {
+	replicator := &keyRecordingReplicator{}
+	newStorage := func(name string) MemorySetStorage {
+		return NewBitmapStorage(replicator, BitmapStorageConfigs{
+			StorageName:       name,
+			MonitoringTicker:  time.Minute,
+			OptimizingTicker:  time.Minute,
+			ReplicationTicker: time.Minute,
+			ReplicationKey:    name,
+		}, &Warmer{BatchSize: 10})
+	}
+	proxy := NewMigrationProxy(newStorage("old"), newStorage("new"), MigrationProxyConfigs{StorageName: "proxy"})
+
+	ctx := context.Background()
+	if err := proxy.Replicate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	proxy.Promote()
+	if err := proxy.Replicate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := proxy.DropReplicationKey(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(replicator.replicated) != 2 || replicator.replicated[0] != "old" || replicator.replicated[1] != "new" {
+		t.Fatalf("replication must go through primary only, got %v", replicator.replicated)
+	}
+	if len(replicator.dropped) != 1 || replicator.dropped[0] != "new" {
+		t.Fatalf("drop must go through primary only, got %v", replicator.dropped)
+	}
+}
+
+func TestMigrationProxy_SharedReplicationKeyBackgroundLoops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const (
+		monitoring  = 1 * time.Second
+		optimizing  = 2 * time.Second
+		replication = 3 * time.Second
+	)
+	replicator := NewBitmapFakeReplicator("shared")
+	newStorage := func(name string, clock Clock) MemorySetStorage {
+		return NewBitmapStorage(replicator, BitmapStorageConfigs{
+			StorageName:       name,
+			MonitoringTicker:  monitoring,
+			OptimizingTicker:  optimizing,
+			ReplicationTicker: replication,
+			ReplicationKey:    "shared",
+			Clock:             clock,
+		}, &Warmer{BatchSize: 10})
+	}
+	oldClock := newManualClock(time.Unix(1_700_000_000, 0))
+	newClock := newManualClock(time.Unix(1_700_000_000, 0))
+	current, next := newStorage("old", oldClock), newStorage("new", newClock)
+	proxy := NewMigrationProxy(current, next, MigrationProxyConfigs{StorageName: "proxy"})
+	proxy.MustWarmer(ctx, func(ctx context.Context, batchSize int32) ([]uint64, error) {
+		return nil, nil
+	})
+	oldClock.WaitTickers(3)
+	newClock.WaitTickers(3)
+
+	replicaCount := func() uint64 {
+		restored := newStorage("restored", newManualClock(time.Now()))
+		if err := restored.Recover(ctx); err != nil {
+			t.Fatalf("Recover: %v", err)
+		}
+		return restored.GetCount()
+	}
+	// tick принимается только после завершения предыдущего шага цикла, поэтому второй Tick
+	// гарантирует, что шаг репликации закончен
+	tickReplication := func(clock *manualClock) {
+		clock.Tick(replication)
+		clock.Tick(monitoring)
+	}
+
+	proxy.UpsertMany([]uint64{1, 2, 3})
+	next.UpsertMany([]uint64{10}) // расхождение есть только во втором хранилище
+
+	tickReplication(oldClock)
+	tickReplication(newClock)
+	if got := replicaCount(); got != 3 {
+		t.Fatalf("secondary must not replicate in background: replica has %d keys, want 3", got)
+	}
+
+	proxy.Promote()
+	tickReplication(oldClock)
+	tickReplication(newClock)
+	if got := replicaCount(); got != 4 {
+		t.Fatalf("after Promote the new primary must replicate: replica has %d keys, want 4", got)
+	}
+}
@@ -40,7 +40,7 @@ package memory_storage
 //	}
 //	defer conn.DisconnectDB(ctx)
 //
-//	bitmapBytes, err := storage.GetBytesFromBitmap()
+//	bitmapBytes, err := storage.Snapshot()
 //	if err != nil {
 //		return err
 //	}
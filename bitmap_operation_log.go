@@ -0,0 +1,172 @@
+package memory_storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type OperationKind uint8
+
+const (
+	OperationAdd OperationKind = iota + 1
+	OperationRemove
+	OperationAddRange
+	OperationRemoveRange
+	OperationClear
+)
+
+type (
+	// OperationRecord — запись журнала операций над bitmap.
+	// Для диапазонных операций Keys содержит ровно два элемента: [lo, hi).
+	OperationRecord struct {
+		At   time.Time
+		Kind OperationKind
+		Keys []uint64
+	}
+
+	// OperationLogSink — append-only хранилище журнала операций.
+	OperationLogSink interface {
+		// Append дописывает запись в конец журнала
+		Append(ctx context.Context, record OperationRecord) error
+		// ReadSince вызывает fn для записей с At >= from в порядке добавления
+		ReadSince(ctx context.Context, from time.Time, fn func(record OperationRecord) error) error
+	}
+)
+
+var errCorruptedOperationRecord = errors.New("corrupted operation record")
+
+// maxOperationRecordSize — предел длины записи в файле журнала: длина читается с диска и без
+// предела испорченный префикс вёл бы к выделению произвольного объёма памяти. Около 25 млн ключей.
+const maxOperationRecordSize = 256 << 20
+
+// encodeOperationRecord: [int64 unix nano][kind][uvarint count][uvarint keys...]
+func encodeOperationRecord(record OperationRecord) []byte {
+	buf := make([]byte, 0, 9+binary.MaxVarintLen64*(len(record.Keys)+1))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(record.At.UnixNano()))
+	buf = append(buf, byte(record.Kind))
+	buf = binary.AppendUvarint(buf, uint64(len(record.Keys)))
+	for _, k := range record.Keys {
+		buf = binary.AppendUvarint(buf, k)
+	}
+	return buf
+}
+
+func decodeOperationRecord(data []byte) (OperationRecord, error) {
+	if len(data) < 10 {
+		return OperationRecord{}, errCorruptedOperationRecord
+	}
+	record := OperationRecord{
+		At:   time.Unix(0, int64(binary.BigEndian.Uint64(data[:8]))),
+		Kind: OperationKind(data[8]),
+	}
+	data = data[9:]
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return OperationRecord{}, errCorruptedOperationRecord
+	}
+	data = data[n:]
+	record.Keys = make([]uint64, 0, count)
+	for i := uint64(0); i < count; i++ {
+		k, n := binary.Uvarint(data)
+		if n <= 0 {
+			return OperationRecord{}, errCorruptedOperationRecord
+		}
+		record.Keys = append(record.Keys, k)
+		data = data[n:]
+	}
+	return record, nil
+}
+
+// FileOperationLogSink — журнал операций в локальном файле.
+// Каждая запись предваряется uvarint-длиной; недописанный хвост после сбоя игнорируется при чтении.
+type FileOperationLogSink struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	sync bool
+}
+
+// NewFileOperationLogSink открывает (или создаёт) файл журнала на дозапись.
+// syncWrites=true делает fsync после каждой записи.
+func NewFileOperationLogSink(path string, syncWrites bool) (*FileOperationLogSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open operation log: %w", err)
+	}
+	return &FileOperationLogSink{path: path, file: f, sync: syncWrites}, nil
+}
+
+func (f *FileOperationLogSink) Append(ctx context.Context, record OperationRecord) error {
+	payload := encodeOperationRecord(record)
+	if len(payload) > maxOperationRecordSize {
+		return fmt.Errorf("write operation log: record of %d bytes exceeds %d", len(payload), maxOperationRecordSize)
+	}
+	frame := binary.AppendUvarint(make([]byte, 0, len(payload)+binary.MaxVarintLen64), uint64(len(payload)))
+	frame = append(frame, payload...)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.file.Write(frame); err != nil {
+		return fmt.Errorf("write operation log: %w", err)
+	}
+	if f.sync {
+		return f.file.Sync()
+	}
+	return nil
+}
+
+func (f *FileOperationLogSink) ReadSince(ctx context.Context, from time.Time, fn func(record OperationRecord) error) error {
+	file, err := os.Open(f.path)
+	if err != nil {
+		return fmt.Errorf("open operation log: %w", err)
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		size, err := binary.ReadUvarint(reader)
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return nil
+			}
+			return err
+		}
+		if size > maxOperationRecordSize {
+			return fmt.Errorf("%w: record size %d exceeds %d", errCorruptedOperationRecord, size, maxOperationRecordSize)
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// недописанная запись в конце файла — результат сбоя во время Append
+				return nil
+			}
+			return err
+		}
+		record, err := decodeOperationRecord(payload)
+		if err != nil {
+			return err
+		}
+		if record.At.Before(from) {
+			continue
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+func (f *FileOperationLogSink) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
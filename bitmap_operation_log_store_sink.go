@@ -0,0 +1,82 @@
+package memory_storage
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// StoreOperationLogSink — журнал операций bitmap в sdk.Store.
+// Ключ записи: prefix + unix nano (big-endian) + порядковый номер, поэтому обход префикса идёт по времени.
+type StoreOperationLogSink struct {
+	store  *sdk.Store
+	prefix []byte
+	ttl    time.Duration
+	seq    atomic.Uint64
+}
+
+// NewStoreOperationLogSink создаёт журнал под префиксом prefix.
+// ttl > 0 задаёт срок хранения записей (ретеншн журнала), 0 — без срока.
+func NewStoreOperationLogSink(store *sdk.Store, prefix []byte, ttl time.Duration) *StoreOperationLogSink {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	return &StoreOperationLogSink{
+		store:  store,
+		prefix: cloneBytes(prefix),
+		ttl:    ttl,
+	}
+}
+
+func (s *StoreOperationLogSink) recordKey(at time.Time, seq uint64) []byte {
+	key := make([]byte, 0, len(s.prefix)+16)
+	key = append(key, s.prefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(at.UnixNano()))
+	key = binary.BigEndian.AppendUint64(key, seq)
+	return key
+}
+
+func (s *StoreOperationLogSink) Append(ctx context.Context, record OperationRecord) error {
+	key := s.recordKey(record.At, s.seq.Add(1))
+	if err := s.store.Set(key, encodeOperationRecord(record), s.ttl); err != nil {
+		return fmt.Errorf("append operation log: %w", err)
+	}
+	return nil
+}
+
+func (s *StoreOperationLogSink) ReadSince(ctx context.Context, from time.Time, fn func(record OperationRecord) error) error {
+	return s.store.DB().View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = s.prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := s.prefix
+		if from.After(time.Unix(0, 0)) {
+			seek = s.recordKey(from, 0)
+		}
+		for it.Seek(seek); it.ValidForPrefix(s.prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var record OperationRecord
+			err := it.Item().Value(func(val []byte) error {
+				var derr error
+				record, derr = decodeOperationRecord(val)
+				return derr
+			})
+			if err != nil {
+				return err
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
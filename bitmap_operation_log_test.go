@@ -0,0 +1,141 @@
+package memory_storage
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+func newLoggedBitmapStorage(name string, clock Clock, sink OperationLogSink) MemorySetStorage {
+	return NewBitmapStorage(
+		NewBitmapStubReplicator(),
+		BitmapStorageConfigs{
+			StorageName:       name,
+			MonitoringTicker:  time.Minute,
+			OptimizingTicker:  time.Minute,
+			ReplicationTicker: time.Minute,
+			ReplicationKey:    name,
+			Clock:             clock,
+			OperationLog:      sink,
+		},
+		&Warmer{BatchSize: 10},
+	)
+}
+
+func assertReplayCatchesUp(t *testing.T, sink OperationLogSink) {
+	t.Helper()
+	ctx := context.Background()
+	clock := newManualClock(time.Unix(1_700_000_000, 0))
+
+	source := newLoggedBitmapStorage("source", clock, sink)
+	source.UpsertMany([]uint64{1, 2, 3})
+	clock.now = clock.now.Add(time.Minute)
+	checkpoint := clock.Now()
+	source.RemoveMany([]uint64{1})
+	source.UpsertRange(100, 110)
+	source.RemoveRange(105, 200)
+
+	// реплика снята до checkpoint: содержит только первую пачку
+	replica := newLoggedBitmapStorage("replica", clock, sink)
+	replica.(*roaringBitmapStorage).bitmap.AddMany([]uint64{1, 2, 3})
+
+	if err := replica.Replay(ctx, checkpoint); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if replica.GetCount() != source.GetCount() {
+		t.Fatalf("replica count=%d, source count=%d", replica.GetCount(), source.GetCount())
+	}
+	if replica.Contains(1) || !replica.Contains(104) || replica.Contains(105) {
+		t.Fatalf("replica state differs from source after replay")
+	}
+
+	// полный replay с нуля на пустом хранилище
+	fresh := newLoggedBitmapStorage("fresh", clock, sink)
+	if err := fresh.Replay(ctx, time.Time{}); err != nil {
+		t.Fatalf("Replay from zero: %v", err)
+	}
+	if fresh.GetCount() != source.GetCount() {
+		t.Fatalf("fresh count=%d, source count=%d", fresh.GetCount(), source.GetCount())
+	}
+}
+
+func TestOperationLog_FileSinkReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bitmap.oplog")
+	sink, err := NewFileOperationLogSink(path, false)
+	if err != nil {
+		t.Fatalf("NewFileOperationLogSink: %v", err)
+	}
+	defer sink.Close()
+
+	assertReplayCatchesUp(t, sink)
+
+	// недописанный хвост не должен ломать чтение
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.Write([]byte{50, 1, 2})
+	_ = f.Close()
+
+	count := 0
+	if err := sink.ReadSince(context.Background(), time.Time{}, func(OperationRecord) error {
+		count++
+		return nil
+	}); err != nil {
+		t.Fatalf("ReadSince with torn tail: %v", err)
+	}
+	if count != 4 {
+		t.Fatalf("records=%d, want 4", count)
+	}
+}
+
+func TestOperationLog_FileSinkCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bitmap.oplog")
+	sink, err := NewFileOperationLogSink(path, false)
+	if err != nil {
+		t.Fatalf("NewFileOperationLogSink: %v", err)
+	}
+	defer sink.Close()
+	if err := sink.Append(context.Background(), OperationRecord{At: time.Unix(1, 0), Kind: OperationAdd, Keys: []uint64{1}}); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	// испорченный префикс длины: запись в экзабайты
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.Write(binary.AppendUvarint(nil, 1<<60))
+	_, _ = f.Write([]byte{1, 2, 3})
+	_ = f.Close()
+
+	count := 0
+	err = sink.ReadSince(context.Background(), time.Time{}, func(OperationRecord) error {
+		count++
+		return nil
+	})
+	if !errors.Is(err, errCorruptedOperationRecord) {
+		t.Fatalf("ReadSince with corrupt length: %v, want errCorruptedOperationRecord", err)
+	}
+	if count != 1 {
+		t.Fatalf("records before corruption=%d, want 1", count)
+	}
+}
+
+func TestOperationLog_StoreSinkReplay(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	assertReplayCatchesUp(t, NewStoreOperationLogSink(store, []byte("oplog:bitmap:"), 0))
+}
@@ -0,0 +1,116 @@
+package memory_storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+// BitmapDrift — расхождение bitmap с ключами префикса Store (источник истины — Store).
+type BitmapDrift struct {
+	// Keys — живых ключей префикса; Skipped — из них без id (extractID вернул false).
+	Keys    uint64
+	Skipped uint64
+	// InStore — различных id в Store, InBitmap — в bitmap.
+	InStore  uint64
+	InBitmap uint64
+	// Missing — id из Store, которых нет в bitmap; Stale — id bitmap без ключа в Store.
+	Missing *roaring64.Bitmap
+	Stale   *roaring64.Bitmap
+	// Added и Removed — сколько id исправлено при repair.
+	Added   uint64
+	Removed uint64
+}
+
+// Drifted сообщает, найдено ли расхождение.
+func (d BitmapDrift) Drifted() bool {
+	return !d.Missing.IsEmpty() || !d.Stale.IsEmpty()
+}
+
+// ReconcileBitmapWithPrefix сверяет bitmap с id ключей prefix в store и при repair исправляет
+// расхождение в обе стороны: добавляет Missing, удаляет Stale.
+//
+// Сверка не останавливает писателей, поэтому запись, попавшая между снимком bitmap и сканом,
+// выглядит как расхождение. Перед исправлением сверка повторяется, и исправляются только id,
+// расходящиеся в обоих проходах; в отчёте Missing и Stale — подтверждённые повтором.
+func ReconcileBitmapWithPrefix(
+	ctx context.Context,
+	store *sdk.Store,
+	prefix []byte,
+	extractID func(key []byte) (uint64, bool),
+	bitmap MemorySetStorage,
+	repair bool,
+) (BitmapDrift, error) {
+	if store == nil || bitmap == nil || extractID == nil {
+		return BitmapDrift{}, errors.New("store, bitmap and extractID must be not nil")
+	}
+	drift, err := reconcilePass(ctx, store, prefix, extractID, bitmap)
+	if err != nil || !repair || !drift.Drifted() {
+		return drift, err
+	}
+	again, err := reconcilePass(ctx, store, prefix, extractID, bitmap)
+	if err != nil {
+		return drift, err
+	}
+	again.Missing.And(drift.Missing)
+	again.Stale.And(drift.Stale)
+	drift = again
+
+	if !drift.Missing.IsEmpty() {
+		bitmap.UpsertMany(drift.Missing.ToArray())
+		drift.Added = drift.Missing.GetCardinality()
+	}
+	if !drift.Stale.IsEmpty() {
+		bitmap.RemoveMany(drift.Stale.ToArray())
+		drift.Removed = drift.Stale.GetCardinality()
+	}
+	return drift, nil
+}
+
+func reconcilePass(
+	ctx context.Context,
+	store *sdk.Store,
+	prefix []byte,
+	extractID func(key []byte) (uint64, bool),
+	bitmap MemorySetStorage,
+) (BitmapDrift, error) {
+	// снимок bitmap до скана: id, добавленный в оба места во время скана, попадёт в Missing, а не в Stale
+	inBitmap, err := bitmapCopy(bitmap)
+	if err != nil {
+		return BitmapDrift{}, err
+	}
+
+	var drift BitmapDrift
+	inStore := roaring64.NewBitmap()
+	// значения не нужны: фильтр видит ключ до чтения значения и пропускает все ключи,
+	// fn вызывается только чтобы прервать скан по отмене ctx
+	err = store.ScanPrefixFiltered(prefix, 0, func(key []byte, _ int64, _ byte) bool {
+		if ctx.Err() != nil {
+			return true
+		}
+		drift.Keys++
+		if id, ok := extractID(key); ok {
+			inStore.Add(id)
+		} else {
+			drift.Skipped++
+		}
+		return false
+	}, func(sdk.KV) error {
+		return ctx.Err()
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return BitmapDrift{}, fmt.Errorf("scan prefix %q: %w", prefix, err)
+	}
+
+	drift.InStore = inStore.GetCardinality()
+	drift.InBitmap = inBitmap.GetCardinality()
+	drift.Missing = roaring64.AndNot(inStore, inBitmap)
+	drift.Stale = roaring64.AndNot(inBitmap, inStore)
+	return drift, nil
+}
@@ -0,0 +1,60 @@
+package memory_storage
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+func TestReconcileBitmapWithPrefix_ReportsAndRepairsDrift(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	for _, key := range []string{"goods:1", "goods:2", "goods:3", "goods:draft"} {
+		if err := store.Set([]byte(key), []byte("v"), 0); err != nil {
+			t.Fatalf("Set(%s): %v", key, err)
+		}
+	}
+	extractID := func(key []byte) (uint64, bool) {
+		id, err := strconv.ParseUint(strings.TrimPrefix(string(key), "goods:"), 10, 64)
+		return id, err == nil
+	}
+	bitmap := newLoggedBitmapStorage("goods", nil, nil)
+	bitmap.UpsertMany([]uint64{1, 2, 40})
+
+	drift, err := ReconcileBitmapWithPrefix(ctx, store, []byte("goods:"), extractID, bitmap, false)
+	if err != nil {
+		t.Fatalf("report: %v", err)
+	}
+	if drift.Keys != 4 || drift.Skipped != 1 || drift.InStore != 3 || drift.InBitmap != 3 {
+		t.Fatalf("drift counters = %+v", drift)
+	}
+	if !drift.Missing.Contains(3) || drift.Missing.GetCardinality() != 1 ||
+		!drift.Stale.Contains(40) || drift.Stale.GetCardinality() != 1 {
+		t.Fatalf("missing = %v, stale = %v", drift.Missing.ToArray(), drift.Stale.ToArray())
+	}
+	if bitmap.Contains(3) || !bitmap.Contains(40) {
+		t.Fatalf("report-only run must not change bitmap")
+	}
+
+	drift, err = ReconcileBitmapWithPrefix(ctx, store, []byte("goods:"), extractID, bitmap, true)
+	if err != nil || drift.Added != 1 || drift.Removed != 1 {
+		t.Fatalf("repair = %+v, %v", drift, err)
+	}
+	if !bitmap.Contains(3) || bitmap.Contains(40) || bitmap.GetCount() != 3 {
+		t.Fatalf("bitmap after repair: count %d", bitmap.GetCount())
+	}
+
+	drift, err = ReconcileBitmapWithPrefix(ctx, store, []byte("goods:"), extractID, bitmap, false)
+	if err != nil || drift.Drifted() {
+		t.Fatalf("after repair drift = %+v, %v", drift, err)
+	}
+}
@@ -3,7 +3,10 @@ package memory_storage
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/btree"
@@ -14,15 +17,24 @@ type (
 		Upsert(item Item) bool
 		UpsertMany(items []Item) int
 		Delete(item Item) bool
+		Pop(item Item) (Item, bool)
 		DeleteMany(items []Item) int
 		Has(item Item) bool
 		GetNodeItem(item Item) (Item, bool)
 		GetLastWriteUnix(item Item) (int64, bool)
 		ForEach(callback func(key []byte, timestampUnixSeconds int64) bool) error
+		ForEachKV(callback func(key, value []byte, timestampUnixSeconds int64) bool) error
+		ForEachKVUnsafe(callback func(key, value []byte, timestampUnixSeconds int64) bool) error
+		AscendFrom(start []byte, callback func(key, value []byte, timestampUnixSeconds int64) bool) error
 		Size() int
 		Reset()
 		PurgeExpiredAt(now time.Time, ttl time.Duration, maxToDelete int) int
 		ListExpiredAt(now time.Time, ttl time.Duration, maxCount int) []Item
+		UpsertWithTTL(item Item, ttl time.Duration) bool
+		PurgeDeadlined(now time.Time, maxToDelete int) int
+		LoadSorted(items []Item) (int, error)
+		LoadSortedFrom(next func() (Item, bool)) (int, error)
+		KeyStats() KeyStats
 	}
 
 	// Item — элемент дерева: байтовый ключ и значение.
@@ -34,6 +46,14 @@ type (
 		Less(than btree.Item) bool
 		GetExpirationTime() int64
 	}
+
+	// DeadlineItem — элемент с собственным абсолютным дедлайном (per-item TTL).
+	// Дедлайн 0 означает «без собственного TTL»: такой элемент живёт по общей семантике PurgeExpiredAt.
+	DeadlineItem interface {
+		Item
+		GetDeadline() int64
+		SetDeadline(deadlineUnixSeconds int64)
+	}
 )
 
 // Options — параметры инициализации дерева.
@@ -44,18 +64,68 @@ type Options struct {
 	// которое кэшируется во внутреннем списке свободных узлов (free list).
 	// Это НЕ байты. Чем больше значение, тем меньше аллокаций/GC при вставках/удалениях
 	FreeListCapacity int
+	// MaxItems — ёмкость дерева. При превышении вытесняются элементы с самой старой записью.
+	// 0 — без ограничения.
+	MaxItems int
+	// OnEvict вызывается для элементов, удалённых PurgeExpiredAt/PurgeDeadlined/Reset и вытеснением по ёмкости.
+	// Вызов идёт вне лока дерева, поэтому из колбэка можно обращаться к дереву.
+	// Ключ и значение принадлежат уже удалённому элементу — их можно хранить без копирования.
+	OnEvict func(key []byte, value []byte, reason EvictReason)
+	// CompressKeyPrefixes включает сжатие ключей: общий префикс (до последнего KeyPrefixDelimiter)
+	// хранится один раз в таблице дерева, а элемент держит только его id и суффикс.
+	// Сжимаются ValueNodeItem и FilterNodeItem; дерево хранит их копии, поэтому
+	// GetNodeItem/ListExpiredAt возвращают элементы другого типа, а Key() у них аллоцирует.
+	CompressKeyPrefixes bool
+	// KeyPrefixDelimiter — разделитель префикса, по умолчанию ':'.
+	KeyPrefixDelimiter byte
+	// MaxKeyPrefixes — предел размера таблицы префиксов, по умолчанию 4096.
+	// Ключи с новыми префиксами сверх предела хранятся без сжатия.
+	MaxKeyPrefixes int
+}
+
+// EvictReason — причина удаления элемента, передаваемая в Options.OnEvict.
+type EvictReason uint8
+
+const (
+	EvictExpired  EvictReason = iota + 1 // PurgeExpiredAt: последняя запись старше ttl
+	EvictDeadline                        // PurgeDeadlined: наступил собственный дедлайн элемента
+	EvictReset                           // Reset: полная очистка дерева
+	EvictCapacity                        // вытеснение при превышении Options.MaxItems
+)
+
+func (r EvictReason) String() string {
+	switch r {
+	case EvictExpired:
+		return "expired"
+	case EvictDeadline:
+		return "deadline"
+	case EvictReset:
+		return "reset"
+	case EvictCapacity:
+		return "capacity"
+	default:
+		return "unknown"
+	}
 }
 
 // ByteKeyBTree — потокобезопасное B-дерево для байтовых ключей.
 type ByteKeyBTree struct {
-	tree *btree.BTree
-	mu   sync.RWMutex
+	id       uint64 // порядок создания, задаёт канонический порядок локов в MultiTree
+	tree     *btree.BTree
+	index    expirationIndex
+	mu       sync.RWMutex
+	degree   int
+	freeList *btree.FreeList
+	maxItems int
+	onEvict  func(key []byte, value []byte, reason EvictReason)
+	prefixes *keyPrefixTable // nil, если сжатие префиксов выключено
 }
 
 type ValueNodeItem struct {
 	valueBytes           []byte
 	keyBytes             []byte
 	timestampUnixSeconds int64
+	deadlineUnixSeconds  int64
 }
 
 func NewValueNodeItem(key, value []byte, expiration time.Time) *ValueNodeItem {
@@ -76,17 +146,26 @@ func (v *ValueNodeItem) Value() []byte {
 
 // Less — порядок для байтовых ключей.
 func (v *ValueNodeItem) Less(b btree.Item) bool {
-	return bytes.Compare(v.keyBytes, b.(Item).Key()) < 0
+	return lessKey(v.keyBytes, b)
 }
 
 func (v *ValueNodeItem) GetExpirationTime() int64 {
 	return v.timestampUnixSeconds
 }
 
+func (v *ValueNodeItem) GetDeadline() int64 {
+	return v.deadlineUnixSeconds
+}
+
+func (v *ValueNodeItem) SetDeadline(deadlineUnixSeconds int64) {
+	v.deadlineUnixSeconds = deadlineUnixSeconds
+}
+
 // FilterNodeItem — элемент дерева: ключ и момент последней записи.
 type FilterNodeItem struct {
 	keyBytes             []byte
 	timestampUnixSeconds int64
+	deadlineUnixSeconds  int64
 }
 
 func NewFilterNodeItem(key []byte, expiration time.Time) *FilterNodeItem {
@@ -106,14 +185,24 @@ func (a *FilterNodeItem) Value() []byte {
 
 // Less — порядок для байтовых ключей.
 func (a *FilterNodeItem) Less(b btree.Item) bool {
-	return bytes.Compare(a.keyBytes, b.(Item).Key()) < 0
+	return lessKey(a.keyBytes, b)
 }
 
 func (a *FilterNodeItem) GetExpirationTime() int64 {
 	return a.timestampUnixSeconds
 }
 
+func (a *FilterNodeItem) GetDeadline() int64 {
+	return a.deadlineUnixSeconds
+}
+
+func (a *FilterNodeItem) SetDeadline(deadlineUnixSeconds int64) {
+	a.deadlineUnixSeconds = deadlineUnixSeconds
+}
+
 // NewByteKeyBTree создаёт дерево с указанными опциями.
+var treeIDSeq atomic.Uint64
+
 func NewByteKeyBTree(opts Options) TtlBTree {
 	degree := opts.Degree
 	if degree <= 0 {
@@ -123,9 +212,20 @@ func NewByteKeyBTree(opts Options) TtlBTree {
 		opts.FreeListCapacity = 80000
 	}
 	fl := btree.NewFreeList(opts.FreeListCapacity)
+	var prefixes *keyPrefixTable
+	if opts.CompressKeyPrefixes {
+		prefixes = newKeyPrefixTable(opts.KeyPrefixDelimiter, opts.MaxKeyPrefixes)
+	}
 
 	return &ByteKeyBTree{
-		tree: btree.NewWithFreeList(degree, fl),
+		id:       treeIDSeq.Add(1),
+		tree:     btree.NewWithFreeList(degree, fl),
+		index:    newExpirationIndex(degree),
+		degree:   degree,
+		freeList: fl,
+		maxItems: opts.MaxItems,
+		onEvict:  opts.OnEvict,
+		prefixes: prefixes,
 	}
 }
 
@@ -151,9 +251,11 @@ func (b *ByteKeyBTree) Upsert(item Item) bool {
 	}
 
 	b.mu.Lock()
-	prev := b.tree.ReplaceOrInsert(item)
+	prev := b.insertLocked(item)
+	evicted := b.evictOverCapacityLocked()
 	b.mu.Unlock()
 
+	b.notifyEvicted(evicted, EvictCapacity)
 	return prev == nil
 }
 
@@ -173,16 +275,82 @@ func (b *ByteKeyBTree) UpsertMany(items []Item) int {
 		if len(item.Key()) == 0 {
 			continue
 		}
-		prev := b.tree.ReplaceOrInsert(item)
-		if prev == nil {
+		if b.insertLocked(item) == nil {
 			added++
 		}
 	}
+	evicted := b.evictOverCapacityLocked()
 	b.mu.Unlock()
 
+	b.notifyEvicted(evicted, EvictCapacity)
 	return added
 }
 
+// LoadSorted — массовая загрузка из элементов, отсортированных по возрастанию ключа
+// (например, прогрев из префиксного скана Badger). См. LoadSortedFrom.
+func (b *ByteKeyBTree) LoadSorted(items []Item) (int, error) {
+	i := 0
+	return b.LoadSortedFrom(func() (Item, bool) {
+		if i >= len(items) {
+			return nil, false
+		}
+		item := items[i]
+		i++
+		return item, true
+	})
+}
+
+// LoadSortedFrom — массовая загрузка из итератора; next возвращает false, когда элементы закончились.
+// Ключи должны строго возрастать, иначе загрузка прерывается с ошибкой и дерево не меняется.
+// Дерево строится без лока: вставка по возрастанию всегда идёт в правый край, без поиска по дереву.
+// Если к моменту подмены дерево пустое, оно подменяется построенным целиком, иначе элементы вливаются под локом.
+// Возвращает число реально новых ключей.
+func (b *ByteKeyBTree) LoadSortedFrom(next func() (Item, bool)) (int, error) {
+	if next == nil {
+		return 0, errors.New("nil iterator")
+	}
+
+	built := btree.NewWithFreeList(b.degree, b.freeList)
+	builtIndex := newExpirationIndex(b.degree)
+	var prevKey []byte
+	for {
+		item, ok := next()
+		if !ok {
+			break
+		}
+		if item == nil || len(item.Key()) == 0 {
+			continue
+		}
+		if prevKey != nil && bytes.Compare(prevKey, item.Key()) >= 0 {
+			return 0, fmt.Errorf("keys must be strictly ascending: %q after %q", item.Key(), prevKey)
+		}
+		prevKey = item.Key()
+		item = b.compress(item)
+		built.ReplaceOrInsert(item)
+		builtIndex.add(item)
+	}
+
+	added := 0
+	b.mu.Lock()
+	if b.tree.Len() == 0 {
+		b.tree = built
+		b.index = builtIndex
+		added = built.Len()
+	} else {
+		built.Ascend(func(x btree.Item) bool {
+			if b.insertLocked(x.(Item)) == nil {
+				added++
+			}
+			return true
+		})
+	}
+	evicted := b.evictOverCapacityLocked()
+	b.mu.Unlock()
+
+	b.notifyEvicted(evicted, EvictCapacity)
+	return added, nil
+}
+
 // Delete — удаление ключа. Возвращает true, если ключ существовал.
 func (b *ByteKeyBTree) Delete(item Item) bool {
 	if item == nil {
@@ -193,11 +361,30 @@ func (b *ByteKeyBTree) Delete(item Item) bool {
 	}
 
 	b.mu.Lock()
-	deleted := b.tree.Delete(item) != nil
+	deleted := b.removeLocked(item) != nil
 	b.mu.Unlock()
 	return deleted
 }
 
+// Pop — атомарно извлекает элемент по ключу: чтение и удаление под одним локом.
+// Из конкурентных Pop одного ключа элемент получит ровно один вызывающий (claim-семантика для очередей).
+func (b *ByteKeyBTree) Pop(item Item) (Item, bool) {
+	if item == nil {
+		return nil, false
+	}
+	if len(item.Key()) == 0 {
+		return nil, false
+	}
+
+	b.mu.Lock()
+	removed := b.removeLocked(item)
+	b.mu.Unlock()
+	if removed == nil {
+		return nil, false
+	}
+	return removed, true
+}
+
 // DeleteMany — массовое удаление. Возвращает число реально удалённых ключей.
 func (b *ByteKeyBTree) DeleteMany(items []Item) int {
 	if len(items) == 0 {
@@ -213,7 +400,7 @@ func (b *ByteKeyBTree) DeleteMany(items []Item) int {
 		if len(item.Key()) == 0 {
 			continue
 		}
-		if b.tree.Delete(item) != nil {
+		if b.removeLocked(item) != nil {
 			deleted++
 		}
 	}
@@ -287,6 +474,57 @@ func (b *ByteKeyBTree) ForEach(callback func(key []byte, timestampUnixSeconds in
 	return nil
 }
 
+// ForEachKV — полный обход по возрастанию ключей вместе со значениями.
+// Ключ и значение копируются, их можно сохранять и менять.
+// Если callback возвращает false — обход останавливается.
+func (b *ByteKeyBTree) ForEachKV(callback func(key, value []byte, timestampUnixSeconds int64) bool) error {
+	if callback == nil {
+		return errors.New("nil callback")
+	}
+	b.mu.RLock()
+	b.tree.Ascend(func(x btree.Item) bool {
+		it := x.(Item)
+		return callback(cloneBytes(it.Key()), cloneBytes(it.Value()), it.GetExpirationTime())
+	})
+	b.mu.RUnlock()
+	return nil
+}
+
+// ForEachKVUnsafe — обход без копирования: key и value — срезы самих листьев дерева.
+// Они валидны только внутри callback, их нельзя менять и сохранять. Из callback нельзя писать в дерево.
+func (b *ByteKeyBTree) ForEachKVUnsafe(callback func(key, value []byte, timestampUnixSeconds int64) bool) error {
+	if callback == nil {
+		return errors.New("nil callback")
+	}
+	b.mu.RLock()
+	b.tree.Ascend(func(x btree.Item) bool {
+		it := x.(Item)
+		return callback(it.Key(), it.Value(), it.GetExpirationTime())
+	})
+	b.mu.RUnlock()
+	return nil
+}
+
+// AscendFrom — обход по возрастанию, начиная с первого ключа >= start (seek).
+// Пустой start — обход с начала. Ключ и значение копируются.
+func (b *ByteKeyBTree) AscendFrom(start []byte, callback func(key, value []byte, timestampUnixSeconds int64) bool) error {
+	if callback == nil {
+		return errors.New("nil callback")
+	}
+	iterator := func(x btree.Item) bool {
+		it := x.(Item)
+		return callback(cloneBytes(it.Key()), cloneBytes(it.Value()), it.GetExpirationTime())
+	}
+	b.mu.RLock()
+	if len(start) == 0 {
+		b.tree.Ascend(iterator)
+	} else {
+		b.tree.AscendGreaterOrEqual(&FilterNodeItem{keyBytes: start}, iterator)
+	}
+	b.mu.RUnlock()
+	return nil
+}
+
 // Size — текущее количество элементов.
 func (b *ByteKeyBTree) Size() int {
 	b.mu.RLock()
@@ -297,12 +535,24 @@ func (b *ByteKeyBTree) Size() int {
 
 // Reset — полная очистка дерева.
 func (b *ByteKeyBTree) Reset() {
+	var evicted []Item
 	b.mu.Lock()
+	if b.onEvict != nil {
+		evicted = make([]Item, 0, b.tree.Len())
+		b.tree.Ascend(func(x btree.Item) bool {
+			evicted = append(evicted, x.(Item))
+			return true
+		})
+	}
 	b.tree.Clear(true)
+	b.index.clear()
 	b.mu.Unlock()
+
+	b.notifyEvicted(evicted, EvictReset)
 }
 
 // PurgeExpiredAt — удалить ключи, чья последняя запись старше now - ttl.
+// Просматривается только истёкшая часть индекса по времени, от самых старых записей.
 // Если maxToDelete <= 0 — без лимита. Возвращает число удалённых.
 func (b *ByteKeyBTree) PurgeExpiredAt(now time.Time, ttl time.Duration, maxToDelete int) int {
 	if ttl <= 0 {
@@ -310,38 +560,22 @@ func (b *ByteKeyBTree) PurgeExpiredAt(now time.Time, ttl time.Duration, maxToDel
 	}
 	cutoffUnix := now.Add(-ttl).Unix()
 
-	itemsToDelete := make([]Item, 0)
-	b.mu.RLock()
-	b.tree.Ascend(func(x btree.Item) bool {
-		it := x.(Item)
-		if it.GetExpirationTime() <= cutoffUnix {
-			itemsToDelete = append(itemsToDelete, it)
-			if maxToDelete > 0 && len(itemsToDelete) >= maxToDelete {
-				return false
-			}
-		}
-		return true
-	})
-	b.mu.RUnlock()
-
-	if len(itemsToDelete) == 0 {
-		return 0
-	}
-
-	deleted := 0
 	b.mu.Lock()
+	itemsToDelete := b.collectIndexedLocked(b.index.byTime, cutoffUnix, maxToDelete, itemTimestamp)
+	deleted := make([]Item, 0, len(itemsToDelete))
 	for _, item := range itemsToDelete {
-		if b.tree.Delete(item) != nil {
-			deleted++
+		if removed := b.removeLocked(item); removed != nil {
+			deleted = append(deleted, removed)
 		}
 	}
 	b.mu.Unlock()
 
-	return deleted
+	b.notifyEvicted(deleted, EvictExpired)
+	return len(deleted)
 }
 
 // ListExpiredAt — возвращает реальные листья дерева, будьте аккуратны и не меняйте их!
-// Граница включительна. Ничего не удаляет.
+// Граница включительна. Ничего не удаляет. Порядок — от самых старых записей, при равенстве — по ключу.
 // Если ttl <= 0 — возвращает nil.
 // maxCount > 0 — ограничивает количество возвращаемых ключей, 0/отрицательное — без лимита.
 func (b *ByteKeyBTree) ListExpiredAt(now time.Time, ttl time.Duration, maxCount int) []Item {
@@ -354,17 +588,109 @@ func (b *ByteKeyBTree) ListExpiredAt(now time.Time, ttl time.Duration, maxCount
 	limit := maxCount > 0
 
 	b.mu.RLock()
-	b.tree.Ascend(func(x btree.Item) bool {
-		it := x.(Item)
-		if it.GetExpirationTime() <= cutoff {
-			out = append(out, it)
-			if limit && len(out) >= maxCount {
-				return false
-			}
+	b.index.byTime.AscendLessThan(expirationPivot(cutoff+1), func(x btree.Item) bool {
+		e := x.(expirationEntry)
+		// устаревшие записи индекса пропускаем, их исправит ближайшая чистка
+		if b.tree.Get(e.item) != e.item || e.item.GetExpirationTime() > cutoff {
+			return true
 		}
-		return true
+		out = append(out, e.item)
+		return !limit || len(out) < maxCount
 	})
 	b.mu.RUnlock()
 
 	return out
 }
+
+// UpsertWithTTL — вставка/обновление с собственным TTL элемента.
+// Дедлайн считается от момента записи элемента: GetExpirationTime() + ttl; ttl <= 0 — без собственного дедлайна.
+// Элемент должен реализовывать DeadlineItem, иначе вставки не будет и вернётся false.
+// Возвращает true, если ключ был новым.
+// Дедлайн выставляется под локом записи: item может уже быть листом дерева (повторная вставка того же
+// элемента), и менять его дедлайн без лока — гонка с читателями и рассинхронизация индекса дедлайнов.
+func (b *ByteKeyBTree) UpsertWithTTL(item Item, ttl time.Duration) bool {
+	if item == nil {
+		return false
+	}
+	deadlineItem, ok := item.(DeadlineItem)
+	if !ok {
+		return false
+	}
+	if len(item.Key()) == 0 {
+		return false
+	}
+	var deadline int64
+	if ttl > 0 {
+		deadline = item.GetExpirationTime() + int64(ttl/time.Second)
+	}
+
+	b.mu.Lock()
+	if b.tree.Get(item) == item {
+		// записи индекса лежат под старым дедлайном, снимаем их до изменения
+		b.index.remove(item)
+	}
+	deadlineItem.SetDeadline(deadline)
+	prev := b.insertLocked(item)
+	evicted := b.evictOverCapacityLocked()
+	b.mu.Unlock()
+
+	b.notifyEvicted(evicted, EvictCapacity)
+	return prev == nil
+}
+
+// PurgeDeadlined — удалить элементы, чей собственный дедлайн (UpsertWithTTL) наступил к моменту now.
+// Граница включительна. Элементы без дедлайна не трогаются.
+// Если maxToDelete <= 0 — без лимита. Возвращает число удалённых.
+func (b *ByteKeyBTree) PurgeDeadlined(now time.Time, maxToDelete int) int {
+	nowUnix := now.Unix()
+
+	b.mu.Lock()
+	itemsToDelete := b.collectIndexedLocked(b.index.byDeadline, nowUnix, maxToDelete, itemDeadline)
+	deleted := make([]Item, 0, len(itemsToDelete))
+	for _, item := range itemsToDelete {
+		if removed := b.removeLocked(item); removed != nil {
+			deleted = append(deleted, removed)
+		}
+	}
+	b.mu.Unlock()
+
+	b.notifyEvicted(deleted, EvictDeadline)
+	return len(deleted)
+}
+
+// evictOverCapacityLocked вытесняет элементы с самой старой записью, пока размер больше MaxItems.
+// Кандидаты берутся из начала индекса по времени. Вызывается под локом записи;
+// возвращает вытесненные элементы для OnEvict.
+func (b *ByteKeyBTree) evictOverCapacityLocked() []Item {
+	if b.maxItems <= 0 {
+		return nil
+	}
+	excess := b.tree.Len() - b.maxItems
+	if excess <= 0 {
+		return nil
+	}
+
+	evicted := make([]Item, 0, excess)
+	for len(evicted) < excess {
+		candidates := b.collectIndexedLocked(b.index.byTime, math.MaxInt64-1, excess-len(evicted), itemTimestamp)
+		if len(candidates) == 0 {
+			break
+		}
+		for _, item := range candidates {
+			if removed := b.removeLocked(item); removed != nil {
+				evicted = append(evicted, removed)
+			}
+		}
+	}
+	return evicted
+}
+
+// notifyEvicted вызывает OnEvict для удалённых элементов; вызывать только вне лока.
+func (b *ByteKeyBTree) notifyEvicted(items []Item, reason EvictReason) {
+	if b.onEvict == nil {
+		return
+	}
+	for _, item := range items {
+		b.onEvict(item.Key(), item.Value(), reason)
+	}
+}
@@ -0,0 +1,120 @@
+package memory_storage
+
+import (
+	"github.com/google/btree"
+)
+
+// expirationEntry — элемент вторичного индекса по времени: момент (запись или дедлайн) + сам лист основного дерева.
+// Порядок: по at, при равенстве — по ключу, поэтому (at, key) уникален.
+type expirationEntry struct {
+	at   int64
+	item Item
+}
+
+func (e expirationEntry) Less(than btree.Item) bool {
+	o := than.(expirationEntry)
+	if e.at != o.at {
+		return e.at < o.at
+	}
+	return e.item.Less(o.item)
+}
+
+// expirationPivot — граница для AscendLessThan: все записи с at < bound.
+func expirationPivot(bound int64) expirationEntry {
+	return expirationEntry{at: bound, item: &FilterNodeItem{}}
+}
+
+// expirationIndex — индексы по моменту записи и по собственному дедлайну.
+// Позволяют чистке и вытеснению трогать только истёкшую часть дерева, а не сканировать все ключи.
+// Индекс опирается на то, что timestamp и дедлайн листа не меняются, пока он в дереве;
+// если лист всё же изменили напрямую, запись индекса устаревает и исправляется лениво при чистке.
+type expirationIndex struct {
+	byTime     *btree.BTree
+	byDeadline *btree.BTree
+}
+
+func newExpirationIndex(degree int) expirationIndex {
+	return expirationIndex{
+		byTime:     btree.New(degree),
+		byDeadline: btree.New(degree),
+	}
+}
+
+func (x expirationIndex) add(item Item) {
+	x.byTime.ReplaceOrInsert(expirationEntry{at: item.GetExpirationTime(), item: item})
+	if d, ok := item.(DeadlineItem); ok && d.GetDeadline() > 0 {
+		x.byDeadline.ReplaceOrInsert(expirationEntry{at: d.GetDeadline(), item: item})
+	}
+}
+
+func (x expirationIndex) remove(item Item) {
+	x.byTime.Delete(expirationEntry{at: item.GetExpirationTime(), item: item})
+	if d, ok := item.(DeadlineItem); ok && d.GetDeadline() > 0 {
+		x.byDeadline.Delete(expirationEntry{at: d.GetDeadline(), item: item})
+	}
+}
+
+func (x expirationIndex) clear() {
+	x.byTime.Clear(false)
+	x.byDeadline.Clear(false)
+}
+
+// insertLocked — вставка в дерево с поддержкой индекса; вызывается под локом записи.
+// Если включено сжатие префиксов, в дерево попадает сжатая копия элемента.
+func (b *ByteKeyBTree) insertLocked(item Item) Item {
+	item = b.compress(item)
+	prev := b.tree.ReplaceOrInsert(item)
+	if prev != nil {
+		b.index.remove(prev.(Item))
+	}
+	b.index.add(item)
+	if prev == nil {
+		return nil
+	}
+	return prev.(Item)
+}
+
+// removeLocked — удаление из дерева с поддержкой индекса; вызывается под локом записи.
+func (b *ByteKeyBTree) removeLocked(item Item) Item {
+	removed := b.tree.Delete(item)
+	if removed == nil {
+		return nil
+	}
+	b.index.remove(removed.(Item))
+	return removed.(Item)
+}
+
+// collectIndexedLocked собирает до limit живых листьев с моментом <= bound из индекса idx.
+// Устаревшие записи индекса (лист удалён, перезаписан или изменён напрямую) удаляются или переиндексируются.
+// Вызывается под локом записи.
+func (b *ByteKeyBTree) collectIndexedLocked(idx *btree.BTree, bound int64, limit int, at func(Item) int64) []Item {
+	out := make([]Item, 0)
+	var stale []expirationEntry
+	idx.AscendLessThan(expirationPivot(bound+1), func(x btree.Item) bool {
+		e := x.(expirationEntry)
+		if b.tree.Get(e.item) != e.item || at(e.item) != e.at {
+			stale = append(stale, e)
+			return true
+		}
+		out = append(out, e.item)
+		return limit <= 0 || len(out) < limit
+	})
+	for _, e := range stale {
+		idx.Delete(e)
+		if b.tree.Get(e.item) == e.item && (idx == b.index.byTime || at(e.item) > 0) {
+			idx.ReplaceOrInsert(expirationEntry{at: at(e.item), item: e.item})
+		}
+	}
+	return out
+}
+
+func itemDeadline(item Item) int64 {
+	if d, ok := item.(DeadlineItem); ok {
+		return d.GetDeadline()
+	}
+	return 0
+}
+
+func itemTimestamp(item Item) int64 {
+	return item.GetExpirationTime()
+}
@@ -0,0 +1,248 @@
+package memory_storage
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/google/btree"
+)
+
+const defaultMaxKeyPrefixes = 4096
+
+// KeyStats — статистика ключей дерева и экономии от сжатия префиксов.
+type KeyStats struct {
+	Keys           int // количество ключей
+	CompressedKeys int // ключей, хранящихся как id префикса + суффикс
+	Prefixes       int // размер таблицы префиксов
+	KeyBytes       int // суммарная длина ключей как есть
+	StoredKeyBytes int // реально хранимые байты ключей: суффиксы, полные несжатые ключи и таблица префиксов
+	SavedBytes     int // KeyBytes - StoredKeyBytes с учётом служебных полей сжатых элементов, может быть < 0
+}
+
+// keyPrefixTable — таблица интернированных префиксов ключей.
+// Префикс — часть ключа до последнего разделителя включительно ("user:v3:session:").
+// Префиксы не удаляются вместе с ключами; рост таблицы ограничен maxPrefixes.
+// Сравнения ключей читают префиксы без лока — через снимок list, который публикуется после каждого
+// добавления: префиксы не меняются, а старые id остаются валидными в любом более новом снимке.
+type keyPrefixTable struct {
+	mu          sync.RWMutex
+	delimiter   byte
+	maxPrefixes int
+	prefixes    [][]byte
+	ids         map[string]uint32
+	list        atomic.Pointer[[][]byte]
+}
+
+func newKeyPrefixTable(delimiter byte, maxPrefixes int) *keyPrefixTable {
+	if delimiter == 0 {
+		delimiter = ':'
+	}
+	if maxPrefixes <= 0 {
+		maxPrefixes = defaultMaxKeyPrefixes
+	}
+	return &keyPrefixTable{
+		delimiter:   delimiter,
+		maxPrefixes: maxPrefixes,
+		ids:         make(map[string]uint32),
+	}
+}
+
+// intern возвращает id префикса ключа и длину префикса; ok=false — ключ без префикса или таблица заполнена.
+func (t *keyPrefixTable) intern(key []byte) (uint32, int, bool) {
+	cut := bytes.LastIndexByte(key, t.delimiter) + 1
+	if cut <= 0 {
+		return 0, 0, false
+	}
+	prefix := key[:cut]
+
+	t.mu.RLock()
+	id, ok := t.ids[string(prefix)]
+	t.mu.RUnlock()
+	if ok {
+		return id, cut, true
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if id, ok := t.ids[string(prefix)]; ok {
+		return id, cut, true
+	}
+	if len(t.prefixes) >= t.maxPrefixes {
+		return 0, 0, false
+	}
+	id = uint32(len(t.prefixes))
+	t.prefixes = append(t.prefixes, cloneBytes(prefix))
+	t.ids[string(prefix)] = id
+	list := t.prefixes
+	t.list.Store(&list)
+	return id, cut, true
+}
+
+// prefix вызывается только для id, выданных intern, поэтому снимок уже опубликован.
+func (t *keyPrefixTable) prefix(id uint32) []byte {
+	return (*t.list.Load())[id]
+}
+
+func (t *keyPrefixTable) stats() (count, size int) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, p := range t.prefixes {
+		size += len(p)
+	}
+	return len(t.prefixes), size
+}
+
+// compressedNodeItem — элемент со сжатым ключом: id префикса в таблице дерева + суффикс.
+// Key() собирает полный ключ заново при каждом вызове, поэтому сравнения (Less здесь и lessKey у
+// несжатых элементов) его не вызывают: ключи сравниваются по частям, а при общем префиксе — по суффиксам.
+type compressedNodeItem struct {
+	table                *keyPrefixTable
+	suffix               []byte
+	valueBytes           []byte
+	timestampUnixSeconds int64
+	deadlineUnixSeconds  int64
+	prefixID             uint32
+	filter               bool // сжат из FilterNodeItem: при выгрузке восстанавливается тот же тип
+}
+
+func (c *compressedNodeItem) Key() []byte {
+	p := c.table.prefix(c.prefixID)
+	key := make([]byte, 0, len(p)+len(c.suffix))
+	key = append(key, p...)
+	return append(key, c.suffix...)
+}
+
+func (c *compressedNodeItem) Value() []byte {
+	return c.valueBytes
+}
+
+func (c *compressedNodeItem) Less(b btree.Item) bool {
+	if o, ok := b.(*compressedNodeItem); ok {
+		if o.table == c.table && o.prefixID == c.prefixID {
+			return bytes.Compare(c.suffix, o.suffix) < 0
+		}
+		return compareSplitKeys(c.table.prefix(c.prefixID), c.suffix, o.table.prefix(o.prefixID), o.suffix) < 0
+	}
+	return compareSplitKeys(c.table.prefix(c.prefixID), c.suffix, b.(Item).Key(), nil) < 0
+}
+
+// lessKey — Less несжатого элемента с ключом key: сжатый элемент сравнивается без сборки его ключа.
+func lessKey(key []byte, than btree.Item) bool {
+	if c, ok := than.(*compressedNodeItem); ok {
+		return compareSplitKeys(key, nil, c.table.prefix(c.prefixID), c.suffix) < 0
+	}
+	return bytes.Compare(key, than.(Item).Key()) < 0
+}
+
+func (c *compressedNodeItem) GetExpirationTime() int64 {
+	return c.timestampUnixSeconds
+}
+
+func (c *compressedNodeItem) GetDeadline() int64 {
+	return c.deadlineUnixSeconds
+}
+
+func (c *compressedNodeItem) SetDeadline(deadlineUnixSeconds int64) {
+	c.deadlineUnixSeconds = deadlineUnixSeconds
+}
+
+// compareSplitKeys сравнивает ключи aPrefix+aSuffix и bPrefix+bSuffix без склейки.
+func compareSplitKeys(aPrefix, aSuffix, bPrefix, bSuffix []byte) int {
+	a, b := aPrefix, bPrefix
+	aRest, bRest := aSuffix, bSuffix
+	for {
+		if len(a) == 0 {
+			if len(aRest) == 0 {
+				break
+			}
+			a, aRest = aRest, nil
+		}
+		if len(b) == 0 {
+			if len(bRest) == 0 {
+				break
+			}
+			b, bRest = bRest, nil
+		}
+		n := min(len(a), len(b))
+		if c := bytes.Compare(a[:n], b[:n]); c != 0 {
+			return c
+		}
+		a, b = a[n:], b[n:]
+	}
+	aLeft := len(a) + len(aRest)
+	bLeft := len(b) + len(bRest)
+	switch {
+	case aLeft < bLeft:
+		return -1
+	case aLeft > bLeft:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// compress переводит ValueNodeItem/FilterNodeItem в сжатое представление.
+// Элементы других типов, ключи без префикса и ключи сверх лимита таблицы хранятся как есть.
+func (b *ByteKeyBTree) compress(item Item) Item {
+	if b.prefixes == nil {
+		return item
+	}
+	var value []byte
+	var deadline int64
+	var filter bool
+	switch it := item.(type) {
+	case *ValueNodeItem:
+		value, deadline = it.valueBytes, it.deadlineUnixSeconds
+	case *FilterNodeItem:
+		deadline, filter = it.deadlineUnixSeconds, true
+	default:
+		return item
+	}
+	key := item.Key()
+	id, cut, ok := b.prefixes.intern(key)
+	if !ok {
+		return item
+	}
+	return &compressedNodeItem{
+		table:                b.prefixes,
+		prefixID:             id,
+		suffix:               cloneBytes(key[cut:]),
+		valueBytes:           value,
+		timestampUnixSeconds: item.GetExpirationTime(),
+		deadlineUnixSeconds:  deadline,
+		filter:               filter,
+	}
+}
+
+// KeyStats — статистика ключей и экономии памяти от сжатия префиксов (Options.CompressKeyPrefixes).
+// Обходит всё дерево под локом чтения.
+func (b *ByteKeyBTree) KeyStats() KeyStats {
+	var stats KeyStats
+	overhead := int(unsafe.Sizeof(compressedNodeItem{}) - unsafe.Sizeof(ValueNodeItem{}))
+
+	b.mu.RLock()
+	b.tree.Ascend(func(x btree.Item) bool {
+		stats.Keys++
+		if c, ok := x.(*compressedNodeItem); ok {
+			stats.CompressedKeys++
+			stats.KeyBytes += len(c.table.prefix(c.prefixID)) + len(c.suffix)
+			stats.StoredKeyBytes += len(c.suffix)
+			return true
+		}
+		n := len(x.(Item).Key())
+		stats.KeyBytes += n
+		stats.StoredKeyBytes += n
+		return true
+	})
+	b.mu.RUnlock()
+
+	if b.prefixes != nil {
+		count, size := b.prefixes.stats()
+		stats.Prefixes = count
+		stats.StoredKeyBytes += size
+	}
+	stats.SavedBytes = stats.KeyBytes - stats.StoredKeyBytes - stats.CompressedKeys*overhead
+	return stats
+}
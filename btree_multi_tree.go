@@ -0,0 +1,215 @@
+package memory_storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/btree"
+)
+
+// MultiTree — координатор согласованных изменений нескольких деревьев
+// (например, основного по ключу и вторичного по времени).
+// Деревья блокируются в каноническом порядке (по порядку создания), поэтому
+// пересекающиеся MultiTree не взаимоблокируются.
+type MultiTree struct {
+	trees []*ByteKeyBTree
+	order []int // индексы trees в порядке взятия локов
+}
+
+// NewMultiTree объединяет деревья; индекс дерева в последующих вызовах — его позиция в аргументах.
+// Поддерживаются только деревья, созданные NewByteKeyBTree.
+func NewMultiTree(trees ...TtlBTree) *MultiTree {
+	if len(trees) == 0 {
+		panic("multi tree requires at least one tree")
+	}
+	m := &MultiTree{
+		trees: make([]*ByteKeyBTree, 0, len(trees)),
+		order: make([]int, 0, len(trees)),
+	}
+	seen := make(map[*ByteKeyBTree]struct{}, len(trees))
+	for i, t := range trees {
+		bt, ok := t.(*ByteKeyBTree)
+		if !ok || bt == nil {
+			panic(fmt.Sprintf("multi tree: tree %d is not a *ByteKeyBTree", i))
+		}
+		if _, dup := seen[bt]; dup {
+			panic(fmt.Sprintf("multi tree: tree %d passed twice", i))
+		}
+		seen[bt] = struct{}{}
+		m.trees = append(m.trees, bt)
+		m.order = append(m.order, i)
+	}
+	sort.Slice(m.order, func(i, j int) bool {
+		return m.trees[m.order[i]].id < m.trees[m.order[j]].id
+	})
+	return m
+}
+
+type stagedOp struct {
+	item   Item
+	delete bool
+}
+
+// MultiTreeTx — накопитель изменений внутри MultiTree.Update.
+// Изменения применяются только если fn вернула nil; чтения видят собственные несохранённые изменения.
+type MultiTreeTx struct {
+	m      *MultiTree
+	staged []map[string]stagedOp
+	ops    [][]stagedOp
+}
+
+func (tx *MultiTreeTx) checkTree(tree int) {
+	if tree < 0 || tree >= len(tx.m.trees) {
+		panic(fmt.Sprintf("multi tree: tree index %d out of range", tree))
+	}
+}
+
+// Get — чтение ключа из дерева tree с учётом изменений текущей транзакции.
+func (tx *MultiTreeTx) Get(tree int, item Item) (Item, bool) {
+	tx.checkTree(tree)
+	if item == nil || len(item.Key()) == 0 {
+		return nil, false
+	}
+	if op, ok := tx.staged[tree][string(item.Key())]; ok {
+		if op.delete {
+			return nil, false
+		}
+		return op.item, true
+	}
+	res := tx.m.trees[tree].tree.Get(item)
+	if res == nil {
+		return nil, false
+	}
+	return res.(Item), true
+}
+
+// Upsert — отложенная вставка/обновление в дереве tree.
+func (tx *MultiTreeTx) Upsert(tree int, item Item) {
+	tx.checkTree(tree)
+	if item == nil || len(item.Key()) == 0 {
+		return
+	}
+	op := stagedOp{item: item}
+	tx.staged[tree][string(item.Key())] = op
+	tx.ops[tree] = append(tx.ops[tree], op)
+}
+
+// Delete — отложенное удаление ключа из дерева tree.
+func (tx *MultiTreeTx) Delete(tree int, item Item) {
+	tx.checkTree(tree)
+	if item == nil || len(item.Key()) == 0 {
+		return
+	}
+	op := stagedOp{item: item, delete: true}
+	tx.staged[tree][string(item.Key())] = op
+	tx.ops[tree] = append(tx.ops[tree], op)
+}
+
+// Update выполняет fn под локами записи всех деревьев и применяет накопленные изменения
+// по принципу «всё или ничего»: при ошибке или панике в fn деревья не меняются.
+func (m *MultiTree) Update(fn func(tx *MultiTreeTx) error) (err error) {
+	if fn == nil {
+		return errors.New("nil update function")
+	}
+	tx := &MultiTreeTx{
+		m:      m,
+		staged: make([]map[string]stagedOp, len(m.trees)),
+		ops:    make([][]stagedOp, len(m.trees)),
+	}
+	for i := range m.trees {
+		tx.staged[i] = make(map[string]stagedOp)
+	}
+
+	evicted := make([][]Item, len(m.trees))
+	m.lockAll()
+	func() {
+		defer m.unlockAll()
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("panic in multi tree update: %v", p)
+			}
+		}()
+		if err = fn(tx); err != nil {
+			return
+		}
+		for i, bt := range m.trees {
+			for _, op := range tx.ops[i] {
+				if op.delete {
+					bt.removeLocked(op.item)
+				} else {
+					bt.insertLocked(op.item)
+				}
+			}
+			evicted[i] = bt.evictOverCapacityLocked()
+		}
+	}()
+	if err != nil {
+		return err
+	}
+
+	for i, bt := range m.trees {
+		bt.notifyEvicted(evicted[i], EvictCapacity)
+	}
+	return nil
+}
+
+// Snapshot снимает согласованный снимок всех деревьев.
+// Клонирование B-дерева ленивое (copy-on-write), поэтому локи держатся недолго,
+// а по снимку можно итерироваться без блокировки писателей.
+func (m *MultiTree) Snapshot() *MultiTreeSnapshot {
+	snapshot := &MultiTreeSnapshot{trees: make([]*btree.BTree, len(m.trees))}
+	// Clone меняет служебное состояние оригинала, поэтому нужен эксклюзивный лок
+	m.lockAll()
+	for i, bt := range m.trees {
+		snapshot.trees[i] = bt.tree.Clone()
+	}
+	m.unlockAll()
+	return snapshot
+}
+
+func (m *MultiTree) lockAll() {
+	for _, i := range m.order {
+		m.trees[i].mu.Lock()
+	}
+}
+
+func (m *MultiTree) unlockAll() {
+	for j := len(m.order) - 1; j >= 0; j-- {
+		m.trees[m.order[j]].mu.Unlock()
+	}
+}
+
+// MultiTreeSnapshot — неизменяемый согласованный снимок деревьев MultiTree.
+type MultiTreeSnapshot struct {
+	trees []*btree.BTree
+}
+
+// Len — количество элементов дерева tree в снимке.
+func (s *MultiTreeSnapshot) Len(tree int) int {
+	return s.trees[tree].Len()
+}
+
+// Get — чтение ключа из дерева tree в снимке.
+func (s *MultiTreeSnapshot) Get(tree int, item Item) (Item, bool) {
+	if item == nil || len(item.Key()) == 0 {
+		return nil, false
+	}
+	res := s.trees[tree].Get(item)
+	if res == nil {
+		return nil, false
+	}
+	return res.(Item), true
+}
+
+// ForEachKV — обход дерева tree в снимке по возрастанию ключей, ключи и значения копируются.
+func (s *MultiTreeSnapshot) ForEachKV(tree int, callback func(key, value []byte, timestampUnixSeconds int64) bool) error {
+	if callback == nil {
+		return errors.New("nil callback")
+	}
+	s.trees[tree].Ascend(func(x btree.Item) bool {
+		it := x.(Item)
+		return callback(cloneBytes(it.Key()), cloneBytes(it.Value()), it.GetExpirationTime())
+	})
+	return nil
+}
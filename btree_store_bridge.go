@@ -0,0 +1,185 @@
+package memory_storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/google/btree"
+)
+
+const dumpFormatVersion = 1
+
+const (
+	dumpKindValue  byte = 1
+	dumpKindFilter byte = 2
+)
+
+// dumpRecord — элемент дерева в виде, пригодном для записи в Store.
+type dumpRecord struct {
+	key      []byte
+	value    []byte
+	ts       int64
+	deadline int64
+	kind     byte
+}
+
+// encodeDumpValue: [version][kind][varint ts][varint deadline][value...]
+func encodeDumpValue(r dumpRecord) []byte {
+	buf := make([]byte, 0, 2+2*binary.MaxVarintLen64+len(r.value))
+	buf = append(buf, dumpFormatVersion, r.kind)
+	buf = binary.AppendVarint(buf, r.ts)
+	buf = binary.AppendVarint(buf, r.deadline)
+	return append(buf, r.value...)
+}
+
+func decodeDumpValue(data []byte) (dumpRecord, error) {
+	if len(data) < 2 {
+		return dumpRecord{}, errors.New("dump value too short")
+	}
+	if data[0] != dumpFormatVersion {
+		return dumpRecord{}, fmt.Errorf("unknown dump format version %d", data[0])
+	}
+	r := dumpRecord{kind: data[1]}
+	data = data[2:]
+	ts, n := binary.Varint(data)
+	if n <= 0 {
+		return dumpRecord{}, errors.New("corrupted dump timestamp")
+	}
+	data = data[n:]
+	deadline, n := binary.Varint(data)
+	if n <= 0 {
+		return dumpRecord{}, errors.New("corrupted dump deadline")
+	}
+	r.ts, r.deadline, r.value = ts, deadline, data[n:]
+	return r, nil
+}
+
+// dumpRecords снимает содержимое дерева. Для ByteKeyBTree сохраняются тип элемента и собственный дедлайн,
+// для прочих реализаций TtlBTree — только ключ, значение и время записи.
+func dumpRecords(tree TtlBTree) ([]dumpRecord, error) {
+	bt, ok := tree.(*ByteKeyBTree)
+	if !ok {
+		records := make([]dumpRecord, 0, tree.Size())
+		err := tree.ForEachKV(func(key, value []byte, ts int64) bool {
+			records = append(records, dumpRecord{key: key, value: value, ts: ts, kind: dumpKindValue})
+			return true
+		})
+		return records, err
+	}
+
+	bt.mu.RLock()
+	defer bt.mu.RUnlock()
+	records := make([]dumpRecord, 0, bt.tree.Len())
+	bt.tree.Ascend(func(x btree.Item) bool {
+		it := x.(Item)
+		r := dumpRecord{key: it.Key(), value: it.Value(), ts: it.GetExpirationTime(), deadline: itemDeadline(it), kind: dumpKindValue}
+		switch c := it.(type) {
+		case *FilterNodeItem:
+			r.kind = dumpKindFilter
+		case *compressedNodeItem:
+			if c.filter {
+				r.kind = dumpKindFilter
+			}
+		}
+		records = append(records, r)
+		return true
+	})
+	return records, nil
+}
+
+// DumpToStore сохраняет содержимое дерева в store под префиксом prefix (чекпоинт).
+// ttlFrom по времени записи элемента возвращает TTL ключа в Store: 0 — без TTL,
+// отрицательное значение — элемент уже истёк и не сохраняется; ttlFrom == nil — без TTL.
+// Старые ключи под prefix не удаляются. Возвращает число записанных элементов.
+func DumpToStore(ctx context.Context, tree TtlBTree, store *sdk.Store, prefix []byte, ttlFrom func(ts int64) time.Duration) (int, error) {
+	if tree == nil || store == nil {
+		return 0, errors.New("tree and store must be not nil")
+	}
+	records, err := dumpRecords(tree)
+	if err != nil {
+		return 0, err
+	}
+
+	wb := store.DB().NewWriteBatch()
+	defer wb.Cancel()
+
+	written := 0
+	for _, r := range records {
+		if err := ctx.Err(); err != nil {
+			return written, err
+		}
+		var ttl time.Duration
+		if ttlFrom != nil {
+			if ttl = ttlFrom(r.ts); ttl < 0 {
+				continue
+			}
+		}
+		key := make([]byte, 0, len(prefix)+len(r.key))
+		key = append(append(key, prefix...), r.key...)
+		if err := wb.SetEntry(store.NewEntry(key, encodeDumpValue(r), ttl)); err != nil {
+			return written, fmt.Errorf("dump tree to store: %w", err)
+		}
+		written++
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, fmt.Errorf("dump tree to store: %w", err)
+	}
+	return written, nil
+}
+
+// LoadFromStore восстанавливает элементы, сохранённые DumpToStore под префиксом prefix, в дерево tree.
+// Существующие ключи дерева перезаписываются. Возвращает число новых ключей дерева.
+func LoadFromStore(ctx context.Context, store *sdk.Store, prefix []byte, tree TtlBTree) (int, error) {
+	if tree == nil || store == nil {
+		return 0, errors.New("tree and store must be not nil")
+	}
+
+	items := make([]Item, 0)
+	err := store.ScanPrefix(prefix, 0, func(kv sdk.KV) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r, err := decodeDumpValue(kv.Value)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", kv.Key, err)
+		}
+		key := kv.Key[len(prefix):]
+		ts := time.Unix(r.ts, 0)
+		var item DeadlineItem
+		if r.kind == dumpKindFilter {
+			item = NewFilterNodeItem(key, ts)
+		} else {
+			item = NewValueNodeItem(key, r.value, ts)
+		}
+		item.SetDeadline(r.deadline)
+		items = append(items, item)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("load tree from store: %w", err)
+	}
+	return tree.LoadSorted(items)
+}
+
+// NewTreeInvalidator возвращает обработчики для sdk.ListenInvalidations, которые сбрасывают
+// изменённые писателем ключи из дерева-кеша читателя. Ключи Store без prefix игнорируются,
+// у остальных prefix отрезается (та же раскладка, что у DumpToStore). onReset очищает дерево целиком.
+func NewTreeInvalidator(tree TtlBTree, prefix []byte) (onKeys func(keys [][]byte), onReset func()) {
+	prefix = cloneBytes(prefix)
+	onKeys = func(keys [][]byte) {
+		items := make([]Item, 0, len(keys))
+		for _, k := range keys {
+			if !bytes.HasPrefix(k, prefix) || len(k) == len(prefix) {
+				continue
+			}
+			items = append(items, &FilterNodeItem{keyBytes: k[len(prefix):]})
+		}
+		tree.DeleteMany(items)
+	}
+	return onKeys, tree.Reset
+}
@@ -0,0 +1,152 @@
+package memory_storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+func TestBTree_DumpToStoreAndLoadFromStore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Unix(31_000, 0)
+	src := NewByteKeyBTree(Options{CompressKeyPrefixes: true})
+	src.Upsert(newTestValue("user:1", "alice", now))
+	src.Upsert(newTestFilter("user:2", now.Add(-time.Minute)))
+	src.UpsertWithTTL(newTestValue("user:3", "bob", now), time.Hour)
+	src.Upsert(newTestValue("old", "gone", now.Add(-48*time.Hour)))
+
+	ttlFrom := func(ts int64) time.Duration {
+		// хранить сутки с момента записи
+		return time.Unix(ts, 0).Add(24 * time.Hour).Sub(now)
+	}
+	written, err := DumpToStore(ctx, src, store, []byte("ckpt:"), ttlFrom)
+	if err != nil || written != 3 {
+		t.Fatalf("DumpToStore = (%d, %v), want 3 written", written, err)
+	}
+
+	dst := NewByteKeyBTree(Options{})
+	dst.Upsert(newTestValue("user:1", "stale", now.Add(-time.Hour)))
+	added, err := LoadFromStore(ctx, store, []byte("ckpt:"), dst)
+	if err != nil || added != 2 || dst.Size() != 3 {
+		t.Fatalf("LoadFromStore = (%d, %v), size %d", added, err, dst.Size())
+	}
+
+	it, ok := dst.GetNodeItem(newTestFilter("user:1", now))
+	if !ok || string(it.Value()) != "alice" || it.GetExpirationTime() != now.Unix() {
+		t.Fatalf("user:1 restored as %v", it)
+	}
+	if it, _ := dst.GetNodeItem(newTestFilter("user:2", now)); it == nil {
+		t.Fatalf("user:2 must be restored")
+	} else if _, isFilter := it.(*FilterNodeItem); !isFilter {
+		t.Fatalf("user:2 must be restored as FilterNodeItem, got %T", it)
+	}
+	if deleted := dst.PurgeDeadlined(now.Add(2*time.Hour), 0); deleted != 1 || dst.Has(newTestFilter("user:3", now)) {
+		t.Fatalf("restored deadline must be honoured, deleted=%d", deleted)
+	}
+}
+
+func TestBTree_InvalidationBroadcastDropsCachedKeys(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	dir, err := os.MkdirTemp("", "inv")
+	if err != nil {
+		t.Fatalf("MkdirTemp: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "s")
+
+	broadcaster, err := sdk.NewInvalidationBroadcaster(socket)
+	if err != nil {
+		t.Fatalf("NewInvalidationBroadcaster: %v", err)
+	}
+	defer broadcaster.Close()
+	go func() { _ = store.BroadcastInvalidations(ctx, broadcaster, []byte("cache:")) }()
+
+	now := time.Unix(32_000, 0)
+	cache := NewByteKeyBTree(Options{})
+	cache.Upsert(newTestValue("a", "old", now))
+	cache.Upsert(newTestValue("b", "old", now))
+
+	onKeys, onReset := NewTreeInvalidator(cache, []byte("cache:"))
+	connected := make(chan struct{}, 1)
+	go func() {
+		_ = sdk.ListenInvalidations(ctx, socket, onKeys, sdk.InvalidationListenerOptions{
+			ReconnectDelay: 10 * time.Millisecond,
+			OnReset: func() {
+				onReset()
+				select {
+				case connected <- struct{}{}:
+				default:
+				}
+			},
+		})
+	}()
+	<-connected
+
+	// после подключения кеш пуст — наполняем заново и ждём инвалидации одного ключа
+	cache.Upsert(newTestValue("a", "old", now))
+	cache.Upsert(newTestValue("b", "old", now))
+	deadline := time.Now().Add(5 * time.Second)
+	for cache.Has(newTestFilter("a", now)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("key a was not invalidated")
+		}
+		// подключение к сокету и подписка в Badger асинхронны — повторяем запись
+		if err := store.Set([]byte("cache:a"), []byte("new"), 0); err != nil {
+			t.Fatalf("Set: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !cache.Has(newTestFilter("b", now)) {
+		t.Fatalf("untouched key b must stay cached")
+	}
+}
+
+func TestBTree_DumpToStoreTTLFollowsStoreClock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	clock := newManualClock(time.Now())
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true, Clock: clock}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	tree := NewByteKeyBTree(Options{})
+	tree.Upsert(newTestValue("k", "v", clock.Now()))
+	if _, err := DumpToStore(ctx, tree, store, []byte("ckpt:"), func(int64) time.Duration { return time.Minute }); err != nil {
+		t.Fatalf("DumpToStore: %v", err)
+	}
+	if _, err := store.Get([]byte("ckpt:k")); err != nil {
+		t.Fatalf("Get before expiry: %v", err)
+	}
+
+	clock.mu.Lock()
+	clock.now = clock.now.Add(2 * time.Minute)
+	clock.mu.Unlock()
+	if _, err := store.Get([]byte("ckpt:k")); !errors.Is(err, sdk.ErrNotFound) {
+		t.Fatalf("Get after fast-forward = %v, want ErrNotFound", err)
+	}
+	restored := NewByteKeyBTree(Options{})
+	if n, err := LoadFromStore(ctx, store, []byte("ckpt:"), restored); err != nil || n != 0 {
+		t.Fatalf("LoadFromStore after expiry = (%d, %v), want nothing", n, err)
+	}
+}
@@ -2,7 +2,10 @@ package memory_storage
 
 import (
 	"bytes"
+	"fmt"
 	"math/rand"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -403,6 +406,52 @@ func TestBTree_ListExpired_ReturnsLivePointers(t *testing.T) {
 	}
 }
 
+func TestBTree_ExpirationIndex_FollowsOverwritesAndDeletes(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	base := time.Unix(27_000, 0)
+	ttl := time.Minute
+
+	bt.Upsert(newTestFilter("refreshed", base.Add(-time.Hour)))
+	bt.Upsert(newTestFilter("deleted", base.Add(-time.Hour)))
+	bt.Upsert(newTestFilter("stale", base.Add(-2*time.Hour)))
+	bt.Upsert(newTestFilter("fresh", base))
+
+	bt.Upsert(newTestFilter("refreshed", base))
+	bt.Delete(newTestFilter("deleted", base))
+
+	expired := keysFromItems(bt.ListExpiredAt(base, ttl, 0))
+	if strings.Join(expired, ",") != "stale" {
+		t.Fatalf("expired=%v, want [stale]", expired)
+	}
+	if deleted := bt.PurgeExpiredAt(base, ttl, 0); deleted != 1 {
+		t.Fatalf("PurgeExpiredAt deleted=%d, want 1", deleted)
+	}
+	if bt.Size() != 2 || !bt.Has(newTestFilter("refreshed", base)) {
+		t.Fatalf("refreshed and fresh must remain, size=%d", bt.Size())
+	}
+}
+
+func TestBTree_ExpirationIndex_OldestFirstAcrossKeys(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	base := time.Unix(28_000, 0)
+	for i, k := range []string{"a", "b", "c", "d"} {
+		// чем «больше» ключ, тем старше запись
+		bt.Upsert(newTestFilter(k, base.Add(-time.Duration(10+i)*time.Minute)))
+	}
+
+	got := keysFromItems(bt.ListExpiredAt(base, time.Minute, 2))
+	if strings.Join(got, ",") != "d,c" {
+		t.Fatalf("ListExpiredAt=%v, want oldest first [d c]", got)
+	}
+	if deleted := bt.PurgeExpiredAt(base, time.Minute, 3); deleted != 3 || !bt.Has(newTestFilter("a", base)) {
+		t.Fatalf("limited purge must remove the 3 oldest, deleted=%d", deleted)
+	}
+}
+
 // ============================================================================
 // Смешивание типов FilterNodeItem / ValueNodeItem
 // ============================================================================
@@ -449,6 +498,643 @@ func TestBTree_MixedTypes_OrderByKey(t *testing.T) {
 	}
 }
 
+// ============================================================================
+// Per-item TTL: UpsertWithTTL / PurgeDeadlined
+// ============================================================================
+
+func TestBTree_UpsertWithTTL_PurgeDeadlined(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	base := time.Unix(14_000, 0)
+
+	bt.UpsertWithTTL(newTestValue("short", "v", base), 10*time.Second)
+	bt.UpsertWithTTL(newTestValue("long", "v", base), time.Hour)
+	bt.Upsert(newTestValue("plain", "v", base))
+
+	if deleted := bt.PurgeDeadlined(base.Add(9*time.Second), 0); deleted != 0 {
+		t.Fatalf("nothing must be deadlined before 10s, deleted=%d", deleted)
+	}
+	// граница включительна
+	if deleted := bt.PurgeDeadlined(base.Add(10*time.Second), 0); deleted != 1 {
+		t.Fatalf("PurgeDeadlined deleted=%d, want 1", deleted)
+	}
+	if bt.Has(newTestFilter("short", base)) {
+		t.Fatalf("short-lived key must be purged")
+	}
+	if !bt.Has(newTestFilter("long", base)) || !bt.Has(newTestFilter("plain", base)) {
+		t.Fatalf("long-lived and plain keys must remain")
+	}
+
+	// элементы без дедлайна живут по общей семантике last-write
+	if deleted := bt.PurgeDeadlined(base.Add(24*time.Hour), 0); deleted != 1 {
+		t.Fatalf("only long must be deadlined, deleted=%d", deleted)
+	}
+	if deleted := bt.PurgeExpiredAt(base.Add(24*time.Hour), time.Hour, 0); deleted != 1 {
+		t.Fatalf("plain must be purged by global ttl, deleted=%d", deleted)
+	}
+}
+
+func TestBTree_UpsertWithTTL_OverwriteResetsDeadline(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	base := time.Unix(15_000, 0)
+
+	bt.UpsertWithTTL(newTestFilter("k", base), 5*time.Second)
+	// перезапись без TTL снимает дедлайн
+	if isNew := bt.Upsert(newTestFilter("k", base)); isNew {
+		t.Fatalf("overwrite must not report new key")
+	}
+	if deleted := bt.PurgeDeadlined(base.Add(time.Minute), 0); deleted != 0 {
+		t.Fatalf("overwritten key without ttl must not be deadlined, deleted=%d", deleted)
+	}
+
+	bt.UpsertWithTTL(newTestFilter("a", base), time.Second)
+	bt.UpsertWithTTL(newTestFilter("b", base), time.Second)
+	if deleted := bt.PurgeDeadlined(base.Add(time.Minute), 1); deleted != 1 {
+		t.Fatalf("PurgeDeadlined must respect maxToDelete, deleted=%d", deleted)
+	}
+}
+
+func TestBTree_UpsertWithTTL_SameItemConcurrent(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	base := time.Unix(16_000, 0)
+	item := newTestValue("k", "v", base)
+	bt.UpsertWithTTL(item, time.Hour)
+
+	// повторная вставка того же листа с другим TTL параллельно с чтением дедлайнов
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// последняя вставка — с TTL 200s
+		for i := 0; i < 2000; i++ {
+			bt.UpsertWithTTL(item, time.Duration(i%200+1)*time.Second)
+			runtime.Gosched()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			// может удалить лист с коротким дедлайном — следующая вставка вернёт его
+			bt.PurgeDeadlined(base.Add(100*time.Second), 0)
+			runtime.Gosched()
+		}
+	}()
+	wg.Wait()
+
+	// в индексе остался только последний дедлайн: +200s
+	if deleted := bt.PurgeDeadlined(base.Add(199*time.Second), 0); deleted != 0 {
+		t.Fatalf("stale deadline must be dropped, deleted=%d", deleted)
+	}
+	if deleted := bt.PurgeDeadlined(base.Add(200*time.Second), 0); deleted != 1 {
+		t.Fatalf("PurgeDeadlined deleted=%d, want 1", deleted)
+	}
+}
+
+// ============================================================================
+// Колбэки вытеснения: OnEvict / MaxItems
+// ============================================================================
+
+type evictRecord struct {
+	key, value string
+	reason     EvictReason
+}
+
+func newEvictRecorder() (*[]evictRecord, func(key, value []byte, reason EvictReason)) {
+	var mu sync.Mutex
+	records := make([]evictRecord, 0)
+	return &records, func(key, value []byte, reason EvictReason) {
+		mu.Lock()
+		records = append(records, evictRecord{string(key), string(value), reason})
+		mu.Unlock()
+	}
+}
+
+func TestBTree_OnEvict_PurgeAndReset(t *testing.T) {
+	t.Parallel()
+
+	records, onEvict := newEvictRecorder()
+	bt := NewByteKeyBTree(Options{OnEvict: onEvict})
+	base := time.Unix(16_000, 0)
+
+	bt.Upsert(newTestValue("old", "v1", base.Add(-time.Hour)))
+	bt.UpsertWithTTL(newTestValue("ttl", "v2", base), time.Second)
+	bt.Upsert(newTestValue("fresh", "v3", base))
+
+	bt.PurgeExpiredAt(base, time.Minute, 0)
+	bt.PurgeDeadlined(base.Add(time.Second), 0)
+	// обычное удаление — не вытеснение
+	bt.Delete(newTestFilter("missing", base))
+	bt.Reset()
+
+	want := []evictRecord{
+		{"old", "v1", EvictExpired},
+		{"ttl", "v2", EvictDeadline},
+		{"fresh", "v3", EvictReset},
+	}
+	if len(*records) != len(want) {
+		t.Fatalf("evicted=%v, want %v", *records, want)
+	}
+	for i := range want {
+		if (*records)[i] != want[i] {
+			t.Fatalf("evicted[%d]=%v, want %v", i, (*records)[i], want[i])
+		}
+	}
+}
+
+func TestBTree_OnEvict_Capacity(t *testing.T) {
+	t.Parallel()
+
+	records, onEvict := newEvictRecorder()
+	bt := NewByteKeyBTree(Options{MaxItems: 2, OnEvict: onEvict})
+	base := time.Unix(17_000, 0)
+
+	bt.Upsert(newTestValue("b", "1", base.Add(2*time.Second)))
+	bt.Upsert(newTestValue("a", "2", base.Add(1*time.Second)))
+	bt.UpsertMany([]Item{
+		newTestValue("c", "3", base.Add(3*time.Second)),
+		newTestValue("d", "4", base.Add(4*time.Second)),
+	})
+
+	if bt.Size() != 2 {
+		t.Fatalf("Size=%d, want 2", bt.Size())
+	}
+	if !bt.Has(newTestFilter("c", base)) || !bt.Has(newTestFilter("d", base)) {
+		t.Fatalf("newest keys must survive capacity eviction")
+	}
+	if len(*records) != 2 || (*records)[0].key != "a" || (*records)[1].key != "b" {
+		t.Fatalf("evicted=%v, want oldest a and b", *records)
+	}
+	for _, r := range *records {
+		if r.reason != EvictCapacity {
+			t.Fatalf("reason=%v, want capacity", r.reason)
+		}
+	}
+}
+
+func TestBTree_OnEvict_CallbackOutsideLock(t *testing.T) {
+	t.Parallel()
+
+	var bt TtlBTree
+	calls := 0
+	bt = NewByteKeyBTree(Options{OnEvict: func(key, value []byte, reason EvictReason) {
+		// повторный вход в дерево не должен приводить к дедлоку
+		_ = bt.Size()
+		calls++
+	}})
+	bt.Upsert(newTestFilter("k", time.Unix(0, 0)))
+	bt.Reset()
+	if calls != 1 {
+		t.Fatalf("OnEvict calls=%d, want 1", calls)
+	}
+}
+
+// ============================================================================
+// Массовая загрузка: LoadSorted / LoadSortedFrom
+// ============================================================================
+
+func TestBTree_LoadSorted_EmptyTree(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{Degree: 4})
+	ts := time.Unix(18_000, 0)
+
+	items := make([]Item, 0, 1000)
+	for i := 0; i < 1000; i++ {
+		items = append(items, newTestValue(fmt.Sprintf("k%05d", i), "v", ts))
+	}
+	loaded, err := bt.LoadSorted(items)
+	if err != nil {
+		t.Fatalf("LoadSorted: %v", err)
+	}
+	if loaded != 1000 || bt.Size() != 1000 {
+		t.Fatalf("loaded=%d size=%d, want 1000", loaded, bt.Size())
+	}
+
+	var prev string
+	_ = bt.ForEach(func(key []byte, _ int64) bool {
+		if prev != "" && string(key) <= prev {
+			t.Fatalf("order violated: %q after %q", key, prev)
+		}
+		prev = string(key)
+		return true
+	})
+}
+
+func TestBTree_LoadSorted_MergesIntoExistingAndRejectsUnsorted(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	ts := time.Unix(19_000, 0)
+	bt.Upsert(newTestValue("b", "old", ts))
+
+	loaded, err := bt.LoadSorted([]Item{
+		newTestValue("a", "1", ts),
+		newTestValue("b", "2", ts),
+		nil,
+		newTestValue("c", "3", ts),
+	})
+	if err != nil {
+		t.Fatalf("LoadSorted: %v", err)
+	}
+	if loaded != 2 || bt.Size() != 3 {
+		t.Fatalf("loaded=%d size=%d, want 2 and 3", loaded, bt.Size())
+	}
+	found, _ := bt.GetNodeItem(newTestFilter("b", ts))
+	if string(found.Value()) != "2" {
+		t.Fatalf("loaded item must replace existing, got %q", found.Value())
+	}
+
+	if _, err := bt.LoadSorted([]Item{newTestValue("z", "", ts), newTestValue("y", "", ts)}); err == nil {
+		t.Fatalf("unsorted input must be rejected")
+	}
+	if bt.Has(newTestFilter("z", ts)) {
+		t.Fatalf("tree must not change on rejected load")
+	}
+}
+
+// ============================================================================
+// Обход со значениями: ForEachKV / ForEachKVUnsafe / AscendFrom
+// ============================================================================
+
+func TestBTree_ForEachKV_CopiesValues(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	ts := time.Unix(20_000, 0)
+	bt.Upsert(newTestValue("b", "vb", ts))
+	bt.Upsert(newTestValue("a", "va", ts))
+	bt.Upsert(newTestFilter("c", ts))
+
+	var got []string
+	err := bt.ForEachKV(func(key, value []byte, timestamp int64) bool {
+		got = append(got, string(key)+"="+string(value))
+		if timestamp != ts.Unix() {
+			t.Fatalf("timestamp=%d, want %d", timestamp, ts.Unix())
+		}
+		if len(value) > 0 {
+			value[0] = 'X' // портим копию
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ForEachKV: %v", err)
+	}
+	want := []string{"a=va", "b=vb", "c="}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("ForEachKV=%v, want %v", got, want)
+	}
+
+	found, _ := bt.GetNodeItem(newTestFilter("a", ts))
+	if string(found.Value()) != "va" {
+		t.Fatalf("ForEachKV must pass copies, tree value changed to %q", found.Value())
+	}
+
+	count := 0
+	_ = bt.ForEachKVUnsafe(func(key, value []byte, _ int64) bool {
+		count++
+		return false
+	})
+	if count != 1 {
+		t.Fatalf("ForEachKVUnsafe must stop early, count=%d", count)
+	}
+	if err := bt.ForEachKV(nil); err == nil {
+		t.Fatalf("nil callback must fail")
+	}
+}
+
+func TestBTree_AscendFrom_Seek(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	ts := time.Unix(21_000, 0)
+	for _, k := range []string{"user:1", "user:3", "user:5", "zzz"} {
+		bt.Upsert(newTestValue(k, k, ts))
+	}
+
+	var got []string
+	_ = bt.AscendFrom([]byte("user:2"), func(key, value []byte, _ int64) bool {
+		got = append(got, string(key))
+		return len(got) < 2
+	})
+	if strings.Join(got, ",") != "user:3,user:5" {
+		t.Fatalf("AscendFrom(user:2)=%v, want [user:3 user:5]", got)
+	}
+
+	got = got[:0]
+	_ = bt.AscendFrom(nil, func(key, _ []byte, _ int64) bool {
+		got = append(got, string(key))
+		return true
+	})
+	if len(got) != 4 {
+		t.Fatalf("AscendFrom(nil) must iterate all, got %v", got)
+	}
+}
+
+// ============================================================================
+// Атомарное извлечение: Pop
+// ============================================================================
+
+func TestBTree_Pop(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{})
+	ts := time.Unix(22_000, 0)
+	bt.Upsert(newTestValue("job", "payload", ts))
+
+	got, ok := bt.Pop(newTestFilter("job", ts))
+	if !ok || string(got.Value()) != "payload" {
+		t.Fatalf("Pop=(%v,%v), want payload", got, ok)
+	}
+	if _, ok := bt.Pop(newTestFilter("job", ts)); ok {
+		t.Fatalf("second Pop must miss")
+	}
+	if bt.Size() != 0 {
+		t.Fatalf("Pop must delete item")
+	}
+}
+
+func TestBTree_Concurrent_PopClaimsOnce(t *testing.T) {
+	bt := NewByteKeyBTree(Options{})
+	ts := time.Unix(23_000, 0)
+	const jobs = 100
+	for i := 0; i < jobs; i++ {
+		bt.Upsert(newTestValue(fmt.Sprintf("job:%03d", i), "p", ts))
+	}
+
+	var claimed sync.Map
+	var wg sync.WaitGroup
+	var duplicates, total int64
+	var mu sync.Mutex
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < jobs; i++ {
+				if it, ok := bt.Pop(newTestFilter(fmt.Sprintf("job:%03d", i), ts)); ok {
+					_, loaded := claimed.LoadOrStore(string(it.Key()), true)
+					mu.Lock()
+					total++
+					if loaded {
+						duplicates++
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if duplicates != 0 || total != jobs {
+		t.Fatalf("claimed total=%d duplicates=%d, want %d and 0", total, duplicates, jobs)
+	}
+}
+
+// ============================================================================
+// Сжатие префиксов ключей
+// ============================================================================
+
+func TestBTree_CompressKeyPrefixes_SameBehaviour(t *testing.T) {
+	t.Parallel()
+
+	plain := NewByteKeyBTree(Options{})
+	compressed := NewByteKeyBTree(Options{CompressKeyPrefixes: true})
+	ts := time.Unix(29_000, 0)
+
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 500; i++ {
+		key := fmt.Sprintf("user:v3:session:%d", r.Intn(300))
+		if i%3 == 0 {
+			key = fmt.Sprintf("user:v3:%d", r.Intn(50))
+		}
+		if i%11 == 0 {
+			key = fmt.Sprintf("nodelim%d", i)
+		}
+		plain.Upsert(newTestValue(key, "v"+key, ts))
+		compressed.Upsert(newTestValue(key, "v"+key, ts))
+	}
+	plain.Delete(newTestFilter("user:v3:session:5", ts))
+	compressed.Delete(newTestFilter("user:v3:session:5", ts))
+
+	dump := func(bt TtlBTree) string {
+		var sb strings.Builder
+		_ = bt.ForEachKV(func(key, value []byte, _ int64) bool {
+			sb.WriteString(string(key) + "=" + string(value) + ";")
+			return true
+		})
+		return sb.String()
+	}
+	if dump(plain) != dump(compressed) {
+		t.Fatalf("compressed tree content differs from plain tree")
+	}
+
+	it, ok := compressed.GetNodeItem(newTestFilter("user:v3:session:7", ts))
+	if ok != plain.Has(newTestFilter("user:v3:session:7", ts)) {
+		t.Fatalf("GetNodeItem hit mismatch")
+	}
+	if ok && (string(it.Key()) != "user:v3:session:7" || string(it.Value()) != "vuser:v3:session:7") {
+		t.Fatalf("GetNodeItem = %q/%q", it.Key(), it.Value())
+	}
+
+	var fromPlain, fromCompressed []string
+	_ = plain.AscendFrom([]byte("user:v3:session:2"), func(key, _ []byte, _ int64) bool {
+		fromPlain = append(fromPlain, string(key))
+		return len(fromPlain) < 5
+	})
+	_ = compressed.AscendFrom([]byte("user:v3:session:2"), func(key, _ []byte, _ int64) bool {
+		fromCompressed = append(fromCompressed, string(key))
+		return len(fromCompressed) < 5
+	})
+	if strings.Join(fromPlain, ",") != strings.Join(fromCompressed, ",") {
+		t.Fatalf("AscendFrom differs: %v vs %v", fromPlain, fromCompressed)
+	}
+}
+
+func TestBTree_CompressKeyPrefixes_KeyStats(t *testing.T) {
+	t.Parallel()
+
+	bt := NewByteKeyBTree(Options{CompressKeyPrefixes: true, MaxKeyPrefixes: 1})
+	ts := time.Unix(30_000, 0)
+	prefix := "tenant:acme:user:v3:session:"
+	for i := 0; i < 100; i++ {
+		bt.Upsert(newTestFilter(fmt.Sprintf("%s%04d", prefix, i), ts))
+	}
+	bt.Upsert(newTestFilter("other:1", ts)) // таблица заполнена — ключ хранится как есть
+
+	stats := bt.KeyStats()
+	if stats.Keys != 101 || stats.CompressedKeys != 100 || stats.Prefixes != 1 {
+		t.Fatalf("stats = %+v", stats)
+	}
+	if stats.KeyBytes != 100*(len(prefix)+4)+len("other:1") {
+		t.Fatalf("KeyBytes = %d", stats.KeyBytes)
+	}
+	if stats.StoredKeyBytes != 100*4+len(prefix)+len("other:1") || stats.SavedBytes <= 0 {
+		t.Fatalf("stored=%d saved=%d", stats.StoredKeyBytes, stats.SavedBytes)
+	}
+	if plain := NewByteKeyBTree(Options{}); plain.KeyStats().SavedBytes != 0 {
+		t.Fatalf("plain tree must report no savings")
+	}
+}
+
+func TestBTree_CompressKeyPrefixes_LookupWithoutKeyRebuild(t *testing.T) {
+	bt := NewByteKeyBTree(Options{CompressKeyPrefixes: true})
+	ts := time.Unix(31_000, 0)
+	for i := 0; i < 1000; i++ {
+		bt.Upsert(newTestFilter(fmt.Sprintf("user:v3:session:%04d", i), ts))
+		bt.Upsert(newTestFilter(fmt.Sprintf("order:%04d", i), ts))
+	}
+	probe := newTestFilter("user:v3:session:0500", ts)
+	compressedProbe := bt.(*ByteKeyBTree).compress(newTestFilter("order:0500", ts))
+
+	if !bt.Has(compressedProbe) {
+		t.Fatalf("compressed probe must be found")
+	}
+	// поиск не собирает ключи сжатых элементов дерева
+	if allocs := testing.AllocsPerRun(100, func() {
+		if !bt.Has(probe) {
+			t.Fatalf("key must be found")
+		}
+	}); allocs != 0 {
+		t.Fatalf("lookup allocates %.0f times, want 0", allocs)
+	}
+}
+
+func TestCompareSplitKeys(t *testing.T) {
+	t.Parallel()
+
+	r := rand.New(rand.NewSource(1))
+	randKey := func() []byte {
+		k := make([]byte, r.Intn(6))
+		for i := range k {
+			k[i] = byte('a' + r.Intn(3))
+		}
+		return k
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := randKey(), randKey()
+		ac, bc := r.Intn(len(a)+1), r.Intn(len(b)+1)
+		if got, want := compareSplitKeys(a[:ac], a[ac:], b[:bc], b[bc:]), bytes.Compare(a, b); got != want {
+			t.Fatalf("compareSplitKeys(%q|%q, %q|%q) = %d, want %d", a[:ac], a[ac:], b[:bc], b[bc:], got, want)
+		}
+	}
+}
+
+// ============================================================================
+// Согласованные изменения нескольких деревьев: MultiTree
+// ============================================================================
+
+func TestMultiTree_UpdateAllOrNothing(t *testing.T) {
+	t.Parallel()
+
+	primary := NewByteKeyBTree(Options{})
+	byTime := NewByteKeyBTree(Options{})
+	mt := NewMultiTree(primary, byTime)
+	ts := time.Unix(24_000, 0)
+
+	err := mt.Update(func(tx *MultiTreeTx) error {
+		tx.Upsert(0, newTestValue("user:1", "alice", ts))
+		tx.Upsert(1, newTestValue("24000:user:1", "", ts))
+		if it, ok := tx.Get(0, newTestFilter("user:1", ts)); !ok || string(it.Value()) != "alice" {
+			t.Errorf("tx must see own staged write, got (%v,%v)", it, ok)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if primary.Size() != 1 || byTime.Size() != 1 {
+		t.Fatalf("sizes = %d/%d, want 1/1", primary.Size(), byTime.Size())
+	}
+
+	boom := fmt.Errorf("boom")
+	err = mt.Update(func(tx *MultiTreeTx) error {
+		tx.Delete(0, newTestFilter("user:1", ts))
+		tx.Upsert(1, newTestValue("24001:user:2", "", ts))
+		return boom
+	})
+	if err != boom {
+		t.Fatalf("Update error = %v, want boom", err)
+	}
+	if primary.Size() != 1 || byTime.Size() != 1 {
+		t.Fatalf("failed Update must not change trees, sizes = %d/%d", primary.Size(), byTime.Size())
+	}
+
+	err = mt.Update(func(tx *MultiTreeTx) error {
+		tx.Upsert(1, newTestValue("24002:user:3", "", ts))
+		panic("oops")
+	})
+	if err == nil || byTime.Size() != 1 {
+		t.Fatalf("panicking Update must fail without changes, err=%v size=%d", err, byTime.Size())
+	}
+}
+
+func TestMultiTree_SnapshotIsolation(t *testing.T) {
+	t.Parallel()
+
+	a := NewByteKeyBTree(Options{})
+	b := NewByteKeyBTree(Options{})
+	mt := NewMultiTree(a, b)
+	ts := time.Unix(25_000, 0)
+	a.Upsert(newTestValue("k", "v1", ts))
+	b.Upsert(newTestValue("k", "v1", ts))
+
+	snap := mt.Snapshot()
+	_ = mt.Update(func(tx *MultiTreeTx) error {
+		tx.Upsert(0, newTestValue("k", "v2", ts))
+		tx.Delete(1, newTestFilter("k", ts))
+		return nil
+	})
+
+	if it, ok := snap.Get(0, newTestFilter("k", ts)); !ok || string(it.Value()) != "v1" {
+		t.Fatalf("snapshot must keep old value, got (%v,%v)", it, ok)
+	}
+	if snap.Len(1) != 1 || b.Size() != 0 {
+		t.Fatalf("snapshot len=%d live size=%d, want 1 and 0", snap.Len(1), b.Size())
+	}
+	var keys []string
+	_ = snap.ForEachKV(0, func(key, value []byte, _ int64) bool {
+		keys = append(keys, string(key)+"="+string(value))
+		return true
+	})
+	if strings.Join(keys, ",") != "k=v1" {
+		t.Fatalf("snapshot ForEachKV = %v", keys)
+	}
+}
+
+func TestMultiTree_Concurrent_OppositeOrderNoDeadlock(t *testing.T) {
+	a := NewByteKeyBTree(Options{})
+	b := NewByteKeyBTree(Options{})
+	ab := NewMultiTree(a, b)
+	ba := NewMultiTree(b, a)
+	ts := time.Unix(26_000, 0)
+
+	var wg sync.WaitGroup
+	for w, mt := range []*MultiTree{ab, ba, ab, ba} {
+		wg.Add(1)
+		go func(w int, mt *MultiTree) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("w%d:%03d", w, i)
+				_ = mt.Update(func(tx *MultiTreeTx) error {
+					tx.Upsert(0, newTestValue(key, "x", ts))
+					tx.Upsert(1, newTestValue(key, "x", ts))
+					return nil
+				})
+				_ = mt.Snapshot()
+			}
+		}(w, mt)
+	}
+	wg.Wait()
+
+	if a.Size() != 800 || b.Size() != 800 {
+		t.Fatalf("sizes = %d/%d, want 800/800", a.Size(), b.Size())
+	}
+}
+
 // ============================================================================
 // Конкурентные тесты
 // ============================================================================
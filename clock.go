@@ -0,0 +1,16 @@
+package memory_storage
+
+import "github.com/PavelAgarkov/memory-storage/sdk"
+
+// Clock, Ticker и Timer — общие с sdk абстракции времени: одни и те же управляемые часы
+// можно передать и в BitmapStorageConfigs, и в sdk.Options.
+type (
+	Clock  = sdk.Clock
+	Ticker = sdk.Ticker
+	Timer  = sdk.Timer
+)
+
+// NewRealClock возвращает Clock поверх пакета time.
+func NewRealClock() Clock {
+	return sdk.NewRealClock()
+}
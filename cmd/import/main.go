@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/PavelAgarkov/memory-storage/sdk/importer"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const usage = `usage: import [flags] <file>...
+
+Загружает JSONL, CSV или protodelim-файлы ("-" — stdin) в хранилище параллельными WriteBatch.
+Формат по умолчанию определяется расширением: .jsonl/.ndjson, .csv, .pb/.bin — protodelim.
+Для protodelim нужны -descriptor (FileDescriptorSet: protoc --include_imports --descriptor_set_out)
+и -message.
+
+flags:
+`
+
+type config struct {
+	dir        string
+	valueDir   string
+	keyFile    string
+	codec      string
+	format     string
+	keyField   string
+	keyPrefix  string
+	ttl        time.Duration
+	workers    int
+	batch      int
+	dedupe     bool
+	dryRun     bool
+	maxErrors  int
+	descriptor string
+	message    string
+	quiet      bool
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dir, "dir", filepath.Join(".", "data", "v3"), "каталог LSM Badger")
+	flag.StringVar(&cfg.valueDir, "value-dir", "", "каталог value-log, по умолчанию <dir>/vlog")
+	flag.StringVar(&cfg.keyFile, "key", "", "файл ключа шифрования (32 байта), пусто — без шифрования")
+	flag.StringVar(&cfg.codec, "codec", "json", "кодек значений: json, msgpack, proto")
+	flag.StringVar(&cfg.format, "format", "", "формат входа: jsonl, csv, protodelim; пусто — по расширению")
+	flag.StringVar(&cfg.keyField, "key-field", "", "поле/столбец ключа (jsonl: без него — конверт key/value/ttl; csv: по умолчанию key)")
+	flag.StringVar(&cfg.keyPrefix, "key-prefix", "", "префикс ключей")
+	flag.DurationVar(&cfg.ttl, "ttl", 0, "TTL записей без собственного ttl")
+	flag.IntVar(&cfg.workers, "workers", 0, "параллельных WriteBatch, 0 — GOMAXPROCS")
+	flag.IntVar(&cfg.batch, "batch", 1000, "записей в подпартии WriteBatch")
+	flag.BoolVar(&cfg.dedupe, "dedupe", false, "пропускать повторы ключа (побеждает первое вхождение)")
+	flag.BoolVar(&cfg.dryRun, "dry-run", false, "только проверить записи кодеком, хранилище не открывается")
+	flag.IntVar(&cfg.maxErrors, "max-errors", 0, "допустимо ошибочных записей, -1 — без предела")
+	flag.StringVar(&cfg.descriptor, "descriptor", "", "FileDescriptorSet для protodelim")
+	flag.StringVar(&cfg.message, "message", "", "полное имя сообщения protodelim, например app.v1.User")
+	flag.BoolVar(&cfg.quiet, "quiet", false, "без индикатора прогресса")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config, files []string) error {
+	opts := importer.Options{
+		KeyField:  cfg.keyField,
+		KeyPrefix: cfg.keyPrefix,
+		TTL:       cfg.ttl,
+		Workers:   cfg.workers,
+		BatchSize: cfg.batch,
+		Dedupe:    cfg.dedupe,
+		DryRun:    cfg.dryRun,
+		MaxErrors: cfg.maxErrors,
+	}
+	if cfg.message != "" {
+		mt, err := loadMessageType(cfg.descriptor, cfg.message)
+		if err != nil {
+			return err
+		}
+		opts.Message = mt
+	}
+
+	store, err := openStore(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	failed := false
+	for _, name := range files {
+		opts.Format = importer.Format(cfg.format)
+		if opts.Format == "" {
+			if opts.Format, err = formatOf(name); err != nil {
+				return err
+			}
+		}
+		if opts.Format == importer.ProtoDelimited && cfg.codec != "proto" {
+			return fmt.Errorf("%s: protodelim import requires -codec proto", name)
+		}
+		stats, err := importFile(ctx, store, name, opts, cfg.quiet)
+		for _, e := range stats.Errors {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, e)
+		}
+		verb := "written"
+		if cfg.dryRun {
+			verb = "valid"
+		}
+		fmt.Printf("%s: %d records, %d %s, %d duplicates, %d failed in %v\n",
+			name, stats.Records, stats.Written, verb, stats.Skipped, stats.Failed, stats.Elapsed.Round(time.Millisecond))
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		failed = failed || stats.Failed > 0
+	}
+	if failed {
+		return fmt.Errorf("some records failed")
+	}
+	return nil
+}
+
+func importFile(ctx context.Context, store *sdk.Store, name string, opts importer.Options, quiet bool) (importer.Stats, error) {
+	var in io.Reader = os.Stdin
+	var total int64
+	if name != "-" {
+		f, err := os.Open(name)
+		if err != nil {
+			return importer.Stats{}, err
+		}
+		defer f.Close()
+		if fi, err := f.Stat(); err == nil {
+			total = fi.Size()
+		}
+		in = f
+	}
+	if !quiet {
+		opts.Progress = func(p importer.Progress) { drawProgress(p, total) }
+		defer fmt.Fprintln(os.Stderr)
+	}
+	return importer.Run(ctx, store, in, opts)
+}
+
+// drawProgress перерисовывает строку прогресса на stderr; без размера входа — только счётчики.
+func drawProgress(p importer.Progress, total int64) {
+	const width = 30
+	rate := float64(p.Written) / max(p.Elapsed.Seconds(), 1e-3)
+	if total <= 0 {
+		fmt.Fprintf(os.Stderr, "\r%d records %.0f rec/s", p.Records, rate)
+		return
+	}
+	frac := min(float64(p.Bytes)/float64(total), 1)
+	fill := int(frac * width)
+	fmt.Fprintf(os.Stderr, "\r[%s%s] %3.0f%% %d records %.0f rec/s %d failed",
+		strings.Repeat("=", fill), strings.Repeat(" ", width-fill), frac*100, p.Records, rate, p.Failed)
+}
+
+func formatOf(name string) (importer.Format, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".jsonl", ".ndjson":
+		return importer.JSONL, nil
+	case ".csv":
+		return importer.CSV, nil
+	case ".pb", ".bin":
+		return importer.ProtoDelimited, nil
+	}
+	return "", fmt.Errorf("%s: cannot infer format, use -format", name)
+}
+
+func loadMessageType(descriptor, name string) (protoreflect.MessageType, error) {
+	if descriptor == "" {
+		return nil, fmt.Errorf("-message requires -descriptor")
+	}
+	raw, err := os.ReadFile(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("%s: %w", descriptor, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, err
+	}
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, err
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a message", name)
+	}
+	return dynamicpb.NewMessageType(md), nil
+}
+
+// openStore открывает целевое хранилище; для -dry-run — пустое хранилище в памяти с тем же кодеком,
+// чтобы проверка не трогала данные.
+func openStore(ctx context.Context, cfg config) (*sdk.Store, error) {
+	opts := sdk.Options{
+		Dir:          cfg.dir,
+		ValueDir:     cfg.valueDir,
+		InMemory:     cfg.dryRun,
+		LoggingLevel: sdk.LogError,
+	}
+	if cfg.dryRun {
+		opts.Dir, opts.ValueDir = "", ""
+	} else if opts.ValueDir == "" {
+		opts.ValueDir = filepath.Join(cfg.dir, "vlog")
+	}
+	switch cfg.codec {
+	case "json":
+		opts.Codec = sdk.JSONCodec{}
+	case "msgpack":
+		opts.Codec = sdk.MsgpackCodec{}
+	case "proto":
+		opts.Codec = sdk.ProtoCodec{}
+	default:
+		return nil, fmt.Errorf("unknown codec %q", cfg.codec)
+	}
+	if cfg.keyFile != "" && !cfg.dryRun {
+		key, err := os.ReadFile(cfg.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKey = key
+		opts.IndexCacheSize = 64 << 20 // Badger требует кеш индексов при шифровании
+	}
+	return sdk.Open(ctx, opts, nil)
+}
@@ -0,0 +1,515 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/PavelAgarkov/memory-storage/sdk/admin"
+	"github.com/PavelAgarkov/memory-storage/sdk/query"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const usage = `usage: msctl [flags] <command> [args]
+
+commands:
+  query "SELECT key, value.name WHERE prefix = 'user:v3:' LIMIT 50"
+  get <key> [type]       значение ключа в JSON; proto-сообщение — type или тип из -proto-type
+  serve                 HTTP admin API (-addr, -token)
+  replay <trace.rec>    выполнить трассу StartRecording на хранилище (-read-only=false, -speed)
+  reclaim [light|normal|full]
+                        вернуть место на диске; full при сильной фрагментации пересобирает
+                        хранилище клоном (-read-only=false)
+  check [repair <quarantine.jsonl>]
+                        найти ключи со ссылками на пропавшие или обрезанные файлы value-log;
+                        repair выгружает их в файл карантина и удаляет (-read-only=false)
+  seed <spec.yaml>       заполнить хранилище тестовыми данными по шаблону sdk.SeedSpec (-read-only=false)
+  value-threshold [apply]
+                        размеры значений по префиксам и рекомендуемый ValueThreshold (-max-scan);
+                        apply сохраняет его для Options.AutoValueThreshold
+  heatmap <file|url>    тепловая карта доступа и рекомендуемый BlockCacheSize: файл Heatmap.WriteFile
+                        или /api/heatmap работающего узла (-token)
+
+flags:
+`
+
+type config struct {
+	dir      string
+	valueDir string
+	keyFile  string
+	codec    string
+	readOnly bool
+	format   string
+	maxScan  int
+	addr     string
+	token    string
+	speed    float64
+	// types — proto-типы из -descriptors и назначения -proto-type для декодирования значений
+	types *sdk.ProtoTypes
+}
+
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.dir, "dir", filepath.Join(".", "data", "v3"), "каталог LSM Badger")
+	flag.StringVar(&cfg.valueDir, "value-dir", "", "каталог value-log, по умолчанию <dir>/vlog")
+	flag.StringVar(&cfg.keyFile, "key", "", "файл ключа шифрования (32 байта), пусто — без шифрования")
+	flag.StringVar(&cfg.codec, "codec", "json", "кодек значений: json, msgpack, proto")
+	flag.BoolVar(&cfg.readOnly, "read-only", true, "открыть хранилище только на чтение")
+	flag.StringVar(&cfg.format, "format", "table", "формат вывода query: table или json")
+	flag.IntVar(&cfg.maxScan, "max-scan", 0, "максимум просмотренных записей на запрос, 0 — без ограничения")
+	flag.StringVar(&cfg.addr, "addr", "127.0.0.1:8089", "адрес HTTP admin API для serve")
+	flag.StringVar(&cfg.token, "token", "", "Bearer-токен HTTP admin API")
+	flag.Float64Var(&cfg.speed, "speed", 0, "темп replay относительно записи, 0 — без пауз")
+	cfg.types = sdk.NewProtoTypes()
+	var mappings []string
+	flag.Func("descriptors", "файл FileDescriptorSet (protoc --include_imports --descriptor_set_out), можно повторять",
+		cfg.types.LoadDescriptorSet)
+	flag.Func("proto-type", "назначение prefix=full.Name для декодирования proto-значений, можно повторять",
+		func(s string) error { mappings = append(mappings, s); return nil })
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	// назначения разбираются после всех -descriptors, чтобы порядок флагов не имел значения
+	for _, m := range mappings {
+		if err := cfg.types.ParsePrefixMapping(m); err != nil {
+			fmt.Fprintln(os.Stderr, "msctl:", err)
+			os.Exit(2)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, cfg, flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, "msctl:", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, cfg config, args []string) error {
+	switch args[0] {
+	case "query":
+		if len(args) != 2 {
+			return fmt.Errorf("query expects one argument, got %d", len(args)-1)
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		res, err := query.Run(ctx, store, args[1], cfg.queryOptions())
+		if err != nil {
+			return err
+		}
+		return printResult(res, cfg.format)
+	case "get":
+		if len(args) < 2 || len(args) > 3 {
+			return fmt.Errorf("get expects a key and optional proto type")
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		typeName := ""
+		if len(args) == 3 {
+			typeName = args[2]
+		}
+		return printValue(store, cfg.types, []byte(args[1]), typeName)
+	case "serve":
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		srv := &http.Server{
+			Addr:    cfg.addr,
+			Handler: admin.NewHandler(store, admin.Options{Token: cfg.token, Query: cfg.queryOptions(), Types: cfg.types}),
+		}
+		go func() {
+			<-ctx.Done()
+			_ = srv.Close()
+		}()
+		fmt.Fprintln(os.Stderr, "msctl: admin API on", cfg.addr)
+		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	case "replay":
+		if len(args) != 2 {
+			return fmt.Errorf("replay expects a trace file")
+		}
+		ops, err := sdk.ReadRecording(args[1])
+		if err != nil {
+			return err
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		stats, err := sdk.Replay(ctx, store, ops, sdk.ReplayOptions{Speed: cfg.speed})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("ops=%d mismatches=%d\n", stats.Ops, stats.Mismatches)
+		for op, d := range stats.Recorded {
+			fmt.Printf("%-15s recorded=%v replayed=%v\n", op, d, stats.Latency[op])
+		}
+		return nil
+	case "reclaim":
+		level := sdk.ReclaimNormal
+		if len(args) > 1 {
+			switch args[1] {
+			case "light":
+				level = sdk.ReclaimLight
+			case "normal":
+			case "full":
+				level = sdk.ReclaimFull
+			default:
+				return fmt.Errorf("unknown reclaim level %q", args[1])
+			}
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		rep, err := store.Reclaim(ctx, level)
+		if err != nil {
+			_ = store.Close()
+			return err
+		}
+		fmt.Printf("live ~%s, fragmentation %.0f%%, vlog rewrites %d\n", mib(rep.LiveBytes), rep.Fragmentation*100, rep.GCRewrites)
+		fmt.Printf("before: lsm %s vlog %s\n", mib(rep.Before.LSM), mib(rep.Before.VLog))
+		if rep.CloneDir != "" {
+			// msctl держит хранилище эксклюзивно, записей после снимка быть не может
+			if err := store.CloseAndInstallClone(rep); err != nil {
+				if errors.Is(err, sdk.ErrReclaimCloneStale) {
+					_ = store.Close()
+				}
+				return err
+			}
+			fmt.Println("rebuilt from clone")
+		} else if err := store.Close(); err != nil {
+			return err
+		}
+		fmt.Printf("after:  lsm %s vlog %s (%v)\n", mib(rep.After.LSM), mib(rep.After.VLog), rep.Duration.Round(time.Millisecond))
+		return nil
+	case "check":
+		var opts sdk.IntegrityOptions
+		if len(args) > 1 {
+			if len(args) != 3 || args[1] != "repair" {
+				return fmt.Errorf("check expects optional \"repair <quarantine file>\"")
+			}
+			f, err := os.OpenFile(args[2], os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			opts.Repair, opts.Quarantine = true, f
+		}
+		opts.VerifyTables = true
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		rep, err := store.CheckIntegrity(ctx, opts)
+		for _, d := range rep.Dangling {
+			fmt.Printf("%q version %d: %d bytes unreadable\n", d.Key, d.Version, d.ValueSize)
+		}
+		if len(rep.Dangling) > 0 {
+			fmt.Printf("vlog files present: %v\n", rep.VlogFiles)
+		}
+		if rep.TablesErr != nil {
+			fmt.Println("sst checksum:", rep.TablesErr)
+		}
+		fmt.Printf("%d keys checked, %d dangling, %d deleted (%v)\n",
+			rep.Checked, len(rep.Dangling), rep.Deleted, rep.Duration.Round(time.Millisecond))
+		if err != nil {
+			return err
+		}
+		if !rep.OK() && !opts.Repair {
+			return errors.New("integrity check failed")
+		}
+		return nil
+	case "seed":
+		if len(args) != 2 {
+			return fmt.Errorf("seed expects a spec file")
+		}
+		spec, err := sdk.LoadSeedSpec(args[1])
+		if err != nil {
+			return err
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		stats, err := sdk.SeedFromTemplate(ctx, store, spec)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%d keys, %s in %v\n", stats.Keys, mib(stats.Bytes), stats.Duration.Round(time.Millisecond))
+		return nil
+	case "value-threshold":
+		apply := len(args) > 1 && args[1] == "apply"
+		if len(args) > 2 || len(args) == 2 && !apply {
+			return fmt.Errorf("value-threshold expects optional \"apply\"")
+		}
+		store, err := openStore(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		defer store.Close()
+		rep, err := store.ValueThresholdReport(ctx, sdk.ValueThresholdOptions{MaxKeys: cfg.maxScan})
+		if err != nil {
+			return err
+		}
+		printValueThreshold(rep)
+		if apply {
+			if err := store.SaveValueThreshold(rep.Recommended.Threshold); err != nil {
+				return err
+			}
+			fmt.Println("saved, applies on next open with AutoValueThreshold")
+		}
+		return nil
+	case "heatmap":
+		if len(args) != 2 {
+			return fmt.Errorf("heatmap expects a file or admin API url")
+		}
+		hm, err := loadHeatmap(ctx, args[1], cfg.token)
+		if err != nil {
+			return err
+		}
+		printHeatmap(hm)
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func openStore(ctx context.Context, cfg config) (*sdk.Store, error) {
+	opts := sdk.Options{
+		Dir:          cfg.dir,
+		ValueDir:     cfg.valueDir,
+		ReadOnly:     cfg.readOnly,
+		LoggingLevel: sdk.LogError,
+	}
+	if opts.ValueDir == "" {
+		opts.ValueDir = filepath.Join(cfg.dir, "vlog")
+	}
+	switch cfg.codec {
+	case "json":
+		opts.Codec = sdk.JSONCodec{}
+	case "msgpack":
+		opts.Codec = sdk.MsgpackCodec{}
+	case "proto":
+		opts.Codec = sdk.ProtoCodec{}
+	default:
+		return nil, fmt.Errorf("unknown codec %q", cfg.codec)
+	}
+	if cfg.keyFile != "" {
+		key, err := os.ReadFile(cfg.keyFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.EncryptionKey = key
+		opts.IndexCacheSize = 64 << 20 // Badger требует кеш индексов при шифровании
+	}
+	return sdk.Open(ctx, opts, nil)
+}
+
+// queryOptions декодирует значения ключей с назначенным -proto-type в proto-сообщения.
+func (cfg config) queryOptions() query.Options {
+	return query.Options{
+		MaxScan: cfg.maxScan,
+		New: func(key []byte) any {
+			if m := cfg.types.NewMessage(key); m != nil {
+				return m
+			}
+			return new(any)
+		},
+	}
+}
+
+// printValue печатает значение ключа: proto-сообщение — через protojson, остальное — кодеком Store,
+// а если значение не декодируется — base64.
+func printValue(store *sdk.Store, types *sdk.ProtoTypes, key []byte, typeName string) error {
+	raw, err := store.Get(key)
+	if err != nil {
+		return err
+	}
+	var msg proto.Message
+	if typeName != "" {
+		mt, err := types.FindMessageByName(protoreflect.FullName(typeName))
+		if err != nil {
+			return fmt.Errorf("proto type %s: %w", typeName, err)
+		}
+		msg = mt.New().Interface()
+	} else {
+		msg = types.NewMessage(key)
+	}
+	if msg != nil {
+		if err := store.DecodeValue(key, raw, msg); err != nil {
+			return err
+		}
+		out, err := protojson.MarshalOptions{Multiline: true, Resolver: types}.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+		return nil
+	}
+	var v any
+	if err := store.DecodeValue(key, raw, &v); err != nil {
+		fmt.Fprintln(os.Stderr, "msctl: decode:", err)
+		fmt.Println(base64.StdEncoding.EncodeToString(raw))
+		return nil
+	}
+	out, err := json.MarshalIndent(query.JSONValue(v), "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(out))
+	return nil
+}
+
+func printResult(res *query.Result, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(res)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(res.Columns, "\t"))
+	for _, row := range res.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = cell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	more := ""
+	if res.Truncated {
+		more = " (truncated)"
+	}
+	fmt.Fprintf(os.Stderr, "%d rows, %d scanned%s\n", len(res.Rows), res.Scanned, more)
+	return nil
+}
+
+func printValueThreshold(rep sdk.ValueThresholdReport) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "prefix\tkeys\tbytes\tp50\tp90\tp99\tmax")
+	for _, p := range rep.Prefixes {
+		fmt.Fprintf(tw, "%q\t%d\t%s\t%d\t%d\t%d\t%d\n", p.Prefix, p.Keys, mib(p.Bytes), p.P50, p.P90, p.P99, p.Max)
+	}
+	_ = tw.Flush()
+	more := ""
+	if rep.Truncated {
+		more = " (truncated, estimates cover scanned keys only)"
+	}
+	fmt.Printf("\n%d keys%s, lsm budget %s\n", rep.Keys, more, mib(rep.LSMBudget))
+	for _, e := range []struct {
+		name string
+		est  sdk.ThresholdEstimate
+	}{{"current", rep.Current}, {"recommended", rep.Recommended}} {
+		fmt.Printf("%-12s threshold %7d: %3.0f%% inline, lsm %s, vlog %s\n",
+			e.name, e.est.Threshold, e.est.InlineFraction*100, mib(e.est.LSMBytes), mib(e.est.VlogBytes))
+	}
+}
+
+// loadHeatmap читает карту из файла или запрашивает у админ-API (src — http(s) URL).
+func loadHeatmap(ctx context.Context, src, token string) (sdk.Heatmap, error) {
+	if !strings.HasPrefix(src, "http://") && !strings.HasPrefix(src, "https://") {
+		return sdk.ReadHeatmap(src)
+	}
+	var hm sdk.Heatmap
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, src, nil)
+	if err != nil {
+		return hm, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return hm, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var e struct{ Error string }
+		_ = json.NewDecoder(resp.Body).Decode(&e)
+		return hm, fmt.Errorf("%s: %s %s", src, resp.Status, e.Error)
+	}
+	return hm, json.NewDecoder(resp.Body).Decode(&hm)
+}
+
+func printHeatmap(hm sdk.Heatmap) {
+	fmt.Printf("sampled %.2f%% of keys since %s, estimates for the whole store (keys / bytes)\n\n",
+		hm.SampleRate*100, hm.Since.Format(time.RFC3339))
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprint(tw, "last read \\ reads\t")
+	for _, f := range hm.Frequency {
+		fmt.Fprintf(tw, "%s\t", f)
+	}
+	fmt.Fprintln(tw)
+	for i, r := range hm.Recency {
+		fmt.Fprintf(tw, "%s\t", r)
+		for _, c := range hm.Cells[i] {
+			if c.Keys == 0 {
+				fmt.Fprint(tw, "-\t")
+				continue
+			}
+			fmt.Fprintf(tw, "%d / %s\t", c.Keys, mib(c.Bytes))
+		}
+		fmt.Fprintln(tw)
+	}
+	_ = tw.Flush()
+	fmt.Printf("\ntotal %d keys, %s\n", hm.Total.Keys, mib(hm.Total.Bytes))
+	fmt.Printf("working set (read within %v): %d keys, %s (lsm %s, vlog %s)\n",
+		hm.WorkingSetWindow, hm.WorkingSet.Keys, mib(hm.WorkingSet.Bytes), mib(hm.WorkingSetLSMBytes), mib(hm.WorkingSetVLogBytes))
+	fmt.Printf("BlockCacheSize: current %s, recommended %s\n", mib(hm.BlockCacheSize), mib(hm.RecommendedBlockCacheSize))
+	if hm.WorkingSetVLogBytes > 0 {
+		fmt.Printf("value log working set is served by the OS page cache: keep ~%s of free memory for it\n", mib(hm.WorkingSetVLogBytes))
+	}
+}
+
+func mib(b int64) string {
+	return fmt.Sprintf("%.1f MiB", float64(b)/(1<<20))
+}
+
+func cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "NULL"
+	case string:
+		return v
+	}
+	b, err := json.Marshal(query.JSONValue(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
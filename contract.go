@@ -18,10 +18,20 @@ type (
 		MustWarmer(ctx context.Context, warmerFunc WarmerFunc)
 		// Contains проверяет, содержится ли ключ в хранилище
 		Contains(key uint64) bool
+		// ContainsMany проверяет ключи одной операцией под одним локом; i-й результат — для keys[i]
+		ContainsMany(keys []uint64) []bool
+		// ContainsAll возвращает true, если в хранилище есть все ключи (для пустого keys — true)
+		ContainsAll(keys []uint64) bool
+		// ContainsAny возвращает true, если в хранилище есть хотя бы один из ключей
+		ContainsAny(keys []uint64) bool
 		// UpsertMany добавляет несколько ключей в хранилище
 		UpsertMany(keys []uint64)
 		// RemoveMany удаляет несколько ключей из хранилища
 		RemoveMany(keys []uint64)
+		// UpsertRange добавляет все ключи полуинтервала [lo, hi) без материализации среза
+		UpsertRange(lo, hi uint64)
+		// RemoveRange удаляет все ключи полуинтервала [lo, hi)
+		RemoveRange(lo, hi uint64)
 		// GetCount возвращает количество элементов в хранилище
 		GetCount() uint64
 		// Clear очищает хранилище
@@ -32,12 +42,42 @@ type (
 		ReadFromBuffer(ctx context.Context, buffer *bytes2.Buffer) (int64, error)
 		// GetBytesFromBitmap возвращает байтовое представление bitmap
 		GetBytesFromBitmap() ([]byte, error)
+		// Snapshot возвращает байтовое представление копии bitmap, снятой под коротким локом;
+		// сериализация идёт без блокировки писателей
+		Snapshot() ([]byte, error)
 		// Recover восстанавливает bitmap из байтового представления
 		Recover(ctx context.Context) error
 		// Replicate реплицирует данные из bitmap в хранилище
 		Replicate(ctx context.Context) error
 		// DropReplicationKey удаляет ключ репликации из хранилища
 		DropReplicationKey(ctx context.Context) error
+		// Replay применяет записи журнала операций начиная с from (например, после Recover из устаревшей реплики)
+		Replay(ctx context.Context, from time.Time) error
+	}
+
+	// MemorySet — членство ключей без управления жизненным циклом bitmap (прогрев, репликация):
+	// всё, что нужно сервисам-потребителям. Реализуется и MemorySetStorage, и RemoteSetClient.
+	MemorySet interface {
+		Contains(key uint64) bool
+		ContainsMany(keys []uint64) []bool
+		UpsertMany(keys []uint64)
+		RemoveMany(keys []uint64)
+		GetCount() uint64
+	}
+
+	// MemorySetStatistics — аналитика по множеству без выгрузки bitmap целиком.
+	// Реализуется хранилищами, которые поддерживают упорядоченные операции (например, roaring64).
+	MemorySetStatistics interface {
+		// RangeCardinality возвращает количество ключей в полуинтервале [lo, hi)
+		RangeCardinality(lo, hi uint64) uint64
+		// Minimum возвращает наименьший ключ; false, если хранилище пустое
+		Minimum() (uint64, bool)
+		// Maximum возвращает наибольший ключ; false, если хранилище пустое
+		Maximum() (uint64, bool)
+		// Rank возвращает количество ключей, меньших или равных x
+		Rank(x uint64) uint64
+		// Select возвращает n-й по возрастанию ключ (с нуля)
+		Select(n uint64) (uint64, error)
 	}
 
 	MemorySetStorageReplicator interface {
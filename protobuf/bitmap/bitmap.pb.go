@@ -0,0 +1,432 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v6.31.1
+// source: bitmap/bitmap.proto
+
+package bitmappb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ContainsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Set           string                 `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	Key           uint64                 `protobuf:"varint,2,opt,name=key,proto3" json:"key,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContainsRequest) Reset() {
+	*x = ContainsRequest{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainsRequest) ProtoMessage() {}
+
+func (x *ContainsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainsRequest.ProtoReflect.Descriptor instead.
+func (*ContainsRequest) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ContainsRequest) GetSet() string {
+	if x != nil {
+		return x.Set
+	}
+	return ""
+}
+
+func (x *ContainsRequest) GetKey() uint64 {
+	if x != nil {
+		return x.Key
+	}
+	return 0
+}
+
+type ContainsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Contains      bool                   `protobuf:"varint,1,opt,name=contains,proto3" json:"contains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContainsResponse) Reset() {
+	*x = ContainsResponse{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainsResponse) ProtoMessage() {}
+
+func (x *ContainsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainsResponse.ProtoReflect.Descriptor instead.
+func (*ContainsResponse) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ContainsResponse) GetContains() bool {
+	if x != nil {
+		return x.Contains
+	}
+	return false
+}
+
+type KeysRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Set           string                 `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	Keys          []uint64               `protobuf:"varint,2,rep,packed,name=keys,proto3" json:"keys,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KeysRequest) Reset() {
+	*x = KeysRequest{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KeysRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KeysRequest) ProtoMessage() {}
+
+func (x *KeysRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KeysRequest.ProtoReflect.Descriptor instead.
+func (*KeysRequest) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *KeysRequest) GetSet() string {
+	if x != nil {
+		return x.Set
+	}
+	return ""
+}
+
+func (x *KeysRequest) GetKeys() []uint64 {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+// ContainsManyResponse — contains[i] для keys[i] запроса.
+type ContainsManyResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Contains      []bool                 `protobuf:"varint,1,rep,packed,name=contains,proto3" json:"contains,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ContainsManyResponse) Reset() {
+	*x = ContainsManyResponse{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ContainsManyResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ContainsManyResponse) ProtoMessage() {}
+
+func (x *ContainsManyResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ContainsManyResponse.ProtoReflect.Descriptor instead.
+func (*ContainsManyResponse) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *ContainsManyResponse) GetContains() []bool {
+	if x != nil {
+		return x.Contains
+	}
+	return nil
+}
+
+type MutationResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MutationResponse) Reset() {
+	*x = MutationResponse{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MutationResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MutationResponse) ProtoMessage() {}
+
+func (x *MutationResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MutationResponse.ProtoReflect.Descriptor instead.
+func (*MutationResponse) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{4}
+}
+
+type CardinalityRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Set           string                 `protobuf:"bytes,1,opt,name=set,proto3" json:"set,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CardinalityRequest) Reset() {
+	*x = CardinalityRequest{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardinalityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardinalityRequest) ProtoMessage() {}
+
+func (x *CardinalityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardinalityRequest.ProtoReflect.Descriptor instead.
+func (*CardinalityRequest) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *CardinalityRequest) GetSet() string {
+	if x != nil {
+		return x.Set
+	}
+	return ""
+}
+
+type CardinalityResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         uint64                 `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CardinalityResponse) Reset() {
+	*x = CardinalityResponse{}
+	mi := &file_bitmap_bitmap_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardinalityResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardinalityResponse) ProtoMessage() {}
+
+func (x *CardinalityResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_bitmap_bitmap_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardinalityResponse.ProtoReflect.Descriptor instead.
+func (*CardinalityResponse) Descriptor() ([]byte, []int) {
+	return file_bitmap_bitmap_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CardinalityResponse) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+var File_bitmap_bitmap_proto protoreflect.FileDescriptor
+
+const file_bitmap_bitmap_proto_rawDesc = "" +
+	"\n" +
+	"\x13bitmap/bitmap.proto\x12\tbitmap.v1\"5\n" +
+	"\x0fContainsRequest\x12\x10\n" +
+	"\x03set\x18\x01 \x01(\tR\x03set\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\x04R\x03key\".\n" +
+	"\x10ContainsResponse\x12\x1a\n" +
+	"\bcontains\x18\x01 \x01(\bR\bcontains\"3\n" +
+	"\vKeysRequest\x12\x10\n" +
+	"\x03set\x18\x01 \x01(\tR\x03set\x12\x12\n" +
+	"\x04keys\x18\x02 \x03(\x04R\x04keys\"2\n" +
+	"\x14ContainsManyResponse\x12\x1a\n" +
+	"\bcontains\x18\x01 \x03(\bR\bcontains\"\x12\n" +
+	"\x10MutationResponse\"&\n" +
+	"\x12CardinalityRequest\x12\x10\n" +
+	"\x03set\x18\x01 \x01(\tR\x03set\"+\n" +
+	"\x13CardinalityResponse\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x04R\x05count2\xf1\x02\n" +
+	"\rBitmapService\x12C\n" +
+	"\bContains\x12\x1a.bitmap.v1.ContainsRequest\x1a\x1b.bitmap.v1.ContainsResponse\x12G\n" +
+	"\fContainsMany\x12\x16.bitmap.v1.KeysRequest\x1a\x1f.bitmap.v1.ContainsManyResponse\x12A\n" +
+	"\n" +
+	"UpsertMany\x12\x16.bitmap.v1.KeysRequest\x1a\x1b.bitmap.v1.MutationResponse\x12A\n" +
+	"\n" +
+	"RemoveMany\x12\x16.bitmap.v1.KeysRequest\x1a\x1b.bitmap.v1.MutationResponse\x12L\n" +
+	"\vCardinality\x12\x1d.bitmap.v1.CardinalityRequest\x1a\x1e.bitmap.v1.CardinalityResponseBAZ?github.com/PavelAgarkov/memory-storage/protobuf/bitmap;bitmappbb\x06proto3"
+
+var (
+	file_bitmap_bitmap_proto_rawDescOnce sync.Once
+	file_bitmap_bitmap_proto_rawDescData []byte
+)
+
+func file_bitmap_bitmap_proto_rawDescGZIP() []byte {
+	file_bitmap_bitmap_proto_rawDescOnce.Do(func() {
+		file_bitmap_bitmap_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_bitmap_bitmap_proto_rawDesc), len(file_bitmap_bitmap_proto_rawDesc)))
+	})
+	return file_bitmap_bitmap_proto_rawDescData
+}
+
+var file_bitmap_bitmap_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_bitmap_bitmap_proto_goTypes = []any{
+	(*ContainsRequest)(nil),      // 0: bitmap.v1.ContainsRequest
+	(*ContainsResponse)(nil),     // 1: bitmap.v1.ContainsResponse
+	(*KeysRequest)(nil),          // 2: bitmap.v1.KeysRequest
+	(*ContainsManyResponse)(nil), // 3: bitmap.v1.ContainsManyResponse
+	(*MutationResponse)(nil),     // 4: bitmap.v1.MutationResponse
+	(*CardinalityRequest)(nil),   // 5: bitmap.v1.CardinalityRequest
+	(*CardinalityResponse)(nil),  // 6: bitmap.v1.CardinalityResponse
+}
+var file_bitmap_bitmap_proto_depIdxs = []int32{
+	0, // 0: bitmap.v1.BitmapService.Contains:input_type -> bitmap.v1.ContainsRequest
+	2, // 1: bitmap.v1.BitmapService.ContainsMany:input_type -> bitmap.v1.KeysRequest
+	2, // 2: bitmap.v1.BitmapService.UpsertMany:input_type -> bitmap.v1.KeysRequest
+	2, // 3: bitmap.v1.BitmapService.RemoveMany:input_type -> bitmap.v1.KeysRequest
+	5, // 4: bitmap.v1.BitmapService.Cardinality:input_type -> bitmap.v1.CardinalityRequest
+	1, // 5: bitmap.v1.BitmapService.Contains:output_type -> bitmap.v1.ContainsResponse
+	3, // 6: bitmap.v1.BitmapService.ContainsMany:output_type -> bitmap.v1.ContainsManyResponse
+	4, // 7: bitmap.v1.BitmapService.UpsertMany:output_type -> bitmap.v1.MutationResponse
+	4, // 8: bitmap.v1.BitmapService.RemoveMany:output_type -> bitmap.v1.MutationResponse
+	6, // 9: bitmap.v1.BitmapService.Cardinality:output_type -> bitmap.v1.CardinalityResponse
+	5, // [5:10] is the sub-list for method output_type
+	0, // [0:5] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_bitmap_bitmap_proto_init() }
+func file_bitmap_bitmap_proto_init() {
+	if File_bitmap_bitmap_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_bitmap_bitmap_proto_rawDesc), len(file_bitmap_bitmap_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_bitmap_bitmap_proto_goTypes,
+		DependencyIndexes: file_bitmap_bitmap_proto_depIdxs,
+		MessageInfos:      file_bitmap_bitmap_proto_msgTypes,
+	}.Build()
+	File_bitmap_bitmap_proto = out.File
+	file_bitmap_bitmap_proto_goTypes = nil
+	file_bitmap_bitmap_proto_depIdxs = nil
+}
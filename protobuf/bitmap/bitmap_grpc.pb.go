@@ -0,0 +1,279 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v6.31.1
+// source: bitmap/bitmap.proto
+
+package bitmappb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	BitmapService_Contains_FullMethodName     = "/bitmap.v1.BitmapService/Contains"
+	BitmapService_ContainsMany_FullMethodName = "/bitmap.v1.BitmapService/ContainsMany"
+	BitmapService_UpsertMany_FullMethodName   = "/bitmap.v1.BitmapService/UpsertMany"
+	BitmapService_RemoveMany_FullMethodName   = "/bitmap.v1.BitmapService/RemoveMany"
+	BitmapService_Cardinality_FullMethodName  = "/bitmap.v1.BitmapService/Cardinality"
+)
+
+// BitmapServiceClient is the client API for BitmapService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// BitmapService — общий узел с bitmap в памяти: сервисы проверяют и меняют членство по сети вместо
+// собственной копии множества. set — имя множества на узле, пустое — множество по умолчанию.
+type BitmapServiceClient interface {
+	Contains(ctx context.Context, in *ContainsRequest, opts ...grpc.CallOption) (*ContainsResponse, error)
+	ContainsMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*ContainsManyResponse, error)
+	UpsertMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*MutationResponse, error)
+	RemoveMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*MutationResponse, error)
+	Cardinality(ctx context.Context, in *CardinalityRequest, opts ...grpc.CallOption) (*CardinalityResponse, error)
+}
+
+type bitmapServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewBitmapServiceClient(cc grpc.ClientConnInterface) BitmapServiceClient {
+	return &bitmapServiceClient{cc}
+}
+
+func (c *bitmapServiceClient) Contains(ctx context.Context, in *ContainsRequest, opts ...grpc.CallOption) (*ContainsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ContainsResponse)
+	err := c.cc.Invoke(ctx, BitmapService_Contains_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bitmapServiceClient) ContainsMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*ContainsManyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ContainsManyResponse)
+	err := c.cc.Invoke(ctx, BitmapService_ContainsMany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bitmapServiceClient) UpsertMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*MutationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MutationResponse)
+	err := c.cc.Invoke(ctx, BitmapService_UpsertMany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bitmapServiceClient) RemoveMany(ctx context.Context, in *KeysRequest, opts ...grpc.CallOption) (*MutationResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MutationResponse)
+	err := c.cc.Invoke(ctx, BitmapService_RemoveMany_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *bitmapServiceClient) Cardinality(ctx context.Context, in *CardinalityRequest, opts ...grpc.CallOption) (*CardinalityResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CardinalityResponse)
+	err := c.cc.Invoke(ctx, BitmapService_Cardinality_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BitmapServiceServer is the server API for BitmapService service.
+// All implementations must embed UnimplementedBitmapServiceServer
+// for forward compatibility.
+//
+// BitmapService — общий узел с bitmap в памяти: сервисы проверяют и меняют членство по сети вместо
+// собственной копии множества. set — имя множества на узле, пустое — множество по умолчанию.
+type BitmapServiceServer interface {
+	Contains(context.Context, *ContainsRequest) (*ContainsResponse, error)
+	ContainsMany(context.Context, *KeysRequest) (*ContainsManyResponse, error)
+	UpsertMany(context.Context, *KeysRequest) (*MutationResponse, error)
+	RemoveMany(context.Context, *KeysRequest) (*MutationResponse, error)
+	Cardinality(context.Context, *CardinalityRequest) (*CardinalityResponse, error)
+	mustEmbedUnimplementedBitmapServiceServer()
+}
+
+// UnimplementedBitmapServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedBitmapServiceServer struct{}
+
+func (UnimplementedBitmapServiceServer) Contains(context.Context, *ContainsRequest) (*ContainsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Contains not implemented")
+}
+func (UnimplementedBitmapServiceServer) ContainsMany(context.Context, *KeysRequest) (*ContainsManyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ContainsMany not implemented")
+}
+func (UnimplementedBitmapServiceServer) UpsertMany(context.Context, *KeysRequest) (*MutationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpsertMany not implemented")
+}
+func (UnimplementedBitmapServiceServer) RemoveMany(context.Context, *KeysRequest) (*MutationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveMany not implemented")
+}
+func (UnimplementedBitmapServiceServer) Cardinality(context.Context, *CardinalityRequest) (*CardinalityResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cardinality not implemented")
+}
+func (UnimplementedBitmapServiceServer) mustEmbedUnimplementedBitmapServiceServer() {}
+func (UnimplementedBitmapServiceServer) testEmbeddedByValue()                       {}
+
+// UnsafeBitmapServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to BitmapServiceServer will
+// result in compilation errors.
+type UnsafeBitmapServiceServer interface {
+	mustEmbedUnimplementedBitmapServiceServer()
+}
+
+func RegisterBitmapServiceServer(s grpc.ServiceRegistrar, srv BitmapServiceServer) {
+	// If the following call pancis, it indicates UnimplementedBitmapServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&BitmapService_ServiceDesc, srv)
+}
+
+func _BitmapService_Contains_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ContainsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BitmapServiceServer).Contains(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BitmapService_Contains_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BitmapServiceServer).Contains(ctx, req.(*ContainsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BitmapService_ContainsMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BitmapServiceServer).ContainsMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BitmapService_ContainsMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BitmapServiceServer).ContainsMany(ctx, req.(*KeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BitmapService_UpsertMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BitmapServiceServer).UpsertMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BitmapService_UpsertMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BitmapServiceServer).UpsertMany(ctx, req.(*KeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BitmapService_RemoveMany_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BitmapServiceServer).RemoveMany(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BitmapService_RemoveMany_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BitmapServiceServer).RemoveMany(ctx, req.(*KeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BitmapService_Cardinality_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CardinalityRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BitmapServiceServer).Cardinality(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: BitmapService_Cardinality_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BitmapServiceServer).Cardinality(ctx, req.(*CardinalityRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// BitmapService_ServiceDesc is the grpc.ServiceDesc for BitmapService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var BitmapService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "bitmap.v1.BitmapService",
+	HandlerType: (*BitmapServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Contains",
+			Handler:    _BitmapService_Contains_Handler,
+		},
+		{
+			MethodName: "ContainsMany",
+			Handler:    _BitmapService_ContainsMany_Handler,
+		},
+		{
+			MethodName: "UpsertMany",
+			Handler:    _BitmapService_UpsertMany_Handler,
+		},
+		{
+			MethodName: "RemoveMany",
+			Handler:    _BitmapService_RemoveMany_Handler,
+		},
+		{
+			MethodName: "Cardinality",
+			Handler:    _BitmapService_Cardinality_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "bitmap/bitmap.proto",
+}
@@ -3,8 +3,10 @@ package memory_storage
 import (
 	bytes2 "bytes"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/RoaringBitmap/roaring/roaring64"
@@ -19,6 +21,15 @@ type roaringBitmapStorage struct {
 
 	replicator MemorySetStorageReplicator // репликатор для репликации данных в запасное хранилище
 	warmer     *Warmer                    // функция, которая будет вызвана для заполнения хранилища
+
+	lastReplicaDiff atomic.Pointer[ReplicaDivergence]
+	// replicationPaused — фоновая репликация по ReplicationTicker отключена (второе хранилище MigrationProxy)
+	replicationPaused atomic.Bool
+}
+
+// setBackgroundReplication включает и отключает репликацию по ReplicationTicker; явный Replicate работает всегда.
+func (s *roaringBitmapStorage) setBackgroundReplication(on bool) {
+	s.replicationPaused.Store(!on)
 }
 
 type BitmapStorageConfigs struct {
@@ -29,6 +40,15 @@ type BitmapStorageConfigs struct {
 	StorageName       string
 	DebugLogs         bool   // флаг для включения/отключения отладочных логов
 	ReplicationKey    string // ключ для репликации, например, "bitmap_current_goods_ids"
+	Clock             Clock  // источник времени для фоновых циклов; nil — реальное время
+	// OperationLog — опциональный журнал операций (add/remove) для аудита и догоняющего Replay.
+	// Запись в журнал идёт под локом хранилища, чтобы порядок в журнале совпадал с порядком применения.
+	OperationLog OperationLogSink
+	// DiffTicker — период фоновой DiffAgainstReplica; 0 — сверка с репликой выключена.
+	DiffTicker time.Duration
+	// OnReplicaDivergence получает результат каждой DiffAgainstReplica, например чтобы выставить
+	// метрику расхождения diff.Size(); может быть nil.
+	OnReplicaDivergence func(diff BitmapDiff)
 }
 
 func NewBitmapStorage(
@@ -39,8 +59,14 @@ func NewBitmapStorage(
 	if warmer.BatchSize <= 0 {
 		panic(fmt.Sprintf("[%s] warmer batch size must be greater than 0", configs.StorageName))
 	}
+	if configs.Clock == nil {
+		configs.Clock = NewRealClock()
+	}
+	// без copy-on-write: в roaring64 он действует только на верхнем уровне, вложенные 32-битные
+	// bitmap остаются общими с копией, и запись или RunOptimize меняли бы их под сериализацией Snapshot
+	bitmap := roaring64.NewBitmap()
 	s := &roaringBitmapStorage{
-		bitmap:     roaring64.NewBitmap(),
+		bitmap:     bitmap,
 		configs:    configs,
 		replicator: replicator,
 		warmer:     warmer,
@@ -70,10 +96,50 @@ func (s *roaringBitmapStorage) Contains(key uint64) bool {
 	return hit
 }
 
+// ContainsMany пересекает bitmap с bitmap запроса под одним RLock вместо len(keys) вызовов Contains.
+func (s *roaringBitmapStorage) ContainsMany(keys []uint64) []bool {
+	out := make([]bool, len(keys))
+	if len(keys) == 0 {
+		return out
+	}
+	query := roaring64.BitmapOf(keys...)
+	s.mu.RLock()
+	hits := roaring64.And(s.bitmap, query)
+	s.mu.RUnlock()
+	for i, k := range keys {
+		out[i] = hits.Contains(k)
+	}
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] contains %d of %d keys", s.configs.StorageName, hits.GetCardinality(), query.GetCardinality()))
+	}
+	return out
+}
+
+func (s *roaringBitmapStorage) ContainsAll(keys []uint64) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	query := roaring64.BitmapOf(keys...)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitmap.AndCardinality(query) == query.GetCardinality()
+}
+
+func (s *roaringBitmapStorage) ContainsAny(keys []uint64) bool {
+	if len(keys) == 0 {
+		return false
+	}
+	query := roaring64.BitmapOf(keys...)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitmap.Intersects(query)
+}
+
 func (s *roaringBitmapStorage) UpsertMany(keys []uint64) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.bitmap.AddMany(keys)
+	s.logOperation(OperationAdd, keys)
 	if s.withDebugLogs() {
 		fmt.Println(fmt.Sprintf("[%s] upserted %d keys", s.configs.StorageName, len(keys)))
 	}
@@ -85,21 +151,96 @@ func (s *roaringBitmapStorage) RemoveMany(keys []uint64) {
 	for _, k := range keys {
 		s.bitmap.Remove(k)
 	}
+	s.logOperation(OperationRemove, keys)
 	if s.withDebugLogs() {
 		fmt.Println(fmt.Sprintf("[%s] removed %d keys", s.configs.StorageName, len(keys)))
 	}
 }
 
+func (s *roaringBitmapStorage) UpsertRange(lo, hi uint64) {
+	if lo >= hi {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bitmap.AddRange(lo, hi)
+	s.logOperation(OperationAddRange, []uint64{lo, hi})
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] upserted range [%d, %d)", s.configs.StorageName, lo, hi))
+	}
+}
+
+func (s *roaringBitmapStorage) RemoveRange(lo, hi uint64) {
+	if lo >= hi {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bitmap.RemoveRange(lo, hi)
+	s.logOperation(OperationRemoveRange, []uint64{lo, hi})
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] removed range [%d, %d)", s.configs.StorageName, lo, hi))
+	}
+}
+
 func (s *roaringBitmapStorage) GetCount() uint64 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.bitmap.GetCardinality()
 }
 
+func (s *roaringBitmapStorage) RangeCardinality(lo, hi uint64) uint64 {
+	if lo >= hi {
+		return 0
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := s.bitmap.Rank(hi - 1)
+	if lo > 0 {
+		count -= s.bitmap.Rank(lo - 1)
+	}
+	return count
+}
+
+func (s *roaringBitmapStorage) Minimum() (uint64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.bitmap.IsEmpty() {
+		return 0, false
+	}
+	return s.bitmap.Minimum(), true
+}
+
+func (s *roaringBitmapStorage) Maximum() (uint64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.bitmap.IsEmpty() {
+		return 0, false
+	}
+	return s.bitmap.Maximum(), true
+}
+
+func (s *roaringBitmapStorage) Rank(x uint64) uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.bitmap.Rank(x)
+}
+
+func (s *roaringBitmapStorage) Select(n uint64) (uint64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, err := s.bitmap.Select(n)
+	if err != nil {
+		return 0, fmt.Errorf("[%s] select %d: %w", s.configs.StorageName, n, err)
+	}
+	return v, nil
+}
+
 func (s *roaringBitmapStorage) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	s.bitmap.Clear()
+	s.logOperation(OperationClear, nil)
 	if s.withDebugLogs() {
 		fmt.Println(fmt.Sprintf("[%s] cleared roaring64 bitmap storage", s.configs.StorageName))
 	}
@@ -172,6 +313,29 @@ func (s *roaringBitmapStorage) GetBytesFromBitmap() ([]byte, error) {
 	return bitmapBytes, nil
 }
 
+// Snapshot делает полную копию битовой карты под RLock и сериализует её уже без блокировки,
+// чтобы выгрузка большой карты не останавливала запись: копирование контейнеров — memcpy, оно
+// намного быстрее сериализации, а копия ни с чем не делит память.
+func (s *roaringBitmapStorage) Snapshot() ([]byte, error) {
+	s.mu.RLock()
+	if s.bitmap.IsEmpty() {
+		s.mu.RUnlock()
+		return nil, nil
+	}
+	snapshot := s.bitmap.Clone()
+	s.mu.RUnlock()
+
+	bitmapBytes, err := snapshot.ToBytes()
+	if err != nil {
+		return nil, err
+	}
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] bitmap snapshot converted to bytes successfully: %d bytes", s.configs.StorageName, len(bitmapBytes)))
+	}
+
+	return bitmapBytes, nil
+}
+
 // Recover восстанавливает хранилище из байтового представления, полученного из репликатора
 func (s *roaringBitmapStorage) Recover(ctx context.Context) error {
 	err := s.replicator.Recover(ctx, s, s.configs.ReplicationKey)
@@ -208,16 +372,77 @@ func (s *roaringBitmapStorage) DropReplicationKey(ctx context.Context) error {
 	return nil
 }
 
+// Replay догоняет состояние bitmap по журналу операций, начиная с момента from.
+// Применённые записи повторно в журнал не пишутся.
+func (s *roaringBitmapStorage) Replay(ctx context.Context, from time.Time) error {
+	if s.configs.OperationLog == nil {
+		return errors.New(fmt.Sprintf("[%s] operation log is not configured", s.configs.StorageName))
+	}
+	applied := 0
+	err := s.configs.OperationLog.ReadSince(ctx, from, func(record OperationRecord) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch record.Kind {
+		case OperationAdd:
+			s.bitmap.AddMany(record.Keys)
+		case OperationRemove:
+			for _, k := range record.Keys {
+				s.bitmap.Remove(k)
+			}
+		case OperationAddRange, OperationRemoveRange:
+			if len(record.Keys) != 2 {
+				return errors.New(fmt.Sprintf("[%s] range operation must contain 2 keys, got %d", s.configs.StorageName, len(record.Keys)))
+			}
+			if record.Kind == OperationAddRange {
+				s.bitmap.AddRange(record.Keys[0], record.Keys[1])
+			} else {
+				s.bitmap.RemoveRange(record.Keys[0], record.Keys[1])
+			}
+		case OperationClear:
+			s.bitmap.Clear()
+		default:
+			return errors.New(fmt.Sprintf("[%s] unknown operation kind %d", s.configs.StorageName, record.Kind))
+		}
+		applied++
+		return nil
+	})
+	if err != nil {
+		fmt.Println(fmt.Sprintf("[%s] failed to replay operation log", s.configs.StorageName))
+		return err
+	}
+	if s.withDebugLogs() {
+		fmt.Println(fmt.Sprintf("[%s] replayed %d operations since %s", s.configs.StorageName, applied, from.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+// logOperation пишет операцию в журнал; вызывается под локом записи.
+func (s *roaringBitmapStorage) logOperation(kind OperationKind, keys []uint64) {
+	if s.configs.OperationLog == nil {
+		return
+	}
+	record := OperationRecord{At: s.configs.Clock.Now(), Kind: kind, Keys: keys}
+	if err := s.configs.OperationLog.Append(context.Background(), record); err != nil {
+		fmt.Println(fmt.Sprintf("[%s] failed to append operation log: %v", s.configs.StorageName, err))
+	}
+}
+
 func (s *roaringBitmapStorage) background(ctx context.Context, configs BitmapStorageConfigs) {
 	GoRecover(
 		ctx,
 		func(localCtx context.Context) {
-			monitoringTicker := time.NewTicker(configs.MonitoringTicker)
+			monitoringTicker := configs.Clock.NewTicker(configs.MonitoringTicker)
 			defer monitoringTicker.Stop()
-			optimizingTicker := time.NewTicker(configs.OptimizingTicker)
+			optimizingTicker := configs.Clock.NewTicker(configs.OptimizingTicker)
 			defer optimizingTicker.Stop()
-			replicationTicker := time.NewTicker(configs.ReplicationTicker)
+			replicationTicker := configs.Clock.NewTicker(configs.ReplicationTicker)
 			defer replicationTicker.Stop()
+			var diffC <-chan time.Time
+			if configs.DiffTicker > 0 {
+				diffTicker := configs.Clock.NewTicker(configs.DiffTicker)
+				defer diffTicker.Stop()
+				diffC = diffTicker.C()
+			}
 
 			for {
 				select {
@@ -226,15 +451,22 @@ func (s *roaringBitmapStorage) background(ctx context.Context, configs BitmapSto
 						fmt.Println(fmt.Sprintf("[%s] context has done", s.configs.StorageName))
 					}
 					return
-				case <-monitoringTicker.C:
+				case <-monitoringTicker.C():
 					fmt.Println(localCtx, fmt.Sprintf("[%s] monitoring roaring64 bitmap storage", s.configs.StorageName))
-				case <-optimizingTicker.C:
+				case <-optimizingTicker.C():
 					s.optimize(localCtx)
-				case <-replicationTicker.C:
+				case <-replicationTicker.C():
+					if s.replicationPaused.Load() {
+						continue
+					}
 					err := s.Replicate(localCtx)
 					if err != nil {
 						fmt.Println(localCtx, fmt.Sprintf("[%s] failed to replicate bitmap", s.configs.StorageName))
 					}
+				case <-diffC:
+					if _, err := s.DiffAgainstReplica(localCtx); err != nil {
+						fmt.Println(fmt.Sprintf("[%s] failed to diff bitmap against replica: %v", s.configs.StorageName, err))
+					}
 				}
 			}
 		})
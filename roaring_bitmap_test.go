@@ -1,6 +1,9 @@
 package memory_storage
 
 import (
+	"bytes"
+	"context"
+	"sync"
 	"testing"
 	"time"
 )
@@ -22,3 +25,305 @@ func Test_bitmam_execute(t *testing.T) {
 		},
 	)
 }
+
+// manualClock — управляемые часы: тики отправляются вручную через Tick.
+type manualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers map[time.Duration]*manualTicker
+	created chan struct{}
+}
+
+func newManualClock(now time.Time) *manualClock {
+	return &manualClock{
+		now:     now,
+		tickers: make(map[time.Duration]*manualTicker),
+		created: make(chan struct{}, 16),
+	}
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTicker(d time.Duration) Ticker {
+	t := &manualTicker{ch: make(chan time.Time)}
+	c.mu.Lock()
+	c.tickers[d] = t
+	c.mu.Unlock()
+	c.created <- struct{}{}
+	return t
+}
+
+// WaitTickers ждёт, пока фоновый цикл создаст n тикеров.
+func (c *manualClock) WaitTickers(n int) {
+	for i := 0; i < n; i++ {
+		<-c.created
+	}
+}
+
+// Tick блокируется, пока фоновый цикл не примет тик.
+func (c *manualClock) Tick(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	ticker := c.tickers[d]
+	c.mu.Unlock()
+	ticker.ch <- now
+}
+
+// NewTimer — фоновые циклы bitmap таймеры не используют, таймер никогда не срабатывает.
+func (c *manualClock) NewTimer(d time.Duration) Timer {
+	return &manualTimer{ch: make(chan time.Time)}
+}
+
+type manualTicker struct {
+	ch chan time.Time
+}
+
+func (t *manualTicker) C() <-chan time.Time { return t.ch }
+func (t *manualTicker) Stop()               {}
+
+type manualTimer struct {
+	ch chan time.Time
+}
+
+func (t *manualTimer) C() <-chan time.Time        { return t.ch }
+func (t *manualTimer) Stop() bool                 { return true }
+func (t *manualTimer) Reset(d time.Duration) bool { return true }
+
+func Test_bitmap_background_with_manual_clock(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const (
+		monitoring  = 1 * time.Second
+		optimizing  = 2 * time.Second
+		replication = 3 * time.Second
+	)
+	clock := newManualClock(time.Unix(1_700_000_000, 0))
+	replicator := NewBitmapFakeReplicator("name")
+	configs := BitmapStorageConfigs{
+		StorageName:       "name",
+		MonitoringTicker:  monitoring,
+		OptimizingTicker:  optimizing,
+		ReplicationTicker: replication,
+		ReplicationKey:    "BitmapCurrentGoodsIDs",
+		Clock:             clock,
+	}
+	storage := NewBitmapStorage(replicator, configs, &Warmer{BatchSize: 10})
+	storage.MustWarmer(ctx, func(ctx context.Context, batchSize int32) ([]uint64, error) {
+		return nil, nil
+	})
+	clock.WaitTickers(3)
+
+	storage.UpsertMany([]uint64{1, 2, 3})
+	clock.Tick(replication)
+	// следующий тик принимается только после завершения репликации
+	clock.Tick(monitoring)
+
+	restored := NewBitmapStorage(replicator, configs, &Warmer{BatchSize: 10})
+	if err := restored.Recover(ctx); err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if restored.GetCount() != 3 {
+		t.Fatalf("expected 3 replicated keys, got %d", restored.GetCount())
+	}
+}
+
+func Test_bitmap_statistics(t *testing.T) {
+	storage := newTestBitmapStorage("stats")
+	stats := storage.(MemorySetStatistics)
+
+	if _, ok := stats.Minimum(); ok {
+		t.Fatalf("Minimum on empty storage must report false")
+	}
+
+	storage.UpsertMany([]uint64{5, 10, 15, 20, 1 << 40})
+
+	if got := stats.RangeCardinality(10, 20); got != 2 {
+		t.Fatalf("RangeCardinality[10,20)=%d, want 2", got)
+	}
+	if got := stats.RangeCardinality(0, 1<<41); got != 5 {
+		t.Fatalf("RangeCardinality over all=%d, want 5", got)
+	}
+	if min, _ := stats.Minimum(); min != 5 {
+		t.Fatalf("Minimum=%d, want 5", min)
+	}
+	if max, _ := stats.Maximum(); max != 1<<40 {
+		t.Fatalf("Maximum=%d, want %d", max, uint64(1<<40))
+	}
+	if got := stats.Rank(15); got != 3 {
+		t.Fatalf("Rank(15)=%d, want 3", got)
+	}
+	if got, err := stats.Select(1); err != nil || got != 10 {
+		t.Fatalf("Select(1)=(%d,%v), want 10", got, err)
+	}
+	if _, err := stats.Select(5); err == nil {
+		t.Fatalf("Select out of range must fail")
+	}
+}
+
+func Test_bitmap_upsert_and_remove_range(t *testing.T) {
+	storage := newTestBitmapStorage("ranges")
+
+	storage.UpsertRange(1_000_000, 2_000_000)
+	if got := storage.GetCount(); got != 1_000_000 {
+		t.Fatalf("count after UpsertRange=%d, want 1000000", got)
+	}
+	if !storage.Contains(1_000_000) || storage.Contains(2_000_000) {
+		t.Fatalf("range must be half-open [lo, hi)")
+	}
+
+	storage.RemoveRange(1_500_000, 3_000_000)
+	if got := storage.GetCount(); got != 500_000 {
+		t.Fatalf("count after RemoveRange=%d, want 500000", got)
+	}
+
+	// пустой интервал — no-op
+	storage.UpsertRange(10, 10)
+	storage.RemoveRange(20, 10)
+	if got := storage.GetCount(); got != 500_000 {
+		t.Fatalf("empty ranges must be no-op, count=%d", got)
+	}
+}
+
+func Test_bitmap_snapshot_is_isolated_from_writes(t *testing.T) {
+	storage := newTestBitmapStorage("snapshot")
+	storage.UpsertRange(0, 100_000)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := uint64(0); i < 1000; i++ {
+			storage.UpsertMany([]uint64{200_000 + i})
+		}
+	}()
+
+	data, err := storage.Snapshot()
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := newTestBitmapStorage("restored").(*roaringBitmapStorage)
+	if _, err := restored.ReadFromBuffer(context.Background(), bytes.NewBuffer(data)); err != nil {
+		t.Fatalf("ReadFromBuffer: %v", err)
+	}
+	if got := restored.GetCount(); got < 100_000 || got > 101_000 {
+		t.Fatalf("snapshot must be a consistent copy, got cardinality %d", got)
+	}
+	if got := restored.RangeCardinality(0, 100_000); got != 100_000 {
+		t.Fatalf("snapshot lost original keys: %d", got)
+	}
+	if got := storage.GetCount(); got != 101_000 {
+		t.Fatalf("writes during snapshot must be applied to storage, got %d", got)
+	}
+}
+
+func Test_bitmap_contains_many(t *testing.T) {
+	storage := newTestBitmapStorage("batch")
+	storage.UpsertMany([]uint64{1, 3, 5, 1 << 40})
+
+	// порядок и повторы ключей запроса сохраняются в ответе
+	got := storage.ContainsMany([]uint64{5, 2, 1 << 40, 5, 7})
+	want := []bool{true, false, true, true, false}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ContainsMany=%v, want %v", got, want)
+		}
+	}
+	if len(storage.ContainsMany(nil)) != 0 {
+		t.Fatalf("ContainsMany(nil) must be empty")
+	}
+
+	if !storage.ContainsAll([]uint64{1, 3, 3}) || storage.ContainsAll([]uint64{1, 2}) || !storage.ContainsAll(nil) {
+		t.Fatalf("unexpected ContainsAll results")
+	}
+	if !storage.ContainsAny([]uint64{2, 4, 5}) || storage.ContainsAny([]uint64{2, 4}) || storage.ContainsAny(nil) {
+		t.Fatalf("unexpected ContainsAny results")
+	}
+}
+
+func Test_bitmap_diff_against_replica(t *testing.T) {
+	replicator := NewBitmapFakeReplicator("diff")
+	var reported uint64
+	storage := NewBitmapStorage(replicator, BitmapStorageConfigs{
+		StorageName:         "diff",
+		ReplicationKey:      "diff",
+		OnReplicaDivergence: func(diff BitmapDiff) { reported = diff.Size() },
+	}, &Warmer{BatchSize: 10})
+	storage.UpsertMany([]uint64{1, 2, 3})
+	if err := storage.Replicate(context.Background()); err != nil {
+		t.Fatalf("Replicate: %v", err)
+	}
+	storage.UpsertMany([]uint64{4})
+	storage.RemoveMany([]uint64{1})
+
+	differ := storage.(MemorySetDiffer)
+	if _, ok := differ.LastReplicaDivergence(); ok {
+		t.Fatalf("no divergence must be recorded before the first diff")
+	}
+	diff, err := differ.DiffAgainstReplica(context.Background())
+	if err != nil {
+		t.Fatalf("DiffAgainstReplica: %v", err)
+	}
+	if got := diff.Added.ToArray(); len(got) != 1 || got[0] != 4 {
+		t.Fatalf("Added=%v, want [4]", got)
+	}
+	if got := diff.Removed.ToArray(); len(got) != 1 || got[0] != 1 {
+		t.Fatalf("Removed=%v, want [1]", got)
+	}
+	last, ok := differ.LastReplicaDivergence()
+	if !ok || last.Added != 1 || last.Removed != 1 || reported != 2 {
+		t.Fatalf("last=%+v ok=%t reported=%d", last, ok, reported)
+	}
+	if storage.GetCount() != 3 {
+		t.Fatalf("diff must not modify storage, count=%d", storage.GetCount())
+	}
+
+	// сравнение с другим поколением
+	next := newTestBitmapStorage("next")
+	next.UpsertMany([]uint64{2, 3, 4, 5})
+	diff, err = differ.Diff(next)
+	if err != nil || diff.Size() != 1 || !diff.Removed.Contains(5) {
+		t.Fatalf("Diff=%v,%v err=%v", diff.Added.ToArray(), diff.Removed.ToArray(), err)
+	}
+}
+
+func Test_bitmap_snapshot_concurrent_with_optimize(t *testing.T) {
+	storage := newTestBitmapStorage("optimize").(*roaringBitmapStorage)
+	storage.UpsertRange(0, 200_000)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// сплошные пачки ключей RunOptimize переводит в run-контейнеры — запись в контейнеры,
+		// которые сериализует параллельный Snapshot
+		for i := uint64(0); i < 50; i++ {
+			keys := make([]uint64, 5000)
+			for j := range keys {
+				keys[j] = (i+1)<<32 + uint64(j)
+			}
+			storage.UpsertMany(keys)
+			storage.optimize(context.Background())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := storage.Snapshot(); err != nil {
+				t.Errorf("Snapshot: %v", err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	if got := storage.GetCount(); got != 200_000+50*5000 {
+		t.Fatalf("count=%d, want %d", got, 200_000+50*5000)
+	}
+}
@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrAccessDenied — AccessController запретил операцию.
+var ErrAccessDenied = errors.New("access denied")
+
+// AccessOp — вид операции, проверяемой AccessController.
+type AccessOp string
+
+const (
+	AccessRead   AccessOp = "read"
+	AccessWrite  AccessOp = "write"
+	AccessDelete AccessOp = "delete"
+	// AccessScan проверяется для префикса скана, а не для каждого ключа.
+	AccessScan AccessOp = "scan"
+)
+
+// AccessController решает, может ли principal выполнить op над key.
+// Store спрашивает его в Get/Set/Delete/GetAndDelete, объектных методах, сканах префикса и
+// в транзакциях RunTx; специализированные структуры (SortedSet, List и т.п.) работают в обход.
+// Ненулевая ошибка отменяет операцию; для отказа возвращайте ошибку, оборачивающую ErrAccessDenied.
+type AccessController interface {
+	Check(ctx context.Context, principal string, op AccessOp, key []byte) error
+}
+
+type principalKey struct{}
+
+// WithPrincipal кладёт в контекст, от чьего имени выполняются операции (модуль, сервис, пользователь).
+// Его видит AccessController.
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom возвращает principal из контекста ("" — не задан).
+func PrincipalFrom(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
+
+// checkAccess спрашивает AccessController, если он задан.
+func (s *Store) checkAccess(ctx context.Context, op AccessOp, key []byte) error {
+	if s.access == nil {
+		return nil
+	}
+	return s.access.Check(ctx, PrincipalFrom(ctx), op, key)
+}
+
+// StaticAccessRule — правило StaticAccessRules: principals могут выполнять ops над ключами с prefix.
+type StaticAccessRule struct {
+	Prefix string `yaml:"prefix"`
+	// Principals — кому разрешено; "*" — всем, включая операции без principal.
+	Principals []string `yaml:"principals"`
+	// Ops — разрешённые операции; пустой список — все.
+	Ops []AccessOp `yaml:"ops"`
+}
+
+// StaticAccessRules — AccessController по статическому списку правил:
+//
+//	default: deny          # allow | deny — для ключей без подходящего правила
+//	rules:
+//	  - prefix: "billing:"
+//	    principals: [billing-svc, admin]
+//	  - prefix: "billing:reports:"
+//	    principals: ["*"]
+//	    ops: [read, scan]
+//
+// Для ключа действует правило с самым длинным совпавшим префиксом. Скан префикса дополнительно
+// проверяется всеми правилами внутри него: скан "b" не обойдёт правило для "billing:".
+type StaticAccessRules struct {
+	Default string             `yaml:"default"`
+	Rules   []StaticAccessRule `yaml:"rules"`
+}
+
+// ParseStaticAccessRules разбирает правила из YAML.
+func ParseStaticAccessRules(data []byte) (*StaticAccessRules, error) {
+	var r StaticAccessRules
+	if err := yaml.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse access rules: %w", err)
+	}
+	switch r.Default {
+	case "", "deny", "allow":
+	default:
+		return nil, fmt.Errorf("access rules: default must be allow or deny, got %q", r.Default)
+	}
+	for _, rule := range r.Rules {
+		for _, op := range rule.Ops {
+			switch op {
+			case AccessRead, AccessWrite, AccessDelete, AccessScan:
+			default:
+				return nil, fmt.Errorf("access rules: unknown op %q for prefix %q", op, rule.Prefix)
+			}
+		}
+	}
+	return &r, nil
+}
+
+// LoadStaticAccessRules читает правила из YAML-файла.
+func LoadStaticAccessRules(path string) (*StaticAccessRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseStaticAccessRules(data)
+}
+
+func (r *StaticAccessRules) Check(_ context.Context, principal string, op AccessOp, key []byte) error {
+	var best *StaticAccessRule
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if bytes.HasPrefix(key, []byte(rule.Prefix)) && (best == nil || len(rule.Prefix) > len(best.Prefix)) {
+			best = rule
+		}
+	}
+	if best == nil {
+		if r.Default != "allow" {
+			return fmt.Errorf("%w: %q cannot %s %q", ErrAccessDenied, principal, op, key)
+		}
+	} else if !best.allows(principal, op) {
+		return fmt.Errorf("%w: %q cannot %s %q", ErrAccessDenied, principal, op, key)
+	}
+
+	if op == AccessScan {
+		for i := range r.Rules {
+			rule := &r.Rules[i]
+			if len(rule.Prefix) > len(key) && bytes.HasPrefix([]byte(rule.Prefix), key) && !rule.allows(principal, op) {
+				return fmt.Errorf("%w: %q cannot %s %q (covers %q)", ErrAccessDenied, principal, op, key, rule.Prefix)
+			}
+		}
+	}
+	return nil
+}
+
+func (rule *StaticAccessRule) allows(principal string, op AccessOp) bool {
+	if len(rule.Ops) > 0 && !slices.Contains(rule.Ops, op) {
+		return false
+	}
+	if slices.Contains(rule.Principals, "*") {
+		return true
+	}
+	return principal != "" && slices.Contains(rule.Principals, principal)
+}
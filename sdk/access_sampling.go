@@ -0,0 +1,275 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"hash/maphash"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Выборочное наблюдение за доступом к ключам и профиль «мёртвых» ключей — записанных, но не
+// читаемых. Под наблюдение попадает доля ключей по хешу ключа (а не доля операций): для ключа из
+// выборки видны все его чтения и записи, поэтому «не читался ни разу за окно» — точный факт о нём, а
+// доля таких ключей в выборке — оценка для всего префикса. Учитываются чтения Get/GetContext,
+// MultiGet, GetObject, GetAndDelete, GetFirst, GetObjectWithFallback, сканов ScanPrefix* и транзакций RunTx;
+// записи Set/SetContext, SetObject, SetObjectIfVersion, WriteBatch и RunTx. Наблюдение живёт в
+// памяти и начинается заново после перезапуска. По той же выборке строится тепловая карта доступа
+// (AccessHeatmap).
+
+// ErrAccessSamplingOff — наблюдение за доступом не включено (StartAccessSampling).
+var ErrAccessSamplingOff = errors.New("access sampling is not started")
+
+// AccessSamplingOptions — параметры StartAccessSampling.
+type AccessSamplingOptions struct {
+	// SampleRate — доля ключей под наблюдением (0..1], по умолчанию 0.01.
+	SampleRate float64
+	// Prefix возвращает группу ключа в отчётах; по умолчанию — ключ до первого ':' включительно.
+	Prefix func(key []byte) string
+}
+
+type accessSampler struct {
+	rate      float64
+	threshold uint64
+	seed      maphash.Seed
+	prefix    func(key []byte) string
+	started   time.Time
+
+	mu        sync.Mutex
+	lastRead  map[uint64]int64 // хеш ключа → unix nano последнего чтения
+	lastWrite map[uint64]int64
+	reads     map[uint64]uint32 // хеш ключа → чтений с начала наблюдения
+}
+
+// StartAccessSampling включает наблюдение за доступом к доле ключей.
+func (s *Store) StartAccessSampling(opts AccessSamplingOptions) error {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 0.01
+	}
+	if opts.SampleRate > 1 {
+		return errors.New("access sample rate must be in (0, 1]")
+	}
+	if opts.Prefix == nil {
+		opts.Prefix = firstSegment
+	}
+	a := &accessSampler{
+		rate:      opts.SampleRate,
+		threshold: uint64(opts.SampleRate * math.MaxUint64),
+		seed:      maphash.MakeSeed(),
+		prefix:    opts.Prefix,
+		started:   s.clock.Now(),
+		lastRead:  make(map[uint64]int64),
+		lastWrite: make(map[uint64]int64),
+		reads:     make(map[uint64]uint32),
+	}
+	if opts.SampleRate == 1 {
+		a.threshold = math.MaxUint64
+	}
+	if !s.accessSample.CompareAndSwap(nil, a) {
+		return errors.New("access sampling already started")
+	}
+	return nil
+}
+
+// StopAccessSampling выключает наблюдение и забывает накопленное.
+func (s *Store) StopAccessSampling() {
+	s.accessSample.Store(nil)
+}
+
+// sampled возвращает хеш ключа, если ключ под наблюдением.
+func (a *accessSampler) sampled(key []byte) (uint64, bool) {
+	h := maphash.Bytes(a.seed, key)
+	return h, h <= a.threshold
+}
+
+func (s *Store) noteRead(key []byte) {
+	a := s.accessSample.Load()
+	if a == nil {
+		return
+	}
+	if h, ok := a.sampled(key); ok {
+		now := s.clock.Now().UnixNano()
+		a.mu.Lock()
+		a.lastRead[h] = now
+		if a.reads[h] < math.MaxUint32 {
+			a.reads[h]++
+		}
+		a.mu.Unlock()
+	}
+}
+
+func (s *Store) noteWrite(key []byte) {
+	a := s.accessSample.Load()
+	if a == nil {
+		return
+	}
+	if h, ok := a.sampled(key); ok {
+		now := s.clock.Now().UnixNano()
+		a.mu.Lock()
+		a.lastWrite[h] = now
+		a.mu.Unlock()
+	}
+}
+
+// DeadKeyOptions — параметры DeadKeys.
+type DeadKeyOptions struct {
+	// Window — ключ «мёртвый», если не читался за последние Window и не записывался за них же.
+	Window time.Duration
+	// Within — смотреть только ключи под этим префиксом; nil — все (кроме служебных '!').
+	Within []byte
+	// MaxKeys — сколько мёртвых ключей из выборки перечислить в отчёте, по умолчанию 100.
+	MaxKeys int
+}
+
+// DeadKeyPrefix — мёртвые ключи префикса.
+type DeadKeyPrefix struct {
+	Prefix string
+	// Sampled — живых ключей префикса в выборке; Dead — из них мёртвых, DeadBytes — их объём,
+	// DeadWithTTL — из мёртвых с TTL (остальные без TTL не уйдут сами).
+	Sampled     int64
+	Dead        int64
+	DeadBytes   int64
+	DeadWithTTL int64
+	// EstimatedDead и EstimatedWastedBytes — оценка по всему префиксу (выборка / SampleRate).
+	EstimatedDead        int64
+	EstimatedWastedBytes int64
+}
+
+// DeadKey — мёртвый ключ из выборки.
+type DeadKey struct {
+	Key   []byte
+	Bytes int64
+	// LastRead — последнее замеченное чтение; нулевое — не читался с начала наблюдения.
+	LastRead  time.Time
+	ExpiresAt time.Time
+}
+
+// DeadKeyReport — результат DeadKeys.
+type DeadKeyReport struct {
+	At         time.Time
+	Since      time.Time
+	Window     time.Duration
+	SampleRate float64
+	// Complete — наблюдение идёт дольше Window; иначе «мёртвые» — лишь не читавшиеся с его начала.
+	Complete bool
+	// Prefixes — по убыванию EstimatedWastedBytes.
+	Prefixes []DeadKeyPrefix
+	Keys     []DeadKey
+}
+
+// DeadKeys проходит по ключам (без чтения значений) и сообщает, какие из ключей выборки
+// StartAccessSampling не читались и не записывались за Window, с оценкой впустую занятого места
+// по префиксам. Помогает решить, куда ввести TTL и что удалить.
+func (s *Store) DeadKeys(ctx context.Context, opts DeadKeyOptions) (DeadKeyReport, error) {
+	a := s.accessSample.Load()
+	if a == nil {
+		return DeadKeyReport{}, ErrAccessSamplingOff
+	}
+	if opts.Window <= 0 {
+		return DeadKeyReport{}, errors.New("dead key window must be positive")
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 100
+	}
+	now := s.clock.Now()
+	rep := DeadKeyReport{
+		At:         now,
+		Since:      a.started,
+		Window:     opts.Window,
+		SampleRate: a.rate,
+		Complete:   now.Sub(a.started) >= opts.Window,
+	}
+	cutoff := now.Add(-opts.Window).UnixNano()
+
+	type candidate struct {
+		key  []byte
+		hash uint64
+		size int64
+		exp  uint64
+	}
+	// ключи собираются без блокировки выборки, сверка с ней — одним захватом на пачку
+	var batch []candidate
+	groups := make(map[string]*DeadKeyPrefix)
+	check := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for _, c := range batch {
+			p := a.prefix(c.key)
+			g := groups[p]
+			if g == nil {
+				g = &DeadKeyPrefix{Prefix: p}
+				groups[p] = g
+			}
+			g.Sampled++
+			read, write := a.lastRead[c.hash], a.lastWrite[c.hash]
+			if read >= cutoff || write >= cutoff {
+				continue
+			}
+			g.Dead++
+			g.DeadBytes += c.size
+			if c.exp > 0 {
+				g.DeadWithTTL++
+			}
+			if len(rep.Keys) < opts.MaxKeys {
+				dk := DeadKey{Key: c.key, Bytes: c.size}
+				if read > 0 {
+					dk.LastRead = time.Unix(0, read)
+				}
+				if c.exp > 0 {
+					dk.ExpiresAt = time.Unix(int64(c.exp), 0)
+				}
+				rep.Keys = append(rep.Keys, dk)
+			}
+		}
+		batch = batch[:0]
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		io := badger.DefaultIteratorOptions
+		io.PrefetchValues = false
+		io.Prefix = opts.Within
+		it := txn.NewIterator(io)
+		defer it.Close()
+		n := 0
+		for it.Rewind(); it.ValidForPrefix(opts.Within); it.Next() {
+			if n++; n%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			key := item.Key()
+			if key[0] == '!' || s.expired(item) {
+				continue
+			}
+			h, ok := a.sampled(key)
+			if !ok {
+				continue
+			}
+			batch = append(batch, candidate{key: item.KeyCopy(nil), hash: h, size: item.EstimatedSize(), exp: item.ExpiresAt()})
+			if len(batch) == 1024 {
+				check()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	check()
+	for _, g := range groups {
+		g.EstimatedDead = int64(float64(g.Dead) / a.rate)
+		g.EstimatedWastedBytes = int64(float64(g.DeadBytes) / a.rate)
+		rep.Prefixes = append(rep.Prefixes, *g)
+	}
+	sort.Slice(rep.Prefixes, func(i, j int) bool {
+		if rep.Prefixes[i].EstimatedWastedBytes != rep.Prefixes[j].EstimatedWastedBytes {
+			return rep.Prefixes[i].EstimatedWastedBytes > rep.Prefixes[j].EstimatedWastedBytes
+		}
+		return rep.Prefixes[i].Prefix < rep.Prefixes[j].Prefix
+	})
+	return rep, nil
+}
@@ -0,0 +1,143 @@
+// Package admin — HTTP API администрирования Store.
+//
+//	GET|POST /query?q=SELECT ...   — read-only запрос sdk/query, ответ — query.Result в JSON
+//	GET  /api/prefixes?prefix=p    — ключи под p, сгруппированные по следующему сегменту до ':'
+//	GET  /api/keys?prefix=p&after=k&limit=n — страница ключей с размером, TTL и версией
+//	GET  /api/value?key=k[&type=T] — метаданные и значение, декодированное кодеком Store
+//	                                 (proto-сообщение T, из Options.Types или Options.ProtoTypes — через protojson)
+//	GET  /api/types                — имена proto-сообщений Options.Types
+//	POST /api/backup               — полный бэкап в Options.BackupDir (если задан)
+//	GET  /api/jobs                 — выполняющиеся и недавно завершённые долгие операции (Store.Jobs)
+//	POST /api/jobs/cancel?id=n     — отмена операции n (404, если она не выполняется)
+//	GET  /api/heatmap?prefix=p&window=1h — тепловая карта доступа (Store.AccessHeatmap); 409, если
+//	                                 наблюдение за доступом не включено
+//	GET  /ui/                      — встроенный браузер данных поверх /api (если Options.UI)
+//
+// Ключи в параметрах передаются строкой (prefix, after, key) или base64 (prefix_b64, after_b64, key_b64).
+//
+// Ошибки возвращаются как {"error": "..."}: 400 — ошибка разбора запроса, 401 — неверный токен,
+// 403 — sdk.ErrAccessDenied, 503 — запрос отменён, 500 — остальное.
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/PavelAgarkov/memory-storage/sdk/query"
+)
+
+type Options struct {
+	// Token — если задан, запросы должны передавать его в заголовке "Authorization: Bearer <token>".
+	Token string
+	// Principal — от чьего имени выполняются запросы (sdk.WithPrincipal) для AccessController.
+	Principal string
+	// Query — параметры выполнения запросов /query.
+	Query query.Options
+	// UI — отдавать встроенный браузер данных на /ui/. Сама страница доступна без токена и
+	// запрашивает его у пользователя; данные она берёт из /api с токеном.
+	UI bool
+	// ProtoTypes — префикс ключа → полное имя proto-сообщения (из protoregistry.GlobalTypes) для
+	// декодирования значений в /api/value.
+	ProtoTypes map[string]string
+	// Types — реестр proto-типов (например, из файла дескрипторов): в нём ищутся имена type= и
+	// ProtoTypes, а его назначения префиксов действуют раньше ProtoTypes. nil — только GlobalTypes.
+	Types *sdk.ProtoTypes
+	// BrowseMaxKeys — сколько ключей /api/prefixes просматривает за запрос, по умолчанию 100000.
+	BrowseMaxKeys int
+	// BackupDir — каталог для /api/backup; пустой — эндпоинт выключен.
+	BackupDir string
+}
+
+// Handler — http.Handler админ-API поверх store.
+type Handler struct {
+	store *sdk.Store
+	opts  Options
+	mux   *http.ServeMux
+}
+
+func NewHandler(store *sdk.Store, opts Options) *Handler {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	h := &Handler{store: store, opts: opts, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/query", h.query)
+	h.mux.HandleFunc("/api/prefixes", getOnly(h.prefixes))
+	h.mux.HandleFunc("/api/keys", getOnly(h.keys))
+	h.mux.HandleFunc("/api/value", getOnly(h.value))
+	h.mux.HandleFunc("/api/types", getOnly(h.types))
+	h.mux.HandleFunc("/api/jobs", getOnly(h.jobs))
+	h.mux.HandleFunc("/api/jobs/cancel", h.cancelJob)
+	h.mux.HandleFunc("/api/heatmap", getOnly(h.heatmap))
+	if opts.BackupDir != "" {
+		h.mux.HandleFunc("/api/backup", h.backup)
+	}
+	if opts.UI {
+		h.mux.Handle("/ui/", h.uiHandler())
+	}
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.opts.Token != "" && !(h.opts.UI && strings.HasPrefix(r.URL.Path, "/ui/")) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(h.opts.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid token"))
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+func (h *Handler) ctx(r *http.Request) context.Context {
+	ctx := r.Context()
+	if h.opts.Principal != "" {
+		ctx = sdk.WithPrincipal(ctx, h.opts.Principal)
+	}
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		ctx = sdk.WithRequestID(ctx, id)
+	}
+	return ctx
+}
+
+func (h *Handler) query(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	q, err := query.Parse(r.FormValue("q"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	res, err := q.Exec(h.ctx(r), h.store, h.opts.Query)
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, sdk.ErrAccessDenied):
+		return http.StatusForbidden
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,352 @@
+package admin
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+const (
+	defaultKeysPage = 100
+	maxKeysPage     = 1000
+	// defaultBrowseMaxKeys — предел ключей, которые /api/prefixes просматривает за один запрос.
+	defaultBrowseMaxKeys = 100_000
+)
+
+// keyJSON — ключ в ответах API: строкой для чтения и base64 для бинарных ключей.
+type keyJSON struct {
+	Key    string `json:"key"`
+	KeyB64 string `json:"key_b64"`
+	Binary bool   `json:"binary,omitempty"`
+}
+
+func newKeyJSON(k []byte) keyJSON {
+	return keyJSON{Key: string(k), KeyB64: base64.StdEncoding.EncodeToString(k), Binary: !utf8.Valid(k)}
+}
+
+type keyInfoJSON struct {
+	keyJSON
+	ValueSize int64      `json:"value_size"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	TTL       string     `json:"ttl,omitempty"`
+	Version   uint64     `json:"version"`
+	UserMeta  byte       `json:"user_meta"`
+}
+
+func (h *Handler) newKeyInfoJSON(info sdk.KeyInfo) keyInfoJSON {
+	out := keyInfoJSON{keyJSON: newKeyJSON(info.Key), ValueSize: info.ValueSize, Version: info.Version, UserMeta: info.UserMeta}
+	if !info.ExpiresAt.IsZero() {
+		out.ExpiresAt = &info.ExpiresAt
+		out.TTL = info.ExpiresAt.Sub(h.store.Clock().Now()).Truncate(time.Second).String()
+	}
+	return out
+}
+
+func getOnly(fn http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			return
+		}
+		fn(w, r)
+	}
+}
+
+// keyParam читает ключ из name (строка) или name_b64 (base64).
+func keyParam(r *http.Request, name string) ([]byte, error) {
+	if v := r.FormValue(name + "_b64"); v != "" {
+		return base64.StdEncoding.DecodeString(v)
+	}
+	return []byte(r.FormValue(name)), nil
+}
+
+// prefixes группирует ключи под prefix по следующему сегменту до ':'.
+func (h *Handler) prefixes(w http.ResponseWriter, r *http.Request) {
+	within, err := keyParam(r, "prefix")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	maxKeys := h.opts.BrowseMaxKeys
+	if maxKeys <= 0 {
+		maxKeys = defaultBrowseMaxKeys
+	}
+	rep, err := h.store.PrefixReport(h.ctx(r), sdk.PrefixReportOptions{
+		Within:  within,
+		MaxKeys: maxKeys,
+		Prefix: func(key []byte) string {
+			rest := key[len(within):]
+			if i := bytes.IndexByte(rest, ':'); i >= 0 {
+				return string(key[:len(within)+i+1])
+			}
+			return string(key)
+		},
+	})
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	type group struct {
+		keyJSON
+		Keys    int64 `json:"keys"`
+		Bytes   int64 `json:"bytes"`
+		WithTTL int64 `json:"with_ttl"`
+		Leaf    bool  `json:"leaf"`
+	}
+	groups := make([]group, 0, len(rep.Prefixes))
+	for _, p := range rep.Prefixes {
+		groups = append(groups, group{
+			keyJSON: newKeyJSON([]byte(p.Prefix)),
+			Keys:    p.Keys, Bytes: p.Bytes, WithTTL: p.WithTTL,
+			Leaf: !strings.HasSuffix(p.Prefix, ":") || p.Prefix == string(within),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"prefix":    newKeyJSON(within),
+		"keys":      rep.Keys,
+		"truncated": rep.Truncated,
+		"groups":    groups,
+	})
+}
+
+// keys — страница ключей prefix после after.
+func (h *Handler) keys(w http.ResponseWriter, r *http.Request) {
+	prefix, err := keyParam(r, "prefix")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	after, err := keyParam(r, "after")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := defaultKeysPage
+	if v := r.FormValue("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("bad limit %q", v))
+			return
+		}
+		limit = min(limit, maxKeysPage)
+	}
+	if len(after) == 0 {
+		after = nil
+	}
+	list := make([]keyInfoJSON, 0, limit)
+	err = h.store.ScanKeys(h.ctx(r), prefix, after, limit+1, func(info sdk.KeyInfo) error {
+		list = append(list, h.newKeyInfoJSON(info))
+		return nil
+	})
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	resp := map[string]any{"keys": list}
+	if len(list) > limit {
+		resp["keys"] = list[:limit]
+		resp["next"] = list[limit-1].keyJSON
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// value — метаданные и значение ключа, декодированное кодеком Store; proto — через protojson.
+func (h *Handler) value(w http.ResponseWriter, r *http.Request) {
+	key, err := keyParam(r, "key")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	info, raw, err := h.store.Inspect(h.ctx(r), key)
+	if errors.Is(err, sdk.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	resp := map[string]any{
+		"info":    h.newKeyInfoJSON(info),
+		"raw_b64": base64.StdEncoding.EncodeToString(raw),
+	}
+	if utf8.Valid(raw) {
+		resp["text"] = string(raw)
+	}
+	decoded, typ, err := h.decode(key, raw, r.FormValue("type"))
+	if typ != "" {
+		resp["type"] = typ
+	}
+	if err != nil {
+		resp["decode_error"] = err.Error()
+	} else {
+		resp["decoded"] = decoded
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// decode декодирует значение: proto-сообщением typeName (или типом из Options.Types и
+// Options.ProtoTypes по самому длинному префиксу), иначе — кодеком Store в произвольную структуру.
+func (h *Handler) decode(key, raw []byte, typeName string) (any, string, error) {
+	if typeName == "" && h.opts.Types != nil {
+		typeName = string(h.opts.Types.TypeFor(key))
+	}
+	if typeName == "" {
+		best := -1
+		for prefix, name := range h.opts.ProtoTypes {
+			if bytes.HasPrefix(key, []byte(prefix)) && len(prefix) > best {
+				best, typeName = len(prefix), name
+			}
+		}
+	}
+	if typeName != "" {
+		mt, err := h.resolver().FindMessageByName(protoreflect.FullName(typeName))
+		if err != nil {
+			return nil, typeName, fmt.Errorf("proto type %s: %w", typeName, err)
+		}
+		msg := mt.New().Interface()
+		if err := h.store.DecodeValue(key, raw, msg); err != nil {
+			return nil, typeName, err
+		}
+		return protoJSON{msg, h.resolver()}, typeName, nil
+	}
+	var v any
+	if err := h.store.DecodeValue(key, raw, &v); err != nil {
+		return nil, "", err
+	}
+	return v, "", nil
+}
+
+// protoResolver — типы для поиска сообщений и разворачивания google.protobuf.Any.
+type protoResolver interface {
+	protoregistry.MessageTypeResolver
+	protoregistry.ExtensionTypeResolver
+}
+
+func (h *Handler) resolver() protoResolver {
+	if h.opts.Types != nil {
+		return h.opts.Types
+	}
+	return protoregistry.GlobalTypes
+}
+
+// types отдаёт имена сообщений Options.Types — варианты параметра type для /api/value.
+func (h *Handler) types(w http.ResponseWriter, r *http.Request) {
+	names := []protoreflect.FullName{}
+	if h.opts.Types != nil {
+		names = h.opts.Types.Names()
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"types": names})
+}
+
+// protoJSON встраивает protojson-представление сообщения в JSON-ответ.
+type protoJSON struct {
+	m        proto.Message
+	resolver protoResolver
+}
+
+func (p protoJSON) MarshalJSON() ([]byte, error) {
+	return protojson.MarshalOptions{EmitUnpopulated: true, Resolver: p.resolver}.Marshal(p.m)
+}
+
+// backup делает полный бэкап в Options.BackupDir; операция пишется в журнал аудита.
+func (h *Handler) backup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	if err := os.MkdirAll(h.opts.BackupDir, 0o755); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx := h.ctx(r)
+	path := filepath.Join(h.opts.BackupDir, "admin-"+h.store.Clock().Now().UTC().Format("2006-01-02T15-04-05")+".bak.gz")
+	var last uint64
+	err := h.store.RunAudited(ctx, "backup", path, func() error {
+		var err error
+		last, err = h.store.FullBackupToFile(ctx, path)
+		return err
+	})
+	if err != nil {
+		writeError(w, statusOf(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"path": path, "last_version": last})
+}
+
+func (h *Handler) jobs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": h.store.Jobs()})
+}
+
+func (h *Handler) cancelJob(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		return
+	}
+	id, err := strconv.ParseUint(r.FormValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("id: %w", err))
+		return
+	}
+	err = h.store.RunAudited(h.ctx(r), "cancel_job", strconv.FormatUint(id, 10), func() error {
+		return h.store.CancelJob(id)
+	})
+	switch {
+	case errors.Is(err, sdk.ErrJobNotFound):
+		writeError(w, http.StatusNotFound, err)
+	case err != nil:
+		writeError(w, statusOf(err), err)
+	default:
+		writeJSON(w, http.StatusOK, map[string]any{"canceled": id})
+	}
+}
+
+func (h *Handler) heatmap(w http.ResponseWriter, r *http.Request) {
+	within, err := keyParam(r, "prefix")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	opts := sdk.HeatmapOptions{Within: within}
+	if v := r.FormValue("window"); v != "" {
+		if opts.WorkingSetWindow, err = time.ParseDuration(v); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("window: %w", err))
+			return
+		}
+	}
+	hm, err := h.store.AccessHeatmap(h.ctx(r), opts)
+	switch {
+	case errors.Is(err, sdk.ErrAccessSamplingOff):
+		writeError(w, http.StatusConflict, err)
+	case err != nil:
+		writeError(w, statusOf(err), err)
+	default:
+		writeJSON(w, http.StatusOK, hm)
+	}
+}
+
+func (h *Handler) uiHandler() http.Handler {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/ui/", http.FileServer(http.FS(sub)))
+}
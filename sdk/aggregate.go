@@ -0,0 +1,218 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/dgraph-io/ristretto/v2/z"
+)
+
+type AggKind uint8
+
+const (
+	AggCount AggKind = iota + 1
+	AggSum
+	AggMin
+	AggMax
+	AggAvg
+)
+
+func (k AggKind) String() string {
+	switch k {
+	case AggCount:
+		return "count"
+	case AggSum:
+		return "sum"
+	case AggMin:
+		return "min"
+	case AggMax:
+		return "max"
+	case AggAvg:
+		return "avg"
+	default:
+		return "unknown"
+	}
+}
+
+// AggExtractor достаёт числовое поле из записи; false — запись не участвует в агрегате.
+type AggExtractor func(kv KV) (float64, bool)
+
+// AggResult — результат агрегации. Count — число учтённых записей;
+// для Min/Max/Avg при Count == 0 значение Value равно NaN.
+type AggResult struct {
+	Kind  AggKind
+	Value float64
+	Count uint64
+}
+
+type aggState struct {
+	count    uint64
+	sum      float64
+	min, max float64
+}
+
+func newAggState() aggState {
+	return aggState{min: math.Inf(1), max: math.Inf(-1)}
+}
+
+func (a *aggState) add(v float64) {
+	a.count++
+	a.sum += v
+	a.min = math.Min(a.min, v)
+	a.max = math.Max(a.max, v)
+}
+
+func (a *aggState) merge(b aggState) {
+	a.count += b.count
+	a.sum += b.sum
+	a.min = math.Min(a.min, b.min)
+	a.max = math.Max(a.max, b.max)
+}
+
+func (a aggState) result(kind AggKind) AggResult {
+	res := AggResult{Kind: kind, Count: a.count}
+	switch kind {
+	case AggCount:
+		res.Value = float64(a.count)
+	case AggSum:
+		res.Value = a.sum
+	case AggMin, AggMax, AggAvg:
+		if a.count == 0 {
+			res.Value = math.NaN()
+			break
+		}
+		switch kind {
+		case AggMin:
+			res.Value = a.min
+		case AggMax:
+			res.Value = a.max
+		default:
+			res.Value = a.sum / float64(a.count)
+		}
+	}
+	return res
+}
+
+func validateAgg(extractor AggExtractor, agg AggKind) error {
+	if extractor == nil {
+		return fmt.Errorf("nil aggregate extractor")
+	}
+	if agg < AggCount || agg > AggAvg {
+		return fmt.Errorf("unknown aggregate kind %d", agg)
+	}
+	return nil
+}
+
+// Aggregate считает агрегат по записям префикса в одной транзакции чтения.
+func (s *Store) Aggregate(ctx context.Context, prefix []byte, extractor AggExtractor, agg AggKind) (AggResult, error) {
+	if err := validateAgg(extractor, agg); err != nil {
+		return AggResult{}, err
+	}
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return AggResult{}, err
+	}
+	state := newAggState()
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			if err := item.Value(func(val []byte) error {
+				if v, ok := extractor(KV{Key: item.Key(), Value: val}); ok {
+					state.add(v)
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return AggResult{}, err
+	}
+	return state.result(agg), nil
+}
+
+// AggregateStream — параллельный Aggregate поверх badger.Stream.
+// extractor вызывается конкурентно из numGo горутин и не должен сохранять KV: срезы валидны только внутри вызова.
+// numGo <= 0 — значение Stream по умолчанию.
+func (s *Store) AggregateStream(ctx context.Context, prefix []byte, extractor AggExtractor, agg AggKind, numGo int) (AggResult, error) {
+	if err := validateAgg(extractor, agg); err != nil {
+		return AggResult{}, err
+	}
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return AggResult{}, err
+	}
+
+	stream := s.db.NewStream()
+	stream.Prefix = prefix
+	stream.LogPrefix = "Store.AggregateStream"
+	stream.ChooseKey = func(item *badger.Item) bool { return !hiddenSystemKey(prefix, item.Key()) }
+	if numGo > 0 {
+		stream.NumGo = numGo
+	}
+
+	// у каждой горутины Stream свой частичный агрегат, сливаем их в конце
+	partials := make([]aggState, stream.NumGo)
+	for i := range partials {
+		partials[i] = newAggState()
+	}
+	var readErr error
+	var errOnce sync.Once
+	stream.UseKeyToListWithThreadId = true
+	stream.KeyToListWithThreadId = func(key []byte, itr *badger.Iterator, threadId int) (*pb.KVList, error) {
+		item := itr.Item()
+		if item.IsDeletedOrExpired() || s.expired(item) {
+			return nil, nil
+		}
+		if err := s.scanLimit.wait(ctx); err != nil {
+			return nil, nil // отмена ctx остановит Orchestrate
+		}
+		err := item.Value(func(val []byte) error {
+			if v, ok := extractor(KV{Key: key, Value: val}); ok {
+				partials[threadId].add(v)
+			}
+			return nil
+		})
+		if err != nil {
+			// Stream только логирует ошибки KeyToList, поэтому запоминаем первую сами
+			errOnce.Do(func() { readErr = err })
+		}
+		return nil, err
+	}
+	stream.FinishThread = func(threadId int) (*pb.KVList, error) {
+		return &pb.KVList{}, nil
+	}
+	stream.Send = func(buf *z.Buffer) error {
+		return nil
+	}
+
+	if err := stream.Orchestrate(ctx); err != nil {
+		return AggResult{}, err
+	}
+	if readErr != nil {
+		return AggResult{}, readErr
+	}
+	total := newAggState()
+	for _, p := range partials {
+		total.merge(p)
+	}
+	return total.result(agg), nil
+}
@@ -0,0 +1,311 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Anti-entropy: два Store сравнивают деревья дайджестов префикса и передают друг другу только
+// записи из различающихся листьев; конфликт одного ключа решается по last-write-wins, а для ключей
+// с функцией слияния (RegisterSyncMerge, например CRDT) — слиянием значений.
+// Транспорт не фиксирован: удалённая сторона — любой SyncPeer (для Store в том же процессе — NewSyncPeer).
+// Удаления не распространяются: ключ, удалённый на одной стороне, вернётся с другой.
+
+// SyncEntry — запись, передаваемая при синхронизации.
+type SyncEntry struct {
+	Key       []byte
+	Value     []byte
+	ExpiresAt uint64
+	// Timestamp — метка last-write-wins (SyncOptions.Timestamp на стороне-источнике).
+	Timestamp uint64
+}
+
+// SyncPeer — удалённая сторона синхронизации.
+type SyncPeer interface {
+	// Digest возвращает дерево дайджестов префикса.
+	Digest(ctx context.Context, prefix []byte, fanout int) (*DigestTree, error)
+	// Entries возвращает записи префикса, попадающие в листья leaves дерева с заданным fanout.
+	Entries(ctx context.Context, prefix []byte, fanout int, leaves []int) ([]SyncEntry, error)
+	// Apply применяет записи, пропуская те, у которых локальная копия новее по Timestamp;
+	// записи с функцией слияния сливаются с локальной копией.
+	Apply(ctx context.Context, entries []SyncEntry) error
+}
+
+type SyncOptions struct {
+	// Fanout — ветвление дерева дайджестов, по умолчанию 16. Должен совпадать у обеих сторон.
+	Fanout int
+	// Timestamp — метка для last-write-wins. Обе стороны должны использовать одну функцию.
+	// По умолчанию — версия Badger, которая сравнима только в пределах одного экземпляра,
+	// поэтому для реальных конфликтов метку стоит хранить в значении и доставать отсюда.
+	Timestamp func(kv KV, version uint64) uint64
+}
+
+func (o *SyncOptions) normalize() {
+	if o.Fanout <= 0 {
+		o.Fanout = 16
+	}
+	if o.Timestamp == nil {
+		o.Timestamp = func(_ KV, version uint64) uint64 { return version }
+	}
+}
+
+// SyncStats — итог одного раунда синхронизации.
+type SyncStats struct {
+	// DiffLeaves — число различавшихся листьев дерева дайджестов.
+	DiffLeaves int
+	// Pulled — записей получено от пира, Pushed — отправлено пиру.
+	Pulled, Pushed int
+}
+
+type storeSyncPeer struct {
+	store *Store
+	opts  SyncOptions
+}
+
+// NewSyncPeer — SyncPeer поверх локального Store; его же удобно оборачивать в серверную часть транспорта.
+func NewSyncPeer(store *Store, opts SyncOptions) SyncPeer {
+	opts.normalize()
+	return &storeSyncPeer{store: store, opts: opts}
+}
+
+func (p *storeSyncPeer) Digest(ctx context.Context, prefix []byte, fanout int) (*DigestTree, error) {
+	return p.store.PrefixDigest(ctx, prefix, fanout)
+}
+
+func (p *storeSyncPeer) Entries(ctx context.Context, prefix []byte, fanout int, leaves []int) ([]SyncEntry, error) {
+	return p.store.syncEntries(ctx, prefix, fanout, leaves, p.opts.Timestamp)
+}
+
+func (p *storeSyncPeer) Apply(ctx context.Context, entries []SyncEntry) error {
+	return p.store.applySyncEntries(ctx, entries, p.opts.Timestamp)
+}
+
+func (s *Store) syncEntries(ctx context.Context, prefix []byte, fanout int, leaves []int, ts func(KV, uint64) uint64) ([]SyncEntry, error) {
+	t, err := newDigestTree(fanout)
+	if err != nil {
+		return nil, err
+	}
+	want := make(map[int]struct{}, len(leaves))
+	for _, l := range leaves {
+		want[l] = struct{}{}
+	}
+	var out []SyncEntry
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if _, ok := want[t.LeafOf(item.Key())]; !ok {
+				continue
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			key := item.KeyCopy(nil)
+			out = append(out, SyncEntry{
+				Key:       key,
+				Value:     value,
+				ExpiresAt: item.ExpiresAt(),
+				Timestamp: ts(KV{Key: key, Value: value}, item.Version()),
+			})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// applySyncEntries записывает entries, если локальной копии нет или она не новее; ключи с функцией
+// слияния записываются слитыми с локальной копией.
+func (s *Store) applySyncEntries(ctx context.Context, entries []SyncEntry, ts func(KV, uint64) uint64) error {
+	for len(entries) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		applied := 0
+		err := s.db.Update(func(txn *badger.Txn) error {
+			for _, e := range entries {
+				item, err := txn.Get(e.Key)
+				switch {
+				case err == nil && !s.expired(item):
+					value, err := item.ValueCopy(nil)
+					if err != nil {
+						return err
+					}
+					if merge := s.syncMergeFor(e.Key); merge != nil {
+						merged, err := merge(e.Key, value, e.Value)
+						if err != nil {
+							return err
+						}
+						if bytes.Equal(merged, value) {
+							applied++
+							continue
+						}
+						e.Value = merged
+						e.ExpiresAt = laterExpiry(e.ExpiresAt, item.ExpiresAt())
+						break
+					}
+					if ts(KV{Key: e.Key, Value: value}, item.Version()) > e.Timestamp {
+						applied++
+						continue
+					}
+				case err != nil && !errors.Is(err, badger.ErrKeyNotFound):
+					return err
+				}
+				entry := badger.NewEntry(e.Key, e.Value)
+				entry.ExpiresAt = e.ExpiresAt
+				if err := txn.SetEntry(entry); err != nil {
+					if errors.Is(err, badger.ErrTxnTooBig) && applied > 0 {
+						return nil // остаток — следующей транзакцией
+					}
+					return err
+				}
+				applied++
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		entries = entries[applied:]
+	}
+	return nil
+}
+
+// SyncWith выполняет один раунд anti-entropy префикса с peer в обе стороны. Раунд виден в Jobs.
+func (s *Store) SyncWith(ctx context.Context, peer SyncPeer, prefix []byte, opts SyncOptions) (SyncStats, error) {
+	ctx, job := s.StartJob(ctx, JobSync, string(prefix))
+	stats, err := s.syncWith(ctx, peer, prefix, opts, job)
+	job.Finish(err)
+	return stats, err
+}
+
+func (s *Store) syncWith(ctx context.Context, peer SyncPeer, prefix []byte, opts SyncOptions, job *Job) (SyncStats, error) {
+	opts.normalize()
+	var stats SyncStats
+
+	local, err := s.PrefixDigest(ctx, prefix, opts.Fanout)
+	if err != nil {
+		return stats, err
+	}
+	remote, err := peer.Digest(ctx, prefix, opts.Fanout)
+	if err != nil {
+		return stats, err
+	}
+	leaves, err := local.DiffLeaves(remote)
+	if err != nil {
+		return stats, err
+	}
+	stats.DiffLeaves = len(leaves)
+	job.SetProgress(0, int64(len(leaves)))
+	if len(leaves) == 0 {
+		return stats, nil
+	}
+
+	job.SetStage("entries")
+	theirs, err := peer.Entries(ctx, prefix, opts.Fanout, leaves)
+	if err != nil {
+		return stats, err
+	}
+	ours, err := s.syncEntries(ctx, prefix, opts.Fanout, leaves, opts.Timestamp)
+	if err != nil {
+		return stats, err
+	}
+
+	job.SetStage("apply")
+	pull, push := diffSyncEntries(ours, theirs, func(key []byte) bool { return s.syncMergeFor(key) != nil })
+	if err := s.applySyncEntries(ctx, pull, opts.Timestamp); err != nil {
+		return stats, err
+	}
+	stats.Pulled = len(pull)
+	if err := peer.Apply(ctx, push); err != nil {
+		return stats, err
+	}
+	stats.Pushed = len(push)
+	return stats, nil
+}
+
+// diffSyncEntries раскладывает различия: pull — взять у пира, push — отдать пиру. Различающиеся
+// ключи, для которых merged — true, идут в обе стороны: каждая сторона сливает их у себя.
+func diffSyncEntries(ours, theirs []SyncEntry, merged func(key []byte) bool) (pull, push []SyncEntry) {
+	byKey := func(e []SyncEntry) {
+		sort.Slice(e, func(i, j int) bool { return bytes.Compare(e[i].Key, e[j].Key) < 0 })
+	}
+	byKey(ours)
+	byKey(theirs)
+	i, j := 0, 0
+	for i < len(ours) || j < len(theirs) {
+		var c int
+		switch {
+		case i == len(ours):
+			c = 1
+		case j == len(theirs):
+			c = -1
+		default:
+			c = bytes.Compare(ours[i].Key, theirs[j].Key)
+		}
+		switch {
+		case c < 0:
+			push = append(push, ours[i])
+			i++
+		case c > 0:
+			pull = append(pull, theirs[j])
+			j++
+		default:
+			o, t := ours[i], theirs[j]
+			if !bytes.Equal(o.Value, t.Value) || o.ExpiresAt != t.ExpiresAt {
+				if merged(o.Key) {
+					pull = append(pull, t)
+					push = append(push, o)
+				} else if t.Timestamp > o.Timestamp {
+					pull = append(pull, t)
+				} else {
+					push = append(push, o)
+				}
+			}
+			i++
+			j++
+		}
+	}
+	return pull, push
+}
+
+// laterExpiry — более поздний из двух сроков жизни; 0 — бессрочно.
+func laterExpiry(a, b uint64) uint64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// RunAntiEntropy запускает SyncWith каждые interval (по часам Store) до отмены ctx.
+// onRound получает итог каждого раунда и может быть nil; ошибка раунда не останавливает цикл.
+func (s *Store) RunAntiEntropy(ctx context.Context, peer SyncPeer, prefix []byte, interval time.Duration, opts SyncOptions, onRound func(SyncStats, error)) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := s.SyncWith(ctx, peer, prefix, opts)
+		if onRound != nil && ctx.Err() == nil {
+			onRound(stats, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
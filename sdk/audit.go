@@ -0,0 +1,242 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Журнал административных операций: каждая запись —
+//
+//	"!audit:" + время (unix nano, 8 байт big-endian) + порядковый номер (8 байт) → AuditEntry (JSON)
+//
+// Записи только добавляются: API удаления нет, DropAll/DropPrefix журнал сохраняют,
+// а запись об операции добавляется после неё.
+var auditPrefix = []byte("!audit:")
+
+var auditSeq atomic.Uint64
+
+// errStopAudit останавливает scanAudit без ошибки.
+var errStopAudit = errors.New("stop audit scan")
+
+// AuditEntry — запись журнала административных операций.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Actor string    `json:"actor"`
+	// RequestID — WithRequestID контекста операции.
+	RequestID string        `json:"request_id,omitempty"`
+	Op        string        `json:"op"`
+	Target    string        `json:"target,omitempty"`
+	Result    string        `json:"result"` // "ok" или "error"
+	Error     string        `json:"error,omitempty"`
+	Duration  time.Duration `json:"duration"`
+}
+
+type auditActorKey struct{}
+
+// WithAuditActor кладёт в контекст того, кто выполняет операцию (пользователь, сервис, тикет),
+// — он попадёт в поле Actor записей журнала. Без него Actor — principal из WithPrincipal.
+func WithAuditActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, auditActorKey{}, actor)
+}
+
+func (s *Store) auditActor(ctx context.Context) string {
+	if a, ok := ctx.Value(auditActorKey{}).(string); ok && a != "" {
+		return a
+	}
+	if p := PrincipalFrom(ctx); p != "" {
+		return p
+	}
+	if s.defaultActor != "" {
+		return s.defaultActor
+	}
+	return "unknown"
+}
+
+// RunAudited выполняет fn и записывает результат в журнал как операцию op над target.
+// Для собственных административных операций (ротация ключей, миграции данных и т.п.).
+// Возвращает ошибку fn; ошибка записи журнала добавляется к ней.
+func (s *Store) RunAudited(ctx context.Context, op, target string, fn func() error) error {
+	start := s.clock.Now()
+	err := fn()
+	entry := AuditEntry{
+		Time:      start,
+		Actor:     s.auditActor(ctx),
+		RequestID: RequestIDFrom(ctx),
+		Op:        op,
+		Target:    target,
+		Result:    "ok",
+		Duration:  s.clock.Now().Sub(start),
+	}
+	if err != nil {
+		entry.Result = "error"
+		entry.Error = err.Error()
+	}
+	if aerr := s.appendAudit(entry); aerr != nil {
+		if err != nil {
+			return fmt.Errorf("%w (audit: %v)", err, aerr)
+		}
+		return fmt.Errorf("audit: %w", aerr)
+	}
+	return err
+}
+
+func (s *Store) appendAudit(e AuditEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	key := append([]byte(nil), auditPrefix...)
+	key = binary.BigEndian.AppendUint64(key, uint64(e.Time.UnixNano()))
+	key = binary.BigEndian.AppendUint64(key, auditSeq.Add(1))
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, data)
+	})
+}
+
+// ListAuditEntries возвращает до limit (<= 0 — все) записей журнала с временем в [from, to) по возрастанию.
+// Нулевой from — с начала, нулевой to — до конца.
+func (s *Store) ListAuditEntries(from, to time.Time, limit int) ([]AuditEntry, error) {
+	var out []AuditEntry
+	err := s.scanAudit(from, to, func(e AuditEntry) error {
+		out = append(out, e)
+		if limit > 0 && len(out) >= limit {
+			return errStopAudit
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ExportAuditJSONL пишет записи журнала с временем в [from, to) в w, по одной JSON-строке на запись,
+// и возвращает их число.
+func (s *Store) ExportAuditJSONL(w io.Writer, from, to time.Time) (int, error) {
+	enc := json.NewEncoder(w)
+	n := 0
+	err := s.scanAudit(from, to, func(e AuditEntry) error {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func (s *Store) scanAudit(from, to time.Time, fn func(AuditEntry) error) error {
+	seek := append([]byte(nil), auditPrefix...)
+	if !from.IsZero() {
+		seek = binary.BigEndian.AppendUint64(seek, uint64(from.UnixNano()))
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = auditPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(seek); it.ValidForPrefix(auditPrefix); it.Next() {
+			var e AuditEntry
+			if err := it.Item().Value(func(val []byte) error {
+				return json.Unmarshal(val, &e)
+			}); err != nil {
+				return fmt.Errorf("audit entry %x: %w", it.Item().Key(), err)
+			}
+			if !to.IsZero() && !e.Time.Before(to) {
+				return nil
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errStopAudit) {
+		return nil
+	}
+	return err
+}
+
+// DropPrefix удаляет все ключи с указанными префиксами (badger.DB.DropPrefix) и пишет операцию в журнал.
+// На время удаления Badger блокирует запись. Журнал аудита и служебная область "!sys:" сохраняются,
+// даже если префикс их накрывает.
+func (s *Store) DropPrefix(ctx context.Context, prefixes ...[]byte) error {
+	target := fmt.Sprintf("%q", prefixes)
+	return s.RunAudited(ctx, "drop_prefix", target, func() error {
+		return s.withCompactions("drop prefix", func() error {
+			var keep [][]byte
+			for _, pp := range preservedPrefixes {
+				for _, p := range prefixes {
+					if bytes.HasPrefix(pp, p) {
+						keep = append(keep, pp)
+						break
+					}
+				}
+			}
+			if len(keep) > 0 {
+				return s.preserving(keep, func() error { return s.db.DropPrefix(prefixes...) })
+			}
+			return s.db.DropPrefix(prefixes...)
+		})
+	})
+}
+
+// DropAll удаляет все данные (badger.DB.DropAll), кроме журнала аудита и служебной области "!sys:",
+// и пишет операцию в журнал.
+func (s *Store) DropAll(ctx context.Context) error {
+	return s.RunAudited(ctx, "drop_all", "", func() error {
+		return s.withCompactions("drop all", func() error { return s.preserving(preservedPrefixes, s.db.DropAll) })
+	})
+}
+
+// preservedPrefixes — области, которые DropPrefix и DropAll не удаляют.
+var preservedPrefixes = [][]byte{auditPrefix, systemKeyPrefix}
+
+// preserving копирует ключи prefixes в память, выполняет удаление drop и записывает их обратно
+// с прежним сроком жизни.
+func (s *Store) preserving(prefixes [][]byte, drop func() error) error {
+	var saved []*badger.Entry
+	err := s.db.View(func(txn *badger.Txn) error {
+		for _, prefix := range prefixes {
+			opts := badger.DefaultIteratorOptions
+			opts.Prefix = prefix
+			it := txn.NewIterator(opts)
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				item := it.Item()
+				if s.expired(item) {
+					continue
+				}
+				v, err := item.ValueCopy(nil)
+				if err != nil {
+					it.Close()
+					return err
+				}
+				e := badger.NewEntry(item.KeyCopy(nil), v)
+				e.ExpiresAt = item.ExpiresAt()
+				saved = append(saved, e)
+			}
+			it.Close()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if err := drop(); err != nil {
+		return err
+	}
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, e := range saved {
+		if err := wb.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
@@ -16,6 +16,8 @@ import (
 // FullBackupToFile делает полный бэкап в gzip-файл.
 // Возвращает lastTs — версию последней выгруженной записи (нужна для инкрементальных).
 func (s *Store) FullBackupToFile(ctx context.Context, path string) (lastTs uint64, err error) {
+	ctx, job := s.StartJob(ctx, JobBackup, path)
+	defer func() { job.Finish(err) }()
 	f, err := os.Create(path)
 	if err != nil {
 		return 0, fmt.Errorf("create backup file: %w", err)
@@ -34,7 +36,7 @@ func (s *Store) FullBackupToFile(ctx context.Context, path string) (lastTs uint6
 	}()
 
 	stream := s.db.NewStream()
-	lastTs, err = stream.Backup(zw, 0) // 0 = полный бэкап
+	lastTs, err = stream.Backup(jobWriter{ctx, zw, job}, 0) // 0 = полный бэкап
 	if err != nil {
 		return 0, fmt.Errorf("stream backup: %w", err)
 	}
@@ -43,6 +45,8 @@ func (s *Store) FullBackupToFile(ctx context.Context, path string) (lastTs uint6
 
 // IncrementalBackupToFile ...
 func (s *Store) IncrementalBackupToFile(ctx context.Context, path string, sinceTs uint64) (lastTs uint64, err error) {
+	ctx, job := s.StartJob(ctx, JobBackup, path)
+	defer func() { job.Finish(err) }()
 	f, err := os.Create(path)
 	if err != nil {
 		return 0, fmt.Errorf("create incr backup file: %w", err)
@@ -61,7 +65,7 @@ func (s *Store) IncrementalBackupToFile(ctx context.Context, path string, sinceT
 	}()
 
 	stream := s.db.NewStream()
-	lastTs, err = stream.Backup(zw, sinceTs) // вернёт lastTs; для следующего инкрементала передаём lastTs+1
+	lastTs, err = stream.Backup(jobWriter{ctx, zw, job}, sinceTs) // вернёт lastTs; для следующего инкрементала передаём lastTs+1
 	if err != nil {
 		return 0, fmt.Errorf("stream incremental backup: %w", err)
 	}
@@ -70,13 +74,33 @@ func (s *Store) IncrementalBackupToFile(ctx context.Context, path string, sinceT
 
 // RestoreFromReader: загрузка бэкапа в ТЕКУЩУЮ открыту БД.
 // Важно: на время Load не должно быть параллельных транзакций.
+// Операция записывается в журнал аудита и видна в Jobs (прогресс — прочитанные байты).
 func (s *Store) RestoreFromReader(r io.Reader, maxPending int) error {
+	return s.RunAudited(context.Background(), "restore", "reader", func() (err error) {
+		ctx, job := s.StartJob(context.Background(), JobRestore, "reader")
+		defer func() { job.Finish(err) }()
+		return s.restoreFromReader(jobReader{ctx, r, job}, maxPending, job)
+	})
+}
+
+func (s *Store) restoreFromReader(r io.Reader, maxPending int, job *Job) error {
+	return s.withCompactions("restore", func() error { return s.loadAndFlatten(r, maxPending, job) })
+}
+
+// loadAndFlatten загружает бэкап; job (может быть nil) получает этапы "load" и "flatten".
+func (s *Store) loadAndFlatten(r io.Reader, maxPending int, job *Job) error {
 	if maxPending <= 0 {
 		maxPending = 256 // разумное значение для параллельной записи
 	}
+	if job != nil {
+		job.SetStage("load")
+	}
 	if err := s.db.Load(r, maxPending); err != nil {
 		return fmt.Errorf("load backup: %w", err)
 	}
+	if job != nil {
+		job.SetStage("flatten")
+	}
 	// После restore стоит "сплющить" уровни, чтобы версии ключей были вместе.
 	if err := s.db.Flatten(runtime.NumCPU()); err != nil {
 		return fmt.Errorf("flatten after restore: %w", err)
@@ -84,21 +108,38 @@ func (s *Store) RestoreFromReader(r io.Reader, maxPending int) error {
 	return nil
 }
 
-// Утилита восстановления из файла (gzip).
+// Утилита восстановления из файла (gzip). Операция записывается в журнал аудита и видна в Jobs
+// (прогресс — прочитанные байты файла из его размера).
 func (s *Store) RestoreFromFile(path string) error {
+	return s.RunAudited(context.Background(), "restore", path, func() (err error) {
+		ctx, job := s.StartJob(context.Background(), JobRestore, path)
+		defer func() { job.Finish(err) }()
+		return s.restoreFromFile(ctx, path, job)
+	})
+}
+
+// restoreFromFile восстанавливает бэкап path; job (может быть nil) получает прогресс и отменяет чтение.
+func (s *Store) restoreFromFile(ctx context.Context, path string, job *Job) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return fmt.Errorf("open backup file: %w", err)
 	}
 	defer f.Close()
 
-	zr, err := gzip.NewReader(f)
+	var src io.Reader = f
+	if job != nil {
+		if st, err := f.Stat(); err == nil {
+			job.SetProgress(0, st.Size())
+		}
+		src = jobReader{ctx, f, job}
+	}
+	zr, err := gzip.NewReader(src)
 	if err != nil {
 		return fmt.Errorf("open gzip: %w", err)
 	}
 	defer zr.Close()
 
-	return s.RestoreFromReader(zr, 256)
+	return s.restoreFromReader(zr, 256, job)
 }
 
 // RunBackupScheduleWithVersion запускает почасовые инкременталы и ежедневный full,
@@ -121,7 +162,7 @@ func RunBackupScheduleWithVersion(ctx context.Context, store *Store, dir, versio
 		// Имя файла: full-<version>-YYYY-MM-DD.bak.gz
 		path := filepath.Join(
 			dir,
-			fmt.Sprintf("full-%s-%s.bak.gz", version, time.Now().Format("2006-01-02")),
+			fmt.Sprintf("full-%s-%s.bak.gz", version, store.clock.Now().Format("2006-01-02")),
 		)
 		last, err := store.FullBackupToFile(ctx, path)
 		if err != nil {
@@ -137,7 +178,7 @@ func RunBackupScheduleWithVersion(ctx context.Context, store *Store, dir, versio
 		// Имя файла: incr-<version>-YYYY-MM-DD-HH.bak.gz
 		path := filepath.Join(
 			dir,
-			fmt.Sprintf("incr-%s-%s.bak.gz", version, time.Now().Format("2006-01-02-15")),
+			fmt.Sprintf("incr-%s-%s.bak.gz", version, store.clock.Now().Format("2006-01-02-15")),
 		)
 		last, err := store.IncrementalBackupToFile(ctx, path, since)
 		if err != nil {
@@ -156,18 +197,18 @@ func RunBackupScheduleWithVersion(ctx context.Context, store *Store, dir, versio
 	// Один цикл: каждый час — инкрементал, в полночь — full
 	go func() {
 		// Выравниваем «следующий час» и «следующий день»
-		nextHour := time.Now().Truncate(time.Hour).Add(time.Hour)
-		nextDay := time.Now().Truncate(24 * time.Hour).Add(24 * time.Hour)
+		nextHour := store.clock.Now().Truncate(time.Hour).Add(time.Hour)
+		nextDay := store.clock.Now().Truncate(24 * time.Hour).Add(24 * time.Hour)
 
-		timer := time.NewTimer(time.Until(nextHour))
+		timer := store.clock.NewTimer(nextHour.Sub(store.clock.Now()))
 		defer timer.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
 				return
-			case <-timer.C:
-				now := time.Now()
+			case <-timer.C():
+				now := store.clock.Now()
 				// Около полуночи делаем full
 				if now.After(nextDay.Add(-1*time.Minute)) && now.Before(nextDay.Add(1*time.Minute)) {
 					doFull()
@@ -176,7 +217,7 @@ func RunBackupScheduleWithVersion(ctx context.Context, store *Store, dir, versio
 					doIncr()
 				}
 				nextHour = nextHour.Add(time.Hour)
-				timer.Reset(time.Until(nextHour))
+				timer.Reset(nextHour.Sub(store.clock.Now()))
 			}
 		}
 	}()
@@ -0,0 +1,345 @@
+package sdk
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Проверка того, что бэкапы восстанавливаются. Последняя цепочка (полный бэкап и инкременталы
+// после него) загружается во временный Store, и выборка живых ключей сравнивается с ним по
+// версиям и хешам значений. Ключ, изменённый после бэкапа (версия живого ключа новее последней
+// версии в цепочке), не сравнивается; ключ, который был в хранилище к моменту бэкапа, но не
+// восстановился или восстановился с другим значением, — расхождение. Удаления после бэкапа
+// не проверяются: живой Store о них уже ничего не знает.
+
+// ErrNoBackup — в BackupSink нет ни одного полного бэкапа.
+var ErrNoBackup = errors.New("no backup to verify")
+
+// BackupFile — файл цепочки бэкапов (gzip-поток Badger, как у FullBackupToFile).
+type BackupFile struct {
+	Name string
+	Open func() (io.ReadCloser, error)
+}
+
+// BackupSink — хранилище бэкапов, из которого проверка берёт последнюю цепочку.
+type BackupSink interface {
+	// LatestChain возвращает последний полный бэкап и инкременталы после него по порядку.
+	LatestChain(ctx context.Context) ([]BackupFile, error)
+}
+
+// BackupVersionSource — BackupSink, который знает, до какой версии дошли бэкапы. Без этого пропавший
+// последний инкрементал неотличим от старого бэкапа: его ключи выглядят изменёнными после бэкапа.
+type BackupVersionSource interface {
+	LatestVersion(ctx context.Context) (uint64, error)
+}
+
+// DirBackupSink — каталог RunBackupScheduleWithVersion: full-<Version>-*.bak.gz и incr-<Version>-*.bak.gz.
+type DirBackupSink struct {
+	Dir     string
+	Version string
+}
+
+func (d DirBackupSink) LatestChain(ctx context.Context) ([]BackupFile, error) {
+	type file struct {
+		path string
+		mod  time.Time
+	}
+	list := func(kind string) ([]file, error) {
+		paths, err := filepath.Glob(filepath.Join(d.Dir, kind+"-"+d.Version+"-*.bak.gz"))
+		if err != nil {
+			return nil, err
+		}
+		files := make([]file, 0, len(paths))
+		for _, p := range paths {
+			st, err := os.Stat(p)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, file{path: p, mod: st.ModTime()})
+		}
+		sort.Slice(files, func(i, j int) bool { return files[i].mod.Before(files[j].mod) })
+		return files, nil
+	}
+	fulls, err := list("full")
+	if err != nil {
+		return nil, err
+	}
+	if len(fulls) == 0 {
+		return nil, ErrNoBackup
+	}
+	incrs, err := list("incr")
+	if err != nil {
+		return nil, err
+	}
+	full := fulls[len(fulls)-1]
+	chain := []BackupFile{openBackupFile(full.path)}
+	for _, f := range incrs {
+		if !f.mod.Before(full.mod) {
+			chain = append(chain, openBackupFile(f.path))
+		}
+	}
+	return chain, nil
+}
+
+// LatestVersion — до какой версии дошли бэкапы по файлу since RunBackupScheduleWithVersion; 0 — неизвестно.
+func (d DirBackupSink) LatestVersion(context.Context) (uint64, error) {
+	since := loadSince(filepath.Join(d.Dir, fmt.Sprintf("since-%s.txt", d.Version)))
+	if since == 0 {
+		return 0, nil
+	}
+	return since - 1, nil
+}
+
+func openBackupFile(path string) BackupFile {
+	return BackupFile{
+		Name: filepath.Base(path),
+		Open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}
+}
+
+// BackupVerifyReport — итог VerifyBackupRoundTrip.
+type BackupVerifyReport struct {
+	At    time.Time
+	Chain []string
+	// BackupVersion — последняя версия в восстановленной цепочке.
+	BackupVersion uint64
+	// Sampled — ключей в выборке; Compared — из них существовавших к моменту бэкапа; Newer — изменённых после.
+	Sampled  int
+	Compared int
+	Newer    int
+	// Missing — ключи, не найденные в восстановленной цепочке; Mismatched — восстановленные с другой версией
+	// или значением. Списки ограничены первыми 100 ключами, счётчики — полные.
+	Missing         int
+	Mismatched      int
+	MissingKeys     [][]byte
+	MismatchedKeys  [][]byte
+	RestoreDuration time.Duration
+	Duration        time.Duration
+	// Err — почему проверка не прошла: цепочка не восстановилась, неполна или не сошлась с выборкой.
+	Err string
+}
+
+// OK сообщает, что цепочка восстановилась и расхождений нет.
+func (r BackupVerifyReport) OK() bool {
+	return r.Err == "" && r.Missing == 0 && r.Mismatched == 0
+}
+
+const backupVerifyKeysLimit = 100
+
+// BackupVerifyError — цепочка восстановилась, но выборка с ней не сошлась.
+type BackupVerifyError struct {
+	Report BackupVerifyReport
+}
+
+func (e *BackupVerifyError) Error() string {
+	return fmt.Sprintf("backup %s does not match live store: %d missing, %d mismatched of %d compared keys",
+		strings.Join(e.Report.Chain, "+"), e.Report.Missing, e.Report.Mismatched, e.Report.Compared)
+}
+
+type backupSample struct {
+	key     []byte
+	version uint64
+	hash    [sha256.Size]byte
+}
+
+// VerifyBackupRoundTrip восстанавливает последнюю цепочку sink во временный каталог и сравнивает с
+// живым store долю sampleRate (0..1] ключей. Результат пишется в журнал аудита (op "backup_verify")
+// и доступен через LastBackupVerify и метрики. Расхождение возвращается как *BackupVerifyError.
+func VerifyBackupRoundTrip(ctx context.Context, store *Store, sink BackupSink, sampleRate float64) (BackupVerifyReport, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if sampleRate <= 0 || sampleRate > 1 {
+		return BackupVerifyReport{}, fmt.Errorf("sample rate %v out of (0, 1]", sampleRate)
+	}
+	rep := BackupVerifyReport{At: store.clock.Now()}
+	chain, err := sink.LatestChain(ctx)
+	if err != nil {
+		return rep, fmt.Errorf("list backups: %w", err)
+	}
+	for _, f := range chain {
+		rep.Chain = append(rep.Chain, f.Name)
+	}
+	err = store.RunAudited(ctx, "backup_verify", strings.Join(rep.Chain, "+"), func() error {
+		return store.verifyBackup(ctx, sink, chain, sampleRate, &rep)
+	})
+	rep.Duration = store.clock.Now().Sub(rep.At)
+	if err != nil {
+		rep.Err = err.Error()
+	}
+	// прерванная проверка ничего не говорит о бэкапе
+	if ctx.Err() == nil {
+		store.lastBackupVerify.Store(&rep)
+	}
+	return rep, err
+}
+
+// RunBackupVerification вызывает VerifyBackupRoundTrip каждые interval до отмены ctx.
+// onRound получает итог каждого прохода и может быть nil; ошибка прохода не останавливает цикл.
+func RunBackupVerification(ctx context.Context, store *Store, sink BackupSink, sampleRate float64, interval time.Duration, onRound func(BackupVerifyReport, error)) {
+	ticker := store.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rep, err := VerifyBackupRoundTrip(ctx, store, sink, sampleRate)
+		if onRound != nil && ctx.Err() == nil {
+			onRound(rep, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// LastBackupVerify возвращает итог последней завершённой проверки; false — проверок ещё не было.
+func (s *Store) LastBackupVerify() (BackupVerifyReport, bool) {
+	r := s.lastBackupVerify.Load()
+	if r == nil {
+		return BackupVerifyReport{}, false
+	}
+	return *r, true
+}
+
+func (s *Store) verifyBackup(ctx context.Context, sink BackupSink, chain []BackupFile, sampleRate float64, rep *BackupVerifyReport) error {
+	dir, err := os.MkdirTemp("", "backup-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	restored, err := Open(ctx, Options{Dir: dir, ValueDir: dir, LoggingLevel: LogError, Clock: s.clock}, nil)
+	if err != nil {
+		return fmt.Errorf("open scratch store: %w", err)
+	}
+	defer restored.Close()
+
+	start := s.clock.Now()
+	for _, f := range chain {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := restoreBackupFile(restored, f); err != nil {
+			return fmt.Errorf("restore %s: %w", f.Name, err)
+		}
+	}
+	rep.RestoreDuration = s.clock.Now().Sub(start)
+	rep.BackupVersion = restored.db.MaxVersion()
+	if vs, ok := sink.(BackupVersionSource); ok {
+		want, err := vs.LatestVersion(ctx)
+		if err != nil {
+			return fmt.Errorf("latest backup version: %w", err)
+		}
+		if want > rep.BackupVersion {
+			return fmt.Errorf("backup chain is incomplete: restored up to version %d, backups reached %d", rep.BackupVersion, want)
+		}
+	}
+
+	samples, err := s.sampleForVerify(ctx, sampleRate)
+	if err != nil {
+		return fmt.Errorf("sample live store: %w", err)
+	}
+	rep.Sampled = len(samples)
+	err = restored.db.View(func(txn *badger.Txn) error {
+		for _, smp := range samples {
+			if smp.version > rep.BackupVersion {
+				rep.Newer++
+				continue
+			}
+			rep.Compared++
+			item, err := txn.Get(smp.key)
+			if errors.Is(err, badger.ErrKeyNotFound) || (err == nil && restored.expired(item)) {
+				rep.Missing++
+				if len(rep.MissingKeys) < backupVerifyKeysLimit {
+					rep.MissingKeys = append(rep.MissingKeys, smp.key)
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			var hash [sha256.Size]byte
+			if item.Version() == smp.version {
+				if err := item.Value(func(val []byte) error {
+					hash = sha256.Sum256(val)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			// более новая версия в бэкапе при старой живой невозможна, поэтому другая версия — тоже расхождение
+			if item.Version() != smp.version || hash != smp.hash {
+				rep.Mismatched++
+				if len(rep.MismatchedKeys) < backupVerifyKeysLimit {
+					rep.MismatchedKeys = append(rep.MismatchedKeys, smp.key)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("compare with restored store: %w", err)
+	}
+	if !rep.OK() {
+		return &BackupVerifyError{Report: *rep}
+	}
+	return nil
+}
+
+func restoreBackupFile(dst *Store, f BackupFile) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		return fmt.Errorf("open gzip: %w", err)
+	}
+	defer zr.Close()
+	return dst.restoreFromReader(zr, 256, nil)
+}
+
+// sampleForVerify выбирает долю rate живых ключей и запоминает их версии и хеши значений.
+func (s *Store) sampleForVerify(ctx context.Context, rate float64) ([]backupSample, error) {
+	var samples []backupSample
+	seen := 0
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if seen++; seen%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			if s.expired(item) || rand.Float64() >= rate {
+				continue
+			}
+			smp := backupSample{key: item.KeyCopy(nil), version: item.Version()}
+			if err := item.Value(func(val []byte) error {
+				smp.hash = sha256.Sum256(val)
+				return nil
+			}); err != nil {
+				return err
+			}
+			samples = append(samples, smp)
+		}
+		return nil
+	})
+	return samples, err
+}
@@ -0,0 +1,165 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var ErrBatcherClosed = errors.New("batcher is closed")
+
+type BatcherOptions struct {
+	// Window — сколько ждать попутных Get после первого запроса пачки, по умолчанию 1ms.
+	Window time.Duration
+	// MaxKeys — размер пачки, при котором она выполняется не дожидаясь окна, по умолчанию 64.
+	MaxKeys int
+}
+
+// Batcher — автоматическое объединение Get: запросы, пришедшие в пределах окна,
+// выполняются одной транзакцией чтения вместо отдельной транзакции на каждый ключ.
+type Batcher struct {
+	store *Store
+	opts  BatcherOptions
+
+	mu      sync.Mutex
+	pending []*GetFuture
+	timer   *time.Timer
+	closed  bool
+}
+
+// GetFuture — результат отложенного Get.
+type GetFuture struct {
+	ctx   context.Context // principal для AccessController
+	key   []byte
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+func NewBatcher(store *Store, opts BatcherOptions) *Batcher {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Millisecond
+	}
+	if opts.MaxKeys <= 0 {
+		opts.MaxKeys = 64
+	}
+	return &Batcher{store: store, opts: opts}
+}
+
+// GetAsync ставит ключ в текущую пачку и сразу возвращает future.
+func (b *Batcher) GetAsync(key []byte) *GetFuture {
+	return b.GetAsyncContext(context.Background(), key)
+}
+
+// GetAsyncContext — GetAsync от имени principal из ctx (WithPrincipal): доступ проверяется для
+// каждого ключа пачки отдельно, запрет завершает ошибкой только его future.
+func (b *Batcher) GetAsyncContext(ctx context.Context, key []byte) *GetFuture {
+	f := &GetFuture{ctx: ctx, key: append([]byte(nil), key...), done: make(chan struct{})}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		f.err = ErrBatcherClosed
+		close(f.done)
+		return f
+	}
+	b.pending = append(b.pending, f)
+	var batch []*GetFuture
+	switch {
+	case len(b.pending) >= b.opts.MaxKeys:
+		batch = b.takeLocked()
+	case len(b.pending) == 1:
+		b.timer = time.AfterFunc(b.opts.Window, b.flush)
+	}
+	b.mu.Unlock()
+
+	if batch != nil {
+		b.execute(batch)
+	}
+	return f
+}
+
+// Get — синхронный вариант GetAsync. Ошибка ErrNotFound, если ключа нет.
+func (b *Batcher) Get(ctx context.Context, key []byte) ([]byte, error) {
+	return b.GetAsyncContext(ctx, key).Wait(ctx)
+}
+
+// Wait дожидается результата. Отмена ctx не отменяет чтение в пачке, только ожидание.
+func (f *GetFuture) Wait(ctx context.Context) ([]byte, error) {
+	select {
+	case <-f.done:
+		return f.value, f.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Close выполняет накопленные запросы; последующие Get завершаются ErrBatcherClosed.
+func (b *Batcher) Close() {
+	b.mu.Lock()
+	b.closed = true
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	b.execute(batch)
+}
+
+func (b *Batcher) flush() {
+	b.mu.Lock()
+	batch := b.takeLocked()
+	b.mu.Unlock()
+	b.execute(batch)
+}
+
+func (b *Batcher) takeLocked() []*GetFuture {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	batch := b.pending
+	b.pending = nil
+	return batch
+}
+
+// batchedGet — чтение ключа пачки с теми же проверками, что у MultiGet: доступ, истечение TTL,
+// учёт чтения. Служебная область Batcher недоступна — ErrSystemKey.
+func (s *Store) batchedGet(ctx context.Context, txn *badger.Txn, key []byte) ([]byte, error) {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return nil, err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return nil, err
+	}
+	v, found, err := s.txLiveValue(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	s.noteRead(key)
+	return v, nil
+}
+
+func (b *Batcher) execute(batch []*GetFuture) {
+	if len(batch) == 0 {
+		return
+	}
+	err := b.store.db.View(func(txn *badger.Txn) error {
+		for _, f := range batch {
+			f.value, f.err = b.store.batchedGet(f.ctx, txn, f.key)
+		}
+		return nil
+	})
+	for _, f := range batch {
+		if err != nil && f.err == nil && f.value == nil {
+			f.err = err
+		}
+		close(f.done)
+	}
+}
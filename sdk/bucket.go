@@ -0,0 +1,305 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bucket — пространство ключей арендатора внутри Store: ключи bucket'а name лежат под префиксом
+// name + ":", а методы Bucket принимают и возвращают ключи без него. С BucketOptions.Keys значения
+// шифруются на уровне приложения (AES-GCM) ключом арендатора из KeyProvider:
+//
+//	1 байт формата + 1 байт длины ID ключа + ID ключа + nonce (12 байт) + шифротекст с тегом
+//
+// ID ключа хранится в значении, поэтому ротация (новый CurrentKey) не требует перешифровки: старые
+// значения читаются старым ключом, пока он есть у провайдера. Полный ключ Store входит в AAD —
+// значение, переставленное под другой ключ, не расшифруется. Уничтожение ключей арендатора у
+// провайдера (криптографическое удаление) делает все его значения нечитаемыми, в том числе в бэкапах
+// и старых версиях Badger, которые DropPrefix не достаёт. Чтение через Store в обход Bucket
+// возвращает шифротекст.
+
+// ErrBucketKeyDestroyed — ключ шифрования bucket'а уничтожен или неизвестен провайдеру.
+var ErrBucketKeyDestroyed = errors.New("bucket encryption key is destroyed")
+
+// KeyProvider выдаёт ключи шифрования значений bucket'ов (16, 24 или 32 байта — AES-128/192/256).
+// Обычно это обёртка над KMS; реализация должна быть безопасна для конкурентного использования.
+type KeyProvider interface {
+	// CurrentKey возвращает ID и ключ для новых записей bucket'а.
+	CurrentKey(ctx context.Context, bucket string) (id string, key []byte, err error)
+	// Key возвращает ключ id bucket'а; уничтоженный или неизвестный ключ — ErrBucketKeyDestroyed.
+	Key(ctx context.Context, bucket, id string) ([]byte, error)
+}
+
+type BucketOptions struct {
+	// Keys — провайдер ключей шифрования значений; nil — значения хранятся открыто.
+	Keys KeyProvider
+}
+
+// Bucket — ключи одного арендатора с необязательным шифрованием значений.
+type Bucket struct {
+	store  *Store
+	name   string
+	prefix []byte
+	keys   KeyProvider
+}
+
+// NewBucket возвращает bucket name (непустое, без ':').
+func NewBucket(store *Store, name string, opts BucketOptions) *Bucket {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if name == "" || strings.Contains(name, ":") {
+		panic("bucket name must be non-empty and must not contain ':'")
+	}
+	return &Bucket{store: store, name: name, prefix: []byte(name + ":"), keys: opts.Keys}
+}
+
+func (b *Bucket) Name() string { return b.name }
+
+// Prefix возвращает префикс ключей bucket'а в Store.
+func (b *Bucket) Prefix() []byte { return bytes.Clone(b.prefix) }
+
+// Encrypted — шифруются ли значения bucket'а.
+func (b *Bucket) Encrypted() bool { return b.keys != nil }
+
+func (b *Bucket) key(key []byte) []byte {
+	return append(bytes.Clone(b.prefix), key...)
+}
+
+// Set записывает value под key bucket'а.
+func (b *Bucket) Set(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	k := b.key(key)
+	data, err := b.seal(ctx, k, value)
+	if err != nil {
+		return err
+	}
+	return b.store.SetContext(ctx, k, data, ttl)
+}
+
+// Get возвращает значение key bucket'а.
+func (b *Bucket) Get(ctx context.Context, key []byte) ([]byte, error) {
+	k := b.key(key)
+	data, err := b.store.GetContext(ctx, k)
+	if err != nil {
+		return nil, err
+	}
+	return b.open(ctx, k, data)
+}
+
+// Delete удаляет key bucket'а.
+func (b *Bucket) Delete(ctx context.Context, key []byte) error {
+	return b.store.DeleteContext(ctx, b.key(key))
+}
+
+// SetObject кодирует v кодеком ключа (как EncodeValue, без конверта VersionedObjects) и записывает под key.
+func (b *Bucket) SetObject(ctx context.Context, key []byte, v any, ttl time.Duration) error {
+	k := b.key(key)
+	data, err := b.store.marshal(k, v)
+	if err != nil {
+		return err
+	}
+	if data, err = b.seal(ctx, k, data); err != nil {
+		return err
+	}
+	return b.store.SetContext(ctx, k, data, ttl)
+}
+
+// GetObject декодирует значение key bucket'а в v.
+func (b *Bucket) GetObject(ctx context.Context, key []byte, v any) error {
+	data, err := b.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	return b.store.decode(b.key(key), data, v)
+}
+
+// Scan обходит ключи bucket'а с префиксом prefix (в KV.Key — без префикса bucket'а). Значение,
+// которое не удалось расшифровать, прерывает обход ошибкой.
+func (b *Bucket) Scan(ctx context.Context, prefix []byte, limit int, fn func(kv KV) error) error {
+	return b.store.ScanPrefixContext(ctx, b.key(prefix), limit, func(kv KV) error {
+		v, err := b.open(ctx, kv.Key, kv.Value)
+		if err != nil {
+			return err
+		}
+		return fn(KV{Key: kv.Key[len(b.prefix):], Value: v})
+	})
+}
+
+// Drop удаляет все ключи bucket'а (DropPrefix). Старые версии и бэкапы при этом остаются — для
+// гарантированного удаления зашифрованного bucket'а уничтожьте его ключи у провайдера.
+func (b *Bucket) Drop(ctx context.Context) error {
+	return b.store.DropPrefix(ctx, b.prefix)
+}
+
+// KeyID возвращает ID ключа шифрования, которым записано значение key; пустая строка — bucket без шифрования.
+func (b *Bucket) KeyID(ctx context.Context, key []byte) (string, error) {
+	data, err := b.store.GetContext(ctx, b.key(key))
+	if err != nil || b.keys == nil {
+		return "", err
+	}
+	id, _, _, err := parseBucketEnvelope(b.key(key), data)
+	return id, err
+}
+
+const (
+	bucketValueFormat = 1
+	bucketNonceSize   = 12
+)
+
+// seal шифрует value текущим ключом bucket'а.
+func (b *Bucket) seal(ctx context.Context, key, value []byte) ([]byte, error) {
+	if b.keys == nil {
+		return value, nil
+	}
+	id, secret, err := b.keys.CurrentKey(ctx, b.name)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s: current key: %w", b.name, err)
+	}
+	if id == "" || len(id) > 255 {
+		return nil, fmt.Errorf("bucket %s: key id must be 1..255 bytes", b.name)
+	}
+	aead, err := newBucketAEAD(secret)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s key %s: %w", b.name, id, err)
+	}
+	out := make([]byte, 0, 2+len(id)+bucketNonceSize+len(value)+aead.Overhead())
+	out = append(out, bucketValueFormat, byte(len(id)))
+	out = append(out, id...)
+	nonce := out[len(out) : len(out)+bucketNonceSize]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out = out[:len(out)+bucketNonceSize]
+	return aead.Seal(out, nonce, value, key), nil
+}
+
+// open расшифровывает значение key ключом из его конверта.
+func (b *Bucket) open(ctx context.Context, key, data []byte) ([]byte, error) {
+	if b.keys == nil {
+		return data, nil
+	}
+	id, nonce, sealed, err := parseBucketEnvelope(key, data)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := b.keys.Key(ctx, b.name, id)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s key %s: %w", b.name, id, err)
+	}
+	aead, err := newBucketAEAD(secret)
+	if err != nil {
+		return nil, fmt.Errorf("bucket %s key %s: %w", b.name, id, err)
+	}
+	out, err := aead.Open(nil, nonce, sealed, key)
+	if err != nil {
+		return nil, fmt.Errorf("bucket value %q: decrypt with key %s: %w", key, id, err)
+	}
+	return out, nil
+}
+
+func parseBucketEnvelope(key, data []byte) (id string, nonce, sealed []byte, err error) {
+	if len(data) < 2 || data[0] != bucketValueFormat {
+		return "", nil, nil, fmt.Errorf("bucket value %q: not encrypted", key)
+	}
+	n := int(data[1])
+	if len(data) < 2+n+bucketNonceSize {
+		return "", nil, nil, fmt.Errorf("bucket value %q: truncated envelope", key)
+	}
+	id = string(data[2 : 2+n])
+	nonce = data[2+n : 2+n+bucketNonceSize]
+	return id, nonce, data[2+n+bucketNonceSize:], nil
+}
+
+func newBucketAEAD(secret []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(secret)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MemoryKeyProvider — KeyProvider с ключами в памяти процесса: ключи загружает приложение (например,
+// из KMS при старте), уничтожение — DestroyKey/DestroyBucket. Нулевое значение готово к работе.
+type MemoryKeyProvider struct {
+	mu      sync.RWMutex
+	buckets map[string]*memoryBucketKeys
+}
+
+type memoryBucketKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+// AddKey добавляет ключ id bucket'а и делает его текущим.
+func (p *MemoryKeyProvider) AddKey(bucket, id string, key []byte) error {
+	if id == "" {
+		return errors.New("empty key id")
+	}
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.buckets == nil {
+		p.buckets = make(map[string]*memoryBucketKeys)
+	}
+	bk := p.buckets[bucket]
+	if bk == nil {
+		bk = &memoryBucketKeys{keys: make(map[string][]byte)}
+		p.buckets[bucket] = bk
+	}
+	bk.keys[id] = bytes.Clone(key)
+	bk.current = id
+	return nil
+}
+
+// DestroyKey забывает ключ id bucket'а. Если он был текущим, новые записи получают ErrBucketKeyDestroyed,
+// пока не добавлен новый ключ.
+func (p *MemoryKeyProvider) DestroyKey(bucket, id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	bk := p.buckets[bucket]
+	if bk == nil {
+		return
+	}
+	delete(bk.keys, id)
+	if bk.current == id {
+		bk.current = ""
+	}
+}
+
+// DestroyBucket забывает все ключи bucket'а.
+func (p *MemoryKeyProvider) DestroyBucket(bucket string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.buckets, bucket)
+}
+
+func (p *MemoryKeyProvider) CurrentKey(_ context.Context, bucket string) (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	bk := p.buckets[bucket]
+	if bk == nil || bk.current == "" {
+		return "", nil, ErrBucketKeyDestroyed
+	}
+	return bk.current, bk.keys[bk.current], nil
+}
+
+func (p *MemoryKeyProvider) Key(_ context.Context, bucket, id string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if bk := p.buckets[bucket]; bk != nil {
+		if k, ok := bk.keys[id]; ok {
+			return k, nil
+		}
+	}
+	return nil, ErrBucketKeyDestroyed
+}
@@ -0,0 +1,292 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/dgraph-io/ristretto/v2/z"
+)
+
+// Начальная загрузка через StreamWriter Badger. Транзакции и даже WriteBatch проводят каждую запись
+// через memtable, L0 и компакции; StreamWriter строит из отсортированного потока сразу таблицы
+// нижнего уровня, и первичная загрузка набора данных ускоряется на порядки. Условия: хранилище пусто
+// (служебные ключи Store с '!' в начале не в счёт — они сохраняются), вход отсортирован по ключу, и
+// на время загрузки нет других записей — StreamWriter их блокирует. Если вход оказался не
+// отсортирован, уже записанное фиксируется, а остаток дописывается через WriteBatch.
+// Подписки на изменения (Watch, PrefixDigest) записи StreamWriter не видят.
+
+// ErrStoreNotEmpty — BulkLoad в хранилище, где уже есть данные.
+var ErrStoreNotEmpty = errors.New("store is not empty")
+
+// BulkEntry — запись BulkLoad.
+type BulkEntry struct {
+	Key, Value []byte
+	// TTL — 0 — бессрочно.
+	TTL      time.Duration
+	UserMeta byte
+}
+
+// BulkIterator — источник записей BulkLoad, в идеале по возрастанию ключей. Next возвращает io.EOF,
+// когда записи кончились. Записи используются до следующего вызова Next, их можно не копировать.
+type BulkIterator interface {
+	Next() (BulkEntry, error)
+}
+
+// BulkEntries — BulkIterator по срезу.
+func BulkEntries(entries []BulkEntry) BulkIterator {
+	return &sliceBulkIterator{entries: entries}
+}
+
+type sliceBulkIterator struct {
+	entries []BulkEntry
+	i       int
+}
+
+func (it *sliceBulkIterator) Next() (BulkEntry, error) {
+	if it.i >= len(it.entries) {
+		return BulkEntry{}, io.EOF
+	}
+	it.i++
+	return it.entries[it.i-1], nil
+}
+
+// BulkLoadOptions — параметры BulkLoad.
+type BulkLoadOptions struct {
+	// Progress вызывается каждые ProgressInterval (по умолчанию 1с) и по завершении.
+	Progress         func(BulkLoadProgress)
+	ProgressInterval time.Duration
+}
+
+// BulkLoadProgress — ход BulkLoad.
+type BulkLoadProgress struct {
+	// Entries и Bytes (ключи и значения) — прочитанные из источника записи.
+	Entries int64
+	Bytes   int64
+	// Batched — из них записанные через WriteBatch после того, как вход оказался не отсортирован.
+	Batched int64
+	Elapsed time.Duration
+	Done    bool
+}
+
+// BulkLoadReport — итог BulkLoad.
+type BulkLoadReport struct {
+	BulkLoadProgress
+	// Fallback — вход оказался не отсортирован; FallbackKey — первый ключ не по порядку.
+	Fallback    bool
+	FallbackKey []byte
+}
+
+// BulkLoad загружает записи src в пустое хранилище через StreamWriter. Пустоту проверяет до начала
+// (иначе ErrStoreNotEmpty); ключи проходят AccessController и KeyValidator. Все записи получают одну
+// версию. Операция пишется в журнал аудита (op "bulk_load"). При ошибке часть записей может остаться.
+func (s *Store) BulkLoad(ctx context.Context, src BulkIterator, opts ...BulkLoadOptions) (BulkLoadReport, error) {
+	var o BulkLoadOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.ProgressInterval <= 0 {
+		o.ProgressInterval = time.Second
+	}
+	var rep BulkLoadReport
+	err := s.RunAudited(ctx, "bulk_load", "", func() (err error) {
+		ctx, job := s.StartJob(ctx, JobBulkLoad, "")
+		defer func() { job.Finish(err) }()
+		return s.withCompactions("bulk load", func() error { return s.bulkLoad(ctx, src, o, &rep, job) })
+	})
+	return rep, err
+}
+
+func (s *Store) bulkLoad(ctx context.Context, src BulkIterator, o BulkLoadOptions, rep *BulkLoadReport, job *Job) error {
+	start := s.clock.Now()
+	lastProgress := start
+	progress := func(done bool) {
+		rep.Elapsed = s.clock.Now().Sub(start)
+		rep.Done = done
+		if o.Progress != nil {
+			o.Progress(rep.BulkLoadProgress)
+		}
+	}
+	defer func() { rep.Elapsed = s.clock.Now().Sub(start) }()
+
+	internal, err := s.bulkLoadInternalKeys()
+	if err != nil {
+		return err
+	}
+	version := s.db.MaxVersion() + 1
+
+	sw := s.db.NewStreamWriter()
+	if err := sw.Prepare(); err != nil {
+		return fmt.Errorf("prepare stream writer: %w", err)
+	}
+	flushed := false
+	defer func() {
+		if !flushed {
+			sw.Cancel()
+		}
+	}()
+	buf := z.NewBuffer(4<<20, "sdk.BulkLoad")
+	defer func() { _ = buf.Release() }()
+	send := func() error {
+		if err := sw.Write(buf); err != nil {
+			return fmt.Errorf("stream write: %w", err)
+		}
+		buf.Reset()
+		return nil
+	}
+	var prev []byte
+	add := func(kv *pb.KV) error {
+		badger.KVToBuffer(kv, buf)
+		prev = append(prev[:0], kv.Key...)
+		if buf.LenNoPadding() >= 8<<20 {
+			return send()
+		}
+		return nil
+	}
+	// служебные ключи вливаются в поток по порядку
+	flushInternal := func(before []byte) error {
+		for len(internal) > 0 && (before == nil || bytes.Compare(internal[0].Key, before) < 0) {
+			if err := add(internal[0]); err != nil {
+				return err
+			}
+			internal = internal[1:]
+		}
+		if before != nil && len(internal) > 0 && bytes.Equal(internal[0].Key, before) {
+			internal = internal[1:] // запись источника заменяет служебный ключ
+		}
+		return nil
+	}
+
+	var e BulkEntry
+	for {
+		if e, err = src.Next(); err != nil {
+			break
+		}
+		if err = s.bulkCheck(ctx, e); err != nil {
+			return err
+		}
+		if prev != nil && bytes.Compare(e.Key, prev) <= 0 {
+			rep.Fallback, rep.FallbackKey = true, bytes.Clone(e.Key)
+			break
+		}
+		if err = flushInternal(e.Key); err != nil {
+			return err
+		}
+		kv := &pb.KV{Key: e.Key, Value: e.Value, Version: version}
+		if e.TTL > 0 {
+			kv.ExpiresAt = uint64(s.clock.Now().Add(e.TTL).Unix())
+		}
+		if e.UserMeta != 0 {
+			kv.UserMeta = []byte{e.UserMeta}
+		}
+		if err = add(kv); err != nil {
+			return err
+		}
+		rep.Entries++
+		rep.Bytes += int64(len(e.Key) + len(e.Value))
+		if rep.Entries%1024 == 0 {
+			if err = ctx.Err(); err != nil {
+				return err
+			}
+			job.SetProgress(int64(rep.Entries), 0)
+			if now := s.clock.Now(); now.Sub(lastProgress) >= o.ProgressInterval {
+				lastProgress = now
+				progress(false)
+			}
+		}
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("bulk source: %w", err)
+	}
+	if err := flushInternal(nil); err != nil {
+		return err
+	}
+	if err := send(); err != nil {
+		return err
+	}
+	flushed = true
+	if err := sw.Flush(); err != nil {
+		return fmt.Errorf("flush stream writer: %w", err)
+	}
+	if rep.Fallback {
+		if err := s.bulkLoadBatched(ctx, src, e, o, rep, &lastProgress, progress); err != nil {
+			return err
+		}
+	}
+	progress(true)
+	return nil
+}
+
+// bulkLoadBatched дописывает через WriteBatch запись first и остаток src.
+func (s *Store) bulkLoadBatched(ctx context.Context, src BulkIterator, first BulkEntry, o BulkLoadOptions, rep *BulkLoadReport, lastProgress *time.Time, progress func(bool)) error {
+	wb := s.NewWriteBatchContext(ctx)
+	defer wb.Cancel()
+	e := first
+	for {
+		if err := wb.SetWithMeta(bytes.Clone(e.Key), bytes.Clone(e.Value), e.TTL, e.UserMeta); err != nil {
+			return err
+		}
+		rep.Entries++
+		rep.Batched++
+		rep.Bytes += int64(len(e.Key) + len(e.Value))
+		if now := s.clock.Now(); rep.Entries%1024 == 0 && now.Sub(*lastProgress) >= o.ProgressInterval {
+			*lastProgress = now
+			progress(false)
+		}
+		var err error
+		if e, err = src.Next(); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("bulk source: %w", err)
+		}
+		if err := s.bulkCheck(ctx, e); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func (s *Store) bulkCheck(ctx context.Context, e BulkEntry) error {
+	if len(e.Key) == 0 {
+		return errors.New("bulk load: empty key")
+	}
+	if err := s.checkAccess(ctx, AccessWrite, e.Key); err != nil {
+		return err
+	}
+	return s.validateKey(ctx, e.Key)
+}
+
+// bulkLoadInternalKeys проверяет, что в хранилище нет живых пользовательских ключей, и возвращает
+// служебные (с '!' в начале) по порядку, чтобы записать их обратно после Prepare.
+func (s *Store) bulkLoadInternalKeys() ([]*pb.KV, error) {
+	var internal []*pb.KV
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			if s.expired(item) {
+				continue
+			}
+			if item.Key()[0] != '!' {
+				return fmt.Errorf("%w: key %q", ErrStoreNotEmpty, item.Key())
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			kv := &pb.KV{Key: item.KeyCopy(nil), Value: val, Version: item.Version(), ExpiresAt: item.ExpiresAt()}
+			if m := item.UserMeta(); m != 0 {
+				kv.UserMeta = []byte{m}
+			}
+			internal = append(internal, kv)
+		}
+		return nil
+	})
+	return internal, err
+}
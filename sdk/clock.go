@@ -0,0 +1,74 @@
+package sdk
+
+import "time"
+
+type (
+	// Clock — источник времени хранилища: TTL записей, цикл GC, расписание бэкапов и паузы ретраев.
+	// По умолчанию используется реальное время, в тестах можно подставить управляемую реализацию
+	// и «перематывать» время вместо sleep.
+	Clock interface {
+		Now() time.Time
+		NewTicker(d time.Duration) Ticker
+		NewTimer(d time.Duration) Timer
+	}
+
+	// Ticker — минимальная обёртка над time.Ticker, достаточная для select-циклов.
+	Ticker interface {
+		C() <-chan time.Time
+		Stop()
+	}
+
+	// Timer — минимальная обёртка над time.Timer.
+	Timer interface {
+		C() <-chan time.Time
+		Stop() bool
+		Reset(d time.Duration) bool
+	}
+)
+
+type realClock struct{}
+
+// NewRealClock возвращает Clock поверх пакета time.
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return &realTicker{ticker: time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return &realTimer{timer: time.NewTimer(d)}
+}
+
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t *realTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+func (t *realTicker) Stop() {
+	t.ticker.Stop()
+}
+
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t *realTimer) C() <-chan time.Time {
+	return t.timer.C
+}
+
+func (t *realTimer) Stop() bool {
+	return t.timer.Stop()
+}
+
+func (t *realTimer) Reset(d time.Duration) bool {
+	return t.timer.Reset(d)
+}
@@ -0,0 +1,327 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrNoHealthyNodes — ни один узел, отвечающий за ключ, не доступен.
+var ErrNoHealthyNodes = errors.New("no healthy nodes for key")
+
+// ClusterNode — узел кластера с точки зрения клиента: клиент удалённого сервера
+// (транспорт не важен) или локальный *Store в обёртке с Ping.
+type ClusterNode interface {
+	KVStore
+	// Ping проверяет доступность узла; ошибка помечает узел нездоровым до следующей успешной проверки.
+	Ping(ctx context.Context) error
+}
+
+type ClusterOptions struct {
+	// VirtualNodes — число точек узла на кольце, по умолчанию 128. Больше — ровнее распределение.
+	VirtualNodes int
+	// Replicas — на сколько следующих по кольцу узлов дублируются записи, по умолчанию 0.
+	Replicas int
+	// ReadFallback — при недоступности основного узла читать с реплик.
+	ReadFallback bool
+	// HealthInterval — период проверки узлов в Run, по умолчанию 5s.
+	HealthInterval time.Duration
+	// HealthTimeout — таймаут одного Ping, по умолчанию 1s.
+	HealthTimeout time.Duration
+	// Codec для GetObject/SetObject, по умолчанию JSONCodec.
+	Codec Codec
+	// Clock — часы периода проверок в Run, nil — реальное время. Для встроенных узлов обычно тот
+	// же Clock, что в их Options.Clock.
+	Clock Clock
+}
+
+type clusterNode struct {
+	name    string
+	node    ClusterNode
+	healthy atomic.Bool
+}
+
+type ringPoint struct {
+	hash uint64
+	node int
+}
+
+// ClusterClient — клиентская маршрутизация по нескольким узлам через consistent hashing.
+// Реализует KVStore и ObjectStore, поэтому приложение переходит с встроенного *Store на кластер
+// без изменений кода. Транзакции между узлами не поддерживаются.
+type ClusterClient struct {
+	opts  ClusterOptions
+	nodes []*clusterNode
+	ring  []ringPoint
+}
+
+var (
+	_ KVStore     = (*ClusterClient)(nil)
+	_ ObjectStore = (*ClusterClient)(nil)
+)
+
+// NewClusterClient строит кольцо по именам узлов; имя определяет положение узла на кольце,
+// поэтому при перезапусках имена должны сохраняться. Все узлы изначально считаются здоровыми.
+func NewClusterClient(nodes map[string]ClusterNode, opts ClusterOptions) (*ClusterClient, error) {
+	if len(nodes) == 0 {
+		return nil, errors.New("no cluster nodes")
+	}
+	if opts.VirtualNodes <= 0 {
+		opts.VirtualNodes = 128
+	}
+	if opts.Replicas < 0 || opts.Replicas >= len(nodes) {
+		return nil, fmt.Errorf("replicas must be in [0, %d)", len(nodes))
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = 5 * time.Second
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = time.Second
+	}
+	if opts.Codec == nil {
+		opts.Codec = JSONCodec{}
+	}
+	if opts.Clock == nil {
+		opts.Clock = NewRealClock()
+	}
+
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	c := &ClusterClient{opts: opts}
+	for i, name := range names {
+		if nodes[name] == nil {
+			return nil, fmt.Errorf("nil cluster node %q", name)
+		}
+		n := &clusterNode{name: name, node: nodes[name]}
+		n.healthy.Store(true)
+		c.nodes = append(c.nodes, n)
+		for v := 0; v < opts.VirtualNodes; v++ {
+			c.ring = append(c.ring, ringPoint{hash: hash64([]byte(name + "#" + strconv.Itoa(v))), node: i})
+		}
+	}
+	sort.Slice(c.ring, func(i, j int) bool { return c.ring[i].hash < c.ring[j].hash })
+	return c, nil
+}
+
+// owners — узлы, отвечающие за key: основной и Replicas следующих различных узлов по кольцу.
+func (c *ClusterClient) owners(key []byte) []*clusterNode {
+	h := hash64(key)
+	i := sort.Search(len(c.ring), func(i int) bool { return c.ring[i].hash >= h })
+	out := make([]*clusterNode, 0, c.opts.Replicas+1)
+	seen := make(map[int]struct{}, c.opts.Replicas+1)
+	for j := 0; len(out) < c.opts.Replicas+1 && j < len(c.ring); j++ {
+		p := c.ring[(i+j)%len(c.ring)]
+		if _, ok := seen[p.node]; ok {
+			continue
+		}
+		seen[p.node] = struct{}{}
+		out = append(out, c.nodes[p.node])
+	}
+	return out
+}
+
+// NodeFor — имя основного узла для key.
+func (c *ClusterClient) NodeFor(key []byte) string {
+	return c.owners(key)[0].name
+}
+
+// Healthy возвращает состояние узлов по результатам последней проверки.
+func (c *ClusterClient) Healthy() map[string]bool {
+	out := make(map[string]bool, len(c.nodes))
+	for _, n := range c.nodes {
+		out[n.name] = n.healthy.Load()
+	}
+	return out
+}
+
+// Run периодически (по ClusterOptions.Clock) проверяет узлы через Ping. Блокируется до отмены ctx.
+func (c *ClusterClient) Run(ctx context.Context) {
+	ticker := c.opts.Clock.NewTicker(c.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		c.CheckHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// CheckHealth однократно проверяет все узлы.
+func (c *ClusterClient) CheckHealth(ctx context.Context) {
+	for _, n := range c.nodes {
+		pctx, cancel := context.WithTimeout(ctx, c.opts.HealthTimeout)
+		err := n.node.Ping(pctx)
+		cancel()
+		n.healthy.Store(err == nil)
+	}
+}
+
+// read выполняет чтение на основном узле, а при ReadFallback — на первой здоровой реплике.
+// ErrNotFound от узла — окончательный ответ, на реплики не переходим.
+func (c *ClusterClient) read(key []byte, fn func(n ClusterNode) error) error {
+	owners := c.owners(key)
+	if !c.opts.ReadFallback {
+		owners = owners[:1]
+	}
+	lastErr := ErrNoHealthyNodes
+	for _, o := range owners {
+		if !o.healthy.Load() {
+			continue
+		}
+		err := fn(o.node)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			return err
+		}
+		lastErr = fmt.Errorf("node %s: %w", o.name, err)
+	}
+	return lastErr
+}
+
+// write выполняет запись на всех здоровых владельцах ключа. Основной узел обязан быть здоров;
+// ошибка любой записи возвращается (реплики при этом могут разойтись до следующей записи ключа).
+func (c *ClusterClient) write(key []byte, fn func(n ClusterNode) error) error {
+	owners := c.owners(key)
+	if !owners[0].healthy.Load() {
+		return fmt.Errorf("node %s: %w", owners[0].name, ErrNoHealthyNodes)
+	}
+	var errs []error
+	for _, o := range owners {
+		if !o.healthy.Load() {
+			continue
+		}
+		if err := fn(o.node); err != nil {
+			errs = append(errs, fmt.Errorf("node %s: %w", o.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (c *ClusterClient) Get(key []byte) ([]byte, error) {
+	var out []byte
+	err := c.read(key, func(n ClusterNode) error {
+		v, err := n.Get(key)
+		out = v
+		return err
+	})
+	return out, err
+}
+
+func (c *ClusterClient) Set(key, value []byte, ttl time.Duration) error {
+	return c.write(key, func(n ClusterNode) error { return n.Set(key, value, ttl) })
+}
+
+func (c *ClusterClient) Delete(key []byte) error {
+	return c.write(key, func(n ClusterNode) error { return n.Delete(key) })
+}
+
+// GetAndDelete атомарен только на основном узле; с реплик ключ удаляется следом.
+func (c *ClusterClient) GetAndDelete(key []byte) ([]byte, error) {
+	owners := c.owners(key)
+	if !owners[0].healthy.Load() {
+		return nil, fmt.Errorf("node %s: %w", owners[0].name, ErrNoHealthyNodes)
+	}
+	v, err := owners[0].node.GetAndDelete(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range owners[1:] {
+		if o.healthy.Load() {
+			_ = o.node.Delete(key)
+		}
+	}
+	return v, nil
+}
+
+func (c *ClusterClient) GetObject(key []byte, v any) error {
+	data, err := c.Get(key)
+	if err != nil {
+		return err
+	}
+	if err := c.opts.Codec.Unmarshal(data, v); err != nil {
+		return &DecodeError{Key: append([]byte(nil), key...), Raw: data, Codec: fmt.Sprintf("%T", c.opts.Codec), Err: err}
+	}
+	return nil
+}
+
+func (c *ClusterClient) SetObject(key []byte, v any, ttl time.Duration) error {
+	data, err := c.opts.Codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("codec.Marshal: %w", err)
+	}
+	return c.Set(key, data, ttl)
+}
+
+// ScanPrefix опрашивает все здоровые узлы и отдаёт записи в порядке ключей без дублей реплик:
+// для каждого ключа берётся значение с самого приоритетного из ответивших владельцев.
+// Результат собирается в памяти (до limit записей с каждого узла), поэтому limit <= 0
+// на больших префиксах стоит использовать осторожно.
+func (c *ClusterClient) ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error {
+	type candidate struct {
+		value []byte
+		rank  int
+	}
+	found := make(map[string]candidate)
+	for ni, n := range c.nodes {
+		if !n.healthy.Load() {
+			continue
+		}
+		err := n.node.ScanPrefix(prefix, limit, func(kv KV) error {
+			rank := c.ownerRank(kv.Key, ni)
+			if rank < 0 {
+				return nil // остаток на узле, который больше не владеет ключом
+			}
+			if prev, ok := found[string(kv.Key)]; !ok || rank < prev.rank {
+				found[string(kv.Key)] = candidate{value: kv.Value, rank: rank}
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("node %s: %w", n.name, err)
+		}
+	}
+
+	keys := make([][]byte, 0, len(found))
+	for k := range found {
+		keys = append(keys, []byte(k))
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	for i, k := range keys {
+		if limit > 0 && i >= limit {
+			break
+		}
+		if err := fn(KV{Key: k, Value: found[string(k)].value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ownerRank — позиция узла node среди владельцев key (0 — основной), -1 — не владелец.
+func (c *ClusterClient) ownerRank(key []byte, node int) int {
+	for i, o := range c.owners(key) {
+		if o == c.nodes[node] {
+			return i
+		}
+	}
+	return -1
+}
+
+// LocalClusterNode оборачивает встроенный *Store в ClusterNode (Ping всегда успешен).
+type LocalClusterNode struct {
+	*Store
+}
+
+func (LocalClusterNode) Ping(context.Context) error {
+	return nil
+}
@@ -0,0 +1,63 @@
+package sdk_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/PavelAgarkov/memory-storage/sdk/storetest"
+)
+
+type pingNode struct {
+	sdk.KVStore
+	down atomic.Bool
+}
+
+func (n *pingNode) Ping(context.Context) error {
+	if n.down.Load() {
+		return errors.New("node is down")
+	}
+	return nil
+}
+
+func TestClusterClient_RunUsesClock(t *testing.T) {
+	clock := storetest.NewManualClock(time.Unix(0, 0))
+	node := &pingNode{}
+	node.down.Store(true)
+	c, err := sdk.NewClusterClient(map[string]sdk.ClusterNode{"a": node}, sdk.ClusterOptions{
+		HealthInterval: time.Hour,
+		Clock:          clock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		c.Run(ctx)
+		close(done)
+	}()
+
+	// первая проверка идёт сразу, следующая — только по тику часов
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Healthy()["a"] {
+		if time.Now().After(deadline) {
+			t.Fatal("first health check did not run")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	node.down.Store(false)
+	for !c.Healthy()["a"] {
+		if time.Now().After(deadline) {
+			t.Fatal("health check did not run on clock tick")
+		}
+		clock.Advance(time.Hour)
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
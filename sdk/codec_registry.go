@@ -0,0 +1,65 @@
+package sdk
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+type prefixCodec struct {
+	prefix []byte
+	codec  Codec
+}
+
+// RegisterCodec назначает codec для ключей с префиксом prefix. SetObject/GetObject, TxSetObject/TxGetObject
+// и сканы объектов выбирают кодек по самому длинному совпавшему префиксу, остальные ключи — Options.Codec.
+// Повторная регистрация того же префикса заменяет кодек. Регистрировать кодеки нужно до записи данных
+// под префиксом: уже записанные значения не перекодируются.
+func (s *Store) RegisterCodec(prefix []byte, codec Codec) {
+	if codec == nil {
+		panic("codec must be not nil")
+	}
+	s.codecsMu.Lock()
+	defer s.codecsMu.Unlock()
+
+	codecs := make([]prefixCodec, 0, len(s.codecs)+1)
+	for _, pc := range s.codecs {
+		if !bytes.Equal(pc.prefix, prefix) {
+			codecs = append(codecs, pc)
+		}
+	}
+	codecs = append(codecs, prefixCodec{prefix: append([]byte(nil), prefix...), codec: codec})
+	// длинные префиксы первыми: первое совпадение — самое специфичное
+	sort.SliceStable(codecs, func(i, j int) bool {
+		return len(codecs[i].prefix) > len(codecs[j].prefix)
+	})
+	s.codecs = codecs
+}
+
+// codecFor возвращает кодек для ключа key.
+func (s *Store) codecFor(key []byte) Codec {
+	s.codecsMu.RLock()
+	defer s.codecsMu.RUnlock()
+	for _, pc := range s.codecs {
+		if bytes.HasPrefix(key, pc.prefix) {
+			return pc.codec
+		}
+	}
+	return s.Codec
+}
+
+// EncodeValue кодирует v кодеком, назначенным ключу key, — как SetObject, но без конверта
+// VersionedObjects (такое значение читается с версией 0). Для записи объектов в обход SetObject,
+// например через WriteBatch.
+func (s *Store) EncodeValue(key []byte, v any) ([]byte, error) {
+	return s.marshal(key, v)
+}
+
+// marshal кодирует v кодеком, назначенным ключу key.
+func (s *Store) marshal(key []byte, v any) ([]byte, error) {
+	data, err := s.codecFor(key).Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("codec.Marshal: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,239 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	_ "unsafe" // go:linkname
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Пауза компакций Badger на время, когда важна задержка (распродажа, пиковый час). Компакция читает
+// и переписывает таблицы в фоне и отнимает у запросов диск и CPU; на паузе новые таблицы копятся в
+// L0, а долг переработки растёт и отдаётся после возобновления. Публичного API для этого в Badger нет,
+// поэтому Store вызывает те же внутренние stopCompactions/startCompactions, что и Flatten или DropAll.
+//
+// Пауза ограничена: компакции возобновляются сами по истечении maxDuration и заранее, если L0
+// подбирается к NumLevelZeroTablesStall, — иначе Badger остановил бы запись до конца паузы. Пока
+// компакции стоят, пропускается и периодический GC value-log, а операции, которые сами останавливают
+// компакции (Flatten в Reclaim и восстановлении из бэкапа, DropPrefix, DropAll, BulkLoad,
+// перестроение представлений), возвращают ErrCompactionsPaused. Вызовы этих операций напрямую через
+// DB() пауза не видит — они снова запустят компакции.
+
+//go:linkname badgerStopCompactions github.com/dgraph-io/badger/v4.(*DB).stopCompactions
+func badgerStopCompactions(db *badger.DB)
+
+//go:linkname badgerStartCompactions github.com/dgraph-io/badger/v4.(*DB).startCompactions
+func badgerStartCompactions(db *badger.DB)
+
+// ErrCompactionsPaused — компакции уже на паузе, или операция требует работающих компакций.
+var ErrCompactionsPaused = errors.New("compactions are paused")
+
+// Причины окончания паузы компакций.
+const (
+	CompactionResumeManual   = "manual"
+	CompactionResumeDeadline = "deadline"
+	CompactionResumeL0Stall  = "l0_stall"
+	CompactionResumeClose    = "close"
+)
+
+// compactionGuardInterval — период проверки L0 на паузе.
+const compactionGuardInterval = 100 * time.Millisecond
+
+// CompactionPause — пауза компакций и накопленный за неё долг.
+type CompactionPause struct {
+	Since    time.Time
+	Deadline time.Time
+	// ResumedAt и Reason (CompactionResume*) — когда и почему пауза кончилась; нулевые, пока она идёт.
+	ResumedAt time.Time
+	Reason    string
+	// L0TablesAtPause/L0BytesAtPause — L0 в начале паузы; L0Tables/L0Bytes — сейчас или на момент возобновления.
+	L0TablesAtPause int
+	L0BytesAtPause  int64
+	L0Tables        int
+	L0Bytes         int64
+	// MaxLevelScore — наибольший score компакции уровней (больше 1 — уровень ждёт компакции).
+	MaxLevelScore float64
+}
+
+// Duration — длительность паузы (до сих пор, если она идёт).
+func (p CompactionPause) Duration(now time.Time) time.Duration {
+	if !p.ResumedAt.IsZero() {
+		now = p.ResumedAt
+	}
+	return now.Sub(p.Since)
+}
+
+// CompactionPauseStats — состояние и счётчики пауз компакций.
+type CompactionPauseStats struct {
+	Paused bool
+	// Current — идущая пауза (Paused) или последняя завершённая; нулевая, если пауз не было.
+	Current CompactionPause
+	// Pauses — всего пауз; AutoResumes — из них завершённых без ResumeCompactions.
+	Pauses      uint64
+	AutoResumes uint64
+	// TotalPaused — суммарное время завершённых пауз.
+	TotalPaused time.Duration
+}
+
+type compactionPauser struct {
+	// RLock держат операции, которые сами останавливают компакции; Lock — пауза и возобновление
+	mu          sync.RWMutex
+	active      *CompactionPause
+	last        CompactionPause
+	stopGuard   chan struct{}
+	pauses      uint64
+	autoResumes uint64
+	total       time.Duration
+}
+
+// PauseCompactions останавливает компакции Badger не дольше чем на maxDuration. Ждёт окончания идущих
+// компакций, а также Flatten, DropPrefix и других операций, которые сами останавливают компакции.
+// Повторная пауза до возобновления — ErrCompactionsPaused. Операция пишется в журнал аудита
+// (op "pause_compactions").
+func (s *Store) PauseCompactions(ctx context.Context, maxDuration time.Duration) error {
+	if maxDuration <= 0 {
+		return errors.New("compaction pause duration must be positive")
+	}
+	if s.db.Opts().ReadOnly {
+		return errors.New("compactions do not run on a read-only store")
+	}
+	return s.RunAudited(ctx, "pause_compactions", maxDuration.String(), func() error {
+		c := &s.compactions
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.active != nil {
+			return ErrCompactionsPaused
+		}
+		badgerStopCompactions(s.db)
+		now := s.clock.Now()
+		p := &CompactionPause{Since: now, Deadline: now.Add(maxDuration)}
+		s.observeL0(p)
+		p.L0TablesAtPause, p.L0BytesAtPause = p.L0Tables, p.L0Bytes
+		c.active = p
+		c.pauses++
+		c.stopGuard = make(chan struct{})
+		go s.guardCompactionPause(c.stopGuard, maxDuration)
+		return nil
+	})
+}
+
+// ResumeCompactions возобновляет компакции и возвращает итог паузы. Если паузы нет (или она уже
+// кончилась сама), возвращает последнюю завершённую паузу и false. Операция пишется в журнал аудита
+// (op "resume_compactions").
+func (s *Store) ResumeCompactions(ctx context.Context) (CompactionPause, bool, error) {
+	var p CompactionPause
+	var ok bool
+	err := s.RunAudited(ctx, "resume_compactions", "", func() error {
+		p, ok = s.resumeCompactions(CompactionResumeManual)
+		return nil
+	})
+	return p, ok, err
+}
+
+// resumeCompactions завершает идущую паузу с причиной reason.
+func (s *Store) resumeCompactions(reason string) (CompactionPause, bool) {
+	c := &s.compactions
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.active == nil {
+		return c.last, false
+	}
+	badgerStartCompactions(s.db)
+	p := c.active
+	p.ResumedAt, p.Reason = s.clock.Now(), reason
+	s.observeL0(p)
+	c.last, c.active = *p, nil
+	c.total += p.Duration(p.ResumedAt)
+	if reason != CompactionResumeManual {
+		c.autoResumes++
+	}
+	close(c.stopGuard)
+	return c.last, true
+}
+
+// guardCompactionPause возобновляет компакции по истечении паузы или когда L0 близок к остановке записи.
+func (s *Store) guardCompactionPause(stop <-chan struct{}, maxDuration time.Duration) {
+	timer := s.clock.NewTimer(maxDuration)
+	defer timer.Stop()
+	tick := s.clock.NewTicker(compactionGuardInterval)
+	defer tick.Stop()
+	stall := s.db.Opts().NumLevelZeroTablesStall
+	for {
+		select {
+		case <-stop:
+			return
+		case <-s.bg.Done():
+			return // Close возобновляет паузу сам
+		case <-timer.C():
+			s.resumeCompactions(CompactionResumeDeadline)
+			return
+		case <-tick.C():
+			// запас в одну таблицу: следующий сброс memtable не должен упереться в stall
+			if stall > 0 && s.l0Tables() >= stall-1 {
+				s.resumeCompactions(CompactionResumeL0Stall)
+				return
+			}
+		}
+	}
+}
+
+func (s *Store) l0Tables() int {
+	for _, l := range s.db.Levels() {
+		if l.Level == 0 {
+			return l.NumTables
+		}
+	}
+	return 0
+}
+
+// observeL0 записывает в p текущее состояние L0 и наибольший score компакции.
+func (s *Store) observeL0(p *CompactionPause) {
+	p.MaxLevelScore = 0
+	for _, l := range s.db.Levels() {
+		if l.Level == 0 {
+			p.L0Tables, p.L0Bytes = l.NumTables, l.Size
+		}
+		p.MaxLevelScore = max(p.MaxLevelScore, l.Score)
+	}
+}
+
+// CompactionPauseStats возвращает состояние паузы компакций и накопленный долг.
+func (s *Store) CompactionPauseStats() CompactionPauseStats {
+	c := &s.compactions
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	st := CompactionPauseStats{
+		Paused:      c.active != nil,
+		Current:     c.last,
+		Pauses:      c.pauses,
+		AutoResumes: c.autoResumes,
+		TotalPaused: c.total,
+	}
+	if c.active != nil {
+		st.Current = *c.active
+		s.observeL0(&st.Current)
+	}
+	return st
+}
+
+// compactionsPaused — идёт ли пауза компакций.
+func (s *Store) compactionsPaused() bool {
+	s.compactions.mu.RLock()
+	defer s.compactions.mu.RUnlock()
+	return s.compactions.active != nil
+}
+
+// withCompactions выполняет op, которая сама останавливает и запускает компакции Badger, не давая
+// начаться паузе; на паузе возвращает ErrCompactionsPaused.
+func (s *Store) withCompactions(op string, fn func() error) error {
+	s.compactions.mu.RLock()
+	defer s.compactions.mu.RUnlock()
+	if s.compactions.active != nil {
+		return fmt.Errorf("%s: %w", op, ErrCompactionsPaused)
+	}
+	return fn()
+}
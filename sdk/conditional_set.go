@@ -0,0 +1,103 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Условная запись поверх TransactionManager: проверка текущего значения и запись идут в одной
+// транзакции, а после badger.ErrConflict транзакция повторяется с повторной проверкой, поэтому из
+// конкурентных SetNX одного ключа успешен ровно один. Гарантия держится на обнаружении конфликтов
+// Badger: при DetectConflicts=false нужен TxManagerOptions.LockKeysFn или внешняя блокировка.
+// Истёкший ключ считается отсутствующим.
+
+// SetNX записывает value, только если ключа нет; false — ключ уже есть, значение не изменено.
+// Подходит для блокировок с TTL и идемпотентных вставок.
+func (s *Store) SetNX(key, value []byte, ttl time.Duration) (bool, error) {
+	return s.SetNXContext(context.Background(), key, value, ttl)
+}
+
+// SetNXContext — SetNX от имени principal из ctx (WithPrincipal); отмена ctx прерывает ожидание
+// WriteRateLimit и повторы.
+func (s *Store) SetNXContext(ctx context.Context, key, value []byte, ttl time.Duration) (bool, error) {
+	if err := s.checkConditionalWrite(ctx, key); err != nil {
+		return false, err
+	}
+	var ok bool
+	err := NewTransactionManager(s).ExecuteReadWriteWithContext(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		ok = false
+		_, found, err := s.txLiveValue(txn, key)
+		if err != nil || found {
+			return err
+		}
+		ok = true
+		return txn.SetEntry(s.NewEntry(key, value, s.jitterTTL(ctx, ttl)))
+	})
+	return ok, err
+}
+
+// CompareAndSwap заменяет значение на value, только если текущее равно expected; false — ключа нет
+// или значение другое. TTL записи заменяется на ttl. Для записи отсутствующего ключа — SetNX.
+func (s *Store) CompareAndSwap(key, expected, value []byte, ttl time.Duration) (bool, error) {
+	return s.CompareAndSwapContext(context.Background(), key, expected, value, ttl)
+}
+
+// CompareAndSwapContext — CompareAndSwap от имени principal из ctx (WithPrincipal).
+func (s *Store) CompareAndSwapContext(ctx context.Context, key, expected, value []byte, ttl time.Duration) (bool, error) {
+	if err := s.checkConditionalWrite(ctx, key); err != nil {
+		return false, err
+	}
+	var ok bool
+	err := NewTransactionManager(s).ExecuteReadWriteWithContext(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		ok = false
+		cur, found, err := s.txLiveValue(txn, key)
+		if err != nil || !found || !bytes.Equal(cur, expected) {
+			return err
+		}
+		ok = true
+		return txn.SetEntry(s.NewEntry(key, value, s.jitterTTL(ctx, ttl)))
+	})
+	return ok, err
+}
+
+// checkConditionalWrite — проверки Set плюс право чтения: результат условной записи раскрывает,
+// есть ли ключ и каково его значение.
+func (s *Store) checkConditionalWrite(ctx context.Context, key []byte) error {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return err
+	}
+	if err := s.checkAccess(ctx, AccessWrite, key); err != nil {
+		return err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	if err := s.validateKey(ctx, key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	s.noteWrite(key)
+	return nil
+}
+
+// txLiveValue читает значение key в txn; found=false — ключа нет или он истёк.
+func (s *Store) txLiveValue(txn *badger.Txn, key []byte) ([]byte, bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if s.expired(item) {
+		return nil, false, nil
+	}
+	v, err := item.ValueCopy(nil)
+	return v, err == nil, err
+}
@@ -0,0 +1,236 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ConfigChange — изменение конфигурационного документа.
+type ConfigChange struct {
+	Name string
+	// Raw — закодированный документ; nil при удалении.
+	Raw     []byte
+	Deleted bool
+}
+
+type configDoc struct {
+	raw     []byte
+	version uint64
+}
+
+// ConfigStore — конфигурационные документы и feature-флаги под префиксом Store (по умолчанию "cfg:").
+// Документ кодируется кодеком ключа (Options.Codec или RegisterCodec для префикса), поэтому им может быть
+// JSON или proto. После Start чтения идут из локального кеша, а кеш обновляется по Watch:
+// изменения видны в течение долей секунды, без перезапуска и внешнего сервиса конфигураций.
+type ConfigStore struct {
+	store  *Store
+	prefix string
+
+	mu      sync.RWMutex
+	docs    map[string]configDoc
+	started bool
+	hooks   []configHook
+}
+
+type configHook struct {
+	name string
+	fn   func(ConfigChange)
+}
+
+func NewConfigStore(store *Store, prefix string) *ConfigStore {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if prefix == "" {
+		prefix = "cfg:"
+	}
+	return &ConfigStore{store: store, prefix: prefix, docs: make(map[string]configDoc)}
+}
+
+func (c *ConfigStore) key(name string) []byte {
+	return []byte(c.prefix + name)
+}
+
+// Set записывает документ name.
+func (c *ConfigStore) Set(ctx context.Context, name string, v any) error {
+	if name == "" {
+		return errors.New("empty config name")
+	}
+	k := c.key(name)
+	data, err := c.store.marshal(k, v)
+	if err != nil {
+		return err
+	}
+	return c.store.SetContext(ctx, k, data, 0)
+}
+
+// Delete удаляет документ name.
+func (c *ConfigStore) Delete(ctx context.Context, name string) error {
+	return c.store.DeleteContext(ctx, c.key(name))
+}
+
+// Get декодирует документ name в v: после Start — из кеша, до него — из Store. Нет документа — ErrNotFound.
+func (c *ConfigStore) Get(name string, v any) error {
+	k := c.key(name)
+	c.mu.RLock()
+	started := c.started
+	doc, ok := c.docs[name]
+	c.mu.RUnlock()
+	if !started {
+		return c.store.GetObject(k, v)
+	}
+	if !ok {
+		return ErrNotFound
+	}
+	return c.store.decode(k, doc.raw, v)
+}
+
+// GetTyped — Get в значение типа T.
+func GetTyped[T any](c *ConfigStore, name string) (T, error) {
+	var v T
+	err := c.Get(name, &v)
+	return v, err
+}
+
+// Names возвращает имена документов в кеше (после Start).
+func (c *ConfigStore) Names() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]string, 0, len(c.docs))
+	for name := range c.docs {
+		out = append(out, name)
+	}
+	return out
+}
+
+// OnChange подписывает fn на изменения документа name (пустое имя — всех документов).
+// fn вызывается последовательно из горутины подписки после обновления кеша.
+func (c *ConfigStore) OnChange(name string, fn func(ConfigChange)) {
+	if fn == nil {
+		panic("fn must be not nil")
+	}
+	c.mu.Lock()
+	c.hooks = append(c.hooks, configHook{name: name, fn: fn})
+	c.mu.Unlock()
+}
+
+// Start загружает все документы префикса в кеш и подписывается на изменения.
+// Возвращается, когда кеш заполнен; подписка работает до отмены ctx или Close.
+func (c *ConfigStore) Start(ctx context.Context) error {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return errors.New("config store already started")
+	}
+	c.mu.Unlock()
+
+	prefix := []byte(c.prefix)
+	marker := newSubscriptionMarker()
+	subscribed := make(chan struct{})
+	var once sync.Once
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(c.store.bg, cancel)
+	go func() {
+		defer stop()
+		defer cancel()
+		_ = c.store.Watch(watchCtx, [][]byte{prefix, marker}, func(events []KVEvent) error {
+			for _, e := range events {
+				if bytes.Equal(e.Key, marker) {
+					once.Do(func() { close(subscribed) })
+					continue
+				}
+				if bytes.HasPrefix(e.Key, prefix) {
+					c.refresh(string(e.Key[len(prefix):]))
+				}
+			}
+			return nil
+		})
+	}()
+
+	if err := c.store.awaitSubscription(ctx, marker, subscribed); err != nil {
+		cancel()
+		return err
+	}
+
+	docs := make(map[string]configDoc)
+	err := c.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if c.store.expired(item) {
+				continue
+			}
+			raw, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			docs[string(item.Key()[len(prefix):])] = configDoc{raw: raw, version: item.Version()}
+		}
+		return nil
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// события, пришедшие во время загрузки, уже в кеше и не старше снимка
+	for name, doc := range docs {
+		if cur, ok := c.docs[name]; !ok || cur.version < doc.version {
+			c.docs[name] = doc
+		}
+	}
+	c.started = true
+	return nil
+}
+
+// refresh перечитывает документ из Store: событие подписки не отличает удаление от пустого значения.
+func (c *ConfigStore) refresh(name string) {
+	var doc configDoc
+	var exists bool
+	_ = c.store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(c.key(name))
+		if err != nil || c.store.expired(item) {
+			return nil
+		}
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		doc, exists = configDoc{raw: raw, version: item.Version()}, true
+		return nil
+	})
+
+	c.mu.Lock()
+	cur, had := c.docs[name]
+	switch {
+	case exists && had && cur.version >= doc.version:
+		c.mu.Unlock()
+		return
+	case exists:
+		c.docs[name] = doc
+	case had:
+		delete(c.docs, name)
+	default:
+		c.mu.Unlock()
+		return
+	}
+	hooks := c.hooks
+	c.mu.Unlock()
+
+	change := ConfigChange{Name: name, Raw: doc.raw, Deleted: !exists}
+	for _, h := range hooks {
+		if h.name == "" || h.name == name {
+			h.fn(change)
+		}
+	}
+}
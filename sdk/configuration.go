@@ -1,8 +1,11 @@
 package sdk
 
 import (
+	"errors"
 	"fmt"
 	"time"
+
+	"github.com/dgraph-io/badger/v4/options"
 )
 
 type LogLevel string
@@ -21,6 +24,12 @@ const (
 )
 
 type Options struct {
+	// StoreName — имя хранилища, если в процессе открыто несколько Store: метка store в WriteMetrics,
+	// тег строки мониторинга и ключ в expvar "memory_storage" (Store.Vars). Должно быть уникально в
+	// процессе, иначе Open вернёт ErrStoreNameInUse. Пусто — без имени. Производные Store получают
+	// свои имена: шарды OpenSharded — StoreName/shard-N, реплика LocalLeaderElector — StoreName/replica.
+	StoreName string
+
 	// Dir — каталог для LSM-части (SST, MANIFEST). Должен существовать или будет создан.
 	// При InMemory=true игнорируется.
 	Dir string
@@ -44,6 +53,12 @@ type Options struct {
 	// GCInterval — периодический запуск value-log GC (если вы это делаете сами через s.runGC).
 	// Badger сам GC «по таймеру» не запускает; эту периодику задаёте вы.
 	GCInterval time.Duration
+	// GCDiscardRatio — доля мусора в файле value-log, начиная с которой GC его переписывает; 0 — 0.5.
+	// Меньше — чаще переписывает и быстрее возвращает место ценой записи на диск.
+	GCDiscardRatio float64
+	// GCAdaptive — подстраивать период GC под рост value-log и оценку мусора; nil — фиксированный
+	// GCInterval. Итоги запусков — Store.GCStats.
+	GCAdaptive *GCAdaptiveOptions
 
 	// SyncWrites — fsync на каждую запись (жертвуем скоростью ради максимальной надёжности).
 	// false обычно быстрее, но возможна потеря последних записей при сбое питания/процесса.
@@ -80,6 +95,11 @@ type Options struct {
 	// ≈ NumMemtables * MemTableSize.
 	NumMemtables int
 
+	// MemoryPressure — ужимать блочный и индексный кеши, когда память процесса подходит к пределу
+	// (GOMEMLIMIT или MemoryPressureOptions.Limit), и возвращать их размер, когда давление спадает.
+	// nil — размеры кешей постоянны.
+	MemoryPressure *MemoryPressureOptions
+
 	// ------------------- ПРОЧИЕ ПАРАМЕТРЫ ХРАНЕНИЯ -------------------
 
 	// ValueThreshold — порог (байты), выше которого значение кладётся в value log,
@@ -87,6 +107,10 @@ type Options struct {
 	// Выше порог — меньше LSM, больше vlog I/O. Ниже порог — больше LSM, меньше vlog.
 	ValueThreshold int64
 
+	// AutoValueThreshold — при ValueThreshold == 0 взять порог, сохранённый в Dir через
+	// SaveValueThreshold (обычно рекомендация ValueThresholdReport). Нет сохранённого — умолчание Badger.
+	AutoValueThreshold bool
+
 	// ValueLogFileSize — максимальный размер одиночного файла value log (байты), после чего
 	// Badger начинает новый vlog-файл. Влияет на частоту GC и количество открытых файлов.
 	ValueLogFileSize int64
@@ -99,10 +123,32 @@ type Options struct {
 	// но выше конкуренция за I/O и память.
 	NumCompactors int
 
+	// Compression — сжатие блоков SST: CompressionNone, CompressionSnappy или CompressionZSTD.
+	// Пусто — Snappy (умолчание Badger), либо ZSTD, если задан ZSTDCompressionLevel.
+	// Snappy дешевле по CPU, ZSTD лучше сжимает. Смена не требует миграции: уже записанные таблицы
+	// читаются со своим сжатием, новое применяется к новым таблицам при флашах и компакциях.
+	Compression CompressionType
+
 	// ZSTDCompressionLevel — уровень ZSTD (0 — по умолчанию; <0 — быстрее/хуже; >0 — медленнее/лучше).
-	// Влияет на место на диске и CPU-времена (чтение/запись/компакции).
+	// Влияет на место на диске и CPU-времена (чтение/запись/компакции). Только для ZSTD:
+	// вместе с другим Compression — ошибка Open.
 	ZSTDCompressionLevel int
 
+	// BloomFalsePositive — доля ложных срабатываний bloom-фильтра таблиц, (0, 1); 0 — 0.01.
+	// Меньше — меньше лишних чтений таблиц на промахах, но больше фильтры в памяти (IndexCacheSize).
+	BloomFalsePositive float64
+
+	// NumLevelZeroTables — число таблиц L0, после которого начинается их компакция (0 — 5);
+	// NumLevelZeroTablesStall — после которого записи останавливаются до компакции (0 — 15),
+	// должно быть больше NumLevelZeroTables. Чем больше таблиц в L0, тем больше таблиц читает Get.
+	NumLevelZeroTables      int
+	NumLevelZeroTablesStall int
+
+	// LevelSizeMultiplier — во сколько раз каждый уровень LSM больше предыдущего (0 — 10, минимум 2);
+	// TableSizeMultiplier — во сколько раз растёт размер таблиц от уровня к уровню (0 — 2, минимум 1).
+	LevelSizeMultiplier int
+	TableSizeMultiplier int
+
 	// DetectConflicts — детект конфликтов транзакций (write-write). true — безопаснее, но дороже.
 	// Можно отключать при полном внешнем контроле параллелизма/последовательности.
 	DetectConflicts bool
@@ -113,6 +159,152 @@ type Options struct {
 
 	// Codec - маршалер для сериализации/десериализации объектов
 	Codec Codec
+
+	// SelfTestOnOpen — после открытия проверить запись, чтение, удаление и скан пробного ключа,
+	// расшифровку данных прошлых запусков и круговой прогон Codec. При ошибке Open закрывает БД и
+	// возвращает *SelfTestError с шагом, на котором проверка упала. Ключи проверки — под "!selftest:".
+	SelfTestOnOpen bool
+
+	// QuarantineDecodeErrors — карантин для записей, которые не удалось декодировать при сканах объектов.
+	// Если задан, ScanPrefixObjects передаёт ему *DecodeError и продолжает скан вместо того, чтобы прерваться.
+	// На GetObject/TxGetObject не влияет: там ошибка возвращается вызывающему.
+	QuarantineDecodeErrors func(err *DecodeError)
+
+	// ExpiryCheckInterval — период обработчика уведомлений об истечении (OnExpire), по умолчанию 1s.
+	// Уведомление приходит с задержкой до одного периода после срока.
+	ExpiryCheckInterval time.Duration
+
+	// AuditActor — кто выполняет административные операции (DropPrefix, DropAll, восстановление из бэкапа),
+	// если в контексте операции нет WithAuditActor и WithPrincipal. Обычно имя сервиса или хоста.
+	AuditActor string
+
+	// AccessController — проверка прав на Get/Set/Delete/сканы по principal из контекста (WithPrincipal).
+	// nil — без проверки. Готовая реализация по правилам из YAML — StaticAccessRules.
+	AccessController AccessController
+
+	// OnTx вызывается после каждой транзакции Manager (ExecuteReadWriteWithContext, RunTx) с её трассой:
+	// длительность, число попыток, ошибка и метаданные запроса из контекста (WithRequestID, WithPrincipal).
+	OnTx func(OpTrace)
+
+	// SlowOpThreshold — SetContext, GetContext, DeleteContext, ScanPrefixContext (и их варианты без контекста)
+	// и транзакции Manager дольше порога передаются в OnSlowOp.
+	// 0 — не отслеживать.
+	SlowOpThreshold time.Duration
+	// OnSlowOp — обработчик медленных операций; nil — запись в стандартный log.
+	OnSlowOp func(OpTrace)
+
+	// VersionedObjects — хранить объекты SetObject/TxSetObject в конверте с версией и временем изменения
+	// (ObjectMeta): версия растёт при каждой записи и доступна через GetObjectWithMeta, а
+	// SetObjectIfVersion и UpdateObject дают оптимистичную блокировку (ETag). Запись объекта при этом
+	// читает текущую версию. GetObject и сканы объектов снимают конверт сами, Get отдаёт его как есть.
+	// Значения, записанные без конверта, читаются как версия 0.
+	VersionedObjects bool
+
+	// ProtoSchemaGuard — проверка совместимости схемы для значений, декодируемых в proto.Message.
+	// nil — без проверки.
+	ProtoSchemaGuard *ProtoSchemaGuard
+
+	// Clock — источник времени для TTL записей, цикла GC, расписания бэкапов и пауз между ретраями транзакций.
+	// nil — реальное время. TTL считается от Clock.Now(), и чтения через Store (Get, сканы, TxGetObject)
+	// считают запись истёкшей по этим же часам; собственная проверка Badger идёт по реальному времени.
+	Clock Clock
+
+	// TTLJitter — случайный разброс TTL записей (Set, SetObject, SetWithTTL, WriteBatch, RunTx) в долях:
+	// 0.1 — каждый TTL сдвигается в пределах ±10%, чтобы ключи одной пачки не истекали в одну секунду.
+	// Отдельным записям разброс задаёт WithTTLJitter. Фактический срок — GetWithMeta. 0 — без разброса.
+	TTLJitter float64
+
+	// WriteRateLimit — лимит записей (Set, SetObject, Delete, GetAndDelete) в операциях в секунду.
+	// Операция ждёт своей очереди до начала транзакции; *Context-варианты прерывают ожидание по ctx.
+	// Защищает общий диск от массовых фоновых записей без правок в местах вызова.
+	WriteRateLimit RateLimit
+
+	// MetricsTextfile — периодически писать метрики Store (и, по желанию, счётчики ключей по префиксам)
+	// в файл для textfile-коллектора node_exporter. nil — не писать.
+	MetricsTextfile *MetricsTextfileOptions
+
+	// ReadTxnGuard — следить за возрастом транзакций чтения Store (сканы ScanPrefix*, View) и сообщать
+	// о слишком долгих или прерывать их: долгая транзакция чтения не даёт GC value-log вернуть место.
+	// nil — без слежения.
+	ReadTxnGuard *ReadTxnGuardOptions
+
+	// KeyValidator — проверять имена ключей при записи (Set, SetObject, WriteBatch, RunTx) по шаблону
+	// или функции и отклонять не соответствующие с ErrInvalidKey. Миграции обходят проверку через
+	// WithoutKeyValidation. nil — без проверки.
+	KeyValidator *KeyValidatorOptions
+
+	// ScanRateLimit — лимит сканов в записях в секунду: каждая прочитанная запись префикса занимает
+	// одну операцию. Ожидание идёт внутри транзакции чтения, поэтому медленный скан дольше держит её открытой.
+	ScanRateLimit RateLimit
+}
+
+// CompressionType — алгоритм сжатия блоков SST для Options.Compression.
+type CompressionType string
+
+const (
+	CompressionNone   CompressionType = "none"
+	CompressionSnappy CompressionType = "snappy"
+	CompressionZSTD   CompressionType = "zstd"
+)
+
+// badgerCompression — тип сжатия Badger для Options; ok=false — оставить умолчание Badger.
+func (o Options) badgerCompression() (c options.CompressionType, ok bool, err error) {
+	switch o.Compression {
+	case "":
+		if o.ZSTDCompressionLevel != 0 {
+			return options.ZSTD, true, nil
+		}
+		return 0, false, nil
+	case CompressionNone:
+		c = options.None
+	case CompressionSnappy:
+		c = options.Snappy
+	case CompressionZSTD:
+		return options.ZSTD, true, nil
+	default:
+		return 0, false, fmt.Errorf("unknown Options.Compression %q", o.Compression)
+	}
+	if o.ZSTDCompressionLevel != 0 {
+		return 0, false, fmt.Errorf("Options.ZSTDCompressionLevel requires zstd compression, got %q", o.Compression)
+	}
+	return c, true, nil
+}
+
+// validateTuning проверяет параметры LSM до открытия Badger: часть неверных значений Badger
+// принимает молча и ломается позже (например, остановка записей при Stall <= NumLevelZeroTables).
+func (o Options) validateTuning() error {
+	if _, _, err := o.badgerCompression(); err != nil {
+		return err
+	}
+	if o.BloomFalsePositive < 0 || o.BloomFalsePositive >= 1 {
+		return fmt.Errorf("Options.BloomFalsePositive must be in (0, 1), got %v", o.BloomFalsePositive)
+	}
+	if o.NumLevelZeroTables < 0 || o.NumLevelZeroTablesStall < 0 {
+		return errors.New("Options.NumLevelZeroTables and NumLevelZeroTablesStall must not be negative")
+	}
+	l0, stall := o.NumLevelZeroTables, o.NumLevelZeroTablesStall
+	if l0 == 0 {
+		l0 = 5
+	}
+	if stall == 0 {
+		stall = 15
+	}
+	if stall <= l0 {
+		return fmt.Errorf("Options.NumLevelZeroTablesStall (%d) must be greater than NumLevelZeroTables (%d)", stall, l0)
+	}
+	if o.LevelSizeMultiplier != 0 && o.LevelSizeMultiplier < 2 {
+		return fmt.Errorf("Options.LevelSizeMultiplier must be at least 2, got %d", o.LevelSizeMultiplier)
+	}
+	if o.GCDiscardRatio < 0 || o.GCDiscardRatio >= 1 {
+		return fmt.Errorf("Options.GCDiscardRatio must be in (0, 1), got %v", o.GCDiscardRatio)
+	}
+	if o.TTLJitter < 0 || o.TTLJitter >= 1 {
+		return fmt.Errorf("Options.TTLJitter must be in [0, 1), got %v", o.TTLJitter)
+	}
+	if o.TableSizeMultiplier < 0 {
+		return fmt.Errorf("Options.TableSizeMultiplier must be at least 1, got %d", o.TableSizeMultiplier)
+	}
+	return nil
 }
 
 // ComputeMemoryLimit вычисляет разумные значения для кешей и memtables по переданнуму лимиту памяти
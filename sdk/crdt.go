@@ -0,0 +1,371 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// CRDT-значения для синхронизации нескольких писателей. При anti-entropy конфликт одного ключа
+// решается по last-write-wins, и два узла, одновременно увеличившие счётчик, теряют одно из
+// увеличений. Значения CRDT хранят состояние всех реплик и сливаются функцией слияния, которая
+// коммутативна, ассоциативна и идемпотентна: после обмена оба узла получают одно и то же значение
+// независимо от порядка. Функции слияния назначаются префиксам через RegisterSyncMerge — одинаково
+// на всех узлах; ключи под таким префиксом SyncWith отдаёт пиру и забирает у него, а Apply сливает
+// их с локальной копией вместо перезаписи.
+//
+// Значения кодируются в JSON с сортированными ключами — одинаковое состояние даёт одинаковые байты,
+// поэтому деревья дайджестов узлов сходятся. Меняйте их через UpdateCRDT: он читает, изменяет и
+// записывает значение в одной транзакции.
+
+// SyncMergeFunc сливает локальное и пришедшее значение ключа. Должна быть детерминированной,
+// коммутативной, ассоциативной и идемпотентной.
+type SyncMergeFunc func(key, local, remote []byte) ([]byte, error)
+
+type prefixSyncMerge struct {
+	prefix []byte
+	merge  SyncMergeFunc
+}
+
+// RegisterSyncMerge назначает merge ключам с префиксом prefix (самый длинный совпавший префикс).
+// Повторная регистрация того же префикса заменяет функцию. Готовые функции — MergeGCounter,
+// MergePNCounter, MergeLWWRegister и MergeORSet.
+func (s *Store) RegisterSyncMerge(prefix []byte, merge SyncMergeFunc) {
+	if merge == nil {
+		panic("merge must be not nil")
+	}
+	s.syncMu.Lock()
+	defer s.syncMu.Unlock()
+	merges := make([]prefixSyncMerge, 0, len(s.syncMerges)+1)
+	for _, m := range s.syncMerges {
+		if !bytes.Equal(m.prefix, prefix) {
+			merges = append(merges, m)
+		}
+	}
+	merges = append(merges, prefixSyncMerge{prefix: bytes.Clone(prefix), merge: merge})
+	sort.SliceStable(merges, func(i, j int) bool {
+		return len(merges[i].prefix) > len(merges[j].prefix)
+	})
+	s.syncMerges = merges
+}
+
+// syncMergeFor возвращает функцию слияния ключа или nil.
+func (s *Store) syncMergeFor(key []byte) SyncMergeFunc {
+	s.syncMu.RLock()
+	defer s.syncMu.RUnlock()
+	for _, m := range s.syncMerges {
+		if bytes.HasPrefix(key, m.prefix) {
+			return m.merge
+		}
+	}
+	return nil
+}
+
+// CRDT — значение, которое UpdateCRDT читает и записывает. UnmarshalBinary(nil) сбрасывает значение в пустое.
+type CRDT interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// UpdateCRDT читает значение key в v (пустое, если ключа нет), вызывает fn и записывает v в одной
+// транзакции; при конфликте транзакция повторяется, поэтому fn не должна иметь внешних побочных
+// эффектов. TTL ключа сохраняется.
+func (s *Store) UpdateCRDT(ctx context.Context, key []byte, v CRDT, fn func() error) error {
+	if err := s.checkAccess(ctx, AccessWrite, key); err != nil {
+		return err
+	}
+	if err := s.validateKey(ctx, key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	s.noteWrite(key)
+	return NewTransactionManager(s).ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		var raw []byte
+		var expiresAt uint64
+		item, err := txn.Get(key)
+		switch {
+		case err == nil && !s.expired(item):
+			if raw, err = item.ValueCopy(nil); err != nil {
+				return err
+			}
+			expiresAt = item.ExpiresAt()
+		case err != nil && !errors.Is(err, badger.ErrKeyNotFound):
+			return err
+		}
+		if err := v.UnmarshalBinary(raw); err != nil {
+			return fmt.Errorf("crdt %q: %w", key, err)
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		data, err := v.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		e := badger.NewEntry(key, data)
+		e.ExpiresAt = expiresAt
+		return txn.SetEntry(e)
+	})
+}
+
+// unmarshalCRDT разбирает JSON-состояние; пустое значение — пустое состояние.
+func unmarshalCRDT(data []byte, v any) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// GCounter — счётчик, который только растёт: у каждой реплики своя доля, значение — их сумма.
+type GCounter struct {
+	Counts map[string]uint64 `json:"c,omitempty"`
+}
+
+// Add увеличивает долю реплики replica на n.
+func (c *GCounter) Add(replica string, n uint64) {
+	if n == 0 {
+		return
+	}
+	if c.Counts == nil {
+		c.Counts = make(map[string]uint64)
+	}
+	c.Counts[replica] += n
+}
+
+// Value — сумма долей всех реплик.
+func (c *GCounter) Value() uint64 {
+	var sum uint64
+	for _, n := range c.Counts {
+		sum += n
+	}
+	return sum
+}
+
+// Merge берёт наибольшую долю каждой реплики.
+func (c *GCounter) Merge(o GCounter) {
+	for r, n := range o.Counts {
+		if cur, ok := c.Counts[r]; !ok || n > cur {
+			if c.Counts == nil {
+				c.Counts = make(map[string]uint64)
+			}
+			c.Counts[r] = n
+		}
+	}
+}
+
+func (c *GCounter) MarshalBinary() ([]byte, error) { return json.Marshal(c) }
+
+func (c *GCounter) UnmarshalBinary(data []byte) error {
+	*c = GCounter{}
+	return unmarshalCRDT(data, c)
+}
+
+// PNCounter — счётчик с увеличением и уменьшением: пара GCounter прибавлений и вычитаний.
+type PNCounter struct {
+	P GCounter `json:"p"`
+	N GCounter `json:"n"`
+}
+
+// Add прибавляет delta (может быть отрицательным) от имени реплики replica.
+func (c *PNCounter) Add(replica string, delta int64) {
+	if delta >= 0 {
+		c.P.Add(replica, uint64(delta))
+	} else {
+		c.N.Add(replica, uint64(-delta))
+	}
+}
+
+func (c *PNCounter) Value() int64 {
+	return int64(c.P.Value() - c.N.Value())
+}
+
+func (c *PNCounter) Merge(o PNCounter) {
+	c.P.Merge(o.P)
+	c.N.Merge(o.N)
+}
+
+func (c *PNCounter) MarshalBinary() ([]byte, error) { return json.Marshal(c) }
+
+func (c *PNCounter) UnmarshalBinary(data []byte) error {
+	*c = PNCounter{}
+	return unmarshalCRDT(data, c)
+}
+
+// LWWRegister — значение, где побеждает запись с большей меткой Timestamp; при равных метках —
+// большая Replica, затем большее Value, чтобы выбор не зависел от порядка слияния.
+type LWWRegister struct {
+	Value     []byte `json:"v"`
+	Timestamp uint64 `json:"t"`
+	Replica   string `json:"r"`
+}
+
+// Set записывает value, если (timestamp, replica) новее текущей записи; возвращает, записано ли.
+func (r *LWWRegister) Set(value []byte, timestamp uint64, replica string) bool {
+	return r.Merge(LWWRegister{Value: value, Timestamp: timestamp, Replica: replica})
+}
+
+// Merge оставляет победившую из двух записей; возвращает, взята ли o.
+func (r *LWWRegister) Merge(o LWWRegister) bool {
+	if o.Timestamp != r.Timestamp {
+		if o.Timestamp < r.Timestamp {
+			return false
+		}
+	} else if o.Replica != r.Replica {
+		if o.Replica < r.Replica {
+			return false
+		}
+	} else if bytes.Compare(o.Value, r.Value) <= 0 {
+		return false
+	}
+	*r = LWWRegister{Value: bytes.Clone(o.Value), Timestamp: o.Timestamp, Replica: o.Replica}
+	return true
+}
+
+func (r *LWWRegister) MarshalBinary() ([]byte, error) { return json.Marshal(r) }
+
+func (r *LWWRegister) UnmarshalBinary(data []byte) error {
+	*r = LWWRegister{}
+	return unmarshalCRDT(data, r)
+}
+
+// ORSet — множество строк с добавлением и удалением (observed-remove): удаление снимает только те
+// добавления, которые видела удаляющая реплика, поэтому одновременное добавление побеждает.
+// Каждое добавление получает уникальную метку "реплика:номер"; удалённые метки хранятся как
+// надгробия, и множество растёт с числом удалений.
+type ORSet struct {
+	// Clock — последний номер метки каждой реплики.
+	Clock map[string]uint64 `json:"clock,omitempty"`
+	// Adds — элемент → живые метки добавлений (по возрастанию).
+	Adds map[string][]string `json:"adds,omitempty"`
+	// Tombstones — метки удалённых добавлений (по возрастанию).
+	Tombstones []string `json:"tombstones,omitempty"`
+}
+
+// Add добавляет elem от имени реплики replica.
+func (s *ORSet) Add(replica, elem string) {
+	if s.Clock == nil {
+		s.Clock = make(map[string]uint64)
+	}
+	if s.Adds == nil {
+		s.Adds = make(map[string][]string)
+	}
+	s.Clock[replica]++
+	tag := replica + ":" + strconv.FormatUint(s.Clock[replica], 10)
+	s.Adds[elem] = insertSorted(s.Adds[elem], tag)
+}
+
+// Remove удаляет elem, если он есть; возвращает, был ли он.
+func (s *ORSet) Remove(elem string) bool {
+	tags, ok := s.Adds[elem]
+	if !ok {
+		return false
+	}
+	for _, t := range tags {
+		s.Tombstones = insertSorted(s.Tombstones, t)
+	}
+	delete(s.Adds, elem)
+	return true
+}
+
+func (s *ORSet) Contains(elem string) bool {
+	_, ok := s.Adds[elem]
+	return ok
+}
+
+// Elements возвращает элементы по возрастанию.
+func (s *ORSet) Elements() []string {
+	out := make([]string, 0, len(s.Adds))
+	for e := range s.Adds {
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Merge объединяет добавления и надгробия обеих сторон.
+func (s *ORSet) Merge(o ORSet) {
+	for r, n := range o.Clock {
+		if cur, ok := s.Clock[r]; !ok || n > cur {
+			if s.Clock == nil {
+				s.Clock = make(map[string]uint64)
+			}
+			s.Clock[r] = n
+		}
+	}
+	for _, t := range o.Tombstones {
+		s.Tombstones = insertSorted(s.Tombstones, t)
+	}
+	for e, tags := range o.Adds {
+		if s.Adds == nil {
+			s.Adds = make(map[string][]string)
+		}
+		for _, t := range tags {
+			s.Adds[e] = insertSorted(s.Adds[e], t)
+		}
+	}
+	for e, tags := range s.Adds {
+		live := tags[:0]
+		for _, t := range tags {
+			if _, dead := slices.BinarySearch(s.Tombstones, t); !dead {
+				live = append(live, t)
+			}
+		}
+		if len(live) == 0 {
+			delete(s.Adds, e)
+		} else {
+			s.Adds[e] = live
+		}
+	}
+}
+
+func (s *ORSet) MarshalBinary() ([]byte, error) { return json.Marshal(s) }
+
+func (s *ORSet) UnmarshalBinary(data []byte) error {
+	*s = ORSet{}
+	return unmarshalCRDT(data, s)
+}
+
+// insertSorted вставляет v в отсортированный срез без повторов.
+func insertSorted(xs []string, v string) []string {
+	i, found := slices.BinarySearch(xs, v)
+	if found {
+		return xs
+	}
+	return slices.Insert(xs, i, v)
+}
+
+// crdtMerge — SyncMergeFunc для типа T с методом Merge.
+func crdtMerge[T any, P interface {
+	*T
+	CRDT
+}](merge func(dst P, src T)) SyncMergeFunc {
+	return func(key, local, remote []byte) ([]byte, error) {
+		var l, r T
+		if err := P(&l).UnmarshalBinary(local); err != nil {
+			return nil, fmt.Errorf("crdt %q: local: %w", key, err)
+		}
+		if err := P(&r).UnmarshalBinary(remote); err != nil {
+			return nil, fmt.Errorf("crdt %q: remote: %w", key, err)
+		}
+		merge(&l, r)
+		return P(&l).MarshalBinary()
+	}
+}
+
+// Функции слияния CRDT для RegisterSyncMerge.
+var (
+	MergeGCounter    = crdtMerge(func(dst *GCounter, src GCounter) { dst.Merge(src) })
+	MergePNCounter   = crdtMerge(func(dst *PNCounter, src PNCounter) { dst.Merge(src) })
+	MergeLWWRegister = crdtMerge(func(dst *LWWRegister, src LWWRegister) { dst.Merge(src) })
+	MergeORSet       = crdtMerge(func(dst *ORSet, src ORSet) { dst.Merge(src) })
+)
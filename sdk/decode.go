@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+)
+
+// DecodeError — ошибка декодирования значения с сохранённым контекстом:
+// ключ, сырые байты и кодек, которым пытались декодировать.
+type DecodeError struct {
+	Key   []byte
+	Raw   []byte
+	Codec string
+	Err   error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode %q with %s: %v", e.Key, e.Codec, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// decode — единая точка декодирования значений Store; ошибки оборачиваются в *DecodeError.
+// key и raw копируются: raw может принадлежать транзакции Badger.
+func (s *Store) decode(key, raw []byte, v any) error {
+	if s.versioned {
+		_, raw = unwrapObject(raw)
+	}
+	codec := s.codecFor(key)
+	if err := codec.Unmarshal(raw, v); err != nil {
+		return decodeError(key, raw, codec, err)
+	}
+	if s.guard != nil {
+		if m, ok := v.(proto.Message); ok {
+			if mismatch := s.guard.check(key, raw, m); mismatch != nil {
+				if s.guard.Handler != nil {
+					s.guard.Handler(mismatch)
+				}
+				if s.guard.Reject {
+					return decodeError(key, raw, codec, ErrSchemaMismatch)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeValue декодирует сырое значение ключа так же, как GetObject: кодеком префикса,
+// со снятием конверта VersionedObjects и проверкой ProtoSchemaGuard. Для значений, прочитанных
+// Get или сканами. Ошибка — *DecodeError.
+func (s *Store) DecodeValue(key, raw []byte, v any) error {
+	return s.decode(key, raw, v)
+}
+
+func decodeError(key, raw []byte, codec Codec, err error) *DecodeError {
+	return &DecodeError{
+		Key:   append([]byte(nil), key...),
+		Raw:   append([]byte(nil), raw...),
+		Codec: fmt.Sprintf("%T", codec),
+		Err:   err,
+	}
+}
+
+// ScanPrefixObjects — скан префикса с декодированием значений в *T кодеком хранилища.
+// Недекодируемая запись прерывает скан с *DecodeError, либо, если задан Options.QuarantineDecodeErrors,
+// передаётся в карантин и пропускается. limit <= 0 — без лимита (считаются только декодированные записи).
+func ScanPrefixObjects[T any](s *Store, prefix []byte, limit int, fn func(key []byte, v *T) error) error {
+	if err := s.checkAccess(context.Background(), AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(context.Background(), "scan_objects")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		count := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			key := item.KeyCopy(nil)
+			v := new(T)
+			err := item.Value(func(val []byte) error {
+				return s.decode(key, val, v)
+			})
+			if err != nil {
+				derr, ok := err.(*DecodeError)
+				if !ok || s.quarantine == nil {
+					return err
+				}
+				s.quarantine(derr)
+				continue
+			}
+			if err := fn(key, v); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+}
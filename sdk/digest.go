@@ -0,0 +1,140 @@
+package sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// maxDigestLeaves — верхняя граница числа листьев дерева дайджестов; глубина подбирается под fanout.
+const maxDigestLeaves = 4096
+
+// DigestTree — Merkle-подобное дерево дайджестов записей префикса.
+// Ключ попадает в лист по хешу ключа (не по значению), дайджест листа — XOR хешей
+// (ключ, значение, срок жизни) его записей, дайджест узла — XOR дайджестов детей.
+// XOR позволяет обновлять дерево инкрементально: запись добавляется и убирается одной операцией.
+type DigestTree struct {
+	Fanout int
+	Depth  int
+	// Levels[0] — корень (один элемент), Levels[Depth] — листья (Fanout^Depth элементов).
+	Levels [][]uint64
+}
+
+// digestDepth — наибольшая глубина, при которой листьев не больше maxDigestLeaves.
+func digestDepth(fanout int) int {
+	depth, leaves := 1, fanout
+	for leaves*fanout <= maxDigestLeaves {
+		leaves *= fanout
+		depth++
+	}
+	return depth
+}
+
+func newDigestTree(fanout int) (*DigestTree, error) {
+	if fanout < 2 || fanout > maxDigestLeaves {
+		return nil, fmt.Errorf("digest fanout must be in [2, %d]", maxDigestLeaves)
+	}
+	t := &DigestTree{Fanout: fanout, Depth: digestDepth(fanout)}
+	t.Levels = make([][]uint64, t.Depth+1)
+	width := 1
+	for i := range t.Levels {
+		t.Levels[i] = make([]uint64, width)
+		width *= fanout
+	}
+	return t, nil
+}
+
+// Root — дайджест всего префикса; равные корни означают (с точностью до коллизий) равные данные.
+func (t *DigestTree) Root() uint64 {
+	return t.Levels[0][0]
+}
+
+// Clone возвращает независимую копию дерева.
+func (t *DigestTree) Clone() *DigestTree {
+	c := &DigestTree{Fanout: t.Fanout, Depth: t.Depth, Levels: make([][]uint64, len(t.Levels))}
+	for i, level := range t.Levels {
+		c.Levels[i] = append([]uint64(nil), level...)
+	}
+	return c
+}
+
+// Leaves — число листьев.
+func (t *DigestTree) Leaves() int {
+	return len(t.Levels[t.Depth])
+}
+
+// LeafOf — лист, в который попадает key.
+func (t *DigestTree) LeafOf(key []byte) int {
+	return int(hash64(key) % uint64(t.Leaves()))
+}
+
+// toggle добавляет (или, повторно, убирает) хеш записи в лист и всех его предков.
+func (t *DigestTree) toggle(leaf int, h uint64) {
+	for level := t.Depth; level >= 0; level-- {
+		t.Levels[level][leaf] ^= h
+		leaf /= t.Fanout
+	}
+}
+
+// DiffLeaves возвращает листья, дайджесты которых различаются, спускаясь только в различающиеся узлы.
+func (t *DigestTree) DiffLeaves(other *DigestTree) ([]int, error) {
+	if other == nil || t.Fanout != other.Fanout || t.Depth != other.Depth {
+		return nil, fmt.Errorf("incompatible digest trees")
+	}
+	nodes := []int{0}
+	for level := 0; level < t.Depth; level++ {
+		next := make([]int, 0, len(nodes)*t.Fanout)
+		for _, n := range nodes {
+			if t.Levels[level][n] == other.Levels[level][n] {
+				continue
+			}
+			for c := n * t.Fanout; c < (n+1)*t.Fanout; c++ {
+				next = append(next, c)
+			}
+		}
+		nodes = next
+	}
+	out := nodes[:0]
+	for _, n := range nodes {
+		if t.Levels[t.Depth][n] != other.Levels[t.Depth][n] {
+			out = append(out, n)
+		}
+	}
+	return out, nil
+}
+
+// entryDigest — хеш записи для дерева дайджестов.
+func entryDigest(key, value []byte, expiresAt uint64) uint64 {
+	var exp [8]byte
+	binary.BigEndian.PutUint64(exp[:], expiresAt)
+	return hash64(key, value, exp[:])
+}
+
+// scanDigest обходит живые записи префикса и передаёт хеш каждой в fn.
+func (s *Store) scanDigest(ctx context.Context, prefix []byte, fn func(key []byte, h, expiresAt uint64)) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			key := item.Key()
+			if err := item.Value(func(val []byte) error {
+				fn(key, entryDigest(key, val, item.ExpiresAt()), item.ExpiresAt())
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
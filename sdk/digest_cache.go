@@ -0,0 +1,229 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+type digestCacheKey struct {
+	prefix string
+	fanout int
+}
+
+type digestEntry struct {
+	hash      uint64
+	expiresAt uint64
+}
+
+// cachedDigest — дерево дайджестов префикса, поддерживаемое по подписке на изменения.
+// Кроме дерева хранит хеш каждой записи (чтобы убрать старую версию при изменении),
+// поэтому память растёт линейно с числом ключей префикса.
+type cachedDigest struct {
+	prefix []byte
+	ready  chan struct{}
+	err    error
+	cancel context.CancelFunc
+
+	mu         sync.Mutex
+	tree       *DigestTree
+	entries    map[string]digestEntry
+	nextExpiry uint64   // ближайший срок истечения среди entries, 0 — нет записей с TTL
+	pending    [][]byte // ключи, изменившиеся во время первичного построения
+	built      bool
+}
+
+// PrefixDigest возвращает дерево дайджестов префикса (копию, её можно менять).
+// Первый вызов для пары (prefix, fanout) строит дерево полным сканом и подписывается на изменения;
+// дальше дерево обновляется инкрементально и вызов стоит O(размер дерева).
+// Сравнение Root() двух вызовов — дешёвая проверка «изменилось ли что-то под префиксом».
+// Поддержку можно остановить через DropPrefixDigest.
+func (s *Store) PrefixDigest(ctx context.Context, prefix []byte, fanout int) (*DigestTree, error) {
+	if _, err := newDigestTree(fanout); err != nil {
+		return nil, err
+	}
+	key := digestCacheKey{prefix: string(prefix), fanout: fanout}
+
+	s.digestsMu.Lock()
+	c, ok := s.digests[key]
+	if !ok {
+		c = &cachedDigest{prefix: append([]byte(nil), prefix...), ready: make(chan struct{})}
+		if s.digests == nil {
+			s.digests = make(map[digestCacheKey]*cachedDigest)
+		}
+		s.digests[key] = c
+	}
+	s.digestsMu.Unlock()
+
+	if !ok {
+		c.err = s.startDigest(ctx, c, fanout)
+		if c.err != nil {
+			s.digestsMu.Lock()
+			delete(s.digests, key)
+			s.digestsMu.Unlock()
+		}
+		close(c.ready)
+	}
+
+	select {
+	case <-c.ready:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sweepExpiredLocked(uint64(s.clock.Now().Unix()))
+	return c.tree.Clone(), nil
+}
+
+// DropPrefixDigest прекращает поддержку дерева (prefix, fanout) и освобождает его память.
+func (s *Store) DropPrefixDigest(prefix []byte, fanout int) {
+	key := digestCacheKey{prefix: string(prefix), fanout: fanout}
+	s.digestsMu.Lock()
+	c, ok := s.digests[key]
+	delete(s.digests, key)
+	s.digestsMu.Unlock()
+	if ok {
+		<-c.ready
+		if c.cancel != nil {
+			c.cancel()
+		}
+	}
+}
+
+func (s *Store) startDigest(ctx context.Context, c *cachedDigest, fanout int) error {
+	tree, err := newDigestTree(fanout)
+	if err != nil {
+		return err
+	}
+	c.tree = tree
+	c.entries = make(map[string]digestEntry)
+
+	marker := newSubscriptionMarker()
+	subscribed := make(chan struct{})
+	var once sync.Once
+
+	watchCtx, cancel := context.WithCancel(s.bg)
+	c.cancel = cancel
+	go func() {
+		_ = s.Watch(watchCtx, [][]byte{c.prefix, marker}, func(events []KVEvent) error {
+			for _, e := range events {
+				if bytes.Equal(e.Key, marker) {
+					once.Do(func() { close(subscribed) })
+					continue
+				}
+				if bytes.HasPrefix(e.Key, subscriptionMarkerPrefix) || !bytes.HasPrefix(e.Key, c.prefix) || hiddenSystemKey(c.prefix, e.Key) {
+					continue
+				}
+				s.digestChanged(c, e.Key)
+			}
+			return nil
+		})
+	}()
+
+	// снимок — только после того, как подписка заработала: так изменения между ними не теряются
+	if err := s.awaitSubscription(ctx, marker, subscribed); err != nil {
+		cancel()
+		return err
+	}
+
+	err = s.scanDigest(ctx, c.prefix, func(key []byte, h, expiresAt uint64) {
+		if bytes.HasPrefix(key, subscriptionMarkerPrefix) {
+			return
+		}
+		c.mu.Lock()
+		c.putLocked(key, digestEntry{hash: h, expiresAt: expiresAt})
+		c.mu.Unlock()
+	})
+	if err != nil {
+		cancel()
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.built = true
+	for _, key := range c.pending {
+		s.refreshDigestLocked(c, key)
+	}
+	c.pending = nil
+	return nil
+}
+
+// digestChanged — событие подписки: до окончания построения ключ откладывается,
+// после — его запись в дереве перечитывается из базы.
+func (s *Store) digestChanged(c *cachedDigest, key []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.built {
+		c.pending = append(c.pending, append([]byte(nil), key...))
+		return
+	}
+	s.refreshDigestLocked(c, key)
+}
+
+// refreshDigestLocked приводит запись key в дереве к текущему состоянию базы.
+// Событие подписки не отличает удаление от пустого значения, поэтому состояние читается заново.
+func (s *Store) refreshDigestLocked(c *cachedDigest, key []byte) {
+	var cur digestEntry
+	var exists bool
+	_ = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil || s.expired(item) {
+			return nil
+		}
+		return item.Value(func(val []byte) error {
+			cur = digestEntry{hash: entryDigest(key, val, item.ExpiresAt()), expiresAt: item.ExpiresAt()}
+			exists = true
+			return nil
+		})
+	})
+	c.removeLocked(key)
+	if exists {
+		c.putLocked(key, cur)
+	}
+}
+
+func (c *cachedDigest) putLocked(key []byte, e digestEntry) {
+	c.entries[string(key)] = e
+	c.tree.toggle(c.tree.LeafOf(key), e.hash)
+	if e.expiresAt > 0 && (c.nextExpiry == 0 || e.expiresAt < c.nextExpiry) {
+		c.nextExpiry = e.expiresAt
+	}
+}
+
+func (c *cachedDigest) removeLocked(key []byte) {
+	if old, ok := c.entries[string(key)]; ok {
+		c.tree.toggle(c.tree.LeafOf(key), old.hash)
+		delete(c.entries, string(key))
+	}
+}
+
+// sweepExpiredLocked убирает истёкшие записи: об истечении TTL подписка не сообщает.
+func (c *cachedDigest) sweepExpiredLocked(now uint64) {
+	if c.nextExpiry == 0 || c.nextExpiry > now {
+		return
+	}
+	next := uint64(math.MaxUint64)
+	for k, e := range c.entries {
+		switch {
+		case e.expiresAt == 0:
+		case e.expiresAt <= now:
+			c.tree.toggle(c.tree.LeafOf([]byte(k)), e.hash)
+			delete(c.entries, k)
+		case e.expiresAt < next:
+			next = e.expiresAt
+		}
+	}
+	if next == math.MaxUint64 {
+		next = 0
+	}
+	c.nextExpiry = next
+}
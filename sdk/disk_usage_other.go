@@ -0,0 +1,10 @@
+//go:build !unix
+
+package sdk
+
+import "io/fs"
+
+// allocatedSize — размер файла; без сведений о блоках активный value-log учитывается целиком.
+func allocatedSize(info fs.FileInfo) int64 {
+	return info.Size()
+}
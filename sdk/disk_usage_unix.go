@@ -0,0 +1,17 @@
+//go:build unix
+
+package sdk
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize — занятое файлом место на диске. Badger заранее размечает активный value-log
+// разреженным файлом, поэтому его видимый размер сильно больше реального.
+func allocatedSize(info fs.FileInfo) int64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return int64(st.Blocks) * 512
+	}
+	return info.Size()
+}
@@ -0,0 +1,170 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Badger не сообщает об истечении TTL, поэтому SetWithTTL дополнительно пишет в индекс сроков
+//
+//	"!exp:" + ExpiresAt (8 байт big-endian, unix-секунды) + key → пусто
+//
+// и фоновый обработчик, запущенный первым OnExpire, идёт по индексу до текущего времени,
+// проверяет, что ключа действительно нет, и вызывает подписчиков.
+var expiryIndexPrefix = []byte("!exp:")
+
+type expiryHook struct {
+	prefix []byte
+	fn     func(key []byte)
+}
+
+// SetWithTTL — Set с регистрацией срока в индексе истечений: после истечения ключ будет передан
+// подписчикам OnExpire. Запись и индекс пишутся одной транзакцией.
+func (s *Store) SetWithTTL(key, value []byte, ttl time.Duration) error {
+	if ttl <= 0 {
+		return errors.New("SetWithTTL requires positive ttl")
+	}
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(context.Background()); err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		entry := s.NewEntry(key, value, s.jitterTTL(context.Background(), ttl))
+		if err := txn.SetEntry(entry); err != nil {
+			return err
+		}
+		// индексная запись без TTL: она живёт, пока обработчик не доставит уведомление
+		return txn.Set(expiryIndexKey(entry.ExpiresAt, key), nil)
+	})
+}
+
+func expiryIndexKey(expiresAt uint64, key []byte) []byte {
+	k := make([]byte, 0, len(expiryIndexPrefix)+8+len(key))
+	k = append(k, expiryIndexPrefix...)
+	k = binary.BigEndian.AppendUint64(k, expiresAt)
+	return append(k, key...)
+}
+
+// OnExpire подписывает fn на истечение ключей с префиксом prefix, записанных через SetWithTTL.
+// Первый вызов запускает фоновый обработчик с периодом Options.ExpiryCheckInterval (по умолчанию 1s);
+// он работает до Close. Доставка «хотя бы один раз»: индексная запись удаляется после вызова всех
+// подписчиков, поэтому при падении процесса уведомление повторится после перезапуска и нового OnExpire.
+//
+// Уведомление приходит, только если ключа к сроку нет: ключ, перезаписанный до срока (с новым TTL
+// или без него), пропускается. Ключ, удалённый через Delete до срока, тоже считается истёкшим.
+// fn вызывается последовательно из одной горутины вне транзакций и не должна надолго блокироваться.
+func (s *Store) OnExpire(prefix []byte, fn func(key []byte)) {
+	if fn == nil {
+		panic("fn must be not nil")
+	}
+	s.expiryMu.Lock()
+	s.expiryHooks = append(s.expiryHooks, expiryHook{prefix: append([]byte(nil), prefix...), fn: fn})
+	s.expiryMu.Unlock()
+
+	s.expiryOnce.Do(func() {
+		go s.runExpiryNotifier()
+	})
+}
+
+func (s *Store) runExpiryNotifier() {
+	ticker := s.clock.NewTicker(s.expiryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.bg.Done():
+			return
+		case <-ticker.C():
+		}
+		// ошибка раунда не теряет уведомлений: индекс не очищен, повтор на следующем тике
+		_ = s.notifyExpired()
+	}
+}
+
+type dueExpiry struct {
+	index []byte // индексный ключ
+	gone  bool   // ключа к сроку действительно нет
+}
+
+// notifyExpired обрабатывает все индексные записи со сроком не позже текущего времени.
+// Подписчики вызываются вне транзакций, индекс чистится после них.
+func (s *Store) notifyExpired() error {
+	now := uint64(s.clock.Now().Unix())
+	for {
+		due, err := s.dueExpirations(now, 256)
+		if err != nil || len(due) == 0 {
+			return err
+		}
+		s.expiryMu.Lock()
+		hooks := s.expiryHooks
+		s.expiryMu.Unlock()
+
+		for _, d := range due {
+			if !d.gone {
+				continue
+			}
+			key := d.index[len(expiryIndexPrefix)+8:]
+			for _, h := range hooks {
+				if bytes.HasPrefix(key, h.prefix) {
+					h.fn(key)
+				}
+			}
+		}
+		wb := s.db.NewWriteBatch()
+		for _, d := range due {
+			if err := wb.Delete(d.index); err != nil {
+				wb.Cancel()
+				return err
+			}
+		}
+		if err := wb.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+// dueExpirations возвращает до limit индексных записей со сроком <= now.
+func (s *Store) dueExpirations(now uint64, limit int) ([]dueExpiry, error) {
+	var out []dueExpiry
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = expiryIndexPrefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(expiryIndexPrefix); it.ValidForPrefix(expiryIndexPrefix) && len(out) < limit; it.Next() {
+			k := it.Item().Key()
+			if len(k) < len(expiryIndexPrefix)+8 {
+				continue
+			}
+			if binary.BigEndian.Uint64(k[len(expiryIndexPrefix):]) > now {
+				break
+			}
+			gone, err := s.txKeyGone(txn, k[len(expiryIndexPrefix)+8:])
+			if err != nil {
+				return err
+			}
+			out = append(out, dueExpiry{index: it.Item().KeyCopy(nil), gone: gone})
+		}
+		return nil
+	})
+	return out, err
+}
+
+// txKeyGone — ключа нет или он истёк по часам Store.
+func (s *Store) txKeyGone(txn *badger.Txn, key []byte) (bool, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return s.expired(item), nil
+}
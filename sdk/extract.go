@@ -0,0 +1,316 @@
+package sdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Извлечение значения по пути без описания структур — для быстрых выгрузок отдельных полей.
+//
+// Путь — сегменты через точку ("user.name", "items.0.price"); точку внутри имени экранируют "\.".
+// Для JSON, в стиле gjson:
+//
+//	"friends.1.name"  — элемент массива по индексу
+//	"friends.#"       — длина массива
+//	"friends.#.name"  — поле name каждого элемента массива ([]any)
+//
+// Для protobuf без описания (ScanExtract с ProtoCodec) сегменты — номера полей ("2.1"),
+// с описанием (ScanExtractProto) — имена, JSON-имена или номера полей.
+
+// ScanExtract сканирует префикс и передаёт в fn значение по path для каждой записи.
+// Формат выбирается по кодеку ключа: ProtoCodec — путь по номерам полей wire-формата,
+// иначе значение разбирается как JSON (числа — json.Number). Записи без значения по пути пропускаются,
+// неразбираемые — прерывают скан с *DecodeError (или уходят в Options.QuarantineDecodeErrors).
+// Значения JSON: string, json.Number, bool, nil, map[string]any, []any. Значения wire-формата: uint64 для
+// varint и fixed64, uint32 для fixed32, []byte для строк и вложенных сообщений, []any для повторяющихся полей.
+func (s *Store) ScanExtract(prefix []byte, path string, fn func(key []byte, extracted any)) error {
+	segs := splitExtractPath(path)
+	var wireErr error
+	for _, seg := range segs {
+		if n, err := strconv.Atoi(seg); err != nil || n <= 0 {
+			wireErr = fmt.Errorf("path segment %q: protobuf without descriptor is addressed by field numbers (use ScanExtractProto for names)", seg)
+			break
+		}
+	}
+	return s.ScanPrefix(prefix, 0, func(kv KV) error {
+		raw := s.unwrapValue(kv.Value)
+		var (
+			v   any
+			ok  bool
+			err error
+		)
+		switch s.codecFor(kv.Key).(type) {
+		case ProtoCodec, *ProtoCodec:
+			if wireErr != nil {
+				return wireErr
+			}
+			v, ok, err = extractProtoWire(raw, segs)
+		default:
+			v, ok, err = extractJSON(raw, segs)
+		}
+		if err != nil {
+			return s.quarantineOr(decodeError(kv.Key, raw, s.codecFor(kv.Key), err))
+		}
+		if ok {
+			fn(kv.Key, v)
+		}
+		return nil
+	})
+}
+
+// ScanExtractProto — ScanExtract для protobuf-значений по описанию сообщения-образца sample:
+// сегменты пути — имена, JSON-имена или номера полей, повторяющиеся поля индексируются числом.
+// Значения — как у protoreflect (int32, int64, string, []byte, ...), enum — имя значения,
+// вложенные сообщения — proto.Message, повторяющиеся поля — []any.
+func (s *Store) ScanExtractProto(prefix []byte, sample proto.Message, path string, fn func(key []byte, extracted any)) error {
+	segs := splitExtractPath(path)
+	return s.ScanPrefix(prefix, 0, func(kv KV) error {
+		raw := s.unwrapValue(kv.Value)
+		m := sample.ProtoReflect().New().Interface()
+		if err := proto.Unmarshal(raw, m); err != nil {
+			return s.quarantineOr(decodeError(kv.Key, raw, ProtoCodec{}, err))
+		}
+		if v, ok := extractProto(m.ProtoReflect(), segs); ok {
+			fn(kv.Key, v)
+		}
+		return nil
+	})
+}
+
+// quarantineOr передаёт ошибку в Options.QuarantineDecodeErrors и продолжает скан, если карантин задан.
+func (s *Store) quarantineOr(err *DecodeError) error {
+	if s.quarantine == nil {
+		return err
+	}
+	s.quarantine(err)
+	return nil
+}
+
+// unwrapValue снимает конверт VersionedObjects.
+func (s *Store) unwrapValue(raw []byte) []byte {
+	if s.versioned {
+		_, raw = unwrapObject(raw)
+	}
+	return raw
+}
+
+func splitExtractPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	var segs []string
+	var cur strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path) && path[i+1] == '.':
+			cur.WriteByte('.')
+			i++
+		case path[i] == '.':
+			segs = append(segs, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(path[i])
+		}
+	}
+	return append(segs, cur.String())
+}
+
+func extractJSON(raw []byte, segs []string) (any, bool, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false, err
+	}
+	v, ok := walkJSON(v, segs)
+	return v, ok, nil
+}
+
+func walkJSON(v any, segs []string) (any, bool) {
+	for i, seg := range segs {
+		switch cur := v.(type) {
+		case map[string]any:
+			next, ok := cur[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			if seg == "#" {
+				if i == len(segs)-1 {
+					return len(cur), true
+				}
+				out := make([]any, 0, len(cur))
+				for _, e := range cur {
+					if ev, ok := walkJSON(e, segs[i+1:]); ok {
+						out = append(out, ev)
+					}
+				}
+				return out, true
+			}
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(cur) {
+				return nil, false
+			}
+			v = cur[idx]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func extractProtoWire(raw []byte, segs []string) (any, bool, error) {
+	if len(segs) == 0 {
+		return raw, true, nil
+	}
+	num, _ := strconv.Atoi(segs[0]) // проверено в ScanExtract
+	var found []any
+	for len(raw) > 0 {
+		n, typ, l := protowire.ConsumeTag(raw)
+		if l < 0 {
+			return nil, false, protowire.ParseError(l)
+		}
+		raw = raw[l:]
+		var v any
+		switch typ {
+		case protowire.VarintType:
+			x, l := protowire.ConsumeVarint(raw)
+			if l < 0 {
+				return nil, false, protowire.ParseError(l)
+			}
+			v, raw = x, raw[l:]
+		case protowire.Fixed32Type:
+			x, l := protowire.ConsumeFixed32(raw)
+			if l < 0 {
+				return nil, false, protowire.ParseError(l)
+			}
+			v, raw = x, raw[l:]
+		case protowire.Fixed64Type:
+			x, l := protowire.ConsumeFixed64(raw)
+			if l < 0 {
+				return nil, false, protowire.ParseError(l)
+			}
+			v, raw = x, raw[l:]
+		case protowire.BytesType:
+			x, l := protowire.ConsumeBytes(raw)
+			if l < 0 {
+				return nil, false, protowire.ParseError(l)
+			}
+			v, raw = x, raw[l:]
+		default:
+			l := protowire.ConsumeFieldValue(n, typ, raw)
+			if l < 0 {
+				return nil, false, protowire.ParseError(l)
+			}
+			raw = raw[l:]
+			continue
+		}
+		if n == protowire.Number(num) {
+			found = append(found, v)
+		}
+	}
+	if len(found) == 0 {
+		return nil, false, nil
+	}
+	if len(segs) == 1 {
+		if len(found) == 1 {
+			return found[0], true, nil
+		}
+		return found, true, nil
+	}
+	// вложенное сообщение: повторные вхождения сливаются, как при разборе protobuf
+	nested, ok := found[len(found)-1].([]byte)
+	if !ok {
+		return nil, false, nil
+	}
+	if len(found) > 1 {
+		var merged []byte
+		for _, f := range found {
+			if b, ok := f.([]byte); ok {
+				merged = append(merged, b...)
+			}
+		}
+		nested = merged
+	}
+	return extractProtoWire(nested, segs[1:])
+}
+
+func extractProto(m protoreflect.Message, segs []string) (any, bool) {
+	var v any = m.Interface()
+	for _, seg := range segs {
+		switch cur := v.(type) {
+		case proto.Message:
+			msg := cur.ProtoReflect()
+			fields := msg.Descriptor().Fields()
+			fd := fields.ByName(protoreflect.Name(seg))
+			if fd == nil {
+				fd = fields.ByJSONName(seg)
+			}
+			if fd == nil {
+				if n, err := strconv.Atoi(seg); err == nil {
+					fd = fields.ByNumber(protoreflect.FieldNumber(n))
+				}
+			}
+			if fd == nil || (fd.HasPresence() && !msg.Has(fd)) {
+				return nil, false
+			}
+			v = protoFieldValue(fd, msg.Get(fd))
+		case []any:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(cur) {
+				return nil, false
+			}
+			v = cur[idx]
+		case map[string]any:
+			next, ok := cur[seg]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func protoFieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		out := make([]any, list.Len())
+		for i := range out {
+			out[i] = protoScalar(fd, list.Get(i))
+		}
+		return out
+	case fd.IsMap():
+		out := make(map[string]any, v.Map().Len())
+		v.Map().Range(func(k protoreflect.MapKey, e protoreflect.Value) bool {
+			out[k.String()] = protoScalar(fd.MapValue(), e)
+			return true
+		})
+		return out
+	}
+	return protoScalar(fd, v)
+}
+
+func protoScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return v.Message().Interface()
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int64(v.Enum())
+	}
+	return v.Interface()
+}
@@ -0,0 +1,242 @@
+// Package fake — in-memory реализации интерфейсов sdk (KVStore, ObjectStore, TxRunner)
+// для юнит-тестов бизнес-логики без Badger.
+package fake
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+type Options struct {
+	// Codec для GetObject/SetObject, по умолчанию sdk.JSONCodec.
+	Codec sdk.Codec
+	// Clock для TTL, по умолчанию реальное время.
+	Clock sdk.Clock
+}
+
+type entry struct {
+	value     []byte
+	expiresAt time.Time // нулевое — без TTL
+}
+
+// Store — потокобезопасное хранилище на map. Транзакции сериализуются целиком,
+// поэтому конфликтов (и повторов fn) не бывает.
+type Store struct {
+	codec sdk.Codec
+	clock sdk.Clock
+
+	mu   sync.RWMutex
+	data map[string]entry
+}
+
+var (
+	_ sdk.KVStore     = (*Store)(nil)
+	_ sdk.ObjectStore = (*Store)(nil)
+	_ sdk.TxRunner    = (*Store)(nil)
+)
+
+func NewStore(opts ...Options) *Store {
+	var o Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.Codec == nil {
+		o.Codec = sdk.JSONCodec{}
+	}
+	if o.Clock == nil {
+		o.Clock = sdk.NewRealClock()
+	}
+	return &Store{codec: o.Codec, clock: o.Clock, data: make(map[string]entry)}
+}
+
+func (s *Store) newEntry(value []byte, ttl time.Duration) entry {
+	e := entry{value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expiresAt = s.clock.Now().Add(ttl)
+	}
+	return e
+}
+
+func (s *Store) alive(e entry) bool {
+	return e.expiresAt.IsZero() || s.clock.Now().Before(e.expiresAt)
+}
+
+func (s *Store) getLocked(key []byte) ([]byte, error) {
+	e, ok := s.data[string(key)]
+	if !ok || !s.alive(e) {
+		return nil, sdk.ErrNotFound
+	}
+	return append([]byte(nil), e.value...), nil
+}
+
+func (s *Store) Get(key []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getLocked(key)
+}
+
+func (s *Store) Set(key, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[string(key)] = s.newEntry(value, ttl)
+	return nil
+}
+
+func (s *Store) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, string(key))
+	return nil
+}
+
+func (s *Store) GetAndDelete(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.getLocked(key)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.data, string(key))
+	return v, nil
+}
+
+// ScanPrefix обходит живые записи префикса в порядке ключей, как Badger.
+// fn вызывается под блокировкой чтения: писать в этот же Store из fn нельзя.
+func (s *Store) ScanPrefix(prefix []byte, limit int, fn func(kv sdk.KV) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]string, 0)
+	for k, e := range s.data {
+		if bytes.HasPrefix([]byte(k), prefix) && s.alive(e) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if limit > 0 && i >= limit {
+			break
+		}
+		kv := sdk.KV{Key: []byte(k), Value: append([]byte(nil), s.data[k].value...)}
+		if err := fn(kv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) GetObject(key []byte, v any) error {
+	data, err := s.Get(key)
+	if err != nil {
+		return err
+	}
+	return s.decode(key, data, v)
+}
+
+func (s *Store) SetObject(key []byte, v any, ttl time.Duration) error {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("codec.Marshal: %w", err)
+	}
+	return s.Set(key, data, ttl)
+}
+
+func (s *Store) decode(key, raw []byte, v any) error {
+	if err := s.codec.Unmarshal(raw, v); err != nil {
+		return &sdk.DecodeError{Key: key, Raw: raw, Codec: fmt.Sprintf("%T", s.codec), Err: err}
+	}
+	return nil
+}
+
+// Len — число живых записей; удобно для проверок в тестах.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, e := range s.data {
+		if s.alive(e) {
+			n++
+		}
+	}
+	return n
+}
+
+// RunTx выполняет fn под эксклюзивной блокировкой. Записи буферизуются и применяются
+// только при успешном завершении fn; ошибка или паника откатывают транзакцию.
+func (s *Store) RunTx(ctx context.Context, fn sdk.TxFunc) (err error) {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &fakeTx{store: s, writes: make(map[string]*entry)}
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic in RW txn: %v", p)
+		}
+	}()
+	if err := fn(ctx, tx); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for k, e := range tx.writes {
+		if e == nil {
+			delete(s.data, k)
+			continue
+		}
+		s.data[k] = *e
+	}
+	return nil
+}
+
+// fakeTx — транзакция fake.Store; nil в writes означает удаление.
+type fakeTx struct {
+	store  *Store
+	writes map[string]*entry
+}
+
+func (t *fakeTx) Get(key []byte) ([]byte, error) {
+	if e, ok := t.writes[string(key)]; ok {
+		if e == nil || !t.store.alive(*e) {
+			return nil, sdk.ErrNotFound
+		}
+		return append([]byte(nil), e.value...), nil
+	}
+	return t.store.getLocked(key)
+}
+
+func (t *fakeTx) Set(key, value []byte, ttl time.Duration) error {
+	e := t.store.newEntry(value, ttl)
+	t.writes[string(key)] = &e
+	return nil
+}
+
+func (t *fakeTx) Delete(key []byte) error {
+	t.writes[string(key)] = nil
+	return nil
+}
+
+func (t *fakeTx) GetObject(key []byte, v any) error {
+	data, err := t.Get(key)
+	if err != nil {
+		return err
+	}
+	return t.store.decode(key, data, v)
+}
+
+func (t *fakeTx) SetObject(key []byte, v any, ttl time.Duration) error {
+	data, err := t.store.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return t.Set(key, data, ttl)
+}
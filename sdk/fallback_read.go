@@ -0,0 +1,176 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Чтение со схемы в переходный период: при переходе v2→v3 ключ нового формата ещё есть не у всех
+// записей, и читать приходится сначала новый ключ, а при его отсутствии — старый. GetFirst и
+// GetObjectWithFallback инкапсулируют это и считают, как часто пришлось уйти на старый ключ:
+// когда FallbackReadStats перестаёт показывать Fallback, миграция завершена и старые ключи можно удалять.
+
+// FallbackReadStats — счётчики GetFirst/GetObjectWithFallback для префикса основного ключа
+// (часть до первого ':' включительно).
+type FallbackReadStats struct {
+	Prefix string
+	// Primary — найдено по основному ключу; Fallback — по одному из запасных; Missing — ни по одному.
+	Primary  uint64
+	Fallback uint64
+	Missing  uint64
+	// Upgraded — объектов, перезаписанных под новым ключом GetObjectWithFallback.
+	Upgraded uint64
+}
+
+type fallbackReads struct {
+	mu     sync.Mutex
+	counts map[string]*FallbackReadStats
+}
+
+func (f *fallbackReads) add(key []byte, fn func(*FallbackReadStats)) {
+	p := firstSegment(key)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.counts == nil {
+		f.counts = make(map[string]*FallbackReadStats)
+	}
+	c := f.counts[p]
+	if c == nil {
+		c = &FallbackReadStats{Prefix: p}
+		f.counts[p] = c
+	}
+	fn(c)
+}
+
+// GetFirst возвращает значение первого существующего из keys и его индекс. Все ключи читаются из
+// одного снимка. Нет ни одного — ErrNotFound.
+func (s *Store) GetFirst(keys ...[]byte) ([]byte, int, error) {
+	if len(keys) == 0 {
+		return nil, -1, ErrNotFound
+	}
+	out, idx, _, err := s.getFirst(keys)
+	s.countFallback(keys[0], idx, err)
+	if err != nil {
+		return nil, -1, err
+	}
+	return out, idx, nil
+}
+
+func (s *Store) countFallback(primary []byte, idx int, err error) {
+	switch {
+	case errors.Is(err, ErrNotFound):
+		s.fallbacks.add(primary, func(c *FallbackReadStats) { c.Missing++ })
+	case err != nil:
+	case idx == 0:
+		s.fallbacks.add(primary, func(c *FallbackReadStats) { c.Primary++ })
+	default:
+		s.fallbacks.add(primary, func(c *FallbackReadStats) { c.Fallback++ })
+	}
+}
+
+// GetObjectWithFallback читает объект по newKey, а если его нет — по oldKey. Объект, найденный по
+// старому ключу, передаётся в upgrade (приведение к новой схеме на месте) и записывается под newKey
+// с оставшимся TTL старого; старый ключ не удаляется — его ещё могут читать сервисы на старой версии.
+// Если newKey успели записать параллельно, перезаписи не будет. upgrade == nil — только чтение с
+// откатом, без перезаписи. Ошибка upgrade возвращается как есть, и v при этом не определён.
+func (s *Store) GetObjectWithFallback(newKey, oldKey []byte, v any, upgrade func(any) error) error {
+	raw, idx, ttl, err := s.getFirst([][]byte{newKey, oldKey})
+	s.countFallback(newKey, idx, err)
+	if err != nil {
+		return err
+	}
+	if idx == 0 {
+		return s.decode(newKey, raw, v)
+	}
+	if err := s.decode(oldKey, raw, v); err != nil {
+		return err
+	}
+	if upgrade == nil {
+		return nil
+	}
+	if err := upgrade(v); err != nil {
+		return err
+	}
+	if err := s.checkAccess(context.Background(), AccessWrite, newKey); err != nil {
+		return err
+	}
+	if err := s.validateKey(context.Background(), newKey); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(context.Background()); err != nil {
+		return err
+	}
+	written := false
+	err = NewTransactionManager(s).ExecuteReadWriteWithContext(context.Background(), func(_ context.Context, txn *badger.Txn) error {
+		written = false
+		item, err := txn.Get(newKey)
+		if err == nil && !s.expired(item) {
+			return nil
+		}
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return err
+		}
+		data, _, err := s.txMarshalObject(txn, newKey, v)
+		if err != nil {
+			return err
+		}
+		written = true
+		return txn.SetEntry(s.NewEntry(newKey, data, ttl))
+	})
+	if err != nil {
+		return err
+	}
+	if written {
+		s.fallbacks.add(newKey, func(c *FallbackReadStats) { c.Upgraded++ })
+	}
+	return nil
+}
+
+// getFirst — GetFirst без счётчиков, дополнительно возвращающий оставшийся TTL найденного ключа (0 — без TTL).
+func (s *Store) getFirst(keys [][]byte) ([]byte, int, time.Duration, error) {
+	for _, k := range keys {
+		if err := s.checkAccess(context.Background(), AccessRead, k); err != nil {
+			return nil, -1, 0, err
+		}
+	}
+	var out []byte
+	var ttl time.Duration
+	idx := -1
+	err := s.db.View(func(txn *badger.Txn) error {
+		for i, k := range keys {
+			item, err := txn.Get(k)
+			if errors.Is(err, badger.ErrKeyNotFound) || (err == nil && s.expired(item)) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			idx = i
+			s.noteRead(k)
+			if exp := item.ExpiresAt(); exp > 0 {
+				ttl = max(time.Unix(int64(exp), 0).Sub(s.clock.Now()), time.Second)
+			}
+			out, err = item.ValueCopy(nil)
+			return err
+		}
+		return ErrNotFound
+	})
+	return out, idx, ttl, err
+}
+
+// FallbackReadStats возвращает счётчики GetFirst/GetObjectWithFallback по префиксам основного ключа.
+func (s *Store) FallbackReadStats() []FallbackReadStats {
+	s.fallbacks.mu.Lock()
+	defer s.fallbacks.mu.Unlock()
+	out := make([]FallbackReadStats, 0, len(s.fallbacks.counts))
+	for _, c := range s.fallbacks.counts {
+		out = append(out, *c)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Prefix < out[j].Prefix })
+	return out
+}
@@ -1,27 +1,197 @@
 package sdk
 
 import (
+	"encoding/binary"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 )
 
-func (s *Store) runGC(interval time.Duration) {
-	t := time.NewTicker(interval)
+// Периодический GC value-log. RunValueLogGC переписывает файл value-log, в котором по статистике
+// Badger мусора не меньше Options.GCDiscardRatio, и вызывается повторно, пока переписывать есть что.
+// С Options.GCAdaptive период меняется в пределах [MinInterval, MaxInterval]: вдвое короче, если
+// value-log быстро растёт или Badger оценивает возвращаемый объём выше порога, и вдвое длиннее,
+// пока хранилище простаивает и GC ничего не переписывает.
+
+// GCAdaptiveOptions — параметры Options.GCAdaptive.
+type GCAdaptiveOptions struct {
+	// MinInterval и MaxInterval — границы периода GC, по умолчанию GCInterval/8 и GCInterval*8.
+	MinInterval time.Duration
+	MaxInterval time.Duration
+	// GrowthRate — рост value-log на диске (байт в секунду), начиная с которого GC учащается;
+	// по умолчанию 1 MiB/s.
+	GrowthRate int64
+	// ReclaimableRatio — доля value-log, которую можно вернуть по статистике Badger (файл DISCARD),
+	// начиная с которой GC учащается; по умолчанию 0.3.
+	ReclaimableRatio float64
+}
+
+// GCRun — один запуск GC value-log.
+type GCRun struct {
+	At       time.Time
+	Duration time.Duration
+	// Rewrites — переписанных файлов value-log.
+	Rewrites int
+	// Reclaimed — на сколько уменьшился value-log на диске; VLogBytes — его размер после запуска.
+	Reclaimed int64
+	VLogBytes int64
+	// Reclaimable — оценка возвращаемого объёма перед запуском; GrowthRate — рост value-log
+	// с прошлого запуска, байт в секунду.
+	Reclaimable int64
+	GrowthRate  float64
+}
+
+// GCStats — счётчики периодического GC value-log.
+type GCStats struct {
+	Runs     uint64
+	Rewrites uint64
+	// Skipped — запусков, пропущенных на паузе компакций.
+	Skipped uint64
+	// Reclaimed — всего возвращено байт value-log.
+	Reclaimed int64
+	// Interval — текущий период GC (с GCAdaptive меняется); 0 — периодический GC выключен.
+	Interval time.Duration
+	// Recent — последние запуски, старые первыми.
+	Recent []GCRun
+}
+
+const gcRecentRuns = 32
+
+type gcState struct {
+	mu       sync.Mutex
+	stats    GCStats
+	lastVLog int64
+	lastAt   time.Time
+}
+
+func (o GCAdaptiveOptions) withDefaults(base time.Duration) GCAdaptiveOptions {
+	if o.MinInterval <= 0 {
+		o.MinInterval = base / 8
+	}
+	if o.MaxInterval <= 0 {
+		o.MaxInterval = base * 8
+	}
+	o.MaxInterval = max(o.MaxInterval, o.MinInterval)
+	if o.GrowthRate <= 0 {
+		o.GrowthRate = MiB
+	}
+	if o.ReclaimableRatio <= 0 {
+		o.ReclaimableRatio = 0.3
+	}
+	return o
+}
+
+func (s *Store) runGC(interval time.Duration, ratio float64, adaptive *GCAdaptiveOptions) {
+	if ratio <= 0 {
+		ratio = 0.5 // 50% reclaim threshold
+	}
+	var ad GCAdaptiveOptions
+	if adaptive != nil {
+		ad = adaptive.withDefaults(interval)
+		interval = min(max(interval, ad.MinInterval), ad.MaxInterval)
+	}
+	s.gc.mu.Lock()
+	s.gc.stats.Interval = interval
+	s.gc.mu.Unlock()
+	t := s.clock.NewTimer(interval)
 	defer t.Stop()
 	for {
 		select {
 		case <-s.stopGC:
 			fmt.Println("Stopping Badger GC")
 			return
-		case <-t.C:
-			// Badger рекомендует несколькими попытками вызывать GC пока возвращает nil.
-		gcLoop:
-			for {
-				err := s.db.RunValueLogGC(0.5) // 50% reclaim threshold
-				if err != nil {
-					break gcLoop
-				}
+		case <-t.C():
+			run, ok := s.gcOnce(ratio)
+			if ok && adaptive != nil {
+				interval = ad.next(interval, run)
 			}
+			s.gc.mu.Lock()
+			s.gc.stats.Interval = interval
+			s.gc.mu.Unlock()
+			t.Reset(interval)
 		}
 	}
 }
+
+// next — следующий период GC после запуска run.
+func (o GCAdaptiveOptions) next(interval time.Duration, run GCRun) time.Duration {
+	pressure := run.GrowthRate >= float64(o.GrowthRate) ||
+		(run.VLogBytes > 0 && float64(run.Reclaimable) >= o.ReclaimableRatio*float64(run.VLogBytes))
+	switch {
+	case pressure:
+		return max(interval/2, o.MinInterval)
+	case run.Rewrites > 0:
+		return interval
+	default:
+		return min(interval*2, o.MaxInterval)
+	}
+}
+
+// gcOnce запускает GC value-log и записывает его итог; false — запуск пропущен.
+func (s *Store) gcOnce(ratio float64) (GCRun, bool) {
+	if s.compactionsPaused() {
+		s.gc.mu.Lock()
+		s.gc.stats.Skipped++
+		s.gc.mu.Unlock()
+		return GCRun{}, false
+	}
+	opts := s.db.Opts()
+	start := s.clock.Now()
+	before, _ := diskUsage(opts.Dir, opts.ValueDir)
+	run := GCRun{At: start, Reclaimable: vlogReclaimable(opts.ValueDir)}
+	// Badger рекомендует несколькими попытками вызывать GC пока возвращает nil.
+	for s.db.RunValueLogGC(ratio) == nil {
+		run.Rewrites++
+	}
+	after, _ := diskUsage(opts.Dir, opts.ValueDir)
+	run.Duration = s.clock.Now().Sub(start)
+	run.VLogBytes = after.VLog
+	run.Reclaimed = max(before.VLog-after.VLog, 0)
+
+	g := &s.gc
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.lastAt.IsZero() {
+		if sec := start.Sub(g.lastAt).Seconds(); sec > 0 {
+			run.GrowthRate = max(float64(before.VLog-g.lastVLog), 0) / sec
+		}
+	}
+	g.lastVLog, g.lastAt = after.VLog, start
+	g.stats.Runs++
+	g.stats.Rewrites += uint64(run.Rewrites)
+	g.stats.Reclaimed += run.Reclaimed
+	if len(g.stats.Recent) == gcRecentRuns {
+		g.stats.Recent = append(g.stats.Recent[:0], g.stats.Recent[1:]...)
+	}
+	g.stats.Recent = append(g.stats.Recent, run)
+	return run, true
+}
+
+// vlogReclaimable суммирует статистику мусора по файлам value-log из файла DISCARD Badger: записи
+// по 16 байт (номер файла и объём мусора, big-endian), первая нулевая завершает список. Файл
+// меняется на лету, поэтому результат — оценка; ошибка чтения — 0.
+func vlogReclaimable(valueDir string) int64 {
+	data, err := os.ReadFile(filepath.Join(valueDir, "DISCARD"))
+	if err != nil {
+		return 0
+	}
+	var total int64
+	for off := 0; off+16 <= len(data); off += 16 {
+		if binary.BigEndian.Uint64(data[off:]) == 0 {
+			break
+		}
+		total += int64(binary.BigEndian.Uint64(data[off+8:]))
+	}
+	return total
+}
+
+// GCStats возвращает счётчики периодического GC value-log (Options.GCInterval).
+func (s *Store) GCStats() GCStats {
+	s.gc.mu.Lock()
+	defer s.gc.mu.Unlock()
+	st := s.gc.stats
+	st.Recent = append([]GCRun(nil), st.Recent...)
+	return st
+}
@@ -0,0 +1,128 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Поля «хеша» (аналог Redis HSET/HGET) хранятся плоско, каждое отдельным ключом:
+//
+//	key + "\x00" + field → value
+//
+// Поэтому изменение поля переписывает только его значение, а не весь объект, и конкурентные
+// изменения разных полей не конфликтуют. Ключ объекта и имена полей не должны содержать 0x00.
+
+func hashFieldKey(key []byte, field string) ([]byte, error) {
+	if field == "" || strings.IndexByte(field, 0) >= 0 {
+		return nil, fmt.Errorf("invalid field name %q", field)
+	}
+	k := make([]byte, 0, len(key)+1+len(field))
+	k = append(k, key...)
+	k = append(k, 0)
+	return append(k, field...), nil
+}
+
+func hashPrefix(key []byte) []byte {
+	return append(append(make([]byte, 0, len(key)+1), key...), 0)
+}
+
+// SetField записывает поле field объекта key без чтения и перезаписи остальных полей.
+func (s *Store) SetField(key []byte, field string, value []byte) error {
+	fk, err := hashFieldKey(key, field)
+	if err != nil {
+		return err
+	}
+	return s.Set(fk, value, 0)
+}
+
+// GetField читает поле field объекта key; отсутствующее поле — ErrNotFound.
+func (s *Store) GetField(key []byte, field string) ([]byte, error) {
+	fk, err := hashFieldKey(key, field)
+	if err != nil {
+		return nil, err
+	}
+	return s.Get(fk)
+}
+
+// DeleteField удаляет поле field объекта key.
+func (s *Store) DeleteField(key []byte, field string) error {
+	fk, err := hashFieldKey(key, field)
+	if err != nil {
+		return err
+	}
+	return s.Delete(fk)
+}
+
+// GetFields возвращает все поля объекта key (пустая карта, если полей нет).
+func (s *Store) GetFields(key []byte) (map[string][]byte, error) {
+	prefix := hashPrefix(key)
+	out := make(map[string][]byte)
+	err := s.ScanPrefix(prefix, 0, func(kv KV) error {
+		out[string(kv.Key[len(prefix):])] = kv.Value
+		return nil
+	})
+	return out, err
+}
+
+// DeleteFields удаляет все поля объекта key одной транзакцией.
+func (s *Store) DeleteFields(key []byte) error {
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(context.Background()); err != nil {
+		return err
+	}
+	prefix := hashPrefix(key)
+	return s.db.Update(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if err := txn.Delete(it.Item().KeyCopy(nil)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IncrField атомарно прибавляет delta к целочисленному полю (десятичная строка, отсутствующее — 0)
+// и возвращает новое значение. Транзакция затрагивает только это поле, поэтому конфликтует
+// лишь с изменениями того же поля. Конфликты повторяются (до 20 раз), поэтому badger.ErrConflict
+// возможен только при очень горячем поле — такие счётчики лучше шардировать по нескольким полям.
+func (s *Store) IncrField(ctx context.Context, key []byte, field string, delta int64) (int64, error) {
+	fk, err := hashFieldKey(key, field)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return 0, err
+	}
+	var n int64
+	err = NewTransactionManager(s, TxManagerOptions{MaxRetries: 20}).ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		n = 0
+		item, err := txn.Get(fk)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		case !s.expired(item):
+			if err := item.Value(func(val []byte) error {
+				n, err = strconv.ParseInt(string(val), 10, 64)
+				return err
+			}); err != nil {
+				return fmt.Errorf("field %q is not an integer: %w", field, err)
+			}
+		}
+		n += delta
+		return txn.Set(fk, strconv.AppendInt(nil, n, 10))
+	})
+	return n, err
+}
@@ -0,0 +1,295 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Тепловая карта доступа для подбора размеров кешей. Ключи выборки StartAccessSampling раскладываются
+// по давности последнего чтения (строки) и числу чтений с начала наблюдения (колонки); в клетке —
+// оценка числа ключей и их объёма по всему хранилищу. Рабочий набор — ключи, читавшиеся за
+// WorkingSetWindow. Ключи и значения, лежащие в SST (значения меньше ValueThreshold), читаются через
+// BlockCache — из них считается рекомендуемый BlockCacheSize; значения из value-log кеширует page cache
+// ОС, под него нужна свободная память узла (WorkingSetVLogBytes).
+
+// Границы строк тепловой карты по давности последнего чтения; дальше — "older" и "never".
+var heatmapRecency = []time.Duration{time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// Границы колонок по числу чтений: 0, 1, 2-3, 4-15, 16-63, 64-255, 256+.
+var heatmapFrequency = []uint32{0, 1, 2, 4, 16, 64, 256}
+
+const defaultWorkingSetWindow = time.Hour
+
+type HeatmapOptions struct {
+	// WorkingSetWindow — ключ входит в рабочий набор, если читался за это время; по умолчанию 1 ч.
+	WorkingSetWindow time.Duration
+	// Within — только ключи под этим префиксом; nil — все (кроме служебных '!').
+	Within []byte
+}
+
+// HeatmapCell — оценка по всему хранилищу (выборка / SampleRate).
+type HeatmapCell struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// Heatmap — результат AccessHeatmap.
+type Heatmap struct {
+	At         time.Time `json:"at"`
+	Since      time.Time `json:"since"`
+	SampleRate float64   `json:"sample_rate"`
+	// Recency и Frequency — подписи строк и колонок Cells.
+	Recency   []string        `json:"recency"`
+	Frequency []string        `json:"frequency"`
+	Cells     [][]HeatmapCell `json:"cells"`
+	Total     HeatmapCell     `json:"total"`
+
+	WorkingSetWindow time.Duration `json:"working_set_window"`
+	WorkingSet       HeatmapCell   `json:"working_set"`
+	// WorkingSetLSMBytes — часть рабочего набора в SST, WorkingSetVLogBytes — значения в value-log.
+	WorkingSetLSMBytes  int64 `json:"working_set_lsm_bytes"`
+	WorkingSetVLogBytes int64 `json:"working_set_vlog_bytes"`
+
+	// BlockCacheSize — текущий размер; RecommendedBlockCacheSize — чтобы рабочий набор SST помещался
+	// в кеш целыми блоками, с запасом 20%.
+	BlockCacheSize            int64 `json:"block_cache_size"`
+	RecommendedBlockCacheSize int64 `json:"recommended_block_cache_size"`
+}
+
+// AccessHeatmap проходит по ключам (без чтения значений) и строит тепловую карту ключей выборки
+// StartAccessSampling; без наблюдения — ErrAccessSamplingOff. Последняя карта доступна через
+// LastAccessHeatmap и попадает в WriteMetrics.
+func (s *Store) AccessHeatmap(ctx context.Context, opts HeatmapOptions) (Heatmap, error) {
+	a := s.accessSample.Load()
+	if a == nil {
+		return Heatmap{}, ErrAccessSamplingOff
+	}
+	if opts.WorkingSetWindow <= 0 {
+		opts.WorkingSetWindow = defaultWorkingSetWindow
+	}
+	now := s.clock.Now()
+	bo := s.db.Opts()
+	hm := Heatmap{
+		At:               now,
+		Since:            a.started,
+		SampleRate:       a.rate,
+		Recency:          heatmapRecencyLabels(),
+		Frequency:        heatmapFrequencyLabels(),
+		WorkingSetWindow: opts.WorkingSetWindow,
+		BlockCacheSize:   bo.BlockCacheSize,
+	}
+	hm.Cells = make([][]HeatmapCell, len(hm.Recency))
+	for i := range hm.Cells {
+		hm.Cells[i] = make([]HeatmapCell, len(hm.Frequency))
+	}
+	wsCutoff := now.Add(-opts.WorkingSetWindow).UnixNano()
+
+	type candidate struct {
+		hash  uint64
+		size  int64
+		inLSM bool
+	}
+	var (
+		batch            []candidate
+		lsmKeys, lsmSize int64 // все ключи выборки в SST — для оценки числа блоков
+		wsLSMKeys        int64
+		wsLSM, wsVLog    int64
+	)
+	check := func() {
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		for _, c := range batch {
+			read, n := a.lastRead[c.hash], a.reads[c.hash]
+			row := len(heatmapRecency) + 1 // never
+			if read > 0 {
+				row = len(heatmapRecency)
+				age := time.Duration(now.UnixNano() - read)
+				for i, b := range heatmapRecency {
+					if age < b {
+						row = i
+						break
+					}
+				}
+			}
+			col := 0
+			for i, b := range heatmapFrequency {
+				if n >= b {
+					col = i
+				}
+			}
+			hm.Cells[row][col].Keys++
+			hm.Cells[row][col].Bytes += c.size
+			if c.inLSM {
+				lsmKeys++
+				lsmSize += c.size
+			}
+			if read >= wsCutoff {
+				hm.WorkingSet.Keys++
+				hm.WorkingSet.Bytes += c.size
+				if c.inLSM {
+					wsLSMKeys++
+					wsLSM += c.size
+				} else {
+					wsVLog += c.size
+				}
+			}
+		}
+		batch = batch[:0]
+	}
+	err := s.db.View(func(txn *badger.Txn) error {
+		io := badger.DefaultIteratorOptions
+		io.PrefetchValues = false
+		io.Prefix = opts.Within
+		it := txn.NewIterator(io)
+		defer it.Close()
+		n := 0
+		for it.Rewind(); it.ValidForPrefix(opts.Within); it.Next() {
+			if n++; n%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			key := item.Key()
+			if key[0] == '!' || s.expired(item) {
+				continue
+			}
+			h, ok := a.sampled(key)
+			if !ok {
+				continue
+			}
+			batch = append(batch, candidate{hash: h, size: item.EstimatedSize(), inLSM: item.ValueSize() < bo.ValueThreshold})
+			if len(batch) == 1024 {
+				check()
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return hm, err
+	}
+	check()
+
+	scale := func(v int64) int64 { return int64(float64(v) / a.rate) }
+	for i := range hm.Cells {
+		for j := range hm.Cells[i] {
+			c := &hm.Cells[i][j]
+			c.Keys, c.Bytes = scale(c.Keys), scale(c.Bytes)
+			hm.Total.Keys += c.Keys
+			hm.Total.Bytes += c.Bytes
+		}
+	}
+	hm.WorkingSet.Keys, hm.WorkingSet.Bytes = scale(hm.WorkingSet.Keys), scale(hm.WorkingSet.Bytes)
+	hm.WorkingSetLSMBytes, hm.WorkingSetVLogBytes = scale(wsLSM), scale(wsVLog)
+	hm.RecommendedBlockCacheSize = recommendBlockCache(scale(lsmSize), scale(wsLSMKeys), hm.WorkingSetLSMBytes, int64(bo.BlockSize))
+	s.lastHeatmap.Store(&hm)
+	return hm, nil
+}
+
+// recommendBlockCache оценивает объём блоков SST, в которых лежат hotKeys горячих ключей: если они
+// разбросаны по ключевому пространству равномерно, из B = lsmBytes/blockSize блоков затронуто
+// B·(1 − (1 − 1/B)^hotKeys). Горячие ключи, лежащие рядом, занимают меньше — оценка сверху, но не
+// меньше самих горячих данных. Результат — с запасом 20%, округлён вверх до 16 МиБ.
+func recommendBlockCache(lsmBytes, hotKeys, hotBytes, blockSize int64) int64 {
+	if hotKeys == 0 {
+		return 0
+	}
+	if blockSize <= 0 {
+		blockSize = 4 << 10
+	}
+	blocks := math.Max(1, math.Ceil(float64(lsmBytes)/float64(blockSize)))
+	touched := blocks * -math.Expm1(float64(hotKeys)*math.Log1p(-1/blocks))
+	if blocks == 1 {
+		touched = 1
+	}
+	need := math.Max(touched*float64(blockSize), float64(hotBytes)) * 1.2
+	const round = 16 << 20
+	return int64(math.Ceil(need/round)) * round
+}
+
+func heatmapRecencyLabels() []string {
+	out := make([]string, 0, len(heatmapRecency)+2)
+	for _, b := range heatmapRecency {
+		out = append(out, "<"+shortDuration(b))
+	}
+	return append(out, "older", "never")
+}
+
+func heatmapFrequencyLabels() []string {
+	out := make([]string, len(heatmapFrequency))
+	for i, b := range heatmapFrequency {
+		switch {
+		case i == len(heatmapFrequency)-1:
+			out[i] = fmt.Sprintf("%d+", b)
+		case heatmapFrequency[i+1]-b == 1:
+			out[i] = fmt.Sprint(b)
+		default:
+			out[i] = fmt.Sprintf("%d-%d", b, heatmapFrequency[i+1]-1)
+		}
+	}
+	return out
+}
+
+// shortDuration — 10m, 6h, 7d вместо 10m0s, 6h0m0s, 168h0m0s.
+func shortDuration(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// LastAccessHeatmap возвращает последнюю карту AccessHeatmap; false — карт ещё не было.
+func (s *Store) LastAccessHeatmap() (Heatmap, bool) {
+	hm := s.lastHeatmap.Load()
+	if hm == nil {
+		return Heatmap{}, false
+	}
+	return *hm, true
+}
+
+// WriteFile атомарно записывает карту в path в JSON (читает ReadHeatmap и msctl heatmap).
+func (hm Heatmap) WriteFile(path string) error {
+	data, err := json.MarshalIndent(hm, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ReadHeatmap читает карту, записанную Heatmap.WriteFile.
+func ReadHeatmap(path string) (Heatmap, error) {
+	var hm Heatmap
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return hm, err
+	}
+	if err := json.Unmarshal(data, &hm); err != nil {
+		return hm, fmt.Errorf("heatmap %s: %w", path, err)
+	}
+	return hm, nil
+}
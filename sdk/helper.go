@@ -1,6 +1,10 @@
 package sdk
 
 import (
+	"encoding/binary"
+	"errors"
+	"hash/fnv"
+
 	"google.golang.org/protobuf/encoding/protojson"
 	"google.golang.org/protobuf/proto"
 )
@@ -8,3 +12,57 @@ import (
 func ProtoJsonToOutput(object proto.Message) ([]byte, error) {
 	return protojson.MarshalOptions{EmitUnpopulated: true}.Marshal(object)
 }
+
+// encodeKeyList: [uvarint число ключей]([uvarint длина][ключ])...
+func encodeKeyList(keys [][]byte) []byte {
+	size := binary.MaxVarintLen64
+	for _, k := range keys {
+		size += binary.MaxVarintLen64 + len(k)
+	}
+	buf := binary.AppendUvarint(make([]byte, 0, size), uint64(len(keys)))
+	for _, k := range keys {
+		buf = binary.AppendUvarint(buf, uint64(len(k)))
+		buf = append(buf, k...)
+	}
+	return buf
+}
+
+func decodeKeyList(data []byte) ([][]byte, error) {
+	count, n := binary.Uvarint(data)
+	if n <= 0 || count > uint64(len(data)) {
+		return nil, errors.New("corrupted key list")
+	}
+	data = data[n:]
+	keys := make([][]byte, 0, count)
+	for i := uint64(0); i < count; i++ {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < size {
+			return nil, errors.New("corrupted key list")
+		}
+		keys = append(keys, append([]byte(nil), data[n:n+int(size)]...))
+		data = data[n+int(size):]
+	}
+	return keys, nil
+}
+
+// hash64 — FNV-1a частей с разделителем и финальным перемешиванием (splitmix64): у голого FNV
+// старшие биты коротких похожих строк ("node#1", "node#2") почти совпадают.
+func hash64(parts ...[]byte) uint64 {
+	h := fnv.New64a()
+	for i, p := range parts {
+		if i > 0 {
+			_, _ = h.Write([]byte{0})
+		}
+		_, _ = h.Write(p)
+	}
+	return mix64(h.Sum64())
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,179 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+var (
+	// ErrIdempotencyInProgress — запрос с этим ключом уже выполняется другим обработчиком (его аренда не истекла).
+	ErrIdempotencyInProgress = errors.New("idempotent request is in progress")
+	// ErrLeaseLost — аренда ключа истекла и перешла другому обработчику, либо ключ уже завершён.
+	ErrLeaseLost = errors.New("idempotency lease lost")
+)
+
+type idempotencyRecord struct {
+	Done       bool      `json:"done"`
+	Lease      string    `json:"lease,omitempty"`
+	LeaseUntil time.Time `json:"lease_until,omitempty"`
+	Response   []byte    `json:"response,omitempty"`
+}
+
+type IdempotencyOptions struct {
+	// Prefix — префикс ключей, по умолчанию "idem:".
+	Prefix string
+	// LeaseTTL — сколько первый запрос владеет ключом без Complete; по истечении аренду забирает
+	// следующий Begin (обработчик, видимо, упал). По умолчанию 30s.
+	LeaseTTL time.Duration
+}
+
+// BeginResult — итог IdempotencyStore.Begin: либо New с арендой Lease, либо сохранённый Response.
+type BeginResult struct {
+	// New — запрос нужно выполнить и завершить Complete (или Abort) с Lease.
+	New   bool
+	Lease string
+	// Response — ответ ранее завершённого запроса (при New == false).
+	Response []byte
+}
+
+// IdempotencyStore хранит результаты запросов по ключам идемпотентности.
+// Гонка первых запросов решается транзакцией: из одновременных Begin ровно один получает New,
+// остальные — ErrIdempotencyInProgress до Complete или истечения аренды.
+type IdempotencyStore struct {
+	store *Store
+	tx    *Manager
+	opts  IdempotencyOptions
+}
+
+func NewIdempotencyStore(store *Store, opts IdempotencyOptions) *IdempotencyStore {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "idem:"
+	}
+	if opts.LeaseTTL <= 0 {
+		opts.LeaseTTL = 30 * time.Second
+	}
+	return &IdempotencyStore{store: store, tx: NewTransactionManager(store), opts: opts}
+}
+
+func (is *IdempotencyStore) key(k string) []byte {
+	return []byte(is.opts.Prefix + k)
+}
+
+// txRecord читает запись ключа; false — записи нет или она истекла.
+func (is *IdempotencyStore) txRecord(txn *badger.Txn, key []byte) (idempotencyRecord, *badger.Item, bool, error) {
+	var rec idempotencyRecord
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return rec, nil, false, nil
+	}
+	if err != nil {
+		return rec, nil, false, err
+	}
+	if is.store.expired(item) {
+		return rec, nil, false, nil
+	}
+	err = item.Value(func(val []byte) error {
+		return json.Unmarshal(val, &rec)
+	})
+	if err != nil {
+		return rec, nil, false, fmt.Errorf("idempotency key %q: %w", key, err)
+	}
+	return rec, item, true, nil
+}
+
+// Begin начинает обработку запроса с ключом key. ttl — сколько хранить ключ и ответ с момента Begin.
+// Возвращает New с арендой для первого запроса, сохранённый Response для уже завершённого
+// и ErrIdempotencyInProgress, если запрос выполняется прямо сейчас.
+func (is *IdempotencyStore) Begin(ctx context.Context, key string, ttl time.Duration) (BeginResult, error) {
+	if ttl <= 0 {
+		return BeginResult{}, errors.New("idempotency ttl must be positive")
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return BeginResult{}, err
+	}
+	lease := hex.EncodeToString(raw)
+	k := is.key(key)
+
+	var res BeginResult
+	err := is.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		res = BeginResult{}
+		rec, item, ok, err := is.txRecord(txn, k)
+		if err != nil {
+			return err
+		}
+		now := is.store.clock.Now()
+		switch {
+		case ok && rec.Done:
+			res.Response = rec.Response
+			return nil
+		case ok && now.Before(rec.LeaseUntil):
+			return ErrIdempotencyInProgress
+		}
+
+		data, err := json.Marshal(idempotencyRecord{Lease: lease, LeaseUntil: now.Add(is.opts.LeaseTTL)})
+		if err != nil {
+			return err
+		}
+		entry := is.store.NewEntry(k, data, ttl)
+		if ok {
+			entry.ExpiresAt = item.ExpiresAt() // перехват просроченной аренды не продлевает хранение
+		}
+		res = BeginResult{New: true, Lease: lease}
+		return txn.SetEntry(entry)
+	})
+	return res, err
+}
+
+// txOwned проверяет, что key всё ещё арендован lease.
+func (is *IdempotencyStore) txOwned(txn *badger.Txn, key []byte, lease string) (*badger.Item, error) {
+	rec, item, ok, err := is.txRecord(txn, key)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || rec.Done || rec.Lease != lease {
+		return nil, ErrLeaseLost
+	}
+	return item, nil
+}
+
+// Complete сохраняет ответ запроса атомарно с проверкой аренды: если аренда перешла другому
+// обработчику, возвращается ErrLeaseLost и ответ не сохраняется. Срок хранения ключа не меняется.
+func (is *IdempotencyStore) Complete(ctx context.Context, key, lease string, response []byte) error {
+	k := is.key(key)
+	return is.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		item, err := is.txOwned(txn, k, lease)
+		if err != nil {
+			return err
+		}
+		data, err := json.Marshal(idempotencyRecord{Done: true, Response: response})
+		if err != nil {
+			return err
+		}
+		entry := badger.NewEntry(k, data)
+		entry.ExpiresAt = item.ExpiresAt()
+		return txn.SetEntry(entry)
+	})
+}
+
+// Abort снимает аренду без сохранения ответа (например, запрос завершился ошибкой, которую можно повторить):
+// следующий Begin сразу получит New.
+func (is *IdempotencyStore) Abort(ctx context.Context, key, lease string) error {
+	k := is.key(key)
+	return is.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		if _, err := is.txOwned(txn, k, lease); err != nil {
+			return err
+		}
+		return txn.Delete(k)
+	})
+}
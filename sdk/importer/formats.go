@@ -0,0 +1,228 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// readJSONL разбирает строки JSONL; ошибка emit прерывает чтение, ошибки строк учитываются как ошибки записей.
+func (im *importer) readJSONL(ctx context.Context, emit func(record) error) error {
+	br := bufio.NewReaderSize(&im.in, 1<<20)
+	for n := int64(1); ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if cerr := ctx.Err(); cerr != nil {
+				return context.Cause(ctx)
+			}
+			rec, perr := im.parseJSONLine(bytes.TrimSpace(line))
+			if perr != nil {
+				im.recordFailed(n, rec.key, perr)
+			} else {
+				rec.n = n
+				if eerr := emit(rec); eerr != nil {
+					return eerr
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (im *importer) parseJSONLine(line []byte) (record, error) {
+	rec := record{ttl: im.opts.TTL}
+	if im.opts.KeyField != "" {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(line, &fields); err != nil {
+			return rec, err
+		}
+		raw, ok := fields[im.opts.KeyField]
+		if !ok {
+			return rec, fmt.Errorf("no key field %q", im.opts.KeyField)
+		}
+		key, err := jsonKey(raw)
+		if err != nil {
+			return rec, err
+		}
+		rec.key, rec.value = im.key(key), line
+		return rec, nil
+	}
+
+	var env struct {
+		Key   json.RawMessage `json:"key"`
+		Value json.RawMessage `json:"value"`
+		TTL   json.RawMessage `json:"ttl"`
+	}
+	if err := json.Unmarshal(line, &env); err != nil {
+		return rec, err
+	}
+	if env.Key == nil || env.Value == nil {
+		return rec, errors.New(`line must have "key" and "value" (or set KeyField)`)
+	}
+	key, err := jsonKey(env.Key)
+	if err != nil {
+		return rec, err
+	}
+	rec.key, rec.value = im.key(key), env.Value
+	if env.TTL != nil {
+		ttl, err := jsonKey(env.TTL)
+		if err != nil {
+			return rec, fmt.Errorf("bad ttl %s", env.TTL)
+		}
+		if rec.ttl, err = parseTTL(ttl); err != nil {
+			return rec, err
+		}
+	}
+	return rec, nil
+}
+
+// jsonKey — ключ из строки или числа JSON.
+func jsonKey(raw json.RawMessage) (string, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return n.String(), nil
+	}
+	return "", fmt.Errorf("key must be a string or number, got %s", raw)
+}
+
+// parseTTL разбирает TTL как time.ParseDuration или целое число секунд; пусто — без TTL.
+func parseTTL(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	if sec, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Duration(sec) * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad ttl %q", s)
+	}
+	return d, nil
+}
+
+func (im *importer) key(k string) []byte {
+	return []byte(im.opts.KeyPrefix + k)
+}
+
+func (im *importer) readCSV(ctx context.Context, emit func(record) error) error {
+	cr := csv.NewReader(bufio.NewReaderSize(&im.in, 1<<20))
+	cr.ReuseRecord = true
+	header, err := cr.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+	header = slices.Clone(header)
+	keyField := im.opts.KeyField
+	if keyField == "" {
+		keyField = "key"
+	}
+	keyCol, ttlCol := slices.Index(header, keyField), slices.Index(header, "ttl")
+	if keyCol < 0 {
+		return fmt.Errorf("csv header has no key column %q", keyField)
+	}
+	for n := int64(1); ; n++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if cerr := ctx.Err(); cerr != nil {
+			return context.Cause(ctx)
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) && perr.Err == csv.ErrFieldCount {
+			im.recordFailed(n, nil, err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rec := record{n: n, key: im.key(row[keyCol]), ttl: im.opts.TTL}
+		if ttlCol >= 0 {
+			if rec.ttl, err = parseTTL(row[ttlCol]); err != nil {
+				im.recordFailed(n, rec.key, err)
+				continue
+			}
+		}
+		obj := make(map[string]json.RawMessage, len(row))
+		for i, cell := range row {
+			if i != keyCol && i != ttlCol {
+				obj[header[i]] = csvCell(cell)
+			}
+		}
+		if rec.value, err = json.Marshal(obj); err != nil {
+			im.recordFailed(n, rec.key, err)
+			continue
+		}
+		if err := emit(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// csvCell — JSON ячейки: числа и true/false как есть, остальное строкой.
+func csvCell(cell string) json.RawMessage {
+	if cell == "true" || cell == "false" {
+		return json.RawMessage(cell)
+	}
+	if _, err := strconv.ParseFloat(cell, 64); err == nil && json.Valid([]byte(cell)) {
+		return json.RawMessage(cell)
+	}
+	b, _ := json.Marshal(cell)
+	return b
+}
+
+func (im *importer) readProto(ctx context.Context, emit func(record) error) error {
+	desc := im.opts.Message.Descriptor()
+	fd := desc.Fields().ByName(protoreflect.Name(im.opts.KeyField))
+	if fd == nil {
+		fd = desc.Fields().ByJSONName(im.opts.KeyField)
+	}
+	if fd == nil || fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+		return fmt.Errorf("%s has no scalar field %q", desc.FullName(), im.opts.KeyField)
+	}
+	br := bufio.NewReaderSize(&im.in, 1<<20)
+	for n := int64(1); ; n++ {
+		if cerr := ctx.Err(); cerr != nil {
+			return context.Cause(ctx)
+		}
+		m := im.opts.Message.New().Interface()
+		if err := protodelim.UnmarshalFrom(br, m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			// после битого сообщения границы следующих неизвестны — продолжать нельзя
+			return fmt.Errorf("message %d: %w", n, err)
+		}
+		v := m.ProtoReflect().Get(fd)
+		key := v.String()
+		if fd.Kind() == protoreflect.BytesKind {
+			key = string(v.Bytes())
+		}
+		if err := emit(record{n: n, key: im.key(key), msg: m, ttl: im.opts.TTL}); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,367 @@
+// Package importer — массовая загрузка записей в sdk.Store из JSONL, CSV и потока
+// protobuf-сообщений с префиксом длины (protodelim) параллельными WriteBatch.
+//
+// Вход читается одним потоком: разбор формата, извлечение ключа и дедупликация идут по порядку,
+// а декодирование значения, кодирование кодеком ключа и запись — в Workers воркерах. Запись
+// направляется воркеру по хешу ключа, поэтому повторы одного ключа пишутся по порядку и без Dedupe
+// побеждает последний.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"io"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+type Format string
+
+const (
+	// JSONL — объект JSON на строку. Без KeyField строка — конверт {"key": ..., "value": ..., "ttl": ...}
+	// (ttl — строка time.ParseDuration или секунды); с KeyField значение — вся строка, ключ — её поле.
+	JSONL Format = "jsonl"
+	// CSV — строка заголовка и строки данных. Ключ — столбец KeyField (по умолчанию "key"), TTL —
+	// необязательный столбец "ttl"; значение — объект из остальных столбцов, где числа и true/false
+	// становятся числами и булевыми, прочее — строками.
+	CSV Format = "csv"
+	// ProtoDelimited — сообщения Message с varint-префиксом длины (protodelim); ключ — поле KeyField.
+	// Значение пишется как есть кодеком ключа, поэтому ключам нужен ProtoCodec.
+	ProtoDelimited Format = "protodelim"
+)
+
+type Options struct {
+	Format Format
+	// KeyField — поле или столбец с ключом (см. форматы); ключ записи — KeyPrefix + значение поля.
+	KeyField  string
+	KeyPrefix string
+	// TTL — TTL записей без собственного ttl; 0 — бессрочно.
+	TTL time.Duration
+	// New возвращает значение для декодирования записи JSONL/CSV по ключу; неизвестные поля — ошибка
+	// записи (proto.Message декодируется protojson). nil — map[string]any.
+	New func(key []byte) any
+	// Message — тип сообщений ProtoDelimited, например (*pb.User)(nil).ProtoReflect().Type().
+	Message protoreflect.MessageType
+
+	// Workers — число параллельных WriteBatch (по умолчанию GOMAXPROCS).
+	Workers int
+	// BatchSize — записей в одной подпартии WriteBatch (по умолчанию 1000).
+	BatchSize int
+	// Dedupe — пропускать повторы ключа, оставляя первое вхождение; ключи держатся в памяти.
+	Dedupe bool
+	// DryRun — только проверить: разобрать, закодировать кодеком и декодировать обратно, ничего не записывая.
+	DryRun bool
+	// MaxErrors — сколько ошибочных записей допустимо до остановки импорта; < 0 — без предела,
+	// 0 — первая же ошибка останавливает импорт.
+	MaxErrors int
+
+	// Progress вызывается каждые ProgressInterval (по умолчанию 1с) и по завершении.
+	Progress         func(Progress)
+	ProgressInterval time.Duration
+}
+
+// Progress — счётчики хода импорта.
+type Progress struct {
+	// Records — разобранные записи; Written — записанные (в DryRun — проверенные); Skipped — повторы
+	// при Dedupe; Failed — ошибочные.
+	Records int64
+	Written int64
+	Skipped int64
+	Failed  int64
+	// Bytes — прочитано байт входа.
+	Bytes   int64
+	Elapsed time.Duration
+}
+
+// RecordError — ошибка записи входа. Record — номер с 1: строка для JSONL, строка данных для CSV,
+// сообщение для ProtoDelimited.
+type RecordError struct {
+	Record int64
+	Key    string
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("record %d: %v", e.Record, e.Err)
+	}
+	return fmt.Sprintf("record %d (key %q): %v", e.Record, e.Key, e.Err)
+}
+
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// Stats — итог импорта: счётчики и первые maxKeptErrors ошибок записей.
+type Stats struct {
+	Progress
+	Errors []*RecordError
+}
+
+const maxKeptErrors = 100
+
+// ErrTooManyErrors — число ошибочных записей превысило Options.MaxErrors.
+var ErrTooManyErrors = errors.New("too many failed records")
+
+type record struct {
+	n     int64
+	key   []byte
+	value []byte        // JSON значения (JSONL, CSV)
+	msg   proto.Message // ProtoDelimited
+	ttl   time.Duration
+}
+
+type importer struct {
+	store  *sdk.Store
+	opts   Options
+	start  time.Time
+	cancel context.CancelCauseFunc
+
+	records, written, skipped, failed atomic.Int64
+	in                                countingReader
+
+	mu   sync.Mutex
+	errs []*RecordError
+}
+
+// Run читает r в формате opts.Format и пишет записи в store. Ошибки отдельных записей копятся
+// в Stats.Errors (до MaxErrors); ошибка чтения входа, коммита или отмена ctx прерывают импорт,
+// при этом часть записей уже может быть записана.
+func Run(ctx context.Context, store *sdk.Store, r io.Reader, opts Options) (Stats, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = runtime.GOMAXPROCS(0)
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = time.Second
+	}
+	var read func(context.Context, func(record) error) error
+	im := &importer{store: store, opts: opts, start: time.Now(), in: countingReader{r: r}}
+	switch opts.Format {
+	case JSONL:
+		read = im.readJSONL
+	case CSV:
+		read = im.readCSV
+	case ProtoDelimited:
+		if opts.Message == nil || opts.KeyField == "" {
+			return Stats{}, errors.New("protodelim import requires Message and KeyField")
+		}
+		read = im.readProto
+	default:
+		return Stats{}, fmt.Errorf("unknown import format %q", opts.Format)
+	}
+
+	ctx, im.cancel = context.WithCancelCause(ctx)
+	defer im.cancel(nil)
+
+	queues := make([]chan record, opts.Workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan record, opts.BatchSize)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			im.work(ctx, queues[i])
+		}()
+	}
+
+	stopProgress := make(chan struct{})
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		if opts.Progress == nil {
+			return
+		}
+		t := time.NewTicker(opts.ProgressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stopProgress:
+				return
+			case <-t.C:
+				opts.Progress(im.progress())
+			}
+		}
+	}()
+
+	seed := maphash.MakeSeed()
+	var seen map[string]struct{}
+	if opts.Dedupe {
+		seen = make(map[string]struct{})
+	}
+	readErr := read(ctx, func(rec record) error {
+		im.records.Add(1)
+		if seen != nil {
+			if _, dup := seen[string(rec.key)]; dup {
+				im.skipped.Add(1)
+				return nil
+			}
+			seen[string(rec.key)] = struct{}{}
+		}
+		q := queues[maphash.Bytes(seed, rec.key)%uint64(len(queues))]
+		select {
+		case q <- rec:
+			return nil
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		}
+	})
+	if readErr != nil {
+		im.cancel(readErr)
+	}
+	for _, q := range queues {
+		close(q)
+	}
+	wg.Wait()
+	close(stopProgress)
+	<-progressDone
+
+	stats := Stats{Progress: im.progress()}
+	im.mu.Lock()
+	stats.Errors = im.errs
+	im.mu.Unlock()
+	if opts.Progress != nil {
+		opts.Progress(stats.Progress)
+	}
+	if readErr != nil {
+		return stats, readErr
+	}
+	return stats, context.Cause(ctx)
+}
+
+func (im *importer) progress() Progress {
+	return Progress{
+		Records: im.records.Load(),
+		Written: im.written.Load(),
+		Skipped: im.skipped.Load(),
+		Failed:  im.failed.Load(),
+		Bytes:   im.in.n.Load(),
+		Elapsed: time.Since(im.start),
+	}
+}
+
+// recordFailed учитывает ошибку записи n (или разбора входа) и останавливает импорт сверх MaxErrors.
+func (im *importer) recordFailed(n int64, key []byte, err error) {
+	failed := im.failed.Add(1)
+	im.mu.Lock()
+	if len(im.errs) < maxKeptErrors {
+		im.errs = append(im.errs, &RecordError{Record: n, Key: string(key), Err: err})
+	}
+	im.mu.Unlock()
+	if im.opts.MaxErrors >= 0 && failed > int64(im.opts.MaxErrors) {
+		im.cancel(fmt.Errorf("%w: %d", ErrTooManyErrors, failed))
+	}
+}
+
+func (im *importer) work(ctx context.Context, in <-chan record) {
+	var wb *sdk.WriteBatch
+	if !im.opts.DryRun {
+		wb = im.store.NewWriteBatchContext(ctx, sdk.WriteBatchOptions{MaxCount: im.opts.BatchSize})
+		defer wb.Cancel()
+	}
+	for rec := range in {
+		if ctx.Err() != nil {
+			continue // дочитываем очередь, чтобы не блокировать читателя
+		}
+		data, fresh, err := im.encode(rec)
+		if err == nil && im.opts.DryRun {
+			err = im.store.DecodeValue(rec.key, data, fresh)
+		}
+		if err == nil && !im.opts.DryRun {
+			if err = wb.Set(rec.key, data, rec.ttl); err != nil && wb.Err() != nil {
+				im.cancel(err)
+				return
+			}
+		}
+		if err != nil {
+			im.recordFailed(rec.n, rec.key, err)
+			continue
+		}
+		im.written.Add(1)
+	}
+	if wb != nil && ctx.Err() == nil {
+		_ = wb.Flush() // ошибки записей уже учтены, важна только ошибка коммита
+		if err := wb.Err(); err != nil {
+			im.cancel(err)
+		}
+	}
+}
+
+// encode декодирует значение записи и кодирует его кодеком ключа. fresh — пустое значение того же
+// типа для проверки обратного декодирования в DryRun.
+func (im *importer) encode(rec record) (data []byte, fresh any, err error) {
+	var v any
+	switch {
+	case rec.msg != nil:
+		v, fresh = rec.msg, rec.msg.ProtoReflect().New().Interface()
+	case im.opts.New != nil:
+		v, fresh = im.opts.New(rec.key), im.opts.New(rec.key)
+		if m, ok := v.(proto.Message); ok {
+			err = protojson.Unmarshal(rec.value, m)
+		} else {
+			dec := json.NewDecoder(bytes.NewReader(rec.value))
+			dec.DisallowUnknownFields()
+			err = dec.Decode(v)
+		}
+	default:
+		dec := json.NewDecoder(bytes.NewReader(rec.value))
+		dec.UseNumber()
+		var raw any
+		err = dec.Decode(&raw)
+		v, fresh = normalizeNumbers(raw), new(any)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = im.store.EncodeValue(rec.key, v)
+	return data, fresh, err
+}
+
+// normalizeNumbers заменяет json.Number на int64 или float64: не-JSON кодеки (msgpack) иначе
+// записали бы числа строками.
+func normalizeNumbers(v any) any {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]any:
+		for k, e := range x {
+			x[k] = normalizeNumbers(e)
+		}
+	case []any:
+		for i, e := range x {
+			x[i] = normalizeNumbers(e)
+		}
+	}
+	return v
+}
+
+type countingReader struct {
+	r io.Reader
+	n atomic.Int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,203 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Проверка целостности: каждое значение последней версии ключа читается так же, как его прочитал бы Get.
+// Значения в LSM читаются из памяти, указатели в value-log — из файлов, поэтому пропавший или
+// обрезанный после частичного восстановления файл value-log проявляется здесь, а не на чтениях в проде.
+//
+// Badger не возвращает ошибку чтения value-log: он пишет её в лог и отдаёт пустое значение. Поэтому
+// битой считается запись, у которой в LSM указатель на значение ненулевой длины, а прочитано пустое.
+// Контрольные суммы самих значений Badger проверяет только с VerifyValueChecksum, их проверка не входит
+// в CheckIntegrity.
+
+// IntegrityOptions — параметры CheckIntegrity.
+type IntegrityOptions struct {
+	// Repair — удалить ключи, значения которых невозможно прочитать. Перед удалением сведения о них
+	// пишутся в Quarantine; ключ, перезаписанный после проверки, не удаляется.
+	Repair bool
+	// Quarantine — куда выгрузить битые записи (JSONL, по строке DanglingRef); обязателен при Repair.
+	Quarantine io.Writer
+	// VerifyTables — дополнительно проверить контрольные суммы блоков SST.
+	VerifyTables bool
+}
+
+// DanglingRef — ключ, значение которого не читается.
+type DanglingRef struct {
+	Key     []byte `json:"key"`
+	Version uint64 `json:"version"`
+	// ValueSize — длина потерянного значения по указателю.
+	ValueSize int64     `json:"value_size"`
+	ExpiresAt uint64    `json:"expires_at,omitempty"`
+	UserMeta  byte      `json:"user_meta,omitempty"`
+	Found     time.Time `json:"found"`
+}
+
+// IntegrityReport — итог CheckIntegrity.
+type IntegrityReport struct {
+	Checked int64
+	// VlogFiles — номера файлов value-log на диске (подробности о битых ссылках — в логе Badger).
+	VlogFiles []uint32
+	Dangling  []DanglingRef
+	// Deleted — удалено при Repair.
+	Deleted int
+	// TablesErr — ошибка проверки SST при VerifyTables.
+	TablesErr error
+	Duration  time.Duration
+}
+
+// OK — проверка не нашла повреждений.
+func (r IntegrityReport) OK() bool {
+	return len(r.Dangling) == 0 && r.TablesErr == nil
+}
+
+// CheckIntegrity сверяет ссылки LSM на value-log с файлами на диске, читая последнюю версию каждого
+// неистёкшего ключа. С Repair удаляет невосстановимые ключи (операция пишется в журнал аудита).
+// Проверка видна в Jobs (прогресс — проверенные ключи).
+func (s *Store) CheckIntegrity(ctx context.Context, opts IntegrityOptions) (IntegrityReport, error) {
+	ctx, job := s.StartJob(ctx, JobScrub, "")
+	rep, err := s.checkIntegrity(ctx, opts, job)
+	job.Finish(err)
+	return rep, err
+}
+
+func (s *Store) checkIntegrity(ctx context.Context, opts IntegrityOptions, job *Job) (IntegrityReport, error) {
+	start := s.clock.Now()
+	var rep IntegrityReport
+	if opts.Repair && opts.Quarantine == nil {
+		return rep, errors.New("integrity repair requires a quarantine writer")
+	}
+	if !s.db.Opts().InMemory {
+		var err error
+		if rep.VlogFiles, err = vlogFiles(s.db.Opts().ValueDir); err != nil {
+			return rep, err
+		}
+	}
+
+	err := s.db.View(func(txn *badger.Txn) error {
+		iopt := badger.DefaultIteratorOptions
+		iopt.PrefetchValues = false
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if rep.Checked%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				job.SetProgress(rep.Checked, 0)
+			}
+			item := it.Item()
+			if s.expired(item) {
+				continue
+			}
+			rep.Checked++
+			var n int
+			if err := item.Value(func(v []byte) error { n = len(v); return nil }); err != nil {
+				return fmt.Errorf("key %q: %w", item.Key(), err)
+			}
+			if n == 0 && item.ValueSize() > 0 {
+				rep.Dangling = append(rep.Dangling, DanglingRef{
+					Key:       item.KeyCopy(nil),
+					Version:   item.Version(),
+					ValueSize: item.ValueSize(),
+					ExpiresAt: item.ExpiresAt(),
+					UserMeta:  item.UserMeta(),
+					Found:     s.clock.Now(),
+				})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	job.SetProgress(rep.Checked, 0)
+	if opts.VerifyTables {
+		job.SetStage("verify_tables")
+		rep.TablesErr = s.db.VerifyChecksum()
+	}
+
+	if opts.Repair && len(rep.Dangling) > 0 {
+		job.SetStage("repair")
+		err = s.RunAudited(ctx, "integrity_repair", fmt.Sprintf("%d dangling keys", len(rep.Dangling)), func() error {
+			return s.repairDangling(ctx, opts.Quarantine, &rep)
+		})
+	}
+	rep.Duration = s.clock.Now().Sub(start)
+	return rep, err
+}
+
+// repairDangling выгружает битые записи в карантин и удаляет те, что не перезаписаны после проверки.
+func (s *Store) repairDangling(ctx context.Context, quarantine io.Writer, rep *IntegrityReport) error {
+	enc := json.NewEncoder(quarantine)
+	for _, d := range rep.Dangling {
+		if err := enc.Encode(d); err != nil {
+			return fmt.Errorf("quarantine: %w", err)
+		}
+	}
+	if f, ok := quarantine.(interface{ Sync() error }); ok {
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("quarantine: %w", err)
+		}
+	}
+	for _, d := range rep.Dangling {
+		if err := s.writeLimit.wait(ctx); err != nil {
+			return err
+		}
+		deleted := false
+		err := s.db.Update(func(txn *badger.Txn) error {
+			item, err := txn.Get(d.Key)
+			if errors.Is(err, badger.ErrKeyNotFound) {
+				return nil
+			}
+			if err != nil || item.Version() != d.Version {
+				return err
+			}
+			deleted = true
+			return txn.Delete(d.Key)
+		})
+		if err != nil {
+			return fmt.Errorf("key %q: %w", d.Key, err)
+		}
+		if deleted {
+			rep.Deleted++
+		}
+	}
+	return nil
+}
+
+// vlogFiles — номера файлов value-log в каталоге по возрастанию.
+func vlogFiles(dir string) ([]uint32, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var fids []uint32
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".vlog" {
+			continue
+		}
+		fid, err := strconv.ParseUint(strings.TrimSuffix(name, ".vlog"), 10, 32)
+		if err != nil {
+			continue
+		}
+		fids = append(fids, uint32(fid))
+	}
+	sort.Slice(fids, func(i, j int) bool { return fids[i] < fids[j] })
+	return fids, nil
+}
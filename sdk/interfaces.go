@@ -0,0 +1,118 @@
+package sdk
+
+import (
+	"context"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Интерфейсы для потребителей SDK: бизнес-логику можно писать против них и
+// тестировать на фейках из пакета sdk/fake, не открывая Badger.
+
+// KVStore — операции с сырыми значениями. Реализуется *Store.
+type KVStore interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte, ttl time.Duration) error
+	Delete(key []byte) error
+	GetAndDelete(key []byte) ([]byte, error)
+	ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error
+}
+
+// ObjectStore — операции с объектами через кодек хранилища. Реализуется *Store.
+type ObjectStore interface {
+	GetObject(key []byte, v any) error
+	SetObject(key []byte, v any, ttl time.Duration) error
+}
+
+// Tx — операции внутри транзакции TxRunner. Отсутствующий ключ — ErrNotFound.
+type Tx interface {
+	Get(key []byte) ([]byte, error)
+	Set(key, value []byte, ttl time.Duration) error
+	Delete(key []byte) error
+	GetObject(key []byte, v any) error
+	SetObject(key []byte, v any, ttl time.Duration) error
+}
+
+// TxFunc — тело транзакции; ненулевая ошибка откатывает транзакцию.
+type TxFunc func(ctx context.Context, tx Tx) error
+
+// TxRunner выполняет транзакции чтения-записи. Реализуется *Manager.
+type TxRunner interface {
+	RunTx(ctx context.Context, fn TxFunc) error
+}
+
+var (
+	_ KVStore     = (*Store)(nil)
+	_ ObjectStore = (*Store)(nil)
+	_ TxRunner    = (*Manager)(nil)
+)
+
+// RunTx — ExecuteReadWriteWithContext с транзакцией, скрытой за интерфейсом Tx.
+// Операции Tx проверяются AccessController с principal из ctx. При конфликте fn вызывается повторно, поэтому она не должна иметь внешних побочных эффектов.
+func (m *Manager) RunTx(ctx context.Context, fn TxFunc) error {
+	return m.ExecuteReadWriteWithContext(ctx, func(ctx context.Context, txn *badger.Txn) error {
+		return fn(ctx, &badgerTx{ctx: ctx, store: m.store, txn: txn})
+	})
+}
+
+type badgerTx struct {
+	ctx   context.Context
+	store *Store
+	txn   *badger.Txn
+}
+
+func (t *badgerTx) Get(key []byte) ([]byte, error) {
+	if err := t.store.checkAccess(t.ctx, AccessRead, key); err != nil {
+		return nil, err
+	}
+	NoteTxKey(t.ctx, key)
+	item, err := t.txn.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	if t.store.expired(item) {
+		return nil, ErrNotFound
+	}
+	t.store.noteRead(key)
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTx) Set(key, value []byte, ttl time.Duration) error {
+	if err := t.store.checkAccess(t.ctx, AccessWrite, key); err != nil {
+		return err
+	}
+	if err := t.store.validateKey(t.ctx, key); err != nil {
+		return err
+	}
+	NoteTxKey(t.ctx, key)
+	t.store.noteWrite(key)
+	return t.txn.SetEntry(t.store.NewEntry(key, value, t.store.jitterTTL(t.ctx, ttl)))
+}
+
+func (t *badgerTx) Delete(key []byte) error {
+	if err := t.store.checkAccess(t.ctx, AccessDelete, key); err != nil {
+		return err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	NoteTxKey(t.ctx, key)
+	return t.txn.Delete(key)
+}
+
+func (t *badgerTx) GetObject(key []byte, v any) error {
+	if err := t.store.checkAccess(t.ctx, AccessRead, key); err != nil {
+		return err
+	}
+	NoteTxKey(t.ctx, key)
+	return t.store.TxGetObject(t.txn, key, v)
+}
+
+func (t *badgerTx) SetObject(key []byte, v any, ttl time.Duration) error {
+	data, _, err := t.store.txMarshalObject(t.txn, key, v)
+	if err != nil {
+		return err
+	}
+	return t.Set(key, data, ttl)
+}
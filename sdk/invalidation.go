@@ -0,0 +1,199 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Рассылка инвалидаций между процессами одного хоста.
+// Писатель (единственный процесс, открывший Badger на запись) слушает unix-сокет и
+// рассылает ключи изменённых записей; читатели (ReadOnly-реплики) сбрасывают их из своих кешей.
+// Сообщение: [uvarint число ключей]([uvarint длина][ключ])...
+
+const (
+	defaultInvalidationWriteTimeout = time.Second
+	// границы разбора сообщения: длина из сокета не должна приводить к выделению произвольной памяти.
+	// Ключ не длиннее предела Badger (65000 байт).
+	maxInvalidationKeyLen = 65000
+	maxInvalidationKeys   = 1 << 20
+)
+
+var errInvalidationMessage = errors.New("malformed invalidation message")
+
+// InvalidationBroadcaster — серверная сторона рассылки инвалидаций на стороне писателя.
+type InvalidationBroadcaster struct {
+	ln           net.Listener
+	writeTimeout time.Duration
+
+	mu     sync.Mutex
+	conns  map[net.Conn]struct{}
+	closed bool
+}
+
+// NewInvalidationBroadcaster начинает слушать unix-сокет path.
+// Оставшийся от прошлого запуска файл сокета удаляется.
+func NewInvalidationBroadcaster(path string) (*InvalidationBroadcaster, error) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("remove stale socket: %w", err)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listen invalidation socket: %w", err)
+	}
+	b := &InvalidationBroadcaster{
+		ln:           ln,
+		writeTimeout: defaultInvalidationWriteTimeout,
+		conns:        make(map[net.Conn]struct{}),
+	}
+	go b.acceptLoop()
+	return b, nil
+}
+
+func (b *InvalidationBroadcaster) acceptLoop() {
+	for {
+		conn, err := b.ln.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			_ = conn.Close()
+			return
+		}
+		b.conns[conn] = struct{}{}
+		b.mu.Unlock()
+	}
+}
+
+// Publish рассылает ключи всем подключённым читателям.
+// Читатель, не принявший сообщение за writeTimeout, отключается: после переподключения
+// он сбрасывает кеш целиком, поэтому пропущенные сообщения не приводят к устаревшим чтениям.
+func (b *InvalidationBroadcaster) Publish(keys [][]byte) {
+	if len(keys) == 0 {
+		return
+	}
+	msg := encodeKeyList(keys)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn := range b.conns {
+		_ = conn.SetWriteDeadline(time.Now().Add(b.writeTimeout))
+		if _, err := conn.Write(msg); err != nil {
+			_ = conn.Close()
+			delete(b.conns, conn)
+		}
+	}
+}
+
+// Close прекращает приём подключений и отключает всех читателей.
+func (b *InvalidationBroadcaster) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	for conn := range b.conns {
+		_ = conn.Close()
+	}
+	b.conns = map[net.Conn]struct{}{}
+	b.mu.Unlock()
+	return b.ln.Close()
+}
+
+// BroadcastInvalidations рассылает через b ключи всех изменений с указанными префиксами.
+// Блокируется до отмены ctx; вызывается в процессе-писателе.
+func (s *Store) BroadcastInvalidations(ctx context.Context, b *InvalidationBroadcaster, prefixes ...[]byte) error {
+	if b == nil {
+		return errors.New("nil broadcaster")
+	}
+	return s.Watch(ctx, prefixes, func(events []KVEvent) error {
+		keys := make([][]byte, 0, len(events))
+		for _, e := range events {
+			keys = append(keys, e.Key)
+		}
+		b.Publish(keys)
+		return nil
+	})
+}
+
+type InvalidationListenerOptions struct {
+	// ReconnectDelay — пауза перед повторным подключением, по умолчанию 1s.
+	ReconnectDelay time.Duration
+	// OnReset вызывается после каждого (пере)подключения: всё, что изменилось пока связи не было,
+	// неизвестно, поэтому кеш нужно сбросить целиком. Может быть nil.
+	OnReset func()
+}
+
+// ListenInvalidations подключается к сокету писателя и вызывает onKeys для каждого сообщения.
+// При обрыве связи переподключается; блокируется до отмены ctx.
+func ListenInvalidations(ctx context.Context, path string, onKeys func(keys [][]byte), opts InvalidationListenerOptions) error {
+	if onKeys == nil {
+		return errors.New("nil invalidation callback")
+	}
+	if opts.ReconnectDelay <= 0 {
+		opts.ReconnectDelay = time.Second
+	}
+
+	var dialer net.Dialer
+	for {
+		conn, err := dialer.DialContext(ctx, "unix", path)
+		if err == nil {
+			if opts.OnReset != nil {
+				opts.OnReset()
+			}
+			readInvalidations(ctx, conn, onKeys)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(opts.ReconnectDelay):
+		}
+	}
+}
+
+func readInvalidations(ctx context.Context, conn net.Conn, onKeys func(keys [][]byte)) {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		keys, err := decodeInvalidation(reader)
+		if err != nil {
+			return
+		}
+		onKeys(keys)
+	}
+}
+
+func decodeInvalidation(r *bufio.Reader) ([][]byte, error) {
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if count > maxInvalidationKeys {
+		return nil, fmt.Errorf("%w: %d keys", errInvalidationMessage, count)
+	}
+	keys := make([][]byte, 0, min(count, 1024))
+	for i := uint64(0); i < count; i++ {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n > maxInvalidationKeyLen {
+			return nil, fmt.Errorf("%w: key length %d", errInvalidationMessage, n)
+		}
+		key := make([]byte, n)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
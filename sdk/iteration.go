@@ -1,12 +1,38 @@
 package sdk
 
-import "github.com/dgraph-io/badger/v4"
+import (
+	"context"
+
+	"github.com/dgraph-io/badger/v4"
+)
 
 type KV struct {
 	Key, Value []byte
 }
 
 func (s *Store) ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error {
+	return s.ScanPrefixContext(context.Background(), prefix, limit, fn)
+}
+
+// ScanPrefixContext — ScanPrefix от имени principal из ctx (WithPrincipal); отмена ctx прерывает
+// ожидание ScanRateLimit.
+func (s *Store) ScanPrefixContext(ctx context.Context, prefix []byte, limit int, fn func(kv KV) error) error {
+	start := s.clock.Now()
+	count := 0
+	err := s.scanPrefix(ctx, prefix, limit, func(kv KV) error {
+		count++
+		return fn(kv)
+	})
+	s.record(RecordScan, prefix, nil, count, 0, start, err)
+	return s.traceOp(ctx, "scan", prefix, start, 1, err)
+}
+
+func (s *Store) scanPrefix(ctx context.Context, prefix []byte, limit int, fn func(kv KV) error) error {
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(ctx, "scan")
+	defer done()
 	return s.db.View(func(txn *badger.Txn) error {
 		opts := badger.DefaultIteratorOptions
 		opts.Prefix = prefix // ← ставим префикс через поле
@@ -19,6 +45,15 @@ func (s *Store) ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error
 		count := 0
 		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
 			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
 			var kv KV
 			kv.Key = append(kv.Key[:0], item.Key()...)
 			if err := item.Value(func(val []byte) error {
@@ -27,6 +62,60 @@ func (s *Store) ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error
 			}); err != nil {
 				return err
 			}
+			s.noteRead(kv.Key)
+			if err := fn(kv); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// ScanPrefixFiltered — скан префикса с отсечением до чтения значения.
+// filter получает ключ, размер значения и user meta; значение читается (в том числе из value log)
+// только для записей, прошедших filter. nil filter пропускает всё. limit <= 0 — без лимита.
+func (s *Store) ScanPrefixFiltered(prefix []byte, limit int, filter func(key []byte, valueSize int64, userMeta byte) bool, fn func(kv KV) error) error {
+	if err := s.checkAccess(context.Background(), AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(context.Background(), "scan_filtered")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		count := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			if filter != nil && !filter(item.Key(), item.ValueSize(), item.UserMeta()) {
+				continue
+			}
+			var kv KV
+			kv.Key = item.KeyCopy(nil)
+			if err := item.Value(func(val []byte) error {
+				kv.Value = append(kv.Value[:0], val...)
+				return nil
+			}); err != nil {
+				return err
+			}
+			s.noteRead(kv.Key)
 			if err := fn(kv); err != nil {
 				return err
 			}
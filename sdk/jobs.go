@@ -0,0 +1,226 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Реестр долгих операций Store: бэкап, восстановление, очистка PurgeWhere, проверка целостности,
+// Reclaim, BulkLoad, раунд anti-entropy, перестройка представлений, выгрузка в SQLite. Операция
+// регистрируется на время выполнения с отметкой начала, этапом и прогрессом, и оператор видит через
+// Store.Jobs (или GET /api/jobs админ-API), что хранилище делает прямо сейчас, и может отменить операцию:
+// CancelJob отменяет её контекст с причиной ErrJobCanceled. Прикладные долгие операции (миграции,
+// переписывание префиксов) регистрируются так же через StartJob.
+
+// Виды операций в JobInfo.Kind.
+const (
+	JobBackup      = "backup"
+	JobRestore     = "restore"
+	JobPurge       = "purge"
+	JobScrub       = "scrub"
+	JobReclaim     = "reclaim"
+	JobBulkLoad    = "bulk_load"
+	JobSync        = "sync"
+	JobViewRebuild = "view_rebuild"
+	JobSQLExport   = "sql_export"
+)
+
+var (
+	// ErrJobCanceled — причина отмены контекста операции через CancelJob.
+	ErrJobCanceled = errors.New("job canceled by operator")
+	// ErrJobNotFound — операции с таким ID нет среди выполняющихся.
+	ErrJobNotFound = errors.New("job not found")
+)
+
+// jobHistory — сколько завершённых операций помнит реестр.
+const jobHistory = 32
+
+// JobInfo — снимок состояния операции.
+type JobInfo struct {
+	ID     uint64 `json:"id"`
+	Kind   string `json:"kind"`
+	Target string `json:"target,omitempty"`
+	// Principal и RequestID — из контекста, с которым операция запущена.
+	Principal string    `json:"principal,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// Stage — текущий этап (например, "flatten" у Reclaim); пустой — у операции один этап.
+	Stage string `json:"stage,omitempty"`
+	// Done и Total — прогресс в единицах операции (байты бэкапа, ключи проверки); Total 0 — неизвестен.
+	Done  int64 `json:"done"`
+	Total int64 `json:"total,omitempty"`
+	// Running — операция выполняется; FinishedAt, Err и Canceled — итог завершённой.
+	Running    bool      `json:"running"`
+	FinishedAt time.Time `json:"finished_at,omitempty"`
+	Err        string    `json:"error,omitempty"`
+	Canceled   bool      `json:"canceled,omitempty"`
+}
+
+// JobRegistry — выполняющиеся и недавно завершённые операции.
+type JobRegistry struct {
+	clock   Clock
+	mu      sync.Mutex
+	nextID  uint64
+	running map[uint64]*Job
+	recent  []JobInfo // старые первыми
+}
+
+func NewJobRegistry(clock Clock) *JobRegistry {
+	if clock == nil {
+		clock = NewRealClock()
+	}
+	return &JobRegistry{clock: clock, running: make(map[uint64]*Job)}
+}
+
+// Job — регистрация операции в JobRegistry. Finish снимает её с учёта.
+type Job struct {
+	reg    *JobRegistry
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	info   JobInfo // под reg.mu
+}
+
+// Start регистрирует операцию kind над target и возвращает её контекст (отменяется через Cancel) и Job.
+func (r *JobRegistry) Start(ctx context.Context, kind, target string) (context.Context, *Job) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	meta := MetaFrom(ctx)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.nextID++
+	j := &Job{reg: r, ctx: ctx, cancel: cancel, info: JobInfo{
+		ID:        r.nextID,
+		Kind:      kind,
+		Target:    target,
+		Principal: meta.Principal,
+		RequestID: meta.RequestID,
+		StartedAt: r.clock.Now(),
+		Running:   true,
+	}}
+	r.running[j.info.ID] = j
+	return ctx, j
+}
+
+// List возвращает выполняющиеся операции по порядку запуска, затем недавно завершённые (новые первыми).
+func (r *JobRegistry) List() []JobInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]JobInfo, 0, len(r.running)+len(r.recent))
+	for _, j := range r.running {
+		out = append(out, j.info)
+	}
+	sort.Slice(out, func(i, k int) bool { return out[i].ID < out[k].ID })
+	for i := len(r.recent) - 1; i >= 0; i-- {
+		out = append(out, r.recent[i])
+	}
+	return out
+}
+
+// Cancel отменяет контекст выполняющейся операции id с причиной ErrJobCanceled. Операция
+// завершается сама, когда заметит отмену.
+func (r *JobRegistry) Cancel(id uint64) error {
+	r.mu.Lock()
+	j := r.running[id]
+	r.mu.Unlock()
+	if j == nil {
+		return ErrJobNotFound
+	}
+	j.cancel(ErrJobCanceled)
+	return nil
+}
+
+func (j *Job) ID() uint64 { return j.info.ID }
+
+// SetStage задаёт текущий этап операции.
+func (j *Job) SetStage(stage string) {
+	j.reg.mu.Lock()
+	j.info.Stage = stage
+	j.reg.mu.Unlock()
+}
+
+// SetProgress задаёт прогресс: done из total (0 — неизвестно).
+func (j *Job) SetProgress(done, total int64) {
+	j.reg.mu.Lock()
+	j.info.Done, j.info.Total = done, total
+	j.reg.mu.Unlock()
+}
+
+// AddDone увеличивает выполненную часть на n.
+func (j *Job) AddDone(n int64) {
+	j.reg.mu.Lock()
+	j.info.Done += n
+	j.reg.mu.Unlock()
+}
+
+// Finish снимает операцию с учёта с итогом err и освобождает её контекст. Повторный вызов ничего не делает.
+func (j *Job) Finish(err error) {
+	r := j.reg
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !j.info.Running {
+		return
+	}
+	j.info.Running = false
+	j.info.FinishedAt = r.clock.Now()
+	if err != nil {
+		j.info.Err = err.Error()
+	}
+	j.info.Canceled = errors.Is(context.Cause(j.ctx), ErrJobCanceled)
+	j.cancel(nil)
+	delete(r.running, j.info.ID)
+	if len(r.recent) == jobHistory {
+		r.recent = append(r.recent[:0], r.recent[1:]...)
+	}
+	r.recent = append(r.recent, j.info)
+}
+
+// StartJob регистрирует прикладную долгую операцию (миграцию, переписывание префикса) в реестре Store;
+// её контекст отменяется через CancelJob. По окончании вызовите Job.Finish.
+func (s *Store) StartJob(ctx context.Context, kind, target string) (context.Context, *Job) {
+	return s.jobs.Start(ctx, kind, target)
+}
+
+// Jobs возвращает выполняющиеся операции Store по порядку запуска, затем недавно завершённые.
+func (s *Store) Jobs() []JobInfo {
+	return s.jobs.List()
+}
+
+// CancelJob отменяет выполняющуюся операцию id; нет такой — ErrJobNotFound.
+func (s *Store) CancelJob(id uint64) error {
+	return s.jobs.Cancel(id)
+}
+
+// jobWriter передаёт запись в w, считая байты в прогресс операции и прерываясь по её отмене.
+type jobWriter struct {
+	ctx context.Context
+	w   io.Writer
+	job *Job
+}
+
+func (w jobWriter) Write(p []byte) (int, error) {
+	if w.ctx.Err() != nil {
+		return 0, context.Cause(w.ctx)
+	}
+	n, err := w.w.Write(p)
+	w.job.AddDone(int64(n))
+	return n, err
+}
+
+// jobReader — jobWriter для чтения.
+type jobReader struct {
+	ctx context.Context
+	r   io.Reader
+	job *Job
+}
+
+func (r jobReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, context.Cause(r.ctx)
+	}
+	n, err := r.r.Read(p)
+	r.job.AddDone(int64(n))
+	return n, err
+}
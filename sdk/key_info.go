@@ -0,0 +1,101 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// KeyInfo — метаданные ключа без значения.
+type KeyInfo struct {
+	Key []byte
+	// ValueSize — размер значения в байтах.
+	ValueSize int64
+	// ExpiresAt — когда истечёт TTL; нулевое — без TTL.
+	ExpiresAt time.Time
+	// Version — версия Badger (commit timestamp) последней записи.
+	Version  uint64
+	UserMeta byte
+}
+
+func keyInfo(item *badger.Item) KeyInfo {
+	info := KeyInfo{
+		Key:       item.KeyCopy(nil),
+		ValueSize: item.ValueSize(),
+		Version:   item.Version(),
+		UserMeta:  item.UserMeta(),
+	}
+	if exp := item.ExpiresAt(); exp > 0 {
+		info.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	return info
+}
+
+// ScanKeys вызывает fn для живых ключей prefix, следующих после after (nil — с начала), без чтения
+// значений; не больше limit ключей (0 — без предела). Постраничный обход: after — последний ключ
+// предыдущей страницы.
+func (s *Store) ScanKeys(ctx context.Context, prefix, after []byte, limit int, fn func(KeyInfo) error) error {
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(ctx, "scan_keys")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		start := prefix
+		if bytes.Compare(after, prefix) >= 0 {
+			start = append(append([]byte(nil), after...), 0)
+		}
+		count := 0
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			if err := fn(keyInfo(item)); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// Inspect возвращает метаданные и сырое значение ключа (как оно лежит в Badger, с конвертом
+// VersionedObjects, если он есть). Отсутствующий или истёкший ключ — ErrNotFound.
+func (s *Store) Inspect(ctx context.Context, key []byte) (KeyInfo, []byte, error) {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return KeyInfo{}, nil, err
+	}
+	var info KeyInfo
+	var raw []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if s.expired(item) {
+			return ErrNotFound
+		}
+		info = keyInfo(item)
+		raw, err = item.ValueCopy(nil)
+		return err
+	})
+	return info, raw, err
+}
@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"context"
+	"hash/maphash"
+	"slices"
+)
+
+// KeyLocker — внутрипроцессная взаимная блокировка по ключам на N полосах (stripes): ключ
+// блокирует мьютекс полосы hash(key) % N. Для писателей, отключивших DetectConflicts: вместо
+// конфликтов и ретраев записи одного ключа просто выполняются по очереди.
+// Разные ключи могут попасть в одну полосу и ждать друг друга — это цена фиксированной памяти.
+// Блокировка не реентерабельна: повторный Lock того же ключа в той же горутине зависнет.
+type KeyLocker struct {
+	seed    maphash.Seed
+	stripes []chan struct{}
+}
+
+// NewKeyLocker создаёт блокировщик на stripes полосах (по умолчанию 256).
+func NewKeyLocker(stripes int) *KeyLocker {
+	if stripes <= 0 {
+		stripes = 256
+	}
+	l := &KeyLocker{seed: maphash.MakeSeed(), stripes: make([]chan struct{}, stripes)}
+	for i := range l.stripes {
+		l.stripes[i] = make(chan struct{}, 1)
+	}
+	return l
+}
+
+func (l *KeyLocker) stripe(key []byte) int {
+	return int(maphash.Bytes(l.seed, key) % uint64(len(l.stripes)))
+}
+
+// Lock ждёт блокировку key; отмена ctx прерывает ожидание с ctx.Err().
+func (l *KeyLocker) Lock(ctx context.Context, key []byte) error {
+	select {
+	case l.stripes[l.stripe(key)] <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TryLock берёт блокировку key, только если она свободна.
+func (l *KeyLocker) TryLock(key []byte) bool {
+	select {
+	case l.stripes[l.stripe(key)] <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Unlock снимает блокировку key. Unlock незаблокированного ключа — паника.
+func (l *KeyLocker) Unlock(key []byte) {
+	l.unlockStripe(l.stripe(key))
+}
+
+func (l *KeyLocker) unlockStripe(i int) {
+	select {
+	case <-l.stripes[i]:
+	default:
+		panic("sdk: unlock of unlocked KeyLocker stripe")
+	}
+}
+
+// stripesOf — различные полосы ключей по возрастанию: общий порядок захвата исключает взаимоблокировки.
+func (l *KeyLocker) stripesOf(keys [][]byte) []int {
+	idx := make([]int, 0, len(keys))
+	for _, k := range keys {
+		idx = append(idx, l.stripe(k))
+	}
+	slices.Sort(idx)
+	return slices.Compact(idx)
+}
+
+// LockKeys блокирует все keys (в порядке полос, без взаимоблокировок) и возвращает функцию снятия.
+// При отмене ctx уже взятые блокировки снимаются.
+func (l *KeyLocker) LockKeys(ctx context.Context, keys ...[]byte) (unlock func(), err error) {
+	idx := l.stripesOf(keys)
+	for n, i := range idx {
+		select {
+		case l.stripes[i] <- struct{}{}:
+		case <-ctx.Done():
+			l.unlockStripes(idx[:n])
+			return nil, ctx.Err()
+		}
+	}
+	return func() { l.unlockStripes(idx) }, nil
+}
+
+// TryLockKeys — LockKeys без ожидания: либо берёт все блокировки, либо ни одной.
+func (l *KeyLocker) TryLockKeys(keys ...[]byte) (unlock func(), ok bool) {
+	idx := l.stripesOf(keys)
+	for n, i := range idx {
+		select {
+		case l.stripes[i] <- struct{}{}:
+		default:
+			l.unlockStripes(idx[:n])
+			return nil, false
+		}
+	}
+	return func() { l.unlockStripes(idx) }, true
+}
+
+func (l *KeyLocker) unlockStripes(idx []int) {
+	for _, i := range idx {
+		l.unlockStripe(i)
+	}
+}
+
+type txLockKeysKey struct{}
+
+// WithTxLockKeys кладёт в ctx ключи, которые TxLockKeys вернёт как TxManagerOptions.LockKeysFn.
+func WithTxLockKeys(ctx context.Context, keys ...[]byte) context.Context {
+	return context.WithValue(ctx, txLockKeysKey{}, keys)
+}
+
+// TxLockKeys возвращает ключи из WithTxLockKeys; годится как TxManagerOptions.LockKeysFn.
+func TxLockKeys(ctx context.Context) [][]byte {
+	keys, _ := ctx.Value(txLockKeysKey{}).([][]byte)
+	return keys
+}
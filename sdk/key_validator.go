@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Проверка имён ключей при записи. Все сканы по префиксу полагаются на соглашение об именах
+// (entity:vN:id), и один сервис, записавший ключ не по соглашению, ломает их у всех остальных.
+// Валидатор отклоняет такие ключи до записи в Set/SetContext, SetObject, SetObjectIfVersion,
+// UpdateObject, TxSetObject, WriteBatch и транзакциях RunTx; запись напрямую через DB() и txn.Set
+// он не видит. Служебные ключи Store (с '!' в начале) не проверяются.
+
+// ErrInvalidKey — ключ не прошёл Options.KeyValidator.
+var ErrInvalidKey = errors.New("invalid key")
+
+// EntityVersionIDKey — соглашение entity:vN:id: имя сущности в нижнем регистре, версия схемы и
+// непустой идентификатор (может содержать ':').
+var EntityVersionIDKey = regexp.MustCompile(`^[a-z][a-z0-9_]*:v[0-9]+:[^\s]+$`)
+
+// KeyValidatorOptions — параметры Options.KeyValidator.
+type KeyValidatorOptions struct {
+	// Pattern — регулярное выражение для ключа; якоря ^ и $ задаются в нём самом. nil — не проверять.
+	Pattern *regexp.Regexp
+	// Func — проверка ключа кодом после Pattern; ненулевая ошибка отклоняет запись. nil — не проверять.
+	Func func(key []byte) error
+	// Exempt — префиксы ключей, которые не проверяются (например, наследие до введения соглашения).
+	Exempt []string
+	// OnReject вызывается для каждого отклонённого ключа; nil — только счётчик KeyValidationStats.
+	OnReject func(key []byte, err error)
+}
+
+// KeyValidationError — ключ отклонён валидатором. Оборачивает ErrInvalidKey.
+type KeyValidationError struct {
+	Key []byte
+	// Err — ошибка Func; nil — ключ не соответствует Pattern.
+	Err error
+}
+
+func (e *KeyValidationError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid key %q: %v", e.Key, e.Err)
+	}
+	return fmt.Sprintf("invalid key %q: does not match key pattern", e.Key)
+}
+
+func (e *KeyValidationError) Unwrap() []error {
+	if e.Err != nil {
+		return []error{ErrInvalidKey, e.Err}
+	}
+	return []error{ErrInvalidKey}
+}
+
+// KeyValidationStats — счётчики валидатора ключей.
+type KeyValidationStats struct {
+	// Rejected — отклонённых записей; Bypassed — записей, пропущенных без проверки по WithoutKeyValidation.
+	Rejected uint64
+	Bypassed uint64
+}
+
+type keyValidator struct {
+	opts     KeyValidatorOptions
+	rejected atomic.Uint64
+	bypassed atomic.Uint64
+}
+
+type skipKeyValidationKey struct{}
+
+// WithoutKeyValidation отключает Options.KeyValidator для записей с этим контекстом — для миграций,
+// которые переименовывают ключи старого формата. Такие записи считаются в KeyValidationStats.Bypassed.
+func WithoutKeyValidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipKeyValidationKey{}, true)
+}
+
+// validateKey проверяет ключ записи: ключи служебной области запрещены, остальные проверяет
+// Options.KeyValidator, если он задан.
+func (s *Store) validateKey(ctx context.Context, key []byte) error {
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	v := s.keyValidator
+	if v == nil || (len(key) > 0 && key[0] == '!') {
+		return nil
+	}
+	for _, p := range v.opts.Exempt {
+		if strings.HasPrefix(string(key), p) {
+			return nil
+		}
+	}
+	if skip, _ := ctx.Value(skipKeyValidationKey{}).(bool); skip {
+		v.bypassed.Add(1)
+		return nil
+	}
+	var err error
+	if v.opts.Pattern != nil && !v.opts.Pattern.Match(key) {
+		err = &KeyValidationError{Key: bytes.Clone(key)}
+	} else if v.opts.Func != nil {
+		if ferr := v.opts.Func(key); ferr != nil {
+			err = &KeyValidationError{Key: bytes.Clone(key), Err: ferr}
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	v.rejected.Add(1)
+	if v.opts.OnReject != nil {
+		v.opts.OnReject(key, err)
+	}
+	return err
+}
+
+// KeyValidationStats возвращает счётчики Options.KeyValidator; false — валидатор не задан.
+func (s *Store) KeyValidationStats() (KeyValidationStats, bool) {
+	v := s.keyValidator
+	if v == nil {
+		return KeyValidationStats{}, false
+	}
+	return KeyValidationStats{Rejected: v.rejected.Load(), Bypassed: v.bypassed.Load()}, true
+}
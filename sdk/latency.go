@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"fmt"
+	"math/bits"
+	"sync/atomic"
+	"time"
+)
+
+// Гистограммы задержек Get, Set и коммита транзакций Manager. Статистика кешей не объясняет, что
+// видит пользователь: p99 Get растёт и от компакций, и от GC value-log, и от ожидания лимитов.
+// Гистограмма — HDR-подобная: 32 линейных корзины на каждую степень двойки (погрешность
+// квантиля не больше ~3%), счётчики атомарные, запись без блокировок. Значения накапливаются с
+// открытия Store.
+
+const (
+	latencySubBits  = 5
+	latencySub      = 1 << latencySubBits
+	latencyMaxShift = 35 // до 2^41 нс (~36 минут); больше — в последнюю корзину
+	latencyBuckets  = (latencyMaxShift + 2) * latencySub
+)
+
+type latencyHistogram struct {
+	counts [latencyBuckets]atomic.Uint64
+	sum    atomic.Int64
+	max    atomic.Int64
+}
+
+func latencyBucket(v uint64) int {
+	if v < 2*latencySub {
+		return int(v)
+	}
+	shift := bits.Len64(v) - (latencySubBits + 1)
+	if shift > latencyMaxShift {
+		return latencyBuckets - 1
+	}
+	return (shift+1)*latencySub + int(v>>shift) - latencySub
+}
+
+// latencyBucketHigh — наибольшее значение корзины idx.
+func latencyBucketHigh(idx int) uint64 {
+	if idx < 2*latencySub {
+		return uint64(idx)
+	}
+	shift := idx/latencySub - 1
+	m := uint64(idx%latencySub + latencySub)
+	return (m+1)<<shift - 1
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	v := max(int64(d), 0)
+	h.counts[latencyBucket(uint64(v))].Add(1)
+	h.sum.Add(v)
+	for {
+		cur := h.max.Load()
+		if v <= cur || h.max.CompareAndSwap(cur, v) {
+			return
+		}
+	}
+}
+
+// OpLatency — квантили задержки операции с открытия Store.
+type OpLatency struct {
+	Count uint64
+	// Sum — суммарная длительность всех операций.
+	Sum           time.Duration
+	P50, P95, P99 time.Duration
+	Max           time.Duration
+}
+
+func (l OpLatency) String() string {
+	return fmt.Sprintf("n=%d p50=%s p95=%s p99=%s max=%s", l.Count, l.P50, l.P95, l.P99, l.Max)
+}
+
+func (h *latencyHistogram) snapshot() OpLatency {
+	var counts [latencyBuckets]uint64
+	var total uint64
+	for i := range h.counts {
+		counts[i] = h.counts[i].Load()
+		total += counts[i]
+	}
+	out := OpLatency{Count: total, Sum: time.Duration(h.sum.Load()), Max: time.Duration(h.max.Load())}
+	if total == 0 {
+		return out
+	}
+	quantile := func(q float64) time.Duration {
+		rank := uint64(q*float64(total) + 0.5)
+		rank = min(max(rank, 1), total)
+		var seen uint64
+		for i, c := range counts {
+			seen += c
+			if seen >= rank {
+				return min(time.Duration(latencyBucketHigh(i)), out.Max)
+			}
+		}
+		return out.Max
+	}
+	out.P50, out.P95, out.P99 = quantile(0.50), quantile(0.95), quantile(0.99)
+	return out
+}
+
+type opLatencies struct {
+	get, set, commit latencyHistogram
+}
+
+// LatencyStats — задержки операций Store.
+type LatencyStats struct {
+	// Get и Set — Get/GetContext и Set/SetContext (в том числе SetObject без VersionedObjects), вместе
+	// с ожиданием лимитов и проверкой прав.
+	Get OpLatency
+	Set OpLatency
+	// Commit — коммит транзакций Manager (ExecuteReadWriteWithContext, RunTx и обёртки над ними),
+	// каждая попытка отдельно.
+	Commit OpLatency
+}
+
+// LatencyStats возвращает p50/p95/p99 задержек Get, Set и коммита с открытия Store.
+func (s *Store) LatencyStats() LatencyStats {
+	return LatencyStats{
+		Get:    s.latency.get.snapshot(),
+		Set:    s.latency.set.snapshot(),
+		Commit: s.latency.commit.snapshot(),
+	}
+}
@@ -0,0 +1,347 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Выбор единственного писателя между процессами одной машины. Badger держит каталог под
+// эксклюзивным flock, пока он открыт на запись, и не даёт открыть его ни на запись, ни на чтение
+// второму процессу. LocalLeaderElector добавляет к этому выбор лидера:
+//
+//   - лидер — процесс, взявший flock файла <Dir>.leader. Он открывает Store на запись и раз в
+//     Heartbeat пишет пульс: служебный ключ "!sys:leader:info" в Store и тот же JSON в файл блокировки;
+//   - остальные — последователи. Раз в Heartbeat они пробуют взять flock: ядро снимает его, когда
+//     лидер умирает, и первый успевший последователь становится лидером без перезапуска;
+//   - пока лидер жив, живой каталог последователю недоступен. С ReplicaInterval лидер периодически
+//     публикует полный снимок в ReplicaPath, а последователи держат его копию в памяти как реплику
+//     только для чтения: она отстаёт не больше чем на ReplicaInterval плюс Heartbeat.
+//
+// Зависший, но живой лидер flock не отпускает: последователи видят устаревший пульс в Leader(),
+// но перехватить запись не могут. Новый лидер получает то, что Badger успел сохранить: без
+// Options.SyncWrites последние записи упавшего лидера могут потеряться.
+
+// LeaderRole — роль процесса в LocalLeaderElector.
+type LeaderRole string
+
+const (
+	RoleFollower LeaderRole = "follower"
+	RoleLeader   LeaderRole = "leader"
+)
+
+// Пульс хранится в служебной области (SystemKeys), чтобы пользовательские сканы и удаления его не видели.
+const (
+	leaderNamespace = "leader"
+	leaderInfoName  = "info"
+)
+
+// legacyLeaderKey — прежний ключ пульса вне служебной области: из него читается срок при переходе,
+// после чего ключ удаляется.
+var legacyLeaderKey = []byte("!leader")
+
+// LocalLeaderOptions — параметры NewLocalLeaderElector.
+type LocalLeaderOptions struct {
+	// Store — параметры Store лидера; Dir обязателен, InMemory и ReadOnly не допускаются.
+	Store Options
+	Limit *MemoryLimit
+	// ID — имя процесса в пульсе, по умолчанию hostname:pid.
+	ID string
+	// Heartbeat — период пульса лидера и попыток последователей взять блокировку, по умолчанию 1s.
+	Heartbeat time.Duration
+	// ReplicaInterval — как часто лидер публикует снимок для реплик; 0 — без реплик, у
+	// последователя нет Store.
+	ReplicaInterval time.Duration
+	// ReplicaPath — файл снимка, по умолчанию <Dir>.replica.gz. Снимок не шифруется (права 0600).
+	ReplicaPath string
+	// OnRole вызывается при каждой смене роли или Store: store — Store лидера либо реплика
+	// последователя (nil, если реплики нет). Прежний Store закрывается после возврата из OnRole.
+	OnRole func(role LeaderRole, store *Store)
+	// OnError получает ошибки пульса и реплик, которые не останавливают Run; nil — стандартный log.
+	OnError func(error)
+}
+
+// LeaderInfo — пульс лидера.
+type LeaderInfo struct {
+	ID   string    `json:"id"`
+	Term uint64    `json:"term"`
+	At   time.Time `json:"at"`
+}
+
+// LocalLeaderElector выбирает среди процессов машины одного писателя каталога Store.
+type LocalLeaderElector struct {
+	opts     LocalLeaderOptions
+	clock    Clock
+	lockPath string
+
+	mu         sync.RWMutex
+	role       LeaderRole
+	store      *Store
+	replicaMod time.Time
+}
+
+func NewLocalLeaderElector(opts LocalLeaderOptions) (*LocalLeaderElector, error) {
+	if opts.Store.Dir == "" {
+		return nil, errors.New("leader election needs Store.Dir")
+	}
+	if opts.Store.InMemory || opts.Store.ReadOnly {
+		return nil, errors.New("leader election needs a writable on-disk store")
+	}
+	if opts.ID == "" {
+		host, _ := os.Hostname()
+		opts.ID = fmt.Sprintf("%s:%d", host, os.Getpid())
+	}
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = time.Second
+	}
+	dir := filepath.Clean(opts.Store.Dir)
+	if opts.ReplicaPath == "" {
+		opts.ReplicaPath = dir + ".replica.gz"
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("[Badger] leader election: %v", err)
+		}
+	}
+	clock := opts.Store.Clock
+	if clock == nil {
+		clock = NewRealClock()
+	}
+	return &LocalLeaderElector{opts: opts, clock: clock, lockPath: dir + ".leader"}, nil
+}
+
+// Role возвращает текущую роль; до первого шага Run и после его завершения — пустую.
+func (e *LocalLeaderElector) Role() LeaderRole {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.role
+}
+
+// Store возвращает Store лидера, реплику последователя или nil.
+func (e *LocalLeaderElector) Store() *Store {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.store
+}
+
+// Leader читает последний пульс лидера из файла блокировки.
+func (e *LocalLeaderElector) Leader() (LeaderInfo, error) {
+	data, err := os.ReadFile(e.lockPath)
+	if err != nil {
+		return LeaderInfo{}, err
+	}
+	if len(data) == 0 {
+		return LeaderInfo{}, ErrNotFound
+	}
+	var info LeaderInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return LeaderInfo{}, fmt.Errorf("parse leader heartbeat: %w", err)
+	}
+	return info, nil
+}
+
+// Run участвует в выборах до отмены ctx: последователь ждёт блокировку, лидер держит её и Store.
+// Возвращает nil после отмены ctx (Store закрыт, блокировка отпущена) или ошибку, с которой
+// участвовать дальше нельзя, например Store лидера не открылся.
+func (e *LocalLeaderElector) Run(ctx context.Context) error {
+	f, err := os.OpenFile(e.lockPath, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("open leader lock: %w", err)
+	}
+	defer f.Close()
+	defer e.swap("", nil)
+
+	ticker := e.clock.NewTicker(e.opts.Heartbeat)
+	defer ticker.Stop()
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			return fmt.Errorf("leader lock: %w", err)
+		}
+		if locked {
+			return e.lead(ctx, f)
+		}
+		e.follow()
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
+
+// swap меняет роль и Store, уведомляет OnRole и закрывает прежний Store.
+func (e *LocalLeaderElector) swap(role LeaderRole, s *Store) {
+	e.mu.Lock()
+	old, oldRole := e.store, e.role
+	e.role, e.store = role, s
+	e.mu.Unlock()
+	if role == oldRole && s == old {
+		return
+	}
+	if e.opts.OnRole != nil && role != "" {
+		e.opts.OnRole(role, s)
+	}
+	if old != nil {
+		if err := old.Close(); err != nil {
+			e.opts.OnError(fmt.Errorf("close %s store: %w", oldRole, err))
+		}
+	}
+}
+
+func (e *LocalLeaderElector) lead(ctx context.Context, f *os.File) error {
+	defer unlockFile(f)
+	e.releaseStoreName()
+	s, err := Open(ctx, e.opts.Store, e.opts.Limit)
+	if err != nil {
+		return fmt.Errorf("open store as leader: %w", err)
+	}
+	prev, err := readLeaderInfo(s)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		e.opts.OnError(fmt.Errorf("read previous term: %w", err))
+	}
+	info := LeaderInfo{ID: e.opts.ID, Term: prev.Term + 1}
+	if err := e.heartbeat(s, f, &info); err != nil {
+		_ = s.Close()
+		return fmt.Errorf("first heartbeat: %w", err)
+	}
+	if err := s.db.Update(func(txn *badger.Txn) error { return txn.Delete(legacyLeaderKey) }); err != nil {
+		e.opts.OnError(fmt.Errorf("delete legacy leader key: %w", err))
+	}
+	e.swap(RoleLeader, s)
+	defer func() {
+		_ = f.Truncate(0)
+	}()
+
+	var replica <-chan time.Time
+	if e.opts.ReplicaInterval > 0 {
+		t := e.clock.NewTicker(e.opts.ReplicaInterval)
+		defer t.Stop()
+		replica = t.C()
+		e.publishReplica(ctx, s)
+	}
+	ticker := e.clock.NewTicker(e.opts.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			e.swap("", nil)
+			return nil
+		case <-ticker.C():
+			if err := e.heartbeat(s, f, &info); err != nil {
+				e.opts.OnError(fmt.Errorf("heartbeat: %w", err))
+			}
+		case <-replica:
+			e.publishReplica(ctx, s)
+		}
+	}
+}
+
+func (e *LocalLeaderElector) heartbeat(s *Store, f *os.File, info *LeaderInfo) error {
+	info.At = e.clock.Now()
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	if err := NewSystemKeys(s, leaderNamespace).Set(leaderInfoName, info); err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, 0)
+	return err
+}
+
+// readLeaderInfo читает последний пульс из Store, а если его нет — из legacyLeaderKey.
+func readLeaderInfo(s *Store) (LeaderInfo, error) {
+	var info LeaderInfo
+	err := NewSystemKeys(s, leaderNamespace).Get(leaderInfoName, &info)
+	if !errors.Is(err, ErrNotFound) {
+		return info, err
+	}
+	err = s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(legacyLeaderKey)
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &info)
+		})
+	})
+	return info, err
+}
+
+// publishReplica пишет снимок во временный файл и атомарно подменяет им ReplicaPath.
+func (e *LocalLeaderElector) publishReplica(ctx context.Context, s *Store) {
+	tmp := e.opts.ReplicaPath + ".tmp"
+	if _, err := s.FullBackupToFile(ctx, tmp); err != nil {
+		e.opts.OnError(fmt.Errorf("publish replica: %w", err))
+		return
+	}
+	if err := os.Chmod(tmp, 0o600); err != nil {
+		e.opts.OnError(fmt.Errorf("publish replica: %w", err))
+		return
+	}
+	if err := os.Rename(tmp, e.opts.ReplicaPath); err != nil {
+		e.opts.OnError(fmt.Errorf("publish replica: %w", err))
+	}
+}
+
+// releaseStoreName освобождает Options.StoreName текущего Store до открытия преемника; сам Store
+// закрывается в swap, после OnRole.
+func (e *LocalLeaderElector) releaseStoreName() {
+	if s := e.Store(); s != nil {
+		s.releaseName()
+	}
+}
+
+// follow подгружает свежий снимок лидера в реплику в памяти.
+func (e *LocalLeaderElector) follow() {
+	if e.opts.ReplicaInterval <= 0 {
+		e.swap(RoleFollower, nil)
+		return
+	}
+	st, err := os.Stat(e.opts.ReplicaPath)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			e.opts.OnError(fmt.Errorf("stat replica: %w", err))
+		}
+		e.swap(RoleFollower, e.Store())
+		return
+	}
+	if !st.ModTime().After(e.replicaMod) {
+		return
+	}
+	opts := e.opts.Store
+	opts.InMemory = true
+	opts.Dir, opts.ValueDir = "", ""
+	opts.EncryptionKey = nil
+	opts.AutoValueThreshold = false
+	opts.GCInterval = 0
+	if opts.StoreName != "" {
+		opts.StoreName += "/replica"
+	}
+	// прежняя реплика обслуживает чтения, пока не готова новая, но имя должна отдать ей заранее
+	e.releaseStoreName()
+	replica, err := Open(context.Background(), opts, e.opts.Limit)
+	if err != nil {
+		e.opts.OnError(fmt.Errorf("open replica: %w", err))
+		return
+	}
+	if err := replica.restoreFromFile(context.Background(), e.opts.ReplicaPath, nil); err != nil {
+		_ = replica.Close()
+		e.opts.OnError(fmt.Errorf("load replica: %w", err))
+		return
+	}
+	e.replicaMod = st.ModTime()
+	e.swap(RoleFollower, replica)
+}
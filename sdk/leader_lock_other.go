@@ -0,0 +1,18 @@
+//go:build !unix
+
+package sdk
+
+import (
+	"errors"
+	"os"
+)
+
+var errNoFlock = errors.New("local leader election needs flock, unsupported on this platform")
+
+func tryLockFile(*os.File) (bool, error) {
+	return false, errNoFlock
+}
+
+func unlockFile(*os.File) error {
+	return errNoFlock
+}
@@ -0,0 +1,23 @@
+//go:build unix
+
+package sdk
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLockFile берёт эксклюзивный flock без ожидания; false — его держит другой процесс.
+// Ядро снимает flock при смерти процесса, поэтому упавший лидер не оставляет блокировку.
+func tryLockFile(f *os.File) (bool, error) {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
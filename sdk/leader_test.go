@@ -0,0 +1,68 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+func TestLocalLeaderElector_LeaderRecordIsSystemKey(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	// каталог с пульсом прежнего формата: срок продолжается, а старый ключ исчезает
+	s, err := Open(context.Background(), Options{Dir: dir}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(LeaderInfo{ID: "old", Term: 4})
+	if err := s.db.Update(func(txn *badger.Txn) error { return txn.Set(legacyLeaderKey, data) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewLocalLeaderElector(LocalLeaderOptions{
+		Store:     Options{Dir: dir},
+		ID:        "a",
+		Heartbeat: 20 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.Store() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("elector did not become leader")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s = e.Store()
+
+	var keys []string
+	if err := s.ScanPrefix(nil, 0, func(kv KV) error {
+		keys = append(keys, string(kv.Key))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("user scan sees %q", keys)
+	}
+	info, err := GetSystem[LeaderInfo](NewSystemKeys(s, leaderNamespace), leaderInfoName)
+	if err != nil || info.ID != "a" || info.Term != 5 {
+		t.Fatalf("leader record = %+v, %v", info, err)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+}
@@ -0,0 +1,295 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Раскладка ключей списка (N — имя списка):
+//
+//	"list:" + N + "\x00h"        → head, tail (по 8 байт): элементы занимают позиции [head, tail)
+//	"list:" + N + "\x00i" + pos  → значение элемента
+//
+// pos — int64 со сдвинутым знаковым битом (big-endian), поэтому порядок ключей совпадает с порядком
+// позиций: LPush уменьшает head, RPush увеличивает tail, и значения не переписываются.
+
+type ListOptions struct {
+	// MaxLen — предельная длина; при превышении push обрезает список с противоположного конца.
+	// 0 — без ограничения.
+	MaxLen int
+	// TTL — срок жизни каждого элемента с момента его добавления, 0 — бессрочно.
+	// Истёкшие элементы пропускаются при чтении и убираются с концов списка при следующей записи.
+	TTL time.Duration
+}
+
+// List — персистентный список значений (аналог Redis LIST) с ограничением длины.
+type List struct {
+	store *Store
+	tx    *Manager
+	opts  ListOptions
+	base  string
+}
+
+func NewList(store *Store, name string, opts ListOptions) (*List, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if name == "" || strings.IndexByte(name, 0) >= 0 {
+		return nil, fmt.Errorf("invalid list name %q", name)
+	}
+	if opts.MaxLen < 0 {
+		return nil, errors.New("list MaxLen must be >= 0")
+	}
+	return &List{store: store, tx: NewTransactionManager(store), opts: opts, base: "list:" + name + "\x00"}, nil
+}
+
+func (l *List) metaKey() []byte {
+	return []byte(l.base + "h")
+}
+
+func (l *List) itemPrefix() []byte {
+	return []byte(l.base + "i")
+}
+
+func (l *List) itemKey(pos int64) []byte {
+	return binary.BigEndian.AppendUint64(l.itemPrefix(), uint64(pos)^(1<<63))
+}
+
+func (l *List) txBounds(txn *badger.Txn) (head, tail int64, err error) {
+	item, err := txn.Get(l.metaKey())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	err = item.Value(func(val []byte) error {
+		if len(val) != 16 {
+			return errors.New("list: corrupted header")
+		}
+		head = int64(binary.BigEndian.Uint64(val[:8]))
+		tail = int64(binary.BigEndian.Uint64(val[8:]))
+		return nil
+	})
+	return head, tail, err
+}
+
+func (l *List) txSetBounds(txn *badger.Txn, head, tail int64) error {
+	if head == tail {
+		return txn.Delete(l.metaKey())
+	}
+	val := binary.BigEndian.AppendUint64(nil, uint64(head))
+	return txn.Set(l.metaKey(), binary.BigEndian.AppendUint64(val, uint64(tail)))
+}
+
+// txAlive — есть ли живой элемент в позиции pos.
+func (l *List) txAlive(txn *badger.Txn, pos int64) (bool, error) {
+	item, err := txn.Get(l.itemKey(pos))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !l.store.expired(item), nil
+}
+
+// txDropExpired сдвигает границы за истёкшие элементы на обоих концах.
+func (l *List) txDropExpired(txn *badger.Txn, head, tail int64) (int64, int64, error) {
+	if l.opts.TTL <= 0 {
+		return head, tail, nil
+	}
+	for head < tail {
+		ok, err := l.txAlive(txn, head)
+		if err != nil {
+			return head, tail, err
+		}
+		if ok {
+			break
+		}
+		if err := txn.Delete(l.itemKey(head)); err != nil {
+			return head, tail, err
+		}
+		head++
+	}
+	for head < tail {
+		ok, err := l.txAlive(txn, tail-1)
+		if err != nil {
+			return head, tail, err
+		}
+		if ok {
+			break
+		}
+		if err := txn.Delete(l.itemKey(tail - 1)); err != nil {
+			return head, tail, err
+		}
+		tail--
+	}
+	return head, tail, nil
+}
+
+func (l *List) push(ctx context.Context, left bool, values [][]byte) (int, error) {
+	var length int
+	err := l.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		head, tail, err := l.txBounds(txn)
+		if err != nil {
+			return err
+		}
+		if head, tail, err = l.txDropExpired(txn, head, tail); err != nil {
+			return err
+		}
+		for _, v := range values {
+			var pos int64
+			if left {
+				head--
+				pos = head
+			} else {
+				pos = tail
+				tail++
+			}
+			if err := txn.SetEntry(l.store.NewEntry(l.itemKey(pos), v, l.opts.TTL)); err != nil {
+				return err
+			}
+		}
+		// обрезаем с противоположного конца: новые элементы вытесняют старые
+		for l.opts.MaxLen > 0 && tail-head > int64(l.opts.MaxLen) {
+			var pos int64
+			if left {
+				tail--
+				pos = tail
+			} else {
+				pos = head
+				head++
+			}
+			if err := txn.Delete(l.itemKey(pos)); err != nil {
+				return err
+			}
+		}
+		length = int(tail - head)
+		return l.txSetBounds(txn, head, tail)
+	})
+	return length, err
+}
+
+// LPush добавляет значения в начало списка (последнее из values окажется первым) и возвращает новую длину.
+func (l *List) LPush(ctx context.Context, values ...[]byte) (int, error) {
+	return l.push(ctx, true, values)
+}
+
+// RPush добавляет значения в конец списка и возвращает новую длину.
+func (l *List) RPush(ctx context.Context, values ...[]byte) (int, error) {
+	return l.push(ctx, false, values)
+}
+
+// Len — длина списка. При TTL может учитывать истёкшие элементы, ещё не убранные записью.
+func (l *List) Len() (int, error) {
+	var n int
+	err := l.store.db.View(func(txn *badger.Txn) error {
+		head, tail, err := l.txBounds(txn)
+		n = int(tail - head)
+		return err
+	})
+	return n, err
+}
+
+// normalizeRange переводит индексы в стиле Redis (включительно, отрицательные — от конца) в позиции [from, to).
+func normalizeRange(head, tail int64, start, stop int) (int64, int64) {
+	n := tail - head
+	s, e := int64(start), int64(stop)
+	if s < 0 {
+		s += n
+	}
+	if e < 0 {
+		e += n
+	}
+	s = max(s, 0)
+	e = min(e, n-1)
+	if s > e {
+		return head, head
+	}
+	return head + s, head + e + 1
+}
+
+// LRange возвращает элементы с индексами [start, stop] включительно; отрицательный индекс
+// отсчитывается от конца (-1 — последний). Истёкшие элементы пропускаются.
+func (l *List) LRange(start, stop int) ([][]byte, error) {
+	var out [][]byte
+	err := l.store.db.View(func(txn *badger.Txn) error {
+		head, tail, err := l.txBounds(txn)
+		if err != nil {
+			return err
+		}
+		from, to := normalizeRange(head, tail, start, stop)
+		if from == to {
+			return nil
+		}
+		prefix := l.itemPrefix()
+		end := l.itemKey(to)
+		it := txn.NewIterator(badger.IteratorOptions{Prefix: prefix, PrefetchValues: true, PrefetchSize: int(min(to-from, 100))})
+		defer it.Close()
+		for it.Seek(l.itemKey(from)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if bytes.Compare(item.Key(), end) >= 0 {
+				break
+			}
+			if l.store.expired(item) {
+				continue
+			}
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			out = append(out, v)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// LTrim оставляет только элементы с индексами [start, stop] (индексы как в LRange).
+// Удаление идёт порциями в отдельных транзакциях, поэтому на больших списках операция не атомарна.
+func (l *List) LTrim(ctx context.Context, start, stop int) error {
+	const chunk = 1000
+	// целевой диапазон фиксируем по первому снимку, иначе порции сдвигали бы индексы
+	var keepFrom, keepTo int64
+	if err := l.store.db.View(func(txn *badger.Txn) error {
+		head, tail, err := l.txBounds(txn)
+		keepFrom, keepTo = normalizeRange(head, tail, start, stop)
+		return err
+	}); err != nil {
+		return err
+	}
+	for {
+		done := false
+		err := l.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+			head, tail, err := l.txBounds(txn)
+			if err != nil {
+				return err
+			}
+			// элементы, дописанные между порциями за пределы исходного диапазона, тоже обрезаются
+			n := 0
+			for ; head < tail && head < keepFrom && n < chunk; head, n = head+1, n+1 {
+				if err := txn.Delete(l.itemKey(head)); err != nil {
+					return err
+				}
+			}
+			for ; head < tail && tail > keepTo && n < chunk; tail, n = tail-1, n+1 {
+				if err := txn.Delete(l.itemKey(tail - 1)); err != nil {
+					return err
+				}
+			}
+			done = n < chunk
+			return l.txSetBounds(txn, head, tail)
+		})
+		if err != nil || done {
+			return err
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package sdk
+
+import (
+	"math"
+	"runtime/debug"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Реакция кешей на давление памяти. Блочный и индексный кеши Badger (Ristretto) держат свой размер
+// независимо от того, сколько памяти занято остальным процессом, и под GOMEMLIMIT Go-сборщик не может
+// их освободить: куча растёт до OOM. Наблюдатель сравнивает память процесса в понимании рантайма
+// (то, что ограничивает debug.SetMemoryLimit) с пределом и при превышении High ужимает предельные
+// размеры кешей вдвое (не ниже MinFraction исходных) и просит рантайм вернуть память ОС, а при падении
+// ниже Low постепенно возвращает размеры. Ristretto вытесняет лишнее при следующих вставках, поэтому
+// ужатый кеш освобождает память по мере чтений, а не мгновенно.
+
+// MemoryPressureOptions — параметры Options.MemoryPressure.
+type MemoryPressureOptions struct {
+	// Limit — предел памяти процесса в байтах; 0 — текущий debug.SetMemoryLimit (GOMEMLIMIT).
+	// Если предела нет ни там, ни здесь, наблюдатель не запускается.
+	Limit int64
+	// High — доля Limit, выше которой кеши ужимаются, по умолчанию 0.85; Low — ниже которой
+	// восстанавливаются, по умолчанию 0.7.
+	High float64
+	Low  float64
+	// MinFraction — до какой доли исходных размеров можно ужать кеши, по умолчанию 0.1.
+	MinFraction float64
+	// Interval — период проверки, по умолчанию 1s.
+	Interval time.Duration
+	// OnChange вызывается после каждого изменения размеров кешей.
+	OnChange func(MemoryPressureStats)
+}
+
+// MemoryPressureStats — состояние наблюдателя давления памяти.
+type MemoryPressureStats struct {
+	Used  int64
+	Limit int64
+	// Scale — текущая доля исходных размеров кешей.
+	Scale           float64
+	BlockCacheBytes int64
+	IndexCacheBytes int64
+	Shrinks         uint64
+	Restores        uint64
+}
+
+type memoryWatcher struct {
+	opts      MemoryPressureOptions
+	baseBlock int64
+	baseIndex int64
+	samples   []metrics.Sample
+	mu        sync.Mutex
+	stats     MemoryPressureStats
+}
+
+func (o *MemoryPressureOptions) normalize() {
+	if o.Limit <= 0 {
+		if l := debug.SetMemoryLimit(-1); l != math.MaxInt64 {
+			o.Limit = l
+		}
+	}
+	if o.High <= 0 || o.High > 1 {
+		o.High = 0.85
+	}
+	if o.Low <= 0 || o.Low >= o.High {
+		o.Low = min(0.7, o.High*0.8)
+	}
+	if o.MinFraction <= 0 || o.MinFraction > 1 {
+		o.MinFraction = 0.1
+	}
+	if o.Interval <= 0 {
+		o.Interval = time.Second
+	}
+}
+
+// startMemoryPressure запускает наблюдателя до Close; без предела памяти ничего не делает.
+func (s *Store) startMemoryPressure(opts MemoryPressureOptions) {
+	opts.normalize()
+	if opts.Limit <= 0 {
+		return
+	}
+	w := &memoryWatcher{
+		opts: opts,
+		samples: []metrics.Sample{
+			{Name: "/memory/classes/total:bytes"},
+			{Name: "/memory/classes/heap/released:bytes"},
+		},
+	}
+	w.baseBlock, _ = s.db.CacheMaxCost(badger.BlockCache, -1)
+	w.baseIndex, _ = s.db.CacheMaxCost(badger.IndexCache, -1)
+	w.stats = MemoryPressureStats{Limit: opts.Limit, Scale: 1, BlockCacheBytes: w.baseBlock, IndexCacheBytes: w.baseIndex}
+	s.memWatch.Store(w)
+	go func() {
+		t := s.clock.NewTicker(opts.Interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.bg.Done():
+				return
+			case <-t.C():
+				s.checkMemoryPressure(w)
+			}
+		}
+	}()
+}
+
+func (s *Store) checkMemoryPressure(w *memoryWatcher) {
+	metrics.Read(w.samples)
+	used := int64(w.samples[0].Value.Uint64() - w.samples[1].Value.Uint64())
+	ratio := float64(used) / float64(w.opts.Limit)
+
+	w.mu.Lock()
+	w.stats.Used = used
+	scale := w.stats.Scale
+	switch {
+	case ratio > w.opts.High && scale > w.opts.MinFraction:
+		scale = max(scale/2, w.opts.MinFraction)
+		w.stats.Shrinks++
+	case ratio < w.opts.Low && scale < 1:
+		scale = min(scale*1.5, 1)
+		w.stats.Restores++
+	default:
+		w.mu.Unlock()
+		return
+	}
+	w.stats.Scale = scale
+	w.stats.BlockCacheBytes = int64(float64(w.baseBlock) * scale)
+	w.stats.IndexCacheBytes = int64(float64(w.baseIndex) * scale)
+	stats := w.stats
+	w.mu.Unlock()
+
+	if w.baseBlock > 0 {
+		_, _ = s.db.CacheMaxCost(badger.BlockCache, stats.BlockCacheBytes)
+	}
+	if w.baseIndex > 0 {
+		_, _ = s.db.CacheMaxCost(badger.IndexCache, stats.IndexCacheBytes)
+	}
+	if ratio > w.opts.High {
+		debug.FreeOSMemory()
+	}
+	if w.opts.OnChange != nil {
+		w.opts.OnChange(stats)
+	}
+}
+
+// MemoryPressureStats возвращает состояние наблюдателя Options.MemoryPressure; ok=false — он не запущен.
+func (s *Store) MemoryPressureStats() (stats MemoryPressureStats, ok bool) {
+	w := s.memWatch.Load()
+	if w == nil {
+		return stats, false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.stats, true
+}
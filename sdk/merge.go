@@ -0,0 +1,260 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Слияние операндов средствами Badger (MergeOperator): Merge дописывает операнд новой версией ключа
+// без чтения, а функция слияния сворачивает версии при чтении GetMerged и в фоне раз в interval,
+// записывая результат одной версией. Так счётчики и append-only списки обходятся без транзакций
+// чтение-изменение-запись и без конфликтов. Badger держит MergeOperator и горутину на каждый ключ,
+// поэтому операторы создаются при первом Merge ключа и останавливаются (со свёрткой) после
+// mergeIdleAfter без записей, а все оставшиеся — в Close.
+//
+// Обычный Get такого ключа возвращает только последний операнд — читайте через GetMerged.
+
+// ErrNoMerge — для ключа не зарегистрирована функция слияния.
+var ErrNoMerge = errors.New("no merge function registered for key")
+
+// MergeFunc сливает накопленное значение existing с более новым операндом. Не должна сохранять или
+// возвращать existing и operand: Badger переиспользует их буферы.
+type MergeFunc func(existing, operand []byte) []byte
+
+const (
+	defaultMergeInterval = time.Minute
+	mergeIdleAfter       = 5 * time.Minute
+)
+
+type prefixMerge struct {
+	prefix   []byte
+	fn       MergeFunc
+	interval time.Duration
+}
+
+type activeMerge struct {
+	op       *badger.MergeOperator
+	lastUsed time.Time
+}
+
+type mergeRegistry struct {
+	mu       sync.Mutex
+	prefixes []prefixMerge // по убыванию длины префикса
+	ops      map[string]*activeMerge
+	once     sync.Once
+}
+
+// RegisterMerge назначает fn ключам с префиксом prefix (самый длинный совпавший префикс) для Merge и
+// GetMerged; interval — период фоновой свёртки операндов ключа (<= 0 — минута). Повторная регистрация
+// префикса заменяет функцию для ключей, оператор которых ещё не создан. Готовые функции —
+// MergeUint64Add и MergeAppend.
+func (s *Store) RegisterMerge(prefix []byte, fn MergeFunc, interval time.Duration) {
+	if fn == nil {
+		panic("merge fn must be not nil")
+	}
+	if interval <= 0 {
+		interval = defaultMergeInterval
+	}
+	m := &s.merges
+	m.mu.Lock()
+	prefixes := make([]prefixMerge, 0, len(m.prefixes)+1)
+	for _, p := range m.prefixes {
+		if !bytes.Equal(p.prefix, prefix) {
+			prefixes = append(prefixes, p)
+		}
+	}
+	prefixes = append(prefixes, prefixMerge{prefix: bytes.Clone(prefix), fn: fn, interval: interval})
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i].prefix) > len(prefixes[j].prefix)
+	})
+	m.prefixes = prefixes
+	if m.ops == nil {
+		m.ops = make(map[string]*activeMerge)
+	}
+	m.mu.Unlock()
+
+	m.once.Do(func() {
+		go s.runMergeSweeper()
+	})
+}
+
+// Merge дописывает operand к ключу, функция слияния которого назначена RegisterMerge; иначе ErrNoMerge.
+// Principal для AccessController берётся из ctx (WithPrincipal).
+func (s *Store) Merge(ctx context.Context, key, operand []byte) error {
+	if err := s.checkAccess(ctx, AccessWrite, key); err != nil {
+		return err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	if err := s.validateKey(ctx, key); err != nil {
+		return err
+	}
+	op, err := s.mergeOperator(key)
+	if err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	s.noteWrite(key)
+	return op.Add(operand)
+}
+
+// GetMerged возвращает результат свёртки всех операндов ключа; ErrNotFound — операндов нет.
+func (s *Store) GetMerged(ctx context.Context, key []byte) ([]byte, error) {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return nil, err
+	}
+	m := &s.merges
+	m.mu.Lock()
+	p, ok := m.lookup(key)
+	active := m.ops[string(key)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoMerge, key)
+	}
+	s.noteRead(key)
+	if active != nil {
+		// свёртка оператора держит его лок — читаем через него, чтобы не застать половину записи
+		return active.op.Get()
+	}
+	var out []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		out, err = foldMergeVersions(txn, key, p.fn)
+		return err
+	})
+	return out, err
+}
+
+// mergeOperator возвращает оператор ключа, создавая его при первом обращении.
+func (s *Store) mergeOperator(key []byte) (*badger.MergeOperator, error) {
+	m := &s.merges
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.ops[string(key)]; ok {
+		a.lastUsed = s.clock.Now()
+		return a.op, nil
+	}
+	p, ok := m.lookup(key)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrNoMerge, key)
+	}
+	op := s.db.GetMergeOperator(bytes.Clone(key), badger.MergeFunc(p.fn), p.interval)
+	m.ops[string(key)] = &activeMerge{op: op, lastUsed: s.clock.Now()}
+	return op, nil
+}
+
+// lookup вызывается под m.mu.
+func (m *mergeRegistry) lookup(key []byte) (prefixMerge, bool) {
+	for _, p := range m.prefixes {
+		if bytes.HasPrefix(key, p.prefix) {
+			return p, true
+		}
+	}
+	return prefixMerge{}, false
+}
+
+func (s *Store) runMergeSweeper() {
+	ticker := s.clock.NewTicker(mergeIdleAfter)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.bg.Done():
+			return
+		case <-ticker.C():
+		}
+		s.stopMergeOperators(s.clock.Now().Add(-mergeIdleAfter))
+	}
+}
+
+// stopMergeOperators останавливает операторы, не получавшие Merge с idleSince; Stop выполняет
+// последнюю свёртку. Нулевое idleSince останавливает все (Close).
+func (s *Store) stopMergeOperators(idleSince time.Time) {
+	m := &s.merges
+	var stop []*badger.MergeOperator
+	m.mu.Lock()
+	for k, a := range m.ops {
+		if idleSince.IsZero() || a.lastUsed.Before(idleSince) {
+			stop = append(stop, a.op)
+			delete(m.ops, k)
+		}
+	}
+	m.mu.Unlock()
+	for _, op := range stop {
+		op.Stop()
+	}
+}
+
+// foldMergeVersions сворачивает версии ключа от старых к новым, как MergeOperator.Get, не создавая
+// оператор (и его горутину) ради чтения.
+func foldMergeVersions(txn *badger.Txn, key []byte, fn MergeFunc) ([]byte, error) {
+	opts := badger.DefaultIteratorOptions
+	opts.AllVersions = true
+	it := txn.NewKeyIterator(key, opts)
+	defer it.Close()
+	var (
+		out   []byte
+		found bool
+	)
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		if item.IsDeletedOrExpired() {
+			break
+		}
+		if !found {
+			// итератор идёт от новых версий к старым: первая — самый новый операнд
+			v, err := item.ValueCopy(nil)
+			if err != nil {
+				return nil, err
+			}
+			out, found = v, true
+		} else if err := item.Value(func(old []byte) error {
+			out = fn(old, out)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		if item.DiscardEarlierVersions() {
+			break
+		}
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// MergeUint64Add складывает 8-байтовые big-endian счётчики (операнд — приращение, см. Uint64Operand).
+func MergeUint64Add(existing, operand []byte) []byte {
+	return Uint64Operand(decodeUint64(existing) + decodeUint64(operand))
+}
+
+// Uint64Operand кодирует приращение для MergeUint64Add; результат GetMerged читается
+// binary.BigEndian.Uint64.
+func Uint64Operand(v uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, v)
+}
+
+func decodeUint64(b []byte) uint64 {
+	if len(b) != 8 {
+		return 0
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// MergeAppend дописывает операнд в конец значения (append-only список; разделитель — в самих операндах).
+func MergeAppend(existing, operand []byte) []byte {
+	out := make([]byte, 0, len(existing)+len(operand))
+	out = append(out, existing...)
+	return append(out, operand...)
+}
@@ -0,0 +1,337 @@
+package sdk
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// Метрики в формате текстового экспозиционного формата Prometheus для textfile-коллектора
+// node_exporter: там, где нет эндпоинта для скрейпа, Store периодически переписывает файл *.prom,
+// а node_exporter отдаёт его вместе со своими метриками. Файл пишется во временный рядом и
+// переименовывается, поэтому коллектор никогда не видит его недописанным.
+
+// MetricsTextfileOptions — параметры Options.MetricsTextfile.
+type MetricsTextfileOptions struct {
+	// Path — файл метрик, обычно в каталоге --collector.textfile.directory; должен оканчиваться на .prom.
+	Path string
+	// Interval — период записи, по умолчанию 1m.
+	Interval time.Duration
+	// Prefixes — добавлять число ключей и объём по префиксам из PrefixReport. Отчёт — полный проход
+	// по ключам, поэтому пересчитывается реже, раз в PrefixInterval (по умолчанию 10m), а между
+	// пересчётами пишется последний.
+	Prefixes       bool
+	PrefixInterval time.Duration
+	PrefixReport   PrefixReportOptions
+	// Labels — постоянные метки всех метрик, например {"store": "orders"}.
+	Labels map[string]string
+	// OnError получает ошибки записи; nil — стандартный log.
+	OnError func(error)
+}
+
+// WriteMetrics пишет метрики Store в текстовом формате Prometheus. prefixes может быть nil.
+// С Options.StoreName ко всем метрикам добавляется метка store, если её нет в labels.
+func (s *Store) WriteMetrics(w io.Writer, prefixes *PrefixReportResult, labels map[string]string) error {
+	p := &promWriter{w: bufio.NewWriter(w), labels: formatLabels(s.metricLabels(labels))}
+
+	lsm, vlog := s.db.Size()
+	p.gauge("memory_storage_lsm_bytes", "Size of LSM tables on disk.", float64(lsm))
+	p.gauge("memory_storage_vlog_bytes", "Size of value log files on disk.", float64(vlog))
+
+	bc, ic := s.db.BlockCacheMetrics(), s.db.IndexCacheMetrics()
+	opts := s.db.Opts()
+	// у отключённого кеша метрик нет (nil); методы Metrics безопасны для nil и вернут нули
+	caches := []struct {
+		name     string
+		m        *ristretto.Metrics
+		capacity int64
+	}{{"block", bc, opts.BlockCacheSize}, {"index", ic, opts.IndexCacheSize}}
+	p.header("memory_storage_cache_hits_total", "counter", "Cache hits.")
+	for _, c := range caches {
+		p.sample("memory_storage_cache_hits_total", float64(c.m.Hits()), "cache", c.name)
+	}
+	p.header("memory_storage_cache_misses_total", "counter", "Cache misses.")
+	for _, c := range caches {
+		p.sample("memory_storage_cache_misses_total", float64(c.m.Misses()), "cache", c.name)
+	}
+	p.header("memory_storage_cache_used_bytes", "gauge", "Approximate cache usage.")
+	for _, c := range caches {
+		p.sample("memory_storage_cache_used_bytes", float64(max(int64(c.m.CostAdded())-int64(c.m.CostEvicted()), 0)), "cache", c.name)
+	}
+	p.header("memory_storage_cache_capacity_bytes", "gauge", "Configured cache capacity.")
+	for _, c := range caches {
+		p.sample("memory_storage_cache_capacity_bytes", float64(c.capacity), "cache", c.name)
+	}
+
+	pi := s.PressureInfo()
+	p.gauge("memory_storage_write_pressure", "Write pressure score 0..1, see Store.Pressure.", pi.Score)
+	p.gauge("memory_storage_l0_tables", "Tables in LSM level 0.", float64(pi.L0Tables))
+	p.gauge("memory_storage_immutable_memtables", "Memtables waiting for flush.", float64(pi.ImmutableMemtables))
+
+	cp := s.CompactionPauseStats()
+	paused := 0.0
+	if cp.Paused {
+		paused = 1
+	}
+	p.gauge("memory_storage_compactions_paused", "1 while compactions are paused by PauseCompactions.", paused)
+	p.counter("memory_storage_compaction_pauses_total", "Compaction pauses started.", float64(cp.Pauses))
+	p.counter("memory_storage_compaction_auto_resumes_total", "Compaction pauses ended by deadline, L0 stall guard or Close.", float64(cp.AutoResumes))
+	p.counter("memory_storage_compaction_paused_seconds_total", "Total duration of finished compaction pauses.", cp.TotalPaused.Seconds())
+	if cp.Pauses > 0 {
+		p.gauge("memory_storage_compaction_pause_l0_tables_added", "L0 tables accumulated during the current or last compaction pause.",
+			float64(max(cp.Current.L0Tables-cp.Current.L0TablesAtPause, 0)))
+	}
+
+	lat := s.LatencyStats()
+	ops := []struct {
+		name string
+		l    OpLatency
+	}{{"get", lat.Get}, {"set", lat.Set}, {"commit", lat.Commit}}
+	p.header("memory_storage_op_duration_seconds", "summary", "Latency of Get, Set and transaction commits since the store was opened.")
+	for _, op := range ops {
+		p.sample("memory_storage_op_duration_seconds", op.l.P50.Seconds(), "op", op.name, "quantile", "0.5")
+		p.sample("memory_storage_op_duration_seconds", op.l.P95.Seconds(), "op", op.name, "quantile", "0.95")
+		p.sample("memory_storage_op_duration_seconds", op.l.P99.Seconds(), "op", op.name, "quantile", "0.99")
+		p.sample("memory_storage_op_duration_seconds_sum", op.l.Sum.Seconds(), "op", op.name)
+		p.sample("memory_storage_op_duration_seconds_count", float64(op.l.Count), "op", op.name)
+	}
+	p.header("memory_storage_op_duration_max_seconds", "gauge", "Slowest Get, Set and transaction commit since the store was opened.")
+	for _, op := range ops {
+		p.sample("memory_storage_op_duration_max_seconds", op.l.Max.Seconds(), "op", op.name)
+	}
+
+	wa := s.WriteAmpStats()
+	p.header("memory_storage_write_bytes_total", "counter", "Bytes written by users and by Badger to L0, the value log and compactions (process-wide).")
+	p.sample("memory_storage_write_bytes_total", float64(wa.UserBytes), "kind", "user")
+	p.sample("memory_storage_write_bytes_total", float64(wa.L0Bytes), "kind", "l0")
+	p.sample("memory_storage_write_bytes_total", float64(wa.VLogBytes), "kind", "vlog")
+	p.sample("memory_storage_write_bytes_total", float64(wa.CompactionTotal), "kind", "compaction")
+	p.header("memory_storage_compaction_written_bytes_total", "counter", "Bytes written by compactions into each level (process-wide).")
+	for l, b := range wa.CompactionBytes {
+		p.sample("memory_storage_compaction_written_bytes_total", float64(b), "level", strconv.Itoa(l))
+	}
+	p.gauge("memory_storage_write_amplification", "Bytes written to disk per byte written by users.", wa.WriteAmplification)
+	p.header("memory_storage_slow_compactions_total", "counter", "Compactions that took longer than 2s by target level.")
+	for l, n := range wa.SlowCompactions {
+		p.sample("memory_storage_slow_compactions_total", float64(n), "level", strconv.Itoa(l))
+	}
+	p.counter("memory_storage_l0_stalls_total", "Writes stalled for more than 1s until L0 was compacted.", float64(wa.L0Stalls))
+	p.counter("memory_storage_l0_stall_seconds_total", "Total duration of L0 write stalls.", wa.L0StallTime.Seconds())
+
+	if gc := s.GCStats(); gc.Interval > 0 {
+		p.counter("memory_storage_vlog_gc_runs_total", "Value log GC runs.", float64(gc.Runs))
+		p.counter("memory_storage_vlog_gc_rewrites_total", "Value log files rewritten by GC.", float64(gc.Rewrites))
+		p.counter("memory_storage_vlog_gc_skipped_total", "Value log GC runs skipped while compactions were paused.", float64(gc.Skipped))
+		p.counter("memory_storage_vlog_gc_reclaimed_bytes_total", "Value log bytes reclaimed by GC.", float64(gc.Reclaimed))
+		p.gauge("memory_storage_vlog_gc_interval_seconds", "Current value log GC interval.", gc.Interval.Seconds())
+		if n := len(gc.Recent); n > 0 {
+			p.gauge("memory_storage_vlog_reclaimable_bytes", "Reclaimable value log bytes estimated before the last GC run.", float64(gc.Recent[n-1].Reclaimable))
+		}
+	}
+
+	rl := s.RateLimitStats()
+	p.header("memory_storage_rate_limit_waits_total", "counter", "Operations delayed by rate limits.")
+	p.sample("memory_storage_rate_limit_waits_total", float64(rl.WriteWaits), "kind", "write")
+	p.sample("memory_storage_rate_limit_waits_total", float64(rl.ScanWaits), "kind", "scan")
+	p.header("memory_storage_rate_limit_throttled_seconds_total", "counter", "Time spent waiting for rate limits.")
+	p.sample("memory_storage_rate_limit_throttled_seconds_total", rl.WriteThrottled.Seconds(), "kind", "write")
+	p.sample("memory_storage_rate_limit_throttled_seconds_total", rl.ScanThrottled.Seconds(), "kind", "scan")
+
+	if s.readGuard != nil {
+		rt := s.ReadTxnStats()
+		p.gauge("memory_storage_read_txn_active", "Open read transactions.", float64(rt.Active))
+		p.gauge("memory_storage_read_txn_oldest_age_seconds", "Age of the oldest open read transaction.", rt.OldestAge.Seconds())
+		p.counter("memory_storage_read_txn_long_total", "Read transactions that exceeded the max age.", float64(rt.Long))
+	}
+	if kv, ok := s.KeyValidationStats(); ok {
+		p.counter("memory_storage_key_rejects_total", "Writes rejected by the key validator.", float64(kv.Rejected))
+		p.counter("memory_storage_key_validation_bypassed_total", "Writes that skipped the key validator.", float64(kv.Bypassed))
+	}
+	if fb := s.FallbackReadStats(); len(fb) > 0 {
+		p.header("memory_storage_fallback_reads_total", "counter", "GetFirst/GetObjectWithFallback reads by where the value was found.")
+		for _, c := range fb {
+			p.sample("memory_storage_fallback_reads_total", float64(c.Primary), "prefix", c.Prefix, "result", "primary")
+			p.sample("memory_storage_fallback_reads_total", float64(c.Fallback), "prefix", c.Prefix, "result", "fallback")
+			p.sample("memory_storage_fallback_reads_total", float64(c.Missing), "prefix", c.Prefix, "result", "missing")
+		}
+		p.header("memory_storage_fallback_upgrades_total", "counter", "Objects rewritten under the new key by GetObjectWithFallback.")
+		for _, c := range fb {
+			p.sample("memory_storage_fallback_upgrades_total", float64(c.Upgraded), "prefix", c.Prefix)
+		}
+	}
+	if mp, ok := s.MemoryPressureStats(); ok {
+		p.gauge("memory_storage_cache_scale", "Cache size fraction under memory pressure.", mp.Scale)
+	}
+	if hm, ok := s.LastAccessHeatmap(); ok {
+		p.header("memory_storage_working_set_bytes", "gauge", "Estimated bytes read within the working set window of the last access heatmap.")
+		p.sample("memory_storage_working_set_bytes", float64(hm.WorkingSetLSMBytes), "location", "lsm")
+		p.sample("memory_storage_working_set_bytes", float64(hm.WorkingSetVLogBytes), "location", "vlog")
+		p.gauge("memory_storage_working_set_keys", "Estimated keys read within the working set window of the last access heatmap.", float64(hm.WorkingSet.Keys))
+		p.gauge("memory_storage_recommended_block_cache_bytes", "BlockCacheSize recommended by the last access heatmap.", float64(hm.RecommendedBlockCacheSize))
+	}
+
+	if bv, ok := s.LastBackupVerify(); ok {
+		okv := 0.0
+		if bv.OK() {
+			okv = 1
+		}
+		p.gauge("memory_storage_backup_verify_ok", "1 if the last backup round-trip verification matched the live store.", okv)
+		p.gauge("memory_storage_backup_verify_timestamp_seconds", "Time of the last backup round-trip verification.", float64(bv.At.Unix()))
+		p.gauge("memory_storage_backup_verify_compared_keys", "Keys compared by the last backup verification.", float64(bv.Compared))
+		p.gauge("memory_storage_backup_verify_missing_keys", "Keys missing from the restored backup.", float64(bv.Missing))
+		p.gauge("memory_storage_backup_verify_mismatched_keys", "Keys restored with a different value.", float64(bv.Mismatched))
+	}
+
+	if prefixes != nil {
+		p.gauge("memory_storage_prefix_report_keys", "Live keys seen by the last prefix report.", float64(prefixes.Keys))
+		truncated := 0.0
+		if prefixes.Truncated {
+			truncated = 1
+		}
+		p.gauge("memory_storage_prefix_report_truncated", "1 if the last prefix report hit MaxKeys.", truncated)
+		p.header("memory_storage_prefix_keys", "gauge", "Live keys per prefix.")
+		for _, ps := range prefixes.Prefixes {
+			p.sample("memory_storage_prefix_keys", float64(ps.Keys), "prefix", ps.Prefix)
+		}
+		p.header("memory_storage_prefix_bytes", "gauge", "Estimated key and value bytes per prefix.")
+		for _, ps := range prefixes.Prefixes {
+			p.sample("memory_storage_prefix_bytes", float64(ps.Bytes), "prefix", ps.Prefix)
+		}
+		p.header("memory_storage_prefix_ttl_keys", "gauge", "Live keys with TTL per prefix.")
+		for _, ps := range prefixes.Prefixes {
+			p.sample("memory_storage_prefix_ttl_keys", float64(ps.WithTTL), "prefix", ps.Prefix)
+		}
+	}
+	return p.w.Flush()
+}
+
+// WriteMetricsTextfile атомарно переписывает path метриками WriteMetrics.
+func (s *Store) WriteMetricsTextfile(path string, prefixes *PrefixReportResult, labels map[string]string) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("create metrics file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if err := s.WriteMetrics(f, prefixes, labels); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write metrics: %w", err)
+	}
+	// CreateTemp создаёт файл с правами 0600, а node_exporter обычно работает под другим пользователем
+	if err := f.Chmod(0o644); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// startMetricsTextfile пишет файл метрик сразу и затем раз в Interval до Close.
+func (s *Store) startMetricsTextfile(opts MetricsTextfileOptions) {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	if opts.PrefixInterval <= 0 {
+		opts.PrefixInterval = 10 * time.Minute
+	}
+	if opts.OnError == nil {
+		opts.OnError = func(err error) {
+			log.Printf("[Badger] metrics textfile %s: %v", opts.Path, err)
+		}
+	}
+	go func() {
+		var prefixes *PrefixReportResult
+		var prefixesAt time.Time
+		t := s.clock.NewTicker(opts.Interval)
+		defer t.Stop()
+		for {
+			if opts.Prefixes && (prefixes == nil || s.clock.Now().Sub(prefixesAt) >= opts.PrefixInterval) {
+				rep, err := s.PrefixReport(s.bg, opts.PrefixReport)
+				if err != nil && s.bg.Err() == nil {
+					opts.OnError(fmt.Errorf("prefix report: %w", err))
+				}
+				if err == nil {
+					prefixes, prefixesAt = &rep, s.clock.Now()
+				}
+			}
+			if s.bg.Err() != nil {
+				return
+			}
+			if err := s.WriteMetricsTextfile(opts.Path, prefixes, opts.Labels); err != nil {
+				opts.OnError(err)
+			}
+			select {
+			case <-s.bg.Done():
+				return
+			case <-t.C():
+			}
+		}
+	}()
+}
+
+type promWriter struct {
+	w      *bufio.Writer
+	labels string
+}
+
+func (p *promWriter) header(name, typ, help string) {
+	fmt.Fprintf(p.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (p *promWriter) gauge(name, help string, v float64) {
+	p.header(name, "gauge", help)
+	p.sample(name, v)
+}
+
+func (p *promWriter) counter(name, help string, v float64) {
+	p.header(name, "counter", help)
+	p.sample(name, v)
+}
+
+// sample пишет строку метрики; kv — пары имя/значение дополнительных меток.
+func (p *promWriter) sample(name string, v float64, kv ...string) {
+	labels := p.labels
+	for i := 0; i+1 < len(kv); i += 2 {
+		if labels != "" {
+			labels += ","
+		}
+		labels += kv[i] + `="` + escapeLabel(kv[i+1]) + `"`
+	}
+	if labels != "" {
+		name += "{" + labels + "}"
+	}
+	fmt.Fprintf(p.w, "%s %s\n", name, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+func formatLabels(labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, k := range names {
+		parts = append(parts, k+`="`+escapeLabel(labels[k])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
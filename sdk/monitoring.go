@@ -31,15 +31,25 @@ func (s *Store) StartBadgerMemStats() {
 		return int((float64(used) / float64(cap)) * 100.0)
 	}
 	mib := func(b int64) int64 { return b >> 20 }
+	lat := s.LatencyStats()
+	wa := s.WriteAmpStats()
 
+	tag := "[Badger]"
+	if s.name != "" {
+		tag = "[Badger " + s.name + "]"
+	}
 	log.Printf(
-		"[Badger]"+
+		tag+
 			" BlockCache: used=%d MiB / %d MiB (%d%%), hits=%d, misses=%d"+
 			" IndexCache: used=%d MiB / %d MiB (%d%%), hits=%d, misses=%d"+
-			" OnDisk: LSM=%d MiB, VLog=%d MiB",
+			" OnDisk: LSM=%d MiB, VLog=%d MiB"+
+			" Latency: get %s, set %s, commit %s"+
+			" WriteAmp: %.2f (compaction=%d MiB), L0 stalls=%d (%s)",
 		mib(blockUsed), mib(blockCap), pct(blockUsed, blockCap), bc.Hits(), bc.Misses(),
 		mib(indexUsed), mib(indexCap), pct(indexUsed, indexCap), ic.Hits(), ic.Misses(),
 		mib(lsmSize), mib(vlogSize),
+		lat.Get, lat.Set, lat.Commit,
+		wa.WriteAmplification, mib(wa.CompactionTotal), wa.L0Stalls, wa.L0StallTime,
 	)
 }
 
@@ -51,6 +61,8 @@ func (s *Store) runMonitoring(ctx context.Context) {
 		select {
 		case <-ctx.Done():
 			return
+		case <-s.bg.Done():
+			return
 		case <-ticker.C:
 			s.StartBadgerMemStats()
 		}
@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ErrVersionMismatch — версия объекта изменилась с момента чтения (аналог 412 Precondition Failed для ETag).
+var ErrVersionMismatch = errors.New("object version mismatch")
+
+// objectEnvelopeMagic открывает конверт объекта. Ни JSON, ни protobuf (номер поля 0 недопустим)
+// не начинаются с 0x00, поэтому конверт не спутать со значением, записанным до включения версий.
+var objectEnvelopeMagic = []byte{0x00, 'V', 1}
+
+const objectEnvelopeSize = 3 + 8 + 8
+
+// ObjectMeta — метаданные объекта из конверта Options.VersionedObjects.
+type ObjectMeta struct {
+	// Version растёт на 1 при каждой записи объекта; 0 — объекта нет (или он записан без конверта).
+	Version uint64
+	// UpdatedAt — время последней записи по часам Store.
+	UpdatedAt time.Time
+}
+
+func wrapObject(meta ObjectMeta, data []byte) []byte {
+	out := make([]byte, 0, objectEnvelopeSize+len(data))
+	out = append(out, objectEnvelopeMagic...)
+	out = binary.BigEndian.AppendUint64(out, meta.Version)
+	out = binary.BigEndian.AppendUint64(out, uint64(meta.UpdatedAt.UnixNano()))
+	return append(out, data...)
+}
+
+// unwrapObject отделяет метаданные от закодированного объекта; значение без конверта — версия 0.
+func unwrapObject(raw []byte) (ObjectMeta, []byte) {
+	if len(raw) < objectEnvelopeSize || !bytes.HasPrefix(raw, objectEnvelopeMagic) {
+		return ObjectMeta{}, raw
+	}
+	b := raw[len(objectEnvelopeMagic):]
+	return ObjectMeta{
+		Version:   binary.BigEndian.Uint64(b[:8]),
+		UpdatedAt: time.Unix(0, int64(binary.BigEndian.Uint64(b[8:16]))),
+	}, raw[objectEnvelopeSize:]
+}
+
+// txObjectMeta — метаданные текущей версии key; отсутствующий или истёкший ключ — нулевые.
+func (s *Store) txObjectMeta(txn *badger.Txn, key []byte) (ObjectMeta, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return ObjectMeta{}, nil
+	}
+	if err != nil {
+		return ObjectMeta{}, err
+	}
+	if s.expired(item) {
+		return ObjectMeta{}, nil
+	}
+	var meta ObjectMeta
+	err = item.Value(func(val []byte) error {
+		meta, _ = unwrapObject(val)
+		return nil
+	})
+	return meta, err
+}
+
+// txMarshalObject кодирует v для записи по key. С Options.VersionedObjects значение заворачивается
+// в конверт со следующей версией, поэтому текущая версия читается в той же транзакции.
+func (s *Store) txMarshalObject(txn *badger.Txn, key []byte, v any) ([]byte, ObjectMeta, error) {
+	data, err := s.marshal(key, v)
+	if err != nil || !s.versioned {
+		return data, ObjectMeta{}, err
+	}
+	meta, err := s.txObjectMeta(txn, key)
+	if err != nil {
+		return nil, ObjectMeta{}, err
+	}
+	meta = ObjectMeta{Version: meta.Version + 1, UpdatedAt: s.clock.Now()}
+	return wrapObject(meta, data), meta, nil
+}
+
+// GetObjectWithMeta — GetObject, дополнительно возвращающий версию объекта (ETag).
+// Без Options.VersionedObjects метаданные всегда нулевые.
+func (s *Store) GetObjectWithMeta(key []byte, v any) (ObjectMeta, error) {
+	if err := s.checkAccess(context.Background(), AccessRead, key); err != nil {
+		return ObjectMeta{}, err
+	}
+	var meta ObjectMeta
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if s.expired(item) {
+			return ErrNotFound
+		}
+		return item.Value(func(val []byte) error {
+			if s.versioned {
+				meta, _ = unwrapObject(val)
+			}
+			return s.decode(key, val, v)
+		})
+	})
+	return meta, err
+}
+
+// SetObjectIfVersion записывает объект, только если его текущая версия равна version
+// (0 — объекта ещё нет), иначе возвращает ErrVersionMismatch. Требует Options.VersionedObjects.
+func (s *Store) SetObjectIfVersion(ctx context.Context, key []byte, v any, ttl time.Duration, version uint64) (ObjectMeta, error) {
+	if !s.versioned {
+		return ObjectMeta{}, errors.New("SetObjectIfVersion requires Options.VersionedObjects")
+	}
+	return s.setObjectIfVersion(ctx, key, v, version, func(data []byte) *badger.Entry {
+		return s.NewEntry(key, data, ttl)
+	})
+}
+
+func (s *Store) setObjectIfVersion(ctx context.Context, key []byte, v any, version uint64, entry func(data []byte) *badger.Entry) (ObjectMeta, error) {
+	if err := s.validateKey(ctx, key); err != nil {
+		return ObjectMeta{}, err
+	}
+	s.noteWrite(key)
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return ObjectMeta{}, err
+	}
+	var meta ObjectMeta
+	err := s.db.Update(func(txn *badger.Txn) error {
+		cur, err := s.txObjectMeta(txn, key)
+		if err != nil {
+			return err
+		}
+		if cur.Version != version {
+			return ErrVersionMismatch
+		}
+		data, m, err := s.txMarshalObject(txn, key, v)
+		if err != nil {
+			return err
+		}
+		meta = m
+		return txn.SetEntry(entry(data))
+	})
+	// конкурентная запись того же ключа после нашего чтения — тоже несовпадение версии
+	if errors.Is(err, badger.ErrConflict) {
+		err = ErrVersionMismatch
+	}
+	return meta, err
+}
+
+// UpdateObject читает объект в v, вызывает fn и записывает результат с проверкой версии.
+// Если объект успели изменить, v обнуляется, объект перечитывается и fn вызывается снова
+// (до 20 попыток), поэтому fn не должна иметь внешних побочных эффектов. Отсутствующий объект —
+// ErrNotFound; ошибка fn прерывает обновление. TTL записи сохраняется. Требует Options.VersionedObjects.
+func (s *Store) UpdateObject(ctx context.Context, key []byte, v any, fn func(v any) error) (ObjectMeta, error) {
+	if !s.versioned {
+		return ObjectMeta{}, errors.New("UpdateObject requires Options.VersionedObjects")
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return ObjectMeta{}, errors.New("UpdateObject target must be a non-nil pointer")
+	}
+	const maxAttempts = 20
+	for attempt := 1; ; attempt++ {
+		rv.Elem().SetZero()
+		var expiresAt uint64
+		var meta ObjectMeta
+		err := s.db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get(key)
+			if err != nil {
+				return err
+			}
+			if s.expired(item) {
+				return ErrNotFound
+			}
+			expiresAt = item.ExpiresAt()
+			return item.Value(func(val []byte) error {
+				meta, _ = unwrapObject(val)
+				return s.decode(key, val, v)
+			})
+		})
+		if err != nil {
+			return ObjectMeta{}, err
+		}
+		if err := fn(v); err != nil {
+			return ObjectMeta{}, err
+		}
+		next, err := s.setObjectIfVersion(ctx, key, v, meta.Version, func(data []byte) *badger.Entry {
+			e := badger.NewEntry(key, data)
+			e.ExpiresAt = expiresAt
+			return e
+		})
+		if !errors.Is(err, ErrVersionMismatch) || attempt == maxAttempts {
+			return next, err
+		}
+		if err := sleepWithJitter(ctx, s.clock, 5*time.Millisecond, 150*time.Millisecond, attempt); err != nil {
+			return ObjectMeta{}, err
+		}
+	}
+}
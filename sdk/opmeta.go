@@ -0,0 +1,125 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Метаданные запроса в контексте: WithRequestID и WithPrincipal. Store добавляет их к трассам
+// транзакций и медленных операций (Options.OnTx, Options.OnSlowOp), к записям журнала аудита и к
+// ошибкам операций с контекстом (*OpError), чтобы логи хранилища сопоставлялись с логами запросов.
+
+type requestIDKey struct{}
+
+// WithRequestID кладёт в контекст идентификатор запроса приложения.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom возвращает идентификатор запроса из контекста ("" — не задан).
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// OpMeta — метаданные запроса из контекста.
+type OpMeta struct {
+	RequestID string
+	Principal string
+}
+
+// MetaFrom собирает OpMeta из контекста.
+func MetaFrom(ctx context.Context) OpMeta {
+	return OpMeta{RequestID: RequestIDFrom(ctx), Principal: PrincipalFrom(ctx)}
+}
+
+func (m OpMeta) String() string {
+	var parts []string
+	if m.RequestID != "" {
+		parts = append(parts, "request_id="+m.RequestID)
+	}
+	if m.Principal != "" {
+		parts = append(parts, "principal="+m.Principal)
+	}
+	return strings.Join(parts, " ")
+}
+
+// OpError — ошибка операции Store, выполненной с метаданными в контексте.
+// Оборачивает исходную ошибку: errors.Is(err, ErrNotFound) и т.п. продолжают работать.
+type OpError struct {
+	Op   string
+	Key  []byte // nil для транзакций
+	Meta OpMeta
+	Err  error
+}
+
+func (e *OpError) Error() string {
+	if e.Key != nil {
+		return fmt.Sprintf("%s %q [%s]: %v", e.Op, e.Key, e.Meta, e.Err)
+	}
+	return fmt.Sprintf("%s [%s]: %v", e.Op, e.Meta, e.Err)
+}
+
+func (e *OpError) Unwrap() error { return e.Err }
+
+// OpTrace — трасса операции Store для Options.OnTx и Options.OnSlowOp.
+type OpTrace struct {
+	Op       string // "set", "get", "delete", "scan", "tx"
+	Key      []byte // ключ или префикс скана; nil для транзакций
+	Meta     OpMeta
+	Start    time.Time
+	Duration time.Duration
+	// Attempts — число попыток транзакции (с повторами после конфликтов); 1 для остальных операций.
+	Attempts int
+	Err      error
+}
+
+// wrapOpErr оборачивает err в *OpError, если в контексте есть метаданные; иначе возвращает err как есть.
+func wrapOpErr(ctx context.Context, op string, key []byte, err error) error {
+	if err == nil {
+		return nil
+	}
+	meta := MetaFrom(ctx)
+	if meta == (OpMeta{}) {
+		return err
+	}
+	var opErr *OpError
+	if errors.As(err, &opErr) {
+		return err
+	}
+	return &OpError{Op: op, Key: key, Meta: meta, Err: err}
+}
+
+// traceOp завершает операцию op: сообщает о ней OnSlowOp, если она дольше SlowOpThreshold,
+// и возвращает err, обёрнутую wrapOpErr.
+func (s *Store) traceOp(ctx context.Context, op string, key []byte, start time.Time, attempts int, err error) error {
+	err = wrapOpErr(ctx, op, key, err)
+	if s.onTx == nil && s.slowOpThreshold <= 0 {
+		return err
+	}
+	tr := OpTrace{
+		Op:       op,
+		Key:      key,
+		Meta:     MetaFrom(ctx),
+		Start:    start,
+		Duration: s.clock.Now().Sub(start),
+		Attempts: attempts,
+		Err:      err,
+	}
+	if op == "tx" && s.onTx != nil {
+		s.onTx(tr)
+	}
+	if s.slowOpThreshold > 0 && tr.Duration >= s.slowOpThreshold {
+		s.onSlowOp(tr)
+	}
+	return err
+}
+
+// logSlowOp — OnSlowOp по умолчанию.
+func logSlowOp(tr OpTrace) {
+	log.Printf("[Badger] slow %s %q: %s, attempts=%d, %s, err=%v", tr.Op, tr.Key, tr.Duration, tr.Attempts, tr.Meta, tr.Err)
+}
@@ -0,0 +1,93 @@
+package sdk
+
+import (
+	"context"
+	"sort"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// PrefixReportOptions — параметры PrefixReport.
+type PrefixReportOptions struct {
+	// Prefix группирует ключи; по умолчанию — часть ключа до первого ':' включительно.
+	Prefix func(key []byte) string
+	// MaxKeys — предел просмотренных ключей; 0 — без предела.
+	MaxKeys int
+	// Within — считать только ключи под этим префиксом; nil — все.
+	Within []byte
+}
+
+// PrefixStats — живые ключи одного префикса.
+type PrefixStats struct {
+	Prefix string
+	Keys   int64
+	// Bytes — оценка занимаемого места: ключи и значения (без чтения значений).
+	Bytes int64
+	// WithTTL — ключей с TTL.
+	WithTTL int64
+}
+
+// PrefixReportResult — результат PrefixReport.
+type PrefixReportResult struct {
+	Keys      int64
+	Truncated bool
+	// Prefixes — по убыванию числа ключей.
+	Prefixes []PrefixStats
+}
+
+// PrefixReport считает живые ключи и их объём по префиксам одним проходом без чтения значений.
+func (s *Store) PrefixReport(ctx context.Context, opts PrefixReportOptions) (PrefixReportResult, error) {
+	if opts.Prefix == nil {
+		opts.Prefix = firstSegment
+	}
+	var rep PrefixReportResult
+	groups := make(map[string]*PrefixStats)
+	err := s.db.View(func(txn *badger.Txn) error {
+		io := badger.DefaultIteratorOptions
+		io.PrefetchValues = false
+		io.Prefix = opts.Within
+		it := txn.NewIterator(io)
+		defer it.Close()
+		for it.Rewind(); it.ValidForPrefix(opts.Within); it.Next() {
+			if opts.MaxKeys > 0 && rep.Keys >= int64(opts.MaxKeys) {
+				rep.Truncated = true
+				return nil
+			}
+			if rep.Keys%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			if s.expired(item) {
+				continue
+			}
+			rep.Keys++
+			p := opts.Prefix(item.Key())
+			g := groups[p]
+			if g == nil {
+				g = &PrefixStats{Prefix: p}
+				groups[p] = g
+			}
+			g.Keys++
+			g.Bytes += item.EstimatedSize()
+			if item.ExpiresAt() > 0 {
+				g.WithTTL++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	for _, g := range groups {
+		rep.Prefixes = append(rep.Prefixes, *g)
+	}
+	sort.Slice(rep.Prefixes, func(i, j int) bool {
+		if rep.Prefixes[i].Keys != rep.Prefixes[j].Keys {
+			return rep.Prefixes[i].Keys > rep.Prefixes[j].Keys
+		}
+		return rep.Prefixes[i].Prefix < rep.Prefixes[j].Prefix
+	})
+	return rep, nil
+}
@@ -0,0 +1,188 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Давление на запись — насколько Badger близок к остановке записей. Badger останавливает запись,
+// когда в L0 набирается NumLevelZeroTablesStall таблиц или все memtable ждут сброса; до этого момента
+// пишущие не получают никакого сигнала. Pressure сводит три признака к одному числу 0..1 — по худшему:
+//
+//   - L0: число таблиц L0 относительно порога остановки;
+//   - memtable: доля неизменяемых memtable, ждущих сброса (по журналам *.mem), и очередь записей Badger;
+//   - компакция: наибольший score уровней LSM (1 — уровень пора компактировать, 3 и больше — отставание).
+
+// pressureTTL — сколько переиспользуется вычисленное давление: PressureGate спрашивает его на каждую запись.
+const pressureTTL = 100 * time.Millisecond
+
+// badgerWriteChCapacity — ёмкость очереди записей Badger (kvWriteChCapacity).
+const badgerWriteChCapacity = 1000
+
+// PressureInfo — составляющие давления на запись.
+type PressureInfo struct {
+	// Score — итог 0..1, максимум составляющих.
+	Score      float64
+	L0         float64
+	Memtables  float64
+	Compaction float64
+
+	L0Tables           int
+	ImmutableMemtables int
+	PendingWrites      int64
+	MaxLevelScore      float64
+}
+
+type pressureCache struct {
+	mu   sync.Mutex
+	at   time.Time
+	info PressureInfo
+}
+
+// Pressure возвращает давление на запись 0..1; около 1 Badger вот-вот остановит записи.
+func (s *Store) Pressure() float64 {
+	return s.PressureInfo().Score
+}
+
+// PressureInfo возвращает давление на запись по составляющим. Значение кешируется на 100ms.
+func (s *Store) PressureInfo() PressureInfo {
+	now := s.clock.Now()
+	s.pressure.mu.Lock()
+	defer s.pressure.mu.Unlock()
+	if !s.pressure.at.IsZero() && now.Sub(s.pressure.at) < pressureTTL {
+		return s.pressure.info
+	}
+	s.pressure.info = s.computePressure()
+	s.pressure.at = now
+	return s.pressure.info
+}
+
+func (s *Store) computePressure() PressureInfo {
+	opts := s.db.Opts()
+	var p PressureInfo
+	for _, l := range s.db.Levels() {
+		if l.Level == 0 {
+			p.L0Tables = l.NumTables
+		}
+		p.MaxLevelScore = max(p.MaxLevelScore, l.Score)
+	}
+	if opts.NumLevelZeroTablesStall > 0 {
+		p.L0 = min(float64(p.L0Tables)/float64(opts.NumLevelZeroTablesStall), 1)
+	}
+	p.Compaction = min(max((p.MaxLevelScore-1)/2, 0), 1)
+
+	if !opts.InMemory {
+		if mems, err := filepath.Glob(filepath.Join(opts.Dir, "*.mem")); err == nil && len(mems) > 0 {
+			p.ImmutableMemtables = len(mems) - 1 // одна — активная
+		}
+	}
+	if m, ok := expvar.Get("badger_write_pending_num_memtable").(*expvar.Map); ok {
+		p.PendingWrites = expvarMapInt(m, opts.Dir)
+	}
+	if opts.NumMemtables > 1 {
+		p.Memtables = float64(p.ImmutableMemtables) / float64(opts.NumMemtables-1)
+	}
+	p.Memtables = min(max(p.Memtables, float64(p.PendingWrites)/badgerWriteChCapacity), 1)
+
+	p.Score = max(p.L0, p.Memtables, p.Compaction)
+	return p
+}
+
+// ErrBackpressure — PressureGate отклонил запись: давление выше RejectAbove.
+var ErrBackpressure = errors.New("store write pressure is too high")
+
+// PressureGateOptions — параметры NewPressureGate.
+type PressureGateOptions struct {
+	// DelayAbove — давление, начиная с которого запись задерживается, по умолчанию 0.6. Задержка растёт
+	// линейно от нуля до MaxDelay на RejectAbove.
+	DelayAbove float64
+	// RejectAbove — давление, начиная с которого запись отклоняется с ErrBackpressure, по умолчанию 0.9;
+	// больше 1 — не отклонять.
+	RejectAbove float64
+	// MaxDelay — наибольшая задержка, по умолчанию 500ms.
+	MaxDelay time.Duration
+}
+
+// PressureGateStats — сколько записей PressureGate задержал и отклонил.
+type PressureGateStats struct {
+	Delayed  uint64
+	Rejected uint64
+	Delay    time.Duration
+}
+
+// PressureGate притормаживает производителей по Store.Pressure до того, как Badger остановит запись сам.
+// Wait вызывается перед записью (или перед тем, как взять сообщение из очереди), Do оборачивает запись.
+type PressureGate struct {
+	store    *Store
+	opts     PressureGateOptions
+	delayed  atomic.Uint64
+	rejected atomic.Uint64
+	delay    atomic.Int64
+}
+
+func NewPressureGate(store *Store, opts PressureGateOptions) *PressureGate {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.DelayAbove <= 0 {
+		opts.DelayAbove = 0.6
+	}
+	if opts.RejectAbove <= 0 {
+		opts.RejectAbove = 0.9
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = 500 * time.Millisecond
+	}
+	return &PressureGate{store: store, opts: opts}
+}
+
+// Wait задерживает вызывающего по текущему давлению или возвращает ErrBackpressure.
+func (g *PressureGate) Wait(ctx context.Context) error {
+	p := g.store.Pressure()
+	if p >= g.opts.RejectAbove {
+		g.rejected.Add(1)
+		return ErrBackpressure
+	}
+	if p < g.opts.DelayAbove {
+		return nil
+	}
+	frac := 1.0
+	if upper := min(g.opts.RejectAbove, 1); upper > g.opts.DelayAbove {
+		frac = min((p-g.opts.DelayAbove)/(upper-g.opts.DelayAbove), 1)
+	}
+	d := time.Duration(frac * float64(g.opts.MaxDelay))
+	if d <= 0 {
+		return nil
+	}
+	g.delayed.Add(1)
+	g.delay.Add(int64(d))
+	t := g.store.clock.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C():
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Do выполняет fn после Wait.
+func (g *PressureGate) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if err := g.Wait(ctx); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+func (g *PressureGate) Stats() PressureGateStats {
+	return PressureGateStats{
+		Delayed:  g.delayed.Load(),
+		Rejected: g.rejected.Load(),
+		Delay:    time.Duration(g.delay.Load()),
+	}
+}
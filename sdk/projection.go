@@ -0,0 +1,127 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtoProjection — набор полей верхнего уровня protobuf-сообщения, которые нужно декодировать.
+// Остальные поля пропускаются на уровне wire-формата без разбора.
+type ProtoProjection struct {
+	fields map[protowire.Number]struct{}
+}
+
+// NewProtoProjection строит проекцию по именам полей сообщения-образца m.
+func NewProtoProjection(m proto.Message, fieldNames ...string) (*ProtoProjection, error) {
+	desc := m.ProtoReflect().Descriptor()
+	p := &ProtoProjection{fields: make(map[protowire.Number]struct{}, len(fieldNames))}
+	for _, name := range fieldNames {
+		fd := desc.Fields().ByName(protoreflect.Name(name))
+		if fd == nil {
+			return nil, fmt.Errorf("message %s has no field %q", desc.FullName(), name)
+		}
+		p.fields[fd.Number()] = struct{}{}
+	}
+	return p, nil
+}
+
+// Project оставляет в закодированном сообщении только поля проекции.
+func (p *ProtoProjection) Project(raw []byte) ([]byte, error) {
+	out := make([]byte, 0, len(raw))
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		m := protowire.ConsumeFieldValue(num, typ, raw[n:])
+		if m < 0 {
+			return nil, protowire.ParseError(m)
+		}
+		if _, ok := p.fields[num]; ok {
+			out = append(out, raw[:n+m]...)
+		}
+		raw = raw[n+m:]
+	}
+	return out, nil
+}
+
+// Unmarshal декодирует в m только поля проекции.
+func (p *ProtoProjection) Unmarshal(raw []byte, m proto.Message) error {
+	projected, err := p.Project(raw)
+	if err != nil {
+		return err
+	}
+	return proto.Unmarshal(projected, m)
+}
+
+// ScanPrefixProjected — ScanPrefixFiltered для protobuf-значений с частичным декодированием.
+// newMsg создаёт пустое сообщение для каждой записи; ошибки декодирования — *DecodeError
+// (с учётом Options.QuarantineDecodeErrors, как в ScanPrefixObjects).
+func (s *Store) ScanPrefixProjected(
+	prefix []byte,
+	limit int,
+	filter func(key []byte, valueSize int64, userMeta byte) bool,
+	projection *ProtoProjection,
+	newMsg func() proto.Message,
+	fn func(key []byte, m proto.Message) error,
+) error {
+	if err := s.checkAccess(context.Background(), AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(context.Background(), "scan_projected")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		count := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			if filter != nil && !filter(item.Key(), item.ValueSize(), item.UserMeta()) {
+				continue
+			}
+			key := item.KeyCopy(nil)
+			m := newMsg()
+			err := item.Value(func(val []byte) error {
+				if err := projection.Unmarshal(val, m); err != nil {
+					return &DecodeError{Key: key, Raw: append([]byte(nil), val...), Codec: "ProtoProjection", Err: err}
+				}
+				return nil
+			})
+			if err != nil {
+				derr, ok := err.(*DecodeError)
+				if !ok || s.quarantine == nil {
+					return err
+				}
+				s.quarantine(derr)
+				continue
+			}
+			if err := fn(key, m); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"errors"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ErrSchemaMismatch — декодированное protobuf-сообщение не прошло ProtoSchemaGuard с Reject=true.
+var ErrSchemaMismatch = errors.New("protobuf schema mismatch")
+
+// SchemaMismatch — описание подозрительного декодирования: значение записано другой версией схемы.
+type SchemaMismatch struct {
+	Key  []byte
+	Type protoreflect.FullName
+	// UnknownBytes — суммарный размер неизвестных полей во всём сообщении (включая вложенные).
+	UnknownBytes int
+	// TotalBytes — размер сырого значения.
+	TotalBytes int
+	// ZeroFields — поля из ProtoSchemaGuard.RequiredFields, оказавшиеся незаполненными.
+	ZeroFields []protoreflect.Name
+}
+
+// SchemaMismatchHandler получает описание несовпадения; Key и срезы можно сохранять.
+type SchemaMismatchHandler func(m *SchemaMismatch)
+
+// ProtoSchemaGuard — проверка совместимости схемы при чтении protobuf-значений.
+// Срабатывает после успешного декодирования в proto.Message (GetObject, TxGetObject, сканы объектов).
+// Proto3 молча отбрасывает незнакомое в неизвестные поля и оставляет пропавшие поля нулевыми,
+// поэтому чтение старой/новой версии схемы без проверки выглядит успешным.
+type ProtoSchemaGuard struct {
+	// MaxUnknownBytes — сколько байт неизвестных полей допустимо; больше — несовпадение.
+	// 0 — любое неизвестное поле считается несовпадением, <0 — проверка отключена.
+	MaxUnknownBytes int
+	// RequiredFields — поля, обязательные по соглашению: по полному имени типа список имён полей,
+	// которые не должны быть нулевыми (для полей без presence — значение по умолчанию считается нулём).
+	RequiredFields map[protoreflect.FullName][]protoreflect.Name
+	// Handler вызывается при несовпадении. Может быть nil, если нужен только Reject.
+	Handler SchemaMismatchHandler
+	// Reject — вернуть из чтения *DecodeError с ErrSchemaMismatch вместо значения.
+	Reject bool
+}
+
+// check возвращает описание несовпадения или nil, если сообщение выглядит совместимым.
+func (g *ProtoSchemaGuard) check(key, raw []byte, m proto.Message) *SchemaMismatch {
+	msg := m.ProtoReflect()
+	unknown := -1
+	if g.MaxUnknownBytes >= 0 {
+		unknown = unknownBytes(msg)
+	}
+	var zero []protoreflect.Name
+	if names := g.RequiredFields[msg.Descriptor().FullName()]; len(names) > 0 {
+		fields := msg.Descriptor().Fields()
+		for _, name := range names {
+			fd := fields.ByName(name)
+			if fd == nil || !msg.Has(fd) {
+				zero = append(zero, name)
+			}
+		}
+	}
+	if unknown <= g.MaxUnknownBytes && len(zero) == 0 {
+		return nil
+	}
+	return &SchemaMismatch{
+		Key:          append([]byte(nil), key...),
+		Type:         msg.Descriptor().FullName(),
+		UnknownBytes: max(unknown, 0),
+		TotalBytes:   len(raw),
+		ZeroFields:   zero,
+	}
+}
+
+// unknownBytes суммирует неизвестные поля сообщения и всех вложенных сообщений.
+func unknownBytes(msg protoreflect.Message) int {
+	n := len(msg.GetUnknown())
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			if fd.MapValue().Message() != nil {
+				v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+					n += unknownBytes(mv.Message())
+					return true
+				})
+			}
+		case fd.Message() != nil && fd.IsList():
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				n += unknownBytes(list.Get(i).Message())
+			}
+		case fd.Message() != nil:
+			n += unknownBytes(v.Message())
+		}
+		return true
+	})
+	return n
+}
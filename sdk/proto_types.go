@@ -0,0 +1,235 @@
+package sdk
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Реестр proto-типов для просмотра значений без сгенерированного кода. Инструменты (msctl, HTTP
+// admin) не импортируют пакеты сервисов, поэтому сообщения из protoregistry.GlobalTypes им не
+// видны и proto-значения показываются как base64. ProtoTypes загружает описания из файла
+// FileDescriptorSet (protoc --include_imports --descriptor_set_out=types.pb) или из уже
+// собранных дескрипторов и создаёт по ним dynamicpb-сообщения; типы, которых в реестре нет,
+// ищутся в protoregistry.GlobalTypes.
+
+// ProtoTypes — реестр proto-типов и соответствие префиксов ключей типам. Безопасен для
+// конкурентного использования; нулевое значение не готово — создавайте через NewProtoTypes.
+type ProtoTypes struct {
+	mu       sync.RWMutex
+	files    *protoregistry.Files
+	types    *protoregistry.Types
+	prefixes []prefixProtoType // длинные префиксы первыми
+}
+
+type prefixProtoType struct {
+	prefix []byte
+	name   protoreflect.FullName
+}
+
+func NewProtoTypes() *ProtoTypes {
+	return &ProtoTypes{files: new(protoregistry.Files), types: new(protoregistry.Types)}
+}
+
+// LoadDescriptorSet регистрирует типы из файла FileDescriptorSet. Зависимости, которых нет в файле,
+// берутся из protoregistry.GlobalFiles (например, well-known types).
+func (t *ProtoTypes) LoadDescriptorSet(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("parse descriptor set %s: %w", path, err)
+	}
+	return t.RegisterDescriptorSet(&set)
+}
+
+// RegisterDescriptorSet регистрирует типы из set. Файлы должны идти после своих зависимостей —
+// в таком порядке их пишет protoc.
+func (t *ProtoTypes) RegisterDescriptorSet(set *descriptorpb.FileDescriptorSet) error {
+	for _, fdp := range set.GetFile() {
+		fd, err := protodesc.NewFile(fdp, protoFileResolver{t})
+		if err != nil {
+			return fmt.Errorf("descriptor %s: %w", fdp.GetName(), err)
+		}
+		if err := t.RegisterFile(fd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterFile регистрирует сообщения файла fd (включая вложенные). Файл, уже известный реестру или
+// protoregistry.GlobalFiles, пропускается.
+func (t *ProtoTypes) RegisterFile(fd protoreflect.FileDescriptor) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, err := t.files.FindFileByPath(fd.Path()); err == nil {
+		return nil
+	}
+	if _, err := protoregistry.GlobalFiles.FindFileByPath(fd.Path()); err == nil {
+		return nil
+	}
+	if err := t.files.RegisterFile(fd); err != nil {
+		return fmt.Errorf("register %s: %w", fd.Path(), err)
+	}
+	var register func(msgs protoreflect.MessageDescriptors) error
+	register = func(msgs protoreflect.MessageDescriptors) error {
+		for i := 0; i < msgs.Len(); i++ {
+			md := msgs.Get(i)
+			if md.IsMapEntry() {
+				continue
+			}
+			if err := t.types.RegisterMessage(dynamicpb.NewMessageType(md)); err != nil {
+				return fmt.Errorf("register %s: %w", md.FullName(), err)
+			}
+			if err := register(md.Messages()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := register(fd.Messages()); err != nil {
+		return err
+	}
+	for i := 0; i < fd.Enums().Len(); i++ {
+		if err := t.types.RegisterEnum(dynamicpb.NewEnumType(fd.Enums().Get(i))); err != nil {
+			return fmt.Errorf("register %s: %w", fd.Enums().Get(i).FullName(), err)
+		}
+	}
+	return nil
+}
+
+// MapPrefix назначает ключам с префиксом prefix сообщение name; выбирается самый длинный
+// совпавший префикс. Тип должен быть известен реестру или protoregistry.GlobalTypes.
+func (t *ProtoTypes) MapPrefix(prefix string, name protoreflect.FullName) error {
+	if _, err := t.FindMessageByName(name); err != nil {
+		return fmt.Errorf("proto type %s: %w", name, err)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	prefixes := make([]prefixProtoType, 0, len(t.prefixes)+1)
+	for _, p := range t.prefixes {
+		if string(p.prefix) != prefix {
+			prefixes = append(prefixes, p)
+		}
+	}
+	prefixes = append(prefixes, prefixProtoType{prefix: []byte(prefix), name: name})
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i].prefix) > len(prefixes[j].prefix)
+	})
+	t.prefixes = prefixes
+	return nil
+}
+
+// ParsePrefixMapping разбирает назначение вида "prefix=full.Name" и передаёт его в MapPrefix.
+func (t *ProtoTypes) ParsePrefixMapping(s string) error {
+	prefix, name, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("proto type mapping %q: want prefix=full.Name", s)
+	}
+	return t.MapPrefix(prefix, protoreflect.FullName(name))
+}
+
+// TypeFor возвращает сообщение, назначенное ключу через MapPrefix; пустое имя — не назначено.
+func (t *ProtoTypes) TypeFor(key []byte) protoreflect.FullName {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, p := range t.prefixes {
+		if bytes.HasPrefix(key, p.prefix) {
+			return p.name
+		}
+	}
+	return ""
+}
+
+// NewMessage возвращает пустое сообщение, назначенное ключу, или nil, если тип не назначен.
+func (t *ProtoTypes) NewMessage(key []byte) proto.Message {
+	name := t.TypeFor(key)
+	if name == "" {
+		return nil
+	}
+	mt, err := t.FindMessageByName(name)
+	if err != nil {
+		return nil
+	}
+	return mt.New().Interface()
+}
+
+// Names возвращает имена сообщений реестра (без protoregistry.GlobalTypes) по алфавиту.
+func (t *ProtoTypes) Names() []protoreflect.FullName {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	var out []protoreflect.FullName
+	t.types.RangeMessages(func(mt protoreflect.MessageType) bool {
+		out = append(out, mt.Descriptor().FullName())
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// FindMessageByName ищет сообщение в реестре, затем в protoregistry.GlobalTypes.
+func (t *ProtoTypes) FindMessageByName(name protoreflect.FullName) (protoreflect.MessageType, error) {
+	t.mu.RLock()
+	mt, err := t.types.FindMessageByName(name)
+	t.mu.RUnlock()
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindMessageByName(name)
+	}
+	return mt, err
+}
+
+// FindMessageByURL нужен protojson для google.protobuf.Any.
+func (t *ProtoTypes) FindMessageByURL(url string) (protoreflect.MessageType, error) {
+	t.mu.RLock()
+	mt, err := t.types.FindMessageByURL(url)
+	t.mu.RUnlock()
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalTypes.FindMessageByURL(url)
+	}
+	return mt, err
+}
+
+func (t *ProtoTypes) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByName(field)
+}
+
+func (t *ProtoTypes) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return protoregistry.GlobalTypes.FindExtensionByNumber(message, field)
+}
+
+// protoFileResolver разрешает зависимости загружаемых файлов: сначала реестр, затем GlobalFiles.
+type protoFileResolver struct{ t *ProtoTypes }
+
+func (r protoFileResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	r.t.mu.RLock()
+	fd, err := r.t.files.FindFileByPath(path)
+	r.t.mu.RUnlock()
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalFiles.FindFileByPath(path)
+	}
+	return fd, err
+}
+
+func (r protoFileResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	r.t.mu.RLock()
+	d, err := r.t.files.FindDescriptorByName(name)
+	r.t.mu.RUnlock()
+	if errors.Is(err, protoregistry.NotFound) {
+		return protoregistry.GlobalFiles.FindDescriptorByName(name)
+	}
+	return d, err
+}
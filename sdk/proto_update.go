@@ -0,0 +1,96 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// TxUpdateObject читает сообщение по key, переносит в него из msg только поля из mask и записывает обратно,
+// сохраняя TTL записи. Путь маски — имена полей через точку ("profile.city"); поле, не заданное в msg,
+// очищается. Поле-сообщение в конце пути заменяется целиком, повторяющиеся поля и map — тоже.
+// Пустая или nil mask заменяет сообщение целиком. Отсутствующий ключ создаётся из замаскированных полей.
+// После вызова msg содержит итоговое (слитое) сообщение.
+func (s *Store) TxUpdateObject(tx *badger.Txn, key []byte, msg proto.Message, mask *fieldmaskpb.FieldMask) error {
+	if len(mask.GetPaths()) > 0 && !mask.IsValid(msg) {
+		return fmt.Errorf("invalid field mask %v for %s", mask.GetPaths(), msg.ProtoReflect().Descriptor().FullName())
+	}
+
+	cur := msg.ProtoReflect().New().Interface()
+	var expiresAt uint64
+	item, err := tx.Get(key)
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+	case err != nil:
+		return err
+	case !s.expired(item):
+		expiresAt = item.ExpiresAt()
+		if err := item.Value(func(val []byte) error {
+			return s.decode(key, val, cur)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if len(mask.GetPaths()) == 0 {
+		cur = msg
+	} else {
+		// значения переносятся без копирования, поэтому берём их из клона, а не из msg
+		src := proto.Clone(msg).ProtoReflect()
+		for _, path := range mask.GetPaths() {
+			applyMaskPath(cur.ProtoReflect(), src, strings.Split(path, "."))
+		}
+	}
+
+	data, _, err := s.txMarshalObject(tx, key, cur)
+	if err != nil {
+		return err
+	}
+	entry := badger.NewEntry(key, data)
+	entry.ExpiresAt = expiresAt
+	if err := tx.SetEntry(entry); err != nil {
+		return err
+	}
+	if cur != msg {
+		proto.Reset(msg)
+		proto.Merge(msg, cur)
+	}
+	return nil
+}
+
+// applyMaskPath копирует поле по пути path из src в dst.
+func applyMaskPath(dst, src protoreflect.Message, path []string) {
+	fd := dst.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if len(path) == 1 {
+		if src.Has(fd) {
+			dst.Set(fd, src.Get(fd))
+		} else {
+			dst.Clear(fd)
+		}
+		return
+	}
+	// промежуточное поле — одиночное сообщение (IsValid это гарантирует); если его нет ни в src,
+	// ни в dst, менять нечего, а пустое сообщение в dst создавать не нужно
+	if !src.Has(fd) && !dst.Has(fd) {
+		return
+	}
+	applyMaskPath(dst.Mutable(fd).Message(), src.Get(fd).Message(), path[1:])
+}
+
+// UpdateObjectFields — TxUpdateObject в собственной транзакции. Конфликт с конкурентной записью того же ключа
+// повторяется: сообщение перечитывается и маска применяется заново, поэтому параллельные обновления
+// разных полей не затирают друг друга.
+func (s *Store) UpdateObjectFields(ctx context.Context, key []byte, msg proto.Message, mask *fieldmaskpb.FieldMask) error {
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	return NewTransactionManager(s, TxManagerOptions{MaxRetries: 20}).ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		return s.TxUpdateObject(txn, key, msg, mask)
+	})
+}
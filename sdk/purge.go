@@ -0,0 +1,217 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Очистка по условию для данных без TTL Badger, срок которых приложение хранит в самом значении.
+// PurgeWhere проходит префикс пачками по batchSize ключей: пачка читается из снимка, а удаляется
+// одной транзакцией вместе с контрольной точкой в служебной области (SystemKeys "purge"). Ключ,
+// изменённый между чтением и удалением, проверяется заново по новому значению, поэтому свежая
+// запись под тем же ключом не удаляется. Прерванная очистка (отмена ctx, ошибка, рестарт)
+// продолжается следующим вызовом с того же префикса с места контрольной точки; завершённая — удаляет
+// её. Чтение ждёт ScanRateLimit, удаление — WriteRateLimit за каждый ключ.
+
+const defaultPurgeBatch = 1000
+
+// PurgeCheckpoint — прогресс очистки префикса.
+type PurgeCheckpoint struct {
+	// After — последний обработанный ключ; следующая пачка начинается после него.
+	After     []byte    `json:"after"`
+	Scanned   uint64    `json:"scanned"`
+	Deleted   uint64    `json:"deleted"`
+	StartedAt time.Time `json:"started_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PurgeResult — итог вызова PurgeWhere.
+type PurgeResult struct {
+	// Scanned и Deleted — за этот вызов; Total — с начала очистки, включая прерванные вызовы.
+	Scanned uint64
+	Deleted uint64
+	Total   PurgeCheckpoint
+	// Resumed — очистка продолжена с контрольной точки.
+	Resumed bool
+	Batches int
+}
+
+type purgeCandidate struct {
+	key     []byte
+	version uint64
+}
+
+func purgeCheckpointName(prefix []byte) string {
+	return "prefix:" + string(prefix)
+}
+
+// PurgeWhere удаляет ключи prefix, для которых predicate возвращает true, пачками по batchSize
+// (<= 0 — 1000). predicate вызывается из транзакции и не должен обращаться к Store. Операция
+// пишется в журнал аудита (op "purge_where"). При ошибке или отмене ctx уже удалённые пачки
+// остаются удалёнными, а повторный вызов продолжит с контрольной точки. Очистка видна в Jobs
+// (прогресс — прочитанные ключи).
+func (s *Store) PurgeWhere(ctx context.Context, prefix []byte, predicate func(KV) bool, batchSize int) (PurgeResult, error) {
+	if predicate == nil {
+		return PurgeResult{}, errors.New("purge predicate must be not nil")
+	}
+	if batchSize <= 0 {
+		batchSize = defaultPurgeBatch
+	}
+	if err := checkSystemKey(prefix); err != nil {
+		return PurgeResult{}, err
+	}
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return PurgeResult{}, err
+	}
+	var res PurgeResult
+	err := s.RunAudited(ctx, "purge_where", string(prefix), func() (err error) {
+		ctx, job := s.StartJob(ctx, JobPurge, string(prefix))
+		defer func() { job.Finish(err) }()
+		sys := NewSystemKeys(s, "purge")
+		name := purgeCheckpointName(prefix)
+		cpKey, err := sys.key(name)
+		if err != nil {
+			return err
+		}
+		state := PurgeCheckpoint{StartedAt: s.clock.Now()}
+		switch err := sys.Get(name, &state); {
+		case err == nil:
+			res.Resumed = true
+		case !errors.Is(err, ErrNotFound):
+			return err
+		}
+		tm := NewTransactionManager(s)
+		for {
+			cands, scanned, last, done, err := s.purgeBatch(ctx, prefix, state.After, batchSize, predicate)
+			if err != nil {
+				return err
+			}
+			for _, c := range cands {
+				if err := s.checkAccess(ctx, AccessDelete, c.key); err != nil {
+					return err
+				}
+				if err := s.writeLimit.wait(ctx); err != nil {
+					return err
+				}
+			}
+			var next PurgeCheckpoint
+			var deleted uint64
+			err = tm.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+				deleted = 0
+				for _, c := range cands {
+					item, err := txn.Get(c.key)
+					if errors.Is(err, badger.ErrKeyNotFound) {
+						continue
+					}
+					if err != nil {
+						return err
+					}
+					if s.expired(item) {
+						continue
+					}
+					if item.Version() != c.version {
+						// ключ перезаписан после чтения пачки — решаем по новому значению
+						v, err := item.ValueCopy(nil)
+						if err != nil {
+							return err
+						}
+						if !predicate(KV{Key: c.key, Value: v}) {
+							continue
+						}
+					}
+					if err := txn.Delete(c.key); err != nil {
+						return err
+					}
+					deleted++
+				}
+				next = state
+				if last != nil {
+					next.After = last
+				}
+				next.Scanned += scanned
+				next.Deleted += deleted
+				next.UpdatedAt = s.clock.Now()
+				if done {
+					return txn.Delete(cpKey)
+				}
+				return sys.setTxn(txn, cpKey, next)
+			})
+			if err != nil {
+				return err
+			}
+			state = next
+			res.Batches++
+			res.Scanned += scanned
+			res.Deleted += deleted
+			res.Total = state
+			job.SetProgress(int64(state.Scanned), 0)
+			if done {
+				return nil
+			}
+		}
+	})
+	return res, err
+}
+
+// purgeBatch читает из снимка до batchSize живых ключей prefix после after и возвращает тех, для кого
+// predicate истинен, число прочитанных, последний прочитанный ключ и признак конца префикса.
+func (s *Store) purgeBatch(ctx context.Context, prefix, after []byte, batchSize int, predicate func(KV) bool) (cands []purgeCandidate, scanned uint64, last []byte, done bool, err error) {
+	ctx, finish := s.trackRead(ctx, "purge")
+	defer finish()
+	done = true
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		seek := prefix
+		if after != nil {
+			seek = after
+		}
+		for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if after != nil && bytes.Equal(item.Key(), after) {
+				continue
+			}
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if scanned == uint64(batchSize) {
+				done = false
+				return nil
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			kv := KV{Key: item.KeyCopy(nil)}
+			if kv.Value, err = item.ValueCopy(nil); err != nil {
+				return err
+			}
+			scanned++
+			last = kv.Key
+			if predicate(kv) {
+				cands = append(cands, purgeCandidate{key: kv.Key, version: item.Version()})
+			}
+		}
+		return nil
+	})
+	return cands, scanned, last, done, err
+}
+
+// PurgeProgress возвращает контрольную точку незавершённой очистки prefix; false — очистка не начата
+// или завершена.
+func (s *Store) PurgeProgress(prefix []byte) (PurgeCheckpoint, bool, error) {
+	var cp PurgeCheckpoint
+	err := NewSystemKeys(s, "purge").Get(purgeCheckpointName(prefix), &cp)
+	if errors.Is(err, ErrNotFound) {
+		return PurgeCheckpoint{}, false, nil
+	}
+	return cp, err == nil, err
+}
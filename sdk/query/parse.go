@@ -0,0 +1,405 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Грамматика:
+//
+//	query   = SELECT columns [WHERE or] [LIMIT number]
+//	columns = "*" | column {"," column}
+//	column  = "key" | "value" | "value." path
+//	or      = and {OR and}
+//	and     = not {AND not}
+//	not     = NOT not | "(" or ")" | operand op operand
+//	op      = "=" | "!=" | "<>" | "<" | "<=" | ">" | ">="
+//	operand = column | 'строка' | число | TRUE | FALSE | NULL
+//
+// prefix = 'строка' — особое условие: задаёт префикс скана и допустимо только как слагаемое
+// верхнего уровня AND.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokComma
+	tokLParen
+	tokRParen
+	tokStar
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case c == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case c == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case c == '*':
+			toks = append(toks, token{tokStar, "*", i})
+			i++
+		case c == '\'':
+			var sb strings.Builder
+			j := i + 1
+			for {
+				if j >= len(src) {
+					return nil, fmt.Errorf("unterminated string at %d", i)
+				}
+				if src[j] == '\'' {
+					if j+1 < len(src) && src[j+1] == '\'' {
+						sb.WriteByte('\'')
+						j += 2
+						continue
+					}
+					break
+				}
+				sb.WriteByte(src[j])
+				j++
+			}
+			toks = append(toks, token{tokString, sb.String(), i})
+			i = j + 1
+		case strings.ContainsRune("=!<>", c):
+			j := i + 1
+			if j < len(src) && (src[j] == '=' || (c == '<' && src[j] == '>')) {
+				j++
+			}
+			op := src[i:j]
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected %q at %d", op, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i = j
+		case c == '-' || c == '.' || unicode.IsDigit(c):
+			j := i + 1
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || strings.ContainsRune(".eE+-", rune(src[j]))) {
+				if (src[j] == '+' || src[j] == '-') && src[j-1] != 'e' && src[j-1] != 'E' {
+					break
+				}
+				j++
+			}
+			toks = append(toks, token{tokNumber, src[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(c):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			toks = append(toks, token{tokIdent, src[i:j], i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(toks, token{tokEOF, "", len(src)}), nil
+}
+
+// expr — условие WHERE.
+type expr interface {
+	eval(r *row) (bool, error)
+}
+
+type andExpr struct{ l, r expr }
+type orExpr struct{ l, r expr }
+type notExpr struct{ e expr }
+
+type cmpExpr struct {
+	op   string
+	l, r operand
+}
+
+// operand — колонка (path != nil) или литерал.
+type operand struct {
+	path []string // ["key"], ["value", ...]
+	lit  any      // string, float64, bool или nil
+}
+
+type parser struct {
+	toks []token
+	i    int
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tokIdent && strings.EqualFold(t.text, kw) {
+		p.i++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at %d: %s", p.peek().pos, fmt.Sprintf(format, args...))
+}
+
+// Parse разбирает запрос.
+func Parse(src string) (*Query, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	p := &parser{toks: toks}
+	q, err := p.query()
+	if err != nil {
+		return nil, fmt.Errorf("query: %w", err)
+	}
+	return q, nil
+}
+
+func (p *parser) query() (*Query, error) {
+	if !p.keyword("select") {
+		return nil, p.errorf("expected SELECT")
+	}
+	q := &Query{}
+	if p.peek().kind == tokStar {
+		p.next()
+		q.Columns = []string{"key", "value"}
+	} else {
+		for {
+			t := p.next()
+			if t.kind != tokIdent {
+				return nil, fmt.Errorf("at %d: expected column", t.pos)
+			}
+			if _, err := columnPath(t.text); err != nil {
+				return nil, fmt.Errorf("at %d: %w", t.pos, err)
+			}
+			q.Columns = append(q.Columns, t.text)
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+
+	if p.keyword("where") {
+		where, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if q.where, q.Prefix, err = extractPrefix(where); err != nil {
+			return nil, err
+		}
+	}
+	if p.keyword("limit") {
+		t := p.next()
+		n, err := strconv.Atoi(t.text)
+		if t.kind != tokNumber || err != nil || n <= 0 {
+			return nil, fmt.Errorf("at %d: LIMIT must be a positive integer", t.pos)
+		}
+		q.Limit = n
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", t.text)
+	}
+	return q, nil
+}
+
+func (p *parser) or() (expr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("or") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (expr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("and") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l, r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.keyword("not") {
+		e, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		e, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.next().kind != tokRParen {
+			return nil, p.errorf("expected )")
+		}
+		return e, nil
+	}
+	l, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	t := p.next()
+	if t.kind != tokOp {
+		return nil, fmt.Errorf("at %d: expected comparison operator", t.pos)
+	}
+	r, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	op := t.text
+	if op == "<>" {
+		op = "!="
+	}
+	return cmpExpr{op: op, l: l, r: r}, nil
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		return operand{lit: t.text}, nil
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return operand{}, fmt.Errorf("at %d: bad number %q", t.pos, t.text)
+		}
+		return operand{lit: f}, nil
+	case tokIdent:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return operand{lit: true}, nil
+		case "false":
+			return operand{lit: false}, nil
+		case "null":
+			return operand{lit: nil}, nil
+		case "prefix":
+			return operand{path: []string{"prefix"}}, nil
+		}
+		path, err := columnPath(t.text)
+		if err != nil {
+			return operand{}, fmt.Errorf("at %d: %w", t.pos, err)
+		}
+		return operand{path: path}, nil
+	}
+	return operand{}, fmt.Errorf("at %d: expected operand", t.pos)
+}
+
+// columnPath разбирает "key", "value" и "value.a.b".
+func columnPath(col string) ([]string, error) {
+	path := strings.Split(col, ".")
+	for _, seg := range path {
+		if seg == "" {
+			return nil, fmt.Errorf("bad column %q", col)
+		}
+	}
+	switch {
+	case path[0] == "key" && len(path) == 1, path[0] == "value":
+		return path, nil
+	}
+	return nil, fmt.Errorf("unknown column %q: want key, value or value.<path>", col)
+}
+
+var errPrefixPlacement = errors.New("prefix = '...' is allowed only as a top-level AND term")
+
+// extractPrefix вынимает условие prefix из слагаемых верхнего уровня AND.
+func extractPrefix(e expr) (expr, []byte, error) {
+	var terms []expr
+	var flatten func(e expr)
+	flatten = func(e expr) {
+		if a, ok := e.(andExpr); ok {
+			flatten(a.l)
+			flatten(a.r)
+			return
+		}
+		terms = append(terms, e)
+	}
+	flatten(e)
+
+	var prefix []byte
+	var rest expr
+	for _, t := range terms {
+		if c, ok := t.(cmpExpr); ok && (c.l.isPrefix() || c.r.isPrefix()) {
+			lit := c.r
+			if c.r.isPrefix() {
+				lit = c.l
+			}
+			s, isStr := lit.lit.(string)
+			if c.op != "=" || lit.path != nil || !isStr {
+				return nil, nil, errors.New("prefix supports only prefix = 'string'")
+			}
+			if prefix != nil {
+				return nil, nil, errors.New("prefix is specified twice")
+			}
+			prefix = []byte(s)
+			continue
+		}
+		if mentionsPrefix(t) {
+			return nil, nil, errPrefixPlacement
+		}
+		if rest == nil {
+			rest = t
+		} else {
+			rest = andExpr{rest, t}
+		}
+	}
+	return rest, prefix, nil
+}
+
+func mentionsPrefix(e expr) bool {
+	switch e := e.(type) {
+	case andExpr:
+		return mentionsPrefix(e.l) || mentionsPrefix(e.r)
+	case orExpr:
+		return mentionsPrefix(e.l) || mentionsPrefix(e.r)
+	case notExpr:
+		return mentionsPrefix(e.e)
+	case cmpExpr:
+		return e.l.isPrefix() || e.r.isPrefix()
+	}
+	return false
+}
+
+func (o operand) isPrefix() bool {
+	return len(o.path) == 1 && o.path[0] == "prefix"
+}
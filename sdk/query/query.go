@@ -0,0 +1,440 @@
+// Package query — read-only запросы к Store для ручного исследования данных:
+//
+//	SELECT key, value.name WHERE prefix = 'user:v3:' AND value.id > 100 LIMIT 50
+//
+// Значения декодируются кодеком Store (sdk.Store.DecodeValue), поля берутся через protoreflect
+// для proto-сообщений и через reflect для map, срезов и структур. Запрос только читает:
+// он сканирует префикс (или всё хранилище без prefix) и фильтрует записи в памяти.
+package query
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Query — разобранный запрос.
+type Query struct {
+	Columns []string
+	// Prefix — префикс скана из условия prefix = '...'; nil — всё хранилище, кроме системных ключей "!".
+	Prefix []byte
+	// Limit — LIMIT; 0 — не задан.
+	Limit int
+
+	where expr
+}
+
+type Options struct {
+	// New возвращает указатель, в который декодируется значение ключа (например, proto-сообщение).
+	// nil — декодирование в any: JSON и msgpack дают map[string]any.
+	New func(key []byte) any
+	// MaxRows ограничивает число строк для запроса без LIMIT, по умолчанию 1000.
+	MaxRows int
+	// MaxScan ограничивает число просмотренных записей (0 — без ограничения).
+	// При достижении скан останавливается, а Result.Truncated = true.
+	MaxScan int
+}
+
+// Result — результат запроса.
+type Result struct {
+	Columns []string
+	// Rows — значения колонок; вложенные значения — map, срезы или proto.Message.
+	// Если значение не удалось декодировать, колонка value содержит сырые байты.
+	Rows    [][]any
+	Scanned int
+	// Truncated — строк или просмотренных записей больше, чем позволили LIMIT/MaxRows/MaxScan.
+	Truncated bool
+}
+
+var errStop = errors.New("stop query scan")
+
+// Run разбирает и выполняет запрос.
+func Run(ctx context.Context, store *sdk.Store, src string, opts Options) (*Result, error) {
+	q, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return q.Exec(ctx, store, opts)
+}
+
+// Exec выполняет запрос. ctx передаётся в ScanPrefixContext: principal из него проверяется AccessController.
+func (q *Query) Exec(ctx context.Context, store *sdk.Store, opts Options) (*Result, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = opts.MaxRows
+		if limit <= 0 {
+			limit = 1000
+		}
+	}
+	cols := make([][]string, len(q.Columns))
+	for i, c := range q.Columns {
+		cols[i], _ = columnPath(c)
+	}
+
+	res := &Result{Columns: q.Columns}
+	skipSystem := !bytes.HasPrefix(q.Prefix, []byte("!"))
+	err := store.ScanPrefixContext(ctx, q.Prefix, 0, func(kv sdk.KV) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if skipSystem && bytes.HasPrefix(kv.Key, []byte("!")) {
+			return nil
+		}
+		if opts.MaxScan > 0 && res.Scanned >= opts.MaxScan {
+			res.Truncated = true
+			return errStop
+		}
+		res.Scanned++
+
+		r := &row{store: store, opts: &opts, kv: kv}
+		if q.where != nil {
+			ok, err := q.where.eval(r)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", kv.Key, err)
+			}
+			if !ok {
+				return nil
+			}
+		}
+		if len(res.Rows) >= limit {
+			res.Truncated = q.Limit <= 0
+			return errStop
+		}
+		out := make([]any, len(cols))
+		for i, path := range cols {
+			out[i] = r.column(path)
+		}
+		res.Rows = append(res.Rows, out)
+		return nil
+	})
+	if err != nil && !errors.Is(err, errStop) {
+		return nil, err
+	}
+	return res, nil
+}
+
+// row — запись скана с ленивым декодированием значения.
+type row struct {
+	store   *sdk.Store
+	opts    *Options
+	kv      sdk.KV
+	decoded bool
+	value   any
+	bad     bool // значение не декодировалось
+}
+
+func (r *row) decode() {
+	if r.decoded {
+		return
+	}
+	r.decoded = true
+	var dst any = new(any)
+	if r.opts.New != nil {
+		dst = r.opts.New(r.kv.Key)
+	}
+	if err := r.store.DecodeValue(r.kv.Key, r.kv.Value, dst); err != nil {
+		r.bad = true
+		return
+	}
+	if p, ok := dst.(*any); ok {
+		r.value = *p
+	} else {
+		r.value = dst
+	}
+}
+
+func (r *row) column(path []string) any {
+	if path[0] == "key" {
+		return string(r.kv.Key)
+	}
+	r.decode()
+	if r.bad {
+		if len(path) == 1 {
+			return r.kv.Value
+		}
+		return nil
+	}
+	return resolve(r.value, path[1:])
+}
+
+func (o operand) value(r *row) any {
+	if o.path == nil {
+		return o.lit
+	}
+	return normalize(r.column(o.path))
+}
+
+func (e andExpr) eval(r *row) (bool, error) {
+	ok, err := e.l.eval(r)
+	if err != nil || !ok {
+		return false, err
+	}
+	return e.r.eval(r)
+}
+
+func (e orExpr) eval(r *row) (bool, error) {
+	ok, err := e.l.eval(r)
+	if err != nil || ok {
+		return ok, err
+	}
+	return e.r.eval(r)
+}
+
+func (e notExpr) eval(r *row) (bool, error) {
+	ok, err := e.e.eval(r)
+	return !ok, err
+}
+
+func (e cmpExpr) eval(r *row) (bool, error) {
+	l, rv := e.l.value(r), e.r.value(r)
+	c, comparable := compare(l, rv)
+	switch e.op {
+	case "=":
+		return comparable && c == 0, nil
+	case "!=":
+		return !comparable || c != 0, nil
+	}
+	if !comparable || l == nil || rv == nil {
+		return false, nil
+	}
+	if _, isBool := l.(bool); isBool {
+		return false, fmt.Errorf("operator %s is not defined for booleans", e.op)
+	}
+	switch e.op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	case ">=":
+		return c >= 0, nil
+	}
+	return false, fmt.Errorf("unknown operator %q", e.op)
+}
+
+// compare сравнивает нормализованные значения; false — значения разных типов.
+func compare(a, b any) (int, bool) {
+	switch a := a.(type) {
+	case nil:
+		return 0, b == nil
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1, true
+			case a > b:
+				return 1, true
+			}
+			return 0, true
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return strings.Compare(a, b), true
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			if a == b {
+				return 0, true
+			}
+			return 1, true
+		}
+	}
+	return 0, false
+}
+
+// normalize приводит скаляры к float64, string или bool; составные значения возвращаются как есть и ни с чем не равны.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case nil, string, bool, float64:
+		return v
+	case []byte:
+		return string(v)
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v.String()
+		}
+		return f
+	case float32:
+		return float64(v)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.String:
+		return rv.String()
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.Pointer:
+		if rv.IsNil() {
+			return nil
+		}
+	}
+	return v
+}
+
+// resolve идёт по пути path внутри значения v; несуществующее поле — nil.
+func resolve(v any, path []string) any {
+	for _, seg := range path {
+		if v == nil {
+			return nil
+		}
+		if m, ok := v.(proto.Message); ok {
+			v = protoField(m.ProtoReflect(), seg)
+			continue
+		}
+		v = reflectField(reflect.ValueOf(v), seg)
+	}
+	return v
+}
+
+func protoField(m protoreflect.Message, name string) any {
+	fields := m.Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil {
+		if n, err := strconv.Atoi(name); err == nil {
+			fd = fields.ByNumber(protoreflect.FieldNumber(n))
+		}
+	}
+	if fd == nil || (fd.HasPresence() && !m.Has(fd)) {
+		return nil
+	}
+	val := m.Get(fd)
+	switch {
+	case fd.IsList():
+		list := val.List()
+		out := make([]any, list.Len())
+		for i := range out {
+			out[i] = protoValue(fd, list.Get(i))
+		}
+		return out
+	case fd.IsMap():
+		out := make(map[string]any, val.Map().Len())
+		val.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			out[k.String()] = protoValue(fd.MapValue(), v)
+			return true
+		})
+		return out
+	}
+	return protoValue(fd, val)
+}
+
+func protoValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return v.Message().Interface()
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int64(v.Enum())
+	}
+	return v.Interface()
+}
+
+func reflectField(rv reflect.Value, seg string) any {
+	for rv.Kind() == reflect.Pointer || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return nil
+		}
+		rv = rv.Elem()
+	}
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		f := rv.MapIndex(reflect.ValueOf(seg).Convert(rv.Type().Key()))
+		if !f.IsValid() {
+			return nil
+		}
+		return f.Interface()
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(seg)
+		if err != nil || i < 0 || i >= rv.Len() {
+			return nil
+		}
+		return rv.Index(i).Interface()
+	case reflect.Struct:
+		t := rv.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if name == seg || strings.EqualFold(sf.Name, seg) {
+				return rv.Field(i).Interface()
+			}
+		}
+	}
+	return nil
+}
+
+// MarshalJSON кодирует результат для HTTP и CLI; значения проходят через JSONValue.
+func (r *Result) MarshalJSON() ([]byte, error) {
+	rows := make([][]any, len(r.Rows))
+	for i, row := range r.Rows {
+		rows[i] = make([]any, len(row))
+		for j, v := range row {
+			rows[i][j] = JSONValue(v)
+		}
+	}
+	return json.Marshal(struct {
+		Columns   []string `json:"columns"`
+		Rows      [][]any  `json:"rows"`
+		Scanned   int      `json:"scanned"`
+		Truncated bool     `json:"truncated"`
+	}{r.Columns, rows, r.Scanned, r.Truncated})
+}
+
+// JSONValue готовит значение колонки к encoding/json: proto-сообщения (в том числе вложенные
+// в map и срезы) заменяются на protojson, NaN и бесконечности — на строки.
+func JSONValue(v any) any {
+	switch v := v.(type) {
+	case proto.Message:
+		b, err := protojson.Marshal(v)
+		if err != nil {
+			return fmt.Sprintf("<%v>", err)
+		}
+		return json.RawMessage(b)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = JSONValue(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = JSONValue(e)
+		}
+		return out
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return strconv.FormatFloat(v, 'g', -1, 64)
+		}
+	}
+	return v
+}
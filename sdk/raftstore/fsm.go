@@ -0,0 +1,129 @@
+package raftstore
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+const (
+	opSet uint8 = iota + 1
+	opDelete
+)
+
+// command — запись журнала raft. ExpiresAt вычисляется лидером, чтобы все узлы
+// применили одинаковый срок жизни независимо от момента применения.
+type command struct {
+	Op        uint8  `msgpack:"o"`
+	Key       []byte `msgpack:"k"`
+	Value     []byte `msgpack:"v,omitempty"`
+	ExpiresAt uint64 `msgpack:"e,omitempty"`
+}
+
+// snapshotRecord — запись в снимке FSM.
+type snapshotRecord struct {
+	Key       []byte `msgpack:"k"`
+	Value     []byte `msgpack:"v"`
+	ExpiresAt uint64 `msgpack:"e,omitempty"`
+	UserMeta  byte   `msgpack:"m,omitempty"`
+}
+
+// fsm применяет команды журнала к sdk.Store. Store целиком принадлежит узлу:
+// восстановление из снимка очищает его через DropAll.
+type fsm struct {
+	store *sdk.Store
+}
+
+var _ raft.FSM = (*fsm)(nil)
+
+// Apply возвращает error (или nil) — его получает вызывающий Node.apply через ApplyFuture.Response.
+func (f *fsm) Apply(l *raft.Log) any {
+	var cmd command
+	if err := msgpack.Unmarshal(l.Data, &cmd); err != nil {
+		return fmt.Errorf("decode raft command: %w", err)
+	}
+	return f.store.DB().Update(func(txn *badger.Txn) error {
+		switch cmd.Op {
+		case opSet:
+			e := badger.NewEntry(cmd.Key, cmd.Value)
+			e.ExpiresAt = cmd.ExpiresAt
+			return txn.SetEntry(e)
+		case opDelete:
+			return txn.Delete(cmd.Key)
+		default:
+			return fmt.Errorf("unknown raft command %d", cmd.Op)
+		}
+	})
+}
+
+// Snapshot фиксирует состояние открытой транзакцией чтения: Persist идёт параллельно
+// с новыми Apply, а снимок должен соответствовать моменту вызова Snapshot.
+func (f *fsm) Snapshot() (raft.FSMSnapshot, error) {
+	return &fsmSnapshot{txn: f.store.DB().NewTransaction(false)}, nil
+}
+
+func (f *fsm) Restore(rc io.ReadCloser) error {
+	defer rc.Close()
+	db := f.store.DB()
+	if err := db.DropAll(); err != nil {
+		return fmt.Errorf("drop before restore: %w", err)
+	}
+
+	wb := db.NewWriteBatch()
+	defer wb.Cancel()
+	dec := msgpack.NewDecoder(rc)
+	for {
+		var rec snapshotRecord
+		if err := dec.Decode(&rec); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return fmt.Errorf("decode snapshot: %w", err)
+		}
+		e := badger.NewEntry(rec.Key, rec.Value).WithMeta(rec.UserMeta)
+		e.ExpiresAt = rec.ExpiresAt
+		if err := wb.SetEntry(e); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+type fsmSnapshot struct {
+	txn *badger.Txn
+}
+
+func (s *fsmSnapshot) Persist(sink raft.SnapshotSink) error {
+	err := func() error {
+		enc := msgpack.NewEncoder(sink)
+		it := s.txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			rec := snapshotRecord{Key: item.Key(), Value: value, ExpiresAt: item.ExpiresAt(), UserMeta: item.UserMeta()}
+			if err := enc.Encode(&rec); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
+}
+
+func (s *fsmSnapshot) Release() {
+	s.txn.Discard()
+}
@@ -0,0 +1,155 @@
+package raftstore
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// logStore — raft.LogStore и raft.StableStore поверх отдельного экземпляра Badger.
+// Записи журнала: logPrefix + big-endian индекс; значения StableStore: stablePrefix + ключ.
+type logStore struct {
+	db *badger.DB
+}
+
+var (
+	logPrefix    = []byte("l:")
+	stablePrefix = []byte("s:")
+
+	// errStableNotFound — raft сравнивает текст ошибки StableStore с "not found".
+	errStableNotFound = errors.New("not found")
+)
+
+var (
+	_ raft.LogStore    = (*logStore)(nil)
+	_ raft.StableStore = (*logStore)(nil)
+)
+
+func logKey(index uint64) []byte {
+	key := make([]byte, len(logPrefix)+8)
+	copy(key, logPrefix)
+	binary.BigEndian.PutUint64(key[len(logPrefix):], index)
+	return key
+}
+
+func (s *logStore) FirstIndex() (uint64, error) {
+	return s.edgeIndex(false)
+}
+
+func (s *logStore) LastIndex() (uint64, error) {
+	return s.edgeIndex(true)
+}
+
+// edgeIndex — первый (или последний при reverse) индекс журнала, 0 — журнал пуст.
+func (s *logStore) edgeIndex(reverse bool) (uint64, error) {
+	var index uint64
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Prefix = logPrefix
+		opts.Reverse = reverse
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		seek := logPrefix
+		if reverse {
+			seek = logKey(^uint64(0))
+		}
+		it.Seek(seek)
+		if it.ValidForPrefix(logPrefix) {
+			index = binary.BigEndian.Uint64(it.Item().Key()[len(logPrefix):])
+		}
+		return nil
+	})
+	return index, err
+}
+
+func (s *logStore) GetLog(index uint64, log *raft.Log) error {
+	return s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(logKey(index))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return raft.ErrLogNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return msgpack.Unmarshal(val, log)
+		})
+	})
+}
+
+func (s *logStore) StoreLog(log *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{log})
+}
+
+func (s *logStore) StoreLogs(logs []*raft.Log) error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for _, l := range logs {
+		data, err := msgpack.Marshal(l)
+		if err != nil {
+			return err
+		}
+		if err := wb.Set(logKey(l.Index), data); err != nil {
+			return err
+		}
+	}
+	return wb.Flush()
+}
+
+func (s *logStore) DeleteRange(min, max uint64) error {
+	wb := s.db.NewWriteBatch()
+	defer wb.Cancel()
+	for i := min; i <= max; i++ {
+		if err := wb.Delete(logKey(i)); err != nil {
+			return err
+		}
+		if i == max { // защита от переполнения при max == MaxUint64
+			break
+		}
+	}
+	return wb.Flush()
+}
+
+func (s *logStore) Set(key, val []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(append(append([]byte(nil), stablePrefix...), key...), val)
+	})
+}
+
+func (s *logStore) Get(key []byte) ([]byte, error) {
+	var out []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(append(append([]byte(nil), stablePrefix...), key...))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return errStableNotFound
+		}
+		if err != nil {
+			return err
+		}
+		out, err = item.ValueCopy(nil)
+		return err
+	})
+	return out, err
+}
+
+func (s *logStore) SetUint64(key []byte, val uint64) error {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], val)
+	return s.Set(key, buf[:])
+}
+
+func (s *logStore) GetUint64(key []byte) (uint64, error) {
+	val, err := s.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(val) != 8 {
+		return 0, errors.New("invalid uint64 value")
+	}
+	return binary.BigEndian.Uint64(val), nil
+}
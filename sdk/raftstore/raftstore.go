@@ -0,0 +1,230 @@
+// Package raftstore — экспериментальный реплицируемый режим sdk.Store поверх hashicorp/raft.
+// Записи проходят через журнал raft и применяются к локальному Badger на каждом узле;
+// писать можно только через лидера. Журнал raft хранится в отдельном экземпляре Badger.
+// API может меняться без сохранения совместимости.
+package raftstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/hashicorp/raft"
+	"github.com/vmihailenco/msgpack/v5"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+// ErrNotLeader — запись или линеаризуемое чтение на узле, который не является лидером.
+var ErrNotLeader = raft.ErrNotLeader
+
+type ReadConsistency uint8
+
+const (
+	// ReadLocal — чтение локальной копии, возможно отстающей от лидера.
+	ReadLocal ReadConsistency = iota
+	// ReadLinearizable — чтение только на лидере после Barrier: видны все подтверждённые записи.
+	ReadLinearizable
+)
+
+type Config struct {
+	// NodeID — уникальный и постоянный идентификатор узла.
+	NodeID string
+	// BindAddr — адрес TCP-транспорта raft, например "0.0.0.0:7000".
+	BindAddr string
+	// AdvertiseAddr — адрес, по которому узел доступен остальным, по умолчанию BindAddr.
+	AdvertiseAddr string
+	// Dir — каталог журнала и снимков raft (не каталог данных Store).
+	Dir string
+	// Bootstrap — создать новый кластер из одного этого узла; остальные добавляются через AddVoter.
+	// Игнорируется, если в Dir уже есть состояние raft.
+	Bootstrap bool
+	// ApplyTimeout — таймаут записи, если у ctx нет дедлайна; по умолчанию 5s.
+	ApplyTimeout time.Duration
+	// LogOutput — куда пишет логи raft, по умолчанию os.Stderr (уровень ERROR).
+	LogOutput io.Writer
+	// Raft — тонкая настройка raft; LocalID, LogOutput и LogLevel заполняются из Config, если пусты.
+	Raft *raft.Config
+}
+
+// Node — узел реплицируемого хранилища.
+type Node struct {
+	cfg       Config
+	store     *sdk.Store
+	raft      *raft.Raft
+	transport *raft.NetworkTransport
+	logDB     *badger.DB
+}
+
+// NewNode запускает raft-узел поверх store. store должен принадлежать узлу целиком:
+// писать в него в обход Node нельзя, а восстановление из снимка очищает его.
+func NewNode(cfg Config, store *sdk.Store) (*Node, error) {
+	if cfg.NodeID == "" || cfg.BindAddr == "" || cfg.Dir == "" {
+		return nil, errors.New("NodeID, BindAddr and Dir are required")
+	}
+	if cfg.ApplyTimeout <= 0 {
+		cfg.ApplyTimeout = 5 * time.Second
+	}
+	if cfg.LogOutput == nil {
+		cfg.LogOutput = os.Stderr
+	}
+	rc := raft.DefaultConfig()
+	if cfg.Raft != nil {
+		c := *cfg.Raft
+		rc = &c
+	}
+	if rc.LocalID == "" {
+		rc.LocalID = raft.ServerID(cfg.NodeID)
+	}
+	if rc.LogOutput == nil && rc.Logger == nil {
+		rc.LogOutput = cfg.LogOutput
+	}
+	if rc.LogLevel == "" || cfg.Raft == nil {
+		rc.LogLevel = "ERROR"
+	}
+
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("make raft dir: %w", err)
+	}
+	logDB, err := badger.Open(badger.DefaultOptions(filepath.Join(cfg.Dir, "log")).WithLogger(nil))
+	if err != nil {
+		return nil, fmt.Errorf("open raft log: %w", err)
+	}
+	logs := &logStore{db: logDB}
+
+	snapshots, err := raft.NewFileSnapshotStore(cfg.Dir, 2, cfg.LogOutput)
+	if err != nil {
+		_ = logDB.Close()
+		return nil, fmt.Errorf("open raft snapshots: %w", err)
+	}
+
+	advertise := cfg.AdvertiseAddr
+	if advertise == "" {
+		advertise = cfg.BindAddr
+	}
+	addr, err := net.ResolveTCPAddr("tcp", advertise)
+	if err != nil {
+		_ = logDB.Close()
+		return nil, fmt.Errorf("resolve advertise addr: %w", err)
+	}
+	transport, err := raft.NewTCPTransport(cfg.BindAddr, addr, 3, 10*time.Second, cfg.LogOutput)
+	if err != nil {
+		_ = logDB.Close()
+		return nil, fmt.Errorf("raft transport: %w", err)
+	}
+
+	r, err := raft.NewRaft(rc, &fsm{store: store}, logs, logs, snapshots, transport)
+	if err != nil {
+		_ = transport.Close()
+		_ = logDB.Close()
+		return nil, fmt.Errorf("start raft: %w", err)
+	}
+
+	if cfg.Bootstrap {
+		has, err := raft.HasExistingState(logs, logs, snapshots)
+		if err == nil && !has {
+			err = r.BootstrapCluster(raft.Configuration{Servers: []raft.Server{{
+				ID:      rc.LocalID,
+				Address: transport.LocalAddr(),
+			}}}).Error()
+		}
+		if err != nil {
+			_ = r.Shutdown().Error()
+			_ = transport.Close()
+			_ = logDB.Close()
+			return nil, fmt.Errorf("bootstrap raft: %w", err)
+		}
+	}
+
+	return &Node{cfg: cfg, store: store, raft: r, transport: transport, logDB: logDB}, nil
+}
+
+// Shutdown останавливает raft и закрывает журнал. Store закрывает вызывающий.
+func (n *Node) Shutdown() error {
+	err := n.raft.Shutdown().Error()
+	if cerr := n.transport.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := n.logDB.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// IsLeader — является ли узел текущим лидером.
+func (n *Node) IsLeader() bool {
+	return n.raft.State() == raft.Leader
+}
+
+// Leader возвращает идентификатор и адрес известного узлу лидера (пустые, если лидер не выбран).
+func (n *Node) Leader() (id, addr string) {
+	a, i := n.raft.LeaderWithID()
+	return string(i), string(a)
+}
+
+// AddVoter добавляет узел в кластер; вызывается на лидере.
+func (n *Node) AddVoter(ctx context.Context, id, addr string) error {
+	return n.raft.AddVoter(raft.ServerID(id), raft.ServerAddress(addr), 0, n.timeout(ctx)).Error()
+}
+
+// RemoveServer исключает узел из кластера; вызывается на лидере.
+func (n *Node) RemoveServer(ctx context.Context, id string) error {
+	return n.raft.RemoveServer(raft.ServerID(id), 0, n.timeout(ctx)).Error()
+}
+
+func (n *Node) timeout(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return max(time.Until(deadline), time.Millisecond)
+	}
+	return n.cfg.ApplyTimeout
+}
+
+func (n *Node) apply(ctx context.Context, cmd command) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	data, err := msgpack.Marshal(&cmd)
+	if err != nil {
+		return err
+	}
+	f := n.raft.Apply(data, n.timeout(ctx))
+	if err := f.Error(); err != nil {
+		return err
+	}
+	if err, ok := f.Response().(error); ok {
+		return err
+	}
+	return nil
+}
+
+// Set реплицирует запись; ttl считается от часов Store лидера. ErrNotLeader на ведомом узле.
+func (n *Node) Set(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	e := n.store.NewEntry(key, value, ttl)
+	return n.apply(ctx, command{Op: opSet, Key: key, Value: value, ExpiresAt: e.ExpiresAt})
+}
+
+// Delete реплицирует удаление. ErrNotLeader на ведомом узле.
+func (n *Node) Delete(ctx context.Context, key []byte) error {
+	return n.apply(ctx, command{Op: opDelete, Key: key})
+}
+
+// Get читает ключ с заданной согласованностью.
+func (n *Node) Get(ctx context.Context, key []byte, consistency ReadConsistency) ([]byte, error) {
+	if consistency == ReadLinearizable {
+		if err := n.raft.Barrier(n.timeout(ctx)).Error(); err != nil {
+			return nil, err
+		}
+	}
+	return n.store.Get(key)
+}
+
+// Store — локальная копия данных для чтений (сканов, агрегатов) без гарантий свежести.
+func (n *Node) Store() *sdk.Store {
+	return n.store
+}
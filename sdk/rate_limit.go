@@ -0,0 +1,85 @@
+package sdk
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimit — ограничение частоты операций. OpsPerSec <= 0 — без ограничения.
+type RateLimit struct {
+	OpsPerSec float64
+	// Burst — сколько операций можно выполнить подряд без ожидания, <= 0 — max(1, OpsPerSec).
+	Burst int
+}
+
+// RateLimitStats — сколько раз и как долго операции ждали лимитеров.
+type RateLimitStats struct {
+	WriteWaits     uint64
+	WriteThrottled time.Duration
+	ScanWaits      uint64
+	ScanThrottled  time.Duration
+}
+
+type throttle struct {
+	lim       *rate.Limiter
+	waits     atomic.Uint64
+	throttled atomic.Int64
+}
+
+func newThrottle(l RateLimit) *throttle {
+	if l.OpsPerSec <= 0 {
+		return nil
+	}
+	burst := l.Burst
+	if burst <= 0 {
+		burst = max(1, int(l.OpsPerSec))
+	}
+	return &throttle{lim: rate.NewLimiter(rate.Limit(l.OpsPerSec), burst)}
+}
+
+// wait резервирует одну операцию и ждёт своей очереди; при отмене ctx резерв возвращается.
+// nil-throttle не ограничивает.
+func (t *throttle) wait(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r := t.lim.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	t.waits.Add(1)
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		t.throttled.Add(int64(time.Since(start)))
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		t.throttled.Add(int64(time.Since(start)))
+		return ctx.Err()
+	}
+}
+
+func (t *throttle) stats() (uint64, time.Duration) {
+	if t == nil {
+		return 0, 0
+	}
+	return t.waits.Load(), time.Duration(t.throttled.Load())
+}
+
+// RateLimitStats возвращает накопленную статистику ожидания Options.WriteRateLimit/ScanRateLimit.
+func (s *Store) RateLimitStats() RateLimitStats {
+	var st RateLimitStats
+	st.WriteWaits, st.WriteThrottled = s.writeLimit.stats()
+	st.ScanWaits, st.ScanThrottled = s.scanLimit.stats()
+	return st
+}
@@ -0,0 +1,149 @@
+package sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// RateLimiter — лимитер по ключам (пользователь, IP, API-ключ) по алгоритму GCRA с состоянием в Store:
+// на ключ хранится одно число — теоретическое время прибытия (TAT) следующего запроса, — поэтому
+// состояние переживает перезапуск, а запись истекает по TTL, как только ведро снова полное.
+// В отличие от Options.WriteRateLimit ограничивает не операции Store, а события приложения.
+type RateLimiter struct {
+	store    *Store
+	tx       *Manager
+	prefix   string
+	interval time.Duration // интервал между запросами при равномерном потоке
+	burst    int
+}
+
+// RateDecision — решение лимитера.
+type RateDecision struct {
+	Allowed bool
+	// Remaining — сколько ещё запросов пройдёт подряд сразу после этого.
+	Remaining int
+	// RetryAfter — через сколько повторить отклонённый запрос (0 для разрешённого).
+	RetryAfter time.Duration
+}
+
+// NewRateLimiter создаёт лимитер с ключами под prefix (по умолчанию "rl:").
+// limit.Burst <= 0 — max(1, OpsPerSec), как у RateLimit в Options.
+func NewRateLimiter(store *Store, prefix string, limit RateLimit) (*RateLimiter, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if limit.OpsPerSec <= 0 {
+		return nil, errors.New("rate limiter OpsPerSec must be positive")
+	}
+	if prefix == "" {
+		prefix = "rl:"
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = max(1, int(limit.OpsPerSec))
+	}
+	return &RateLimiter{
+		store:    store,
+		tx:       NewTransactionManager(store, TxManagerOptions{MaxRetries: 20}),
+		prefix:   prefix,
+		interval: time.Duration(float64(time.Second) / limit.OpsPerSec),
+		burst:    burst,
+	}, nil
+}
+
+// Allow — AllowN(ctx, key, 1).Allowed.
+func (rl *RateLimiter) Allow(ctx context.Context, key string) (bool, error) {
+	d, err := rl.AllowN(ctx, key, 1)
+	return d.Allowed, err
+}
+
+// AllowN пропускает n запросов, если они укладываются в лимит; отклонённый запрос ничего не расходует.
+func (rl *RateLimiter) AllowN(ctx context.Context, key string, n int) (RateDecision, error) {
+	var d RateDecision
+	err := rl.update(ctx, key, n, func(now, tat, newTat time.Time) bool {
+		allowAt := newTat.Add(-rl.burstOffset())
+		if now.Before(allowAt) {
+			d = RateDecision{RetryAfter: allowAt.Sub(now), Remaining: rl.remaining(now, tat)}
+			return false
+		}
+		d = RateDecision{Allowed: true, Remaining: rl.remaining(now, newTat)}
+		return true
+	})
+	return d, err
+}
+
+// Reserve всегда расходует n запросов и возвращает, сколько нужно подождать перед их выполнением
+// (0 — сразу). Подходит для фоновых задач, которые должны выполниться, но не быстрее лимита.
+func (rl *RateLimiter) Reserve(ctx context.Context, key string, n int) (time.Duration, error) {
+	var wait time.Duration
+	err := rl.update(ctx, key, n, func(now, _, newTat time.Time) bool {
+		wait = max(0, newTat.Add(-rl.burstOffset()).Sub(now))
+		return true
+	})
+	return wait, err
+}
+
+// Reset сбрасывает состояние ключа (ведро снова полное).
+func (rl *RateLimiter) Reset(key string) error {
+	return rl.store.Delete(rl.key(key))
+}
+
+func (rl *RateLimiter) key(k string) []byte {
+	return []byte(rl.prefix + k)
+}
+
+func (rl *RateLimiter) burstOffset() time.Duration {
+	return rl.interval * time.Duration(rl.burst)
+}
+
+// remaining — сколько запросов ещё помещается в ведро при данном TAT.
+func (rl *RateLimiter) remaining(now, tat time.Time) int {
+	free := rl.burstOffset() - tat.Sub(now)
+	if free <= 0 {
+		return 0
+	}
+	return int(free / rl.interval)
+}
+
+// update читает TAT ключа, вызывает decide и, если тот вернул true, сохраняет новый TAT.
+func (rl *RateLimiter) update(ctx context.Context, key string, n int, decide func(now, tat, newTat time.Time) bool) error {
+	if n <= 0 || n > rl.burst {
+		return fmt.Errorf("rate limiter: n must be in [1, %d]", rl.burst)
+	}
+	k := rl.key(key)
+	return rl.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		now := rl.store.clock.Now()
+		tat := now
+		item, err := txn.Get(k)
+		switch {
+		case errors.Is(err, badger.ErrKeyNotFound):
+		case err != nil:
+			return err
+		case !rl.store.expired(item):
+			if err := item.Value(func(val []byte) error {
+				if len(val) != 8 {
+					return fmt.Errorf("rate limiter: corrupted state of %q", key)
+				}
+				if t := time.Unix(0, int64(binary.BigEndian.Uint64(val))); t.After(now) {
+					tat = t
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		}
+
+		newTat := tat.Add(rl.interval * time.Duration(n))
+		if !decide(now, tat, newTat) {
+			return nil
+		}
+		// к сроку newTat ведро снова полное и запись не нужна; TTL в Badger — в секундах, округляем вверх
+		ttl := newTat.Sub(now).Truncate(time.Second) + time.Second
+		return txn.SetEntry(rl.store.NewEntry(k, binary.BigEndian.AppendUint64(nil, uint64(newTat.UnixNano())), ttl))
+	})
+}
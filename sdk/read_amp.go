@@ -0,0 +1,248 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Анализ усиления чтения: для выборки вызовов Get/GetContext снимаются счётчики Badger до и после
+// чтения — сколько memtable и таблиц LSM (по уровням) просмотрено, сколько проверок bloom-фильтров
+// и сколько из них отсекли таблицу, было ли чтение из value-log, попадания и промахи блочного кеша.
+// Результат копится по префиксам ключей. Много таблиц L0 на чтение — накопление L0 (компакция не
+// успевает), много промахов кеша — мал BlockCacheSize.
+//
+// Счётчики Badger общие на процесс, поэтому измеренные чтения выполняются по одному, а выборка,
+// во время которой прошли чужие чтения, отбрасывается (ReadAmpReport.Discarded).
+
+// ReadAmpOptions — параметры StartReadAmpSampling.
+type ReadAmpOptions struct {
+	// SampleRate — доля измеряемых Get (0..1], по умолчанию 0.01.
+	SampleRate float64
+	// Prefix возвращает группу ключа; по умолчанию — ключ до первого ':' включительно
+	// (ключи без ':' — в группе "").
+	Prefix func(key []byte) string
+}
+
+// ReadAmp — средние на одно измеренное чтение ключей префикса.
+type ReadAmp struct {
+	Prefix  string
+	Samples uint64
+	// Found — доля чтений, нашедших ключ.
+	Found     float64
+	Memtables float64
+	// Tables — просмотренные таблицы LSM, TablesByLevel — по уровням (индекс — уровень).
+	Tables        float64
+	TablesByLevel []float64
+	// BloomChecks — проверки bloom-фильтров; BloomNegatives — из них отсекшие таблицу.
+	BloomChecks    float64
+	BloomNegatives float64
+	VlogReads      float64
+	CacheHits      float64
+	CacheMisses    float64
+	Latency        time.Duration
+}
+
+// ReadAmpReport — результат ReadAmpStats.
+type ReadAmpReport struct {
+	// Prefixes — по убыванию числа измерений.
+	Prefixes []ReadAmp
+	// Discarded — измерения, испорченные параллельными чтениями.
+	Discarded uint64
+	// Levels — текущее состояние уровней LSM (число таблиц L0, размеры, score компакции).
+	Levels []badger.LevelInfo
+}
+
+type readAmpSums struct {
+	samples, found, memtables, bloomChecks, bloomNegatives, vlogReads, cacheHits, cacheMisses uint64
+	tables                                                                                    []uint64
+	latency                                                                                   time.Duration
+}
+
+type readAmpSampler struct {
+	rate   float64
+	prefix func(key []byte) string
+	levels int
+
+	measure sync.Mutex // измерения по одному
+
+	mu        sync.Mutex
+	sums      map[string]*readAmpSums
+	discarded uint64
+}
+
+// readAmpCounters — снимок счётчиков Badger.
+type readAmpCounters struct {
+	gets, memtables, bloomAll, bloomHit, vlogReads, cacheHits, cacheMisses int64
+	tables                                                                 []int64
+}
+
+// StartReadAmpSampling включает измерение усиления чтения. Требует метрик Badger (включены по умолчанию).
+func (s *Store) StartReadAmpSampling(opts ReadAmpOptions) error {
+	if !s.db.Opts().MetricsEnabled {
+		return errors.New("read amplification sampling requires badger metrics")
+	}
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 0.01
+	}
+	if opts.Prefix == nil {
+		opts.Prefix = firstSegment
+	}
+	r := &readAmpSampler{
+		rate:   opts.SampleRate,
+		prefix: opts.Prefix,
+		levels: s.db.Opts().MaxLevels,
+		sums:   make(map[string]*readAmpSums),
+	}
+	if !s.readAmp.CompareAndSwap(nil, r) {
+		return errors.New("read amplification sampling already started")
+	}
+	return nil
+}
+
+// StopReadAmpSampling выключает измерение и возвращает накопленный отчёт.
+func (s *Store) StopReadAmpSampling() ReadAmpReport {
+	r := s.readAmp.Swap(nil)
+	return s.readAmpReport(r)
+}
+
+// ReadAmpStats возвращает накопленный отчёт, не останавливая измерение.
+func (s *Store) ReadAmpStats() ReadAmpReport {
+	return s.readAmpReport(s.readAmp.Load())
+}
+
+func (s *Store) readAmpReport(r *readAmpSampler) ReadAmpReport {
+	rep := ReadAmpReport{Levels: s.db.Levels()}
+	if r == nil {
+		return rep
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rep.Discarded = r.discarded
+	for p, sum := range r.sums {
+		n := float64(sum.samples)
+		ra := ReadAmp{
+			Prefix:         p,
+			Samples:        sum.samples,
+			Found:          float64(sum.found) / n,
+			Memtables:      float64(sum.memtables) / n,
+			BloomChecks:    float64(sum.bloomChecks) / n,
+			BloomNegatives: float64(sum.bloomNegatives) / n,
+			VlogReads:      float64(sum.vlogReads) / n,
+			CacheHits:      float64(sum.cacheHits) / n,
+			CacheMisses:    float64(sum.cacheMisses) / n,
+			Latency:        sum.latency / time.Duration(sum.samples),
+			TablesByLevel:  make([]float64, len(sum.tables)),
+		}
+		for l, t := range sum.tables {
+			ra.TablesByLevel[l] = float64(t) / n
+			ra.Tables += float64(t) / n
+		}
+		rep.Prefixes = append(rep.Prefixes, ra)
+	}
+	sort.Slice(rep.Prefixes, func(i, j int) bool {
+		if rep.Prefixes[i].Samples != rep.Prefixes[j].Samples {
+			return rep.Prefixes[i].Samples > rep.Prefixes[j].Samples
+		}
+		return rep.Prefixes[i].Prefix < rep.Prefixes[j].Prefix
+	})
+	return rep
+}
+
+// getSampled — get, измеренный, если чтение попало в выборку.
+func (s *Store) getSampled(ctx context.Context, key []byte) ([]byte, error) {
+	r := s.readAmp.Load()
+	if r == nil || rand.Float64() >= r.rate {
+		return s.get(ctx, key)
+	}
+	r.measure.Lock()
+	defer r.measure.Unlock()
+	before := r.counters(s.db)
+	start := s.clock.Now()
+	out, err := s.get(ctx, key)
+	latency := s.clock.Now().Sub(start)
+	after := r.counters(s.db)
+	r.add(key, before, after, latency, err == nil)
+	return out, err
+}
+
+func (r *readAmpSampler) counters(db *badger.DB) readAmpCounters {
+	c := readAmpCounters{
+		gets:      expvarInt("badger_get_num_user"),
+		memtables: expvarInt("badger_get_num_memtable"),
+		vlogReads: expvarInt("badger_read_num_vlog"),
+		tables:    make([]int64, r.levels),
+	}
+	if m, ok := expvar.Get("badger_hit_num_lsm_bloom_filter").(*expvar.Map); ok {
+		c.bloomAll = expvarMapInt(m, "DoesNotHave_ALL")
+		c.bloomHit = expvarMapInt(m, "DoesNotHave_HIT")
+	}
+	if m, ok := expvar.Get("badger_get_num_lsm").(*expvar.Map); ok {
+		for l := range c.tables {
+			c.tables[l] = expvarMapInt(m, fmt.Sprintf("l%d", l))
+		}
+	}
+	if bc := db.BlockCacheMetrics(); bc != nil {
+		c.cacheHits, c.cacheMisses = int64(bc.Hits()), int64(bc.Misses())
+	}
+	return c
+}
+
+func (r *readAmpSampler) add(key []byte, before, after readAmpCounters, latency time.Duration, found bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if after.gets-before.gets != 1 {
+		r.discarded++
+		return
+	}
+	p := r.prefix(key)
+	sum := r.sums[p]
+	if sum == nil {
+		sum = &readAmpSums{tables: make([]uint64, r.levels)}
+		r.sums[p] = sum
+	}
+	sum.samples++
+	if found {
+		sum.found++
+	}
+	sum.memtables += uint64(after.memtables - before.memtables)
+	sum.bloomChecks += uint64(after.bloomAll - before.bloomAll)
+	sum.bloomNegatives += uint64(after.bloomHit - before.bloomHit)
+	sum.vlogReads += uint64(after.vlogReads - before.vlogReads)
+	sum.cacheHits += uint64(after.cacheHits - before.cacheHits)
+	sum.cacheMisses += uint64(after.cacheMisses - before.cacheMisses)
+	for l := range sum.tables {
+		sum.tables[l] += uint64(after.tables[l] - before.tables[l])
+	}
+	sum.latency += latency
+}
+
+func expvarInt(name string) int64 {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func expvarMapInt(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// firstSegment — ключ до первого ':' включительно; для ключа без ':' — "".
+func firstSegment(key []byte) string {
+	if i := bytes.IndexByte(key, ':'); i >= 0 {
+		return string(key[:i+1])
+	}
+	return ""
+}
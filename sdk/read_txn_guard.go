@@ -0,0 +1,193 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Сторож долгих транзакций чтения. Открытая транзакция чтения (и её итераторы) удерживает снимок:
+// GC value-log не может переписать файлы, в которых лежат видимые ей версии, и место на диске не
+// возвращается, пока транзакция жива. Утёкший итератор в коде приложения незаметен до тех пор, пока
+// не кончится диск. Сторож следит за возрастом транзакций чтения Store (сканы ScanPrefix* и View),
+// сообщает о переживших MaxAge вместе со стеком открытия и, если включено, прерывает их.
+// Транзакции, открытые напрямую через DB(), ему не видны.
+
+// ErrReadTxnTooOld — транзакция чтения прервана сторожем: она открыта дольше ReadTxnGuardOptions.MaxAge.
+var ErrReadTxnTooOld = errors.New("read transaction exceeded max age")
+
+// ReadTxnGuardOptions — параметры Options.ReadTxnGuard.
+type ReadTxnGuardOptions struct {
+	// MaxAge — допустимый возраст транзакции чтения, по умолчанию 30s.
+	MaxAge time.Duration
+	// Abort — прерывать транзакции старше MaxAge: скан или View завершается с ErrReadTxnTooOld
+	// (для View — через отмену переданного в fn контекста). false — только сообщать.
+	Abort bool
+	// CheckInterval — период проверки, по умолчанию MaxAge/4 (не реже раза в секунду).
+	CheckInterval time.Duration
+	// OnLongTxn вызывается один раз для каждой транзакции, пережившей MaxAge; nil — запись в стандартный log.
+	OnLongTxn func(LongReadTxn)
+}
+
+// LongReadTxn — транзакция чтения, пережившая MaxAge.
+type LongReadTxn struct {
+	Op      string
+	Started time.Time
+	Age     time.Duration
+	Aborted bool
+	// Stack — где транзакция открыта.
+	Stack string
+}
+
+// ReadTxnStats — состояние транзакций чтения под сторожем.
+type ReadTxnStats struct {
+	Active int
+	// OldestAge — возраст самой старой открытой транзакции.
+	OldestAge time.Duration
+	// Long — сколько транзакций пережили MaxAge, Aborted — из них прервано.
+	Long    uint64
+	Aborted uint64
+}
+
+type trackedReadTxn struct {
+	op       string
+	start    time.Time
+	pcs      []uintptr
+	cancel   context.CancelCauseFunc
+	reported bool
+}
+
+type readTxnGuard struct {
+	opts    ReadTxnGuardOptions
+	mu      sync.Mutex
+	next    uint64
+	active  map[uint64]*trackedReadTxn
+	long    atomic.Uint64
+	aborted atomic.Uint64
+}
+
+func (s *Store) startReadTxnGuard(opts ReadTxnGuardOptions) {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 30 * time.Second
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = min(opts.MaxAge/4, time.Second)
+	}
+	if opts.OnLongTxn == nil {
+		opts.OnLongTxn = logLongReadTxn
+	}
+	g := &readTxnGuard{opts: opts, active: make(map[uint64]*trackedReadTxn)}
+	s.readGuard = g
+	go func() {
+		t := s.clock.NewTicker(opts.CheckInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-s.bg.Done():
+				return
+			case <-t.C():
+				g.check(s.clock.Now())
+			}
+		}
+	}()
+}
+
+// trackRead регистрирует транзакцию чтения op; done снимает её с учёта. Без сторожа возвращает ctx как есть.
+func (s *Store) trackRead(ctx context.Context, op string) (context.Context, func()) {
+	g := s.readGuard
+	if g == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	pcs := make([]uintptr, 16)
+	pcs = pcs[:runtime.Callers(3, pcs)]
+	g.mu.Lock()
+	g.next++
+	id := g.next
+	g.active[id] = &trackedReadTxn{op: op, start: s.clock.Now(), pcs: pcs, cancel: cancel}
+	g.mu.Unlock()
+	return ctx, func() {
+		g.mu.Lock()
+		delete(g.active, id)
+		g.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+func (g *readTxnGuard) check(now time.Time) {
+	var long []LongReadTxn
+	g.mu.Lock()
+	for _, t := range g.active {
+		age := now.Sub(t.start)
+		if t.reported || age < g.opts.MaxAge {
+			continue
+		}
+		t.reported = true
+		g.long.Add(1)
+		if g.opts.Abort {
+			t.cancel(ErrReadTxnTooOld)
+			g.aborted.Add(1)
+		}
+		long = append(long, LongReadTxn{Op: t.op, Started: t.start, Age: age, Aborted: g.opts.Abort, Stack: formatStack(t.pcs)})
+	}
+	g.mu.Unlock()
+	for _, l := range long {
+		g.opts.OnLongTxn(l)
+	}
+}
+
+// ReadTxnStats возвращает состояние транзакций чтения; без Options.ReadTxnGuard — нули.
+func (s *Store) ReadTxnStats() ReadTxnStats {
+	g := s.readGuard
+	if g == nil {
+		return ReadTxnStats{}
+	}
+	now := s.clock.Now()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	st := ReadTxnStats{Active: len(g.active), Long: g.long.Load(), Aborted: g.aborted.Load()}
+	for _, t := range g.active {
+		st.OldestAge = max(st.OldestAge, now.Sub(t.start))
+	}
+	return st
+}
+
+// View выполняет fn в транзакции чтения под сторожем (Options.ReadTxnGuard). Если сторож прервёт
+// транзакцию, ctx внутри fn отменяется, а View возвращает ErrReadTxnTooOld; итераторы fn должен
+// закрыть сам, как и с badger.DB.View.
+func (s *Store) View(ctx context.Context, fn func(ctx context.Context, txn *badger.Txn) error) error {
+	ctx, done := s.trackRead(ctx, "view")
+	defer done()
+	err := s.db.View(func(txn *badger.Txn) error {
+		return fn(ctx, txn)
+	})
+	if cause := context.Cause(ctx); errors.Is(cause, ErrReadTxnTooOld) {
+		return cause
+	}
+	return err
+}
+
+func formatStack(pcs []uintptr) string {
+	var sb strings.Builder
+	frames := runtime.CallersFrames(pcs)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&sb, "%s\n\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	return sb.String()
+}
+
+func logLongReadTxn(t LongReadTxn) {
+	log.Printf("[Badger] read transaction %s open for %s (aborted=%v), blocks value-log GC; opened at:\n%s", t.Op, t.Age, t.Aborted, t.Stack)
+}
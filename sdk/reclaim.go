@@ -0,0 +1,317 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ReclaimLevel — насколько агрессивно Reclaim возвращает место.
+type ReclaimLevel int
+
+const (
+	// ReclaimLight — только проходы GC value-log с порогом 0.5; дёшево, можно часто.
+	ReclaimLight ReclaimLevel = iota
+	// ReclaimNormal — Flatten (компакция всех уровней LSM обновляет статистику мусора value-log)
+	// и проходы GC с порогом 0.3.
+	ReclaimNormal
+	// ReclaimFull — ReclaimNormal с порогом GC 0.1 и, если фрагментация после этого выше
+	// reclaimCloneFragmentation, клон живых данных в соседний каталог (см. CloseAndInstallClone).
+	ReclaimFull
+)
+
+func (l ReclaimLevel) String() string {
+	switch l {
+	case ReclaimLight:
+		return "light"
+	case ReclaimNormal:
+		return "normal"
+	case ReclaimFull:
+		return "full"
+	}
+	return fmt.Sprintf("ReclaimLevel(%d)", int(l))
+}
+
+// reclaimCloneFragmentation — доля мусора на диске, начиная с которой ReclaimFull делает клон.
+const reclaimCloneFragmentation = 0.3
+
+// ErrReclaimCloneStale — после снимка для клона в хранилище были записи, установка клона их потеряла бы.
+var ErrReclaimCloneStale = errors.New("store was written after the reclaim clone snapshot")
+
+// DiskUsage — размер файлов хранилища на диске.
+type DiskUsage struct {
+	LSM  int64
+	VLog int64
+}
+
+func (u DiskUsage) Total() int64 {
+	return u.LSM + u.VLog
+}
+
+// ReclaimReport — итог Reclaim.
+type ReclaimReport struct {
+	Level  ReclaimLevel
+	Before DiskUsage
+	After  DiskUsage
+	// LiveBytes — оценка живых данных (последние версии неистёкших ключей) до Reclaim;
+	// Fragmentation — доля остального на диске до Reclaim.
+	LiveBytes     int64
+	Fragmentation float64
+	Flattened     bool
+	// GCRewrites — число переписанных файлов value-log.
+	GCRewrites int
+	// CloneDir и CloneValueDir — каталоги клона, если он сделан; After тогда — размер клона.
+	CloneDir      string
+	CloneValueDir string
+	Duration      time.Duration
+
+	cloneVersion uint64
+}
+
+// Reclaim возвращает место на диске после массовых удалений: GC value-log, Flatten и, для
+// ReclaimFull при сильной фрагментации, клон живых данных. Работает на открытом хранилище, но
+// Flatten и клон нагружают диск — запускайте в спокойные часы. Клон — снимок: чтобы заменить им
+// хранилище, вызовите CloseAndInstallClone до любых других записей. Операция видна в Jobs с этапами
+// "measure", "flatten", "vlog_gc" и "clone".
+func (s *Store) Reclaim(ctx context.Context, level ReclaimLevel) (ReclaimReport, error) {
+	ctx, job := s.StartJob(ctx, JobReclaim, "")
+	rep, err := s.reclaim(ctx, level, job)
+	job.Finish(err)
+	return rep, err
+}
+
+func (s *Store) reclaim(ctx context.Context, level ReclaimLevel, job *Job) (ReclaimReport, error) {
+	start := s.clock.Now()
+	rep := ReclaimReport{Level: level}
+	opts := s.db.Opts()
+	if opts.InMemory {
+		return rep, errors.New("reclaim is not supported for in-memory store")
+	}
+	if opts.ReadOnly {
+		return rep, errors.New("reclaim requires a writable store")
+	}
+	var err error
+	if rep.Before, err = diskUsage(opts.Dir, opts.ValueDir); err != nil {
+		return rep, err
+	}
+	job.SetStage("measure")
+	if rep.LiveBytes, err = s.liveBytes(ctx); err != nil {
+		return rep, err
+	}
+	rep.Fragmentation = fragmentation(rep.LiveBytes, rep.Before)
+
+	ratio := 0.5
+	if level >= ReclaimNormal {
+		ratio = 0.3
+		if level == ReclaimFull {
+			ratio = 0.1
+		}
+		job.SetStage("flatten")
+		err := s.withCompactions("flatten", func() error { return s.db.Flatten(runtime.NumCPU()) })
+		if err != nil {
+			return rep, fmt.Errorf("flatten: %w", err)
+		}
+		rep.Flattened = true
+	}
+	job.SetStage("vlog_gc")
+	for {
+		if err := ctx.Err(); err != nil {
+			return rep, err
+		}
+		err := s.db.RunValueLogGC(ratio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			break
+		}
+		if err != nil {
+			return rep, fmt.Errorf("value log gc: %w", err)
+		}
+		rep.GCRewrites++
+	}
+	if rep.After, err = diskUsage(opts.Dir, opts.ValueDir); err != nil {
+		return rep, err
+	}
+
+	if level == ReclaimFull && fragmentation(rep.LiveBytes, rep.After) > reclaimCloneFragmentation {
+		job.SetStage("clone")
+		if err := s.cloneLive(ctx, &rep); err != nil {
+			return rep, fmt.Errorf("reclaim clone: %w", err)
+		}
+	}
+	rep.Duration = s.clock.Now().Sub(start)
+	return rep, nil
+}
+
+func fragmentation(live int64, u DiskUsage) float64 {
+	if u.Total() <= 0 || live >= u.Total() {
+		return 0
+	}
+	return 1 - float64(live)/float64(u.Total())
+}
+
+// liveBytes оценивает объём последних версий неистёкших ключей без чтения значений из value-log.
+func (s *Store) liveBytes(ctx context.Context) (int64, error) {
+	var n int64
+	err := s.db.View(func(txn *badger.Txn) error {
+		iopt := badger.DefaultIteratorOptions
+		iopt.PrefetchValues = false
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+		i := 0
+		for it.Rewind(); it.Valid(); it.Next() {
+			if i++; i%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			if item := it.Item(); !s.expired(item) {
+				n += item.EstimatedSize()
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// cloneLive пишет последние версии живых ключей в каталоги клона рядом с исходными.
+func (s *Store) cloneLive(ctx context.Context, rep *ReclaimReport) error {
+	opts := s.db.Opts()
+	rep.CloneDir, rep.CloneValueDir = reclaimCloneDirs(opts.Dir, opts.ValueDir)
+	for _, d := range []string{rep.CloneDir, rep.CloneValueDir} {
+		if err := os.RemoveAll(d); err != nil {
+			return err
+		}
+	}
+	co := opts
+	co.Dir, co.ValueDir = rep.CloneDir, rep.CloneValueDir
+	ok := false
+	defer func() {
+		if !ok {
+			_ = os.RemoveAll(rep.CloneDir)
+			_ = os.RemoveAll(rep.CloneValueDir)
+		}
+	}()
+
+	var err error
+	if rep.cloneVersion, err = s.streamLiveTo(ctx, co, "reclaim clone"); err != nil {
+		return err
+	}
+	if rep.After, err = diskUsage(rep.CloneDir, rep.CloneValueDir); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+// reclaimCloneDirs — каталоги клона с той же раскладкой: value-dir внутри dir остаётся внутри.
+func reclaimCloneDirs(dir, valueDir string) (string, string) {
+	cloneDir := dir + ".reclaim"
+	if valueDir == dir {
+		return cloneDir, cloneDir
+	}
+	if rel, err := filepath.Rel(dir, valueDir); err == nil && !strings.HasPrefix(rel, "..") {
+		return cloneDir, filepath.Join(cloneDir, rel)
+	}
+	return cloneDir, valueDir + ".reclaim"
+}
+
+// CloseAndInstallClone закрывает хранилище и подменяет его каталоги клоном из rep: исходные
+// переименовываются в *.old и удаляются после успешной подмены. Если после снимка клона были
+// записи, возвращает ErrReclaimCloneStale: до закрытия — оставляя хранилище открытым, а если запись
+// успела пройти между проверкой и Close — уже закрыв его, но не трогая исходные каталоги.
+// После вызова Store не пригоден, откройте его заново.
+func (s *Store) CloseAndInstallClone(rep ReclaimReport) error {
+	if rep.CloneDir == "" {
+		return errors.New("reclaim report has no clone")
+	}
+	if s.db.MaxVersion() != rep.cloneVersion {
+		return ErrReclaimCloneStale
+	}
+	opts := s.db.Opts()
+	if err := s.Close(); err != nil {
+		return err
+	}
+	return installClone(opts, rep)
+}
+
+// installClone подменяет каталоги закрытого хранилища клоном, проверив, что после снимка клона
+// в них ничего не записано: Close сбрасывает memtable в таблицы, поэтому MaxVersion видна при
+// открытии только для чтения.
+func installClone(opts badger.Options, rep ReclaimReport) error {
+	ro := opts
+	ro.ReadOnly = true
+	db, err := badger.Open(ro)
+	if err != nil {
+		return fmt.Errorf("check closed store version: %w", err)
+	}
+	version := db.MaxVersion()
+	if err := db.Close(); err != nil {
+		return err
+	}
+	if version != rep.cloneVersion {
+		return ErrReclaimCloneStale
+	}
+
+	separate := rep.CloneValueDir != rep.CloneDir &&
+		!strings.HasPrefix(rep.CloneValueDir, rep.CloneDir+string(filepath.Separator))
+	moves := [][2]string{{opts.Dir, rep.CloneDir}}
+	if separate {
+		moves = append(moves, [2]string{opts.ValueDir, rep.CloneValueDir})
+	}
+	for _, m := range moves {
+		if err := os.Rename(m[0], m[0]+".old"); err != nil {
+			return err
+		}
+		if err := os.Rename(m[1], m[0]); err != nil {
+			return fmt.Errorf("install clone (original kept in %s.old): %w", m[0], err)
+		}
+	}
+	for _, m := range moves {
+		if err := os.RemoveAll(m[0] + ".old"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diskUsage суммирует таблицы и журналы memtable LSM (*.sst, *.mem) и файлы value-log (*.vlog) в каталогах хранилища.
+func diskUsage(dir, valueDir string) (DiskUsage, error) {
+	var u DiskUsage
+	seen := make(map[string]bool)
+	for _, d := range []string{dir, valueDir} {
+		err := filepath.WalkDir(d, func(path string, e fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if e.IsDir() && path != d {
+				return fs.SkipDir
+			}
+			if e.IsDir() || seen[path] {
+				return nil
+			}
+			seen[path] = true
+			info, err := e.Info()
+			if err != nil {
+				return err
+			}
+			switch filepath.Ext(path) {
+			case ".sst", ".mem":
+				u.LSM += allocatedSize(info)
+			case ".vlog":
+				u.VLog += allocatedSize(info)
+			}
+			return nil
+		})
+		if err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
@@ -0,0 +1,60 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallClone_WriteBeforeClose(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	open := func() *Store {
+		t.Helper()
+		s, err := Open(context.Background(), Options{Dir: dir}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	s := open()
+	if err := s.Set([]byte("a"), []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	var rep ReclaimReport
+	if err := s.cloneLive(context.Background(), &rep); err != nil {
+		t.Fatal(err)
+	}
+
+	// запись между проверкой версии в CloseAndInstallClone и Close
+	if err := s.Set([]byte("late"), []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	opts := s.db.Opts()
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := installClone(opts, rep); !errors.Is(err, ErrReclaimCloneStale) {
+		t.Fatalf("install clone: %v, want ErrReclaimCloneStale", err)
+	}
+
+	s = open()
+	if v, err := s.Get([]byte("late")); err != nil || string(v) != "2" {
+		t.Fatalf("late write lost: %q %v", v, err)
+	}
+
+	rep = ReclaimReport{}
+	if err := s.cloneLive(context.Background(), &rep); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CloseAndInstallClone(rep); err != nil {
+		t.Fatalf("install fresh clone: %v", err)
+	}
+	s = open()
+	defer s.Close()
+	for _, k := range []string{"a", "late"} {
+		if _, err := s.Get([]byte(k)); err != nil {
+			t.Fatalf("get %q from installed clone: %v", k, err)
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Запись операций Store в файл-кольцо для отладки: StartRecording включает запись, StopRecording выключает,
+// ReadRecording читает трассу, Replay выполняет её на другом (обычно пустом) Store.
+//
+// Файл: заголовок recHeaderSize байт (magic, ёмкость) и ёмкость × recSize байт записей фиксированного
+// размера; запись с номером seq лежит в слоте seq % ёмкость, поэтому хранятся последние «ёмкость» операций.
+// Запись (big-endian):
+//
+//	seq(8) ts(8, unix nano) latency(8, ns) op(1) flags(1) keyLen(2) valueSize(4) ttl(4, секунды)
+//	valueHash(8, FNV-64a) key(recKeyMax байт, обрезан)
+//
+// Значения не сохраняются — только размер и хеш, поэтому трасса не содержит данных, а Replay
+// пишет синтетические значения того же размера.
+const (
+	recMagic      = "MSREC001"
+	recHeaderSize = 16
+	recSize       = 128
+	recFixed      = 44
+	recKeyMax     = recSize - recFixed
+)
+
+const (
+	recFlagErr      = 1 << 0 // операция вернула ошибку
+	recFlagNotFound = 1 << 1
+	recFlagKeyCut   = 1 << 2 // ключ длиннее recKeyMax и обрезан
+)
+
+// Операции, попадающие в трассу.
+const (
+	RecordSet          = "set"
+	RecordGet          = "get"
+	RecordDelete       = "delete"
+	RecordGetAndDelete = "get_and_delete"
+	RecordScan         = "scan"
+	RecordTx           = "tx"
+)
+
+var recOps = []string{"", RecordSet, RecordGet, RecordDelete, RecordGetAndDelete, RecordScan, RecordTx}
+
+func recOpCode(op string) byte {
+	for i, o := range recOps {
+		if o == op {
+			return byte(i)
+		}
+	}
+	return 0
+}
+
+// RecordedOp — операция из трассы.
+type RecordedOp struct {
+	Seq     uint64
+	Time    time.Time
+	Latency time.Duration
+	Op      string
+	// Key — ключ (для scan — префикс); KeyTruncated — ключ обрезан до первых байт.
+	Key          []byte
+	KeyTruncated bool
+	// ValueSize — размер записанного или прочитанного значения; для scan — число выданных записей,
+	// для tx — число попыток.
+	ValueSize int
+	ValueHash uint64
+	TTL       time.Duration
+	Err       bool
+	NotFound  bool
+}
+
+type opRecorder struct {
+	mu       sync.Mutex
+	f        *os.File
+	capacity uint64
+	seq      uint64
+	buf      [recSize]byte
+	err      error
+}
+
+// StartRecording начинает писать операции Store (Set/Get/Delete/GetAndDelete, сканы ScanPrefix и
+// транзакции Manager) в файл path, хранящий последние capacity операций. Существующий файл перезаписывается.
+// Запись — одна операция pwrite на вызов Store, поэтому режим предназначен для отладки, а не для постоянной работы.
+func (s *Store) StartRecording(path string, capacity int) error {
+	if capacity <= 0 {
+		return errors.New("recording capacity must be positive")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	hdr := make([]byte, recHeaderSize)
+	copy(hdr, recMagic)
+	binary.BigEndian.PutUint64(hdr[8:], uint64(capacity))
+	if _, err := f.WriteAt(hdr, 0); err != nil {
+		_ = f.Close()
+		return err
+	}
+	r := &opRecorder{f: f, capacity: uint64(capacity)}
+	if !s.recorder.CompareAndSwap(nil, r) {
+		_ = f.Close()
+		return errors.New("recording already started")
+	}
+	return nil
+}
+
+// StopRecording выключает запись и закрывает файл. Возвращает первую ошибку записи, если она была.
+func (s *Store) StopRecording() error {
+	r := s.recorder.Swap(nil)
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err := r.err
+	if cerr := r.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// record пишет операцию в трассу, если запись включена.
+func (s *Store) record(op string, key, value []byte, size int, ttl time.Duration, start time.Time, err error) {
+	r := s.recorder.Load()
+	if r == nil {
+		return
+	}
+	latency := s.clock.Now().Sub(start)
+	var hash uint64
+	if value != nil {
+		h := fnv.New64a()
+		_, _ = h.Write(value)
+		hash = h.Sum64()
+		size = len(value)
+	}
+	var flags byte
+	if err != nil {
+		flags |= recFlagErr
+		if errors.Is(err, ErrNotFound) {
+			flags |= recFlagNotFound
+		}
+	}
+	if len(key) > recKeyMax {
+		flags |= recFlagKeyCut
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	b := r.buf[:]
+	clear(b)
+	binary.BigEndian.PutUint64(b[0:], r.seq)
+	binary.BigEndian.PutUint64(b[8:], uint64(start.UnixNano()))
+	binary.BigEndian.PutUint64(b[16:], uint64(latency))
+	b[24] = recOpCode(op)
+	b[25] = flags
+	binary.BigEndian.PutUint16(b[26:], uint16(min(len(key), 1<<16-1)))
+	binary.BigEndian.PutUint32(b[28:], uint32(size))
+	binary.BigEndian.PutUint32(b[32:], uint32(ttl/time.Second))
+	binary.BigEndian.PutUint64(b[36:], hash)
+	copy(b[recFixed:], key)
+	off := int64(recHeaderSize) + int64(r.seq%r.capacity)*recSize
+	if _, err := r.f.WriteAt(b, off); err != nil {
+		r.err = err
+		return
+	}
+	r.seq++
+}
+
+// ReadRecording читает трассу из файла StartRecording в порядке выполнения операций.
+func ReadRecording(path string) ([]RecordedOp, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < recHeaderSize || string(data[:8]) != recMagic {
+		return nil, fmt.Errorf("%s: not an operation recording", path)
+	}
+	var ops []RecordedOp
+	for off := recHeaderSize; off+recSize <= len(data); off += recSize {
+		b := data[off : off+recSize]
+		if b[24] == 0 || int(b[24]) >= len(recOps) {
+			continue // пустой слот
+		}
+		keyLen := int(binary.BigEndian.Uint16(b[26:]))
+		op := RecordedOp{
+			Seq:          binary.BigEndian.Uint64(b[0:]),
+			Time:         time.Unix(0, int64(binary.BigEndian.Uint64(b[8:]))),
+			Latency:      time.Duration(binary.BigEndian.Uint64(b[16:])),
+			Op:           recOps[b[24]],
+			Key:          bytes.Clone(b[recFixed : recFixed+min(keyLen, recKeyMax)]),
+			KeyTruncated: b[25]&recFlagKeyCut != 0,
+			ValueSize:    int(binary.BigEndian.Uint32(b[28:])),
+			TTL:          time.Duration(binary.BigEndian.Uint32(b[32:])) * time.Second,
+			ValueHash:    binary.BigEndian.Uint64(b[36:]),
+			Err:          b[25]&recFlagErr != 0,
+			NotFound:     b[25]&recFlagNotFound != 0,
+		}
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Seq < ops[j].Seq })
+	return ops, nil
+}
+
+type ReplayOptions struct {
+	// Speed — темп воспроизведения относительно записи: 1 — исходные паузы между операциями,
+	// 2 — вдвое быстрее; <= 0 — без пауз.
+	Speed float64
+	// OnOp вызывается после каждой воспроизведённой операции с исходной записью, задержкой и ошибкой повтора.
+	OnOp func(op RecordedOp, latency time.Duration, err error)
+}
+
+// ReplayStats — итог Replay.
+type ReplayStats struct {
+	Ops int
+	// Mismatches — операции, исход которых отличается от записанного (ошибка/успех, найден/не найден).
+	Mismatches int
+	// Latency — суммарная задержка повторов по операциям; Recorded — записанная.
+	Latency  map[string]time.Duration
+	Recorded map[string]time.Duration
+}
+
+// Replay выполняет операции трассы на store: Set пишет синтетическое значение записанного размера
+// (с записанным TTL), Get/Delete/GetAndDelete повторяются как есть, scan читает столько же записей.
+// Транзакции не воспроизводятся (их содержимое не записывается), но учитываются в Recorded.
+func Replay(ctx context.Context, store *Store, ops []RecordedOp, opts ReplayOptions) (ReplayStats, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	stats := ReplayStats{Latency: make(map[string]time.Duration), Recorded: make(map[string]time.Duration)}
+	var base, replayStart time.Time
+	for i, op := range ops {
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+		if opts.Speed > 0 {
+			if i == 0 {
+				base, replayStart = op.Time, store.clock.Now()
+			}
+			due := replayStart.Add(time.Duration(float64(op.Time.Sub(base)) / opts.Speed))
+			if wait := due.Sub(store.clock.Now()); wait > 0 {
+				t := store.clock.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					t.Stop()
+					return stats, ctx.Err()
+				case <-t.C():
+				}
+			}
+		}
+		stats.Recorded[op.Op] += op.Latency
+		if op.Op == RecordTx {
+			continue
+		}
+
+		start := store.clock.Now()
+		var err error
+		switch op.Op {
+		case RecordSet:
+			err = store.SetContext(ctx, op.Key, syntheticValue(op.ValueHash, op.ValueSize), op.TTL)
+		case RecordGet:
+			_, err = store.GetContext(ctx, op.Key)
+		case RecordDelete:
+			err = store.DeleteContext(ctx, op.Key)
+		case RecordGetAndDelete:
+			_, err = store.GetAndDelete(op.Key)
+		case RecordScan:
+			err = store.ScanPrefixContext(ctx, op.Key, op.ValueSize, func(KV) error { return nil })
+		}
+		latency := store.clock.Now().Sub(start)
+		stats.Ops++
+		stats.Latency[op.Op] += latency
+		if (err != nil) != op.Err || errors.Is(err, ErrNotFound) != op.NotFound {
+			stats.Mismatches++
+		}
+		if opts.OnOp != nil {
+			opts.OnOp(op, latency, err)
+		}
+	}
+	return stats, nil
+}
+
+// syntheticValue — детерминированное значение размера size, производное от хеша исходного.
+func syntheticValue(hash uint64, size int) []byte {
+	v := make([]byte, size)
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], hash)
+	for i := range v {
+		v[i] = seed[i%8]
+	}
+	return v
+}
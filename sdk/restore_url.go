@@ -0,0 +1,580 @@
+package sdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Восстановление бэкапа по сети. RestoreFromURL скачивает файл бэкапа (gzip-поток, как у
+// FullBackupToFile) в промежуточный файл и загружает его только после сверки с манифестом: размер,
+// SHA-256 всего файла и, если в манифесте есть, SHA-256 блоков по ChunkSize. Обрыв соединения не
+// начинает загрузку заново: следующий запрос продолжает с докачанного места (Range, If-Range по
+// ETag), а промежуточный файл переживает и рестарт процесса — повторный RestoreFromURL того же URL
+// докачивает его. Блоки сверяются по мере получения, поэтому испорченный по дороге блок
+// перекачивается сразу, а не обнаруживается в конце многогигабайтной загрузки.
+//
+// Манифест пишет WriteBackupManifest рядом с бэкапом: path + BackupManifestSuffix.
+
+// BackupManifestSuffix — суффикс файла манифеста рядом с файлом бэкапа.
+const BackupManifestSuffix = ".manifest.json"
+
+const (
+	defaultManifestChunk = 64 << 20
+	defaultRestoreRetry  = 8
+)
+
+var (
+	// ErrBackupChecksum — скачанный бэкап не совпал с манифестом.
+	ErrBackupChecksum = errors.New("backup checksum mismatch")
+	// errChunkMismatch — блок испорчен при передаче; перекачивается.
+	errChunkMismatch = errors.New("backup chunk checksum mismatch")
+)
+
+// BackupManifest — размер и контрольные суммы файла бэкапа.
+type BackupManifest struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	// ChunkSize и Chunks — SHA-256 последовательных блоков файла (последний может быть короче).
+	ChunkSize int64    `json:"chunk_size,omitempty"`
+	Chunks    []string `json:"chunks,omitempty"`
+}
+
+func (m BackupManifest) validate() error {
+	if m.Size <= 0 || len(m.SHA256) != 2*sha256.Size {
+		return errors.New("backup manifest: size and sha256 are required")
+	}
+	if len(m.Chunks) == 0 {
+		return nil
+	}
+	if m.ChunkSize <= 0 || int64(len(m.Chunks)) != (m.Size+m.ChunkSize-1)/m.ChunkSize {
+		return fmt.Errorf("backup manifest: %d chunks do not cover %d bytes by %d", len(m.Chunks), m.Size, m.ChunkSize)
+	}
+	return nil
+}
+
+// chunkLen — длина блока i.
+func (m BackupManifest) chunkLen(i int) int64 {
+	return min(m.ChunkSize, m.Size-int64(i)*m.ChunkSize)
+}
+
+// NewBackupManifest считает манифест потока r с блоками по chunkSize байт (<= 0 — 64 МиБ).
+func NewBackupManifest(r io.Reader, chunkSize int64) (BackupManifest, error) {
+	if chunkSize <= 0 {
+		chunkSize = defaultManifestChunk
+	}
+	m := BackupManifest{ChunkSize: chunkSize}
+	whole := sha256.New()
+	for {
+		chunk := sha256.New()
+		n, err := io.Copy(io.MultiWriter(whole, chunk), io.LimitReader(r, chunkSize))
+		if err != nil {
+			return BackupManifest{}, err
+		}
+		if n == 0 {
+			break
+		}
+		m.Size += n
+		m.Chunks = append(m.Chunks, hex.EncodeToString(chunk.Sum(nil)))
+		if n < chunkSize {
+			break
+		}
+	}
+	m.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return m, nil
+}
+
+// WriteBackupManifest считает манифест файла бэкапа path и пишет его в path + BackupManifestSuffix.
+func WriteBackupManifest(path string, chunkSize int64) (BackupManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return BackupManifest{}, err
+	}
+	defer f.Close()
+	m, err := NewBackupManifest(f, chunkSize)
+	if err != nil {
+		return BackupManifest{}, fmt.Errorf("backup manifest %s: %w", path, err)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return BackupManifest{}, err
+	}
+	return m, os.WriteFile(path+BackupManifestSuffix, data, 0o644)
+}
+
+type RestoreURLOptions struct {
+	// Client — HTTP-клиент; nil — http.DefaultClient.
+	Client *http.Client
+	// Manifest — ожидаемые размер и контрольные суммы. nil — манифест загружается с ManifestURL,
+	// а если он пуст — с url + BackupManifestSuffix.
+	Manifest    *BackupManifest
+	ManifestURL string
+	// StagingDir — каталог промежуточного файла; пусто — os.TempDir(). Для докачки после рестарта
+	// каталог должен быть тем же.
+	StagingDir string
+	// BytesPerSecond — ограничение скорости скачивания; <= 0 — без ограничения.
+	BytesPerSecond int64
+	// MaxRetries — сколько неудачных запросов подряд (без новых байт) допустимо; <= 0 — 8. Новыми
+	// считаются байты дальше всего, что уже скачивалось: повторно скачанный испорченный блок — не прогресс.
+	MaxRetries int
+	// RetryBackoff — пауза перед первым повтором, дальше удваивается до 30 с; <= 0 — 1 с.
+	RetryBackoff time.Duration
+	// KeepStaged — не удалять промежуточный файл после загрузки.
+	KeepStaged bool
+}
+
+// RestoreURLResult — итог RestoreFromURL.
+type RestoreURLResult struct {
+	Size int64
+	// Resumed — байт, докачанных ранее (прошлыми вызовами); Downloaded — скачано этим вызовом.
+	Resumed    int64
+	Downloaded int64
+	// Retries — повторённых запросов; ChunkRetries — из них из-за испорченного блока.
+	Retries      int
+	ChunkRetries int
+	Duration     time.Duration
+}
+
+// restoreStage — состояние промежуточного файла рядом с ним (.state): докачка продолжается, только
+// если файл того же бэкапа. URL — без query: подпись presigned URL меняется от запроса к запросу.
+type restoreStage struct {
+	URL          string `json:"url"`
+	SHA256       string `json:"sha256"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// RestoreFromURL скачивает бэкап по HTTP(S) с докачкой, сверяет с манифестом и загружает в
+// ТЕКУЩУЮ открытую БД (как RestoreFromReader: на время загрузки не должно быть параллельных
+// транзакций). Несовпадение всего файла с манифестом — ErrBackupChecksum, промежуточный файл при этом
+// удаляется. Операция пишется в журнал аудита (op "restore", цель — URL без query) и видна в Jobs
+// (этапы "download", "verify", "load", "flatten").
+func (s *Store) RestoreFromURL(ctx context.Context, rawURL string, opts RestoreURLOptions) (RestoreURLResult, error) {
+	var res RestoreURLResult
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return res, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return res, fmt.Errorf("restore from url: unsupported scheme %q", u.Scheme)
+	}
+	// query часто несёт подпись (presigned URL) — в журнал и Jobs она не попадает
+	target := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	err = s.RunAudited(ctx, "restore", target, func() (err error) {
+		ctx, job := s.StartJob(ctx, JobRestore, target)
+		defer func() { job.Finish(err) }()
+		return s.restoreFromURL(ctx, rawURL, target, opts, job, &res)
+	})
+	return res, err
+}
+
+func (s *Store) restoreFromURL(ctx context.Context, rawURL, target string, opts RestoreURLOptions, job *Job, res *RestoreURLResult) error {
+	start := s.clock.Now()
+	d := &urlDownload{store: s, url: rawURL, target: target, opts: opts, job: job, res: res}
+	if d.opts.Client == nil {
+		d.opts.Client = http.DefaultClient
+	}
+	if d.opts.MaxRetries <= 0 {
+		d.opts.MaxRetries = defaultRestoreRetry
+	}
+	if d.opts.RetryBackoff <= 0 {
+		d.opts.RetryBackoff = time.Second
+	}
+	if d.opts.BytesPerSecond > 0 {
+		d.limit = rate.NewLimiter(rate.Limit(d.opts.BytesPerSecond), int(min(d.opts.BytesPerSecond, 256<<10)))
+	}
+	if opts.Manifest != nil {
+		d.manifest = *opts.Manifest
+	} else {
+		murl := opts.ManifestURL
+		if murl == "" {
+			murl = manifestURLFor(rawURL)
+		}
+		var err error
+		if d.manifest, err = d.fetchManifest(ctx, murl); err != nil {
+			return err
+		}
+	}
+	if err := d.manifest.validate(); err != nil {
+		return err
+	}
+	res.Size = d.manifest.Size
+
+	dir := opts.StagingDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	sum := sha256.Sum256([]byte(target))
+	d.path = filepath.Join(dir, "restore-"+hex.EncodeToString(sum[:8])+".part")
+
+	job.SetStage("download")
+	if err := d.download(ctx); err != nil {
+		return err
+	}
+	job.SetStage("verify")
+	if err := d.verify(); err != nil {
+		d.removeStaged()
+		return err
+	}
+	if err := s.restoreFromFile(ctx, d.path, job); err != nil {
+		return err
+	}
+	if !opts.KeepStaged {
+		d.removeStaged()
+	}
+	res.Duration = s.clock.Now().Sub(start)
+	return nil
+}
+
+// manifestURLFor — URL манифеста рядом с бэкапом (суффикс к пути, query сохраняется).
+func manifestURLFor(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL + BackupManifestSuffix
+	}
+	u.Path += BackupManifestSuffix
+	u.RawPath = ""
+	return u.String()
+}
+
+type urlDownload struct {
+	store    *Store
+	url      string
+	target   string // url без query
+	opts     RestoreURLOptions
+	job      *Job
+	res      *RestoreURLResult
+	manifest BackupManifest
+	path     string
+	state    restoreStage
+	limit    *rate.Limiter
+	// reached — самый дальний байт, до которого доходила закачка; растёт только на новых данных
+	reached int64
+}
+
+func (d *urlDownload) fetchManifest(ctx context.Context, murl string) (BackupManifest, error) {
+	var m BackupManifest
+	err := d.retry(ctx, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, murl, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := d.opts.Client.Do(req)
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return permanentStatus(resp.StatusCode), fmt.Errorf("backup manifest: %s", resp.Status)
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<20)).Decode(&m); err != nil {
+			return false, fmt.Errorf("backup manifest: %w", err)
+		}
+		return false, nil
+	})
+	return m, err
+}
+
+// retry вызывает fn, пока она не вернёт nil, постоянную ошибку (permanent) или MaxRetries неудач подряд;
+// неудача, при которой закачка продвинулась дальше прежнего (reached), счётчик сбрасывает. Байты
+// отброшенного испорченного блока прогрессом не считаются: источник, раз за разом отдающий один и тот же
+// испорченный блок, исчерпает MaxRetries.
+func (d *urlDownload) retry(ctx context.Context, fn func() (permanent bool, err error)) error {
+	backoff := d.opts.RetryBackoff
+	failures := 0
+	for {
+		before := d.reached
+		permanent, err := fn()
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if permanent {
+			return err
+		}
+		if d.reached > before {
+			failures, backoff = 0, d.opts.RetryBackoff
+		}
+		if failures++; failures > d.opts.MaxRetries {
+			return fmt.Errorf("restore from url: giving up after %d retries: %w", d.opts.MaxRetries, err)
+		}
+		d.res.Retries++
+		if errors.Is(err, errChunkMismatch) {
+			d.res.ChunkRetries++
+		}
+		log.Printf("[Badger] restore from url: %v; retrying in %s", err, backoff)
+		timer := d.store.clock.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return context.Cause(ctx)
+		case <-timer.C():
+		}
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// permanentStatus — ответ, который повтор не исправит.
+func permanentStatus(code int) bool {
+	return code >= 400 && code < 500 && code != http.StatusRequestTimeout && code != http.StatusTooManyRequests
+}
+
+// download докачивает промежуточный файл до размера из манифеста.
+func (d *urlDownload) download(ctx context.Context) error {
+	f, err := os.OpenFile(d.path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("restore from url: staging file: %w", err)
+	}
+	defer f.Close()
+	off, err := d.resumeOffset(f)
+	if err != nil {
+		return err
+	}
+	d.res.Resumed = off
+	d.reached = off
+	d.job.SetProgress(off, d.manifest.Size)
+	return d.retry(ctx, func() (bool, error) {
+		if off == d.manifest.Size {
+			return false, nil
+		}
+		var permanent bool
+		off, permanent, err = d.fetch(ctx, f, off)
+		return permanent, err
+	})
+}
+
+// resumeOffset возвращает, с какого байта продолжать: состояние от другого бэкапа обнуляет файл,
+// а при блочном манифесте файл обрезается до конца последнего целого совпавшего блока.
+func (d *urlDownload) resumeOffset(f *os.File) (int64, error) {
+	statePath := d.path + ".state"
+	var st restoreStage
+	if data, err := os.ReadFile(statePath); err == nil {
+		_ = json.Unmarshal(data, &st)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	off := fi.Size()
+	if st.URL != d.target || st.SHA256 != d.manifest.SHA256 || off > d.manifest.Size {
+		st = restoreStage{URL: d.target, SHA256: d.manifest.SHA256}
+		off = 0
+	}
+	if len(d.manifest.Chunks) > 0 && off > 0 {
+		good := int64(0)
+		buf := make([]byte, 32<<10)
+		for i := range d.manifest.Chunks {
+			n := d.manifest.chunkLen(i)
+			if good+n > off {
+				break
+			}
+			h := sha256.New()
+			if _, err := io.CopyBuffer(h, io.NewSectionReader(f, good, n), buf); err != nil {
+				return 0, err
+			}
+			if hex.EncodeToString(h.Sum(nil)) != d.manifest.Chunks[i] {
+				break
+			}
+			good += n
+		}
+		off = good
+	}
+	if err := f.Truncate(off); err != nil {
+		return 0, err
+	}
+	d.state = st
+	return off, d.saveState()
+}
+
+func (d *urlDownload) saveState() error {
+	data, err := json.Marshal(d.state)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.path+".state", data, 0o600)
+}
+
+// fetch запрашивает файл с байта off и пишет ответ в f; возвращает новый конец файла.
+func (d *urlDownload) fetch(ctx context.Context, f *os.File, off int64) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return off, true, err
+	}
+	if off > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(off, 10)+"-")
+		// объект по URL сменился — сервер отдаст его целиком (200), а не хвост
+		if v := d.state.ETag; v != "" {
+			req.Header.Set("If-Range", v)
+		} else if v := d.state.LastModified; v != "" {
+			req.Header.Set("If-Range", v)
+		}
+	}
+	resp, err := d.opts.Client.Do(req)
+	if err != nil {
+		return off, false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && off > 0:
+		if start, ok := contentRangeStart(resp.Header.Get("Content-Range")); !ok || start != off {
+			return off, false, fmt.Errorf("restore from url: unexpected Content-Range %q for offset %d", resp.Header.Get("Content-Range"), off)
+		}
+	case resp.StatusCode == http.StatusOK:
+		if off > 0 {
+			log.Printf("[Badger] restore from url: server ignored range at offset %d, downloading from start", off)
+			off = 0
+			if err := f.Truncate(0); err != nil {
+				return off, true, err
+			}
+			d.job.SetProgress(0, d.manifest.Size)
+		}
+	default:
+		return off, permanentStatus(resp.StatusCode), fmt.Errorf("restore from url: %s", resp.Status)
+	}
+	if etag, lm := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"); etag != d.state.ETag || lm != d.state.LastModified {
+		d.state.ETag, d.state.LastModified = etag, lm
+		if err := d.saveState(); err != nil {
+			return off, true, err
+		}
+	}
+
+	body := io.Reader(resp.Body)
+	if d.limit != nil {
+		body = &limitedReader{ctx: ctx, r: body, lim: d.limit}
+	}
+	var (
+		chunk    = -1
+		chunkEnd int64
+		h        hash.Hash
+	)
+	buf := make([]byte, 32<<10)
+	for off < d.manifest.Size {
+		if len(d.manifest.Chunks) > 0 && chunk < 0 {
+			chunk = int(off / d.manifest.ChunkSize)
+			chunkStart := int64(chunk) * d.manifest.ChunkSize
+			chunkEnd = chunkStart + d.manifest.chunkLen(chunk)
+			h = sha256.New()
+			// докачка с середины блока: начало блока уже на диске и тоже входит в его сумму
+			if off > chunkStart {
+				if _, err := io.CopyBuffer(h, io.NewSectionReader(f, chunkStart, off-chunkStart), buf); err != nil {
+					return off, true, err
+				}
+			}
+		}
+		want := int64(len(buf))
+		if rest := d.manifest.Size - off; rest < want {
+			want = rest
+		}
+		if chunk >= 0 {
+			want = min(want, chunkEnd-off)
+		}
+		n, rerr := body.Read(buf[:want])
+		if n > 0 {
+			if _, err := f.WriteAt(buf[:n], off); err != nil {
+				return off, true, err
+			}
+			if h != nil {
+				h.Write(buf[:n])
+			}
+			off += int64(n)
+			d.reached = max(d.reached, off)
+			d.res.Downloaded += int64(n)
+			d.job.SetProgress(off, d.manifest.Size)
+			if chunk >= 0 && off == chunkEnd {
+				if hex.EncodeToString(h.Sum(nil)) != d.manifest.Chunks[chunk] {
+					start := chunkEnd - d.manifest.chunkLen(chunk)
+					if err := f.Truncate(start); err != nil {
+						return start, true, err
+					}
+					d.job.SetProgress(start, d.manifest.Size)
+					return start, false, fmt.Errorf("%w: chunk %d", errChunkMismatch, chunk)
+				}
+				chunk = -1
+			}
+		}
+		if rerr == io.EOF {
+			if off < d.manifest.Size {
+				return off, false, io.ErrUnexpectedEOF
+			}
+			break
+		}
+		if rerr != nil {
+			return off, false, rerr
+		}
+	}
+	return off, false, nil
+}
+
+// contentRangeStart разбирает начало диапазона "bytes start-end/size".
+func contentRangeStart(v string) (int64, bool) {
+	v, ok := strings.CutPrefix(v, "bytes ")
+	if !ok {
+		return 0, false
+	}
+	start, _, ok := strings.Cut(v, "-")
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	return n, err == nil
+}
+
+// verify сверяет промежуточный файл с SHA-256 манифеста.
+func (d *urlDownload) verify() error {
+	f, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return err
+	}
+	if n != d.manifest.Size || hex.EncodeToString(h.Sum(nil)) != d.manifest.SHA256 {
+		return fmt.Errorf("%w: %s", ErrBackupChecksum, d.target)
+	}
+	return nil
+}
+
+func (d *urlDownload) removeStaged() {
+	_ = os.Remove(d.path)
+	_ = os.Remove(d.path + ".state")
+}
+
+// limitedReader — чтение не быстрее лимитера байт.
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+	lim *rate.Limiter
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if b := l.lim.Burst(); len(p) > b {
+		p = p[:b]
+	}
+	n, err := l.r.Read(p)
+	if n > 0 {
+		if werr := l.lim.WaitN(l.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,109 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testRestoreChunk = 512
+
+// newTestBackup возвращает содержимое бэкапа хранилища с n ключами и его манифест с мелкими блоками.
+func newTestBackup(t *testing.T, n int) ([]byte, BackupManifest) {
+	t.Helper()
+	src, err := Open(context.Background(), Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	for i := 0; i < n; i++ {
+		if err := src.Set([]byte(fmt.Sprintf("key:%04d", i)), bytes.Repeat([]byte{byte(i)}, 64), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "backup.gz")
+	if _, err := src.FullBackupToFile(context.Background(), path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := NewBackupManifest(bytes.NewReader(data), testRestoreChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Chunks) < 3 {
+		t.Fatalf("backup too small for the test: %d chunks", len(m.Chunks))
+	}
+	return data, m
+}
+
+func restoreFromTestServer(t *testing.T, handler http.HandlerFunc, m BackupManifest) (RestoreURLResult, error) {
+	t.Helper()
+	srv := httptest.NewServer(handler)
+	defer srv.Close()
+	dst, err := Open(context.Background(), Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	return dst.RestoreFromURL(context.Background(), srv.URL+"/backup.gz", RestoreURLOptions{
+		Manifest:     &m,
+		StagingDir:   t.TempDir(),
+		MaxRetries:   3,
+		RetryBackoff: time.Millisecond,
+	})
+}
+
+func TestRestoreFromURL_GivesUpOnPersistentlyCorruptChunk(t *testing.T) {
+	data, m := newTestBackup(t, 200)
+	corrupt := bytes.Clone(data)
+	corrupt[testRestoreChunk+10] ^= 0xff // блок 1 всегда приходит испорченным
+
+	requests := 0
+	res, err := restoreFromTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.ServeContent(w, r, "backup.gz", time.Time{}, bytes.NewReader(corrupt))
+	}, m)
+	if !errors.Is(err, errChunkMismatch) {
+		t.Fatalf("err = %v, want chunk mismatch", err)
+	}
+	if requests != 4 || res.ChunkRetries != 3 {
+		t.Fatalf("requests=%d chunk retries=%d, want 4 and 3", requests, res.ChunkRetries)
+	}
+}
+
+func TestRestoreFromURL_ResumeInsideChunk(t *testing.T) {
+	data, m := newTestBackup(t, 200)
+	cut := int64(testRestoreChunk + testRestoreChunk/2) // обрыв посреди блока 1
+
+	requests := 0
+	res, err := restoreFromTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			// объявляем весь файл, но отдаём только начало — клиент получит обрыв соединения
+			w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write(data[:cut])
+			return
+		}
+		http.ServeContent(w, r, "backup.gz", time.Time{}, bytes.NewReader(data))
+	}, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Retries != 1 || res.ChunkRetries != 0 {
+		t.Fatalf("retries=%d chunk retries=%d, want 1 and 0", res.Retries, res.ChunkRetries)
+	}
+	if res.Downloaded != m.Size {
+		t.Fatalf("downloaded %d bytes, want %d: the resumed chunk must not be downloaded again", res.Downloaded, m.Size)
+	}
+}
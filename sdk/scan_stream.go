@@ -0,0 +1,179 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+	"github.com/dgraph-io/ristretto/v2/z"
+)
+
+// ScanPrefixNoCopy — ScanPrefix без копирования ключей и значений.
+// kv.Key и kv.Value указывают во внутренние буферы Badger и валидны только внутри вызова fn:
+// сохранять их (или передавать в другие горутины) можно только скопировав.
+// Значения не предзагружаются, поэтому в памяти одновременно находится одна запись.
+func (s *Store) ScanPrefixNoCopy(prefix []byte, limit int, fn func(kv KV) error) error {
+	if err := s.checkAccess(context.Background(), AccessScan, prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(context.Background(), "scan_nocopy")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		count := 0
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			key := item.Key()
+			s.noteRead(key)
+			if err := item.Value(func(val []byte) error {
+				return fn(KV{Key: key, Value: val})
+			}); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+		return nil
+	})
+}
+
+type StreamScanOptions struct {
+	// NumGo — число горутин чтения badger.Stream, <= 0 — значение Stream по умолчанию.
+	NumGo int
+	// Workers — число горутин, вызывающих fn, по умолчанию 1 (fn вызывается последовательно).
+	Workers int
+	// BufferSize — сколько прочитанных записей может ждать обработки, по умолчанию 1024.
+	// Когда буфер полон, чтение останавливается до тех пор, пока fn не разберёт очередь,
+	// поэтому память скана ограничена BufferSize записями независимо от размера префикса.
+	BufferSize int
+}
+
+// ScanPrefixStream — параллельный скан префикса поверх badger.Stream с ограниченным буфером.
+// Порядок записей не гарантируется. Записи передаются в fn копиями, их можно сохранять.
+// Первая ошибка fn (или отмена ctx) останавливает скан и возвращается.
+func (s *Store) ScanPrefixStream(ctx context.Context, prefix []byte, opts StreamScanOptions, fn func(kv KV) error) error {
+	if err := s.checkAccess(ctx, AccessScan, prefix); err != nil {
+		return err
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BufferSize <= 0 {
+		opts.BufferSize = 1024
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var firstErr error
+	var errOnce sync.Once
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	buf := make(chan KV, opts.BufferSize)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for kv := range buf {
+				if ctx.Err() != nil {
+					continue // дочитываем канал, чтобы не блокировать Stream
+				}
+				s.noteRead(kv.Key)
+				if err := fn(kv); err != nil {
+					fail(err)
+				}
+			}
+		}()
+	}
+
+	stream := s.db.NewStream()
+	stream.Prefix = prefix
+	stream.LogPrefix = "Store.ScanPrefixStream"
+	stream.ChooseKey = func(item *badger.Item) bool { return !hiddenSystemKey(prefix, item.Key()) }
+	if opts.NumGo > 0 {
+		stream.NumGo = opts.NumGo
+	}
+	stream.KeyToList = func(key []byte, itr *badger.Iterator) (*pb.KVList, error) {
+		item := itr.Item()
+		if item.IsDeletedOrExpired() || s.expired(item) {
+			return nil, nil
+		}
+		if err := s.scanLimit.wait(ctx); err != nil {
+			return nil, nil // отмена ctx остановит Orchestrate
+		}
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			fail(err)
+			return nil, err
+		}
+		select {
+		case buf <- KV{Key: key, Value: value}:
+		case <-ctx.Done():
+		}
+		return nil, nil
+	}
+	stream.Send = func(*z.Buffer) error {
+		return nil
+	}
+
+	err := stream.Orchestrate(ctx)
+	close(buf)
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// ScanPrefixChan — ScanPrefixContext с выдачей записей в канал для select-циклов и раздачи
+// воркерам. В канале ждут не больше buffer записей (0 — без буфера): пока потребитель не
+// забирает записи, скан стоит. Канал записей закрывается по окончании скана, после чего из канала
+// ошибок читается итог — ошибка скана или ctx.Err() при отмене; успешный скан закрывает его без значения.
+// Скан держит транзакцию чтения, пока канал не вычитан: бросая чтение, отмените ctx.
+func (s *Store) ScanPrefixChan(ctx context.Context, prefix []byte, buffer int) (<-chan KV, <-chan error) {
+	out := make(chan KV, max(buffer, 0))
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		err := s.ScanPrefixContext(ctx, prefix, 0, func(kv KV) error {
+			select {
+			case out <- kv:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-s.bg.Done():
+				return errors.New("store is closed")
+			}
+		})
+		close(out)
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return out, errs
+}
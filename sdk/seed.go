@@ -0,0 +1,566 @@
+package sdk
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"gopkg.in/yaml.v3"
+)
+
+// SeedSpec — шаблон тестового набора данных для SeedFromTemplate:
+//
+//	seed: 42                       # одинаковый seed — одинаковые данные
+//	prefixes:
+//	  - prefix: "user:v3:"
+//	    count: 100000
+//	    key: seq                   # seq (по умолчанию) | uuid | hex
+//	    fields:                    # JSON-объект, кодируется кодеком хранилища
+//	      name: name
+//	      email: email
+//	      age: int(18, 90)
+//	      plan: enum(free|pro|team)
+//	      bio: text(200)
+//	    ttl: uniform(1h, 72h)      # none | fixed(d) | uniform(a, b) | exp(mean)
+//	    ttl_fraction: 0.3          # доля ключей с TTL, по умолчанию 1
+//	  - prefix: "event:"
+//	    count: 10000
+//	    message: app.v1.Event      # proto из protoregistry.GlobalTypes; поля без генератора — по типу
+//	    fields: {kind: enum(click|view)}
+//	  - prefix: "blob:"
+//	    count: 1000
+//	    bytes: uniform(512, 65536) # случайные байты без кодека
+//
+// Генераторы полей: name, first_name, last_name, email, phone, city, country, company, word, uuid,
+// seq, bool, time, text(n), int(a, b), float(a, b), enum(a|b|c), const(v).
+type SeedSpec struct {
+	Seed     int64        `yaml:"seed"`
+	Prefixes []SeedPrefix `yaml:"prefixes"`
+}
+
+// SeedPrefix — ключи одного префикса в SeedSpec.
+type SeedPrefix struct {
+	Prefix string `yaml:"prefix"`
+	Count  int    `yaml:"count"`
+	// Key — суффикс ключа: seq — номер с ведущими нулями, uuid, hex — 16 случайных hex-символов.
+	Key string `yaml:"key"`
+	// Fields — генераторы полей значения; без Message значение — JSON-подобный объект.
+	Fields map[string]string `yaml:"fields"`
+	// Message — полное имя proto-сообщения значения; MessageType (только из Go) его заменяет.
+	Message     string                   `yaml:"message"`
+	MessageType protoreflect.MessageType `yaml:"-"`
+	// Bytes — распределение размера случайного значения в байтах: fixed(n) или uniform(a, b).
+	Bytes string `yaml:"bytes"`
+	// TTL — распределение TTL: none, fixed(d), uniform(a, b), exp(mean).
+	TTL         string   `yaml:"ttl"`
+	TTLFraction *float64 `yaml:"ttl_fraction"`
+}
+
+// SeedStats — итог SeedFromTemplate.
+type SeedStats struct {
+	Keys     int64
+	Bytes    int64
+	Duration time.Duration
+}
+
+// ParseSeedSpec разбирает шаблон из YAML и проверяет генераторы.
+func ParseSeedSpec(data []byte) (*SeedSpec, error) {
+	var spec SeedSpec
+	if err := yaml.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("parse seed spec: %w", err)
+	}
+	if _, err := spec.compile(); err != nil {
+		return nil, err
+	}
+	return &spec, nil
+}
+
+// LoadSeedSpec читает шаблон из YAML-файла.
+func LoadSeedSpec(path string) (*SeedSpec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSeedSpec(data)
+}
+
+// SeedFromTemplate заполняет store данными по шаблону через WriteBatch. Данные детерминированы
+// SeedSpec.Seed, повторный запуск перезаписывает те же ключи (кроме key: uuid/hex).
+func SeedFromTemplate(ctx context.Context, store *Store, spec *SeedSpec) (SeedStats, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	start := store.clock.Now()
+	var stats SeedStats
+	prefixes, err := spec.compile()
+	if err != nil {
+		return stats, err
+	}
+	rnd := rand.New(rand.NewPCG(uint64(spec.Seed), uint64(spec.Seed)>>32|1))
+	wb := store.NewWriteBatchContext(ctx)
+	defer wb.Cancel()
+	for _, p := range prefixes {
+		width := len(strconv.Itoa(p.Count - 1))
+		for i := 0; i < p.Count; i++ {
+			if i%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return stats, err
+				}
+			}
+			key := []byte(p.Prefix + p.key(rnd, i, width))
+			value, err := p.value(store, rnd, key, i)
+			if err != nil {
+				return stats, fmt.Errorf("seed %s: %w", p.Prefix, err)
+			}
+			if err := wb.Set(key, value, p.ttl(rnd)); err != nil {
+				return stats, err
+			}
+			stats.Keys++
+			stats.Bytes += int64(len(key) + len(value))
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return stats, err
+	}
+	stats.Duration = store.clock.Now().Sub(start)
+	return stats, nil
+}
+
+// seedGen порождает значение поля: string, int64, float64, bool или time.Time.
+type seedGen func(r *rand.Rand, i int) any
+
+type seedPrefix struct {
+	SeedPrefix
+	fields      map[string]seedGen
+	names       []string
+	message     protoreflect.MessageType
+	size        func(r *rand.Rand) int
+	ttlDist     func(r *rand.Rand) time.Duration
+	ttlFraction float64
+}
+
+func (spec *SeedSpec) compile() ([]seedPrefix, error) {
+	if spec == nil || len(spec.Prefixes) == 0 {
+		return nil, errors.New("seed spec has no prefixes")
+	}
+	out := make([]seedPrefix, 0, len(spec.Prefixes))
+	for _, p := range spec.Prefixes {
+		sp, err := compileSeedPrefix(p)
+		if err != nil {
+			return nil, fmt.Errorf("seed prefix %q: %w", p.Prefix, err)
+		}
+		out = append(out, sp)
+	}
+	return out, nil
+}
+
+func compileSeedPrefix(p SeedPrefix) (seedPrefix, error) {
+	sp := seedPrefix{SeedPrefix: p, fields: make(map[string]seedGen), ttlFraction: 1}
+	if p.Count < 0 {
+		return sp, fmt.Errorf("negative count %d", p.Count)
+	}
+	switch p.Key {
+	case "", "seq", "uuid", "hex":
+	default:
+		return sp, fmt.Errorf("unknown key generator %q", p.Key)
+	}
+	for name, expr := range p.Fields {
+		g, err := parseSeedGen(expr)
+		if err != nil {
+			return sp, fmt.Errorf("field %s: %w", name, err)
+		}
+		sp.fields[name] = g
+		sp.names = append(sp.names, name)
+	}
+	sort.Strings(sp.names)
+
+	sp.message = p.MessageType
+	if sp.message == nil && p.Message != "" {
+		mt, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(p.Message))
+		if err != nil {
+			return sp, fmt.Errorf("message %s: %w", p.Message, err)
+		}
+		sp.message = mt
+	}
+	if sp.message != nil {
+		fds := sp.message.Descriptor().Fields()
+		for _, name := range sp.names {
+			if fds.ByName(protoreflect.Name(name)) == nil && fds.ByJSONName(name) == nil {
+				return sp, fmt.Errorf("%s has no field %q", sp.message.Descriptor().FullName(), name)
+			}
+		}
+	}
+	if p.Bytes != "" {
+		if sp.message != nil || len(p.Fields) > 0 {
+			return sp, errors.New("bytes cannot be combined with fields or message")
+		}
+		name, args, err := splitSeedCall(p.Bytes)
+		if err != nil {
+			return sp, err
+		}
+		lo, hi, err := seedIntArgs(name, args)
+		if err != nil || lo < 0 {
+			return sp, fmt.Errorf("bad bytes %q, want fixed(n) or uniform(a, b)", p.Bytes)
+		}
+		sp.size = func(r *rand.Rand) int { return int(lo + r.Int64N(hi-lo+1)) }
+	}
+
+	dist, err := parseTTLDist(p.TTL)
+	if err != nil {
+		return sp, err
+	}
+	sp.ttlDist = dist
+	if p.TTLFraction != nil {
+		if *p.TTLFraction < 0 || *p.TTLFraction > 1 {
+			return sp, fmt.Errorf("ttl_fraction must be in [0, 1], got %v", *p.TTLFraction)
+		}
+		sp.ttlFraction = *p.TTLFraction
+	}
+	return sp, nil
+}
+
+func (p *seedPrefix) key(r *rand.Rand, i, width int) string {
+	switch p.Key {
+	case "uuid":
+		return seedUUID(r)
+	case "hex":
+		return seedHex(r, 8)
+	}
+	return fmt.Sprintf("%0*d", width, i)
+}
+
+func (p *seedPrefix) value(store *Store, r *rand.Rand, key []byte, i int) ([]byte, error) {
+	if p.size != nil {
+		b := make([]byte, p.size(r))
+		for j := range b {
+			b[j] = byte(r.UintN(256))
+		}
+		return b, nil
+	}
+	if p.message != nil {
+		m, err := p.protoValue(r, i)
+		if err != nil {
+			return nil, err
+		}
+		return store.EncodeValue(key, m.Interface())
+	}
+	obj := make(map[string]any, len(p.names))
+	for _, name := range p.names {
+		obj[name] = p.fields[name](r, i)
+	}
+	return store.EncodeValue(key, obj)
+}
+
+// protoValue заполняет скалярные поля сообщения: по генератору из Fields или случайно по типу.
+func (p *seedPrefix) protoValue(r *rand.Rand, i int) (protoreflect.Message, error) {
+	m := p.message.New()
+	fds := m.Descriptor().Fields()
+	for j := 0; j < fds.Len(); j++ {
+		fd := fds.Get(j)
+		if fd.IsList() || fd.IsMap() || fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind {
+			continue
+		}
+		gen := p.fields[string(fd.Name())]
+		if gen == nil {
+			gen = p.fields[fd.JSONName()]
+		}
+		if gen == nil {
+			gen = seedGenForKind(fd)
+		}
+		v, err := seedProtoValue(fd, gen(r, i))
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", fd.Name(), err)
+		}
+		m.Set(fd, v)
+	}
+	return m, nil
+}
+
+func (p *seedPrefix) ttl(r *rand.Rand) time.Duration {
+	if p.ttlDist == nil || r.Float64() >= p.ttlFraction {
+		return 0
+	}
+	return max(p.ttlDist(r), time.Second)
+}
+
+func parseTTLDist(expr string) (func(r *rand.Rand) time.Duration, error) {
+	if expr == "" || expr == "none" {
+		return nil, nil
+	}
+	name, args, err := splitSeedCall(expr)
+	if err != nil {
+		return nil, err
+	}
+	ds := make([]time.Duration, len(args))
+	for i, a := range args {
+		if ds[i], err = time.ParseDuration(a); err != nil || ds[i] <= 0 {
+			return nil, fmt.Errorf("ttl %q: bad duration %q", expr, a)
+		}
+	}
+	switch {
+	case name == "fixed" && len(ds) == 1:
+		return func(*rand.Rand) time.Duration { return ds[0] }, nil
+	case name == "uniform" && len(ds) == 2 && ds[0] <= ds[1]:
+		return func(r *rand.Rand) time.Duration { return ds[0] + time.Duration(r.Int64N(int64(ds[1]-ds[0])+1)) }, nil
+	case name == "exp" && len(ds) == 1:
+		return func(r *rand.Rand) time.Duration { return time.Duration(r.ExpFloat64() * float64(ds[0])) }, nil
+	}
+	return nil, fmt.Errorf("bad ttl %q, want none, fixed(d), uniform(a, b) or exp(mean)", expr)
+}
+
+// splitSeedCall разбирает "name(a, b)" на имя и аргументы; "name" — без аргументов.
+func splitSeedCall(expr string) (string, []string, error) {
+	expr = strings.TrimSpace(expr)
+	open := strings.IndexByte(expr, '(')
+	if open < 0 {
+		return expr, nil, nil
+	}
+	if !strings.HasSuffix(expr, ")") {
+		return "", nil, fmt.Errorf("bad generator %q", expr)
+	}
+	inner := expr[open+1 : len(expr)-1]
+	var args []string
+	sep := ","
+	if strings.HasPrefix(expr, "enum(") {
+		sep = "|"
+	}
+	if strings.HasPrefix(expr, "const(") {
+		args = []string{inner}
+	} else {
+		for _, a := range strings.Split(inner, sep) {
+			args = append(args, strings.TrimSpace(a))
+		}
+	}
+	return strings.TrimSpace(expr[:open]), args, nil
+}
+
+// seedIntArgs — границы для fixed(n) и uniform(a, b).
+func seedIntArgs(name string, args []string) (int64, int64, error) {
+	nums := make([]int64, len(args))
+	for i, a := range args {
+		n, err := strconv.ParseInt(a, 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		nums[i] = n
+	}
+	switch {
+	case name == "fixed" && len(nums) == 1:
+		return nums[0], nums[0], nil
+	case name == "uniform" && len(nums) == 2 && nums[0] <= nums[1]:
+		return nums[0], nums[1], nil
+	}
+	return 0, 0, errors.New("bad range")
+}
+
+var (
+	seedFirstNames = []string{"Anna", "Boris", "Clara", "Denis", "Elena", "Fedor", "Galina", "Igor", "Julia", "Kirill", "Lena", "Maxim", "Nina", "Oleg", "Polina", "Roman", "Sofia", "Timur", "Vera", "Yuri"}
+	seedLastNames  = []string{"Ivanov", "Smirnova", "Kuznetsov", "Popova", "Vasiliev", "Petrova", "Sokolov", "Mikhailova", "Novikov", "Fedorova", "Morozov", "Volkova", "Alekseev", "Lebedeva", "Semenov"}
+	seedCities     = []string{"Moscow", "Kazan", "Novosibirsk", "Yekaterinburg", "Samara", "Berlin", "Lisbon", "Warsaw", "Prague", "Riga", "Tbilisi", "Almaty"}
+	seedCountries  = []string{"RU", "DE", "PT", "PL", "CZ", "LV", "GE", "KZ", "US", "GB"}
+	seedCompanies  = []string{"Acme", "Globex", "Initech", "Umbrella", "Hooli", "Vandelay", "Stark", "Wayne", "Tyrell", "Cyberdyne"}
+	seedWords      = strings.Fields("lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor incididunt ut labore et dolore magna aliqua enim ad minim veniam quis nostrud exercitation ullamco laboris nisi aliquip ex ea commodo consequat")
+)
+
+func seedPick(r *rand.Rand, list []string) string {
+	return list[r.IntN(len(list))]
+}
+
+func parseSeedGen(expr string) (seedGen, error) {
+	name, args, err := splitSeedCall(expr)
+	if err != nil {
+		return nil, err
+	}
+	bad := fmt.Errorf("bad generator %q", expr)
+	if args == nil {
+		switch name {
+		case "name":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedFirstNames) + " " + seedPick(r, seedLastNames) }, nil
+		case "first_name":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedFirstNames) }, nil
+		case "last_name":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedLastNames) }, nil
+		case "email":
+			return func(r *rand.Rand, i int) any {
+				return fmt.Sprintf("%s.%d@%s.example", strings.ToLower(seedPick(r, seedFirstNames)), i, strings.ToLower(seedPick(r, seedCompanies)))
+			}, nil
+		case "phone":
+			return func(r *rand.Rand, _ int) any { return fmt.Sprintf("+7%010d", r.Int64N(1e10)) }, nil
+		case "city":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedCities) }, nil
+		case "country":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedCountries) }, nil
+		case "company":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedCompanies) }, nil
+		case "word":
+			return func(r *rand.Rand, _ int) any { return seedPick(r, seedWords) }, nil
+		case "uuid":
+			return func(r *rand.Rand, _ int) any { return seedUUID(r) }, nil
+		case "seq":
+			return func(_ *rand.Rand, i int) any { return int64(i) }, nil
+		case "bool":
+			return func(r *rand.Rand, _ int) any { return r.IntN(2) == 1 }, nil
+		case "time":
+			// последний год до фиксированной точки, чтобы данные не зависели от даты запуска
+			base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+			return func(r *rand.Rand, _ int) any { return base.Add(-time.Duration(r.Int64N(int64(365 * 24 * time.Hour)))) }, nil
+		}
+		return nil, bad
+	}
+	switch name {
+	case "text":
+		if len(args) != 1 {
+			return nil, bad
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return nil, bad
+		}
+		return func(r *rand.Rand, _ int) any {
+			var sb strings.Builder
+			for sb.Len() < n {
+				if sb.Len() > 0 {
+					sb.WriteByte(' ')
+				}
+				sb.WriteString(seedPick(r, seedWords))
+			}
+			return sb.String()[:n]
+		}, nil
+	case "int":
+		lo, hi, err := seedIntArgs("uniform", args)
+		if err != nil {
+			return nil, bad
+		}
+		return func(r *rand.Rand, _ int) any { return lo + r.Int64N(hi-lo+1) }, nil
+	case "float":
+		if len(args) != 2 {
+			return nil, bad
+		}
+		lo, err1 := strconv.ParseFloat(args[0], 64)
+		hi, err2 := strconv.ParseFloat(args[1], 64)
+		if err1 != nil || err2 != nil || lo > hi {
+			return nil, bad
+		}
+		return func(r *rand.Rand, _ int) any { return math.Round((lo+r.Float64()*(hi-lo))*100) / 100 }, nil
+	case "enum":
+		if len(args) == 0 || args[0] == "" {
+			return nil, bad
+		}
+		return func(r *rand.Rand, _ int) any { return seedPick(r, args) }, nil
+	case "const":
+		v := args[0]
+		return func(*rand.Rand, int) any { return v }, nil
+	}
+	return nil, bad
+}
+
+func seedUUID(r *rand.Rand) string {
+	var b [16]byte
+	for i := range b {
+		b[i] = byte(r.UintN(256))
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	h := hex.EncodeToString(b[:])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+func seedHex(r *rand.Rand, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(r.UintN(256))
+	}
+	return hex.EncodeToString(b)
+}
+
+// seedGenForKind — генератор по умолчанию для поля proto без генератора в Fields.
+func seedGenForKind(fd protoreflect.FieldDescriptor) seedGen {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return func(r *rand.Rand, _ int) any { return r.IntN(2) == 1 }
+	case protoreflect.StringKind:
+		return func(r *rand.Rand, _ int) any { return seedPick(r, seedWords) }
+	case protoreflect.BytesKind:
+		return func(r *rand.Rand, _ int) any { return seedHex(r, 8) }
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		return func(r *rand.Rand, _ int) any { return math.Round(r.Float64()*1e4) / 100 }
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return func(r *rand.Rand, _ int) any { return int64(values.Get(r.IntN(values.Len())).Number()) }
+	}
+	return func(r *rand.Rand, _ int) any { return r.Int64N(1000) }
+}
+
+// seedProtoValue приводит сгенерированное значение к типу поля.
+func seedProtoValue(fd protoreflect.FieldDescriptor, v any) (protoreflect.Value, error) {
+	if t, ok := v.(time.Time); ok {
+		if fd.Kind() == protoreflect.StringKind {
+			return protoreflect.ValueOfString(t.Format(time.RFC3339)), nil
+		}
+		v = t.Unix()
+	}
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(fmt.Sprint(v)), nil
+	case protoreflect.BytesKind:
+		return protoreflect.ValueOfBytes([]byte(fmt.Sprint(v))), nil
+	case protoreflect.BoolKind:
+		if b, ok := v.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.EnumKind:
+		if n, ok := v.(int64); ok {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), nil
+		}
+		if s, ok := v.(string); ok {
+			if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+				return protoreflect.ValueOfEnum(ev.Number()), nil
+			}
+		}
+	case protoreflect.FloatKind:
+		if f, ok := seedFloat(v); ok {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+	case protoreflect.DoubleKind:
+		if f, ok := seedFloat(v); ok {
+			return protoreflect.ValueOfFloat64(f), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if n, ok := v.(int64); ok {
+			return protoreflect.ValueOfInt32(int32(n)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if n, ok := v.(int64); ok {
+			return protoreflect.ValueOfInt64(n), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if n, ok := v.(int64); ok && n >= 0 {
+			return protoreflect.ValueOfUint32(uint32(n)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if n, ok := v.(int64); ok && n >= 0 {
+			return protoreflect.ValueOfUint64(uint64(n)), nil
+		}
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot use %T %v as %s", v, v, fd.Kind())
+}
+
+func seedFloat(v any) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,221 @@
+package sdk
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/dgraph-io/badger/v4"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Самопроверка при открытии (Options.SelfTestOnOpen). Ошибки конфигурации — не тот ключ шифрования,
+// файловая система только для чтения, кодек, не умеющий в свои же значения, — иначе всплывают на
+// первом пользовательском запросе. Проверка идёт мимо AccessController и журналов, ключи —
+// под "!selftest:":
+//
+//	"!selftest:marker" — постоянная метка: magic + случайные байты + sha256 от них. Пишется при первом
+//	                     открытии и читается при каждом следующем: она уже лежит в SST на диске, и
+//	                     её чтение проверяет расшифровку данных прошлых запусков текущим ключом.
+//	"!selftest:canary" — временный ключ: запись, чтение, скан, удаление. Значение больше
+//	                     ValueThreshold, поэтому уходит в value-log и проходит шифрование на диске
+//	                     сразу, а не после сброса memtable.
+
+var (
+	selfTestPrefix = []byte("!selftest:")
+	selfTestMarker = []byte("!selftest:marker")
+	selfTestCanary = []byte("!selftest:canary")
+	selfTestMagic  = []byte("sdk-selftest-v1:")
+)
+
+// SelfTestError — самопроверка при открытии не прошла на шаге Step.
+type SelfTestError struct {
+	Step string
+	Err  error
+}
+
+func (e *SelfTestError) Error() string {
+	return fmt.Sprintf("store self-test failed at %s: %v", e.Step, e.Err)
+}
+
+func (e *SelfTestError) Unwrap() error {
+	return e.Err
+}
+
+func selfTestFail(step string, err error) error {
+	return &SelfTestError{Step: step, Err: err}
+}
+
+// selfTest выполняет проверки по порядку и возвращает первую ошибку.
+func (s *Store) selfTest(readOnly bool) error {
+	opts := s.db.Opts()
+	if err := s.selfTestMarker(readOnly); err != nil {
+		return err
+	}
+	if !readOnly {
+		size := 64
+		if !opts.InMemory {
+			size = int(opts.ValueThreshold) + 1
+		}
+		if err := s.selfTestCanary(size); err != nil {
+			return err
+		}
+	}
+	if err := s.selfTestScan(readOnly); err != nil {
+		return err
+	}
+	return s.selfTestCodec()
+}
+
+func newSelfTestMarker() ([]byte, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(nonce)
+	out := append(append([]byte(nil), selfTestMagic...), nonce...)
+	return append(out, sum[:8]...), nil
+}
+
+func checkSelfTestMarker(v []byte) error {
+	n := len(selfTestMagic)
+	if len(v) != n+16+8 || !bytes.Equal(v[:n], selfTestMagic) {
+		return fmt.Errorf("marker has unexpected layout (%d bytes)", len(v))
+	}
+	sum := sha256.Sum256(v[n : n+16])
+	if !bytes.Equal(sum[:8], v[n+16:]) {
+		return errors.New("marker checksum mismatch")
+	}
+	return nil
+}
+
+func (s *Store) selfTestMarker(readOnly bool) error {
+	var stored []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(selfTestMarker)
+		if err != nil {
+			return err
+		}
+		stored, err = item.ValueCopy(nil)
+		if err == nil && len(stored) == 0 && item.ValueSize() > 0 {
+			// Badger не возвращает ошибки чтения value-log, а отдаёт пустое значение
+			err = errors.New("value log entry is unreadable")
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, badger.ErrKeyNotFound):
+		if readOnly {
+			return nil
+		}
+		marker, err := newSelfTestMarker()
+		if err != nil {
+			return selfTestFail("marker", err)
+		}
+		if err := s.db.Update(func(txn *badger.Txn) error {
+			return txn.Set(selfTestMarker, marker)
+		}); err != nil {
+			return selfTestFail("marker write (read-only filesystem?)", err)
+		}
+		return nil
+	case err != nil:
+		return selfTestFail("marker read", err)
+	}
+	if err := checkSelfTestMarker(stored); err != nil {
+		return selfTestFail("marker decrypt (wrong encryption key or corrupted data?)", err)
+	}
+	return nil
+}
+
+func (s *Store) selfTestCanary(size int) error {
+	want := make([]byte, size)
+	if _, err := rand.Read(want); err != nil {
+		return selfTestFail("canary", err)
+	}
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(selfTestCanary, want)
+	}); err != nil {
+		return selfTestFail("canary write (read-only filesystem or full disk?)", err)
+	}
+	var got []byte
+	if err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(selfTestCanary)
+		if err != nil {
+			return err
+		}
+		got, err = item.ValueCopy(nil)
+		return err
+	}); err != nil {
+		return selfTestFail("canary read", err)
+	}
+	if !bytes.Equal(got, want) {
+		return selfTestFail("canary round-trip", fmt.Errorf("read %d bytes back, want %d identical bytes", len(got), len(want)))
+	}
+	if err := s.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(selfTestCanary)
+	}); err != nil {
+		return selfTestFail("canary delete", err)
+	}
+	return nil
+}
+
+// selfTestScan проходит префикс самопроверки итератором: после удаления canary остаётся только метка.
+func (s *Store) selfTestScan(readOnly bool) error {
+	var keys []string
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = selfTestPrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, string(it.Item().Key()))
+		}
+		return nil
+	})
+	if err != nil {
+		return selfTestFail("scan", err)
+	}
+	if readOnly {
+		return nil
+	}
+	if len(keys) != 1 || keys[0] != string(selfTestMarker) {
+		return selfTestFail("scan", fmt.Errorf("found keys %q, want only %q", keys, selfTestMarker))
+	}
+	return nil
+}
+
+type selfTestValue struct {
+	Name string `json:"name" msgpack:"name"`
+	N    int64  `json:"n" msgpack:"n"`
+	Data []byte `json:"data" msgpack:"data"`
+}
+
+// selfTestCodec прогоняет через Codec пробное значение: для ProtoCodec — proto-сообщение, иначе структуру.
+func (s *Store) selfTestCodec() error {
+	var in, out any
+	if _, ok := s.Codec.(ProtoCodec); ok {
+		in, out = wrapperspb.String("self-test"), &wrapperspb.StringValue{}
+	} else {
+		in, out = &selfTestValue{Name: "self-test", N: -42, Data: []byte{0, 1, 255}}, &selfTestValue{}
+	}
+	raw, err := s.Codec.Marshal(in)
+	if err != nil {
+		return selfTestFail("codec marshal", err)
+	}
+	if err := s.Codec.Unmarshal(raw, out); err != nil {
+		return selfTestFail("codec unmarshal", err)
+	}
+	if m, ok := out.(*wrapperspb.StringValue); ok {
+		if m.GetValue() != "self-test" {
+			return selfTestFail("codec round-trip", fmt.Errorf("got %q", m.GetValue()))
+		}
+		return nil
+	}
+	if !reflect.DeepEqual(in, out) {
+		return selfTestFail("codec round-trip", fmt.Errorf("got %+v, want %+v", out, in))
+	}
+	return nil
+}
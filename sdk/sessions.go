@@ -0,0 +1,261 @@
+package sdk
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Раскладка ключей сессий (P — SessionOptions.Prefix):
+//
+//	P + "t:" + token               → sessionRecord (JSON)
+//	P + "u:" + userID + "\x00" + token → пусто (индекс сессий пользователя)
+//
+// Обе записи пишутся с одним TTL и продлеваются вместе, поэтому истёкшая сессия исчезает и из индекса.
+
+// Session — сессия пользователя.
+type Session struct {
+	Token     string
+	UserID    string
+	Payload   []byte
+	CreatedAt time.Time
+	// ExpiresAt — срок с учётом последнего продления (точность — секунда).
+	ExpiresAt time.Time
+}
+
+type sessionRecord struct {
+	UserID    string        `json:"user_id"`
+	Payload   []byte        `json:"payload,omitempty"`
+	CreatedAt time.Time     `json:"created_at"`
+	TTL       time.Duration `json:"ttl"`
+}
+
+type SessionOptions struct {
+	// Prefix — корневой префикс ключей сессий, по умолчанию "sess:".
+	Prefix string
+	// TokenBytes — длина случайного токена в байтах (в строке — вдвое больше hex-символов), по умолчанию 32.
+	TokenBytes int
+	// NoSliding — не продлевать TTL при GetSession.
+	NoSliding bool
+}
+
+// SessionStore — сессии поверх Store: создание, чтение со скользящим TTL и отзыв всех сессий пользователя.
+type SessionStore struct {
+	store *Store
+	tx    *Manager
+	opts  SessionOptions
+}
+
+func NewSessionStore(store *Store, opts SessionOptions) *SessionStore {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "sess:"
+	}
+	if opts.TokenBytes <= 0 {
+		opts.TokenBytes = 32
+	}
+	return &SessionStore{store: store, tx: NewTransactionManager(store), opts: opts}
+}
+
+func (ss *SessionStore) tokenKey(token string) []byte {
+	return []byte(ss.opts.Prefix + "t:" + token)
+}
+
+func (ss *SessionStore) userPrefix(userID string) []byte {
+	return []byte(ss.opts.Prefix + "u:" + userID + "\x00")
+}
+
+func (ss *SessionStore) userKey(userID, token string) []byte {
+	return append(ss.userPrefix(userID), token...)
+}
+
+// txPut пишет сессию и запись индекса с ttl от текущего момента.
+func (ss *SessionStore) txPut(txn *badger.Txn, token string, rec sessionRecord) (time.Time, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return time.Time{}, err
+	}
+	entry := ss.store.NewEntry(ss.tokenKey(token), data, rec.TTL)
+	if err := txn.SetEntry(entry); err != nil {
+		return time.Time{}, err
+	}
+	if err := txn.SetEntry(ss.store.NewEntry(ss.userKey(rec.UserID, token), nil, rec.TTL)); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(entry.ExpiresAt), 0), nil
+}
+
+// CreateSession создаёт сессию пользователя userID со сроком ttl и возвращает её со случайным токеном.
+func (ss *SessionStore) CreateSession(ctx context.Context, userID string, payload []byte, ttl time.Duration) (Session, error) {
+	if userID == "" || strings.IndexByte(userID, 0) >= 0 {
+		return Session{}, fmt.Errorf("invalid user id %q", userID)
+	}
+	if ttl <= 0 {
+		return Session{}, errors.New("session ttl must be positive")
+	}
+	raw := make([]byte, ss.opts.TokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return Session{}, err
+	}
+	token := hex.EncodeToString(raw)
+	rec := sessionRecord{UserID: userID, Payload: payload, CreatedAt: ss.store.clock.Now(), TTL: ttl}
+
+	if err := ss.store.writeLimit.wait(ctx); err != nil {
+		return Session{}, err
+	}
+	var expiresAt time.Time
+	err := ss.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		var err error
+		expiresAt, err = ss.txPut(txn, token, rec)
+		return err
+	})
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{Token: token, UserID: userID, Payload: payload, CreatedAt: rec.CreatedAt, ExpiresAt: expiresAt}, nil
+}
+
+// GetSession возвращает сессию по токену (ErrNotFound — нет или истекла) и, если не задан NoSliding,
+// продлевает её на исходный ttl от текущего момента.
+func (ss *SessionStore) GetSession(ctx context.Context, token string) (Session, error) {
+	var out Session
+	err := ss.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		item, err := txn.Get(ss.tokenKey(token))
+		if err != nil {
+			return err
+		}
+		if ss.store.expired(item) {
+			return ErrNotFound
+		}
+		var rec sessionRecord
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &rec)
+		}); err != nil {
+			return fmt.Errorf("session %s: %w", token, err)
+		}
+		out = Session{
+			Token:     token,
+			UserID:    rec.UserID,
+			Payload:   rec.Payload,
+			CreatedAt: rec.CreatedAt,
+			ExpiresAt: time.Unix(int64(item.ExpiresAt()), 0),
+		}
+		if ss.opts.NoSliding {
+			return nil
+		}
+		out.ExpiresAt, err = ss.txPut(txn, token, rec)
+		return err
+	})
+	return out, err
+}
+
+// Revoke удаляет сессию; отсутствующая сессия — не ошибка.
+func (ss *SessionStore) Revoke(ctx context.Context, token string) error {
+	if err := ss.store.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	return ss.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		item, err := txn.Get(ss.tokenKey(token))
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var rec sessionRecord
+		if err := item.Value(func(val []byte) error {
+			return json.Unmarshal(val, &rec)
+		}); err != nil {
+			return fmt.Errorf("session %s: %w", token, err)
+		}
+		if err := txn.Delete(ss.tokenKey(token)); err != nil {
+			return err
+		}
+		return txn.Delete(ss.userKey(rec.UserID, token))
+	})
+}
+
+// userTokens — токены живых сессий пользователя по индексу.
+func (ss *SessionStore) userTokens(txn *badger.Txn, userID string) []string {
+	prefix := ss.userPrefix(userID)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	var out []string
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		if ss.store.expired(it.Item()) {
+			continue
+		}
+		out = append(out, string(it.Item().Key()[len(prefix):]))
+	}
+	return out
+}
+
+// RevokeAllForUser удаляет все сессии пользователя одной транзакцией и возвращает их число.
+func (ss *SessionStore) RevokeAllForUser(ctx context.Context, userID string) (int, error) {
+	if err := ss.store.writeLimit.wait(ctx); err != nil {
+		return 0, err
+	}
+	var n int
+	err := ss.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		tokens := ss.userTokens(txn, userID)
+		for _, token := range tokens {
+			if err := txn.Delete(ss.tokenKey(token)); err != nil {
+				return err
+			}
+			if err := txn.Delete(ss.userKey(userID, token)); err != nil {
+				return err
+			}
+		}
+		n = len(tokens)
+		return nil
+	})
+	return n, err
+}
+
+// ListForUser возвращает токены активных сессий пользователя (например, для списка устройств).
+func (ss *SessionStore) ListForUser(userID string) ([]string, error) {
+	var out []string
+	err := ss.store.db.View(func(txn *badger.Txn) error {
+		out = ss.userTokens(txn, userID)
+		return nil
+	})
+	return out, err
+}
+
+// CountForUser — число активных сессий пользователя.
+func (ss *SessionStore) CountForUser(userID string) (int, error) {
+	tokens, err := ss.ListForUser(userID)
+	return len(tokens), err
+}
+
+// CountActive — число всех активных сессий (проход по ключам без чтения значений).
+func (ss *SessionStore) CountActive() (int, error) {
+	prefix := []byte(ss.opts.Prefix + "t:")
+	n := 0
+	err := ss.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if !ss.store.expired(it.Item()) {
+				n++
+			}
+		}
+		return nil
+	})
+	return n, err
+}
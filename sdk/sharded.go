@@ -0,0 +1,337 @@
+package sdk
+
+import (
+	"bytes"
+	"container/heap"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+const shardRebalanceBatch = 1024
+
+// ShardedStore — хранилище поверх N экземпляров Badger в разных каталогах (дисках).
+// Ключ живёт ровно в одном шарде, выбранном по FNV-1a хешу ключа, поэтому порядок dirs
+// при повторных открытиях должен сохраняться; после изменения числа шардов нужен Rebalance.
+// Транзакции между шардами не поддерживаются: Manager работает с отдельными шардами (Shard(i)).
+type ShardedStore struct {
+	shards []*Store
+}
+
+var (
+	_ KVStore     = (*ShardedStore)(nil)
+	_ ObjectStore = (*ShardedStore)(nil)
+)
+
+// OpenSharded открывает по Store на каждый каталог из dirs с параметрами opts (Dir/ValueDir
+// заменяются каталогом шарда). limit — общий лимит памяти, он делится между шардами поровну.
+func OpenSharded(ctx context.Context, opts Options, dirs []string, limit *MemoryLimit) (*ShardedStore, error) {
+	if len(dirs) == 0 {
+		return nil, errors.New("no shard directories")
+	}
+	var shardLimit *MemoryLimit
+	if limit != nil {
+		l := *limit
+		n := int64(len(dirs))
+		l.BlockCacheSize /= n
+		l.IndexCacheSize /= n
+		l.MemTableSize /= n
+		shardLimit = &l
+	}
+
+	ss := &ShardedStore{shards: make([]*Store, 0, len(dirs))}
+	for i, dir := range dirs {
+		o := opts
+		o.Dir, o.ValueDir = dir, dir
+		if o.StoreName != "" {
+			// имя уникально в процессе: у каждого шарда своё
+			o.StoreName = fmt.Sprintf("%s/shard-%d", opts.StoreName, i)
+		}
+		s, err := Open(ctx, o, shardLimit)
+		if err != nil {
+			_ = ss.Close()
+			return nil, fmt.Errorf("open shard %d (%s): %w", i, dir, err)
+		}
+		ss.shards = append(ss.shards, s)
+	}
+	return ss, nil
+}
+
+// Close закрывает все шарды и возвращает первую ошибку.
+func (ss *ShardedStore) Close() error {
+	var first error
+	for _, s := range ss.shards {
+		if err := s.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// NumShards — число шардов.
+func (ss *ShardedStore) NumShards() int {
+	return len(ss.shards)
+}
+
+// Shard возвращает i-й шард, например для транзакций в пределах одного шарда.
+func (ss *ShardedStore) Shard(i int) *Store {
+	return ss.shards[i]
+}
+
+// ShardFor — номер шарда, в котором хранится key.
+func (ss *ShardedStore) ShardFor(key []byte) int {
+	h := fnv.New64a()
+	_, _ = h.Write(key)
+	return int(h.Sum64() % uint64(len(ss.shards)))
+}
+
+func (ss *ShardedStore) shard(key []byte) *Store {
+	return ss.shards[ss.ShardFor(key)]
+}
+
+func (ss *ShardedStore) Get(key []byte) ([]byte, error) {
+	return ss.shard(key).Get(key)
+}
+
+func (ss *ShardedStore) Set(key, value []byte, ttl time.Duration) error {
+	return ss.shard(key).Set(key, value, ttl)
+}
+
+func (ss *ShardedStore) Delete(key []byte) error {
+	return ss.shard(key).Delete(key)
+}
+
+func (ss *ShardedStore) GetAndDelete(key []byte) ([]byte, error) {
+	return ss.shard(key).GetAndDelete(key)
+}
+
+func (ss *ShardedStore) GetObject(key []byte, v any) error {
+	return ss.shard(key).GetObject(key, v)
+}
+
+func (ss *ShardedStore) SetObject(key []byte, v any, ttl time.Duration) error {
+	return ss.shard(key).SetObject(key, v, ttl)
+}
+
+// RegisterCodec регистрирует кодек префикса во всех шардах.
+func (ss *ShardedStore) RegisterCodec(prefix []byte, codec Codec) {
+	for _, s := range ss.shards {
+		s.RegisterCodec(prefix, codec)
+	}
+}
+
+// shardCursor — итератор одного шарда в слиянии ScanPrefix.
+type shardCursor struct {
+	store *Store
+	txn   *badger.Txn
+	it    *badger.Iterator
+}
+
+type cursorHeap []*shardCursor
+
+func (h cursorHeap) Len() int { return len(h) }
+func (h cursorHeap) Less(i, j int) bool {
+	return bytes.Compare(h[i].it.Item().Key(), h[j].it.Item().Key()) < 0
+}
+func (h cursorHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h *cursorHeap) Push(x any)   { *h = append(*h, x.(*shardCursor)) }
+func (h *cursorHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
+
+// ScanPrefix обходит префикс во всех шардах в общем порядке ключей (слияние N итераторов).
+// Каждый шард читается из своего снимка, общего снимка на все шарды нет.
+func (ss *ShardedStore) ScanPrefix(prefix []byte, limit int, fn func(kv KV) error) error {
+	cursors := make([]*shardCursor, 0, len(ss.shards))
+	defer func() {
+		for _, c := range cursors {
+			c.it.Close()
+			c.txn.Discard()
+		}
+	}()
+
+	h := make(cursorHeap, 0, len(ss.shards))
+	for _, s := range ss.shards {
+		txn := s.db.NewTransaction(false)
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		c := &shardCursor{store: s, txn: txn, it: txn.NewIterator(opts)}
+		cursors = append(cursors, c)
+		c.it.Seek(prefix)
+		if c.it.ValidForPrefix(prefix) {
+			h = append(h, c)
+		}
+	}
+	heap.Init(&h)
+
+	count := 0
+	for h.Len() > 0 {
+		c := h[0]
+		item := c.it.Item()
+		if !c.store.expired(item) {
+			if err := c.store.scanLimit.wait(context.Background()); err != nil {
+				return err
+			}
+			kv := KV{Key: item.KeyCopy(nil)}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			kv.Value = value
+			if err := fn(kv); err != nil {
+				return err
+			}
+			count++
+			if limit > 0 && count >= limit {
+				return nil
+			}
+		}
+		c.it.Next()
+		if c.it.ValidForPrefix(prefix) {
+			heap.Fix(&h, 0)
+		} else {
+			heap.Pop(&h)
+		}
+	}
+	return nil
+}
+
+// FullBackupToDir делает полный бэкап каждого шарда в dir/shard-NN.bak.gz.
+// Возвращает lastTs каждого шарда для последующих инкрементальных бэкапов.
+func (ss *ShardedStore) FullBackupToDir(ctx context.Context, dir string) ([]uint64, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("make backup dir: %w", err)
+	}
+	last := make([]uint64, len(ss.shards))
+	for i, s := range ss.shards {
+		ts, err := s.FullBackupToFile(ctx, shardBackupPath(dir, i))
+		if err != nil {
+			return nil, fmt.Errorf("backup shard %d: %w", i, err)
+		}
+		last[i] = ts
+	}
+	return last, nil
+}
+
+// RestoreFromDir восстанавливает каждый шард из dir/shard-NN.bak.gz.
+// Число шардов должно совпадать с тем, при котором делался бэкап.
+func (ss *ShardedStore) RestoreFromDir(dir string) error {
+	for i, s := range ss.shards {
+		if err := s.RestoreFromFile(shardBackupPath(dir, i)); err != nil {
+			return fmt.Errorf("restore shard %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func shardBackupPath(dir string, shard int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%02d.bak.gz", shard))
+}
+
+// Rebalance переносит ключи, лежащие не в своём шарде (после изменения числа каталогов),
+// в шард по текущему ShardFor, сохраняя TTL и user meta. Возвращает число перенесённых ключей.
+// Запускать без параллельной записи: ключ пишется в новый шард до удаления из старого,
+// и конкурентная запись в старый шард между этими шагами будет потеряна.
+func (ss *ShardedStore) Rebalance(ctx context.Context) (int, error) {
+	moved := 0
+	for i, src := range ss.shards {
+		n, err := ss.rebalanceShard(ctx, i, src)
+		moved += n
+		if err != nil {
+			return moved, fmt.Errorf("rebalance shard %d: %w", i, err)
+		}
+	}
+	return moved, nil
+}
+
+func (ss *ShardedStore) rebalanceShard(ctx context.Context, from int, src *Store) (int, error) {
+	// key хранится отдельно: при коммите Badger дописывает версию в entry.Key
+	type misplaced struct {
+		to    int
+		key   []byte
+		entry *badger.Entry
+	}
+	moved := 0
+	batch := make([]misplaced, 0, shardRebalanceBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		writers := make(map[int]*badger.WriteBatch)
+		defer func() {
+			for _, wb := range writers {
+				wb.Cancel()
+			}
+		}()
+		for _, m := range batch {
+			wb, ok := writers[m.to]
+			if !ok {
+				wb = ss.shards[m.to].db.NewWriteBatch()
+				writers[m.to] = wb
+			}
+			if err := wb.SetEntry(m.entry); err != nil {
+				return err
+			}
+		}
+		for _, wb := range writers {
+			if err := wb.Flush(); err != nil {
+				return err
+			}
+		}
+		del := src.db.NewWriteBatch()
+		defer del.Cancel()
+		for _, m := range batch {
+			if err := del.Delete(m.key); err != nil {
+				return err
+			}
+		}
+		if err := del.Flush(); err != nil {
+			return err
+		}
+		moved += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := src.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			item := it.Item()
+			to := ss.ShardFor(item.Key())
+			if to == from || src.expired(item) {
+				continue
+			}
+			value, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			key := item.KeyCopy(nil)
+			e := badger.NewEntry(append([]byte(nil), key...), value).WithMeta(item.UserMeta())
+			e.ExpiresAt = item.ExpiresAt()
+			batch = append(batch, misplaced{to: to, key: key, entry: e})
+			if len(batch) >= shardRebalanceBatch {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return moved, err
+}
@@ -0,0 +1,327 @@
+package sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Раскладка ключей множества (N — имя множества):
+//
+//	"zs:" + N + "\x00s" + score + member → пусто   (индекс по счёту, упорядочен по score, затем member)
+//	"zs:" + N + "\x00m" + member         → score  (счёт участника для обновления и ZScore)
+//	"zs:" + N + "\x00n"                  → число участников (uint64)
+//
+// score — float64 в порядко-сохраняющей кодировке (8 байт big-endian), поэтому диапазон счётов —
+// это диапазон ключей.
+
+// ZMember — участник множества со счётом.
+type ZMember struct {
+	Member string
+	Score  float64
+}
+
+// SortedSet — упорядоченное по счёту множество строк (аналог Redis ZSET) в Badger.
+// Все изменения выполняются транзакциями с ретраями конфликтов; Tx*-методы позволяют менять
+// множество в одной транзакции с основной записью (например, профилем и его местом в рейтинге).
+type SortedSet struct {
+	store *Store
+	tx    *Manager
+	base  string
+}
+
+func NewSortedSet(store *Store, name string) (*SortedSet, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if name == "" || strings.IndexByte(name, 0) >= 0 {
+		return nil, fmt.Errorf("invalid sorted set name %q", name)
+	}
+	return &SortedSet{store: store, tx: NewTransactionManager(store), base: "zs:" + name + "\x00"}, nil
+}
+
+func encodeScore(score float64) []byte {
+	if score == 0 {
+		score = 0 // -0 и +0 — один ключ
+	}
+	bits := math.Float64bits(score)
+	if bits&(1<<63) != 0 {
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(nil, bits)
+}
+
+func decodeScore(b []byte) float64 {
+	bits := binary.BigEndian.Uint64(b)
+	if bits&(1<<63) != 0 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
+}
+
+func (z *SortedSet) scorePrefix() []byte {
+	return []byte(z.base + "s")
+}
+
+func (z *SortedSet) scoreKey(score float64, member string) []byte {
+	k := append(z.scorePrefix(), encodeScore(score)...)
+	return append(k, member...)
+}
+
+func (z *SortedSet) memberKey(member string) []byte {
+	return []byte(z.base + "m" + member)
+}
+
+func (z *SortedSet) countKey() []byte {
+	return []byte(z.base + "n")
+}
+
+func (z *SortedSet) txScore(txn *badger.Txn, member string) (float64, bool, error) {
+	item, err := txn.Get(z.memberKey(member))
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	var score float64
+	err = item.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return fmt.Errorf("sorted set: corrupted score of %q", member)
+		}
+		score = decodeScore(val)
+		return nil
+	})
+	return score, err == nil, err
+}
+
+func (z *SortedSet) txCount(txn *badger.Txn) (uint64, error) {
+	item, err := txn.Get(z.countKey())
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var n uint64
+	err = item.Value(func(val []byte) error {
+		if len(val) != 8 {
+			return errors.New("sorted set: corrupted counter")
+		}
+		n = binary.BigEndian.Uint64(val)
+		return nil
+	})
+	return n, err
+}
+
+func (z *SortedSet) txAddCount(txn *badger.Txn, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	n, err := z.txCount(txn)
+	if err != nil {
+		return err
+	}
+	n = uint64(int64(n) + int64(delta))
+	if n == 0 {
+		return txn.Delete(z.countKey())
+	}
+	return txn.Set(z.countKey(), binary.BigEndian.AppendUint64(nil, n))
+}
+
+// TxZAdd добавляет участника или меняет его счёт внутри транзакции. Возвращает true, если участник новый.
+func (z *SortedSet) TxZAdd(txn *badger.Txn, member string, score float64) (bool, error) {
+	if math.IsNaN(score) {
+		return false, errors.New("sorted set: NaN score")
+	}
+	old, exists, err := z.txScore(txn, member)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		if old == score {
+			return false, nil
+		}
+		if err := txn.Delete(z.scoreKey(old, member)); err != nil {
+			return false, err
+		}
+	}
+	if err := txn.Set(z.scoreKey(score, member), nil); err != nil {
+		return false, err
+	}
+	if err := txn.Set(z.memberKey(member), encodeScore(score)); err != nil {
+		return false, err
+	}
+	if exists {
+		return false, nil
+	}
+	return true, z.txAddCount(txn, 1)
+}
+
+// TxZRem удаляет участника внутри транзакции. Возвращает true, если участник был.
+func (z *SortedSet) TxZRem(txn *badger.Txn, member string) (bool, error) {
+	score, exists, err := z.txScore(txn, member)
+	if err != nil || !exists {
+		return false, err
+	}
+	if err := txn.Delete(z.scoreKey(score, member)); err != nil {
+		return false, err
+	}
+	if err := txn.Delete(z.memberKey(member)); err != nil {
+		return false, err
+	}
+	return true, z.txAddCount(txn, -1)
+}
+
+// ZAdd добавляет участника или меняет его счёт.
+func (z *SortedSet) ZAdd(ctx context.Context, member string, score float64) (bool, error) {
+	var added bool
+	err := z.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		var err error
+		added, err = z.TxZAdd(txn, member, score)
+		return err
+	})
+	return added, err
+}
+
+// ZRem удаляет участника.
+func (z *SortedSet) ZRem(ctx context.Context, member string) (bool, error) {
+	var removed bool
+	err := z.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		var err error
+		removed, err = z.TxZRem(txn, member)
+		return err
+	})
+	return removed, err
+}
+
+// ZScore возвращает счёт участника или ErrNotFound.
+func (z *SortedSet) ZScore(member string) (float64, error) {
+	var score float64
+	err := z.store.db.View(func(txn *badger.Txn) error {
+		s, ok, err := z.txScore(txn, member)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrNotFound
+		}
+		score = s
+		return nil
+	})
+	return score, err
+}
+
+// ZCard возвращает число участников.
+func (z *SortedSet) ZCard() (int, error) {
+	var n uint64
+	err := z.store.db.View(func(txn *badger.Txn) error {
+		var err error
+		n, err = z.txCount(txn)
+		return err
+	})
+	return int(n), err
+}
+
+// txRangeByScore обходит участников со счётом в [min, max] по возрастанию (reverse — по убыванию).
+func (z *SortedSet) txRangeByScore(txn *badger.Txn, min, max float64, reverse bool, fn func(m ZMember) (bool, error)) error {
+	prefix := z.scorePrefix()
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.PrefetchValues = false
+	opts.Reverse = reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var start []byte
+	if reverse {
+		// при обратном обходе Seek находит наибольший ключ <= start: добиваем 0xFF вместо member
+		// (в UTF-8 такого байта нет, поэтому все участники со счётом max остаются левее)
+		start = append(append(prefix, encodeScore(max)...), 0xFF)
+	} else {
+		start = append(prefix, encodeScore(min)...)
+	}
+	for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().Key()[len(prefix):]
+		if len(key) < 8 {
+			continue
+		}
+		score := decodeScore(key[:8])
+		if score < min || score > max {
+			return nil
+		}
+		more, err := fn(ZMember{Member: string(key[8:]), Score: score})
+		if err != nil || !more {
+			return err
+		}
+	}
+	return nil
+}
+
+// ZRangeByScore возвращает до limit (<= 0 — без ограничения) участников со счётом в [min, max]
+// по возрастанию счёта. Для «топа» используйте ZRevRangeByScore.
+func (z *SortedSet) ZRangeByScore(min, max float64, limit int) ([]ZMember, error) {
+	return z.rangeByScore(min, max, limit, false)
+}
+
+// ZRevRangeByScore — ZRangeByScore по убыванию счёта.
+func (z *SortedSet) ZRevRangeByScore(min, max float64, limit int) ([]ZMember, error) {
+	return z.rangeByScore(min, max, limit, true)
+}
+
+func (z *SortedSet) rangeByScore(min, max float64, limit int, reverse bool) ([]ZMember, error) {
+	var out []ZMember
+	err := z.store.db.View(func(txn *badger.Txn) error {
+		return z.txRangeByScore(txn, min, max, reverse, func(m ZMember) (bool, error) {
+			out = append(out, m)
+			return limit <= 0 || len(out) < limit, nil
+		})
+	})
+	return out, err
+}
+
+// ZRemRangeByScore удаляет участников со счётом в [min, max] и возвращает их число.
+// Большие диапазоны удаляются несколькими транзакциями, поэтому операция в целом не атомарна.
+func (z *SortedSet) ZRemRangeByScore(ctx context.Context, min, max float64) (int, error) {
+	const chunk = 1000
+	total := 0
+	for {
+		removed := 0
+		err := z.tx.ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+			var members []ZMember
+			err := z.txRangeByScore(txn, min, max, false, func(m ZMember) (bool, error) {
+				members = append(members, m)
+				return len(members) < chunk, nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, m := range members {
+				if err := txn.Delete(z.scoreKey(m.Score, m.Member)); err != nil {
+					return err
+				}
+				if err := txn.Delete(z.memberKey(m.Member)); err != nil {
+					return err
+				}
+			}
+			removed = len(members)
+			return z.txAddCount(txn, -removed)
+		})
+		if err != nil {
+			return total, err
+		}
+		total += removed
+		if removed < chunk {
+			return total, nil
+		}
+	}
+}
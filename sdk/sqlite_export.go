@@ -0,0 +1,443 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Зеркало для аналитиков: ExportSQLite выгружает выбранные префиксы в файл SQLite — по таблице на
+// префикс, строка на ключ, колонки — поля JSON-значения по путям в стиле ScanExtract. Аналитик
+// открывает файл любым SQL-инструментом и не трогает живое хранилище и не разбирается в Badger.
+// Все таблицы читаются из одного снимка и пишутся во временный файл рядом с целевым, который затем
+// атомарно подменяет целевой: читатели видят либо прошлую выгрузку, либо новую целиком.
+// RunSQLiteExport повторяет выгрузку по расписанию.
+//
+// SDK не тянет драйвер SQLite: приложение импортирует его само (modernc.org/sqlite — имя "sqlite",
+// github.com/mattn/go-sqlite3 — "sqlite3") и передаёт имя в SQLiteExportOptions.Driver.
+
+// Типы колонок SQLColumn.Type.
+const (
+	SQLText    = "TEXT"
+	SQLInteger = "INTEGER"
+	SQLReal    = "REAL"
+	// SQLJSON — значение по пути целиком, JSON-текстом (объекты, массивы).
+	SQLJSON = "JSON"
+)
+
+const defaultSQLiteBatch = 5000
+
+// sqliteMetaTable — служебная таблица выгрузки: когда она сделана и сколько строк в таблицах.
+const sqliteMetaTable = "_export"
+
+var sqlIdent = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLColumn — колонка таблицы: значение по Path (см. ScanExtract), приведённое к Type (по умолчанию
+// TEXT). Нет значения по пути или оно не приводится к типу — NULL.
+type SQLColumn struct {
+	Name  string `json:"name" yaml:"name"`
+	Path  string `json:"path" yaml:"path"`
+	Type  string `json:"type,omitempty" yaml:"type,omitempty"`
+	Index bool   `json:"index,omitempty" yaml:"index,omitempty"`
+}
+
+// SQLTable — выгрузка префикса в таблицу Name. Кроме Columns в таблице всегда есть key (ключ без
+// префикса, PRIMARY KEY) и expires_at (unix-секунды, NULL — бессрочно); RawValue добавляет value
+// с исходным значением.
+type SQLTable struct {
+	Name     string      `json:"name" yaml:"name"`
+	Prefix   string      `json:"prefix" yaml:"prefix"`
+	Columns  []SQLColumn `json:"columns" yaml:"columns"`
+	RawValue bool        `json:"raw_value,omitempty" yaml:"raw_value,omitempty"`
+}
+
+type SQLiteExportOptions struct {
+	// Driver — имя зарегистрированного драйвера SQLite в database/sql.
+	Driver string
+	// Path — файл выгрузки; каталог должен существовать.
+	Path   string
+	Tables []SQLTable
+	// BatchSize — строк в одной транзакции SQLite; <= 0 — 5000.
+	BatchSize int
+}
+
+// SQLiteExportResult — итог выгрузки.
+type SQLiteExportResult struct {
+	Path       string
+	ExportedAt time.Time
+	// Rows — строк по таблицам.
+	Rows     map[string]int64
+	Bytes    int64
+	Duration time.Duration
+}
+
+// ExportSQLite выгружает opts.Tables в opts.Path. Значения разбираются как JSON (после снятия
+// конверта VersionedObjects); неразбираемое значение прерывает выгрузку с *DecodeError или уходит
+// в Options.QuarantineDecodeErrors. Ключи служебной области и истёкшие не выгружаются, чтение ждёт
+// ScanRateLimit. Операция пишется в журнал аудита (op "export_sqlite") и видна в Jobs (этап —
+// таблица, прогресс — строки).
+func (s *Store) ExportSQLite(ctx context.Context, opts SQLiteExportOptions) (SQLiteExportResult, error) {
+	res := SQLiteExportResult{Path: opts.Path}
+	if err := validateSQLiteExport(opts); err != nil {
+		return res, err
+	}
+	for _, t := range opts.Tables {
+		if err := s.checkAccess(ctx, AccessScan, []byte(t.Prefix)); err != nil {
+			return res, err
+		}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultSQLiteBatch
+	}
+	err := s.RunAudited(ctx, "export_sqlite", opts.Path, func() (err error) {
+		ctx, job := s.StartJob(ctx, JobSQLExport, opts.Path)
+		defer func() { job.Finish(err) }()
+		return s.exportSQLite(ctx, opts, job, &res)
+	})
+	return res, err
+}
+
+func validateSQLiteExport(opts SQLiteExportOptions) error {
+	if opts.Driver == "" {
+		return errors.New("sqlite export: driver name is required")
+	}
+	if opts.Path == "" {
+		return errors.New("sqlite export: path is required")
+	}
+	if len(opts.Tables) == 0 {
+		return errors.New("sqlite export: no tables")
+	}
+	names := make(map[string]bool)
+	for _, t := range opts.Tables {
+		if !sqlIdent.MatchString(t.Name) || strings.EqualFold(t.Name, sqliteMetaTable) {
+			return fmt.Errorf("sqlite export: invalid table name %q", t.Name)
+		}
+		if names[strings.ToLower(t.Name)] {
+			return fmt.Errorf("sqlite export: duplicate table %q", t.Name)
+		}
+		names[strings.ToLower(t.Name)] = true
+		if isSystemKey([]byte(t.Prefix)) {
+			return fmt.Errorf("sqlite export: table %s: %w: %q", t.Name, ErrSystemKey, t.Prefix)
+		}
+		cols := map[string]bool{"key": true, "expires_at": true, "value": t.RawValue}
+		for _, c := range t.Columns {
+			if !sqlIdent.MatchString(c.Name) || cols[strings.ToLower(c.Name)] {
+				return fmt.Errorf("sqlite export: table %s: invalid or duplicate column %q", t.Name, c.Name)
+			}
+			cols[strings.ToLower(c.Name)] = true
+			switch c.Type {
+			case "", SQLText, SQLInteger, SQLReal, SQLJSON:
+			default:
+				return fmt.Errorf("sqlite export: table %s column %s: unknown type %q", t.Name, c.Name, c.Type)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) exportSQLite(ctx context.Context, opts SQLiteExportOptions, job *Job, res *SQLiteExportResult) error {
+	start := s.clock.Now()
+	tmp, err := os.CreateTemp(filepath.Dir(opts.Path), "."+filepath.Base(opts.Path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	_ = tmp.Close()
+	ok := false
+	defer func() {
+		if !ok {
+			_ = os.Remove(tmpPath)
+			_ = os.Remove(tmpPath + "-journal")
+		}
+	}()
+
+	db, err := sql.Open(opts.Driver, tmpPath)
+	if err != nil {
+		return fmt.Errorf("sqlite export: open %s: %w", tmpPath, err)
+	}
+	defer db.Close()
+	// файл ещё никому не виден — журнал и fsync на каждую транзакцию не нужны
+	for _, pragma := range []string{"PRAGMA journal_mode=OFF", "PRAGMA synchronous=OFF"} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			return fmt.Errorf("sqlite export: %s: %w", pragma, err)
+		}
+	}
+	for _, t := range opts.Tables {
+		if _, err := db.ExecContext(ctx, createTableSQL(t)); err != nil {
+			return fmt.Errorf("sqlite export: create table %s: %w", t.Name, err)
+		}
+	}
+
+	ctx, finish := s.trackRead(ctx, "export_sqlite")
+	defer finish()
+	res.Rows = make(map[string]int64, len(opts.Tables))
+	err = s.db.View(func(txn *badger.Txn) error {
+		for _, t := range opts.Tables {
+			job.SetStage(t.Name)
+			n, err := s.exportSQLiteTable(ctx, txn, db, t, opts.BatchSize, job)
+			if err != nil {
+				return fmt.Errorf("sqlite export: table %s: %w", t.Name, err)
+			}
+			res.Rows[t.Name] = n
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	job.SetStage("finalize")
+	for _, t := range opts.Tables {
+		for _, c := range t.Columns {
+			if !c.Index {
+				continue
+			}
+			q := fmt.Sprintf(`CREATE INDEX "%s_%s" ON "%s" ("%s")`, t.Name, c.Name, t.Name, c.Name)
+			if _, err := db.ExecContext(ctx, q); err != nil {
+				return fmt.Errorf("sqlite export: index %s.%s: %w", t.Name, c.Name, err)
+			}
+		}
+	}
+	meta := `CREATE TABLE "` + sqliteMetaTable + `" (exported_at TEXT NOT NULL, tables TEXT NOT NULL)`
+	if _, err := db.ExecContext(ctx, meta); err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+	tables, _ := json.Marshal(res.Rows)
+	if _, err := db.ExecContext(ctx, `INSERT INTO "`+sqliteMetaTable+`" VALUES (?, ?)`, start.UTC().Format(time.RFC3339), string(tables)); err != nil {
+		return fmt.Errorf("sqlite export: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("sqlite export: close: %w", err)
+	}
+	if err := os.Rename(tmpPath, opts.Path); err != nil {
+		return err
+	}
+	ok = true
+	if fi, err := os.Stat(opts.Path); err == nil {
+		res.Bytes = fi.Size()
+	}
+	res.ExportedAt = start
+	res.Duration = s.clock.Now().Sub(start)
+	return nil
+}
+
+func createTableSQL(t SQLTable) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `CREATE TABLE "%s" (key TEXT PRIMARY KEY, expires_at INTEGER`, t.Name)
+	for _, c := range t.Columns {
+		typ := c.Type
+		switch typ {
+		case "":
+			typ = SQLText
+		case SQLJSON:
+			typ = SQLText
+		}
+		fmt.Fprintf(&b, `, "%s" %s`, c.Name, typ)
+	}
+	if t.RawValue {
+		b.WriteString(", value BLOB")
+	}
+	b.WriteString(")")
+	return b.String()
+}
+
+// exportSQLiteTable пишет живые ключи префикса t из снимка txn пачками по batch строк.
+func (s *Store) exportSQLiteTable(ctx context.Context, txn *badger.Txn, db *sql.DB, t SQLTable, batch int, job *Job) (int64, error) {
+	paths := make([][]string, len(t.Columns))
+	for i, c := range t.Columns {
+		paths[i] = splitExtractPath(c.Path)
+	}
+	ncol := 2 + len(t.Columns)
+	if t.RawValue {
+		ncol++
+	}
+	insert := fmt.Sprintf(`INSERT INTO "%s" VALUES (%s)`, t.Name, strings.TrimSuffix(strings.Repeat("?, ", ncol), ", "))
+
+	var (
+		tx   *sql.Tx
+		stmt *sql.Stmt
+		rows int64
+		cur  int
+	)
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx, stmt, cur = nil, nil, 0
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	prefix := []byte(t.Prefix)
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		if s.expired(item) || hiddenSystemKey(prefix, item.Key()) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return rows, context.Cause(ctx)
+		}
+		if err := s.scanLimit.wait(ctx); err != nil {
+			return rows, err
+		}
+		raw, err := item.ValueCopy(nil)
+		if err != nil {
+			return rows, err
+		}
+		raw = s.unwrapValue(raw)
+		args := make([]any, 0, ncol)
+		args = append(args, string(bytes.TrimPrefix(item.Key(), prefix)), nil)
+		if exp := item.ExpiresAt(); exp > 0 {
+			args[1] = int64(exp)
+		}
+		var doc any
+		if len(t.Columns) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			if err := dec.Decode(&doc); err != nil {
+				if err := s.quarantineOr(decodeError(item.KeyCopy(nil), raw, JSONCodec{}, err)); err != nil {
+					return rows, err
+				}
+				continue
+			}
+		}
+		for i, c := range t.Columns {
+			v, found := walkJSON(doc, paths[i])
+			if !found {
+				args = append(args, nil)
+				continue
+			}
+			args = append(args, sqlColumnValue(c.Type, v))
+		}
+		if t.RawValue {
+			args = append(args, raw)
+		}
+
+		if tx == nil {
+			if tx, err = db.BeginTx(ctx, nil); err != nil {
+				return rows, err
+			}
+			if stmt, err = tx.PrepareContext(ctx, insert); err != nil {
+				return rows, err
+			}
+		}
+		if _, err := stmt.ExecContext(ctx, args...); err != nil {
+			return rows, fmt.Errorf("key %q: %w", item.Key(), err)
+		}
+		rows++
+		job.AddDone(1)
+		if cur++; cur == batch {
+			if err := commit(); err != nil {
+				return rows, err
+			}
+		}
+	}
+	return rows, commit()
+}
+
+// sqlColumnValue приводит значение JSON (как у walkJSON) к типу колонки; неприводимое — NULL.
+func sqlColumnValue(typ string, v any) any {
+	if v == nil {
+		return nil
+	}
+	switch typ {
+	case SQLJSON:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	case SQLInteger:
+		switch x := v.(type) {
+		case json.Number:
+			if n, err := x.Int64(); err == nil {
+				return n
+			}
+			if f, err := x.Float64(); err == nil && f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				return int64(f)
+			}
+		case string:
+			if n, err := strconv.ParseInt(x, 10, 64); err == nil {
+				return n
+			}
+		case bool:
+			if x {
+				return int64(1)
+			}
+			return int64(0)
+		case int:
+			return int64(x)
+		}
+		return nil
+	case SQLReal:
+		switch x := v.(type) {
+		case json.Number:
+			if f, err := x.Float64(); err == nil {
+				return f
+			}
+		case string:
+			if f, err := strconv.ParseFloat(x, 64); err == nil {
+				return f
+			}
+		case int:
+			return float64(x)
+		}
+		return nil
+	default:
+		switch x := v.(type) {
+		case string:
+			return x
+		case json.Number:
+			return x.String()
+		case bool:
+			return strconv.FormatBool(x)
+		case int:
+			return strconv.Itoa(x)
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		return string(data)
+	}
+}
+
+// RunSQLiteExport вызывает ExportSQLite каждые interval (по часам Store) до отмены ctx.
+// onRound получает итог каждой выгрузки и может быть nil; ошибка выгрузки не останавливает цикл,
+// файл прошлой выгрузки при этом остаётся на месте.
+func (s *Store) RunSQLiteExport(ctx context.Context, opts SQLiteExportOptions, interval time.Duration, onRound func(SQLiteExportResult, error)) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := s.ExportSQLite(ctx, opts)
+		if onRound != nil && ctx.Err() == nil {
+			onRound(res, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -2,7 +2,8 @@ package sdk
 
 import (
 	"context"
-	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/dgraph-io/badger/v4"
@@ -11,16 +12,91 @@ import (
 var ErrNotFound = badger.ErrKeyNotFound
 
 type Store struct {
-	db *badger.DB
+	db       *badger.DB
+	name     string
+	nameHeld atomic.Bool // имя занято этим Store; см. releaseName
 	Codec
-	stopGC chan struct{}
+	stopGC     chan struct{}
+	quarantine func(err *DecodeError)
+	clock      Clock
+	guard      *ProtoSchemaGuard
+	versioned  bool
+	access     AccessController
+	ttlJitter  float64
+
+	onTx             func(OpTrace)
+	onSlowOp         func(OpTrace)
+	slowOpThreshold  time.Duration
+	recorder         atomic.Pointer[opRecorder]
+	readAmp          atomic.Pointer[readAmpSampler]
+	accessSample     atomic.Pointer[accessSampler]
+	lastHeatmap      atomic.Pointer[Heatmap]
+	memWatch         atomic.Pointer[memoryWatcher]
+	pressure         pressureCache
+	readGuard        *readTxnGuard
+	keyValidator     *keyValidator
+	fallbacks        fallbackReads
+	latency          opLatencies
+	gc               gcState
+	jobs             *JobRegistry
+	compactions      compactionPauser
+	lastBackupVerify atomic.Pointer[BackupVerifyReport]
+	badgerEvents     *badgerEventLogger
+
+	defaultActor string
+	writeLimit   *throttle
+	scanLimit    *throttle
+
+	codecsMu sync.RWMutex
+	codecs   []prefixCodec // по убыванию длины префикса
+
+	syncMu     sync.RWMutex
+	syncMerges []prefixSyncMerge // по убыванию длины префикса
+
+	merges mergeRegistry
+
+	expiryMu       sync.Mutex
+	expiryHooks    []expiryHook
+	expiryOnce     sync.Once
+	expiryInterval time.Duration
+
+	digestsMu sync.Mutex
+	digests   map[digestCacheKey]*cachedDigest
+
+	// bg живёт до Close: фоновые подписки Store (например, PrefixDigest) останавливаются по нему
+	bg       context.Context
+	bgCancel context.CancelFunc
 }
 
 func (s *Store) DB() *badger.DB {
 	return s.db
 }
 
+// Clock возвращает часы хранилища (Options.Clock или реальное время).
+func (s *Store) Clock() Clock {
+	return s.clock
+}
+
+// NewEntry создаёт запись Badger с TTL, отсчитанным от часов хранилища (ttl <= 0 — без TTL).
+// Используйте её вместо badger.NewEntry(...).WithTTL для записей в обход Set (WriteBatch, txn.SetEntry).
+func (s *Store) NewEntry(key, value []byte, ttl time.Duration) *badger.Entry {
+	e := badger.NewEntry(key, value)
+	if ttl > 0 {
+		e.ExpiresAt = uint64(s.clock.Now().Add(ttl).Unix())
+	}
+	return e
+}
+
+// expired — истекла ли запись по часам хранилища.
+func (s *Store) expired(item *badger.Item) bool {
+	exp := item.ExpiresAt()
+	return exp > 0 && exp <= uint64(s.clock.Now().Unix())
+}
+
 func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error) {
+	if err := opts.validateTuning(); err != nil {
+		return nil, err
+	}
 	bo := badger.DefaultOptions(opts.Dir)
 
 	// Уровень логов
@@ -88,6 +164,13 @@ func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error)
 		}
 	}
 
+	if opts.ValueThreshold == 0 && opts.AutoValueThreshold && !opts.InMemory {
+		t, err := loadValueThreshold(opts.Dir)
+		if err != nil {
+			return nil, err
+		}
+		opts.ValueThreshold = t
+	}
 	if opts.ValueThreshold > 0 {
 		bo = bo.WithValueThreshold(opts.ValueThreshold)
 	}
@@ -102,9 +185,27 @@ func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error)
 		bo = bo.WithNumCompactors(opts.NumCompactors)
 	}
 
+	if c, ok, _ := opts.badgerCompression(); ok {
+		bo = bo.WithCompression(c)
+	}
 	if opts.ZSTDCompressionLevel != 0 {
 		bo = bo.WithZSTDCompressionLevel(opts.ZSTDCompressionLevel)
 	}
+	if opts.BloomFalsePositive > 0 {
+		bo = bo.WithBloomFalsePositive(opts.BloomFalsePositive)
+	}
+	if opts.NumLevelZeroTables > 0 {
+		bo = bo.WithNumLevelZeroTables(opts.NumLevelZeroTables)
+	}
+	if opts.NumLevelZeroTablesStall > 0 {
+		bo = bo.WithNumLevelZeroTablesStall(opts.NumLevelZeroTablesStall)
+	}
+	if opts.LevelSizeMultiplier > 0 {
+		bo = bo.WithLevelSizeMultiplier(opts.LevelSizeMultiplier)
+	}
+	if opts.TableSizeMultiplier > 0 {
+		bo.TableSizeMultiplier = opts.TableSizeMultiplier
+	}
 	if opts.DetectConflicts {
 		bo = bo.WithDetectConflicts(opts.DetectConflicts)
 	}
@@ -112,8 +213,15 @@ func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error)
 		bo = bo.WithEncryptionKey(opts.EncryptionKey)
 	}
 
+	events := newBadgerEventLogger(bo.Logger, bo.MaxLevels)
+	bo = bo.WithLogger(events)
+
+	if err := claimStoreName(opts.StoreName); err != nil {
+		return nil, err
+	}
 	db, err := badger.Open(bo)
 	if err != nil {
+		releaseStoreName(opts.StoreName)
 		return nil, err
 	}
 
@@ -121,19 +229,72 @@ func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error)
 	if codec == nil {
 		codec = JSONCodec{}
 	}
+	clock := opts.Clock
+	if clock == nil {
+		clock = NewRealClock()
+	}
 
 	s := &Store{
-		db:     db,
-		Codec:  codec,
-		stopGC: make(chan struct{}),
+		db:         db,
+		name:       opts.StoreName,
+		Codec:      codec,
+		stopGC:     make(chan struct{}),
+		quarantine: opts.QuarantineDecodeErrors,
+		clock:      clock,
+		guard:      opts.ProtoSchemaGuard,
+		versioned:  opts.VersionedObjects,
+		access:     opts.AccessController,
+		ttlJitter:  opts.TTLJitter,
+		jobs:       NewJobRegistry(clock),
+
+		badgerEvents: events,
+
+		onTx:            opts.OnTx,
+		onSlowOp:        opts.OnSlowOp,
+		slowOpThreshold: opts.SlowOpThreshold,
+
+		defaultActor: opts.AuditActor,
+		writeLimit:   newThrottle(opts.WriteRateLimit),
+		scanLimit:    newThrottle(opts.ScanRateLimit),
+	}
+	if opts.KeyValidator != nil {
+		s.keyValidator = &keyValidator{opts: *opts.KeyValidator}
+	}
+	if s.onSlowOp == nil {
+		s.onSlowOp = logSlowOp
+	}
+	s.nameHeld.Store(opts.StoreName != "")
+	s.bg, s.bgCancel = context.WithCancel(context.Background())
+	s.expiryInterval = opts.ExpiryCheckInterval
+	if s.expiryInterval <= 0 {
+		s.expiryInterval = time.Second
+	}
+
+	s.publishVars()
+
+	if opts.SelfTestOnOpen {
+		if err := s.selfTest(opts.ReadOnly); err != nil {
+			_ = s.Close()
+			return nil, err
+		}
 	}
 
 	if opts.GCInterval > 0 && !opts.InMemory && !opts.ReadOnly {
 		go func() {
-			s.runGC(opts.GCInterval)
+			s.runGC(opts.GCInterval, opts.GCDiscardRatio, opts.GCAdaptive)
 		}()
 	}
 
+	if opts.MemoryPressure != nil {
+		s.startMemoryPressure(*opts.MemoryPressure)
+	}
+	if opts.ReadTxnGuard != nil {
+		s.startReadTxnGuard(*opts.ReadTxnGuard)
+	}
+	if opts.MetricsTextfile != nil {
+		s.startMetricsTextfile(*opts.MetricsTextfile)
+	}
+
 	go func() {
 		s.runMonitoring(ctx)
 	}()
@@ -142,27 +303,77 @@ func Open(ctx context.Context, opts Options, limit *MemoryLimit) (*Store, error)
 }
 
 func (s *Store) Close() error {
+	s.releaseName()
+	s.bgCancel()
+	s.resumeCompactions(CompactionResumeClose)
+	s.stopMergeOperators(time.Time{})
 	close(s.stopGC)
 	return s.db.Close()
 }
 
 func (s *Store) Set(key, value []byte, ttl time.Duration) error {
+	return s.SetContext(context.Background(), key, value, ttl)
+}
+
+// SetContext — Set, ожидание WriteRateLimit в котором прерывается отменой ctx.
+// Principal для AccessController берётся из ctx (WithPrincipal).
+func (s *Store) SetContext(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	start := s.clock.Now()
+	err := s.set(ctx, key, value, ttl)
+	s.latency.set.observe(s.clock.Now().Sub(start))
+	s.record(RecordSet, key, value, 0, ttl, start, err)
+	return s.traceOp(ctx, "set", key, start, 1, err)
+}
+
+func (s *Store) set(ctx context.Context, key, value []byte, ttl time.Duration) error {
+	if err := s.checkAccess(ctx, AccessWrite, key); err != nil {
+		return err
+	}
+	if err := s.validateKey(ctx, key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
+	s.noteWrite(key)
 	return s.db.Update(func(txn *badger.Txn) error {
-		e := badger.NewEntry(key, value)
-		if ttl > 0 {
-			e = e.WithTTL(ttl)
-		}
-		return txn.SetEntry(e)
+		return txn.SetEntry(s.NewEntry(key, value, s.jitterTTL(ctx, ttl)))
 	})
 }
 
 func (s *Store) Get(key []byte) ([]byte, error) {
+	return s.GetContext(context.Background(), key)
+}
+
+// GetContext — Get от имени principal из ctx (WithPrincipal).
+func (s *Store) GetContext(ctx context.Context, key []byte) ([]byte, error) {
+	start := s.clock.Now()
+	var out []byte
+	var err error
+	if s.readAmp.Load() != nil {
+		out, err = s.getSampled(ctx, key)
+	} else {
+		out, err = s.get(ctx, key)
+	}
+	s.latency.get.observe(s.clock.Now().Sub(start))
+	s.record(RecordGet, key, out, 0, 0, start, err)
+	return out, s.traceOp(ctx, "get", key, start, 1, err)
+}
+
+func (s *Store) get(ctx context.Context, key []byte) ([]byte, error) {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return nil, err
+	}
 	var out []byte
 	err := s.db.View(func(txn *badger.Txn) error {
 		item, err := txn.Get(key)
 		if err != nil {
 			return err
 		}
+		if s.expired(item) {
+			return ErrNotFound
+		}
+		s.noteRead(key)
 		return item.Value(func(val []byte) error {
 			out = append(out[:0], val...)
 			return nil
@@ -171,18 +382,169 @@ func (s *Store) Get(key []byte) ([]byte, error) {
 	return out, err
 }
 
+// MultiGet читает keys из одного снимка (одна транзакция View вместо View на ключ) и возвращает
+// значения по string(key). Отсутствующих и истёкших ключей в результате нет — их перечисляет
+// MissingKeys. Доступ проверяется до чтения для каждого ключа: запрет на любой из них — ошибка всего
+// вызова.
+func (s *Store) MultiGet(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	start := s.clock.Now()
+	out, err := s.multiGet(ctx, keys)
+	if s.recorder.Load() != nil {
+		for _, k := range keys {
+			v, ok := out[string(k)]
+			if ok {
+				s.record(RecordGet, k, v, 0, 0, start, nil)
+			} else if err == nil {
+				s.record(RecordGet, k, nil, 0, 0, start, ErrNotFound)
+			}
+		}
+	}
+	return out, s.traceOp(ctx, "multi_get", nil, start, 1, err)
+}
+
+func (s *Store) multiGet(ctx context.Context, keys [][]byte) (map[string][]byte, error) {
+	for _, k := range keys {
+		if err := s.checkAccess(ctx, AccessRead, k); err != nil {
+			return nil, err
+		}
+	}
+	out := make(map[string][]byte, len(keys))
+	err := s.db.View(func(txn *badger.Txn) error {
+		for i, k := range keys {
+			if i%64 == 0 && ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if _, ok := out[string(k)]; ok {
+				continue
+			}
+			v, found, err := s.txLiveValue(txn, k)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+			s.noteRead(k)
+			out[string(k)] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MissingKeys возвращает ключи из keys, которых нет в результате MultiGet, в исходном порядке.
+func MissingKeys(keys [][]byte, found map[string][]byte) [][]byte {
+	var missing [][]byte
+	for _, k := range keys {
+		if _, ok := found[string(k)]; !ok {
+			missing = append(missing, k)
+		}
+	}
+	return missing
+}
+
 func (s *Store) Delete(key []byte) error {
+	return s.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext — Delete, ожидание WriteRateLimit в котором прерывается отменой ctx.
+// Principal для AccessController берётся из ctx (WithPrincipal).
+func (s *Store) DeleteContext(ctx context.Context, key []byte) error {
+	start := s.clock.Now()
+	err := s.delete(ctx, key)
+	s.record(RecordDelete, key, nil, 0, 0, start, err)
+	return s.traceOp(ctx, "delete", key, start, 1, err)
+}
+
+func (s *Store) delete(ctx context.Context, key []byte) error {
+	if err := s.checkAccess(ctx, AccessDelete, key); err != nil {
+		return err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(ctx); err != nil {
+		return err
+	}
 	return s.db.Update(func(txn *badger.Txn) error {
 		return txn.Delete(key)
 	})
 }
 
-func (s *Store) SetObject(key []byte, v any, ttl time.Duration) error {
-	data, err := s.Marshal(v)
+// GetAndDelete читает значение и удаляет ключ в одной транзакции.
+// При DetectConflicts=true из конкурентных вызовов для одного ключа успешен ровно один,
+// остальные получают badger.ErrConflict (или ErrNotFound, если ключ уже удалён).
+func (s *Store) GetAndDelete(key []byte) ([]byte, error) {
+	start := s.clock.Now()
+	out, err := s.getAndDelete(key)
+	s.record(RecordGetAndDelete, key, out, 0, 0, start, err)
+	return out, err
+}
+
+func (s *Store) getAndDelete(key []byte) ([]byte, error) {
+	if err := s.checkAccess(context.Background(), AccessRead, key); err != nil {
+		return nil, err
+	}
+	if err := s.checkAccess(context.Background(), AccessDelete, key); err != nil {
+		return nil, err
+	}
+	if err := checkSystemKey(key); err != nil {
+		return nil, err
+	}
+	if err := s.writeLimit.wait(context.Background()); err != nil {
+		return nil, err
+	}
+	var out []byte
+	err := s.db.Update(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if s.expired(item) {
+			return ErrNotFound
+		}
+		out, err = item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		s.noteRead(key)
+		return txn.Delete(key)
+	})
 	if err != nil {
-		return fmt.Errorf("codec.Marshal: %w", err)
+		return nil, err
 	}
-	return s.Set(key, data, ttl)
+	return out, nil
+}
+
+func (s *Store) SetObject(key []byte, v any, ttl time.Duration) error {
+	if !s.versioned {
+		data, err := s.marshal(key, v)
+		if err != nil {
+			return err
+		}
+		return s.Set(key, data, ttl)
+	}
+	if err := s.checkAccess(context.Background(), AccessWrite, key); err != nil {
+		return err
+	}
+	if err := s.validateKey(context.Background(), key); err != nil {
+		return err
+	}
+	if err := s.writeLimit.wait(context.Background()); err != nil {
+		return err
+	}
+	s.noteWrite(key)
+	// версия читается и пишется в одной транзакции: конфликт с параллельной записью повторяем
+	return NewTransactionManager(s).ExecuteReadWriteWithContext(context.Background(), func(_ context.Context, txn *badger.Txn) error {
+		data, _, err := s.txMarshalObject(txn, key, v)
+		if err != nil {
+			return err
+		}
+		return txn.SetEntry(s.NewEntry(key, data, s.jitterTTL(context.Background(), ttl)))
+	})
 }
 
 func (s *Store) GetObject(key []byte, v any) error {
@@ -190,8 +552,5 @@ func (s *Store) GetObject(key []byte, v any) error {
 	if err != nil {
 		return err
 	}
-	if err := s.Unmarshal(data, v); err != nil {
-		return fmt.Errorf("codec.Unmarshal: %w", err)
-	}
-	return nil
+	return s.decode(key, data, v)
 }
@@ -0,0 +1,124 @@
+package sdk
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// Имя хранилища для процессов с несколькими Store (по версии, по арендатору): счётчики Badger в
+// expvar общие на процесс, и без имени метрики хранилищ неразличимы. Options.StoreName добавляет
+// метку store ко всем метрикам WriteMetrics (если её нет в labels), попадает в строку мониторинга
+// и публикует Vars хранилища в expvar "memory_storage" под этим именем (/debug/vars). Имя уникально
+// в процессе: Open второго Store с тем же именем — ErrStoreNameInUse; Close освобождает имя.
+
+// ErrStoreNameInUse — в процессе уже открыт Store с таким Options.StoreName.
+var ErrStoreNameInUse = errors.New("store name is already in use")
+
+const storeVarsName = "memory_storage"
+
+var (
+	storeVarsOnce sync.Once
+	storeVars     *expvar.Map
+
+	storeNamesMu sync.Mutex
+	storeNames   = make(map[string]struct{})
+)
+
+// StoreVars — статистика Store, публикуемая в expvar. Байтовые счётчики WriteAmp — общие на процесс.
+type StoreVars struct {
+	Name      string         `json:"name"`
+	Dir       string         `json:"dir"`
+	LSMBytes  int64          `json:"lsm_bytes"`
+	VLogBytes int64          `json:"vlog_bytes"`
+	Latency   LatencyStats   `json:"latency"`
+	WriteAmp  WriteAmpStats  `json:"write_amp"`
+	GC        GCStats        `json:"gc"`
+	RateLimit RateLimitStats `json:"rate_limit"`
+}
+
+// Name возвращает Options.StoreName ("" — имя не задано).
+func (s *Store) Name() string {
+	return s.name
+}
+
+// Vars возвращает статистику хранилища в том виде, в каком она публикуется в expvar.
+func (s *Store) Vars() StoreVars {
+	lsm, vlog := s.db.Size()
+	return StoreVars{
+		Name:      s.name,
+		Dir:       s.db.Opts().Dir,
+		LSMBytes:  lsm,
+		VLogBytes: vlog,
+		Latency:   s.LatencyStats(),
+		WriteAmp:  s.WriteAmpStats(),
+		GC:        s.GCStats(),
+		RateLimit: s.RateLimitStats(),
+	}
+}
+
+// claimStoreName занимает имя до открытия Badger, чтобы при конфликте не открывать базу.
+func claimStoreName(name string) error {
+	if name == "" {
+		return nil
+	}
+	storeNamesMu.Lock()
+	defer storeNamesMu.Unlock()
+	if _, ok := storeNames[name]; ok {
+		return fmt.Errorf("%w: %q", ErrStoreNameInUse, name)
+	}
+	storeNames[name] = struct{}{}
+	return nil
+}
+
+// releaseName освобождает имя Store до Close, чтобы открыть его преемника (новую реплику) под тем же
+// именем, пока этот Store ещё обслуживает чтения. Повторный вызов и Close имя не трогают: оно уже
+// может принадлежать преемнику.
+func (s *Store) releaseName() {
+	if s.nameHeld.Swap(false) {
+		releaseStoreName(s.name)
+	}
+}
+
+func releaseStoreName(name string) {
+	if name == "" {
+		return
+	}
+	// сначала снимаем публикацию: после освобождения имени его может занять новый Store
+	storeVarsMap().Delete(name)
+	storeNamesMu.Lock()
+	delete(storeNames, name)
+	storeNamesMu.Unlock()
+}
+
+func storeVarsMap() *expvar.Map {
+	storeVarsOnce.Do(func() {
+		storeVars = expvar.NewMap(storeVarsName)
+	})
+	return storeVars
+}
+
+// publishVars публикует Vars под именем хранилища в expvar "memory_storage".
+func (s *Store) publishVars() {
+	if s.name == "" {
+		return
+	}
+	storeVarsMap().Set(s.name, expvar.Func(func() any { return s.Vars() }))
+}
+
+// metricLabels дополняет labels меткой store, если задано имя и метки с таким ключом нет.
+func (s *Store) metricLabels(labels map[string]string) map[string]string {
+	if s.name == "" {
+		return labels
+	}
+	if _, ok := labels["store"]; ok {
+		return labels
+	}
+	out := make(map[string]string, len(labels)+1)
+	for k, v := range labels {
+		out[k] = v
+	}
+	out["store"] = s.name
+	return out
+}
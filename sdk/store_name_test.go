@@ -0,0 +1,117 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenSharded_StoreNamePerShard(t *testing.T) {
+	base := t.TempDir()
+	dirs := []string{filepath.Join(base, "0"), filepath.Join(base, "1")}
+	ss, err := OpenSharded(context.Background(), Options{StoreName: "sharded"}, dirs, nil)
+	if err != nil {
+		t.Fatalf("open named sharded store: %v", err)
+	}
+	if got := ss.Shard(1).Name(); got != "sharded/shard-1" {
+		t.Fatalf("shard name = %q", got)
+	}
+	if err := ss.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Close освобождает имена шардов
+	ss, err = OpenSharded(context.Background(), Options{StoreName: "sharded"}, dirs, nil)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	_ = ss.Close()
+}
+
+func TestStoreName_InUse(t *testing.T) {
+	s, err := Open(context.Background(), Options{InMemory: true, StoreName: "dup"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(context.Background(), Options{InMemory: true, StoreName: "dup"}, nil); !errors.Is(err, ErrStoreNameInUse) {
+		t.Fatalf("second open: %v, want ErrStoreNameInUse", err)
+	}
+	s.releaseName()
+	next, err := Open(context.Background(), Options{InMemory: true, StoreName: "dup"}, nil)
+	if err != nil {
+		t.Fatalf("open after releaseName: %v", err)
+	}
+	// Close прежнего Store не должен отнять имя у преемника
+	_ = s.Close()
+	if _, err := Open(context.Background(), Options{InMemory: true, StoreName: "dup"}, nil); !errors.Is(err, ErrStoreNameInUse) {
+		t.Fatalf("name must stay with the successor: %v", err)
+	}
+	_ = next.Close()
+}
+
+func TestLocalLeaderElector_FailoverWithStoreName(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	newElector := func(id string) *LocalLeaderElector {
+		e, err := NewLocalLeaderElector(LocalLeaderOptions{
+			Store:           Options{Dir: dir, StoreName: "elected"},
+			ID:              id,
+			Heartbeat:       20 * time.Millisecond,
+			ReplicaInterval: 20 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	waitRole := func(e *LocalLeaderElector, want LeaderRole, withStore bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for e.Role() != want || (withStore && e.Store() == nil) {
+			if time.Now().After(deadline) {
+				t.Fatalf("role %q (store %t), want %q with store %t", e.Role(), e.Store() != nil, want, withStore)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	leader := newElector("a")
+	leaderCtx, stopLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error, 1)
+	go func() { leaderDone <- leader.Run(leaderCtx) }()
+	waitRole(leader, RoleLeader, true)
+	if err := leader.Store().Set([]byte("k"), []byte("v"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	follower := newElector("b")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	followerDone := make(chan error, 1)
+	go func() { followerDone <- follower.Run(ctx) }()
+	waitRole(follower, RoleFollower, true)
+
+	// за это время реплика несколько раз открывается заново под тем же именем
+	time.Sleep(100 * time.Millisecond)
+	if got := follower.Store().Name(); got != "elected/replica" {
+		t.Fatalf("replica name = %q", got)
+	}
+
+	stopLeader()
+	if err := <-leaderDone; err != nil {
+		t.Fatalf("leader run: %v", err)
+	}
+	waitRole(follower, RoleLeader, true)
+	s := follower.Store()
+	if s.Name() != "elected" {
+		t.Fatalf("leader name = %q", s.Name())
+	}
+	if v, err := s.Get([]byte("k")); err != nil || string(v) != "v" {
+		t.Fatalf("get after failover: %q %v", v, err)
+	}
+	cancel()
+	if err := <-followerDone; err != nil {
+		t.Fatalf("follower run: %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package storetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+// ManualClock — виртуальные часы для sdk.Options.Clock: время двигается только через Advance,
+// таймеры и тикеры срабатывают, когда виртуальное время доходит до их срока.
+// С SetAutoAdvance(true) каждый новый таймер сразу перематывает часы к своему сроку — паузы
+// (например, backoff ретраев транзакций) проходят мгновенно и в детерминированном порядке.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	auto   bool
+	timers []*manualTimer
+	waits  []time.Duration
+}
+
+var _ sdk.Clock = (*ManualClock)(nil)
+
+// NewManualClock создаёт часы, показывающие start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance сдвигает время на d и срабатывает таймеры и тикеры, чей срок наступил.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.advanceLocked(d)
+}
+
+// SetAutoAdvance включает или выключает перемотку к сроку каждого нового таймера.
+func (c *ManualClock) SetAutoAdvance(on bool) {
+	c.mu.Lock()
+	c.auto = on
+	c.mu.Unlock()
+}
+
+// Waits возвращает длительности всех созданных таймеров по порядку — например, паузы между
+// попытками транзакции.
+func (c *ManualClock) Waits() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Duration(nil), c.waits...)
+}
+
+func (c *ManualClock) advanceLocked(d time.Duration) {
+	c.now = c.now.Add(d)
+	active := c.timers[:0]
+	for _, t := range c.timers {
+		for !t.deadline.After(c.now) {
+			select {
+			case t.ch <- t.deadline:
+			default: // как у time.Ticker: непрочитанный тик не копится
+			}
+			if t.period <= 0 {
+				break
+			}
+			t.deadline = t.deadline.Add(t.period)
+		}
+		if t.deadline.After(c.now) {
+			active = append(active, t)
+		}
+	}
+	clear(c.timers[len(active):])
+	c.timers = active
+}
+
+func (c *ManualClock) add(t *manualTimer) {
+	c.timers = append(c.timers, t)
+}
+
+func (c *ManualClock) remove(t *manualTimer) bool {
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) sdk.Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTimer{clock: c, ch: make(chan time.Time, 1), deadline: c.now.Add(d)}
+	c.waits = append(c.waits, d)
+	c.add(t)
+	if c.auto {
+		c.advanceLocked(max(d, 0))
+	} else if d <= 0 {
+		c.advanceLocked(0)
+	}
+	return t
+}
+
+func (c *ManualClock) NewTicker(d time.Duration) sdk.Ticker {
+	if d <= 0 {
+		panic("storetest: non-positive ticker interval")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &manualTimer{clock: c, ch: make(chan time.Time, 1), deadline: c.now.Add(d), period: d}
+	c.add(t)
+	return manualTicker{t}
+}
+
+type manualTimer struct {
+	clock    *ManualClock
+	ch       chan time.Time
+	deadline time.Time
+	period   time.Duration // > 0 — тикер
+}
+
+func (t *manualTimer) C() <-chan time.Time {
+	return t.ch
+}
+
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	return t.clock.remove(t)
+}
+
+func (t *manualTimer) Reset(d time.Duration) bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	active := t.clock.remove(t)
+	t.deadline = t.clock.now.Add(d)
+	t.clock.add(t)
+	if t.clock.auto {
+		t.clock.advanceLocked(max(d, 0))
+	}
+	return active
+}
+
+type manualTicker struct {
+	t *manualTimer
+}
+
+func (k manualTicker) C() <-chan time.Time {
+	return k.t.ch
+}
+
+func (k manualTicker) Stop() {
+	k.t.Stop()
+}
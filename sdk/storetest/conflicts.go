@@ -0,0 +1,59 @@
+package storetest
+
+import (
+	"sync"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// ConflictSchedule — расписание искусственных конфликтов для sdk.TxManagerOptions.CommitFault:
+// выбранные по порядку коммиты завершаются badger.ErrConflict без обращения к Badger.
+// Коммиты нумеруются с 1 по всем транзакциям менеджера, поэтому ретраи и побочные эффекты
+// колбэков проверяются без настоящей конкуренции.
+type ConflictSchedule struct {
+	mu        sync.Mutex
+	failFirst int
+	failAt    map[int]bool
+	commits   int
+	conflicts int
+}
+
+// FailFirstCommits — первые n коммитов конфликтуют.
+func FailFirstCommits(n int) *ConflictSchedule {
+	return &ConflictSchedule{failFirst: n}
+}
+
+// ConflictOnCommits — конфликтуют коммиты с указанными номерами (с 1).
+func ConflictOnCommits(commits ...int) *ConflictSchedule {
+	cs := &ConflictSchedule{failAt: make(map[int]bool, len(commits))}
+	for _, n := range commits {
+		cs.failAt[n] = true
+	}
+	return cs
+}
+
+// CommitFault — значение для sdk.TxManagerOptions.CommitFault.
+func (cs *ConflictSchedule) CommitFault(int) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.commits++
+	if cs.commits <= cs.failFirst || cs.failAt[cs.commits] {
+		cs.conflicts++
+		return badger.ErrConflict
+	}
+	return nil
+}
+
+// Commits — сколько коммитов было начато (включая искусственно сорванные).
+func (cs *ConflictSchedule) Commits() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.commits
+}
+
+// Conflicts — сколько коммитов сорвано расписанием.
+func (cs *ConflictSchedule) Conflicts() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.conflicts
+}
@@ -0,0 +1,176 @@
+// Package storetest — вспомогательные функции для тестов поверх sdk.Store:
+// эфемерное хранилище, загрузка фикстур из JSONL, проверки ключей/TTL, виртуальные часы
+// и расписание конфликтов транзакций.
+package storetest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/dgraph-io/badger/v4"
+)
+
+// NewTestStore открывает in-memory Store, закрываемый через t.Cleanup.
+// opts позволяет переопределить параметры (например, Clock или Codec); InMemory включается всегда.
+func NewTestStore(t testing.TB, opts ...sdk.Options) *sdk.Store {
+	t.Helper()
+	var o sdk.Options
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	o.InMemory = true
+	o.ReadOnly = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	store, err := sdk.Open(ctx, o, nil)
+	if err != nil {
+		cancel()
+		t.Fatalf("storetest: open store: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		if err := store.Close(); err != nil {
+			t.Errorf("storetest: close store: %v", err)
+		}
+	})
+	return store
+}
+
+// Fixture — одна строка JSONL-фикстуры.
+// Ровно одно из Value (строка как есть) и JSON (произвольный JSON, пишется байтами) должно быть задано.
+// TTL — строка time.ParseDuration ("10m"), пустая — без TTL.
+type Fixture struct {
+	Key   string          `json:"key"`
+	Value *string         `json:"value,omitempty"`
+	JSON  json.RawMessage `json:"json,omitempty"`
+	TTL   string          `json:"ttl,omitempty"`
+}
+
+// LoadJSONL записывает в store записи из JSONL-потока. Пустые строки пропускаются.
+// Возвращает число записанных ключей.
+func LoadJSONL(store *sdk.Store, r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	wb := store.DB().NewWriteBatch()
+	defer wb.Cancel()
+
+	n, line := 0, 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var f Fixture
+		if err := json.Unmarshal(raw, &f); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		if f.Key == "" {
+			return n, fmt.Errorf("line %d: empty key", line)
+		}
+		var value []byte
+		switch {
+		case f.Value != nil && f.JSON != nil:
+			return n, fmt.Errorf("line %d: both value and json are set", line)
+		case f.Value != nil:
+			value = []byte(*f.Value)
+		case f.JSON != nil:
+			value = []byte(f.JSON)
+		default:
+			return n, fmt.Errorf("line %d: neither value nor json is set", line)
+		}
+		var ttl time.Duration
+		if f.TTL != "" {
+			d, err := time.ParseDuration(f.TTL)
+			if err != nil {
+				return n, fmt.Errorf("line %d: ttl: %w", line, err)
+			}
+			ttl = d
+		}
+		if err := wb.SetEntry(store.NewEntry([]byte(f.Key), value, ttl)); err != nil {
+			return n, fmt.Errorf("line %d: %w", line, err)
+		}
+		n++
+	}
+	if err := scanner.Err(); err != nil {
+		return n, err
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// SeedJSONL загружает фикстуру из файла path, при ошибке валит тест.
+func SeedJSONL(t testing.TB, store *sdk.Store, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("storetest: open fixture: %v", err)
+	}
+	defer f.Close()
+	n, err := LoadJSONL(store, f)
+	if err != nil {
+		t.Fatalf("storetest: load fixture %s: %v", path, err)
+	}
+	return n
+}
+
+// RequireKey проверяет, что ключ существует и (если want != nil) его значение равно want.
+func RequireKey(t testing.TB, store *sdk.Store, key, want []byte) {
+	t.Helper()
+	got, err := store.Get(key)
+	if err != nil {
+		t.Fatalf("storetest: key %q: %v", key, err)
+	}
+	if want != nil && !bytes.Equal(got, want) {
+		t.Fatalf("storetest: key %q = %q, want %q", key, got, want)
+	}
+}
+
+// RequireNoKey проверяет, что ключа нет (или он истёк).
+func RequireNoKey(t testing.TB, store *sdk.Store, key []byte) {
+	t.Helper()
+	_, err := store.Get(key)
+	if err == nil {
+		t.Fatalf("storetest: key %q must not exist", key)
+	}
+	if !errors.Is(err, sdk.ErrNotFound) {
+		t.Fatalf("storetest: key %q: %v", key, err)
+	}
+}
+
+// RequireTTLBetween проверяет, что оставшийся TTL ключа по часам хранилища лежит в [min, max].
+// Точность — секунды: Badger хранит срок истечения в unix-секундах.
+func RequireTTLBetween(t testing.TB, store *sdk.Store, key []byte, min, max time.Duration) {
+	t.Helper()
+	var expiresAt uint64
+	err := store.DB().View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		expiresAt = item.ExpiresAt()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("storetest: key %q: %v", key, err)
+	}
+	if expiresAt == 0 {
+		t.Fatalf("storetest: key %q has no TTL", key)
+	}
+	left := time.Unix(int64(expiresAt), 0).Sub(store.Clock().Now())
+	if left < min || left > max {
+		t.Fatalf("storetest: key %q TTL = %s, want between %s and %s", key, left, min, max)
+	}
+}
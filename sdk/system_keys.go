@@ -0,0 +1,223 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Служебная область "!sys:" — состояние подсистем SDK (контрольные точки бэкапов, состояние миграций,
+// квоты). Пользовательские записи и удаления (Set, SetObject, Delete, GetAndDelete, WriteBatch,
+// транзакции RunTx, UpdateCRDT) ключей области получают ErrSystemKey, сканы (ScanPrefix*, ScanKeys,
+// агрегаты, anti-entropy и дерево дайджестов) пропускают их, если префикс скана не начинается с
+// "!sys:", а DropPrefix и DropAll область сохраняют. Подсистемы работают с ней через SystemKeys:
+//
+//	"!sys:" + namespace + ":" + name → 1 байт формата + CRC-32C (4 байта) + JSON
+//
+// Контрольная сумма ловит повреждение значения: Get возвращает ErrSystemKeyCorrupt, а не мусор.
+
+// SystemKeyPrefix — префикс служебной области; скан с ним (или с более длинным) её показывает.
+const SystemKeyPrefix = "!sys:"
+
+var systemKeyPrefix = []byte(SystemKeyPrefix)
+
+var (
+	// ErrSystemKey — запись или удаление ключа служебной области мимо SystemKeys.
+	ErrSystemKey = errors.New("key is reserved for store metadata")
+	// ErrSystemKeyCorrupt — значение служебного ключа не прошло проверку контрольной суммы.
+	ErrSystemKeyCorrupt = errors.New("system key value is corrupt")
+)
+
+const systemValueFormat = 1
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+func isSystemKey(key []byte) bool {
+	return bytes.HasPrefix(key, systemKeyPrefix)
+}
+
+// hiddenSystemKey — key из служебной области, попавший в скан prefix, который её явно не запрашивал.
+func hiddenSystemKey(prefix, key []byte) bool {
+	return isSystemKey(key) && !bytes.HasPrefix(prefix, systemKeyPrefix)
+}
+
+// checkSystemKey запрещает пользовательские изменения служебной области.
+func checkSystemKey(key []byte) error {
+	if isSystemKey(key) {
+		return fmt.Errorf("%w: %q", ErrSystemKey, key)
+	}
+	return nil
+}
+
+// SystemKeys — типизированный доступ подсистемы к своему пространству служебной области.
+type SystemKeys struct {
+	store  *Store
+	prefix []byte
+}
+
+// NewSystemKeys возвращает пространство namespace служебной области (непустое, без ':').
+func NewSystemKeys(store *Store, namespace string) *SystemKeys {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if namespace == "" || strings.Contains(namespace, ":") {
+		panic("system namespace must be non-empty and must not contain ':'")
+	}
+	return &SystemKeys{store: store, prefix: []byte(SystemKeyPrefix + namespace + ":")}
+}
+
+func (k *SystemKeys) key(name string) ([]byte, error) {
+	if name == "" {
+		return nil, errors.New("empty system key name")
+	}
+	return append(bytes.Clone(k.prefix), name...), nil
+}
+
+// Get декодирует значение name в v. Нет значения — ErrNotFound, повреждено — ErrSystemKeyCorrupt.
+func (k *SystemKeys) Get(name string, v any) error {
+	key, err := k.key(name)
+	if err != nil {
+		return err
+	}
+	return k.store.db.View(func(txn *badger.Txn) error {
+		raw, err := k.getTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if raw == nil {
+			return ErrNotFound
+		}
+		return decodeSystemValue(key, raw, v)
+	})
+}
+
+// Set записывает v под name.
+func (k *SystemKeys) Set(name string, v any) error {
+	key, err := k.key(name)
+	if err != nil {
+		return err
+	}
+	data, err := encodeSystemValue(v)
+	if err != nil {
+		return err
+	}
+	return k.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, data)
+	})
+}
+
+// Update читает значение name в v (exists=false и v без изменений, если его нет), вызывает fn и
+// записывает v в одной транзакции; при конфликте транзакция повторяется, поэтому fn не должна иметь
+// внешних побочных эффектов. Ошибка fn прерывает обновление.
+func (k *SystemKeys) Update(ctx context.Context, name string, v any, fn func(exists bool) error) error {
+	key, err := k.key(name)
+	if err != nil {
+		return err
+	}
+	return NewTransactionManager(k.store).ExecuteReadWriteWithContext(ctx, func(_ context.Context, txn *badger.Txn) error {
+		raw, err := k.getTxn(txn, key)
+		if err != nil {
+			return err
+		}
+		if raw != nil {
+			if err := decodeSystemValue(key, raw, v); err != nil {
+				return err
+			}
+		}
+		if err := fn(raw != nil); err != nil {
+			return err
+		}
+		return k.setTxn(txn, key, v)
+	})
+}
+
+// setTxn записывает v под key в транзакции txn — вместе с изменениями, которые значение описывает
+// (например, контрольная точка и обработанная до неё пачка ключей).
+func (k *SystemKeys) setTxn(txn *badger.Txn, key []byte, v any) error {
+	data, err := encodeSystemValue(v)
+	if err != nil {
+		return err
+	}
+	return txn.Set(key, data)
+}
+
+// Delete удаляет name.
+func (k *SystemKeys) Delete(name string) error {
+	key, err := k.key(name)
+	if err != nil {
+		return err
+	}
+	return k.store.db.Update(func(txn *badger.Txn) error {
+		return txn.Delete(key)
+	})
+}
+
+// Names возвращает имена значений пространства по возрастанию.
+func (k *SystemKeys) Names() ([]string, error) {
+	var out []string
+	err := k.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = k.prefix
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(k.prefix); it.ValidForPrefix(k.prefix); it.Next() {
+			if !k.store.expired(it.Item()) {
+				out = append(out, string(it.Item().Key()[len(k.prefix):]))
+			}
+		}
+		return nil
+	})
+	return out, err
+}
+
+// getTxn возвращает сырое значение key или nil, если его нет.
+func (k *SystemKeys) getTxn(txn *badger.Txn, key []byte) ([]byte, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if k.store.expired(item) {
+		return nil, nil
+	}
+	return item.ValueCopy(nil)
+}
+
+// GetSystem — SystemKeys.Get в значение типа T.
+func GetSystem[T any](k *SystemKeys, name string) (T, error) {
+	var v T
+	err := k.Get(name, &v)
+	return v, err
+}
+
+func encodeSystemValue(v any) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 5, 5+len(payload))
+	out[0] = systemValueFormat
+	binary.BigEndian.PutUint32(out[1:5], crc32.Checksum(payload, castagnoli))
+	return append(out, payload...), nil
+}
+
+func decodeSystemValue(key, raw []byte, v any) error {
+	if len(raw) < 5 || raw[0] != systemValueFormat {
+		return fmt.Errorf("%w: %q: unknown format", ErrSystemKeyCorrupt, key)
+	}
+	payload := raw[5:]
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(raw[1:5]) {
+		return fmt.Errorf("%w: %q: checksum mismatch", ErrSystemKeyCorrupt, key)
+	}
+	return json.Unmarshal(payload, v)
+}
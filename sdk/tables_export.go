@@ -0,0 +1,242 @@
+package sdk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/ristretto/v2/z"
+)
+
+// Экспорт снимка в родном формате Badger. Логический бэкап (FullBackupToFile) при восстановлении
+// переигрывается через db.Load: каждая запись заново проходит memtable и компакции, и 100 ГБ
+// грузятся часами. ExportTables пишет живые ключи StreamWriter'ом сразу в готовые таблицы SST
+// нижнего уровня — получается каталог Badger, который новому узлу достаточно скопировать
+// (IngestTables) и открыть. Таблицы шифруются ключом исходного Store, поэтому в экспорт пишется
+// отпечаток ключа, и IngestTables отказывается ставить экспорт узлу с другим EncryptionKey.
+
+// tablesManifestName — описание экспорта в его каталоге.
+const tablesManifestName = "export.json"
+
+// ErrEncryptionKeyMismatch — EncryptionKey узла не совпадает с ключом, которым зашифрован экспорт.
+var ErrEncryptionKeyMismatch = errors.New("encryption key does not match tables export")
+
+// TablesExport — описание экспорта ExportTables (файл export.json в каталоге экспорта).
+type TablesExport struct {
+	Dir        string    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	MaxVersion uint64    `json:"max_version"`
+	// Encrypted — таблицы зашифрованы; KeyFingerprint — HMAC-отпечаток ключа, сам ключ не сохраняется.
+	Encrypted      bool          `json:"encrypted"`
+	KeyFingerprint string        `json:"key_fingerprint,omitempty"`
+	Bytes          int64         `json:"bytes"`
+	Duration       time.Duration `json:"duration"`
+}
+
+// ExportTables пишет последние версии живых ключей в новый каталог Badger dir (его не должно быть
+// или он пуст). Версии записей сохраняются, поэтому инкрементальные бэкапы исходного Store можно
+// догрузить поверх. Операция пишется в журнал аудита (op "export_tables").
+func (s *Store) ExportTables(ctx context.Context, dir string) (TablesExport, error) {
+	exp := TablesExport{Dir: dir}
+	err := s.RunAudited(ctx, "export_tables", dir, func() error {
+		return s.exportTables(ctx, dir, &exp)
+	})
+	return exp, err
+}
+
+func (s *Store) exportTables(ctx context.Context, dir string, exp *TablesExport) error {
+	if err := requireEmptyDir(dir); err != nil {
+		return err
+	}
+	start := s.clock.Now()
+	co := s.db.Opts()
+	co.Dir, co.ValueDir = dir, dir
+	co.InMemory, co.ReadOnly = false, false
+	ok := false
+	defer func() {
+		if !ok {
+			_ = os.RemoveAll(dir)
+		}
+	}()
+	version, err := s.streamLiveTo(ctx, co, "export tables")
+	if err != nil {
+		return err
+	}
+	usage, err := diskUsage(dir, dir)
+	if err != nil {
+		return err
+	}
+	*exp = TablesExport{
+		Dir:            dir,
+		CreatedAt:      start,
+		MaxVersion:     version,
+		Encrypted:      len(co.EncryptionKey) > 0,
+		KeyFingerprint: keyFingerprint(co.EncryptionKey),
+		Bytes:          usage.Total(),
+		Duration:       s.clock.Now().Sub(start),
+	}
+	data, err := json.MarshalIndent(exp, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, tablesManifestName), data, 0o644); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+// streamLiveTo открывает новую БД с опциями opts и записывает в неё StreamWriter'ом последние версии
+// живых ключей. Возвращает MaxVersion на момент снимка.
+func (s *Store) streamLiveTo(ctx context.Context, opts badger.Options, logPrefix string) (uint64, error) {
+	dst, err := badger.Open(opts)
+	if err != nil {
+		return 0, err
+	}
+	version := s.db.MaxVersion()
+	sw := dst.NewStreamWriter()
+	if err := sw.Prepare(); err != nil {
+		_ = dst.Close()
+		return 0, err
+	}
+	stream := s.db.NewStream()
+	stream.LogPrefix = logPrefix
+	stream.ChooseKey = func(item *badger.Item) bool { return !s.expired(item) }
+	stream.Send = func(buf *z.Buffer) error { return sw.Write(buf) }
+	if err := stream.Orchestrate(ctx); err != nil {
+		sw.Cancel()
+		_ = dst.Close()
+		return 0, err
+	}
+	if err := sw.Flush(); err != nil {
+		_ = dst.Close()
+		return 0, err
+	}
+	return version, dst.Close()
+}
+
+// ReadTablesExport читает описание экспорта из каталога ExportTables.
+func ReadTablesExport(dir string) (TablesExport, error) {
+	data, err := os.ReadFile(filepath.Join(dir, tablesManifestName))
+	if err != nil {
+		return TablesExport{}, fmt.Errorf("read tables export manifest: %w", err)
+	}
+	var exp TablesExport
+	if err := json.Unmarshal(data, &exp); err != nil {
+		return TablesExport{}, fmt.Errorf("parse tables export manifest: %w", err)
+	}
+	exp.Dir = dir
+	return exp, nil
+}
+
+// IngestTables ставит экспорт ExportTables в каталоги opts.Dir/opts.ValueDir нового узла (их не должно
+// быть или они пусты); затем хранилище открывается обычным Open(opts). Таблицы по возможности
+// связываются жёсткими ссылками, остальные файлы копируются. Если opts.EncryptionKey не тот, которым
+// зашифрован экспорт, возвращает ErrEncryptionKeyMismatch, ничего не копируя.
+func IngestTables(ctx context.Context, exportDir string, opts Options) (TablesExport, error) {
+	exp, err := ReadTablesExport(exportDir)
+	if err != nil {
+		return exp, err
+	}
+	if opts.InMemory || opts.ReadOnly {
+		return exp, errors.New("ingest tables requires a writable on-disk store")
+	}
+	switch {
+	case exp.Encrypted && len(opts.EncryptionKey) == 0:
+		return exp, fmt.Errorf("%w: export is encrypted, store has no encryption key", ErrEncryptionKeyMismatch)
+	case !exp.Encrypted && len(opts.EncryptionKey) > 0:
+		return exp, fmt.Errorf("%w: export is not encrypted, store has an encryption key", ErrEncryptionKeyMismatch)
+	case exp.Encrypted && !hmac.Equal([]byte(exp.KeyFingerprint), []byte(keyFingerprint(opts.EncryptionKey))):
+		return exp, ErrEncryptionKeyMismatch
+	}
+	valueDir := opts.ValueDir
+	if valueDir == "" {
+		valueDir = opts.Dir
+	}
+	for _, d := range []string{opts.Dir, valueDir} {
+		if err := requireEmptyDir(d); err != nil {
+			return exp, err
+		}
+	}
+	entries, err := os.ReadDir(exportDir)
+	if err != nil {
+		return exp, err
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || name == tablesManifestName || name == "LOCK" {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return exp, err
+		}
+		dst := opts.Dir
+		if filepath.Ext(name) == ".vlog" || name == "DISCARD" {
+			dst = valueDir
+		}
+		if err := linkOrCopy(filepath.Join(exportDir, name), filepath.Join(dst, name)); err != nil {
+			return exp, fmt.Errorf("ingest %s: %w", name, err)
+		}
+	}
+	return exp, nil
+}
+
+// keyFingerprint — отпечаток ключа шифрования, по которому нельзя восстановить сам ключ.
+func keyFingerprint(key []byte) string {
+	if len(key) == 0 {
+		return ""
+	}
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte("memory-storage tables export"))
+	return hex.EncodeToString(m.Sum(nil))
+}
+
+// requireEmptyDir создаёт dir или проверяет, что он пуст.
+func requireEmptyDir(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("directory %s is not empty", dir)
+	}
+	return nil
+}
+
+// linkOrCopy связывает неизменяемые таблицы SST жёсткой ссылкой (если каталоги на одной ФС), а
+// остальное копирует: MANIFEST, value-log и DISCARD Badger дописывает после открытия.
+func linkOrCopy(src, dst string) (err error) {
+	if filepath.Ext(src) == ".sst" && os.Link(src, dst) == nil {
+		return nil
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}()
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Sync()
+}
@@ -0,0 +1,402 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Раскладка ключей временных рядов (P — TimeSeriesOptions.Prefix):
+//
+//	P + "raw:" + series + 0x00 + rts            → float64 (8 байт)
+//	P + "r:" + step + ":" + series + 0x00 + rts → count, sum, min, max (32 байта)
+//	P + "state:" + step + ":" + series          → начало следующего несвёрнутого интервала (unix nano)
+//
+// rts — big-endian (MaxUint64 - unix nano): свежие точки идут первыми, а диапазон [from, to]
+// читается одним проходом итератора от to к from.
+
+// TimePoint — точка ряда.
+type TimePoint struct {
+	T     time.Time
+	Value float64
+}
+
+// RollupPoint — свёртка интервала [T, T+Step).
+type RollupPoint struct {
+	T             time.Time
+	Count         uint64
+	Sum, Min, Max float64
+}
+
+// Avg — среднее значение интервала.
+func (p RollupPoint) Avg() float64 {
+	if p.Count == 0 {
+		return math.NaN()
+	}
+	return p.Sum / float64(p.Count)
+}
+
+// RollupRule — правило фонового прореживания.
+type RollupRule struct {
+	// Step — длина интервала свёртки, например time.Minute.
+	Step time.Duration
+	// Retention — сколько хранить свёртки (от времени интервала), 0 — бессрочно.
+	Retention time.Duration
+	// Delay — сколько ждать опоздавших точек после конца интервала, прежде чем его свернуть.
+	Delay time.Duration
+}
+
+type TimeSeriesOptions struct {
+	// Prefix — корневой префикс всех ключей рядов, по умолчанию "ts:".
+	Prefix string
+	// Retention — сколько хранить сырые точки (от времени точки, через TTL), 0 — бессрочно.
+	Retention time.Duration
+}
+
+// TimeSeries — временные ряды поверх Store: дописывание точек, выборки по диапазону и свёртки.
+type TimeSeries struct {
+	store *Store
+	opts  TimeSeriesOptions
+}
+
+func NewTimeSeries(store *Store, opts TimeSeriesOptions) *TimeSeries {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if opts.Prefix == "" {
+		opts.Prefix = "ts:"
+	}
+	return &TimeSeries{store: store, opts: opts}
+}
+
+func reverseTimestamp(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, math.MaxUint64-uint64(t.UnixNano()))
+}
+
+func parseReverseTimestamp(b []byte) time.Time {
+	return time.Unix(0, int64(math.MaxUint64-binary.BigEndian.Uint64(b)))
+}
+
+func validSeries(series string) error {
+	if series == "" || strings.IndexByte(series, 0) >= 0 {
+		return fmt.Errorf("invalid series name %q", series)
+	}
+	return nil
+}
+
+// seriesPrefix — P + kind + series + 0x00.
+func (ts *TimeSeries) seriesPrefix(kind, series string) []byte {
+	p := make([]byte, 0, len(ts.opts.Prefix)+len(kind)+len(series)+1)
+	p = append(p, ts.opts.Prefix...)
+	p = append(p, kind...)
+	p = append(p, series...)
+	return append(p, 0)
+}
+
+func rollupKind(step time.Duration) string {
+	return "r:" + step.String() + ":"
+}
+
+// ttlFrom — TTL записи, которая должна прожить retention от момента t; false — срок уже прошёл.
+func (ts *TimeSeries) ttlFrom(t time.Time, retention time.Duration) (time.Duration, bool) {
+	if retention <= 0 {
+		return 0, true
+	}
+	ttl := t.Add(retention).Sub(ts.store.clock.Now())
+	return ttl, ttl > 0
+}
+
+// Append дописывает точку. Точка с тем же временем перезаписывает предыдущую.
+// Точка старше Retention не сохраняется.
+func (ts *TimeSeries) Append(series string, t time.Time, value float64) error {
+	if err := validSeries(series); err != nil {
+		return err
+	}
+	ttl, ok := ts.ttlFrom(t, ts.opts.Retention)
+	if !ok {
+		return nil
+	}
+	key := append(ts.seriesPrefix("raw:", series), reverseTimestamp(t)...)
+	val := binary.BigEndian.AppendUint64(nil, math.Float64bits(value))
+	return ts.store.db.Update(func(txn *badger.Txn) error {
+		return txn.SetEntry(ts.store.NewEntry(key, val, ttl))
+	})
+}
+
+// scanRange обходит записи kind/series с временем в [from, to] в порядке убывания времени.
+func (ts *TimeSeries) scanRange(kind, series string, from, to time.Time, fn func(t time.Time, val []byte) error) error {
+	prefix := ts.seriesPrefix(kind, series)
+	start := append(append([]byte(nil), prefix...), reverseTimestamp(to)...)
+	return ts.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = prefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(start); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if ts.store.expired(item) {
+				continue
+			}
+			t := parseReverseTimestamp(item.Key()[len(prefix):])
+			if t.Before(from) {
+				return nil
+			}
+			if err := item.Value(func(val []byte) error {
+				return fn(t, val)
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Query возвращает точки ряда в [from, to] по возрастанию времени.
+// step > 0 — точки усредняются по интервалам [from+k*step, from+(k+1)*step), время точки — начало интервала;
+// пустые интервалы пропускаются. step <= 0 — сырые точки.
+func (ts *TimeSeries) Query(series string, from, to time.Time, step time.Duration) ([]TimePoint, error) {
+	if err := validSeries(series); err != nil {
+		return nil, err
+	}
+	var raw []TimePoint
+	err := ts.scanRange("raw:", series, from, to, func(t time.Time, val []byte) error {
+		if len(val) != 8 {
+			return fmt.Errorf("series %q: corrupted sample at %s", series, t)
+		}
+		raw = append(raw, TimePoint{T: t, Value: math.Float64frombits(binary.BigEndian.Uint64(val))})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(raw)-1; i < j; i, j = i+1, j-1 {
+		raw[i], raw[j] = raw[j], raw[i]
+	}
+	if step <= 0 || len(raw) == 0 {
+		return raw, nil
+	}
+
+	out := make([]TimePoint, 0)
+	var bucket time.Time
+	var sum float64
+	var n int
+	flush := func() {
+		if n > 0 {
+			out = append(out, TimePoint{T: bucket, Value: sum / float64(n)})
+		}
+	}
+	for _, p := range raw {
+		b := from.Add(p.T.Sub(from) / step * step)
+		if n == 0 || !b.Equal(bucket) {
+			flush()
+			bucket, sum, n = b, 0, 0
+		}
+		sum += p.Value
+		n++
+	}
+	flush()
+	return out, nil
+}
+
+// QueryRollup возвращает свёртки ряда с шагом step, начало интервала которых лежит в [from, to].
+func (ts *TimeSeries) QueryRollup(series string, step time.Duration, from, to time.Time) ([]RollupPoint, error) {
+	if err := validSeries(series); err != nil {
+		return nil, err
+	}
+	var out []RollupPoint
+	err := ts.scanRange(rollupKind(step), series, from, to, func(t time.Time, val []byte) error {
+		p, err := decodeRollup(t, val)
+		if err != nil {
+			return fmt.Errorf("series %q: %w", series, err)
+		}
+		out = append(out, p)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+func encodeRollup(p RollupPoint) []byte {
+	b := make([]byte, 0, 32)
+	b = binary.BigEndian.AppendUint64(b, p.Count)
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(p.Sum))
+	b = binary.BigEndian.AppendUint64(b, math.Float64bits(p.Min))
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(p.Max))
+}
+
+func decodeRollup(t time.Time, b []byte) (RollupPoint, error) {
+	if len(b) != 32 {
+		return RollupPoint{}, errors.New("corrupted rollup")
+	}
+	return RollupPoint{
+		T:     t,
+		Count: binary.BigEndian.Uint64(b[0:]),
+		Sum:   math.Float64frombits(binary.BigEndian.Uint64(b[8:])),
+		Min:   math.Float64frombits(binary.BigEndian.Uint64(b[16:])),
+		Max:   math.Float64frombits(binary.BigEndian.Uint64(b[24:])),
+	}, nil
+}
+
+// Series возвращает имена всех рядов с сырыми точками (перескакивая между рядами, а не читая все точки).
+func (ts *TimeSeries) Series() ([]string, error) {
+	root := []byte(ts.opts.Prefix + "raw:")
+	var out []string
+	err := ts.store.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = root
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		for it.Seek(root); it.ValidForPrefix(root); {
+			rest := it.Item().Key()[len(root):]
+			end := bytes.IndexByte(rest, 0)
+			if end < 0 {
+				it.Next()
+				continue
+			}
+			out = append(out, string(rest[:end]))
+			// следующий ряд: series + 0x01 больше любого ключа series + 0x00 + ...
+			next := append(append(append([]byte(nil), root...), rest[:end]...), 1)
+			it.Seek(next)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// Downsample сворачивает сырые точки ряда в интервалы rule.Step, закончившиеся не позже
+// now - rule.Delay и ещё не свёрнутые. Возвращает число записанных свёрток.
+func (ts *TimeSeries) Downsample(ctx context.Context, series string, rule RollupRule) (int, error) {
+	if err := validSeries(series); err != nil {
+		return 0, err
+	}
+	if rule.Step <= 0 {
+		return 0, errors.New("rollup step must be positive")
+	}
+	stateKey := []byte(ts.opts.Prefix + "state:" + rule.Step.String() + ":" + series)
+	limit := ts.store.clock.Now().Add(-rule.Delay).Truncate(rule.Step)
+
+	var next time.Time
+	err := ts.store.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(stateKey)
+		if errors.Is(err, badger.ErrKeyNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			if len(val) == 8 {
+				next = time.Unix(0, int64(binary.BigEndian.Uint64(val)))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// без сохранённого состояния сворачивается всё, начиная с самой старой точки
+	var rollups []RollupPoint
+	err = ts.scanRange("raw:", series, next, limit.Add(-1), func(t time.Time, val []byte) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(val) != 8 {
+			return nil
+		}
+		v := math.Float64frombits(binary.BigEndian.Uint64(val))
+		b := t.Truncate(rule.Step)
+		if n := len(rollups); n > 0 && rollups[n-1].T.Equal(b) {
+			p := &rollups[n-1]
+			p.Count++
+			p.Sum += v
+			p.Min = math.Min(p.Min, v)
+			p.Max = math.Max(p.Max, v)
+			return nil
+		}
+		rollups = append(rollups, RollupPoint{T: b, Count: 1, Sum: v, Min: v, Max: v})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	prefix := ts.seriesPrefix(rollupKind(rule.Step), series)
+	wb := ts.store.db.NewWriteBatch()
+	defer wb.Cancel()
+	written := 0
+	for _, p := range rollups {
+		ttl, ok := ts.ttlFrom(p.T, rule.Retention)
+		if !ok {
+			continue
+		}
+		key := append(append([]byte(nil), prefix...), reverseTimestamp(p.T)...)
+		if err := wb.SetEntry(ts.store.NewEntry(key, encodeRollup(p), ttl)); err != nil {
+			return 0, err
+		}
+		written++
+	}
+	if err := wb.SetEntry(badger.NewEntry(stateKey, binary.BigEndian.AppendUint64(nil, uint64(limit.UnixNano())))); err != nil {
+		return 0, err
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	return written, nil
+}
+
+// RunDownsampling периодически (раз в минимальный Step правил, по часам Store) сворачивает все ряды
+// по всем правилам. Блокируется до отмены ctx; onError получает ошибки раундов и может быть nil.
+func (ts *TimeSeries) RunDownsampling(ctx context.Context, rules []RollupRule, onError func(error)) error {
+	if len(rules) == 0 {
+		return errors.New("no rollup rules")
+	}
+	interval := rules[0].Step
+	for _, r := range rules {
+		if r.Step <= 0 {
+			return errors.New("rollup step must be positive")
+		}
+		interval = min(interval, r.Step)
+	}
+	ticker := ts.store.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := ts.downsampleAll(ctx, rules); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+		}
+	}
+}
+
+func (ts *TimeSeries) downsampleAll(ctx context.Context, rules []RollupRule) error {
+	series, err := ts.Series()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, s := range series {
+		for _, r := range rules {
+			if _, err := ts.Downsample(ctx, s, r); err != nil {
+				errs = append(errs, fmt.Errorf("downsample %q by %s: %w", s, r.Step, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -21,12 +21,37 @@ type Manager struct {
 	maxRetries  int
 	baseBackoff time.Duration
 	maxBackoff  time.Duration
+	commitFault func(attempt int) error
+	locker      *KeyLocker
+	lockKeys    func(ctx context.Context) [][]byte
+
+	counters txCounters
+	diag     *conflictDiag
 }
 
 type TxManagerOptions struct {
 	MaxRetries  int
 	BaseBackoff time.Duration
 	MaxBackoff  time.Duration
+
+	// CommitFault — инъекция ошибок коммита для детерминированных тестов (см. storetest.ConflictSchedule).
+	// Вызывается перед каждым коммитом с номером попытки (с 1); badger.ErrConflict обрабатывается как
+	// настоящий конфликт (откат и повтор с паузой по Clock хранилища), другая ошибка откатывает
+	// транзакцию и возвращается. nil — без инъекций.
+	CommitFault func(attempt int) error
+
+	// ConflictSampleRate — доля попыток (0..1), для которых собираются ключи, чтобы при конфликте
+	// посчитать их в Stats().TopConflictKeys. 0 — диагностика выключена.
+	ConflictSampleRate float64
+
+	// LockKeysFn возвращает ключи, которые транзакция держит заблокированными в KeyLocker на всё
+	// выполнение, включая повторы: писатели одних ключей выполняются по очереди. Полезно при
+	// DetectConflicts=false, когда Badger конфликты не ловит. Готовая функция — TxLockKeys
+	// (ключи из WithTxLockKeys). nil — без блокировок.
+	LockKeysFn func(ctx context.Context) [][]byte
+	// KeyLocker — блокировщик для LockKeysFn. Чтобы блокировки действовали между менеджерами,
+	// передайте им общий; nil — собственный NewKeyLocker(0) у каждого менеджера.
+	KeyLocker *KeyLocker
 }
 
 func NewTransactionManager(store *Store, opts ...TxManagerOptions) *Manager {
@@ -45,22 +70,59 @@ func NewTransactionManager(store *Store, opts ...TxManagerOptions) *Manager {
 		if opts[0].MaxBackoff > 0 {
 			o.MaxBackoff = opts[0].MaxBackoff
 		}
+		o.CommitFault = opts[0].CommitFault
+		o.ConflictSampleRate = opts[0].ConflictSampleRate
+		o.LockKeysFn = opts[0].LockKeysFn
+		o.KeyLocker = opts[0].KeyLocker
 	}
-	return &Manager{
+	m := &Manager{
 		store:       store,
 		maxRetries:  o.MaxRetries,
 		baseBackoff: o.BaseBackoff,
 		maxBackoff:  o.MaxBackoff,
+		commitFault: o.CommitFault,
+	}
+	if o.LockKeysFn != nil {
+		m.lockKeys, m.locker = o.LockKeysFn, o.KeyLocker
+		if m.locker == nil {
+			m.locker = NewKeyLocker(0)
+		}
+	}
+	if o.ConflictSampleRate > 0 {
+		m.diag = &conflictDiag{rate: o.ConflictSampleRate, counts: make(map[string]uint64)}
 	}
+	return m
 }
 
+// ExecuteReadWriteWithContext выполняет action в транзакции чтения-записи и повторяет её после
+// badger.ErrConflict (до MaxRetries раз). Метаданные ctx (WithRequestID, WithPrincipal) попадают
+// в OpTrace для Options.OnTx/OnSlowOp и в *OpError.
 func (m *Manager) ExecuteReadWriteWithContext(ctx context.Context, action RWTx) error {
+	start := m.store.clock.Now()
+	m.counters.transactions.Add(1)
+	attempts, err := m.execute(ctx, action)
+	m.store.record(RecordTx, nil, nil, attempts, 0, start, err)
+	return m.store.traceOp(ctx, "tx", nil, start, attempts, err)
+}
+
+// execute возвращает число начатых попыток и результат последней.
+func (m *Manager) execute(ctx context.Context, action RWTx) (int, error) {
+	if m.lockKeys != nil {
+		if keys := m.lockKeys(ctx); len(keys) > 0 {
+			unlock, err := m.locker.LockKeys(ctx, keys...)
+			if err != nil {
+				return 0, err
+			}
+			defer unlock()
+		}
+	}
 	for attempt := 0; ; attempt++ {
 		if err := ctx.Err(); err != nil {
-			return err
+			return attempt, err
 		}
 
 		tx := m.store.db.NewTransaction(true)
+		attemptCtx, keys := m.diag.sample(ctx)
 
 		runErr := func() (err error) {
 			defer func() {
@@ -68,37 +130,60 @@ func (m *Manager) ExecuteReadWriteWithContext(ctx context.Context, action RWTx)
 					err = fmt.Errorf("panic in RW txn: %v", p)
 				}
 			}()
-			return action(ctx, tx)
+			return action(attemptCtx, tx)
 		}()
 
 		if runErr != nil {
 			tx.Discard()
-			return runErr
+			return attempt + 1, runErr
 		}
 
 		if err := ctx.Err(); err != nil {
 			tx.Discard()
-			return err
+			return attempt + 1, err
 		}
 
-		if err := tx.Commit(); err != nil {
+		m.counters.attempts.Add(1)
+		if err := m.commit(tx, attempt+1); err != nil {
+			if errors.Is(err, badger.ErrConflict) {
+				m.counters.conflicts.Add(1)
+				m.diag.conflict(keys)
+				if attempt >= m.maxRetries {
+					m.counters.exhausted.Add(1)
+				}
+			}
 			if errors.Is(err, badger.ErrConflict) && attempt < m.maxRetries {
 				tx.Discard()
-				if serr := sleepWithJitter(ctx, m.baseBackoff, m.maxBackoff, attempt+1); serr != nil {
-					return serr
+				if serr := sleepWithJitter(ctx, m.store.clock, m.baseBackoff, m.maxBackoff, attempt+1); serr != nil {
+					return attempt + 1, serr
 				}
 				continue
 			}
 			tx.Discard()
-			return err
+			return attempt + 1, err
 		}
 
-		return nil
+		return attempt + 1, nil
+	}
+}
+
+func (m *Manager) commit(tx *badger.Txn, attempt int) error {
+	if m.commitFault != nil {
+		if err := m.commitFault(attempt); err != nil {
+			return err
+		}
 	}
+	start := m.store.clock.Now()
+	err := tx.Commit()
+	m.store.latency.commit.observe(m.store.clock.Now().Sub(start))
+	return err
 }
 
 func (s *Store) TxSetObject(tx *badger.Txn, key []byte, v any) error {
-	data, err := s.Marshal(v)
+	if err := s.validateKey(context.Background(), key); err != nil {
+		return err
+	}
+	data, _, err := s.txMarshalObject(tx, key, v)
 	if err != nil {
 		return err
 	}
@@ -110,12 +195,16 @@ func (s *Store) TxGetObject(tx *badger.Txn, key []byte, v any) error {
 	if err != nil {
 		return err
 	}
+	if s.expired(item) {
+		return ErrNotFound
+	}
+	s.noteRead(key)
 	return item.Value(func(val []byte) error {
-		return s.Unmarshal(val, v)
+		return s.decode(key, val, v)
 	})
 }
 
-func sleepWithJitter(ctx context.Context, base, max time.Duration, attempt int) error {
+func sleepWithJitter(ctx context.Context, clock Clock, base, max time.Duration, attempt int) error {
 	if attempt < 1 {
 		attempt = 1
 	}
@@ -133,12 +222,12 @@ func sleepWithJitter(ctx context.Context, base, max time.Duration, attempt int)
 		}
 	}
 
-	t := time.NewTimer(backoff)
+	t := clock.NewTimer(backoff)
 	defer t.Stop()
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case <-t.C:
+	case <-t.C():
 		return nil
 	}
 }
@@ -0,0 +1,83 @@
+package sdk
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Разброс TTL: ключи, записанные одной пачкой с одинаковым TTL, истекают в одну секунду, и все
+// пересчёты кеша приходят разом. Options.TTLJitter (для отдельных записей — WithTTLJitter) сдвигает
+// каждый TTL на случайную долю в пределах ±N. Служебные TTL (сессии, лимитеры, идемпотентность,
+// реплики raftstore) не разбрасываются. Badger хранит срок с точностью до секунды, поэтому разброс
+// TTL короче нескольких секунд почти не заметен.
+
+type ttlJitterKey struct{}
+
+// WithTTLJitter задаёт разброс TTL записей с этим контекстом (доля в [0, 1): 0.1 — ±10%) вместо
+// Options.TTLJitter; 0 — без разброса.
+func WithTTLJitter(ctx context.Context, fraction float64) context.Context {
+	return context.WithValue(ctx, ttlJitterKey{}, min(max(fraction, 0), 0.99))
+}
+
+// jitterTTL сдвигает ttl на случайную долю разброса из ctx или Options.TTLJitter, но не ниже секунды.
+func (s *Store) jitterTTL(ctx context.Context, ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		return ttl
+	}
+	j := s.ttlJitter
+	if v, ok := ctx.Value(ttlJitterKey{}).(float64); ok {
+		j = v
+	}
+	if j <= 0 {
+		return ttl
+	}
+	delta := time.Duration(float64(ttl) * j * (2*rand.Float64() - 1))
+	return max(ttl+delta, time.Second)
+}
+
+// ValueMeta — метаданные значения, прочитанного GetWithMeta.
+type ValueMeta struct {
+	// ExpiresAt — фактический срок записи (с разбросом TTL); нулевое — без TTL.
+	ExpiresAt time.Time
+	// TTL — сколько осталось до истечения по часам Store; 0 — без TTL.
+	TTL time.Duration
+	// Version — версия Badger (commit timestamp) последней записи.
+	Version uint64
+}
+
+// GetWithMeta — Get, дополнительно возвращающий фактический срок жизни ключа.
+func (s *Store) GetWithMeta(ctx context.Context, key []byte) ([]byte, ValueMeta, error) {
+	if err := s.checkAccess(ctx, AccessRead, key); err != nil {
+		return nil, ValueMeta{}, err
+	}
+	var out []byte
+	var meta ValueMeta
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		if s.expired(item) {
+			return ErrNotFound
+		}
+		s.noteRead(key)
+		meta.Version = item.Version()
+		if exp := item.ExpiresAt(); exp > 0 {
+			meta.ExpiresAt = time.Unix(int64(exp), 0)
+			meta.TTL = meta.ExpiresAt.Sub(s.clock.Now())
+		}
+		out, err = item.ValueCopy(nil)
+		return err
+	})
+	return out, meta, err
+}
+
+// GetWithTTL возвращает значение и оставшийся срок жизни ключа по часам Store (0 — без TTL),
+// например чтобы продлить ключ, пока срок не подошёл к концу.
+func (s *Store) GetWithTTL(key []byte) ([]byte, time.Duration, error) {
+	out, meta, err := s.GetWithMeta(context.Background(), key)
+	return out, meta.TTL, err
+}
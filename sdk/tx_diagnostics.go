@@ -0,0 +1,134 @@
+package sdk
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Диагностика конфликтов транзакций Manager: при TxManagerOptions.ConflictSampleRate > 0 для выборки
+// попыток собирается множество затронутых ключей (чтения и записи), и если попытка завершилась
+// badger.ErrConflict, счётчики этих ключей растут. Stats показывает самые конфликтные ключи.
+//
+// Badger не раскрывает множества ключей транзакции, поэтому ключи собираются на уровне SDK:
+// автоматически для операций Tx в RunTx, а в ExecuteReadWriteWithContext — через NoteTxKey.
+
+// conflictKeysCap — предел числа отслеживаемых ключей; при переполнении счётчики делятся пополам
+// и нулевые выбрасываются, так что остаются устойчиво горячие ключи.
+const conflictKeysCap = 10000
+
+// KeyConflicts — ключ и число конфликтов с его участием.
+type KeyConflicts struct {
+	Key       string
+	Conflicts uint64
+}
+
+// TxStats — счётчики Manager.
+type TxStats struct {
+	// Transactions — вызовы ExecuteReadWriteWithContext/RunTx.
+	Transactions uint64
+	// Attempts — попытки коммита, включая повторы.
+	Attempts  uint64
+	Conflicts uint64
+	// Exhausted — транзакции, завершившиеся ErrConflict после всех повторов.
+	Exhausted uint64
+	// TopConflictKeys — ключи с наибольшим числом конфликтов (по выборке, при ConflictSampleRate > 0).
+	TopConflictKeys []KeyConflicts
+}
+
+type txCounters struct {
+	transactions atomic.Uint64
+	attempts     atomic.Uint64
+	conflicts    atomic.Uint64
+	exhausted    atomic.Uint64
+}
+
+type conflictDiag struct {
+	rate float64
+
+	mu     sync.Mutex
+	counts map[string]uint64
+}
+
+type txKeySet struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+type txKeySetKey struct{}
+
+// NoteTxKey отмечает key как прочитанный или записанный в текущей попытке транзакции для диагностики
+// конфликтов. ctx — контекст, переданный в RWTx; вне выборки и без диагностики ничего не делает.
+// Операции Tx в RunTx отмечаются автоматически.
+func NoteTxKey(ctx context.Context, key []byte) {
+	ks, _ := ctx.Value(txKeySetKey{}).(*txKeySet)
+	if ks == nil {
+		return
+	}
+	ks.mu.Lock()
+	ks.keys[string(key)] = struct{}{}
+	ks.mu.Unlock()
+}
+
+// sample возвращает контекст попытки с множеством ключей, если попытка попала в выборку.
+func (d *conflictDiag) sample(ctx context.Context) (context.Context, *txKeySet) {
+	if d == nil || rand.Float64() >= d.rate {
+		return ctx, nil
+	}
+	ks := &txKeySet{keys: make(map[string]struct{})}
+	return context.WithValue(ctx, txKeySetKey{}, ks), ks
+}
+
+func (d *conflictDiag) conflict(ks *txKeySet) {
+	if ks == nil {
+		return
+	}
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for k := range ks.keys {
+		d.counts[k]++
+	}
+	if len(d.counts) > conflictKeysCap {
+		for k, n := range d.counts {
+			if n /= 2; n == 0 {
+				delete(d.counts, k)
+			} else {
+				d.counts[k] = n
+			}
+		}
+	}
+}
+
+func (d *conflictDiag) top(n int) []KeyConflicts {
+	if d == nil || n <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	out := make([]KeyConflicts, 0, len(d.counts))
+	for k, c := range d.counts {
+		out = append(out, KeyConflicts{Key: k, Conflicts: c})
+	}
+	d.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Conflicts != out[j].Conflicts {
+			return out[i].Conflicts > out[j].Conflicts
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out[:min(n, len(out))]
+}
+
+// Stats возвращает счётчики менеджера и до topN самых конфликтных ключей.
+func (m *Manager) Stats(topN int) TxStats {
+	return TxStats{
+		Transactions:    m.counters.transactions.Load(),
+		Attempts:        m.counters.attempts.Load(),
+		Conflicts:       m.counters.conflicts.Load(),
+		Exhausted:       m.counters.exhausted.Load(),
+		TopConflictKeys: m.diag.top(topN),
+	}
+}
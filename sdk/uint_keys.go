@@ -0,0 +1,131 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Ключи-числа. Badger сравнивает ключи побайтно, поэтому uint64 в десятичной строке ("10" < "9")
+// или little-endian ломает порядок и диапазонные сканы. Идентификатор кодируется 8 байтами
+// big-endian — так же, как byte8 во внутренних хранилищах, — и порядок ключей совпадает с порядком чисел.
+
+// EncodeUint64Key возвращает prefix + id (8 байт big-endian).
+func EncodeUint64Key(prefix []byte, id uint64) []byte {
+	key := make([]byte, 0, len(prefix)+8)
+	return binary.BigEndian.AppendUint64(append(key, prefix...), id)
+}
+
+// DecodeUint64Key разбирает ключ EncodeUint64Key; false — ключ не из prefix или не 8 байт после него.
+func DecodeUint64Key(prefix, key []byte) (uint64, bool) {
+	if len(key) != len(prefix)+8 || !bytes.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	return binary.BigEndian.Uint64(key[len(prefix):]), true
+}
+
+// UintKeyStore — Store с ключами uint64 под общим префиксом. Ключи под префиксом, не являющиеся
+// 8-байтовыми числами, сканами пропускаются.
+type UintKeyStore struct {
+	store  *Store
+	prefix []byte
+}
+
+func NewUintKeyStore(store *Store, prefix []byte) *UintKeyStore {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	return &UintKeyStore{store: store, prefix: append([]byte(nil), prefix...)}
+}
+
+// Prefix возвращает префикс ключей.
+func (u *UintKeyStore) Prefix() []byte {
+	return u.prefix
+}
+
+func (u *UintKeyStore) Key(id uint64) []byte {
+	return EncodeUint64Key(u.prefix, id)
+}
+
+func (u *UintKeyStore) Set(ctx context.Context, id uint64, value []byte, ttl time.Duration) error {
+	return u.store.SetContext(ctx, u.Key(id), value, ttl)
+}
+
+func (u *UintKeyStore) Get(ctx context.Context, id uint64) ([]byte, error) {
+	return u.store.GetContext(ctx, u.Key(id))
+}
+
+func (u *UintKeyStore) Delete(ctx context.Context, id uint64) error {
+	return u.store.DeleteContext(ctx, u.Key(id))
+}
+
+// Range вызывает fn для ключей полуинтервала [lo, hi) по возрастанию id; hi == 0 — до конца префикса.
+// Ошибка fn прерывает скан и возвращается как есть.
+func (u *UintKeyStore) Range(ctx context.Context, lo, hi uint64, fn func(id uint64, value []byte) error) error {
+	return u.scan(ctx, lo, hi, true, func(id uint64, item *badger.Item) error {
+		return item.Value(func(val []byte) error {
+			return fn(id, val)
+		})
+	})
+}
+
+// IDs вызывает fn для id полуинтервала [lo, hi) без чтения значений; hi == 0 — до конца префикса.
+func (u *UintKeyStore) IDs(ctx context.Context, lo, hi uint64, fn func(id uint64) error) error {
+	return u.scan(ctx, lo, hi, false, func(id uint64, _ *badger.Item) error {
+		return fn(id)
+	})
+}
+
+// Count возвращает число живых ключей полуинтервала [lo, hi).
+func (u *UintKeyStore) Count(ctx context.Context, lo, hi uint64) (uint64, error) {
+	var n uint64
+	err := u.IDs(ctx, lo, hi, func(uint64) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func (u *UintKeyStore) scan(ctx context.Context, lo, hi uint64, values bool, fn func(id uint64, item *badger.Item) error) error {
+	s := u.store
+	if err := s.checkAccess(ctx, AccessScan, u.prefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(ctx, "uint_range")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = u.prefix
+		opts.PrefetchValues = values
+
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(u.Key(lo)); it.ValidForPrefix(u.prefix); it.Next() {
+			item := it.Item()
+			id, ok := DecodeUint64Key(u.prefix, item.Key())
+			if !ok {
+				continue
+			}
+			if hi != 0 && id >= hi {
+				return nil
+			}
+			if s.expired(item) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			if err := fn(id, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,209 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Подбор ValueThreshold по фактическим размерам значений. Значение меньше порога хранится в LSM рядом
+// с ключом: чтение — одно обращение, но компакции переписывают его снова и снова. Значение не меньше
+// порога уходит в value-log, а в LSM остаётся указатель: LSM меньше и лучше кешируется, но чтение
+// идёт в два места, а место возвращает только GC value-log.
+//
+// Рекомендация — наибольший порог, при котором LSM укладывается в бюджет (по умолчанию блочный кеш):
+// тогда малые значения читаются из памяти, а крупные не раздувают LSM и компакции.
+
+// valueThresholdFile — файл в Options.Dir с порогом, сохранённым SaveValueThreshold.
+const valueThresholdFile = "sdk_value_threshold"
+
+// Оценочные накладные расходы записи Badger в байтах.
+const (
+	lsmEntryOverhead  = 8 + 10 // версия в ключе и заголовок/метаданные записи
+	valuePointerSize  = 12
+	vlogEntryOverhead = 10 + 8 + 4 // заголовок, версия, crc32
+	maxValueThreshold = 1 << 20    // предел Badger
+)
+
+// ValueThresholdOptions — параметры ValueThresholdReport.
+type ValueThresholdOptions struct {
+	// LSMBudget — желаемый предел размера LSM в байтах; 0 — размер блочного кеша (или 256 MiB).
+	LSMBudget int64
+	// MaxKeys — сколько ключей просмотреть; 0 — все. Отчёт тогда описывает только просмотренные.
+	MaxKeys int
+	// Prefix возвращает группу ключа для Prefixes; по умолчанию — до первого ':' включительно.
+	Prefix func(key []byte) string
+}
+
+// ThresholdEstimate — ожидаемое распределение данных при пороге Threshold.
+type ThresholdEstimate struct {
+	Threshold int64
+	// InlineFraction — доля значений, остающихся в LSM.
+	InlineFraction float64
+	LSMBytes       int64
+	VlogBytes      int64
+}
+
+// PrefixValueSizes — размеры значений группы ключей.
+type PrefixValueSizes struct {
+	Prefix        string
+	Keys          int64
+	Bytes         int64
+	P50, P90, P99 int64
+	Max           int64
+}
+
+// ValueThresholdReport — результат ValueThresholdReport.
+type ValueThresholdReport struct {
+	Keys      int64
+	Truncated bool
+	LSMBudget int64
+	// Current — распределение при текущем пороге, Recommended — при рекомендуемом.
+	Current     ThresholdEstimate
+	Recommended ThresholdEstimate
+	// Candidates — оценки для порогов-степеней двойки от 32 Б до 1 МиБ.
+	Candidates []ThresholdEstimate
+	// Prefixes — по убыванию объёма значений.
+	Prefixes []PrefixValueSizes
+}
+
+// ValueThresholdReport собирает размеры значений (без чтения самих значений) и рекомендует ValueThreshold.
+// Применить рекомендацию можно через SaveValueThreshold и Options.AutoValueThreshold.
+func (s *Store) ValueThresholdReport(ctx context.Context, opts ValueThresholdOptions) (ValueThresholdReport, error) {
+	if opts.Prefix == nil {
+		opts.Prefix = firstSegment
+	}
+	if opts.LSMBudget <= 0 {
+		opts.LSMBudget = s.db.Opts().BlockCacheSize
+		if opts.LSMBudget <= 0 {
+			opts.LSMBudget = 256 << 20
+		}
+	}
+	rep := ValueThresholdReport{LSMBudget: opts.LSMBudget}
+
+	type entry struct{ key, value int64 }
+	var entries []entry
+	groups := make(map[string][]int64)
+	err := s.db.View(func(txn *badger.Txn) error {
+		io := badger.DefaultIteratorOptions
+		io.PrefetchValues = false
+		it := txn.NewIterator(io)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			if opts.MaxKeys > 0 && len(entries) >= opts.MaxKeys {
+				rep.Truncated = true
+				return nil
+			}
+			if len(entries)%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			item := it.Item()
+			if s.expired(item) {
+				continue
+			}
+			e := entry{key: int64(len(item.Key())), value: item.ValueSize()}
+			entries = append(entries, e)
+			p := opts.Prefix(item.Key())
+			groups[p] = append(groups[p], e.value)
+		}
+		return nil
+	})
+	if err != nil {
+		return rep, err
+	}
+	rep.Keys = int64(len(entries))
+
+	estimate := func(t int64) ThresholdEstimate {
+		est := ThresholdEstimate{Threshold: t}
+		inline := 0
+		for _, e := range entries {
+			est.LSMBytes += e.key + lsmEntryOverhead
+			if e.value < t {
+				inline++
+				est.LSMBytes += e.value
+			} else {
+				est.LSMBytes += valuePointerSize
+				est.VlogBytes += e.key + e.value + vlogEntryOverhead
+			}
+		}
+		if len(entries) > 0 {
+			est.InlineFraction = float64(inline) / float64(len(entries))
+		}
+		return est
+	}
+	rep.Current = estimate(s.db.Opts().ValueThreshold)
+	for t := int64(32); t <= maxValueThreshold; t <<= 1 {
+		rep.Candidates = append(rep.Candidates, estimate(t))
+	}
+	rep.Recommended = rep.Candidates[0]
+	for _, c := range rep.Candidates {
+		if c.LSMBytes > opts.LSMBudget {
+			break
+		}
+		rep.Recommended = c
+		if c.InlineFraction == 1 {
+			break // больший порог ничего не меняет
+		}
+	}
+
+	for p, sizes := range groups {
+		sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+		ps := PrefixValueSizes{Prefix: p, Keys: int64(len(sizes)), Max: sizes[len(sizes)-1]}
+		for _, v := range sizes {
+			ps.Bytes += v
+		}
+		q := func(f float64) int64 { return sizes[int(f*float64(len(sizes)-1))] }
+		ps.P50, ps.P90, ps.P99 = q(0.5), q(0.9), q(0.99)
+		rep.Prefixes = append(rep.Prefixes, ps)
+	}
+	sort.Slice(rep.Prefixes, func(i, j int) bool {
+		if rep.Prefixes[i].Bytes != rep.Prefixes[j].Bytes {
+			return rep.Prefixes[i].Bytes > rep.Prefixes[j].Bytes
+		}
+		return rep.Prefixes[i].Prefix < rep.Prefixes[j].Prefix
+	})
+	return rep, nil
+}
+
+// SaveValueThreshold сохраняет порог в каталоге хранилища; при Options.AutoValueThreshold его
+// применит следующий Open. Уже записанные значения остаются на месте, новый порог действует
+// на новые записи (и на переписанные GC value-log).
+func (s *Store) SaveValueThreshold(threshold int64) error {
+	if threshold <= 0 || threshold > maxValueThreshold {
+		return fmt.Errorf("value threshold must be in (0, %d], got %d", maxValueThreshold, threshold)
+	}
+	if s.db.Opts().InMemory {
+		return errors.New("value threshold cannot be saved for in-memory store")
+	}
+	path := filepath.Join(s.db.Opts().Dir, valueThresholdFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(threshold, 10)+"\n"), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// loadValueThreshold читает порог SaveValueThreshold; 0 — не сохранён.
+func loadValueThreshold(dir string) (int64, error) {
+	b, err := os.ReadFile(filepath.Join(dir, valueThresholdFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	t, err := strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	if err != nil || t <= 0 || t > maxValueThreshold {
+		return 0, fmt.Errorf("%s: bad value threshold %q", valueThresholdFile, strings.TrimSpace(string(b)))
+	}
+	return t, nil
+}
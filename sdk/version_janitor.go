@@ -0,0 +1,238 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Уборка старых версий схемы. По соглашению ключи сущности версионируются префиксом
+// "<сущность>:v<N>:" (user:v1:..., user:v2:...); после миграции на новую версию старые ключи
+// остаются в хранилище. VersionJanitor удаляет ключи версий, которые не активны, партиями с
+// ограничением скорости.
+//
+// Защита от ошибок конфигурации: версии из Protected не удаляются никогда, версии новее самой
+// новой активной тоже (это выкатка, о которой janitor ещё не знает), ключи без метки версии не трогаются.
+
+// VersionJanitorOptions — параметры NewVersionJanitor.
+type VersionJanitorOptions struct {
+	// Active — активные версии сущностей: "user" → {"v3"}. Удаляются только ключи перечисленных
+	// сущностей с версией не из списка.
+	Active map[string][]string
+	// Protected — префиксы версий, которые нельзя удалять, например "user:v2:".
+	Protected []string
+	// BatchSize — удалений в одной записи, по умолчанию 1000.
+	BatchSize int
+	// RateLimit — лимит удалений в ключах в секунду; нулевой — без ограничения.
+	RateLimit RateLimit
+	// DryRun — только посчитать, что было бы удалено.
+	DryRun bool
+}
+
+// RetiredVersion — ключи одной выведенной версии.
+type RetiredVersion struct {
+	Prefix string
+	Keys   int64
+	// Bytes — оценка места (ключи и значения); на диске оно освобождается после компакций и GC value-log.
+	Bytes int64
+}
+
+// JanitorReport — итог прохода VersionJanitor.
+type JanitorReport struct {
+	// Retired — удалённые (при DryRun — найденные) версии.
+	Retired []RetiredVersion
+	// Kept — найденные неактивные версии, оставленные защитой: из Protected или новее активных.
+	Kept     []string
+	Deleted  int64
+	Bytes    int64
+	DryRun   bool
+	Duration time.Duration
+}
+
+// VersionJanitor удаляет ключи неактивных версий схемы.
+type VersionJanitor struct {
+	store     *Store
+	opts      VersionJanitorOptions
+	entities  []string
+	active    map[string]map[string]bool
+	newest    map[string]int
+	protected map[string]bool
+	limit     *throttle
+}
+
+var schemaVersion = regexp.MustCompile(`^v([0-9]+)$`)
+
+func NewVersionJanitor(store *Store, opts VersionJanitorOptions) (*VersionJanitor, error) {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	if len(opts.Active) == 0 {
+		return nil, errors.New("version janitor needs active versions")
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	j := &VersionJanitor{
+		store:     store,
+		opts:      opts,
+		active:    make(map[string]map[string]bool),
+		newest:    make(map[string]int),
+		protected: make(map[string]bool),
+		limit:     newThrottle(opts.RateLimit),
+	}
+	for entity, versions := range opts.Active {
+		if entity == "" || strings.Contains(entity, ":") {
+			return nil, fmt.Errorf("bad entity %q", entity)
+		}
+		// пустой список сделал бы неактивными все версии сущности
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("entity %q has no active versions", entity)
+		}
+		j.entities = append(j.entities, entity)
+		j.active[entity] = make(map[string]bool)
+		for _, v := range versions {
+			n, ok := parseSchemaVersion(v)
+			if !ok {
+				return nil, fmt.Errorf("entity %q: bad version %q, want v<N>", entity, v)
+			}
+			j.active[entity][v] = true
+			j.newest[entity] = max(j.newest[entity], n)
+		}
+	}
+	sort.Strings(j.entities)
+	for _, p := range opts.Protected {
+		entity, v, ok := strings.Cut(strings.TrimSuffix(p, ":"), ":")
+		if _, vok := parseSchemaVersion(v); !ok || !vok || entity == "" {
+			return nil, fmt.Errorf("bad protected prefix %q, want <entity>:v<N>:", p)
+		}
+		j.protected[entity+":"+v+":"] = true
+	}
+	return j, nil
+}
+
+func parseSchemaVersion(v string) (int, bool) {
+	m := schemaVersion.FindStringSubmatch(v)
+	if m == nil {
+		return 0, false
+	}
+	n, err := strconv.Atoi(m[1])
+	return n, err == nil
+}
+
+// RunOnce проходит по ключам всех сущностей из Active и удаляет ключи неактивных версий.
+// Удаление пишется в журнал аудита.
+func (j *VersionJanitor) RunOnce(ctx context.Context) (JanitorReport, error) {
+	start := j.store.clock.Now()
+	rep := JanitorReport{DryRun: j.opts.DryRun}
+	var err error
+	if j.opts.DryRun {
+		err = j.sweep(ctx, &rep)
+	} else {
+		err = j.store.RunAudited(ctx, "version_janitor", strings.Join(j.entities, ","), func() error {
+			return j.sweep(ctx, &rep)
+		})
+	}
+	rep.Duration = j.store.clock.Now().Sub(start)
+	return rep, err
+}
+
+// Run вызывает RunOnce каждые interval (по часам Store) до отмены ctx. onRound получает итог
+// каждого прохода и может быть nil; ошибка прохода не останавливает цикл.
+func (j *VersionJanitor) Run(ctx context.Context, interval time.Duration, onRound func(JanitorReport, error)) {
+	ticker := j.store.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rep, err := j.RunOnce(ctx)
+		if onRound != nil && ctx.Err() == nil {
+			onRound(rep, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+func (j *VersionJanitor) sweep(ctx context.Context, rep *JanitorReport) error {
+	var wb *WriteBatch
+	if !j.opts.DryRun {
+		wb = j.store.NewWriteBatchContext(ctx, WriteBatchOptions{MaxCount: j.opts.BatchSize})
+		defer wb.Cancel()
+	}
+	for _, entity := range j.entities {
+		if err := j.sweepEntity(ctx, entity, wb, rep); err != nil {
+			return err
+		}
+	}
+	if wb == nil {
+		return nil
+	}
+	if err := wb.Flush(); err != nil {
+		return err
+	}
+	rep.Deleted = int64(wb.Written())
+	return nil
+}
+
+func (j *VersionJanitor) sweepEntity(ctx context.Context, entity string, wb *WriteBatch, rep *JanitorReport) error {
+	prefix := []byte(entity + ":")
+	return j.store.db.View(func(txn *badger.Txn) error {
+		iopt := badger.DefaultIteratorOptions
+		iopt.PrefetchValues = false
+		iopt.Prefix = prefix
+		it := txn.NewIterator(iopt)
+		defer it.Close()
+		var cur *RetiredVersion
+		for it.Rewind(); it.ValidForPrefix(prefix); {
+			key := it.Item().Key()
+			rest := key[len(prefix):]
+			i := bytes.IndexByte(rest, ':')
+			if i < 0 {
+				it.Next()
+				continue
+			}
+			v := string(rest[:i])
+			vprefix := string(key[:len(prefix)+i+1])
+			n, ok := parseSchemaVersion(v)
+			if !ok || j.active[entity][v] || j.protected[vprefix] || n > j.newest[entity] {
+				if ok && !j.active[entity][v] {
+					rep.Kept = append(rep.Kept, vprefix)
+				}
+				// вся версия пропускается одним Seek: ';' следует за ':'
+				it.Seek(append([]byte(vprefix[:len(vprefix)-1]), ';'))
+				continue
+			}
+			if cur == nil || cur.Prefix != vprefix {
+				rep.Retired = append(rep.Retired, RetiredVersion{Prefix: vprefix})
+				cur = &rep.Retired[len(rep.Retired)-1]
+			}
+			size := it.Item().EstimatedSize()
+			cur.Keys++
+			cur.Bytes += size
+			rep.Bytes += size
+			if wb != nil {
+				if err := j.limit.wait(ctx); err != nil {
+					return err
+				}
+				if err := wb.Delete(it.Item().KeyCopy(nil)); err != nil {
+					return err
+				}
+			} else if cur.Keys%4096 == 0 {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+			}
+			it.Next()
+		}
+		return nil
+	})
+}
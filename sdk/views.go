@@ -0,0 +1,473 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Раскладка ключей представления name: viewsPrefix + name + ":d:" + ключ от mapFn — строки представления;
+// viewsPrefix + name + ":s:" + ключ источника — список строк, порождённых этим ключом источника
+// (нужен, чтобы при изменении/удалении источника удалить старые строки);
+// viewsPrefix + name + ":o:" + ключ строки — владельцы строки: ключи источников, отображающиеся в неё.
+// Строка удаляется, только когда у неё не остаётся живых владельцев; значение берётся от последнего
+// записавшего владельца. Строка истекает вместе с самым долгоживущим владельцем, список строк
+// источника — вместе с источником.
+var viewsPrefix = []byte("!view:")
+
+const viewRebuildBatch = 512
+
+// ViewMapFunc отображает запись источника в строки представления. Вызывается только для
+// существующих записей; пустой результат означает «запись не попадает в представление».
+// Несколько записей источника могут отображаться в одну строку: она живёт, пока в неё отображается
+// хотя бы одна из них, и хранит значение от последней записанной.
+type ViewMapFunc func(kv KV) []KV
+
+// ViewStats — состояние представления для метрик устаревания.
+type ViewStats struct {
+	Name string
+	// Running — представление обновляется инкрементально (запущен ViewRegistry.Run).
+	Running bool
+	// Applied — число применённых изменений источника с момента запуска.
+	Applied uint64
+	// Errors — число неудачных применений; после ошибки представление нужно перестроить.
+	Errors uint64
+	// LastAppliedVersion — версия Badger последнего применённого изменения.
+	LastAppliedVersion uint64
+	// LastAppliedAt — когда последнее изменение было применено.
+	LastAppliedAt time.Time
+	// Lag — задержка между получением последней пачки изменений и её применением.
+	Lag time.Duration
+	// LastRebuildAt — время последней полной перестройки.
+	LastRebuildAt time.Time
+}
+
+type view struct {
+	name         string
+	sourcePrefix []byte
+	dataPrefix   []byte
+	srcPrefix    []byte
+	ownerPrefix  []byte
+	mapFn        ViewMapFunc
+
+	mu sync.Mutex // сериализует инкрементальные применения и перестройку
+
+	applied       atomic.Uint64
+	errors        atomic.Uint64
+	lastVersion   atomic.Uint64
+	lastAppliedAt atomic.Int64
+	lag           atomic.Int64
+	lastRebuildAt atomic.Int64
+}
+
+// ViewRegistry — материализованные представления над префиксами Store.
+type ViewRegistry struct {
+	store   *Store
+	mu      sync.RWMutex
+	views   map[string]*view
+	running atomic.Bool
+}
+
+func NewViewRegistry(store *Store) *ViewRegistry {
+	if store == nil {
+		panic("store must be not nil")
+	}
+	return &ViewRegistry{store: store, views: make(map[string]*view)}
+}
+
+// RegisterView объявляет представление name над записями с префиксом sourcePrefix.
+// Регистрировать представления нужно до Run; существующие данные попадают в представление после Rebuild.
+func (r *ViewRegistry) RegisterView(name string, sourcePrefix []byte, mapFn ViewMapFunc) error {
+	if name == "" || bytes.IndexByte([]byte(name), ':') >= 0 {
+		return fmt.Errorf("invalid view name %q", name)
+	}
+	if mapFn == nil {
+		return errors.New("nil view map function")
+	}
+	if bytes.HasPrefix(viewsPrefix, sourcePrefix) || bytes.HasPrefix(sourcePrefix, viewsPrefix) {
+		return fmt.Errorf("view %q: source prefix %q overlaps view storage", name, sourcePrefix)
+	}
+	if r.running.Load() {
+		return errors.New("views must be registered before Run")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.views[name]; ok {
+		return fmt.Errorf("view %q already registered", name)
+	}
+	base := append(append([]byte(nil), viewsPrefix...), name...)
+	r.views[name] = &view{
+		name:         name,
+		sourcePrefix: append([]byte(nil), sourcePrefix...),
+		dataPrefix:   append(append([]byte(nil), base...), ":d:"...),
+		srcPrefix:    append(append([]byte(nil), base...), ":s:"...),
+		ownerPrefix:  append(append([]byte(nil), base...), ":o:"...),
+		mapFn:        mapFn,
+	}
+	return nil
+}
+
+// Run инкрементально поддерживает все представления по подписке на изменения источников.
+// Блокируется до отмены ctx. Изменения, сделанные пока Run не запущен, видны только после Rebuild.
+func (r *ViewRegistry) Run(ctx context.Context) error {
+	r.mu.RLock()
+	views := make([]*view, 0, len(r.views))
+	prefixes := make([][]byte, 0, len(r.views))
+	for _, v := range r.views {
+		views = append(views, v)
+		prefixes = append(prefixes, v.sourcePrefix)
+	}
+	r.mu.RUnlock()
+	if len(views) == 0 {
+		return errors.New("no views registered")
+	}
+
+	r.running.Store(true)
+	defer r.running.Store(false)
+	return r.store.Watch(ctx, prefixes, func(events []KVEvent) error {
+		received := r.store.clock.Now()
+		for _, v := range views {
+			matched := make([]KVEvent, 0, len(events))
+			for _, e := range events {
+				if bytes.HasPrefix(e.Key, v.sourcePrefix) {
+					matched = append(matched, e)
+				}
+			}
+			if len(matched) == 0 {
+				continue
+			}
+			v.mu.Lock()
+			err := r.applyEvents(v, matched)
+			v.mu.Unlock()
+			if err != nil {
+				// ошибка одного представления не должна останавливать остальные
+				v.errors.Add(1)
+				continue
+			}
+			now := r.store.clock.Now()
+			v.applied.Add(uint64(len(matched)))
+			v.lastVersion.Store(matched[len(matched)-1].Version)
+			v.lastAppliedAt.Store(now.UnixNano())
+			v.lag.Store(int64(now.Sub(received)))
+		}
+		return nil
+	})
+}
+
+func (r *ViewRegistry) applyEvents(v *view, events []KVEvent) error {
+	err := r.store.db.Update(func(txn *badger.Txn) error {
+		for _, e := range events {
+			if err := v.applyTxn(r.store, txn, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if !errors.Is(err, badger.ErrTxnTooBig) {
+		return err
+	}
+	for _, e := range events {
+		if err := r.store.db.Update(func(txn *badger.Txn) error {
+			return v.applyTxn(r.store, txn, e)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyTxn заменяет строки представления, порождённые ключом источника e.Key; пустое e.Value — удаление.
+// Строки, в которые отображаются и другие ключи источника, остаются за ними.
+func (v *view) applyTxn(s *Store, txn *badger.Txn, e KVEvent) error {
+	key, value := e.Key, e.Value
+	srcKey := append(append([]byte(nil), v.srcPrefix...), key...)
+	old, err := v.readKeyList(txn, srcKey)
+	if err != nil {
+		return err
+	}
+
+	var rows []KV
+	if len(value) > 0 {
+		rows = v.mapFn(KV{Key: key, Value: value})
+	}
+	keys := make([][]byte, 0, len(rows))
+	for _, row := range rows {
+		if err := v.putRow(s, txn, row.Key, &viewRowOwner{key: key, value: row.Value, expiresAt: e.ExpiresAt}); err != nil {
+			return err
+		}
+		keys = append(keys, row.Key)
+	}
+	for _, k := range old {
+		if !containsKey(keys, k) {
+			if err := v.putRow(s, txn, k, nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(keys) == 0 {
+		return txn.Delete(srcKey)
+	}
+	entry := badger.NewEntry(srcKey, encodeKeyList(keys))
+	entry.ExpiresAt = e.ExpiresAt
+	return txn.SetEntry(entry)
+}
+
+// viewRowOwner — ключ источника, отображающийся в строку, и значение строки от него.
+type viewRowOwner struct {
+	key       []byte
+	value     []byte
+	expiresAt uint64
+}
+
+// putRow пересобирает строку rowKey: cur (если не nil) становится её текущим владельцем, прочие
+// владельцы остаются, пока их источник жив и всё ещё отображается в rowKey.
+// Без владельцев строка удаляется.
+func (v *view) putRow(s *Store, txn *badger.Txn, rowKey []byte, cur *viewRowOwner) error {
+	ownerKey := append(append([]byte(nil), v.ownerPrefix...), rowKey...)
+	prev, err := v.readKeyList(txn, ownerKey)
+	if err != nil {
+		return err
+	}
+
+	var owners [][]byte
+	var value []byte
+	var expiresAt uint64
+	if cur != nil {
+		owners, value, expiresAt = [][]byte{cur.key}, cur.value, cur.expiresAt
+	}
+	for _, k := range prev {
+		if cur != nil && bytes.Equal(k, cur.key) {
+			continue
+		}
+		o, err := v.ownerRow(s, txn, k, rowKey)
+		if err != nil {
+			return err
+		}
+		if o == nil {
+			continue
+		}
+		if len(owners) == 0 {
+			value, expiresAt = o.value, o.expiresAt
+		} else {
+			expiresAt = laterExpiry(expiresAt, o.expiresAt)
+		}
+		owners = append(owners, k)
+	}
+
+	rowFull := append(append([]byte(nil), v.dataPrefix...), rowKey...)
+	if len(owners) == 0 {
+		if err := txn.Delete(rowFull); err != nil {
+			return err
+		}
+		return txn.Delete(ownerKey)
+	}
+	entry := badger.NewEntry(rowFull, value)
+	entry.ExpiresAt = expiresAt
+	if err := txn.SetEntry(entry); err != nil {
+		return err
+	}
+	entry = badger.NewEntry(ownerKey, encodeKeyList(owners))
+	entry.ExpiresAt = expiresAt
+	return txn.SetEntry(entry)
+}
+
+// ownerRow — строка rowKey от ключа источника key по его текущему значению;
+// nil, если источник удалён, истёк или больше не отображается в rowKey.
+func (v *view) ownerRow(s *Store, txn *badger.Txn, key, rowKey []byte) (*viewRowOwner, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.expired(item) {
+		return nil, nil
+	}
+	value, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, nil
+	}
+	for _, row := range v.mapFn(KV{Key: key, Value: value}) {
+		if bytes.Equal(row.Key, rowKey) {
+			return &viewRowOwner{key: key, value: row.Value, expiresAt: item.ExpiresAt()}, nil
+		}
+	}
+	return nil, nil
+}
+
+// readKeyList читает список ключей по служебному ключу представления; отсутствие ключа — пустой список.
+func (v *view) readKeyList(txn *badger.Txn, key []byte) ([][]byte, error) {
+	item, err := txn.Get(key)
+	if errors.Is(err, badger.ErrKeyNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	raw, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := decodeKeyList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("view %q: %w", v.name, err)
+	}
+	return keys, nil
+}
+
+func containsKey(keys [][]byte, key []byte) bool {
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rebuild полностью перестраивает представление name по текущему содержимому источника.
+// Инкрементальные обновления этого представления на время перестройки приостанавливаются.
+// Перестройка видна в Jobs (прогресс — прочитанные ключи источника).
+func (r *ViewRegistry) Rebuild(ctx context.Context, name string) (err error) {
+	ctx, job := r.store.StartJob(ctx, JobViewRebuild, name)
+	defer func() { job.Finish(err) }()
+	v, err := r.view(name)
+	if err != nil {
+		return err
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	base := append(append([]byte(nil), viewsPrefix...), name+":"...)
+	err = r.store.withCompactions("drop prefix", func() error { return r.store.db.DropPrefix(base) })
+	if err != nil {
+		return fmt.Errorf("drop view %q: %w", name, err)
+	}
+
+	batch := make([]KVEvent, 0, viewRebuildBatch)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := r.applyEvents(v, batch)
+		batch = batch[:0]
+		return err
+	}
+	err = r.scanSource(ctx, v, func(e KVEvent) error {
+		batch = append(batch, e)
+		job.AddDone(1)
+		if len(batch) >= viewRebuildBatch {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		return fmt.Errorf("rebuild view %q: %w", name, err)
+	}
+	v.lastRebuildAt.Store(r.store.clock.Now().UnixNano())
+	return nil
+}
+
+// scanSource — скан источника для Rebuild: как ScanPrefix, но с ExpiresAt записей, чтобы строки
+// перестроенного представления истекали вместе с источником.
+func (r *ViewRegistry) scanSource(ctx context.Context, v *view, fn func(e KVEvent) error) error {
+	s := r.store
+	if err := s.checkAccess(ctx, AccessScan, v.sourcePrefix); err != nil {
+		return err
+	}
+	ctx, done := s.trackRead(ctx, "view_rebuild")
+	defer done()
+	return s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Prefix = v.sourcePrefix
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Seek(v.sourcePrefix); it.ValidForPrefix(v.sourcePrefix); it.Next() {
+			item := it.Item()
+			if s.expired(item) || hiddenSystemKey(v.sourcePrefix, item.Key()) {
+				continue
+			}
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			if err := s.scanLimit.wait(ctx); err != nil {
+				return err
+			}
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			if err := fn(KVEvent{Key: item.KeyCopy(nil), Value: val, Version: item.Version(), ExpiresAt: item.ExpiresAt()}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ScanView обходит строки представления с ключами, начинающимися на prefix.
+// Ключи передаются без служебного префикса представления.
+func (r *ViewRegistry) ScanView(name string, prefix []byte, limit int, fn func(kv KV) error) error {
+	v, err := r.view(name)
+	if err != nil {
+		return err
+	}
+	full := append(append([]byte(nil), v.dataPrefix...), prefix...)
+	return r.store.ScanPrefix(full, limit, func(kv KV) error {
+		kv.Key = kv.Key[len(v.dataPrefix):]
+		return fn(kv)
+	})
+}
+
+// Stats возвращает состояние всех представлений.
+func (r *ViewRegistry) Stats() []ViewStats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]ViewStats, 0, len(r.views))
+	running := r.running.Load()
+	for _, v := range r.views {
+		out = append(out, ViewStats{
+			Name:               v.name,
+			Running:            running,
+			Applied:            v.applied.Load(),
+			Errors:             v.errors.Load(),
+			LastAppliedVersion: v.lastVersion.Load(),
+			LastAppliedAt:      unixNanoTime(v.lastAppliedAt.Load()),
+			Lag:                time.Duration(v.lag.Load()),
+			LastRebuildAt:      unixNanoTime(v.lastRebuildAt.Load()),
+		})
+	}
+	return out
+}
+
+func (r *ViewRegistry) view(name string) (*view, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.views[name]
+	if !ok {
+		return nil, fmt.Errorf("view %q is not registered", name)
+	}
+	return v, nil
+}
+
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
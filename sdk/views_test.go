@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestViewRegistry_CollidingRows(t *testing.T) {
+	s, err := Open(context.Background(), Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// индекс «город → пользователь»: u:1 и u:2 отображаются в одну строку
+	r := NewViewRegistry(s)
+	if err := r.RegisterView("by-city", []byte("u:"), func(kv KV) []KV {
+		return []KV{{Key: kv.Value, Value: kv.Key}}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = r.Run(ctx) }()
+
+	var applied uint64
+	apply := func(fn func() error) {
+		t.Helper()
+		if err := fn(); err != nil {
+			t.Fatal(err)
+		}
+		applied++
+		deadline := time.Now().Add(5 * time.Second)
+		for r.Stats()[0].Applied < applied {
+			if time.Now().After(deadline) {
+				t.Fatalf("view applied %d of %d changes", r.Stats()[0].Applied, applied)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	rows := func() map[string]string {
+		t.Helper()
+		out := make(map[string]string)
+		if err := r.ScanView("by-city", nil, 0, func(kv KV) error {
+			out[string(kv.Key)] = string(kv.Value)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	// Watch может подписаться не сразу: ждём, пока представление увидит первую запись
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Stats()[0].Applied == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("view is not running")
+		}
+		if err := s.Set([]byte("u:1"), []byte("msk"), 0); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	applied = r.Stats()[0].Applied
+	apply(func() error { return s.Set([]byte("u:2"), []byte("msk"), 0) })
+	if got := rows(); got["msk"] != "u:2" {
+		t.Fatalf("rows after collision = %v", got)
+	}
+
+	// удаление u:1 не должно удалять строку, в которую отображается и u:2
+	apply(func() error { return s.Delete([]byte("u:1")) })
+	if got := rows(); got["msk"] != "u:2" {
+		t.Fatalf("row of u:2 lost after deleting u:1: %v", got)
+	}
+
+	apply(func() error { return s.Set([]byte("u:1"), []byte("msk"), 0) })
+	// u:2 уходит из строки: значение переходит к оставшемуся владельцу u:1
+	apply(func() error { return s.Set([]byte("u:2"), []byte("spb"), 0) })
+	if got := rows(); len(got) != 2 || got["msk"] != "u:1" || got["spb"] != "u:2" {
+		t.Fatalf("rows after moving u:2 = %v", got)
+	}
+
+	apply(func() error { return s.Delete([]byte("u:1")) })
+	if got := rows(); len(got) != 1 || got["spb"] != "u:2" {
+		t.Fatalf("rows after deleting the last owner = %v", got)
+	}
+}
@@ -0,0 +1,92 @@
+package sdk
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/dgraph-io/badger/v4/pb"
+)
+
+// KVEvent — изменение ключа, полученное через Watch.
+// Удаление приходит как событие с пустым Value: по событию их не отличить от записи пустого значения.
+type KVEvent struct {
+	Key       []byte
+	Value     []byte
+	Version   uint64
+	ExpiresAt uint64
+}
+
+// Watch вызывает fn для пачек изменений ключей с любым из префиксов (пустой список — все ключи).
+// Блокируется до отмены ctx (возвращает nil) или ошибки fn.
+// Подписка видит только записи, сделанные через этот процесс: процессы, открывшие ту же
+// директорию в ReadOnly, записей писателя не увидят — для них см. InvalidationBroadcaster.
+func (s *Store) Watch(ctx context.Context, prefixes [][]byte, fn func(events []KVEvent) error) error {
+	if fn == nil {
+		return errors.New("nil watch callback")
+	}
+	if len(prefixes) == 0 {
+		prefixes = [][]byte{{}}
+	}
+	matches := make([]pb.Match, 0, len(prefixes))
+	for _, p := range prefixes {
+		matches = append(matches, pb.Match{Prefix: p})
+	}
+
+	err := s.db.Subscribe(ctx, func(list *badger.KVList) error {
+		events := make([]KVEvent, 0, len(list.Kv))
+		for _, kv := range list.Kv {
+			events = append(events, KVEvent{
+				Key:       kv.Key,
+				Value:     kv.Value,
+				Version:   kv.Version,
+				ExpiresAt: kv.ExpiresAt,
+			})
+		}
+		return fn(events)
+	}, matches)
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil
+	}
+	return err
+}
+
+// subscriptionMarkerPrefix — служебные ключи, которыми фоновые подписки Store (PrefixDigest, ConfigStore)
+// убеждаются, что подписка уже работает.
+var subscriptionMarkerPrefix = []byte("!watch:")
+
+var subscriptionMarkerSeq atomic.Uint64
+
+// newSubscriptionMarker возвращает уникальный в процессе ключ-маркер; его нужно включить в префиксы Watch.
+func newSubscriptionMarker() []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(nil), subscriptionMarkerPrefix...), subscriptionMarkerSeq.Add(1))
+}
+
+// awaitSubscription ждёт, пока marker не придёт в подписку (subscribed закрывается её обработчиком).
+// Subscribe регистрирует подписчика асинхронно, поэтому маркер пишется повторно, пока не дойдёт;
+// после возврата снимок данных не пропустит изменений, сделанных до начала подписки.
+func (s *Store) awaitSubscription(ctx context.Context, marker []byte, subscribed <-chan struct{}) error {
+	defer func() {
+		_ = s.db.Update(func(txn *badger.Txn) error { return txn.Delete(marker) })
+	}()
+	// служебный опрос, а не время данных, поэтому реальные часы, а не s.clock
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if err := s.db.Update(func(txn *badger.Txn) error { return txn.Set(marker, []byte{1}) }); err != nil {
+			return err
+		}
+		select {
+		case <-subscribed:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.bg.Done():
+			return errors.New("store is closed")
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package sdk
+
+import (
+	"expvar"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// Усиление записи для планирования ёмкости и оценки износа диска: сколько байт Badger записал на
+// диск (WAL/memtable → L0, value-log, компакции по уровням) на байт, записанный пользователем.
+// Байтовые счётчики — expvar Badger, общие на процесс: при нескольких Store в процессе они
+// суммируются. Badger не считает компакции и задержки записи в метриках, поэтому их Store берёт
+// из сообщений Badger в лог (перехватываются до фильтра по уровню логирования) — это только
+// медленные компакции (дольше 2s) и остановки записи из-за L0 дольше 1s; эти счётчики — свои у
+// каждого Store.
+
+// WriteAmpStats — результат Store.WriteAmpStats; счётчики — с запуска процесса (байты) и с Open (события).
+type WriteAmpStats struct {
+	// UserBytes — байты записей пользователя (ключ, значение, метаданные).
+	UserBytes int64
+	// L0Bytes — байты, записанные в memtable: на диск они попадают дважды — в её WAL (.mem) и в
+	// таблицу L0 при сбросе. VLogBytes — записанные в value-log.
+	L0Bytes   int64
+	VLogBytes int64
+	// CompactionBytes — записано компакциями в уровень (индекс — целевой уровень), CompactionTotal — сумма.
+	CompactionBytes []int64
+	CompactionTotal int64
+	// WriteAmplification — (2·L0Bytes + VLogBytes + CompactionTotal) / UserBytes; 0 — записей ещё не было.
+	WriteAmplification float64
+	// CompactingTables — таблицы в компакциях, идущих сейчас.
+	CompactingTables int64
+
+	// SlowCompactions — компакции дольше 2s по целевому уровню.
+	SlowCompactions []uint64
+	// L0Stalls — остановки записи, пока L0 не разгрузится (дольше 1s), L0StallTime — их суммарная длительность.
+	L0Stalls    uint64
+	L0StallTime time.Duration
+}
+
+// WriteAmpStats возвращает усиление записи и статистику компакций.
+func (s *Store) WriteAmpStats() WriteAmpStats {
+	levels := s.db.Opts().MaxLevels
+	st := WriteAmpStats{
+		UserBytes:        expvarInt("badger_write_bytes_user"),
+		L0Bytes:          expvarInt("badger_write_bytes_l0"),
+		VLogBytes:        expvarInt("badger_write_bytes_vlog"),
+		CompactionBytes:  make([]int64, levels),
+		CompactingTables: expvarInt("badger_compaction_current_num_lsm"),
+	}
+	if m, ok := expvar.Get("badger_write_bytes_compaction").(*expvar.Map); ok {
+		for l := range st.CompactionBytes {
+			st.CompactionBytes[l] = expvarMapInt(m, fmt.Sprintf("l%d", l))
+			st.CompactionTotal += st.CompactionBytes[l]
+		}
+	}
+	if st.UserBytes > 0 {
+		st.WriteAmplification = float64(2*st.L0Bytes+st.VLogBytes+st.CompactionTotal) / float64(st.UserBytes)
+	}
+	if ev := s.badgerEvents; ev != nil {
+		st.SlowCompactions = make([]uint64, len(ev.slowCompactions))
+		for l := range ev.slowCompactions {
+			st.SlowCompactions[l] = ev.slowCompactions[l].Load()
+		}
+		st.L0Stalls = ev.l0Stalls.Load()
+		st.L0StallTime = time.Duration(ev.l0StallNanos.Load())
+	}
+	return st
+}
+
+// badgerEventLogger передаёт сообщения Badger дальше и считает по ним события, которых нет в expvar.
+type badgerEventLogger struct {
+	next            badger.Logger // nil — сообщения не выводятся
+	slowCompactions []atomic.Uint64
+	l0Stalls        atomic.Uint64
+	l0StallNanos    atomic.Int64
+}
+
+func newBadgerEventLogger(next badger.Logger, levels int) *badgerEventLogger {
+	return &badgerEventLogger{next: next, slowCompactions: make([]atomic.Uint64, levels)}
+}
+
+func (l *badgerEventLogger) Infof(format string, args ...any) {
+	switch {
+	case strings.HasPrefix(format, "L0 was stalled for"):
+		if d, ok := argAt[time.Duration](args, 0); ok {
+			l.l0Stalls.Add(1)
+			l.l0StallNanos.Add(int64(d))
+		}
+	case strings.Contains(format, "LOG Compact %d->%d"):
+		// "[%d]%s LOG Compact %d->%d ...": id, метка, исходный и целевой уровень
+		if to, ok := argAt[int](args, 3); ok && to >= 0 && to < len(l.slowCompactions) {
+			l.slowCompactions[to].Add(1)
+		}
+	}
+	if l.next != nil {
+		l.next.Infof(format, args...)
+	}
+}
+
+func (l *badgerEventLogger) Errorf(format string, args ...any) {
+	if l.next != nil {
+		l.next.Errorf(format, args...)
+	}
+}
+
+func (l *badgerEventLogger) Warningf(format string, args ...any) {
+	if l.next != nil {
+		l.next.Warningf(format, args...)
+	}
+}
+
+func (l *badgerEventLogger) Debugf(format string, args ...any) {
+	if l.next != nil {
+		l.next.Debugf(format, args...)
+	}
+}
+
+func argAt[T any](args []any, i int) (T, bool) {
+	var zero T
+	if i >= len(args) {
+		return zero, false
+	}
+	v, ok := args[i].(T)
+	return v, ok
+}
@@ -0,0 +1,210 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+)
+
+// WriteBatch — пакетная запись через badger.WriteBatch: записи копятся и коммитятся крупными
+// транзакциями асинхронно, что на порядок быстрее db.Update на каждый ключ. Записи пакета не
+// атомарны — часть может оказаться в базе, даже если Flush вернул ошибку.
+//
+// Ошибки отдельных записей (нет доступа, пустой или слишком большой ключ) не прерывают пакет:
+// метод возвращает их сразу, а Flush — все вместе через errors.Join. Ошибка коммита фатальна:
+// после неё все методы возвращают её же.
+//
+// Ключи и значения нельзя менять до Flush — Badger хранит их без копирования.
+type WriteBatch struct {
+	s    *Store
+	ctx  context.Context
+	opts WriteBatchOptions
+
+	mu      sync.Mutex
+	wb      *badger.WriteBatch
+	count   int
+	size    int64
+	written int
+	errs    []error
+	dropped int
+	fatal   error
+	done    bool
+}
+
+type WriteBatchOptions struct {
+	// MaxCount и MaxSize — предел записей и байт (ключ+значение) одной подпартии: при достижении
+	// накопленное коммитится и начинается новая. 0 — только внутренние пределы транзакции Badger,
+	// которые WriteBatch также обходит разбиением.
+	MaxCount int
+	MaxSize  int64
+	// MaxErrors — сколько ошибок записей хранить для Flush (по умолчанию 100); остальные только считаются.
+	MaxErrors int
+}
+
+// ErrBatchDone — запись в WriteBatch после Flush или Cancel.
+var ErrBatchDone = errors.New("write batch already flushed or canceled")
+
+// NewWriteBatch — NewWriteBatchContext с context.Background().
+func (s *Store) NewWriteBatch(opts ...WriteBatchOptions) *WriteBatch {
+	return s.NewWriteBatchContext(context.Background(), opts...)
+}
+
+// NewWriteBatchContext создаёт пакет, записи которого проверяются AccessController от имени principal
+// из ctx и ждут WriteRateLimit; отмена ctx прерывает ожидание.
+func (s *Store) NewWriteBatchContext(ctx context.Context, opts ...WriteBatchOptions) *WriteBatch {
+	var o WriteBatchOptions
+	if len(opts) > 0 {
+		o = opts[0]
+	}
+	if o.MaxErrors <= 0 {
+		o.MaxErrors = 100
+	}
+	return &WriteBatch{s: s, ctx: ctx, opts: o, wb: s.db.NewWriteBatch()}
+}
+
+// Set добавляет запись с TTL (0 — бессрочно), как Store.Set.
+func (b *WriteBatch) Set(key, value []byte, ttl time.Duration) error {
+	return b.SetWithMeta(key, value, ttl, 0)
+}
+
+// SetWithMeta — Set с пользовательским байтом метаданных Badger (item.UserMeta()), по которому,
+// например, фильтрует ScanPrefixFiltered.
+func (b *WriteBatch) SetWithMeta(key, value []byte, ttl time.Duration, meta byte) error {
+	if err := b.s.checkAccess(b.ctx, AccessWrite, key); err != nil {
+		return b.entryFailed(key, err)
+	}
+	if err := b.s.validateKey(b.ctx, key); err != nil {
+		return b.entryFailed(key, err)
+	}
+	b.s.noteWrite(key)
+	e := b.s.NewEntry(key, value, b.s.jitterTTL(b.ctx, ttl))
+	if meta != 0 {
+		e = e.WithMeta(meta)
+	}
+	return b.add(key, int64(len(key)+len(value)), func(wb *badger.WriteBatch) error { return wb.SetEntry(e) })
+}
+
+// Delete добавляет удаление key.
+func (b *WriteBatch) Delete(key []byte) error {
+	if err := b.s.checkAccess(b.ctx, AccessDelete, key); err != nil {
+		return b.entryFailed(key, err)
+	}
+	if err := checkSystemKey(key); err != nil {
+		return b.entryFailed(key, err)
+	}
+	return b.add(key, int64(len(key)), func(wb *badger.WriteBatch) error { return wb.Delete(key) })
+}
+
+func (b *WriteBatch) add(key []byte, size int64, op func(*badger.WriteBatch) error) error {
+	if err := b.s.writeLimit.wait(b.ctx); err != nil {
+		return b.entryFailed(key, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.stateLocked(); err != nil {
+		return err
+	}
+	if err := op(b.wb); err != nil {
+		if werr := b.wb.Error(); werr != nil {
+			b.fatal = werr
+			return werr
+		}
+		return b.entryFailedLocked(key, err)
+	}
+	b.count++
+	b.size += size
+	b.written++
+	if (b.opts.MaxCount > 0 && b.count >= b.opts.MaxCount) || (b.opts.MaxSize > 0 && b.size >= b.opts.MaxSize) {
+		if err := b.wb.Flush(); err != nil {
+			b.fatal = err
+			return err
+		}
+		b.wb, b.count, b.size = b.s.db.NewWriteBatch(), 0, 0
+	}
+	return nil
+}
+
+func (b *WriteBatch) stateLocked() error {
+	if b.fatal != nil {
+		return b.fatal
+	}
+	if b.done {
+		return ErrBatchDone
+	}
+	return nil
+}
+
+func (b *WriteBatch) entryFailed(key []byte, err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if serr := b.stateLocked(); serr != nil {
+		return serr
+	}
+	return b.entryFailedLocked(key, err)
+}
+
+func (b *WriteBatch) entryFailedLocked(key []byte, err error) error {
+	err = fmt.Errorf("key %q: %w", key, err)
+	if len(b.errs) < b.opts.MaxErrors {
+		b.errs = append(b.errs, err)
+	} else {
+		b.dropped++
+	}
+	return err
+}
+
+// Flush коммитит накопленное и ждёт завершения всех подпартий. Возвращает ошибку коммита и ошибки
+// отдельных записей (errors.Join); errors.Is находит в ней, например, ErrAccessDenied.
+// После Flush пакет использовать нельзя.
+func (b *WriteBatch) Flush() error {
+	start := b.s.clock.Now()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return ErrBatchDone
+	}
+	b.done = true
+	errs := b.errs
+	if b.fatal != nil {
+		b.wb.Cancel()
+		errs = append([]error{b.fatal}, errs...)
+	} else if err := b.wb.Flush(); err != nil {
+		b.fatal = err
+		errs = append([]error{err}, errs...)
+	}
+	if b.dropped > 0 {
+		errs = append(errs, fmt.Errorf("and %d more failed entries", b.dropped))
+	}
+	return b.s.traceOp(b.ctx, "write_batch", nil, start, 1, errors.Join(errs...))
+}
+
+// Cancel отбрасывает ещё не закоммиченные записи; уже отправленные подпартии остаются в базе.
+// Безопасно вызывать после Flush, например через defer.
+func (b *WriteBatch) Cancel() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return
+	}
+	b.done = true
+	b.wb.Cancel()
+}
+
+// Err возвращает ошибку коммита, если она была, — в отличие от ошибок отдельных записей,
+// после неё пакет не принимает записей. Позволяет отличить сбой пакета от отклонённой записи.
+func (b *WriteBatch) Err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.fatal
+}
+
+// Written — число записей, принятых в пакет (без отклонённых).
+func (b *WriteBatch) Written() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.written
+}
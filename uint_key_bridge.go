@@ -0,0 +1,107 @@
+package memory_storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+// uint64ToByte8 — ключ SimpleFastStorage для id в той же кодировке, что sdk.EncodeUint64Key (big-endian).
+func uint64ToByte8(id uint64) byte8 {
+	var k byte8
+	binary.BigEndian.PutUint64(k[:], id)
+	return k
+}
+
+// AddUint добавляет значение под числовым ключом id.
+func (s *SimpleFastStorage) AddUint(id uint64, value []byte) {
+	s.Add(uint64ToByte8(id), value)
+}
+
+// GetUint возвращает значение числового ключа id.
+func (s *SimpleFastStorage) GetUint(id uint64) ([]byte, bool) {
+	return s.Get(uint64ToByte8(id))
+}
+
+// UintKeysBitmap собирает id живых ключей store в bitmap.
+func UintKeysBitmap(ctx context.Context, store *sdk.UintKeyStore) (*roaring64.Bitmap, error) {
+	if store == nil {
+		return nil, errors.New("store must be not nil")
+	}
+	bm := roaring64.NewBitmap()
+	batch := make([]uint64, 0, 4096)
+	err := store.IDs(ctx, 0, 0, func(id uint64) error {
+		// id идут по возрастанию — AddMany кладёт их в контейнеры без поиска
+		if batch = append(batch, id); len(batch) == cap(batch) {
+			bm.AddMany(batch)
+			batch = batch[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("collect uint keys: %w", err)
+	}
+	bm.AddMany(batch)
+	return bm, nil
+}
+
+// WarmSetFromUintKeys добавляет id живых ключей store во множество set. Возвращает число добавленных id.
+func WarmSetFromUintKeys(ctx context.Context, store *sdk.UintKeyStore, set MemorySetStorage) (uint64, error) {
+	if set == nil {
+		return 0, errors.New("set must be not nil")
+	}
+	bm, err := UintKeysBitmap(ctx, store)
+	if err != nil {
+		return 0, err
+	}
+	set.UpsertMany(bm.ToArray())
+	return bm.GetCardinality(), nil
+}
+
+// UintKeyDiff — расхождение ключей Store и множества.
+type UintKeyDiff struct {
+	InStore uint64
+	InSet   uint64
+	// OnlyInStore — id, которые есть в Store, но не во множестве; OnlyInSet — наоборот.
+	OnlyInStore *roaring64.Bitmap
+	OnlyInSet   *roaring64.Bitmap
+}
+
+// Equal сообщает, совпадают ли множества.
+func (d UintKeyDiff) Equal() bool {
+	return d.OnlyInStore.IsEmpty() && d.OnlyInSet.IsEmpty()
+}
+
+// CompareUintKeys сверяет id живых ключей store с содержимым set. Множество читается через Snapshot,
+// поэтому писатели set не блокируются; записи, пришедшие в Store и set во время сверки, могут дать
+// ложные расхождения — их стоит перепроверить повторной сверкой.
+func CompareUintKeys(ctx context.Context, store *sdk.UintKeyStore, set MemorySetStorage) (UintKeyDiff, error) {
+	if set == nil {
+		return UintKeyDiff{}, errors.New("set must be not nil")
+	}
+	data, err := set.Snapshot()
+	if err != nil {
+		return UintKeyDiff{}, fmt.Errorf("snapshot set: %w", err)
+	}
+	setBm := roaring64.NewBitmap()
+	if len(data) > 0 {
+		if _, err := setBm.ReadFrom(bytes.NewReader(data)); err != nil {
+			return UintKeyDiff{}, fmt.Errorf("read set snapshot: %w", err)
+		}
+	}
+	storeBm, err := UintKeysBitmap(ctx, store)
+	if err != nil {
+		return UintKeyDiff{}, err
+	}
+	return UintKeyDiff{
+		InStore:     storeBm.GetCardinality(),
+		InSet:       setBm.GetCardinality(),
+		OnlyInStore: roaring64.AndNot(storeBm, setBm),
+		OnlyInSet:   roaring64.AndNot(setBm, storeBm),
+	}, nil
+}
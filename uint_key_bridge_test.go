@@ -0,0 +1,70 @@
+package memory_storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/PavelAgarkov/memory-storage/sdk"
+)
+
+func TestUintKeyStore_RangeOrderAndCompareWithSet(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store, err := sdk.Open(ctx, sdk.Options{InMemory: true}, nil)
+	if err != nil {
+		t.Fatalf("sdk.Open: %v", err)
+	}
+	defer store.Close()
+
+	ids := sdk.NewUintKeyStore(store, []byte("goods:"))
+	for _, id := range []uint64{9, 10, 256, 1 << 40, 3} {
+		if err := ids.Set(ctx, id, []byte("v"), 0); err != nil {
+			t.Fatalf("Set(%d): %v", id, err)
+		}
+	}
+	// чужой ключ под тем же префиксом не должен попасть в скан
+	if err := store.Set([]byte("goods:meta"), []byte("x"), 0); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	var got []uint64
+	if err := ids.IDs(ctx, 4, 1<<40, func(id uint64) error {
+		got = append(got, id)
+		return nil
+	}); err != nil {
+		t.Fatalf("IDs: %v", err)
+	}
+	if len(got) != 3 || got[0] != 9 || got[1] != 10 || got[2] != 256 {
+		t.Fatalf("IDs [4, 2^40) = %v, want [9 10 256]", got)
+	}
+
+	set := newLoggedBitmapStorage("uint_keys", nil, nil)
+	set.UpsertMany([]uint64{3, 9, 10, 256, 77})
+	diff, err := CompareUintKeys(ctx, ids, set)
+	if err != nil {
+		t.Fatalf("CompareUintKeys: %v", err)
+	}
+	if diff.Equal() || diff.InStore != 5 || diff.InSet != 5 {
+		t.Fatalf("diff = %+v", diff)
+	}
+	if diff.OnlyInStore.GetCardinality() != 1 || !diff.OnlyInStore.Contains(1<<40) {
+		t.Fatalf("OnlyInStore = %v", diff.OnlyInStore.ToArray())
+	}
+	if diff.OnlyInSet.GetCardinality() != 1 || !diff.OnlyInSet.Contains(77) {
+		t.Fatalf("OnlyInSet = %v", diff.OnlyInSet.ToArray())
+	}
+
+	set.RemoveMany([]uint64{77})
+	if _, err := WarmSetFromUintKeys(ctx, ids, set); err != nil {
+		t.Fatalf("WarmSetFromUintKeys: %v", err)
+	}
+	if diff, err = CompareUintKeys(ctx, ids, set); err != nil || !diff.Equal() {
+		t.Fatalf("after warm diff = %+v, err = %v", diff, err)
+	}
+
+	fast := NewSimpleFastStorage(4)
+	fast.AddUint(1<<40, []byte("big"))
+	if v, ok := fast.GetUint(1 << 40); !ok || string(v) != "big" {
+		t.Fatalf("GetUint = %q, %v", v, ok)
+	}
+}